@@ -3,6 +3,9 @@
 package webrtc
 
 import (
+	"runtime"
+	"sync"
+
 	"github.com/pion/interceptor"
 	"github.com/pion/logging"
 )
@@ -15,11 +18,17 @@ type API struct {
 	settingEngine *SettingEngine
 	mediaEngine   *MediaEngine
 	interceptor   interceptor.Interceptor
+
+	peerConnections *peerConnectionSet
+	networkMonitor  *apiNetworkMonitor
 }
 
 // NewAPI Creates a new API object for keeping semi-global settings to WebRTC objects
 func NewAPI(options ...func(*API)) *API {
-	a := &API{}
+	a := &API{
+		peerConnections: &peerConnectionSet{peerConnections: map[*PeerConnection]struct{}{}},
+		networkMonitor:  &apiNetworkMonitor{},
+	}
 
 	for _, o := range options {
 		o(a)
@@ -29,10 +38,19 @@ func NewAPI(options ...func(*API)) *API {
 		a.settingEngine = &SettingEngine{}
 	}
 
-	if a.settingEngine.LoggerFactory == nil {
+	ownLoggerFactory := a.settingEngine.LoggerFactory == nil
+	if ownLoggerFactory {
 		a.settingEngine.LoggerFactory = logging.NewDefaultLoggerFactory()
 	}
 
+	// Copies of a SettingEngine share its levels, API.SetLogLevel only changes this API
+	a.settingEngine.logLevels = a.settingEngine.logLevels.clone()
+	a.settingEngine.LoggerFactory = &levelLoggerFactory{
+		levels:           a.settingEngine.logLevels,
+		loggerFactory:    a.settingEngine.LoggerFactory,
+		ownLoggerFactory: ownLoggerFactory,
+	}
+
 	if a.mediaEngine == nil {
 		a.mediaEngine = &MediaEngine{}
 	}
@@ -71,3 +89,87 @@ func WithInterceptorRegistry(interceptorRegistry *interceptor.Registry) func(a *
 		a.interceptor = interceptorRegistry.Build()
 	}
 }
+
+// SetLogLevel changes the log level of a scope for all PeerConnections of the API,
+// including the ones already created. See SettingEngine.SetLogLevel for the scopes.
+// Other APIs created with the same SettingEngine keep their levels.
+func (api *API) SetLogLevel(scope string, level logging.LogLevel) {
+	api.settingEngine.logLevels.set(scope, level)
+}
+
+// GetStats returns the StatsReport of every PeerConnection created by the API that
+// hasn't been closed yet. All stats share the same timestamp, so the reports can be
+// compared with each other. The PeerConnections are collected concurrently, by at
+// most GOMAXPROCS goroutines.
+//
+// The API holds on to its PeerConnections until they are closed, also when the
+// application dropped them. Every PeerConnection must be closed with Close, or it
+// is reported and kept in memory for as long as the API is.
+func (api *API) GetStats() map[*PeerConnection]StatsReport {
+	peerConnections := api.peerConnections.list()
+	timestamp := statsTimestampNow()
+
+	var (
+		reports   = make(map[*PeerConnection]StatsReport, len(peerConnections))
+		reportsMu sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(peerConnections) {
+		workers = len(peerConnections)
+	}
+
+	queue := make(chan *PeerConnection)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for pc := range queue {
+				report := pc.getStats(timestamp)
+
+				reportsMu.Lock()
+				reports[pc] = report
+				reportsMu.Unlock()
+			}
+		}()
+	}
+
+	for _, pc := range peerConnections {
+		queue <- pc
+	}
+	close(queue)
+	wg.Wait()
+
+	return reports
+}
+
+// peerConnectionSet holds the PeerConnections of an API that haven't been closed.
+// They are only removed by PeerConnection.Close.
+type peerConnectionSet struct {
+	mu              sync.Mutex
+	peerConnections map[*PeerConnection]struct{}
+}
+
+func (s *peerConnectionSet) add(pc *PeerConnection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peerConnections[pc] = struct{}{}
+}
+
+func (s *peerConnectionSet) remove(pc *PeerConnection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.peerConnections, pc)
+}
+
+func (s *peerConnectionSet) list() []*PeerConnection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peerConnections := make([]*PeerConnection, 0, len(s.peerConnections))
+	for pc := range s.peerConnections {
+		peerConnections = append(peerConnections, pc)
+	}
+	return peerConnections
+}
@@ -39,3 +39,43 @@ func TestNewAPI_Options(t *testing.T) {
 		t.Error("Failed to set media engine")
 	}
 }
+
+func TestAPI_GetStats(t *testing.T) {
+	api := NewAPI()
+	assert.Empty(t, api.GetStats())
+
+	peerConnections := []*PeerConnection{}
+	for i := 0; i < 3; i++ {
+		pc, err := api.NewPeerConnection(Configuration{})
+		assert.NoError(t, err)
+		peerConnections = append(peerConnections, pc)
+	}
+
+	reports := api.GetStats()
+	assert.Len(t, reports, 3)
+
+	var timestamp StatsTimestamp
+	for _, pc := range peerConnections {
+		report, ok := reports[pc]
+		assert.True(t, ok)
+
+		stats, ok := report.GetConnectionStats(pc)
+		assert.True(t, ok)
+		if timestamp == 0 {
+			timestamp = stats.Timestamp
+		}
+		assert.Equal(t, timestamp, stats.Timestamp)
+	}
+
+	// Closed PeerConnections are not collected anymore
+	assert.NoError(t, peerConnections[0].Close())
+	reports = api.GetStats()
+	assert.Len(t, reports, 2)
+	_, ok := reports[peerConnections[0]]
+	assert.False(t, ok)
+
+	for _, pc := range peerConnections[1:] {
+		assert.NoError(t, pc.Close())
+	}
+	assert.Empty(t, api.GetStats())
+}
@@ -15,6 +15,16 @@ func (b *atomicBool) set(value bool) { // nolint: unparam
 	atomic.StoreInt32(&(b.val), i)
 }
 
+// swap sets the value and returns the previous one
+func (b *atomicBool) swap(value bool) bool {
+	var i int32
+	if value {
+		i = 1
+	}
+
+	return atomic.SwapInt32(&(b.val), i) != 0
+}
+
 func (b *atomicBool) get() bool {
 	return atomic.LoadInt32(&(b.val)) != 0
 }
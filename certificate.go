@@ -41,14 +41,14 @@ func NewCertificate(key crypto.PrivateKey, tpl x509.Certificate) (*Certificate,
 		tpl.SignatureAlgorithm = x509.SHA256WithRSA
 		certDER, err = x509.CreateCertificate(rand.Reader, &tpl, &tpl, pk, sk)
 		if err != nil {
-			return nil, &rtcerr.UnknownError{Err: err}
+			return nil, &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 		}
 	case *ecdsa.PrivateKey:
 		pk := sk.Public()
 		tpl.SignatureAlgorithm = x509.ECDSAWithSHA256
 		certDER, err = x509.CreateCertificate(rand.Reader, &tpl, &tpl, pk, sk)
 		if err != nil {
-			return nil, &rtcerr.UnknownError{Err: err}
+			return nil, &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 		}
 	default:
 		return nil, &rtcerr.NotSupportedError{Err: ErrPrivateKeyType}
@@ -56,7 +56,7 @@ func NewCertificate(key crypto.PrivateKey, tpl x509.Certificate) (*Certificate,
 
 	cert, err := x509.ParseCertificate(certDER)
 	if err != nil {
-		return nil, &rtcerr.UnknownError{Err: err}
+		return nil, &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 	}
 
 	return &Certificate{privateKey: key, x509Cert: cert, statsID: fmt.Sprintf("certificate-%d", time.Now().UnixNano())}, nil
@@ -105,11 +105,11 @@ func (c Certificate) GetFingerprints() ([]DTLSFingerprint, error) {
 	for _, algo := range fingerprintAlgorithms {
 		name, err := fingerprint.StringFromHash(algo)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateCertificateFingerprint, err)
+			return nil, wrapError(ErrFailedToGenerateCertificateFingerprint, err)
 		}
 		value, err := fingerprint.Fingerprint(c.x509Cert, algo)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateCertificateFingerprint, err)
+			return nil, wrapError(ErrFailedToGenerateCertificateFingerprint, err)
 		}
 		res[i] = DTLSFingerprint{
 			Algorithm: name,
@@ -126,7 +126,7 @@ func GenerateCertificate(secretKey crypto.PrivateKey) (*Certificate, error) {
 	origin := make([]byte, 16)
 	/* #nosec */
 	if _, err := rand.Read(origin); err != nil {
-		return nil, &rtcerr.UnknownError{Err: err}
+		return nil, &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 	}
 
 	// Max random value, a 130-bits integer, i.e 2^130 - 1
@@ -136,7 +136,7 @@ func GenerateCertificate(secretKey crypto.PrivateKey) (*Certificate, error) {
 	/* #nosec */
 	serialNumber, err := rand.Int(rand.Reader, maxBigInt)
 	if err != nil {
-		return nil, &rtcerr.UnknownError{Err: err}
+		return nil, &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 	}
 
 	return NewCertificate(secretKey, x509.Certificate{
@@ -173,7 +173,7 @@ func (c Certificate) collectStats(report *statsReportCollector) error {
 	base64Certificate := base64.RawURLEncoding.EncodeToString(c.x509Cert.Raw)
 
 	stats := CertificateStats{
-		Timestamp:            statsTimestampFrom(time.Now()),
+		Timestamp:            report.timestamp,
 		Type:                 StatsTypeCertificate,
 		ID:                   c.statsID,
 		Fingerprint:          fingerPrintAlgo[0].Value,
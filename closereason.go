@@ -0,0 +1,237 @@
+// +build !js
+
+package webrtc
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultControlDataChannelID is the ID of the negotiated DataChannel that carries
+	// close reasons, unless it is set with SettingEngine.SetCloseReasonsDataChannelID
+	defaultControlDataChannelID = sctpMaxChannels - 1
+
+	controlMessageTypeDataChannelClose    = 0x00
+	controlMessageTypePeerConnectionClose = 0x01
+
+	controlMessageHeaderLength = 5
+
+	// closeReasonTimeout is how long CloseWithReason waits for the remote to close
+	// the DataChannel before it closes it itself
+	closeReasonTimeout = 5 * time.Second
+)
+
+// CloseReason tells why a DataChannel or PeerConnection has been closed by
+// CloseWithReason, see SettingEngine.EnableCloseReasons
+type CloseReason struct {
+	// Code is defined by the application, e.g. to tell a graceful shutdown from errors
+	Code uint16
+
+	// Reason is a human readable description
+	Reason string
+
+	// Remote is true if the remote peer closed with this reason
+	Remote bool
+}
+
+// CloseWithReason closes the DataChannel like Close, and tells the remote the reason
+// for it. The remote closes the DataChannel once it received the reason, afterwards
+// CloseReason returns it on both peers. Close reasons have to be enabled on both peers
+// with SettingEngine.EnableCloseReasons.
+func (d *DataChannel) CloseWithReason(code uint16, reason string) error {
+	d.mu.RLock()
+	sctpTransport := d.sctpTransport
+	id := d.id
+	d.mu.RUnlock()
+
+	if sctpTransport == nil || id == nil {
+		return ErrSCTPNotEstablished
+	}
+
+	control := sctpTransport.getControlDataChannel()
+	if control == nil {
+		return errCloseReasonsNotEnabled
+	}
+
+	d.setCloseReason(&CloseReason{Code: code, Reason: reason})
+	if err := control.Send(marshalControlMessage(controlMessageTypeDataChannelClose, *id, code, reason)); err != nil {
+		return err
+	}
+	d.setReadyState(DataChannelStateClosing)
+
+	// In case the remote doesn't close it, e.g. because it is closing the PeerConnection
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closeReasonTimer == nil && d.ReadyState() != DataChannelStateClosed {
+		d.closeReasonTimer = time.AfterFunc(closeReasonTimeout, func() {
+			if err := d.Close(); err != nil {
+				d.log.Warnf("Failed to close DataChannel: %v", err)
+			}
+		})
+	}
+	return nil
+}
+
+// CloseReason returns the reason the DataChannel has been closed with by either
+// peer, or nil if it was closed without a reason or is still open
+func (d *DataChannel) CloseReason() *CloseReason {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.closeReason
+}
+
+func (d *DataChannel) setCloseReason(reason *CloseReason) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closeReason == nil {
+		d.closeReason = reason
+	}
+}
+
+// CloseWithReason ends the PeerConnection like Close, and tells the remote the
+// reason for it. The remote shuts down SCTP gracefully once it received the reason,
+// which CloseWithReason waits for until ctx is done. Afterwards CloseReason returns
+// the reason on both peers. Close reasons have to be enabled on both peers with
+// SettingEngine.EnableCloseReasons.
+func (pc *PeerConnection) CloseWithReason(ctx context.Context, code uint16, reason string) error {
+	if pc.isClosed.get() {
+		return nil
+	}
+
+	control := pc.sctpTransport.getControlDataChannel()
+	if control == nil {
+		return errCloseReasonsNotEnabled
+	}
+
+	pc.setCloseReason(&CloseReason{Code: code, Reason: reason})
+	if err := control.Send(marshalControlMessage(controlMessageTypePeerConnectionClose, 0, code, reason)); err != nil {
+		return err
+	}
+
+	// Closing right away could tear down SCTP before the remote read the reason
+	select {
+	case <-control.closed:
+	case <-ctx.Done():
+	}
+
+	return pc.Close()
+}
+
+// CloseReason returns the reason the PeerConnection has been closed with by either
+// peer, or nil if it was closed without a reason or is still open
+func (pc *PeerConnection) CloseReason() *CloseReason {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.closeReason
+}
+
+func (pc *PeerConnection) setCloseReason(reason *CloseReason) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.closeReason == nil {
+		pc.closeReason = reason
+	}
+}
+
+// startCloseReasons opens the DataChannel that carries close reasons once SCTP is
+// connected, if both peers enabled close reasons
+func (pc *PeerConnection) startCloseReasons(remoteDesc *SessionDescription) {
+	if !pc.api.settingEngine.closeReasons || pc.sctpTransport.State() != SCTPTransportStateConnected || pc.sctpTransport.getControlDataChannel() != nil {
+		return
+	}
+
+	m := haveDataChannel(remoteDesc)
+	if m == nil {
+		return
+	}
+	value, ok := m.Attribute(sdpAttributeCloseReasons)
+	if !ok {
+		return
+	}
+
+	// Both peers have to agree on the DataChannel, it is negotiated
+	id := pc.api.settingEngine.getControlDataChannelID()
+	if remoteID, err := strconv.ParseUint(value, 10, 16); err != nil || uint16(remoteID) != id {
+		pc.log.Warnf("Close reasons are disabled, the remote carries them on DataChannel %s instead of %d", value, id)
+		return
+	}
+
+	if err := pc.createControlDataChannel(); err != nil {
+		pc.log.Warnf("Failed to open the DataChannel for close reasons: %v", err)
+	}
+}
+
+// createControlDataChannel opens the negotiated DataChannel that carries close reasons.
+// It belongs to the PeerConnection, so it isn't part of the DataChannels of the
+// SCTPTransport and doesn't show up in the stats.
+func (pc *PeerConnection) createControlDataChannel() error {
+	id := pc.api.settingEngine.getControlDataChannelID()
+	d, err := pc.api.newDataChannel(&DataChannelParameters{Ordered: true, Negotiated: true, ID: &id}, pc.api.settingEngine.LoggerFactory.NewLogger("datachannel"))
+	if err != nil {
+		return err
+	}
+	d.isControl = true
+	d.closed = make(chan struct{})
+	d.OnMessage(pc.handleControlMessage)
+
+	pc.sctpTransport.lock.Lock()
+	pc.sctpTransport.controlDataChannel = d
+	pc.sctpTransport.lock.Unlock()
+	return d.open(pc.sctpTransport)
+}
+
+func (pc *PeerConnection) handleControlMessage(msg DataChannelMessage) {
+	if len(msg.Data) < controlMessageHeaderLength {
+		pc.log.Warnf("Dropping invalid control message of %d bytes", len(msg.Data))
+		return
+	}
+	id := binary.BigEndian.Uint16(msg.Data[1:])
+	reason := &CloseReason{
+		Code:   binary.BigEndian.Uint16(msg.Data[3:]),
+		Reason: string(msg.Data[controlMessageHeaderLength:]),
+		Remote: true,
+	}
+
+	switch msg.Data[0] {
+	case controlMessageTypeDataChannelClose:
+		pc.sctpTransport.lock.RLock()
+		dataChannels := append([]*DataChannel{}, pc.sctpTransport.dataChannels...)
+		pc.sctpTransport.lock.RUnlock()
+
+		for _, d := range dataChannels {
+			if dataChannelID := d.ID(); !d.isControlDataChannel() && dataChannelID != nil && *dataChannelID == id {
+				d.setCloseReason(reason)
+				if err := d.Close(); err != nil {
+					pc.log.Warnf("Failed to close DataChannel: %v", err)
+				}
+			}
+		}
+	case controlMessageTypePeerConnectionClose:
+		pc.setCloseReason(reason)
+
+		// This handler runs in the read loop of the control DataChannel, which Close ends
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), closeReasonTimeout)
+			defer cancel()
+
+			if err := pc.GracefulClose(ctx); err != nil {
+				pc.log.Warnf("Failed to close PeerConnection: %v", err)
+			}
+		}()
+	default:
+		pc.log.Warnf("Dropping control message of unknown type %d", msg.Data[0])
+	}
+}
+
+// marshalControlMessage creates a control message, [type|id|code|reason]
+func marshalControlMessage(messageType byte, id, code uint16, reason string) []byte {
+	msg := make([]byte, controlMessageHeaderLength+len(reason))
+	msg[0] = messageType
+	binary.BigEndian.PutUint16(msg[1:], id)
+	binary.BigEndian.PutUint16(msg[3:], code)
+	copy(msg[controlMessageHeaderLength:], reason)
+	return msg
+}
@@ -0,0 +1,258 @@
+// +build !js
+
+package webrtc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCloseReasonPair(t *testing.T) (*PeerConnection, *PeerConnection) {
+	s := SettingEngine{}
+	s.EnableCloseReasons(true)
+
+	pcOffer, pcAnswer, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+	return pcOffer, pcAnswer
+}
+
+func TestDataChannel_CloseWithReason(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer := newCloseReasonPair(t)
+
+	answerOpened, answerClosed := make(chan *DataChannel), make(chan struct{})
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		if d.Label() != "reason" {
+			return
+		}
+		d.OnOpen(func() {
+			answerOpened <- d
+		})
+		d.OnClose(func() {
+			close(answerClosed)
+		})
+	})
+
+	offerDataChannel, err := pcOffer.CreateDataChannel("reason", nil)
+	assert.NoError(t, err)
+
+	offerClosed := make(chan struct{})
+	offerDataChannel.OnClose(func() {
+		close(offerClosed)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	answerDataChannel := <-answerOpened
+
+	assert.NoError(t, offerDataChannel.CloseWithReason(42, "done"))
+	<-answerClosed
+	<-offerClosed
+
+	assert.Equal(t, &CloseReason{Code: 42, Reason: "done", Remote: true}, answerDataChannel.CloseReason())
+	assert.Equal(t, &CloseReason{Code: 42, Reason: "done"}, offerDataChannel.CloseReason())
+
+	// The timer closing the DataChannel if the remote doesn't has been stopped
+	assert.False(t, offerDataChannel.closeReasonTimer.Stop())
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_CloseWithReason(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer := newCloseReasonPair(t)
+
+	connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	connected.Wait()
+
+	// The control DataChannel has to be open before a reason can be sent
+	for control := pcOffer.sctpTransport.getControlDataChannel(); control == nil || control.ReadyState() != DataChannelStateOpen; control = pcOffer.sctpTransport.getControlDataChannel() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	answerClosed := untilConnectionState(PeerConnectionStateClosed, pcAnswer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, pcOffer.CloseWithReason(ctx, 1, "shutdown"))
+
+	answerClosed.Wait()
+	assert.Equal(t, &CloseReason{Code: 1, Reason: "shutdown", Remote: true}, pcAnswer.CloseReason())
+	assert.Equal(t, &CloseReason{Code: 1, Reason: "shutdown"}, pcOffer.CloseReason())
+}
+
+func TestCloseWithReason_NotEnabled(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	offerDataChannel, err := pcOffer.CreateDataChannel("reason", nil)
+	assert.NoError(t, err)
+
+	opened := make(chan struct{})
+	offerDataChannel.OnOpen(func() {
+		close(opened)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-opened
+
+	assert.ErrorIs(t, offerDataChannel.CloseWithReason(1, "done"), errCloseReasonsNotEnabled)
+	assert.ErrorIs(t, pcOffer.CloseWithReason(context.Background(), 1, "done"), errCloseReasonsNotEnabled)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestCloseWithReason_NoDataChannels(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.EnableCloseReasons(true)
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+
+	pc, err := NewAPI(WithSettingEngine(s), WithMediaEngine(m)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pc.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, "m=application")
+	assert.NotContains(t, offer.SDP, sdpAttributeCloseReasons)
+	for _, s := range pc.GetStats() {
+		switch stats := s.(type) {
+		case PeerConnectionStats:
+			assert.Zero(t, stats.DataChannelsRequested)
+		case DataChannelStats:
+			t.Errorf("Unexpected DataChannel %s", stats.ID)
+		}
+	}
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestCloseWithReason_RemoteNotEnabled(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.EnableCloseReasons(true)
+
+	pcOffer, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	pcAnswer, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	offerDataChannel, err := pcOffer.CreateDataChannel("reason", nil)
+	assert.NoError(t, err)
+
+	opened := make(chan struct{})
+	offerDataChannel.OnOpen(func() {
+		close(opened)
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-opened
+
+	assert.Contains(t, pcOffer.LocalDescription().SDP, sdpAttributeCloseReasons)
+	assert.NotContains(t, pcAnswer.LocalDescription().SDP, sdpAttributeCloseReasons)
+	assert.ErrorIs(t, offerDataChannel.CloseWithReason(1, "done"), errCloseReasonsNotEnabled)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestCloseWithReason_DataChannelID(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	newPeerConnection := func(id uint16) *PeerConnection {
+		s := SettingEngine{}
+		s.EnableCloseReasons(true)
+		s.SetCloseReasonsDataChannelID(id)
+
+		pc, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+		assert.NoError(t, err)
+		return pc
+	}
+
+	createDataChannel := func(pc *PeerConnection) *DataChannel {
+		d, err := pc.CreateDataChannel("reason", nil)
+		assert.NoError(t, err)
+		return d
+	}
+
+	t.Run("Same", func(t *testing.T) {
+		pcOffer, pcAnswer := newPeerConnection(0), newPeerConnection(0)
+		offerDataChannel := createDataChannel(pcOffer)
+
+		connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+		assert.NoError(t, signalPair(pcOffer, pcAnswer))
+		connected.Wait()
+		assert.Contains(t, pcOffer.LocalDescription().SDP, "a="+sdpAttributeCloseReasons+":0\r\n")
+
+		for control := pcOffer.sctpTransport.getControlDataChannel(); control == nil || control.ReadyState() != DataChannelStateOpen; control = pcOffer.sctpTransport.getControlDataChannel() {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// The ID of the control DataChannel isn't used for other DataChannels
+		answerDataChannel := createDataChannel(pcAnswer)
+		for _, d := range []*DataChannel{offerDataChannel, answerDataChannel} {
+			for d.ID() == nil {
+				time.Sleep(10 * time.Millisecond)
+			}
+			assert.NotEqual(t, uint16(0), *d.ID())
+		}
+
+		assert.NoError(t, offerDataChannel.CloseWithReason(1, "done"))
+
+		closePairNow(t, pcOffer, pcAnswer)
+	})
+
+	t.Run("Different", func(t *testing.T) {
+		pcOffer, pcAnswer := newPeerConnection(100), newPeerConnection(200)
+		offerDataChannel := createDataChannel(pcOffer)
+
+		connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+		assert.NoError(t, signalPair(pcOffer, pcAnswer))
+		connected.Wait()
+
+		for offerDataChannel.ReadyState() != DataChannelStateOpen {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.ErrorIs(t, offerDataChannel.CloseWithReason(1, "done"), errCloseReasonsNotEnabled)
+
+		closePairNow(t, pcOffer, pcAnswer)
+	})
+}
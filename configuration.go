@@ -48,4 +48,10 @@ type Configuration struct {
 	// SDPSemantics controls the type of SDP offers accepted by and
 	// SDP answers generated by the PeerConnection.
 	SDPSemantics SDPSemantics `json:"sdpSemantics,omitempty"`
+
+	// PeerConnectionID identifies the PeerConnection in logs. If set, it is
+	// prepended to every log line of the PeerConnection and its ICE, DTLS
+	// and SCTP transports, so the logs of a single session can be told apart
+	// on servers handling many connections.
+	PeerConnectionID string `json:"peerConnectionId,omitempty"`
 }
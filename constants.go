@@ -23,9 +23,30 @@ const (
 
 	mediaSectionApplication = "application"
 
+	sdpAttributeBundleOnly = "bundle-only"
+
+	// sdpAttributeCloseReasons tells that the peer enabled close reasons and the ID
+	// of the DataChannel that carries them, see SettingEngine.EnableCloseReasons
+	sdpAttributeCloseReasons = "x-pion-close-reasons"
+
+	// sdpAttributeRTCPMuxOnly tells that RTCP can't be sent on a separate port, RFC 8858
+	sdpAttributeRTCPMuxOnly = "rtcp-mux-only"
+
+	// sdpSemanticTokenFECFR groups a media SSRC with the SSRC of its FlexFEC stream, RFC 5956
+	sdpSemanticTokenFECFR = "FEC-FR"
+
+	// sdpSemanticTokenSimulcast groups the SSRCs of the simulcast layers of a track
+	sdpSemanticTokenSimulcast = "SIM"
+
 	rtpOutboundMTU = 1200
 
 	rtpPayloadTypeBitmask = 0x7F
+
+	rtpTimestampOffset = 4
+
+	// rtpMaxCSRC is the maximum amount of CSRCs that can be carried
+	// in a RTP header, the CC field is only 4 bits
+	rtpMaxCSRC = 15
 )
 
 func defaultSrtpProtectionProfiles() []dtls.SRTPProtectionProfile {
@@ -3,7 +3,7 @@
 package webrtc
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"math"
@@ -17,7 +17,13 @@ import (
 )
 
 const dataChannelBufferSize = math.MaxUint16 // message size limit for Chromium
-var errSCTPNotEstablished = errors.New("SCTP not established")
+
+// dataChannelDefaultBufferedAmountHighThreshold is the BufferedAmount above which SendContext blocks by default
+const dataChannelDefaultBufferedAmountHighThreshold = 1 << 20
+
+// dataChannelCoalesceSize is about the payload of one SCTP packet, smaller messages are
+// held when coalescing and the held messages are written once they add up to it
+const dataChannelCoalesceSize = 1200
 
 // DataChannel represents a WebRTC DataChannel
 // The DataChannel interface represents a network channel
@@ -36,6 +42,25 @@ type DataChannel struct {
 	readyState                 atomic.Value // DataChannelState
 	bufferedAmountLowThreshold uint64
 	detachCalled               bool
+	closeReason                *CloseReason
+
+	// bufferedAmountHighThreshold is the BufferedAmount above which SendContext blocks
+	bufferedAmountHighThreshold uint64
+
+	// maxBufferedAmount is the BufferedAmount messages are rejected above, 0 is unlimited
+	maxBufferedAmount uint64
+
+	// isControl is set for the DataChannel that carries close reasons, it is never detached
+	isControl bool
+
+	// closed is closed once the DataChannel that carries close reasons has been closed,
+	// it is nil for other DataChannels
+	closed     chan struct{}
+	closedOnce sync.Once
+
+	// closeReasonTimer closes the DataChannel if the remote doesn't close it after
+	// CloseWithReason
+	closeReasonTimer *time.Timer
 
 	// The binaryType represents attribute MUST, on getting, return the value to
 	// which it was last set. On setting, if the new value is either the string
@@ -52,9 +77,23 @@ type DataChannel struct {
 	onBufferedAmountLow func()
 	onErrorHandler      func(error)
 
+	// bufferedAmountLowCh is closed and replaced when the BufferedAmount dropped to the
+	// low threshold or the DataChannel is closing, to wake up blocked SendContext calls
+	bufferedAmountLowMu sync.Mutex
+	bufferedAmountLowCh chan struct{}
+
+	// Small messages held to be written together, see SettingEngine.SetSCTPSendCoalescing
+	coalesceMu      sync.Mutex
+	coalesced       []coalescedMessage
+	coalescedAmount uint64
+	coalesceTimer   *time.Timer
+
 	sctpTransport *SCTPTransport
 	dataChannel   *datachannel.DataChannel
 
+	// readLoops is the read loop, once the DataChannel is open
+	readLoops sync.WaitGroup
+
 	// A reference to the associated api object used by this datachannel
 	api *API
 	log logging.LeveledLogger
@@ -64,7 +103,7 @@ type DataChannel struct {
 // This constructor is part of the ORTC API. It is not
 // meant to be used together with the basic WebRTC API.
 func (api *API) NewDataChannel(transport *SCTPTransport, params *DataChannelParameters) (*DataChannel, error) {
-	d, err := api.newDataChannel(params, api.settingEngine.LoggerFactory.NewLogger("ortc"))
+	d, err := api.newDataChannel(params, api.settingEngine.LoggerFactory.NewLogger("datachannel"))
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +135,9 @@ func (api *API) newDataChannel(params *DataChannelParameters, log logging.Levele
 		maxRetransmits:    params.MaxRetransmits,
 		api:               api,
 		log:               log,
+
+		bufferedAmountHighThreshold: dataChannelDefaultBufferedAmountHighThreshold,
+		bufferedAmountLowCh:         make(chan struct{}),
 	}
 
 	d.setReadyState(DataChannelStateConnecting)
@@ -106,7 +148,7 @@ func (api *API) newDataChannel(params *DataChannelParameters, log logging.Levele
 func (d *DataChannel) open(sctpTransport *SCTPTransport) error {
 	association := sctpTransport.association()
 	if association == nil {
-		return errSCTPNotEstablished
+		return ErrSCTPNotEstablished
 	}
 
 	d.mu.Lock()
@@ -164,9 +206,8 @@ func (d *DataChannel) open(sctpTransport *SCTPTransport) error {
 		return err
 	}
 
-	// bufferedAmountLowThreshold and onBufferedAmountLow might be set earlier
+	// bufferedAmountLowThreshold might be set earlier
 	dc.SetBufferedAmountLowThreshold(d.bufferedAmountLowThreshold)
-	dc.OnBufferedAmountLow(d.onBufferedAmountLow)
 	d.mu.Unlock()
 
 	d.handleOpen(dc)
@@ -187,7 +228,7 @@ func (d *DataChannel) checkDetachAfterOpen() {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	if d.api.settingEngine.detach.DataChannels && !d.detachCalled {
+	if d.api.settingEngine.detach.DataChannels && !d.detachCalled && !d.isControl {
 		d.log.Warn("webrtc.DetachDataChannels() enabled but didn't Detach, call Detach from OnOpen")
 	}
 }
@@ -231,9 +272,16 @@ func (d *DataChannel) OnClose(f func()) {
 }
 
 func (d *DataChannel) onClose() {
-	d.mu.RLock()
+	d.mu.Lock()
 	handler := d.onCloseHandler
-	d.mu.RUnlock()
+	if d.closeReasonTimer != nil {
+		d.closeReasonTimer.Stop()
+	}
+	d.mu.Unlock()
+
+	if d.closed != nil {
+		d.closedOnce.Do(func() { close(d.closed) })
+	}
 
 	if handler != nil {
 		go handler()
@@ -267,6 +315,7 @@ func (d *DataChannel) handleOpen(dc *datachannel.DataChannel) {
 	d.mu.Lock()
 	d.dataChannel = dc
 	d.mu.Unlock()
+	dc.OnBufferedAmountLow(d.handleBufferedAmountLow)
 	d.setReadyState(DataChannelStateOpen)
 
 	d.onOpen()
@@ -274,11 +323,18 @@ func (d *DataChannel) handleOpen(dc *datachannel.DataChannel) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if !d.api.settingEngine.detach.DataChannels {
+	if !d.api.settingEngine.detach.DataChannels || d.isControl {
+		d.readLoops.Add(1)
 		go d.readLoop()
 	}
 }
 
+func (d *DataChannel) isControlDataChannel() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.isControl
+}
+
 // OnError sets an event handler which is invoked when
 // the underlying data transport cannot be read.
 func (d *DataChannel) OnError(f func(err error)) {
@@ -304,6 +360,8 @@ var rlBufPool = sync.Pool{New: func() interface{} {
 }}
 
 func (d *DataChannel) readLoop() {
+	defer d.readLoops.Done()
+
 	for {
 		buffer := rlBufPool.Get().([]byte)
 		n, isString, err := d.dataChannel.ReadDataChannel(buffer)
@@ -327,33 +385,178 @@ func (d *DataChannel) readLoop() {
 	}
 }
 
-// Send sends the binary message to the DataChannel peer
+// Send sends the binary message to the DataChannel peer. If a MaxBufferedAmount
+// is set and the message would exceed it, ErrBufferFull is returned.
 func (d *DataChannel) Send(data []byte) error {
 	err := d.ensureOpen()
 	if err != nil {
 		return err
 	}
 
-	_, err = d.dataChannel.WriteDataChannel(data, false)
-	return err
+	return d.write(data, false)
 }
 
-// SendText sends the text message to the DataChannel peer
+// SendText sends the text message to the DataChannel peer. If a MaxBufferedAmount
+// is set and the message would exceed it, ErrBufferFull is returned.
 func (d *DataChannel) SendText(s string) error {
 	err := d.ensureOpen()
 	if err != nil {
 		return err
 	}
 
-	_, err = d.dataChannel.WriteDataChannel([]byte(s), true)
+	return d.write([]byte(s), true)
+}
+
+func (d *DataChannel) write(data []byte, isString bool) error {
+	d.mu.RLock()
+	maxBufferedAmount := d.maxBufferedAmount
+	d.mu.RUnlock()
+
+	if maxBufferedAmount != 0 && d.BufferedAmount()+uint64(len(data)) > maxBufferedAmount {
+		return ErrBufferFull
+	}
+
+	if delay := d.api.settingEngine.sctp.SendCoalescingDelay; delay > 0 {
+		return d.writeCoalesced(data, isString, delay)
+	}
+
+	_, err := d.dataChannel.WriteDataChannel(data, isString)
 	return err
 }
 
+type coalescedMessage struct {
+	data     []byte
+	isString bool
+}
+
+// writeCoalesced holds small messages to be written together after the delay. Larger
+// messages are written after the held ones, so the order of messages is kept.
+func (d *DataChannel) writeCoalesced(data []byte, isString bool, delay time.Duration) error {
+	d.coalesceMu.Lock()
+	defer d.coalesceMu.Unlock()
+
+	if len(data) >= dataChannelCoalesceSize {
+		if err := d.flushCoalescedLocked(); err != nil {
+			return err
+		}
+		_, err := d.dataChannel.WriteDataChannel(data, isString)
+		return err
+	}
+
+	d.coalesced = append(d.coalesced, coalescedMessage{data: append([]byte{}, data...), isString: isString})
+	d.coalescedAmount += uint64(len(data))
+	if d.coalescedAmount >= dataChannelCoalesceSize {
+		return d.flushCoalescedLocked()
+	}
+
+	if d.coalesceTimer == nil {
+		d.coalesceTimer = time.AfterFunc(delay, d.flushCoalesced)
+	}
+	return nil
+}
+
+func (d *DataChannel) flushCoalesced() {
+	d.coalesceMu.Lock()
+	err := d.flushCoalescedLocked()
+	d.coalesceMu.Unlock()
+
+	if err != nil {
+		d.onError(err)
+	}
+}
+
+// flushCoalescedLocked writes the held messages in one burst, which SCTP bundles into
+// as few packets as possible. Note: the caller should hold coalesceMu.
+func (d *DataChannel) flushCoalescedLocked() error {
+	if d.coalesceTimer != nil {
+		d.coalesceTimer.Stop()
+		d.coalesceTimer = nil
+	}
+
+	messages := d.coalesced
+	d.coalesced, d.coalescedAmount = nil, 0
+	for _, m := range messages {
+		if _, err := d.dataChannel.WriteDataChannel(m.data, m.isString); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendContext sends the binary message like Send, but first blocks while the
+// BufferedAmount is above the BufferedAmountHighThreshold, until it dropped to the
+// BufferedAmountLowThreshold. This keeps a slow receiver from making messages
+// queue up without bound. If the context is done first its error is returned and
+// the message isn't sent. SendContext can be used with detached DataChannels.
+func (d *DataChannel) SendContext(ctx context.Context, data []byte) error {
+	return d.sendContext(ctx, data, false)
+}
+
+// SendTextContext sends the text message like SendText, blocking like SendContext
+func (d *DataChannel) SendTextContext(ctx context.Context, s string) error {
+	return d.sendContext(ctx, []byte(s), true)
+}
+
+func (d *DataChannel) sendContext(ctx context.Context, data []byte, isString bool) error {
+	for {
+		if err := d.ensureOpen(); err != nil {
+			return err
+		}
+
+		// The channel is taken before the BufferedAmount is read, so a drop in between isn't missed
+		d.bufferedAmountLowMu.Lock()
+		bufferedAmountLow := d.bufferedAmountLowCh
+		d.bufferedAmountLowMu.Unlock()
+
+		d.mu.RLock()
+		bufferedAmount := d.dataChannel.BufferedAmount()
+		lowThreshold := d.dataChannel.BufferedAmountLowThreshold()
+		highThreshold := d.bufferedAmountHighThreshold
+		d.mu.RUnlock()
+
+		// Nothing would wake up the call if the low threshold is the higher one
+		if bufferedAmount <= highThreshold || bufferedAmount <= lowThreshold {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-bufferedAmountLow:
+		}
+	}
+
+	return d.write(data, isString)
+}
+
+// wakeBufferedAmountLow wakes up the SendContext calls waiting for the BufferedAmount to drop
+func (d *DataChannel) wakeBufferedAmountLow() {
+	d.bufferedAmountLowMu.Lock()
+	defer d.bufferedAmountLowMu.Unlock()
+
+	if d.bufferedAmountLowCh != nil {
+		close(d.bufferedAmountLowCh)
+	}
+	d.bufferedAmountLowCh = make(chan struct{})
+}
+
+func (d *DataChannel) handleBufferedAmountLow() {
+	d.wakeBufferedAmountLow()
+
+	d.mu.RLock()
+	handler := d.onBufferedAmountLow
+	d.mu.RUnlock()
+
+	if handler != nil {
+		handler()
+	}
+}
+
 func (d *DataChannel) ensureOpen() error {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	if d.ReadyState() != DataChannelStateOpen {
-		return io.ErrClosedPipe
+		return wrapError(ErrDataChannelNotOpen, io.ErrClosedPipe)
 	}
 	return nil
 }
@@ -366,6 +569,19 @@ func (d *DataChannel) ensureOpen() error {
 // Please refer to the data-channels-detach example and the
 // pion/datachannel documentation for the correct way to handle the
 // resulting DataChannel object.
+//
+// Writes to the detached DataChannel are handed directly to the SCTP stream,
+// this package doesn't buffer or copy them. pion/sctp copies the payload once
+// into its chunks, because they must be retained until acknowledged, and
+// pion/dtls encrypts them into a record.
+//
+// Each Read or ReadDataChannel returns exactly one message, ReadDataChannel also
+// reports whether it was sent as a string. A message that doesn't fit into the
+// buffer is discarded and io.ErrShortBuffer is returned, so the buffer should be
+// as large as the largest message expected. If the DataChannel is unordered
+// messages are returned in the order they arrived, which can differ from the order
+// they were sent in. SCTP doesn't number unordered messages, applications that need
+// to detect reordering have to include their own sequence number in the messages.
 func (d *DataChannel) Detach() (datachannel.ReadWriteCloser, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -399,6 +615,9 @@ func (d *DataChannel) Close() error {
 		return nil
 	}
 
+	// Held messages were accepted by Send, they are written before closing
+	d.flushCoalesced()
+
 	return d.dataChannel.Close()
 }
 
@@ -440,7 +659,9 @@ func (d *DataChannel) MaxRetransmits() *uint16 {
 }
 
 // Protocol represents the name of the sub-protocol used with this
-// DataChannel.
+// DataChannel. The protocol of a DataChannel opened by the remote is set
+// before OnDataChannel, so it can be used to route the DataChannel before
+// it is open.
 func (d *DataChannel) Protocol() string {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
@@ -495,7 +716,10 @@ func (d *DataChannel) BufferedAmount() uint64 {
 	if d.dataChannel == nil {
 		return 0
 	}
-	return d.dataChannel.BufferedAmount()
+
+	d.coalesceMu.Lock()
+	defer d.coalesceMu.Unlock()
+	return d.dataChannel.BufferedAmount() + d.coalescedAmount
 }
 
 // BufferedAmountLowThreshold represents the threshold at which the
@@ -535,9 +759,39 @@ func (d *DataChannel) OnBufferedAmountLow(f func()) {
 	defer d.mu.Unlock()
 
 	d.onBufferedAmountLow = f
-	if d.dataChannel != nil {
-		d.dataChannel.OnBufferedAmountLow(f)
-	}
+}
+
+// BufferedAmountHighThreshold is the BufferedAmount above which SendContext
+// blocks, it is 1 MiB by default. Send and SendText never block.
+func (d *DataChannel) BufferedAmountHighThreshold() uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.bufferedAmountHighThreshold
+}
+
+// SetBufferedAmountHighThreshold is used to update the threshold.
+// See BufferedAmountHighThreshold().
+func (d *DataChannel) SetBufferedAmountHighThreshold(th uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bufferedAmountHighThreshold = th
+}
+
+// MaxBufferedAmount is the limit of the BufferedAmount, messages that would
+// exceed it are rejected with ErrBufferFull. It is 0 by default, which means
+// there is no limit.
+func (d *DataChannel) MaxBufferedAmount() uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.maxBufferedAmount
+}
+
+// SetMaxBufferedAmount is used to update the limit.
+// See MaxBufferedAmount().
+func (d *DataChannel) SetMaxBufferedAmount(maxBufferedAmount uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxBufferedAmount = maxBufferedAmount
 }
 
 func (d *DataChannel) getStatsID() string {
@@ -553,7 +807,7 @@ func (d *DataChannel) collectStats(collector *statsReportCollector) {
 	defer d.mu.Unlock()
 
 	stats := DataChannelStats{
-		Timestamp: statsTimestampNow(),
+		Timestamp: collector.timestamp,
 		Type:      StatsTypeDataChannel,
 		ID:        d.statsID,
 		Label:     d.label,
@@ -578,4 +832,9 @@ func (d *DataChannel) collectStats(collector *statsReportCollector) {
 
 func (d *DataChannel) setReadyState(r DataChannelState) {
 	d.readyState.Store(r)
+
+	// Blocked SendContext calls return once the DataChannel is closing
+	if r == DataChannelStateClosing || r == DataChannelStateClosed {
+		d.wakeBufferedAmountLow()
+	}
 }
@@ -4,8 +4,10 @@ package webrtc
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
@@ -577,3 +579,551 @@ func TestDataChannel_NonStandardSessionDescription(t *testing.T) {
 	<-onDataChannelCalled
 	closePairNow(t, offerPC, answerPC)
 }
+
+func BenchmarkDataChannelSendDetached(b *testing.B) {
+	s := SettingEngine{}
+	s.DetachDataChannels()
+	api := NewAPI(WithSettingEngine(s))
+
+	offerPC, answerPC, err := api.newPair(Configuration{})
+	if err != nil {
+		b.Fatalf("Failed to create a PC pair for testing")
+	}
+
+	const messageSize = 16 * 1024
+	done := make(chan struct{})
+	writeFailed := make(chan struct{})
+	var reader sync.WaitGroup
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		if d.Label() != "data" {
+			// Ignore the channel created by newPair
+			return
+		}
+
+		d.OnOpen(func() {
+			detached, detachErr := d.Detach()
+			if detachErr != nil {
+				b.Error(detachErr)
+				return
+			}
+
+			reader.Add(1)
+			go func() {
+				defer reader.Done()
+				buf := make([]byte, messageSize)
+				for n := 0; n < b.N; n++ {
+					if _, readErr := detached.Read(buf); readErr != nil {
+						// Reads fail once the PeerConnections are closed after a failed write
+						select {
+						case <-writeFailed:
+						default:
+							b.Error(readErr)
+						}
+						return
+					}
+				}
+				close(done)
+			}()
+		})
+	})
+
+	dc, err := offerPC.CreateDataChannel("data", nil)
+	assert.NoError(b, err)
+
+	dc.OnOpen(func() {
+		detached, detachErr := dc.Detach()
+		if detachErr != nil {
+			b.Error(detachErr)
+			close(writeFailed)
+			return
+		}
+
+		buf := make([]byte, messageSize)
+		b.SetBytes(messageSize)
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if _, writeErr := detached.Write(buf); writeErr != nil {
+				b.Errorf("Unexpected error sending data: %v", writeErr)
+				close(writeFailed)
+				return
+			}
+		}
+	})
+
+	assert.NoError(b, signalPair(offerPC, answerPC))
+	select {
+	case <-done:
+	case <-writeFailed:
+	}
+	closePairNow(b, offerPC, answerPC)
+	reader.Wait()
+}
+
+// Assert that a DataChannel announced by the remote before OnDataChannel
+// has been set is delivered once a handler is set
+func TestDataChannel_OnDataChannelSetLate(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	for {
+		answerPC.sctpTransport.lock.RLock()
+		accepted := answerPC.sctpTransport.dataChannelsAccepted
+		answerPC.sctpTransport.lock.RUnlock()
+		if accepted != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	onDataChannelCalled := make(chan string)
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		onDataChannelCalled <- d.Label()
+	})
+
+	assert.Equal(t, "initial_data_channel", <-onDataChannelCalled)
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_DetachUnorderedMessages(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.DetachDataChannels()
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	detachedCh := make(chan datachannel.ReadWriteCloser, 1)
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		if d.Label() != "unordered" {
+			return
+		}
+		assert.False(t, d.Ordered())
+		d.OnOpen(func() {
+			detached, detachErr := d.Detach()
+			assert.NoError(t, detachErr)
+			detachedCh <- detached
+		})
+	})
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	ordered := false
+	dc, err := offerPC.CreateDataChannel("unordered", &DataChannelInit{Ordered: &ordered})
+	assert.NoError(t, err)
+
+	openCh := make(chan struct{})
+	dc.OnOpen(func() {
+		close(openCh)
+	})
+	<-openCh
+	sender, err := dc.Detach()
+	assert.NoError(t, err)
+	receiver := <-detachedCh
+
+	// Each read returns one message, in any order
+	sent := map[string]bool{"a": false, "bb": true, "ccc": false}
+	for data, isString := range sent {
+		_, err = sender.WriteDataChannel([]byte(data), isString)
+		assert.NoError(t, err)
+	}
+
+	buf := make([]byte, 1024)
+	for i := len(sent); i > 0; i-- {
+		n, isString, readErr := receiver.ReadDataChannel(buf)
+		assert.NoError(t, readErr)
+
+		expected, ok := sent[string(buf[:n])]
+		assert.True(t, ok)
+		assert.Equal(t, expected, isString)
+		delete(sent, string(buf[:n]))
+	}
+
+	// A message larger than the buffer is discarded
+	_, err = sender.Write(make([]byte, 100))
+	assert.NoError(t, err)
+	_, err = receiver.Read(buf[:10])
+	assert.Equal(t, io.ErrShortBuffer, err)
+
+	_, err = sender.Write([]byte("d"))
+	assert.NoError(t, err)
+	n, err := receiver.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("d"), buf[:n])
+
+	assert.NoError(t, sender.Close())
+	assert.NoError(t, receiver.Close())
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_SendContext(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.DetachDataChannels()
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	// The receiver never reads, once its receive window is full the messages stay buffered
+	detachedCh := make(chan datachannel.ReadWriteCloser, 1)
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		d.OnOpen(func() {
+			detached, detachErr := d.Detach()
+			assert.NoError(t, detachErr)
+			detachedCh <- detached
+		})
+	})
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	dc, err := offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(dataChannelDefaultBufferedAmountHighThreshold), dc.BufferedAmountHighThreshold())
+
+	const highThreshold, messageSize = 64 * 1024, 16 * 1024
+	dc.SetBufferedAmountHighThreshold(highThreshold)
+
+	openCh := make(chan struct{})
+	dc.OnOpen(func() {
+		close(openCh)
+	})
+	<-openCh
+	_, err = dc.Detach()
+	assert.NoError(t, err)
+	receiver := <-detachedCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for {
+		if err = dc.SendContext(ctx, make([]byte, messageSize)); err != nil {
+			break
+		}
+		assert.LessOrEqual(t, dc.BufferedAmount(), uint64(highThreshold+messageSize))
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Closing the DataChannel returns blocked calls
+	sendErr := make(chan error)
+	go func() {
+		sendErr <- dc.SendTextContext(context.Background(), "blocked")
+	}()
+	assert.NoError(t, dc.Close())
+	assert.ErrorIs(t, <-sendErr, io.ErrClosedPipe)
+
+	assert.NoError(t, receiver.Close())
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_MaxBufferedAmount(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	// With a small receive window the messages stay buffered at the sender
+	s := SettingEngine{}
+	s.DetachDataChannels()
+	s.SetSCTPMaxReceiveBufferSize(32 * 1024)
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	detachedCh := make(chan datachannel.ReadWriteCloser, 1)
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		d.OnOpen(func() {
+			detached, detachErr := d.Detach()
+			assert.NoError(t, detachErr)
+			detachedCh <- detached
+		})
+	})
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	dc, err := offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), dc.MaxBufferedAmount())
+
+	const maxBufferedAmount, messageSize = 128 * 1024, 16 * 1024
+	dc.SetMaxBufferedAmount(maxBufferedAmount)
+
+	openCh := make(chan struct{})
+	dc.OnOpen(func() {
+		close(openCh)
+	})
+	<-openCh
+	_, err = dc.Detach()
+	assert.NoError(t, err)
+	receiver := <-detachedCh
+
+	// The receiver never reads, Send fails once the buffer is full
+	for err == nil {
+		err = dc.Send(make([]byte, messageSize))
+		assert.LessOrEqual(t, dc.BufferedAmount(), uint64(maxBufferedAmount))
+	}
+	assert.ErrorIs(t, err, ErrBufferFull)
+	assert.Greater(t, dc.BufferedAmount()+messageSize, uint64(maxBufferedAmount))
+
+	// Messages that fit are still sent
+	assert.NoError(t, dc.SendText(""))
+
+	assert.NoError(t, receiver.Close())
+	assert.NoError(t, dc.Close())
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_SendCoalescing(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.SetSCTPSendCoalescing(50 * time.Millisecond)
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	// Small messages are held, a large one is written after them
+	const messageCount = 100
+	expected := [][]byte{}
+	for i := 0; i < messageCount; i++ {
+		expected = append(expected, []byte(fmt.Sprintf("message %d", i)))
+		if i == messageCount/2 {
+			expected = append(expected, make([]byte, dataChannelCoalesceSize))
+		}
+	}
+
+	done := make(chan struct{})
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		received := 0
+		d.OnMessage(func(msg DataChannelMessage) {
+			assert.Equal(t, expected[received], msg.Data)
+			if received++; received == len(expected) {
+				close(done)
+			}
+		})
+	})
+
+	dc, err := offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+
+	dc.OnOpen(func() {
+		assert.NoError(t, dc.Send(expected[0]))
+		assert.GreaterOrEqual(t, dc.BufferedAmount(), uint64(len(expected[0])))
+
+		for _, message := range expected[1:] {
+			assert.NoError(t, dc.Send(message))
+		}
+	})
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	<-done
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_RejectRequest(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	answerPC.SCTP().OnDataChannelRequest(func(params DataChannelParameters) bool {
+		return params.Protocol != "blocked"
+	})
+
+	accepted := make(chan string, 3)
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		accepted <- d.Label()
+	})
+
+	protocol := "blocked"
+	blocked, err := offerPC.CreateDataChannel("rejected", &DataChannelInit{Protocol: &protocol})
+	assert.NoError(t, err)
+
+	blockedClosed := make(chan struct{})
+	blocked.OnClose(func() {
+		close(blockedClosed)
+	})
+
+	_, err = offerPC.CreateDataChannel("allowed", nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	// The rejected DataChannel is reset, only the allowed ones reach OnDataChannel
+	<-blockedClosed
+	assert.ElementsMatch(t, []string{"initial_data_channel", "allowed"}, []string{<-accepted, <-accepted})
+	assert.Len(t, accepted, 0)
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_Protocols(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.SetDataChannelProtocols("", "chat")
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	// The protocol is known before the DataChannel is open, so it can be routed
+	accepted := make(chan string, 3)
+	answerPC.OnDataChannel(func(d *DataChannel) {
+		assert.Equal(t, DataChannelStateConnecting, d.ReadyState())
+		accepted <- d.Label() + "/" + d.Protocol()
+	})
+
+	protocol := "file"
+	blocked, err := offerPC.CreateDataChannel("rejected", &DataChannelInit{Protocol: &protocol})
+	assert.NoError(t, err)
+
+	blockedClosed := make(chan struct{})
+	blocked.OnClose(func() {
+		close(blockedClosed)
+	})
+
+	protocol = "chat"
+	_, err = offerPC.CreateDataChannel("allowed", &DataChannelInit{Protocol: &protocol})
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	<-blockedClosed
+	assert.ElementsMatch(t, []string{"initial_data_channel/", "allowed/chat"}, []string{<-accepted, <-accepted})
+	assert.Len(t, accepted, 0)
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestDataChannel_MaxDataChannels(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.SetSCTPMaxDataChannels(3)
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(3), offerPC.SCTP().MaxChannels())
+
+	first, err := offerPC.CreateDataChannel("first", nil)
+	assert.NoError(t, err)
+	_, err = offerPC.CreateDataChannel("second", nil)
+	assert.NoError(t, err)
+
+	opened := make(chan struct{})
+	first.OnOpen(func() {
+		close(opened)
+	})
+	closed := make(chan struct{})
+	first.OnClose(func() {
+		close(closed)
+	})
+
+	// signalPair creates the third DataChannel
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	<-opened
+
+	_, err = offerPC.CreateDataChannel("fourth", nil)
+	assert.ErrorIs(t, err, ErrMaxDataChannels)
+
+	// Closing a DataChannel makes room for another one
+	assert.NoError(t, first.Close())
+	<-closed
+
+	_, err = offerPC.CreateDataChannel("fourth", nil)
+	assert.NoError(t, err)
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+// Assert that DataChannels held until OnDataChannel is set are delivered
+// in order, before the ones announced afterwards
+func TestDataChannel_OnDataChannelSetLateOrder(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	labels := []string{"a", "b", "c", "d"}
+	for _, label := range labels[:3] {
+		pc.onDataChannel(&DataChannel{label: label, api: pc.api})
+	}
+
+	delivered := make(chan string, len(labels))
+	block := make(chan struct{})
+	pc.OnDataChannel(func(d *DataChannel) {
+		if d.Label() == "a" {
+			<-block
+		}
+		delivered <- d.Label()
+	})
+
+	// Announced while the held ones are delivered
+	pc.onDataChannel(&DataChannel{label: "d", api: pc.api})
+	close(block)
+
+	for _, label := range labels {
+		assert.Equal(t, label, <-delivered)
+	}
+	assert.NoError(t, pc.Close())
+}
+
+// Assert that the DataChannels held until OnDataChannel is set are only
+// bounded if the SettingEngine asks for it
+func TestDataChannel_OnDataChannelPendingLimit(t *testing.T) {
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		pc.onDataChannel(&DataChannel{api: pc.api})
+	}
+	assert.Len(t, pc.pendingDataChannels, 100)
+	assert.NoError(t, pc.Close())
+
+	s := SettingEngine{}
+	s.SetSCTPMaxPendingDataChannels(2)
+	pc, err = NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		pc.onDataChannel(&DataChannel{api: pc.api})
+	}
+
+	d := &DataChannel{api: pc.api}
+	pc.onDataChannel(d)
+	assert.Len(t, pc.pendingDataChannels, 2)
+	assert.Equal(t, DataChannelStateClosing, d.ReadyState())
+
+	assert.NoError(t, pc.Close())
+}
@@ -3,7 +3,6 @@
 package webrtc
 
 import (
-	"fmt"
 	"syscall/js"
 
 	"github.com/pion/datachannel"
@@ -116,7 +115,7 @@ func (d *DataChannel) SendText(s string) (err error) {
 // resulting DataChannel object.
 func (d *DataChannel) Detach() (datachannel.ReadWriteCloser, error) {
 	if !d.api.settingEngine.detach.DataChannels {
-		return nil, fmt.Errorf("enable detaching by calling webrtc.DetachDataChannels()")
+		return nil, errDetachNotEnabled
 	}
 
 	detached := newDetachedDataChannel(d)
@@ -3,7 +3,7 @@
 package webrtc
 
 import (
-	"errors"
+	"io"
 )
 
 type detachedDataChannel struct {
@@ -39,11 +39,11 @@ func (c *detachedDataChannel) Read(p []byte) (int, error) {
 func (c *detachedDataChannel) ReadDataChannel(p []byte) (int, bool, error) {
 	select {
 	case <-c.done:
-		return 0, false, errors.New("Reader closed")
+		return 0, false, io.ErrClosedPipe
 	case msg := <-c.read:
 		n := copy(p, msg.Data)
 		if n < len(msg.Data) {
-			return n, msg.IsString, errors.New("Read buffer to small")
+			return n, msg.IsString, io.ErrShortBuffer
 		}
 		return n, msg.IsString, nil
 	}
@@ -80,16 +80,16 @@ func TestDataChannel_ORTCE2E(t *testing.T) {
 
 	// attempt to send when channel is closed
 	err = channelA.Send([]byte("ABC"))
-	assert.Error(t, err)
-	assert.Equal(t, io.ErrClosedPipe, err)
+	assert.ErrorIs(t, err, ErrDataChannelNotOpen)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
 
 	err = channelA.SendText("test")
-	assert.Error(t, err)
-	assert.Equal(t, io.ErrClosedPipe, err)
+	assert.ErrorIs(t, err, ErrDataChannelNotOpen)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
 
 	err = channelA.ensureOpen()
-	assert.Error(t, err)
-	assert.Equal(t, io.ErrClosedPipe, err)
+	assert.ErrorIs(t, err, ErrDataChannelNotOpen)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
 }
 
 type testORTCStack struct {
@@ -3,6 +3,7 @@
 package webrtc
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -48,6 +49,10 @@ type DTLSTransport struct {
 	simulcastStreams            []*srtp.ReadStreamSRTP
 	srtpReady                   chan struct{}
 
+	// remoteTracks are the TrackRemotes by their SSRC, for the stats
+	remoteTracksLock sync.RWMutex
+	remoteTracks     map[SSRC]*TrackRemote
+
 	dtlsMatcher mux.MatchFunc
 
 	api *API
@@ -64,6 +69,7 @@ func (api *API) NewDTLSTransport(transport *ICETransport, certificates []Certifi
 		state:        DTLSTransportStateNew,
 		dtlsMatcher:  mux.MatchDTLS,
 		srtpReady:    make(chan struct{}),
+		remoteTracks: map[SSRC]*TrackRemote{},
 		log:          api.settingEngine.LoggerFactory.NewLogger("DTLSTransport"),
 	}
 
@@ -78,7 +84,7 @@ func (api *API) NewDTLSTransport(transport *ICETransport, certificates []Certifi
 	} else {
 		sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 		if err != nil {
-			return nil, &rtcerr.UnknownError{Err: err}
+			return nil, &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 		}
 		certificate, err := GenerateCertificate(sk)
 		if err != nil {
@@ -137,7 +143,7 @@ func (t *DTLSTransport) WriteRTCP(pkts []rtcp.Packet) (int, error) {
 
 	writeStream, err := srtcpSession.OpenWriteStream()
 	if err != nil {
-		return 0, fmt.Errorf("%w: %v", errPeerConnWriteRTCPOpenWriteStream, err)
+		return 0, wrapError(errPeerConnWriteRTCPOpenWriteStream, err)
 	}
 
 	if n, err := writeStream.Write(raw); err != nil {
@@ -173,6 +179,23 @@ func (t *DTLSTransport) GetRemoteCertificate() []byte {
 	return t.remoteCertificate
 }
 
+// ExportKeyingMaterial derives keying material from the DTLS connection as defined
+// in RFC 5705. Custom media paths can use it to set up an external SRTP stack with
+// the label "EXTRACTOR-dtls_srtp", see RFC 5764 Section 4.2. It fails until the DTLS
+// handshake has completed, a context isn't supported.
+func (t *DTLSTransport) ExportKeyingMaterial(label string, context []byte, length int) ([]byte, error) {
+	t.lock.RLock()
+	conn := t.conn
+	t.lock.RUnlock()
+
+	if conn == nil {
+		return nil, errDtlsTransportNotStarted
+	}
+
+	state := conn.ConnectionState()
+	return state.ExportKeyingMaterial(label, context, length)
+}
+
 func (t *DTLSTransport) startSRTP() error {
 	srtpConfig := &srtp.Config{
 		Profile:       t.srtpProtectionProfile,
@@ -210,17 +233,17 @@ func (t *DTLSTransport) startSRTP() error {
 	connState := t.conn.ConnectionState()
 	err := srtpConfig.ExtractSessionKeysFromDTLS(&connState, t.role() == DTLSRoleClient)
 	if err != nil {
-		return fmt.Errorf("%w: %v", errDtlsKeyExtractionFailed, err)
+		return wrapError(errDtlsKeyExtractionFailed, err)
 	}
 
 	srtpSession, err := srtp.NewSessionSRTP(t.srtpEndpoint, srtpConfig)
 	if err != nil {
-		return fmt.Errorf("%w: %v", errFailedToStartSRTP, err)
+		return wrapError(errFailedToStartSRTP, err)
 	}
 
 	srtcpSession, err := srtp.NewSessionSRTCP(t.srtcpEndpoint, srtpConfig)
 	if err != nil {
-		return fmt.Errorf("%w: %v", errFailedToStartSRTCP, err)
+		return wrapError(errFailedToStartSRTCP, err)
 	}
 
 	t.srtpSession.Store(srtpSession)
@@ -308,6 +331,10 @@ func (t *DTLSTransport) Start(remoteParameters DTLSParameters) error {
 
 				return defaultSrtpProtectionProfiles()
 			}(),
+			CipherSuites:       t.api.settingEngine.dtls.CipherSuites,
+			MTU:                t.api.settingEngine.dtls.MTU,
+			InsecureHashes:     t.api.settingEngine.dtls.InsecureHashes,
+			KeyLogWriter:       t.api.settingEngine.dtls.KeyLogWriter,
 			ClientAuth:         dtls.RequireAnyClientCert,
 			LoggerFactory:      t.api.settingEngine.LoggerFactory,
 			InsecureSkipVerify: true,
@@ -325,6 +352,17 @@ func (t *DTLSTransport) Start(remoteParameters DTLSParameters) error {
 		dtlsConfig.ReplayProtectionWindow = int(*t.api.settingEngine.replayProtection.DTLS)
 	}
 
+	if t.api.settingEngine.timeout.DTLSRetransmissionInterval != nil {
+		dtlsConfig.FlightInterval = *t.api.settingEngine.timeout.DTLSRetransmissionInterval
+	}
+
+	if t.api.settingEngine.timeout.DTLSHandshakeTimeout != nil {
+		handshakeTimeout := *t.api.settingEngine.timeout.DTLSHandshakeTimeout
+		dtlsConfig.ConnectContextMaker = func() (context.Context, func()) {
+			return context.WithTimeout(context.Background(), handshakeTimeout)
+		}
+	}
+
 	// Connect as DTLS Client/Server, function is blocking and we
 	// must not hold the DTLSTransport lock
 	if role == DTLSRoleClient {
@@ -453,6 +491,32 @@ func (t *DTLSTransport) ensureICEConn() error {
 	return nil
 }
 
+// addRemoteTrack makes the TrackRemote receiving ssrc count the feedback sent about it
+func (t *DTLSTransport) addRemoteTrack(ssrc SSRC, track *TrackRemote) {
+	t.remoteTracksLock.Lock()
+	defer t.remoteTracksLock.Unlock()
+
+	t.remoteTracks[ssrc] = track
+}
+
+func (t *DTLSTransport) removeRemoteTrack(track *TrackRemote) {
+	t.remoteTracksLock.Lock()
+	defer t.remoteTracksLock.Unlock()
+
+	for ssrc, remoteTrack := range t.remoteTracks {
+		if remoteTrack == track {
+			delete(t.remoteTracks, ssrc)
+		}
+	}
+}
+
+func (t *DTLSTransport) remoteTrack(ssrc SSRC) *TrackRemote {
+	t.remoteTracksLock.RLock()
+	defer t.remoteTracksLock.RUnlock()
+
+	return t.remoteTracks[ssrc]
+}
+
 func (t *DTLSTransport) storeSimulcastStream(s *srtp.ReadStreamSRTP) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -130,3 +130,39 @@ func TestPeerConnection_DTLSRoleSettingEngine(t *testing.T) {
 		runTest(DTLSRoleClient)
 	})
 }
+
+func TestDTLSTransport_ExportKeyingMaterial(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	_, err = offerPC.SCTP().Transport().ExportKeyingMaterial("EXTRACTOR-dtls_srtp", nil, 60)
+	assert.ErrorIs(t, err, errDtlsTransportNotStarted)
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offerPC, answerPC)
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	connected.Wait()
+
+	// Both peers derive the same keying material
+	offerKeys, err := offerPC.SCTP().Transport().ExportKeyingMaterial("EXTRACTOR-dtls_srtp", nil, 60)
+	assert.NoError(t, err)
+	answerKeys, err := answerPC.SCTP().Transport().ExportKeyingMaterial("EXTRACTOR-dtls_srtp", nil, 60)
+	assert.NoError(t, err)
+	assert.Len(t, offerKeys, 60)
+	assert.Equal(t, offerKeys, answerKeys)
+
+	otherKeys, err := offerPC.SCTP().Transport().ExportKeyingMaterial("EXTRACTOR-other", nil, 60)
+	assert.NoError(t, err)
+	assert.NotEqual(t, offerKeys, otherKeys)
+
+	// Labels used by TLS itself are rejected
+	_, err = offerPC.SCTP().Transport().ExportKeyingMaterial("master secret", nil, 60)
+	assert.Error(t, err)
+
+	closePairNow(t, offerPC, answerPC)
+}
@@ -0,0 +1,116 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// telephoneEventState follows the telephone events of a track, an event is identified
+// by the RTP timestamp of its start and repeated until it ends, RFC 4733 Section 2.5.2
+type telephoneEventState struct {
+	active bool
+	ended  bool
+	event  byte
+
+	// Long events are split into segments with their own timestamp, the durations
+	// of the previous segments are in segmentsDuration
+	timestamp        uint32
+	duration         uint16
+	segmentsDuration uint32
+}
+
+// OnDTMFTone sets a handler that is called with every DTMF tone received as telephone
+// event of RFC 4733, once it has ended. The tone is 0-9, A-D, # or *. The telephone-event
+// codec has to be registered with the MediaEngine, see MimeTypeTelephoneEvent, and the
+// tracks of the RTPReceiver have to be read for the events to be seen.
+func (r *RTPReceiver) OnDTMFTone(f func(tone string, duration time.Duration)) {
+	r.onDTMFToneHandler.Store(f)
+}
+
+func (r *RTPReceiver) onDTMFTone(tone string, duration time.Duration) {
+	if handler, ok := r.onDTMFToneHandler.Load().(func(string, time.Duration)); ok && handler != nil {
+		handler(tone, duration)
+	}
+}
+
+// telephoneEventCodec returns the codec of a payload type if it is telephone-event
+func (t *TrackRemote) telephoneEventCodec(payloadType PayloadType) (RTPCodecParameters, bool) {
+	if payloadType == t.PayloadType() {
+		return RTPCodecParameters{}, false
+	}
+
+	codec, _, err := t.receiver.api.mediaEngine.getCodecByPayload(payloadType)
+	if err != nil || !strings.EqualFold(codec.MimeType, MimeTypeTelephoneEvent) {
+		return RTPCodecParameters{}, false
+	}
+	return codec, true
+}
+
+// handleTelephoneEvent reports the DTMF tones carried by telephone event packets
+func (t *TrackRemote) handleTelephoneEvent(b []byte) {
+	if len(b) < 2 {
+		return
+	}
+	codec, ok := t.telephoneEventCodec(PayloadType(b[1] & rtpPayloadTypeBitmask))
+	if !ok || codec.ClockRate == 0 {
+		return
+	}
+
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(b); err != nil || len(packet.Payload) < 4 {
+		return
+	}
+	payload := packet.Payload
+	event, end, duration := payload[0], payload[1]&0x80 != 0, binary.BigEndian.Uint16(payload[2:])
+
+	t.mu.Lock()
+	s := &t.telephoneEvent
+	var tones []string
+	var durations []uint32
+	switch {
+	case s.active && packet.Timestamp == s.timestamp:
+		// An update or a repetition of the end
+		if s.ended {
+			t.mu.Unlock()
+			return
+		}
+	case s.active && !s.ended && !packet.Marker && event == s.event && packet.Timestamp == s.timestamp+uint32(s.duration):
+		// The next segment of a long event
+		s.segmentsDuration += uint32(s.duration)
+		s.timestamp = packet.Timestamp
+	default:
+		// A new event, the end of the previous one may have been lost
+		if s.active && !s.ended {
+			tones = append(tones, dtmfTone(s.event))
+			durations = append(durations, s.segmentsDuration+uint32(s.duration))
+		}
+		*s = telephoneEventState{active: true, event: event, timestamp: packet.Timestamp}
+	}
+
+	s.duration = duration
+	if end {
+		s.ended = true
+		tones = append(tones, dtmfTone(event))
+		durations = append(durations, s.segmentsDuration+uint32(duration))
+	}
+	t.mu.Unlock()
+
+	for i, tone := range tones {
+		if tone != "" {
+			t.receiver.onDTMFTone(tone, time.Duration(durations[i])*time.Second/time.Duration(codec.ClockRate))
+		}
+	}
+}
+
+// dtmfTone returns the tone of a telephone event, or an empty string if it isn't DTMF
+func dtmfTone(event byte) string {
+	if int(event) >= len(dtmfEvents) {
+		return ""
+	}
+	return dtmfEvents[event : event+1]
+}
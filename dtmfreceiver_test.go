@@ -0,0 +1,154 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func registerTelephoneEvent(t *testing.T, m *MediaEngine) {
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeTelephoneEvent, ClockRate: 48000, SDPFmtpLine: "0-16"},
+		PayloadType:        126,
+	}, RTPCodecTypeAudio))
+}
+
+func TestTrackRemote_handleTelephoneEvent(t *testing.T) {
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	registerTelephoneEvent(t, m)
+
+	receiver := &RTPReceiver{api: NewAPI(WithMediaEngine(m))}
+	track := newTrackRemote(RTPCodecTypeAudio, 5, "", receiver)
+	assert.NoError(t, track.checkAndUpdateTrack([]byte{0x80, 111}))
+
+	type tone struct {
+		tone     string
+		duration time.Duration
+	}
+	var tones []tone
+	receiver.OnDTMFTone(func(t string, duration time.Duration) {
+		tones = append(tones, tone{t, duration})
+	})
+
+	write := func(payloadType uint8, marker bool, timestamp uint32, event byte, end bool, duration uint16) {
+		payload := []byte{event, 10, 0, 0}
+		if end {
+			payload[1] |= 0x80
+		}
+		binary.BigEndian.PutUint16(payload[2:], duration)
+		b, err := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, Marker: marker, PayloadType: payloadType, Timestamp: timestamp, SSRC: 5},
+			Payload: payload,
+		}).Marshal()
+		assert.NoError(t, err)
+
+		assert.NoError(t, track.checkAndUpdateTrack(b))
+		track.handleTelephoneEvent(b)
+	}
+
+	// The end is reported once and the codec of the track stays Opus
+	write(126, true, 1000, 5, false, 2400)
+	write(126, false, 1000, 5, true, 4800)
+	write(126, false, 1000, 5, true, 4800)
+	assert.Equal(t, MimeTypeOpus, track.Codec().MimeType)
+	assert.Equal(t, []tone{{"5", 100 * time.Millisecond}}, tones)
+
+	// Packets of the audio codec are no telephone events
+	write(111, false, 2000, 1, true, 4800)
+	assert.Len(t, tones, 1)
+
+	// A long event continues in a new segment, its end is lost
+	write(126, true, 10000, 11, false, 0xFFFF)
+	write(126, false, 10000+0xFFFF, 11, false, 4800)
+	write(126, true, 200000, 15, true, 960)
+	assert.Equal(t, []tone{
+		{"5", 100 * time.Millisecond},
+		{"#", time.Duration(0xFFFF+4800) * time.Second / 48000},
+		{"D", 20 * time.Millisecond},
+	}, tones)
+
+	// Events that aren't DTMF are ignored
+	write(126, true, 300000, 16, true, 960)
+	assert.Len(t, tones, 3)
+}
+
+func TestRTPReceiver_OnDTMFTone(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := func() *API {
+		m := &MediaEngine{}
+		assert.NoError(t, m.RegisterDefaultCodecs())
+		registerTelephoneEvent(t, m)
+		return NewAPI(WithMediaEngine(m))
+	}
+	offerer, err := api().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	answerer, err := api().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	audio, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeOpus}, "audio", "pion")
+	assert.NoError(t, err)
+	sender, err := offerer.AddTrack(audio)
+	assert.NoError(t, err)
+
+	type tone struct {
+		tone     string
+		duration time.Duration
+	}
+	tones := make(chan tone, 16)
+	readDone := make(chan struct{})
+	answerer.OnTrack(func(track *TrackRemote, receiver *RTPReceiver) {
+		defer close(readDone)
+
+		receiver.OnDTMFTone(func(t string, duration time.Duration) {
+			tones <- tone{t, duration}
+		})
+		for {
+			if _, readErr := track.ReadSample(); readErr != nil {
+				return
+			}
+			assert.Equal(t, MimeTypeOpus, track.Codec().MimeType)
+		}
+	})
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offerer, answerer)
+	assert.NoError(t, signalPair(offerer, answerer))
+	connected.Wait()
+
+	stopAudio := make(chan struct{})
+	audioDone := make(chan struct{})
+	go func() {
+		defer close(audioDone)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopAudio:
+				return
+			case <-ticker.C:
+				assert.NoError(t, audio.WriteSample(media.Sample{Data: []byte{0x00}, Duration: 20 * time.Millisecond}))
+			}
+		}
+	}()
+
+	assert.NoError(t, sender.DTMF().InsertDTMF("1#", 120*time.Millisecond, 50*time.Millisecond))
+	assert.Equal(t, tone{"1", 120 * time.Millisecond}, <-tones)
+	assert.Equal(t, tone{"#", 120 * time.Millisecond}, <-tones)
+
+	close(stopAudio)
+	<-audioDone
+	closePairNow(t, offerer, answerer)
+	<-readDone
+}
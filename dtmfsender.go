@@ -0,0 +1,356 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/randutil"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
+)
+
+const (
+	// Limits of the duration and the gap between tones, see the W3C RTCDTMFSender
+	dtmfMinDuration     = 40 * time.Millisecond
+	dtmfMaxDuration     = 6000 * time.Millisecond
+	dtmfMinInterToneGap = 30 * time.Millisecond
+
+	// dtmfPause is how long a comma in the tone buffer pauses
+	dtmfPause = 2 * time.Second
+
+	// dtmfPacketInterval is how often a telephone event is updated while the tone lasts
+	dtmfPacketInterval = 50 * time.Millisecond
+
+	// dtmfEndPackets is how often the end of an event is sent, RFC 4733 Section 2.5.1.4
+	dtmfEndPackets = 3
+
+	// dtmfVolume is the power level of the tones in -dBm0
+	dtmfVolume = 10
+
+	// dtmfMaxSegmentDuration is the longest duration a telephone event can carry, longer
+	// events are split in segments, RFC 4733 Section 2.5.1.3
+	dtmfMaxSegmentDuration = 0xFFFF
+
+	// dtmfEvents are the tones in the order of their event codes, RFC 4733 Section 3.2
+	dtmfEvents = "0123456789*#ABCD"
+)
+
+// DTMFSender sends DTMF tones as telephone events of RFC 4733 with an audio RTPSender,
+// e.g. to drive IVR systems through SIP gateways. The telephone-event codec has to be
+// registered with the MediaEngine with the clock rate of the audio codec, see
+// MimeTypeTelephoneEvent, and the remote has to accept it.
+//
+// The events are sent between the packets of the audio track on the same SSRC, the
+// sequence numbers of the track are shifted by the events sent so far.
+type DTMFSender struct {
+	rtpSender *RTPSender
+
+	mu                  sync.Mutex
+	toneBuffer          string
+	duration            time.Duration
+	interToneGap        time.Duration
+	playing             bool
+	payloadType         PayloadType
+	clockRate           uint32
+	onToneChangeHandler func(tone string)
+
+	// stream is the state of the RTP stream the events are sent on
+	stream struct {
+		mu             sync.Mutex
+		started        bool
+		mediaStarted   bool
+		sequenceNumber uint16
+		sequenceOffset uint16
+
+		// timestamp was the RTP timestamp at timestampTime, the timestamps of
+		// events are extrapolated from it
+		hasTimestamp  bool
+		timestamp     uint32
+		timestampTime time.Time
+	}
+}
+
+func newDTMFSender(rtpSender *RTPSender) *DTMFSender {
+	return &DTMFSender{rtpSender: rtpSender}
+}
+
+// DTMF returns the DTMFSender of the RTPSender, it is nil if the track isn't audio
+func (r *RTPSender) DTMF() *DTMFSender {
+	return r.dtmf
+}
+
+// setCodec looks for the telephone-event codec that can be sent with the codec of the track
+func (d *DTMFSender) setCodec(codecs []RTPCodecParameters, codec RTPCodecParameters) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.payloadType, d.clockRate = 0, 0
+	for _, c := range codecs {
+		if strings.EqualFold(c.MimeType, MimeTypeTelephoneEvent) && c.ClockRate == codec.ClockRate {
+			d.payloadType, d.clockRate = c.PayloadType, c.ClockRate
+			return
+		}
+	}
+}
+
+// CanInsertDTMF tells if the RTPSender is sending and telephone-event has been negotiated
+func (d *DTMFSender) CanInsertDTMF() bool {
+	d.mu.Lock()
+	negotiated := d.clockRate != 0
+	d.mu.Unlock()
+
+	r := d.rtpSender
+	if !negotiated || !r.hasSent() || r.hasStopped() {
+		return false
+	}
+
+	r.mu.RLock()
+	tr := r.tr
+	r.mu.RUnlock()
+	if tr == nil {
+		return true
+	}
+	direction := tr.Direction()
+	return direction == RTPTransceiverDirectionSendrecv || direction == RTPTransceiverDirectionSendonly
+}
+
+// InsertDTMF replaces the tones that are still to be sent. Tones are 0-9, A-D, # and *,
+// a comma pauses for two seconds. Every tone lasts for the duration, from 40ms to 6s,
+// and is followed by the inter tone gap of at least 30ms. Out of range values are
+// clamped, an empty string cancels the tones that haven't been started.
+func (d *DTMFSender) InsertDTMF(tones string, duration, interToneGap time.Duration) error {
+	if !d.CanInsertDTMF() {
+		return &rtcerr.InvalidStateError{Err: errDTMFSenderCannotInsert}
+	}
+
+	tones = strings.ToUpper(tones)
+	for _, tone := range tones {
+		if tone != ',' && !strings.ContainsRune(dtmfEvents, tone) {
+			return &rtcerr.InvalidCharacterError{Err: errDTMFSenderInvalidTone}
+		}
+	}
+
+	switch {
+	case duration < dtmfMinDuration:
+		duration = dtmfMinDuration
+	case duration > dtmfMaxDuration:
+		duration = dtmfMaxDuration
+	}
+	if interToneGap < dtmfMinInterToneGap {
+		interToneGap = dtmfMinInterToneGap
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.toneBuffer = tones
+	d.duration = duration
+	d.interToneGap = interToneGap
+	if !d.playing && tones != "" {
+		d.playing = true
+		go d.play()
+	}
+	return nil
+}
+
+// ToneBuffer returns the tones that are still to be sent
+func (d *DTMFSender) ToneBuffer() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.toneBuffer
+}
+
+// OnToneChange sets a handler that is called when a tone starts, and with an empty
+// string when all tones have been sent
+func (d *DTMFSender) OnToneChange(f func(tone string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onToneChangeHandler = f
+}
+
+// play sends the tones of the buffer until it is empty or the RTPSender is stopped
+func (d *DTMFSender) play() {
+	for {
+		d.mu.Lock()
+		handler := d.onToneChangeHandler
+		if d.toneBuffer == "" {
+			d.playing = false
+			d.mu.Unlock()
+			if handler != nil {
+				handler("")
+			}
+			return
+		}
+		tone := d.toneBuffer[0]
+		d.toneBuffer = d.toneBuffer[1:]
+		duration, interToneGap := d.duration, d.interToneGap
+		d.mu.Unlock()
+
+		if handler != nil {
+			handler(string(tone))
+		}
+
+		ok := true
+		if tone == ',' {
+			ok = d.wait(dtmfPause)
+		} else if ok = d.sendEvent(byte(strings.IndexByte(dtmfEvents, tone)), duration); ok {
+			ok = d.wait(interToneGap)
+		}
+
+		if !ok {
+			d.mu.Lock()
+			d.toneBuffer = ""
+			d.playing = false
+			d.mu.Unlock()
+			return
+		}
+	}
+}
+
+// wait returns false if the RTPSender has been stopped in the meantime
+func (d *DTMFSender) wait(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.rtpSender.stopCalled:
+		return false
+	}
+}
+
+// sendEvent sends a telephone event that is updated every packet interval until it
+// lasts for the duration, the end is sent repeatedly
+func (d *DTMFSender) sendEvent(event byte, duration time.Duration) bool {
+	d.mu.Lock()
+	payloadType, clockRate := d.payloadType, d.clockRate
+	d.mu.Unlock()
+
+	timestamp := d.eventTimestamp(time.Now(), clockRate)
+	var elapsed time.Duration
+	var segmentOffset uint32
+	marker := true
+	for elapsed < duration {
+		interval := dtmfPacketInterval
+		if duration-elapsed < interval {
+			interval = duration - elapsed
+		}
+		if !d.wait(interval) {
+			return false
+		}
+		elapsed += interval
+
+		units := uint32(elapsed * time.Duration(clockRate) / time.Second)
+		for units-segmentOffset > dtmfMaxSegmentDuration {
+			if err := d.writeEvent(payloadType, timestamp, marker, event, false, dtmfMaxSegmentDuration); err != nil {
+				return false
+			}
+			timestamp += dtmfMaxSegmentDuration
+			segmentOffset += dtmfMaxSegmentDuration
+			marker = false
+		}
+
+		end := elapsed >= duration
+		count := 1
+		if end {
+			count = dtmfEndPackets
+		}
+		for i := 0; i < count; i++ {
+			if err := d.writeEvent(payloadType, timestamp, marker, event, end, uint16(units-segmentOffset)); err != nil {
+				return false
+			}
+			marker = false
+		}
+	}
+	return true
+}
+
+// eventTimestamp extrapolates the RTP timestamp of the audio track to the start of an event
+func (d *DTMFSender) eventTimestamp(now time.Time, clockRate uint32) uint32 {
+	s := &d.stream
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasTimestamp {
+		s.hasTimestamp = true
+		s.timestamp = randutil.NewMathRandomGenerator().Uint32()
+		s.timestampTime = now
+	}
+	return s.timestamp + uint32(now.Sub(s.timestampTime)*time.Duration(clockRate)/time.Second)
+}
+
+// writeEvent writes a telephone event packet with the next sequence number of the stream
+func (d *DTMFSender) writeEvent(payloadType PayloadType, timestamp uint32, marker bool, event byte, end bool, duration uint16) error {
+	encoding := d.rtpSender.trackEncodings[0]
+
+	payload := make([]byte, 4)
+	payload[0] = event
+	payload[1] = dtmfVolume
+	if end {
+		payload[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(payload[2:], duration)
+
+	s := &d.stream
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		s.started = true
+		s.sequenceNumber = uint16(randutil.NewMathRandomGenerator().Uint32())
+	}
+	s.sequenceNumber++
+	if s.mediaStarted {
+		s.sequenceOffset++
+	}
+
+	header := &rtp.Header{
+		Version:        2,
+		Marker:         marker,
+		PayloadType:    uint8(payloadType),
+		SequenceNumber: s.sequenceNumber,
+		Timestamp:      timestamp,
+		SSRC:           uint32(encoding.context.ssrc),
+	}
+	_, err := encoding.rtpInterceptor.Write(header, payload, interceptor.Attributes{})
+	return err
+}
+
+// writeMedia writes a packet of the audio track, shifted behind the events sent so far
+func (d *DTMFSender) writeMedia(writer interceptor.RTPWriter, header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+	s := &d.stream
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.mediaStarted {
+		s.mediaStarted = true
+		if s.started {
+			s.sequenceOffset = s.sequenceNumber + 1 - header.SequenceNumber
+		}
+	}
+
+	// The header is shared with the other bindings of the track
+	if s.sequenceOffset != 0 {
+		h := *header
+		h.SequenceNumber += s.sequenceOffset
+		header = &h
+	}
+
+	if !s.started || int16(header.SequenceNumber-s.sequenceNumber) > 0 {
+		s.started = true
+		s.sequenceNumber = header.SequenceNumber
+	}
+	s.hasTimestamp = true
+	s.timestamp = header.Timestamp
+	s.timestampTime = time.Now()
+
+	return writer.Write(header, payload, attributes)
+}
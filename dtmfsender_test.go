@@ -0,0 +1,152 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDTMFSender(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const telephoneEventPayloadType = 101
+
+	api := func() *API {
+		m := &MediaEngine{}
+		assert.NoError(t, m.RegisterDefaultCodecs())
+		assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+			RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeTelephoneEvent, ClockRate: 48000, SDPFmtpLine: "0-16"},
+			PayloadType:        telephoneEventPayloadType,
+		}, RTPCodecTypeAudio))
+		return NewAPI(WithMediaEngine(m))
+	}
+	offerer, err := api().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	answerer, err := api().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	video, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	videoSender, err := offerer.AddTrack(video)
+	assert.NoError(t, err)
+	assert.Nil(t, videoSender.DTMF())
+
+	audio, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeOpus}, "audio", "pion")
+	assert.NoError(t, err)
+	sender, err := offerer.AddTrack(audio)
+	assert.NoError(t, err)
+
+	dtmf := sender.DTMF()
+	assert.False(t, dtmf.CanInsertDTMF())
+	var stateErr *rtcerr.InvalidStateError
+	assert.True(t, errors.As(dtmf.InsertDTMF("1", time.Second, time.Second), &stateErr))
+
+	type event struct {
+		code     byte
+		end      bool
+		duration uint16
+	}
+	events := make(chan event, 16)
+	answerer.OnTrack(func(track *TrackRemote, _ *RTPReceiver) {
+		if track.Kind() != RTPCodecTypeAudio {
+			return
+		}
+
+		var lastSequenceNumber uint16
+		for i := 0; ; i++ {
+			packet, _, readErr := track.ReadRTP()
+			if readErr != nil {
+				return
+			}
+
+			// Events are sent between the audio packets without a gap in sequence numbers
+			if i != 0 {
+				assert.Equal(t, lastSequenceNumber+1, packet.SequenceNumber)
+			}
+			lastSequenceNumber = packet.SequenceNumber
+
+			if packet.PayloadType != telephoneEventPayloadType {
+				continue
+			}
+			events <- event{
+				code:     packet.Payload[0],
+				end:      packet.Payload[1]&0x80 != 0,
+				duration: binary.BigEndian.Uint16(packet.Payload[2:]),
+			}
+		}
+	})
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offerer, answerer)
+	assert.NoError(t, signalPair(offerer, answerer))
+	connected.Wait()
+
+	stopAudio := make(chan struct{})
+	audioDone := make(chan struct{})
+	go func() {
+		defer close(audioDone)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopAudio:
+				return
+			case <-ticker.C:
+				assert.NoError(t, audio.WriteSample(media.Sample{Data: []byte{0x00}, Duration: 20 * time.Millisecond}))
+			}
+		}
+	}()
+
+	assert.True(t, dtmf.CanInsertDTMF())
+	var charErr *rtcerr.InvalidCharacterError
+	assert.True(t, errors.As(dtmf.InsertDTMF("1X", time.Second, time.Second), &charErr))
+
+	toneChanges := make(chan string, 3)
+	dtmf.OnToneChange(func(tone string) {
+		toneChanges <- tone
+	})
+
+	// The durations are clamped to 40ms and 30ms, a tone shorter than the packet
+	// interval is only sent as its end
+	assert.NoError(t, dtmf.InsertDTMF("1a", time.Millisecond, time.Millisecond))
+	assert.Equal(t, "1", <-toneChanges)
+	assert.Equal(t, "A", <-toneChanges)
+	assert.Equal(t, "", <-toneChanges)
+	assert.Equal(t, "", dtmf.ToneBuffer())
+
+	receiveEvents := func(n int) []event {
+		received := []event{}
+		for i := 0; i < n; i++ {
+			received = append(received, <-events)
+		}
+		return received
+	}
+	assert.Equal(t, []event{
+		{1, true, 1920}, {1, true, 1920}, {1, true, 1920},
+		{12, true, 1920}, {12, true, 1920}, {12, true, 1920},
+	}, receiveEvents(6))
+
+	// Longer tones are updated every 50ms
+	assert.NoError(t, dtmf.InsertDTMF("#", 120*time.Millisecond, 70*time.Millisecond))
+	assert.Equal(t, "#", <-toneChanges)
+	assert.Equal(t, "", <-toneChanges)
+	assert.Equal(t, []event{
+		{11, false, 2400}, {11, false, 4800},
+		{11, true, 5760}, {11, true, 5760}, {11, true, 5760},
+	}, receiveEvents(5))
+
+	close(stopAudio)
+	<-audioDone
+	closePairNow(t, offerer, answerer)
+}
@@ -0,0 +1,133 @@
+// +build !js
+
+package webrtc
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// earlyMediaTimeout is how long media for a media section that isn't in the remote
+	// description waits for the next remote description
+	earlyMediaTimeout = 5 * time.Second
+
+	// earlyMediaDrainTimeout is how long a stream is read to discard its early packets
+	// with EarlyMediaHandlingDrop
+	earlyMediaDrainTimeout = 5 * time.Millisecond
+
+	// earlyMediaMaxBytes is how many bytes of early packets are stored for all the
+	// SSRCs together, the packets that don't fit are dropped
+	earlyMediaMaxBytes = 1 << 20
+)
+
+// earlyMedia holds back incoming media that can't be delivered yet. The media path
+// is closed while a remote description is applied, the media may belong to one of
+// its tracks. Packets read before their track exists are stored by SSRC.
+type earlyMedia struct {
+	mu      sync.Mutex
+	closed  bool
+	stopped bool
+	opened  chan struct{} // closed while the media path is open
+	next    chan struct{} // closed the next time the media path opens
+	packets map[SSRC][][]byte
+	size    int // bytes stored in packets
+}
+
+func newEarlyMedia() *earlyMedia {
+	opened := make(chan struct{})
+	close(opened)
+	return &earlyMedia{
+		opened:  opened,
+		next:    make(chan struct{}),
+		packets: map[SSRC][][]byte{},
+	}
+}
+
+// closePath closes the media path until openPath is called
+func (e *earlyMedia) closePath() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed || e.stopped {
+		return
+	}
+	e.closed = true
+	e.opened = make(chan struct{})
+}
+
+// openPath opens the media path and wakes up everyone waiting for it
+func (e *earlyMedia) openPath() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed || e.stopped {
+		return
+	}
+	e.closed = false
+	close(e.opened)
+	close(e.next)
+	e.next = make(chan struct{})
+}
+
+// stop opens the media path for good and drops the stored packets
+func (e *earlyMedia) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopped {
+		return
+	}
+	e.stopped = true
+	if e.closed {
+		e.closed = false
+		close(e.opened)
+	}
+	close(e.next)
+	e.packets = map[SSRC][][]byte{}
+	e.size = 0
+}
+
+// waitOpen returns a channel that is closed once the media path is open
+func (e *earlyMedia) waitOpen() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.opened
+}
+
+// waitNext returns a channel that is closed the next time the media path opens
+func (e *earlyMedia) waitNext() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.next
+}
+
+// store keeps the packets of an SSRC for its track
+func (e *earlyMedia) store(ssrc SSRC, packets [][]byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(packets) == 0 || e.stopped {
+		return
+	}
+	for _, packet := range packets {
+		if e.size+len(packet) > earlyMediaMaxBytes {
+			return
+		}
+		e.size += len(packet)
+		e.packets[ssrc] = append(e.packets[ssrc], packet)
+	}
+}
+
+// take returns the packets stored for an SSRC and forgets them
+func (e *earlyMedia) take(ssrc SSRC) [][]byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	packets := e.packets[ssrc]
+	delete(e.packets, ssrc)
+	for _, packet := range packets {
+		e.size -= len(packet)
+	}
+	return packets
+}
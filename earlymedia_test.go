@@ -0,0 +1,89 @@
+// +build !js
+
+package webrtc
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEarlyMedia(t *testing.T) {
+	isClosed := func(c <-chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	e := newEarlyMedia()
+	assert.True(t, isClosed(e.waitOpen()))
+
+	next := e.waitNext()
+	e.closePath()
+	opened := e.waitOpen()
+	assert.False(t, isClosed(opened))
+
+	e.store(1, [][]byte{{0x01}})
+	e.store(1, [][]byte{{0x02}})
+	assert.Equal(t, [][]byte{{0x01}, {0x02}}, e.take(1))
+	assert.Empty(t, e.take(1))
+
+	e.openPath()
+	assert.True(t, isClosed(opened))
+	assert.True(t, isClosed(next))
+	assert.False(t, isClosed(e.waitNext()))
+
+	// Opening an open path doesn't wake up anyone waiting for the next time
+	next = e.waitNext()
+	e.openPath()
+	assert.False(t, isClosed(next))
+
+	e.closePath()
+	opened = e.waitOpen()
+	e.stop()
+	assert.True(t, isClosed(opened))
+	assert.True(t, isClosed(next))
+
+	e.closePath()
+	assert.True(t, isClosed(e.waitOpen()))
+	e.store(1, [][]byte{{0x01}})
+	assert.Empty(t, e.take(1))
+}
+
+func TestEarlyMedia_MaxBytes(t *testing.T) {
+	e := newEarlyMedia()
+
+	half := make([]byte, earlyMediaMaxBytes/2)
+	e.store(1, [][]byte{half, half, {0x01}})
+	e.store(2, [][]byte{{0x02}})
+	assert.Len(t, e.take(1), 2)
+	assert.Empty(t, e.take(2))
+
+	// Taking the packets frees their space
+	e.store(2, [][]byte{{0x02}})
+	assert.Equal(t, [][]byte{{0x02}}, e.take(2))
+}
+
+func TestTrackRemote_ReadEarly(t *testing.T) {
+	track := newTrackRemote(RTPCodecTypeVideo, 1, "", nil)
+	track.setEarlyPackets([][]byte{{0x01, 0x02}, {0x03}})
+
+	b := make([]byte, 1)
+	n, ok, err := track.readEarly(b)
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, io.ErrShortBuffer)
+	assert.Zero(t, n)
+
+	n, ok, err = track.readEarly(b)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x03}, b[:n])
+
+	_, ok, err = track.readEarly(b)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
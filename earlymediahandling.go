@@ -0,0 +1,35 @@
+// +build !js
+
+package webrtc
+
+// EarlyMediaHandling determines what happens to media that arrives before it can be
+// delivered to a TrackRemote: while a remote description is applied, or before the
+// track of its SSRC has been created. See SettingEngine.SetEarlyMediaHandling
+type EarlyMediaHandling int
+
+const (
+	// EarlyMediaHandlingBuffer holds the packets back and delivers them once the
+	// track has been created, so the first frames are complete. This is the default.
+	EarlyMediaHandlingBuffer EarlyMediaHandling = iota
+
+	// EarlyMediaHandlingDrop discards the packets, a track starts with the packets
+	// that arrive after it has been created
+	EarlyMediaHandlingDrop
+)
+
+// This is done this way because of a linter.
+const (
+	earlyMediaHandlingBufferStr = "buffer"
+	earlyMediaHandlingDropStr   = "drop"
+)
+
+func (h EarlyMediaHandling) String() string {
+	switch h {
+	case EarlyMediaHandlingBuffer:
+		return earlyMediaHandlingBufferStr
+	case EarlyMediaHandlingDrop:
+		return earlyMediaHandlingDropStr
+	default:
+		return ErrUnknownType.Error()
+	}
+}
@@ -0,0 +1,27 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEarlyMediaHandling_String(t *testing.T) {
+	testCases := []struct {
+		handling       EarlyMediaHandling
+		expectedString string
+	}{
+		{EarlyMediaHandlingBuffer, "buffer"},
+		{EarlyMediaHandlingDrop, "drop"},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.expectedString,
+			testCase.handling.String(),
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}
@@ -2,6 +2,7 @@ package webrtc
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -27,6 +28,10 @@ var (
 	// or malformed.
 	ErrTurnCredentials = errors.New("invalid turn server credentials")
 
+	// ErrTurnOAuthNotSupported indicates that a TURN server with OAuth
+	// credentials was left out, only long-term credentials are supported.
+	ErrTurnOAuthNotSupported = errors.New("turn server oauth credentials not supported")
+
 	// ErrExistingTrack indicates that a track already exists.
 	ErrExistingTrack = errors.New("track already exists")
 
@@ -54,14 +59,31 @@ var (
 	// ICECandidatePoolSize was made after PeerConnection has been initialized.
 	ErrModifyingICECandidatePoolSize = errors.New("ice candidate pool size cannot be modified")
 
+	// ErrModifyingPeerConnectionID indicates that an attempt to modify
+	// PeerConnectionID was made after PeerConnection has been initialized.
+	ErrModifyingPeerConnectionID = errors.New("peer connection id cannot be modified")
+
+	// ErrModifyingICETransportPolicy indicates that an attempt to modify
+	// ICETransportPolicy was made after gathering started.
+	ErrModifyingICETransportPolicy = errors.New("ice transport policy cannot be modified after gathering started")
+
+	// ErrModifyingICEServerCount indicates that an attempt to change the number
+	// of ICE server URLs was made after gathering started.
+	ErrModifyingICEServerCount = errors.New("number of ice server urls cannot be modified after gathering started")
+
 	// ErrStringSizeLimit indicates that the character size limit of string is
 	// exceeded. The limit is hardcoded to 65535 according to specifications.
 	ErrStringSizeLimit = errors.New("data channel label exceeds size limit")
 
 	// ErrMaxDataChannelID indicates that the maximum number ID that could be
-	// specified for a data channel has been exceeded.
+	// specified for a data channel has been exceeded, all IDs of the DTLS role,
+	// even for the client and odd for the server, are used by open data channels.
 	ErrMaxDataChannelID = errors.New("maximum number ID for datachannel specified")
 
+	// ErrMaxDataChannels indicates that the number of open data channels reached
+	// the limit set with SettingEngine.SetSCTPMaxDataChannels.
+	ErrMaxDataChannels = errors.New("maximum number of open data channels reached")
+
 	// ErrNegotiatedWithoutID indicates that an attempt to create a data channel
 	// was made while setting the negotiated option to true without providing
 	// the negotiated channel ID.
@@ -126,6 +148,10 @@ var (
 	// ErrNoSRTPProtectionProfile indicates that the DTLS handshake completed and no SRTP Protection Profile was chosen
 	ErrNoSRTPProtectionProfile = errors.New("DTLS Handshake completed and no SRTP Protection Profile was chosen")
 
+	// ErrFailedToGenerateCertificate indicates that creating the key or the X.509 certificate
+	// of a Certificate failed, the error of crypto/x509 or crypto/rand is wrapped
+	ErrFailedToGenerateCertificate = errors.New("failed to generate certificate")
+
 	// ErrFailedToGenerateCertificateFingerprint indicates that we failed to generate the fingerprint used for comparing certificates
 	ErrFailedToGenerateCertificateFingerprint = errors.New("failed to generate certificate fingerprint")
 
@@ -144,9 +170,41 @@ var (
 	// ErrRegisterHeaderExtensionInvalidDirection indicates that a extension was registered with a direction besides `sendonly` or `recvonly`
 	ErrRegisterHeaderExtensionInvalidDirection = errors.New("a header extension must be registered as 'recvonly', 'sendonly' or both")
 
+	// ErrTooManyCSRC indicates that a RTP packet was written with more CSRCs than
+	// can be encoded in the RTP header
+	ErrTooManyCSRC = errors.New("a RTP packet can not contain more than 15 CSRCs")
+
 	// ErrSimulcastProbeOverflow indicates that too many Simulcast probe streams are in flight and the requested SSRC was ignored
 	ErrSimulcastProbeOverflow = errors.New("simulcast probe limit has been reached, new SSRC has been discarded")
 
+	// ErrBufferFull indicates that a message wasn't sent because the BufferedAmount of the
+	// DataChannel would have exceeded its MaxBufferedAmount
+	ErrBufferFull = errors.New("data channel buffer is full")
+
+	// ErrNAT64PrefixNotFound indicates that the network has no DNS64 that synthesizes
+	// IPv6 addresses for IPv4-only names, so there is no NAT64 prefix to discover
+	ErrNAT64PrefixNotFound = errors.New("no NAT64 prefix found")
+
+	// ErrSDPValidation indicates that a remote description breaks a rule of JSEP, it is only
+	// returned when SettingEngine.EnableStrictSDPValidation is set
+	ErrSDPValidation = errors.New("remote description failed strict validation")
+
+	// ErrRTCPMuxRequired indicates that a remote description doesn't multiplex RTCP with
+	// RTP (a=rtcp-mux). RTCP on a separate port isn't supported, whatever the RTCPMuxPolicy.
+	ErrRTCPMuxRequired = errors.New("remote description doesn't multiplex RTCP with RTP, which is required")
+
+	// ErrICEGathererInUse indicates that an ICEGatherer passed to NewPeerConnectionWithICEGatherer
+	// is already used by another PeerConnection
+	ErrICEGathererInUse = errors.New("ICEGatherer is already used by a PeerConnection")
+
+	// ErrICEGathererClosed indicates that an ICEGatherer passed to NewPeerConnectionWithICEGatherer
+	// has been closed
+	ErrICEGathererClosed = errors.New("ICEGatherer is closed")
+
+	// ErrSCTPNotEstablished indicates that a DataChannel was used before the SCTP association
+	// it is sent on has been established
+	ErrSCTPNotEstablished = errors.New("SCTP not established")
+
 	errDetachNotEnabled                 = errors.New("enable detaching by calling webrtc.DetachDataChannels()")
 	errDetachBeforeOpened               = errors.New("datachannel not opened yet, try calling Detach from OnOpen")
 	errDtlsTransportNotStarted          = errors.New("the DTLS transport has not started yet")
@@ -166,9 +224,28 @@ var (
 	errICERoleUnknown                 = errors.New("unknown ICE Role")
 	errICEProtocolUnknown             = errors.New("unknown protocol")
 	errICEGathererNotStarted          = errors.New("gatherer not started")
+	errICEGathererNil                 = errors.New("ICEGatherer is nil")
+	errICEServerNoResponse            = errors.New("no response to the STUN request")
+	errICEServerInvalidResponse       = errors.New("invalid response to the STUN Binding request")
+	errICEServerNoRealm               = errors.New("no realm or nonce in the Unauthorized response")
+	errICEGathererNoRoomForServer     = errors.New("the ICE agent was created with fewer servers, it has no room for this one")
+
+	errNATPMPInvalidResponse = errors.New("invalid NAT-PMP response")
+	errNATPMPResultCode      = errors.New("NAT-PMP request failed with result code")
+
+	errUPnPNoGateway       = errors.New("no UPnP internet gateway device answered")
+	errUPnPNoWANService    = errors.New("UPnP gateway has no WAN connection service")
+	errUPnPInvalidResponse = errors.New("invalid UPnP response")
+	errUPnPFault           = errors.New("UPnP request failed with error code")
 
 	errNetworkTypeUnknown = errors.New("unknown network type")
 
+	errInvalidDSCP = errors.New("DSCP must be less than 64")
+
+	errICEUDPMuxShardsInvalid = errors.New("a sharded UDPMux needs at least one shard")
+	errReusePortUnsupported   = errors.New("SO_REUSEPORT is not supported on this platform")
+	errICEPacketConnNotUDP    = errors.New("the local address of the ICE PacketConn isn't a UDP address")
+
 	errSDPDoesNotMatchOffer                           = errors.New("new sdp does not match previous offer")
 	errSDPDoesNotMatchAnswer                          = errors.New("new sdp does not match previous answer")
 	errPeerConnSDPTypeInvalidValue                    = errors.New("provided value is not a valid enum value of type SDPType")
@@ -182,6 +259,7 @@ var (
 	errPeerConnSimulcastMidRTPExtensionRequired       = errors.New("mid RTP Extensions required for Simulcast")
 	errPeerConnSimulcastStreamIDRTPExtensionRequired  = errors.New("stream id RTP Extensions required for Simulcast")
 	errPeerConnSimulcastIncomingSSRCFailed            = errors.New("incoming SSRC failed Simulcast probing")
+	errPeerConnUndeclaredSSRCMidReceiving             = errors.New("media section of the mid already receives a SSRC")
 	errPeerConnAddTransceiverFromKindOnlyAcceptsOne   = errors.New("AddTransceiverFromKind only accepts one RTPTransceiverInit")
 	errPeerConnAddTransceiverFromTrackOnlyAcceptsOne  = errors.New("AddTransceiverFromTrack only accepts one RTPTransceiverInit")
 	errPeerConnAddTransceiverFromKindSupport          = errors.New("AddTransceiverFromKind currently only supports recvonly")
@@ -200,6 +278,16 @@ var (
 	errRTPSenderDTLSTransportNil  = errors.New("DTLSTransport must not be nil")
 	errRTPSenderSendAlreadyCalled = errors.New("Send has already been called")
 
+	errRTPSenderTransactionIDMismatch = errors.New("parameters aren't from the last GetParameters call")
+	errRTPSenderEncodingsModified     = errors.New("encodings can't be added, removed or change their SSRC or RID")
+	errRTPSenderScaleResolutionDownBy = errors.New("ScaleResolutionDownBy must be at least 1")
+
+	errRTPSenderEncodingTrackMismatch = errors.New("encoding track must have the same ID, StreamID and kind")
+	errRTPSenderRIDNil                = errors.New("simulcast encodings must have a RID")
+	errRTPSenderRIDCollision          = errors.New("encoding with the same RID already exists")
+	errRTPSenderReplaceTrackSimulcast = errors.New("ReplaceTrack is not supported with simulcast encodings")
+	errRTPSenderNoEncodingForRID      = errors.New("no encoding with RID")
+
 	errRTPTransceiverCannotChangeMid        = errors.New("errRTPSenderTrackNil")
 	errRTPTransceiverSetSendingInvalidState = errors.New("invalid state change in RTPTransceiver.setSending")
 	errRTPTransceiverCodecUnsupported       = errors.New("unsupported codec type by this transceiver")
@@ -211,6 +299,7 @@ var (
 	errSDPMediaSectionMultipleTrackInvalid = errors.New("invalid Media Section. Can not have multiple tracks in one MediaSection in UnifiedPlan")
 
 	errSettingEngineSetAnsweringDTLSRole = errors.New("SetAnsweringDTLSRole must DTLSRoleClient or DTLSRoleServer")
+	errSettingEngineNAT64Prefix          = errors.New("NAT64 prefix must be an IPv6 prefix of length 32, 40, 48, 56, 64 or 96")
 
 	errSignalingStateCannotRollback            = errors.New("can't rollback from stable state")
 	errSignalingStateProposedTransitionInvalid = errors.New("invalid proposed signaling state transition")
@@ -222,4 +311,43 @@ var (
 	errCertificatePEMFormatError = errors.New("bad Certificate PEM format")
 
 	errRTPTooShort = errors.New("not long enough to be a RTP Packet")
+
+	errTrackRemoteReadSampleCodecUnsupported = errors.New("ReadSample doesn't support codec")
+
+	errDTMFSenderCannotInsert = errors.New("DTMFSender can't send, the RTPSender isn't sending or telephone-event hasn't been negotiated")
+	errDTMFSenderInvalidTone  = errors.New("DTMF tones can only contain 0-9, A-D, #, * and ,")
+
+	errCloseReasonsNotEnabled = errors.New("close reasons aren't enabled on both peers, see SettingEngine.EnableCloseReasons")
+
+	errFECPacketTooShort = errors.New("not long enough to be a FEC packet")
+	errFECUnsupported    = errors.New("FEC packet uses unsupported features")
+	errREDPacketTooShort = errors.New("not long enough to be a RED packet")
+
+	errRTPPaddingInvalid = errors.New("RTP padding is longer than the payload")
 )
+
+// wrappedError is an error of this package that wraps the error that caused it,
+// errors.Is matches both of them
+type wrappedError struct {
+	sentinel error
+	err      error
+}
+
+// wrapError returns an error matching sentinel with errors.Is, that unwraps to err
+func wrapError(sentinel, err error) error {
+	return &wrappedError{sentinel: sentinel, err: err}
+}
+
+func (e *wrappedError) Error() string {
+	return fmt.Sprintf("%v: %v", e.sentinel, e.err)
+}
+
+// Is tells errors.Is that the error matches its sentinel
+func (e *wrappedError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// Unwrap returns the error that caused it
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,25 @@
+package webrtc
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapError(t *testing.T) {
+	err := wrapError(ErrFailedToGenerateCertificate, io.ErrUnexpectedEOF)
+	assert.Equal(t, "failed to generate certificate: unexpected EOF", err.Error())
+
+	// Both the sentinel and the cause match, also through the errors of rtcerr
+	wrapped := &rtcerr.UnknownError{Err: err}
+	assert.True(t, errors.Is(wrapped, ErrFailedToGenerateCertificate))
+	assert.True(t, errors.Is(wrapped, io.ErrUnexpectedEOF))
+	assert.False(t, errors.Is(wrapped, ErrFailedToGenerateCertificateFingerprint))
+
+	var unknownErr *rtcerr.UnknownError
+	assert.False(t, errors.As(err, &unknownErr))
+	assert.True(t, errors.As(wrapped, &unknownErr))
+}
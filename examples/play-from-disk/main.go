@@ -5,9 +5,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
-	"time"
 
 	"github.com/pion/webrtc/v3"
 	"github.com/pion/webrtc/v3/examples/internal/signal"
@@ -83,7 +81,7 @@ func main() {
 				panic(ivfErr)
 			}
 
-			ivf, header, ivfErr := ivfreader.NewWith(file)
+			ivf, _, ivfErr := ivfreader.NewSampleReader(file, false)
 			if ivfErr != nil {
 				panic(ivfErr)
 			}
@@ -91,25 +89,14 @@ func main() {
 			// Wait for connection established
 			<-iceConnectedCtx.Done()
 
-			// Send our video file frame at a time. Pace our sending so we send it at the same speed it should be played back as.
+			// Send our video file frame at a time. Play paces our sending so we send it at the same speed it should be played back as.
 			// This isn't required since the video is timestamped, but we will such much higher loss if we send all at once.
-			sleepTime := time.Millisecond * time.Duration((float32(header.TimebaseNumerator)/float32(header.TimebaseDenominator))*1000)
-			for {
-				frame, _, ivfErr := ivf.ParseNextFrame()
-				if ivfErr == io.EOF {
-					fmt.Printf("All video frames parsed and sent")
-					os.Exit(0)
-				}
-
-				if ivfErr != nil {
-					panic(ivfErr)
-				}
-
-				time.Sleep(sleepTime)
-				if ivfErr = videoTrack.WriteSample(media.Sample{Data: frame, Duration: time.Second}); ivfErr != nil {
-					panic(ivfErr)
-				}
+			if ivfErr = media.Play(context.Background(), videoTrack, ivf); ivfErr != nil {
+				panic(ivfErr)
 			}
+
+			fmt.Printf("All video frames parsed and sent")
+			os.Exit(0)
 		}()
 	}
 
@@ -138,14 +125,13 @@ func main() {
 		}()
 
 		go func() {
-			// Open a IVF file and start reading using our IVFReader
+			// Open a Ogg file and start reading using our OggReader
 			file, oggErr := os.Open(audioFileName)
 			if oggErr != nil {
 				panic(oggErr)
 			}
 
-			// Open on oggfile in non-checksum mode.
-			ogg, _, oggErr := oggreader.NewWith(file)
+			ogg, _, oggErr := oggreader.NewSampleReader(file, false)
 			if oggErr != nil {
 				panic(oggErr)
 			}
@@ -153,30 +139,13 @@ func main() {
 			// Wait for connection established
 			<-iceConnectedCtx.Done()
 
-			// Keep track of last granule, the difference is the amount of samples in the buffer
-			var lastGranule uint64
-			for {
-				pageData, pageHeader, oggErr := ogg.ParseNextPage()
-				if oggErr == io.EOF {
-					fmt.Printf("All audio pages parsed and sent")
-					os.Exit(0)
-				}
-
-				if oggErr != nil {
-					panic(oggErr)
-				}
-
-				// The amount of samples is the difference between the last and current timestamp
-				sampleCount := float64(pageHeader.GranulePosition - lastGranule)
-				lastGranule = pageHeader.GranulePosition
-				sampleDuration := time.Duration((sampleCount/48000)*1000) * time.Millisecond
-
-				if oggErr = audioTrack.WriteSample(media.Sample{Data: pageData, Duration: sampleDuration}); oggErr != nil {
-					panic(oggErr)
-				}
-
-				time.Sleep(sampleDuration)
+			// The duration of every page is the difference between its granule position and the previous one
+			if oggErr = media.Play(context.Background(), audioTrack, ogg); oggErr != nil {
+				panic(oggErr)
 			}
+
+			fmt.Printf("All audio pages parsed and sent")
+			os.Exit(0)
 		}()
 	}
 
@@ -3,13 +3,12 @@
 package main
 
 import (
-	"errors"
 	"fmt"
-	"io"
 	"net"
 
 	"github.com/pion/webrtc/v3"
 	"github.com/pion/webrtc/v3/examples/internal/signal"
+	"github.com/pion/webrtc/v3/pkg/ingest"
 )
 
 func main() {
@@ -29,11 +28,6 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	defer func() {
-		if err = listener.Close(); err != nil {
-			panic(err)
-		}
-	}()
 
 	// Create a video track
 	videoTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", "pion")
@@ -66,6 +60,11 @@ func main() {
 			if closeErr := peerConnection.Close(); closeErr != nil {
 				panic(closeErr)
 			}
+
+			// Stop forwarding RTP packets
+			if closeErr := listener.Close(); closeErr != nil {
+				panic(closeErr)
+			}
 		}
 	})
 
@@ -100,21 +99,11 @@ func main() {
 	// Output the answer in base64 so we can paste it in browser
 	fmt.Println(signal.Encode(*peerConnection.LocalDescription()))
 
-	// Read RTP packets forever and send them to the WebRTC Client
-	inboundRTPPacket := make([]byte, 1600) // UDP MTU
-	for {
-		n, _, err := listener.ReadFrom(inboundRTPPacket)
-		if err != nil {
-			panic(fmt.Sprintf("error during read: %s", err))
-		}
-
-		if _, err = videoTrack.Write(inboundRTPPacket[:n]); err != nil {
-			if errors.Is(err, io.ErrClosedPipe) {
-				// The peerConnection has been closed.
-				return
-			}
-
-			panic(err)
-		}
+	// Forward RTP packets with the payload type of the source to the WebRTC Client.
+	// The track rewrites SSRC and payload type to the negotiated values.
+	bridge := ingest.New(listener)
+	bridge.AddTrack(96, videoTrack)
+	if err = bridge.Run(); err != nil && peerConnection.ConnectionState() != webrtc.PeerConnectionStateClosed {
+		panic(fmt.Sprintf("error during read: %s", err))
 	}
 }
@@ -18,20 +18,8 @@ import (
 )
 
 func saveToDisk(i media.Writer, track *webrtc.TrackRemote) {
-	defer func() {
-		if err := i.Close(); err != nil {
-			panic(err)
-		}
-	}()
-
-	for {
-		rtpPacket, _, err := track.ReadRTP()
-		if err != nil {
-			panic(err)
-		}
-		if err := i.WriteRTP(rtpPacket); err != nil {
-			panic(err)
-		}
+	if err := media.WriteTrack(i, track); err != nil {
+		panic(err)
 	}
 }
 
@@ -0,0 +1,429 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// fecWindowSize is the number of media packets kept to recover lost packets
+	// from, it has to be a power of two
+	fecWindowSize = 256
+
+	// fecMaxPending is the number of FEC packets kept while waiting for the
+	// packets they protect
+	fecMaxPending = 64
+
+	rtpHeaderLength = 12
+
+	// ULPFEC header followed by the level 0 header with the short mask, RFC 5109
+	ulpfecHeaderLength      = 10
+	ulpfecLevelHeaderLength = 4
+	ulpfecLongMaskLength    = 4
+
+	// FlexFEC-03 header with a single SSRC, SN base and the first mask part
+	flexfecHeaderLength = 20
+)
+
+// fecPacket is a parsed ULPFEC or FlexFEC packet. Its recovery fields are XORed
+// with all but one of the protected packets to rebuild the missing one.
+type fecPacket struct {
+	ssrc              uint32
+	sequenceNumbers   []uint16
+	headerRecovery    [2]byte
+	timestampRecovery uint32
+	lengthRecovery    uint16
+	payloadRecovery   []byte
+}
+
+type fecMediaPacket struct {
+	valid          bool
+	sequenceNumber uint16
+	data           []byte
+}
+
+// fecDecoder recovers lost RTP packets of a single SSRC from ULPFEC (RFC 5109)
+// and FlexFEC (draft-ietf-payload-flexible-fec-scheme-03) packets
+type fecDecoder struct {
+	mu sync.Mutex
+
+	media                [fecWindowSize]fecMediaPacket
+	newestSequenceNumber uint16
+	hasNewest            bool
+
+	pending   []*fecPacket
+	recovered [][]byte
+
+	packetsReceived  uint32
+	packetsDiscarded uint32
+	packetsRecovered uint32
+}
+
+// addMedia stores a copy of a received media packet and recovers
+// packets that can be rebuilt with it
+func (d *fecDecoder) addMedia(b []byte) {
+	if len(b) < rtpHeaderLength {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.storeMedia(b)
+	d.recover()
+}
+
+// addULPFEC adds the payload of a ULPFEC packet protecting the stream ssrc
+func (d *fecDecoder) addULPFEC(ssrc uint32, payload []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.packetsReceived++
+	fec, err := parseULPFEC(ssrc, payload)
+	if err != nil {
+		d.packetsDiscarded++
+		return
+	}
+	d.addFEC(fec)
+}
+
+// addFlexFEC adds the payload of a FlexFEC packet, it can only recover
+// packets of the stream ssrc
+func (d *fecDecoder) addFlexFEC(ssrc uint32, payload []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.packetsReceived++
+	fec, err := parseFlexFEC(payload)
+	if err != nil || fec.ssrc != ssrc {
+		d.packetsDiscarded++
+		return
+	}
+	d.addFEC(fec)
+}
+
+// addRedundant recovers the packets before the primary block of a RED packet from
+// its redundant blocks, if they haven't been received. Like browsers send them, the
+// blocks are assumed to be the payloads of the immediately preceding packets.
+func (d *fecDecoder) addRedundant(header *rtp.Header, blocks []redBlock) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, block := range blocks[:len(blocks)-1] {
+		sequenceNumber := header.SequenceNumber - uint16(len(blocks)-1-i)
+		if d.isOutsideWindow(sequenceNumber) || d.getMedia(sequenceNumber) != nil {
+			continue
+		}
+
+		recovered := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    block.payloadType,
+				SequenceNumber: sequenceNumber,
+				Timestamp:      header.Timestamp - block.timestampOffset,
+				SSRC:           header.SSRC,
+				CSRC:           header.CSRC,
+			},
+			Payload: block.data,
+		}
+		b, err := recovered.Marshal()
+		if err != nil {
+			continue
+		}
+
+		d.storeMedia(b)
+		if len(d.recovered) >= fecWindowSize {
+			d.recovered = d.recovered[1:]
+		}
+		d.recovered = append(d.recovered, b)
+		d.packetsRecovered++
+	}
+}
+
+// popRecovered returns the oldest recovered packet that hasn't been read, or nil
+func (d *fecDecoder) popRecovered() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.recovered) == 0 {
+		return nil
+	}
+	b := d.recovered[0]
+	d.recovered = d.recovered[1:]
+	return b
+}
+
+func (d *fecDecoder) stats() (packetsReceived, packetsDiscarded, packetsRecovered uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.packetsReceived, d.packetsDiscarded, d.packetsRecovered
+}
+
+func (d *fecDecoder) storeMedia(b []byte) {
+	sequenceNumber := binary.BigEndian.Uint16(b[2:])
+	if !d.hasNewest || int16(sequenceNumber-d.newestSequenceNumber) > 0 {
+		d.newestSequenceNumber = sequenceNumber
+		d.hasNewest = true
+	}
+
+	slot := &d.media[sequenceNumber%fecWindowSize]
+	slot.valid = true
+	slot.sequenceNumber = sequenceNumber
+	slot.data = append(slot.data[:0], b...)
+}
+
+func (d *fecDecoder) getMedia(sequenceNumber uint16) []byte {
+	if slot := &d.media[sequenceNumber%fecWindowSize]; slot.valid && slot.sequenceNumber == sequenceNumber {
+		return slot.data
+	}
+	return nil
+}
+
+func (d *fecDecoder) addFEC(fec *fecPacket) {
+	if len(d.pending) >= fecMaxPending {
+		d.pending = d.pending[1:]
+		d.packetsDiscarded++
+	}
+	d.pending = append(d.pending, fec)
+	d.recover()
+}
+
+// isOutsideWindow reports whether the media packet has already been replaced by newer ones
+func (d *fecDecoder) isOutsideWindow(sequenceNumber uint16) bool {
+	diff := d.newestSequenceNumber - sequenceNumber
+	return d.hasNewest && diff < 0x8000 && diff >= fecWindowSize
+}
+
+// recover rebuilds media packets from the pending FEC packets until no
+// more packets can be recovered, a recovered packet may allow recovering others
+func (d *fecDecoder) recover() {
+	for progress := true; progress; {
+		progress = false
+
+		pending := d.pending[:0]
+		for _, fec := range d.pending {
+			missing, missingCount, outsideWindow := uint16(0), 0, false
+			for _, sequenceNumber := range fec.sequenceNumbers {
+				if d.isOutsideWindow(sequenceNumber) {
+					outsideWindow = true
+				} else if d.getMedia(sequenceNumber) == nil {
+					missing = sequenceNumber
+					missingCount++
+				}
+			}
+
+			switch {
+			case outsideWindow || missingCount == 0:
+				d.packetsDiscarded++
+			case missingCount == 1:
+				if b := d.recoverPacket(fec, missing); b != nil {
+					d.storeMedia(b)
+					if len(d.recovered) >= fecWindowSize {
+						d.recovered = d.recovered[1:]
+					}
+					d.recovered = append(d.recovered, b)
+					d.packetsRecovered++
+					progress = true
+				} else {
+					d.packetsDiscarded++
+				}
+			default:
+				pending = append(pending, fec)
+			}
+		}
+		d.pending = pending
+	}
+}
+
+func (d *fecDecoder) recoverPacket(fec *fecPacket, missing uint16) []byte {
+	header := fec.headerRecovery
+	timestamp := fec.timestampRecovery
+	length := fec.lengthRecovery
+	payload := append([]byte{}, fec.payloadRecovery...)
+
+	for _, sequenceNumber := range fec.sequenceNumbers {
+		if sequenceNumber == missing {
+			continue
+		}
+
+		b := d.getMedia(sequenceNumber)
+		header[0] ^= b[0]
+		header[1] ^= b[1]
+		timestamp ^= binary.BigEndian.Uint32(b[rtpTimestampOffset:])
+		length ^= uint16(len(b) - rtpHeaderLength)
+		for i, v := range b[rtpHeaderLength:] {
+			if i >= len(payload) {
+				break
+			}
+			payload[i] ^= v
+		}
+	}
+
+	// The FEC packet doesn't protect the whole packet
+	if int(length) > len(payload) {
+		return nil
+	}
+
+	b := make([]byte, rtpHeaderLength+int(length))
+	b[0] = header[0]&0x3F | 0x80
+	b[1] = header[1]
+	binary.BigEndian.PutUint16(b[2:], missing)
+	binary.BigEndian.PutUint32(b[4:], timestamp)
+	binary.BigEndian.PutUint32(b[8:], fec.ssrc)
+	copy(b[rtpHeaderLength:], payload)
+	return b
+}
+
+// parseULPFEC parses a ULPFEC packet with a single protection level, RFC 5109 Section 7
+func parseULPFEC(ssrc uint32, payload []byte) (*fecPacket, error) {
+	headerLength := ulpfecHeaderLength + ulpfecLevelHeaderLength
+	maskLength := 2
+	if len(payload) > 0 && payload[0]&0x40 != 0 {
+		headerLength += ulpfecLongMaskLength
+		maskLength += ulpfecLongMaskLength
+	}
+	if len(payload) < headerLength {
+		return nil, errFECPacketTooShort
+	}
+
+	sequenceNumberBase := binary.BigEndian.Uint16(payload[2:])
+	fec := &fecPacket{
+		ssrc:              ssrc,
+		headerRecovery:    [2]byte{payload[0], payload[1]},
+		timestampRecovery: binary.BigEndian.Uint32(payload[4:]),
+		lengthRecovery:    binary.BigEndian.Uint16(payload[8:]),
+		payloadRecovery:   payload[headerLength:],
+	}
+
+	if protectionLength := int(binary.BigEndian.Uint16(payload[ulpfecHeaderLength:])); protectionLength < len(fec.payloadRecovery) {
+		fec.payloadRecovery = fec.payloadRecovery[:protectionLength]
+	}
+
+	mask := payload[ulpfecHeaderLength+2 : ulpfecHeaderLength+2+maskLength]
+	for i := 0; i < maskLength*8; i++ {
+		if mask[i/8]&(0x80>>(i%8)) != 0 {
+			fec.sequenceNumbers = append(fec.sequenceNumbers, sequenceNumberBase+uint16(i))
+		}
+	}
+	fec.payloadRecovery = append([]byte{}, fec.payloadRecovery...)
+	return fec, nil
+}
+
+// parseFlexFEC parses a FlexFEC packet with a flexible mask for a single SSRC,
+// draft-ietf-payload-flexible-fec-scheme-03 Section 4.2
+func parseFlexFEC(payload []byte) (*fecPacket, error) {
+	if len(payload) < flexfecHeaderLength {
+		return nil, errFECPacketTooShort
+	}
+
+	// Retransmissions and fixed masks (R and F bits) aren't supported, neither
+	// are packets protecting multiple SSRCs
+	if payload[0]&0xC0 != 0 || payload[8] != 1 {
+		return nil, errFECUnsupported
+	}
+
+	sequenceNumberBase := binary.BigEndian.Uint16(payload[16:])
+	fec := &fecPacket{
+		ssrc:              binary.BigEndian.Uint32(payload[12:]),
+		headerRecovery:    [2]byte{payload[0], payload[1]},
+		lengthRecovery:    binary.BigEndian.Uint16(payload[2:]),
+		timestampRecovery: binary.BigEndian.Uint32(payload[4:]),
+	}
+
+	// The mask is split in parts of 15, 31 and 64 bits, the K bit in front
+	// of the first two parts is set if no other part follows
+	addMask := func(mask uint64, bits int, offset uint16) {
+		for i := 0; i < bits; i++ {
+			if mask&(1<<(bits-1-i)) != 0 {
+				fec.sequenceNumbers = append(fec.sequenceNumbers, sequenceNumberBase+offset+uint16(i))
+			}
+		}
+	}
+
+	headerLength := flexfecHeaderLength
+	mask := binary.BigEndian.Uint16(payload[18:])
+	addMask(uint64(mask&0x7FFF), 15, 0)
+	if mask&0x8000 == 0 {
+		if len(payload) < headerLength+4 {
+			return nil, errFECPacketTooShort
+		}
+		mask := binary.BigEndian.Uint32(payload[headerLength:])
+		addMask(uint64(mask&0x7FFFFFFF), 31, 15)
+		headerLength += 4
+
+		if mask&0x80000000 == 0 {
+			if len(payload) < headerLength+8 {
+				return nil, errFECPacketTooShort
+			}
+			addMask(binary.BigEndian.Uint64(payload[headerLength:]), 64, 46)
+			headerLength += 8
+		}
+	}
+
+	fec.payloadRecovery = append([]byte{}, payload[headerLength:]...)
+	return fec, nil
+}
+
+// redBlock is a block of a RED packet, RFC 2198. The timestamp offset of the
+// primary block is 0.
+type redBlock struct {
+	payloadType     uint8
+	timestampOffset uint32
+	data            []byte
+}
+
+// parseRED returns the blocks of a RED packet, the redundant blocks come first
+// and the primary block is the last one
+func parseRED(payload []byte) ([]redBlock, error) {
+	blocks := []redBlock{}
+	lengths := []int{}
+	offset := 0
+	for {
+		if offset >= len(payload) {
+			return nil, errREDPacketTooShort
+		}
+
+		// The last block header only contains the payload type
+		if payload[offset]&0x80 == 0 {
+			blocks = append(blocks, redBlock{payloadType: payload[offset] & rtpPayloadTypeBitmask})
+			offset++
+			break
+		}
+
+		if offset+4 > len(payload) {
+			return nil, errREDPacketTooShort
+		}
+		header := binary.BigEndian.Uint32(payload[offset:])
+		blocks = append(blocks, redBlock{
+			payloadType:     uint8(header>>24) & rtpPayloadTypeBitmask,
+			timestampOffset: (header >> 10) & 0x3FFF,
+		})
+		lengths = append(lengths, int(header&0x3FF))
+		offset += 4
+	}
+
+	for i, length := range lengths {
+		if offset+length > len(payload) {
+			return nil, errREDPacketTooShort
+		}
+		blocks[i].data = payload[offset : offset+length]
+		offset += length
+	}
+	blocks[len(blocks)-1].data = payload[offset:]
+	return blocks, nil
+}
+
+// parseREDPrimary returns the payload type and data of the primary block of
+// a RED packet. Redundant blocks are skipped.
+func parseREDPrimary(payload []byte) (uint8, []byte, error) {
+	blocks, err := parseRED(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	primary := blocks[len(blocks)-1]
+	return primary.payloadType, primary.data, nil
+}
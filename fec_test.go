@@ -0,0 +1,179 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFECTestPacket(t *testing.T, sequenceNumber uint16, marker bool, payload []byte) []byte {
+	b, err := (&rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         marker,
+			PayloadType:    96,
+			SequenceNumber: sequenceNumber,
+			Timestamp:      uint32(sequenceNumber) * 3000,
+			SSRC:           1234,
+		},
+		Payload: payload,
+	}).Marshal()
+	assert.NoError(t, err)
+	return b
+}
+
+// xorFECTestPackets computes the recovery fields shared by ULPFEC and FlexFEC
+func xorFECTestPackets(packets [][]byte) (header [2]byte, timestamp uint32, length uint16, payload []byte) {
+	for _, b := range packets {
+		header[0] ^= b[0]
+		header[1] ^= b[1]
+		timestamp ^= binary.BigEndian.Uint32(b[4:])
+		length ^= uint16(len(b) - rtpHeaderLength)
+		for i, v := range b[rtpHeaderLength:] {
+			if i >= len(payload) {
+				payload = append(payload, 0)
+			}
+			payload[i] ^= v
+		}
+	}
+	return
+}
+
+func marshalULPFECTestPacket(sequenceNumberBase, mask uint16, packets [][]byte) []byte {
+	header, timestamp, length, payload := xorFECTestPackets(packets)
+
+	b := make([]byte, ulpfecHeaderLength+ulpfecLevelHeaderLength, ulpfecHeaderLength+ulpfecLevelHeaderLength+len(payload))
+	b[0] = header[0] & 0x3F
+	b[1] = header[1]
+	binary.BigEndian.PutUint16(b[2:], sequenceNumberBase)
+	binary.BigEndian.PutUint32(b[4:], timestamp)
+	binary.BigEndian.PutUint16(b[8:], length)
+	binary.BigEndian.PutUint16(b[10:], uint16(len(payload)))
+	binary.BigEndian.PutUint16(b[12:], mask)
+	return append(b, payload...)
+}
+
+func marshalFlexFECTestPacket(ssrc uint32, sequenceNumberBase, mask uint16, packets [][]byte) []byte {
+	header, timestamp, length, payload := xorFECTestPackets(packets)
+
+	b := make([]byte, flexfecHeaderLength, flexfecHeaderLength+len(payload))
+	b[0] = header[0] & 0x3F
+	b[1] = header[1]
+	binary.BigEndian.PutUint16(b[2:], length)
+	binary.BigEndian.PutUint32(b[4:], timestamp)
+	b[8] = 1
+	binary.BigEndian.PutUint32(b[12:], ssrc)
+	binary.BigEndian.PutUint16(b[16:], sequenceNumberBase)
+	binary.BigEndian.PutUint16(b[18:], 0x8000|mask)
+	return append(b, payload...)
+}
+
+func TestFECDecoder_ULPFEC(t *testing.T) {
+	packets := [][]byte{
+		newFECTestPacket(t, 65534, false, []byte{0x01, 0x02, 0x03}),
+		newFECTestPacket(t, 65535, false, []byte{0x04}),
+		newFECTestPacket(t, 0, true, []byte{0x05, 0x06, 0x07, 0x08, 0x09}),
+		newFECTestPacket(t, 1, false, []byte{0x0A, 0x0B}),
+	}
+
+	d := &fecDecoder{}
+	d.addMedia(packets[0])
+	d.addMedia(packets[1])
+	d.addMedia(packets[3])
+	assert.Nil(t, d.popRecovered())
+
+	// Protects all four packets across the sequence number wrap around
+	d.addULPFEC(1234, marshalULPFECTestPacket(65534, 0xF000, packets))
+	assert.Equal(t, packets[2], d.popRecovered())
+	assert.Nil(t, d.popRecovered())
+
+	// Nothing is missing anymore
+	d.addULPFEC(1234, marshalULPFECTestPacket(65534, 0xF000, packets))
+	assert.Nil(t, d.popRecovered())
+
+	d.addULPFEC(1234, []byte{0x00})
+
+	received, discarded, recovered := d.stats()
+	assert.Equal(t, uint32(3), received)
+	assert.Equal(t, uint32(2), discarded)
+	assert.Equal(t, uint32(1), recovered)
+}
+
+func TestFECDecoder_ULPFECBeforeMedia(t *testing.T) {
+	packets := [][]byte{
+		newFECTestPacket(t, 10, false, []byte{0x01}),
+		newFECTestPacket(t, 11, false, []byte{0x02, 0x03}),
+		newFECTestPacket(t, 12, false, []byte{0x04, 0x05, 0x06}),
+	}
+
+	// The FEC packet waits until only a single packet is missing
+	d := &fecDecoder{}
+	d.addULPFEC(1234, marshalULPFECTestPacket(10, 0xE000, packets))
+	d.addMedia(packets[0])
+	assert.Nil(t, d.popRecovered())
+
+	d.addMedia(packets[2])
+	assert.Equal(t, packets[1], d.popRecovered())
+}
+
+func TestFECDecoder_FlexFEC(t *testing.T) {
+	packets := [][]byte{
+		newFECTestPacket(t, 100, false, []byte{0x01, 0x02}),
+		newFECTestPacket(t, 102, true, []byte{0x03, 0x04, 0x05}),
+	}
+
+	d := &fecDecoder{}
+	d.addMedia(packets[1])
+
+	// Packets of other SSRCs can't be recovered
+	d.addFlexFEC(1234, marshalFlexFECTestPacket(5678, 100, 0x5000, packets))
+	assert.Nil(t, d.popRecovered())
+
+	// Protects the sequence numbers 100 and 102
+	d.addFlexFEC(1234, marshalFlexFECTestPacket(1234, 100, 0x5000, packets))
+	assert.Equal(t, packets[0], d.popRecovered())
+
+	received, discarded, recovered := d.stats()
+	assert.Equal(t, uint32(2), received)
+	assert.Equal(t, uint32(1), discarded)
+	assert.Equal(t, uint32(1), recovered)
+}
+
+func TestParseFlexFEC_LongMask(t *testing.T) {
+	b := make([]byte, flexfecHeaderLength+12)
+	b[8] = 1
+	binary.BigEndian.PutUint16(b[16:], 1000)
+	binary.BigEndian.PutUint16(b[18:], 0x4000)
+	binary.BigEndian.PutUint32(b[20:], 0x00000001)
+	binary.BigEndian.PutUint64(b[24:], 0x8000000000000001)
+
+	fec, err := parseFlexFEC(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{1000, 1045, 1046, 1109}, fec.sequenceNumbers)
+
+	_, err = parseFlexFEC(b[:flexfecHeaderLength+4])
+	assert.ErrorIs(t, err, errFECPacketTooShort)
+
+	// Retransmissions aren't supported
+	b[0] = 0x80
+	_, err = parseFlexFEC(b)
+	assert.ErrorIs(t, err, errFECUnsupported)
+}
+
+func TestParseREDPrimary(t *testing.T) {
+	// A redundant block of two bytes with payload type 96, followed by the primary block with payload type 116
+	payloadType, data, err := parseREDPrimary([]byte{0xE0, 0x00, 0x00, 0x02, 0x74, 0xAA, 0xBB, 0xCC, 0xDD})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(116), payloadType)
+	assert.Equal(t, []byte{0xCC, 0xDD}, data)
+
+	_, _, err = parseREDPrimary([]byte{0xE0, 0x00, 0x00, 0x08, 0x74, 0xAA})
+	assert.ErrorIs(t, err, errREDPacketTooShort)
+
+	_, _, err = parseREDPrimary([]byte{})
+	assert.ErrorIs(t, err, errREDPacketTooShort)
+}
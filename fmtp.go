@@ -21,8 +21,20 @@ func parseFmtp(line string) fmtp {
 	return f
 }
 
-// fmtpConsist checks that two FMTP parameters are not inconsistent.
-func fmtpConsist(a, b fmtp) bool {
+// fmtpConsist checks that two FMTP parameters of a codec are not inconsistent.
+// Only the parameters that identify a codec are compared for H264, VP9 and Opus,
+// others like the H264 level or the Opus minptime just describe preferences.
+func fmtpConsist(mimeType string, a, b fmtp) bool {
+	switch {
+	case strings.EqualFold(mimeType, "video/H264"):
+		return h264FmtpConsist(a, b)
+	case strings.EqualFold(mimeType, "video/VP9"):
+		return strings.EqualFold(a.get("profile-id", "0"), b.get("profile-id", "0"))
+	case strings.EqualFold(mimeType, "audio/opus"):
+		// All Opus parameters are declarative, RFC 7587 Section 7
+		return true
+	}
+
 	for k, v := range a {
 		if vb, ok := b[k]; ok && !strings.EqualFold(vb, v) {
 			return false
@@ -35,3 +47,27 @@ func fmtpConsist(a, b fmtp) bool {
 	}
 	return true
 }
+
+// get returns the value of a parameter, or def if it isn't set
+func (f fmtp) get(key, def string) string {
+	if v, ok := f[key]; ok {
+		return v
+	}
+	return def
+}
+
+// h264FmtpConsist checks that the packetization mode and the profile, the first two
+// bytes of profile-level-id, are the same. The level is negotiated with
+// level-asymmetry-allowed and is no reason to reject a codec, RFC 6184 Section 8.1
+func h264FmtpConsist(a, b fmtp) bool {
+	if a.get("packetization-mode", "0") != b.get("packetization-mode", "0") {
+		return false
+	}
+
+	// The default profile-level-id is Baseline at level 1.0
+	profileA, profileB := a.get("profile-level-id", "42000a"), b.get("profile-level-id", "42000a")
+	if len(profileA) != 6 || len(profileB) != 6 {
+		return strings.EqualFold(profileA, profileB)
+	}
+	return strings.EqualFold(profileA[:4], profileB[:4])
+}
@@ -89,7 +89,7 @@ func TestFmtpConsist(t *testing.T) {
 	for name, testCase := range testCases {
 		testCase := testCase
 		check := func(t *testing.T, a, b string) {
-			c := fmtpConsist(parseFmtp(a), parseFmtp(b))
+			c := fmtpConsist("", parseFmtp(a), parseFmtp(b))
 			if c != testCase.consist {
 				t.Errorf(
 					"'%s' and '%s' are expected to be %s, but treated as %s",
@@ -105,3 +105,64 @@ func TestFmtpConsist(t *testing.T) {
 		})
 	}
 }
+
+func TestFmtpConsistCodec(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		mimeType string
+		a, b     string
+		consist  bool
+	}{
+		"H264LevelDiffers": {
+			mimeType: "video/H264",
+			a:        "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+			b:        "packetization-mode=1;profile-level-id=42E034",
+			consist:  true,
+		},
+		"H264ProfileDiffers": {
+			mimeType: "video/H264",
+			a:        "packetization-mode=1;profile-level-id=42e01f",
+			b:        "packetization-mode=1;profile-level-id=640c1f",
+			consist:  false,
+		},
+		"H264PacketizationModeDiffers": {
+			mimeType: "video/h264",
+			a:        "packetization-mode=1;profile-level-id=42e01f",
+			b:        "profile-level-id=42e01f",
+			consist:  false,
+		},
+		"H264Defaults": {
+			mimeType: "video/H264",
+			a:        "packetization-mode=0;profile-level-id=42001f",
+			b:        "",
+			consist:  true,
+		},
+		"VP9ProfileDiffers": {
+			mimeType: "video/VP9",
+			a:        "profile-id=0",
+			b:        "profile-id=2",
+			consist:  false,
+		},
+		"VP9DefaultProfile": {
+			mimeType: "video/VP9",
+			a:        "profile-id=0",
+			b:        "",
+			consist:  true,
+		},
+		"OpusPreferencesDiffer": {
+			mimeType: "audio/opus",
+			a:        "minptime=10;useinbandfec=1",
+			b:        "minptime=20;useinbandfec=0;stereo=1",
+			consist:  true,
+		},
+	} {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if c := fmtpConsist(testCase.mimeType, parseFmtp(testCase.a), parseFmtp(testCase.b)); c != testCase.consist {
+				t.Errorf("'%s' and '%s' of %s: expected %v, got %v", testCase.a, testCase.b, testCase.mimeType, testCase.consist, c)
+			}
+			if c := fmtpConsist(testCase.mimeType, parseFmtp(testCase.b), parseFmtp(testCase.a)); c != testCase.consist {
+				t.Errorf("'%s' and '%s' of %s: expected %v, got %v", testCase.b, testCase.a, testCase.mimeType, testCase.consist, c)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package webrtc
+
+import "fmt"
+
+// ICECandidateErrorCodeUnreachable is the ErrorCode of an ICECandidateError when
+// the server couldn't be reached
+const ICECandidateErrorCodeUnreachable = 701
+
+// ICECandidateError is the equivalent of the RTCPeerConnectionIceErrorEvent. It
+// describes a STUN or TURN server that failed to provide a candidate, e.g. because
+// the TURN credentials were rejected.
+type ICECandidateError struct {
+	// Address and Port are the local address and port used to contact the server
+	Address string
+	Port    uint16
+
+	// URL is the URL of the server, e.g. turn:turn.example.org:3478
+	URL string
+
+	// ErrorCode is the STUN error code returned by the server, e.g. 401 for wrong
+	// credentials, or ICECandidateErrorCodeUnreachable
+	ErrorCode int
+
+	// ErrorText is the reason phrase returned by the server, or a description of the error
+	ErrorText string
+}
+
+func (e ICECandidateError) Error() string {
+	return fmt.Sprintf("ICE server %s: %d %s", e.URL, e.ErrorCode, e.ErrorText)
+}
@@ -3,11 +3,15 @@
 package webrtc
 
 import (
+	"context"
+	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/pion/ice/v2"
 	"github.com/pion/logging"
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
 )
 
 // ICEGatherer gathers local host, server reflexive and relay
@@ -20,16 +24,40 @@ type ICEGatherer struct {
 	state ICEGathererState
 
 	validatedServers []*ice.URL
+	oauthServers     map[*ice.URL]struct{}
 	gatherPolicy     ICETransportPolicy
 
 	agent *ice.Agent
 
-	onLocalCandidateHandler atomic.Value // func(candidate *ICECandidate)
-	onStateChangeHandler    atomic.Value // func(state ICEGathererState)
+	// The servers passed to the agent, copies of the validated servers owned by the
+	// agent, and the context of their diagnosis when they don't provide candidates
+	agentServers    []*ice.URL
+	agentURLCount   int // number of servers before the probe, the agent was created with
+	leftOutServers  []ICEGatheringError
+	serversChanged  bool
+	diagnosisCtx    context.Context
+	cancelDiagnosis context.CancelFunc
+
+	// Server reflexive candidates for ports mapped by the SettingEngine's PortMapper
+	mappedCandidates     []ICECandidate
+	mappingClosed        bool
+	mappedCandidatesLock sync.Mutex
+
+	onLocalCandidateHandler    atomic.Value // func(candidate *ICECandidate)
+	onStateChangeHandler       atomic.Value // func(state ICEGathererState)
+	onGatheringProgressHandler atomic.Value // func(progress ICEGatheringProgress)
+	onGatheringErrorHandler    atomic.Value // func(err ICEGatheringError)
+	onCandidateErrorHandler    atomic.Value // func(err ICECandidateError)
 
 	// Used for GatheringCompletePromise
 	onGatheringCompleteHandler atomic.Value // func()
 
+	// Used by the ICETransport to enter the completed state
+	onGatheringCompleteTransportHandler atomic.Value // func()
+
+	// attached is set once a PeerConnection uses the gatherer
+	attached bool
+
 	api *API
 }
 
@@ -37,27 +65,222 @@ type ICEGatherer struct {
 // This constructor is part of the ORTC API. It is not
 // meant to be used together with the basic WebRTC API.
 func (api *API) NewICEGatherer(opts ICEGatherOptions) (*ICEGatherer, error) {
-	var validatedServers []*ice.URL
-	if len(opts.ICEServers) > 0 {
-		for _, server := range opts.ICEServers {
-			url, err := server.urls()
-			if err != nil {
-				return nil, err
-			}
-			validatedServers = append(validatedServers, url...)
-		}
+	validatedServers, oauthServers, err := validateICEServers(opts.ICEServers)
+	if err != nil {
+		return nil, err
 	}
 
 	return &ICEGatherer{
 		state:            ICEGathererStateNew,
 		gatherPolicy:     opts.ICEGatherPolicy,
 		validatedServers: validatedServers,
+		oauthServers:     oauthServers,
 		api:              api,
 		log:              api.settingEngine.LoggerFactory.NewLogger("ice"),
 	}, nil
 }
 
+// validateICEServers returns the URLs of the servers, and the URLs of the TURN servers
+// with OAuth credentials
+func validateICEServers(servers []ICEServer) ([]*ice.URL, map[*ice.URL]struct{}, error) {
+	var validatedServers []*ice.URL
+	oauthServers := map[*ice.URL]struct{}{}
+	for _, server := range servers {
+		urls, err := server.urls()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, url := range urls {
+			if server.CredentialType == ICECredentialTypeOauth && (url.Scheme == ice.SchemeTypeTURN || url.Scheme == ice.SchemeTypeTURNS) {
+				oauthServers[url] = struct{}{}
+			}
+		}
+		validatedServers = append(validatedServers, urls...)
+	}
+	return validatedServers, oauthServers, nil
+}
+
+// agentURLs returns copies of the servers for the agent, the agent reads them while it
+// gathers and they are only written between gatherings. With a NAT64 prefix STUN servers
+// with an IPv4 address are also contacted at their synthesized address. TURN servers are
+// only contacted over IPv4 by the agent, so they aren't synthesized. pion/turn doesn't
+// support OAuth, TURN servers with OAuth credentials are left out unless the
+// SettingEngine's TURNCredentialFunc supplies long-term credentials for them. Note: the
+// caller should hold the gatherer lock.
+func (g *ICEGatherer) agentURLs(validatedServers []*ice.URL, oauthServers map[*ice.URL]struct{}) ([]*ice.URL, []ICEGatheringError) {
+	var gatheringErrs []ICEGatheringError
+	urls := []*ice.URL{}
+	for _, url := range validatedServers {
+		if _, ok := oauthServers[url]; ok && g.api.settingEngine.candidates.TURNCredentialFunc == nil {
+			gatheringErrs = append(gatheringErrs, ICEGatheringError{URL: url.String(), Err: ErrTurnOAuthNotSupported})
+			continue
+		}
+
+		agentURL := *url
+		urls = append(urls, &agentURL)
+	}
+
+	prefix := g.api.settingEngine.candidates.NAT64Prefix
+	if prefix == nil {
+		return urls, gatheringErrs
+	}
+
+	for _, url := range validatedServers {
+		if url.Scheme != ice.SchemeTypeSTUN {
+			continue
+		}
+
+		if ip := net.ParseIP(url.Host); ip != nil && ip.To4() != nil {
+			synthesized := *url
+			// The agent joins host and port without brackets
+			synthesized.Host = "[" + synthesizeNAT64Address(prefix, ip).String() + "]"
+			urls = append(urls, &synthesized)
+		}
+	}
+	return urls, gatheringErrs
+}
+
+// probedAgentURLs returns the servers for the agent without the ones that failed the probe
+// of the SettingEngine's ServerProbeTimeout, and all servers for the agent before the
+// probe. Note: the caller should hold the gatherer lock.
+func (g *ICEGatherer) probedAgentURLs() (urls, allURLs []*ice.URL, gatheringErrs []ICEGatheringError) {
+	allURLs, gatheringErrs = g.agentURLs(g.validatedServers, g.oauthServers)
+	urls = allURLs
+	if timeout := g.api.settingEngine.candidates.ServerProbeTimeout; timeout > 0 && len(urls) != 0 {
+		var probeErrs []ICEGatheringError
+		urls, probeErrs = probeICEServers(g.api.settingEngine.vnet, urls, timeout)
+		gatheringErrs = append(gatheringErrs, probeErrs...)
+	}
+	for _, err := range gatheringErrs {
+		g.log.Warnf("Leaving out %s", err)
+	}
+	return urls, allURLs, gatheringErrs
+}
+
+// updateAgentServers passes the servers of a configuration set after the agent was
+// created to the agent. pion/ice keeps the slice of servers the agent was created with,
+// so they are replaced in place while the agent doesn't gather, e.g. after an ICE
+// restart. setConfiguration makes sure the number of servers doesn't change.
+func (g *ICEGatherer) updateAgentServers() {
+	// The errors of the probes are reported once the lock is released
+	var gatheringErrs []ICEGatheringError
+	defer func() {
+		g.onGatheringErrors(gatheringErrs)
+	}()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.serversChanged || g.agent == nil || g.State() == ICEGathererStateGathering {
+		return
+	}
+	g.serversChanged = false
+
+	var urls, allURLs []*ice.URL
+	urls, allURLs, gatheringErrs = g.probedAgentURLs()
+
+	// The probe can leave out a different number of servers than when the agent was
+	// created. The servers that failed it are tried anyway if there is room, those
+	// that passed it but don't fit are left out.
+	for _, url := range allURLs {
+		if len(urls) >= len(g.agentServers) {
+			break
+		}
+		if !containsURL(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) > len(g.agentServers) {
+		for _, url := range urls[len(g.agentServers):] {
+			err := ICEGatheringError{URL: url.String(), Err: errICEGathererNoRoomForServer}
+			g.log.Warnf("Leaving out %s", err)
+			gatheringErrs = append(gatheringErrs, err)
+		}
+		urls = urls[:len(g.agentServers)]
+	}
+
+	for i, url := range urls {
+		*g.agentServers[i] = *url
+	}
+	g.leftOutServers = gatheringErrs
+}
+
+// setConfiguration replaces the ICE servers and the gather policy. They are passed to the
+// agent when it is created, false is returned if the agent already exists. The agent then
+// gets the servers when it gathers again on an ICE restart. The agent can't take another
+// number of servers or another policy, an InvalidModificationError is returned for them.
+func (g *ICEGatherer) setConfiguration(servers []ICEServer, policy ICETransportPolicy) (bool, error) {
+	validatedServers, oauthServers, err := validateICEServers(servers)
+	if err != nil {
+		return false, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.startedLocked() {
+		g.validatedServers = validatedServers
+		g.oauthServers = oauthServers
+		g.gatherPolicy = policy
+		return true, nil
+	}
+
+	if policy != g.gatherPolicy {
+		return false, &rtcerr.InvalidModificationError{Err: ErrModifyingICETransportPolicy}
+	}
+	if urls, _ := g.agentURLs(validatedServers, oauthServers); len(urls) != g.agentURLCount {
+		return false, &rtcerr.InvalidModificationError{Err: ErrModifyingICEServerCount}
+	}
+
+	g.validatedServers = validatedServers
+	g.oauthServers = oauthServers
+	g.serversChanged = true
+	return false, nil
+}
+
+// started returns true once the agent has been created or the gatherer has been closed.
+// The configuration can't be replaced freely anymore.
+func (g *ICEGatherer) started() bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.startedLocked()
+}
+
+func (g *ICEGatherer) startedLocked() bool {
+	return g.agent != nil || g.State() != ICEGathererStateNew
+}
+
+func containsURL(urls []*ice.URL, url *ice.URL) bool {
+	for _, u := range urls {
+		if u == url {
+			return true
+		}
+	}
+	return false
+}
+
+// attach reserves the gatherer for a PeerConnection, a gatherer can only be used by one
+func (g *ICEGatherer) attach() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	switch {
+	case g.attached:
+		return &rtcerr.InvalidAccessError{Err: ErrICEGathererInUse}
+	case g.State() == ICEGathererStateClosed:
+		return &rtcerr.InvalidStateError{Err: ErrICEGathererClosed}
+	}
+	g.attached = true
+	return nil
+}
+
 func (g *ICEGatherer) createAgent() error {
+	// The errors of the probes are reported once the lock is released
+	var gatheringErrs []ICEGatheringError
+	defer func() {
+		g.onGatheringErrors(gatheringErrs)
+	}()
+
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
@@ -65,6 +288,14 @@ func (g *ICEGatherer) createAgent() error {
 		return nil
 	}
 
+	if buffers := g.api.settingEngine.udpSocketBuffers; buffers.ReceiveBufferSize > 0 || buffers.SendBufferSize > 0 {
+		if mux, ok := g.api.settingEngine.iceUDPMux.(udpMuxBufferSizer); !ok {
+			g.log.Warn("Buffer sizes of the ICE UDP sockets are ignored, they are only applied to a UDPMux created by NewICEUDPMuxSharded")
+		} else if err := mux.setBufferSizes(buffers.ReceiveBufferSize, buffers.SendBufferSize); err != nil {
+			g.log.Warnf("Failed to set the buffer sizes of the ICE UDP sockets: %v", err)
+		}
+	}
+
 	candidateTypes := []ice.CandidateType{}
 	if g.api.settingEngine.candidates.ICELite {
 		candidateTypes = append(candidateTypes, ice.CandidateTypeHost)
@@ -88,14 +319,18 @@ func (g *ICEGatherer) createAgent() error {
 		mDNSMode = ice.MulticastDNSModeQueryOnly
 	}
 
+	var urls, allURLs []*ice.URL
+	urls, allURLs, gatheringErrs = g.probedAgentURLs()
+
 	config := &ice.AgentConfig{
 		Lite:                   g.api.settingEngine.candidates.ICELite,
-		Urls:                   g.validatedServers,
+		Urls:                   urls,
 		PortMin:                g.api.settingEngine.ephemeralUDP.PortMin,
 		PortMax:                g.api.settingEngine.ephemeralUDP.PortMax,
 		DisconnectedTimeout:    g.api.settingEngine.timeout.ICEDisconnectedTimeout,
 		FailedTimeout:          g.api.settingEngine.timeout.ICEFailedTimeout,
 		KeepaliveInterval:      g.api.settingEngine.timeout.ICEKeepaliveInterval,
+		CheckInterval:          g.api.settingEngine.timeout.ICECheckInterval,
 		LoggerFactory:          g.api.settingEngine.LoggerFactory,
 		CandidateTypes:         candidateTypes,
 		HostAcceptanceMinWait:  g.api.settingEngine.timeout.ICEHostAcceptanceMinWait,
@@ -130,6 +365,10 @@ func (g *ICEGatherer) createAgent() error {
 	}
 
 	g.agent = agent
+	g.agentServers = urls
+	g.agentURLCount = len(allURLs)
+	g.leftOutServers = gatheringErrs
+	g.diagnosisCtx, g.cancelDiagnosis = context.WithCancel(context.Background())
 	return nil
 }
 
@@ -138,12 +377,70 @@ func (g *ICEGatherer) Gather() error {
 	if err := g.createAgent(); err != nil {
 		return err
 	}
+	g.updateAgentServers()
+
+	// The TURNCredentialFunc may block, it is called without the lock
+	credentials := g.turnCredentials()
 
 	g.lock.Lock()
 	agent := g.agent
+	g.setTURNCredentials(credentials)
 	g.lock.Unlock()
 
 	g.setState(ICEGathererStateGathering)
+
+	progress := g.expectedGatheringProgress()
+	for _, p := range progress {
+		g.onGatheringProgress(p.ICEGatheringProgress)
+	}
+
+	// Candidates of mapped ports are signaled once the PortMapper answers, the
+	// end of gathering waits for them
+	var candidateLock sync.Mutex
+	var mappings sync.WaitGroup
+
+	srflx, relay := 0, 0
+	onCandidate := func(c ICECandidate, mapped bool) {
+		candidateLock.Lock()
+		defer candidateLock.Unlock()
+
+		switch c.Typ {
+		case ICECandidateTypeSrflx:
+			srflx++
+		case ICECandidateTypeRelay:
+			relay++
+		default:
+		}
+
+		// Mapped candidates come from the PortMapper, not from a server
+		var p *gatheringProgress
+		if !mapped {
+			p = findGatheringProgress(progress, c)
+		}
+		if p == nil {
+			p = findUnmatchedGatheringProgress(progress, c.Typ)
+		}
+		if p == nil {
+			p = &gatheringProgress{ICEGatheringProgress: ICEGatheringProgress{CandidateType: c.Typ}}
+			progress = append(progress, p)
+		}
+
+		g.applyCandidatePriority(&c)
+		if !g.rewriteCandidate(&c) {
+			return
+		}
+		if handler, ok := g.onLocalCandidateHandler.Load().(func(candidate *ICECandidate)); ok && handler != nil {
+			handler(&c)
+		}
+
+		p.Candidates++
+		// The agent allocates one relay candidate per TURN server
+		if p.URL != "" && c.Typ == ICECandidateTypeRelay {
+			p.Complete = true
+		}
+		g.onGatheringProgress(p.ICEGatheringProgress)
+	}
+
 	if err := agent.OnCandidate(func(candidate ice.Candidate) {
 		onLocalCandidateHandler := func(*ICECandidate) {}
 		if handler, ok := g.onLocalCandidateHandler.Load().(func(candidate *ICECandidate)); ok && handler != nil {
@@ -161,12 +458,39 @@ func (g *ICEGatherer) Gather() error {
 				g.log.Warnf("Failed to convert ice.Candidate: %s", err)
 				return
 			}
-			onLocalCandidateHandler(&c)
+			onCandidate(c, false)
+
+			if g.shouldMapCandidatePort(c) {
+				mappings.Add(1)
+				go func() {
+					defer mappings.Done()
+					if mapped := g.mapCandidatePort(c); mapped != nil {
+						onCandidate(*mapped, true)
+					}
+				}()
+			}
 		} else {
+			// No candidates are signaled once the mappings are done
+			mappings.Wait()
+
+			candidateLock.Lock()
+			for _, p := range progress {
+				if !p.Complete {
+					p.Complete = true
+					g.onGatheringProgress(p.ICEGatheringProgress)
+				}
+			}
+			candidateLock.Unlock()
+
 			g.setState(ICEGathererStateComplete)
 
+			if handler, ok := g.onGatheringCompleteTransportHandler.Load().(func()); ok && handler != nil {
+				handler()
+			}
 			onGatheringCompleteHandler()
 			onLocalCandidateHandler(nil)
+
+			g.diagnoseICEServers(srflx, relay)
 		}
 	}); err != nil {
 		return err
@@ -177,16 +501,31 @@ func (g *ICEGatherer) Gather() error {
 // Close prunes all local candidates, and closes the ports.
 func (g *ICEGatherer) Close() error {
 	g.lock.Lock()
-	defer g.lock.Unlock()
 
 	if g.agent == nil {
+		g.lock.Unlock()
 		return nil
-	} else if err := g.agent.Close(); err != nil {
+	}
+	g.cancelDiagnosis()
+	if err := g.agent.Close(); err != nil {
+		g.lock.Unlock()
 		return err
 	}
 
+	g.mappedCandidatesLock.Lock()
+	mappedCandidates := g.mappedCandidates
+	g.mappedCandidates = nil
+	g.mappingClosed = true
+	g.mappedCandidatesLock.Unlock()
+
 	g.agent = nil
 	g.setState(ICEGathererStateClosed)
+	g.lock.Unlock()
+
+	// Removing a mapping talks to the gateway, which mustn't block the gatherer
+	for _, c := range mappedCandidates {
+		g.unmapCandidatePort(int(c.RelatedPort))
+	}
 
 	return nil
 }
@@ -219,7 +558,171 @@ func (g *ICEGatherer) GetLocalCandidates() ([]ICECandidate, error) {
 		return nil, err
 	}
 
-	return newICECandidatesFromICE(iceCandidates)
+	candidates, err := newICECandidatesFromICE(iceCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mappedCandidatesLock.Lock()
+	candidates = append(candidates, g.mappedCandidates...)
+	g.mappedCandidatesLock.Unlock()
+
+	signaled := candidates[:0]
+	for i := range candidates {
+		g.applyCandidatePriority(&candidates[i])
+		if g.rewriteCandidate(&candidates[i]) {
+			signaled = append(signaled, candidates[i])
+		}
+	}
+
+	return signaled, nil
+}
+
+// turnCredential is the username and password of a TURN server of the agent
+type turnCredential struct {
+	url                string
+	username, password string
+}
+
+// turnCredentials asks the SettingEngine's TURNCredentialFunc for the credentials of the
+// TURN servers of the agent, they are indexed like the servers of the agent
+func (g *ICEGatherer) turnCredentials() []*turnCredential {
+	credentialFunc := g.api.settingEngine.candidates.TURNCredentialFunc
+	if credentialFunc == nil {
+		return nil
+	}
+
+	g.lock.RLock()
+	credentials := make([]*turnCredential, len(g.agentServers))
+	for i, url := range g.agentServers {
+		if url.Scheme == ice.SchemeTypeTURN || url.Scheme == ice.SchemeTypeTURNS {
+			credentials[i] = &turnCredential{url: url.String()}
+		}
+	}
+	g.lock.RUnlock()
+
+	for _, c := range credentials {
+		if c == nil {
+			continue
+		}
+
+		var err error
+		if c.username, c.password, err = credentialFunc(c.url); err != nil {
+			g.log.Warnf("Failed to get credentials for %s, using the previous ones: %s", c.url, err)
+			c.username, c.password = "", ""
+		}
+	}
+	return credentials
+}
+
+// setTURNCredentials writes the credentials to the servers of the agent. The agent reads
+// them when it gathers relay candidates, so they are only written before the agent
+// gathers. Note: the caller should hold the gatherer lock.
+func (g *ICEGatherer) setTURNCredentials(credentials []*turnCredential) {
+	if len(credentials) != len(g.agentServers) || g.State() == ICEGathererStateGathering {
+		return
+	}
+
+	for i, c := range credentials {
+		if c == nil || c.username == "" || c.url != g.agentServers[i].String() {
+			continue
+		}
+		g.agentServers[i].Username = c.username
+		g.agentServers[i].Password = c.password
+	}
+}
+
+// shouldMapCandidatePort returns if the SettingEngine's PortMapper maps the port of the
+// candidate, it maps IPv4 UDP host candidates
+func (g *ICEGatherer) shouldMapCandidatePort(c ICECandidate) bool {
+	if g.api.settingEngine.candidates.PortMapper == nil || g.api.settingEngine.candidates.ICELite || c.Typ != ICECandidateTypeHost || c.Protocol != ICEProtocolUDP {
+		return false
+	}
+
+	ip := net.ParseIP(c.Address)
+	return ip != nil && ip.To4() != nil
+}
+
+// mapCandidatePort asks the SettingEngine's PortMapper to map the port of an IPv4 UDP
+// host candidate and returns a server reflexive candidate for the mapped address. The
+// PortMapper may wait for the gateway, so it is called in its own goroutine.
+func (g *ICEGatherer) mapCandidatePort(c ICECandidate) *ICECandidate {
+	portMapper := g.api.settingEngine.candidates.PortMapper
+	externalIP, externalPort, err := portMapper.MapPort(int(c.Port))
+	if err != nil {
+		g.log.Warnf("Failed to map port %d: %s", c.Port, err)
+		return nil
+	}
+
+	iceCandidate, err := ice.NewCandidateServerReflexive(&ice.CandidateServerReflexiveConfig{
+		Network:   c.Protocol.String(),
+		Address:   externalIP.String(),
+		Port:      externalPort,
+		Component: c.Component,
+		RelAddr:   c.Address,
+		RelPort:   int(c.Port),
+	})
+	if err != nil {
+		g.log.Warnf("Failed to create candidate for mapped port %d: %s", c.Port, err)
+		return nil
+	}
+
+	mapped, err := newICECandidateFromICE(iceCandidate)
+	if err != nil {
+		g.log.Warnf("Failed to convert ice.Candidate: %s", err)
+		return nil
+	}
+
+	// The gatherer may have been closed while the port was mapped
+	g.mappedCandidatesLock.Lock()
+	closed := g.mappingClosed
+	if !closed {
+		g.mappedCandidates = append(g.mappedCandidates, mapped)
+	}
+	g.mappedCandidatesLock.Unlock()
+
+	if closed {
+		g.unmapCandidatePort(int(c.Port))
+		return nil
+	}
+	return &mapped
+}
+
+// unmapCandidatePort releases the mapping of a host candidate port. It must not be
+// called with a lock held, the PortMapper may wait for the gateway.
+func (g *ICEGatherer) unmapCandidatePort(internalPort int) {
+	if err := g.api.settingEngine.candidates.PortMapper.UnmapPort(internalPort); err != nil {
+		g.log.Warnf("Failed to remove port mapping for %d: %s", internalPort, err)
+	}
+}
+
+// applyCandidatePriority overrides the signaled priority of a local candidate if the
+// SettingEngine asks to, the agent keeps its own
+func (g *ICEGatherer) applyCandidatePriority(c *ICECandidate) {
+	if g.api.settingEngine.candidates.PriorityFunc == nil {
+		return
+	}
+
+	if priority := g.api.settingEngine.candidates.PriorityFunc(*c); priority != 0 {
+		c.Priority = priority
+	}
+}
+
+// rewriteCandidate passes a local candidate to the SettingEngine's RewriteFunc, it returns
+// false if the candidate isn't signaled
+func (g *ICEGatherer) rewriteCandidate(c *ICECandidate) bool {
+	rewrite := g.api.settingEngine.candidates.RewriteFunc
+	if rewrite == nil {
+		return true
+	}
+
+	rewritten, ok := rewrite(*c)
+	if !ok {
+		return false
+	}
+	rewritten.statsID = c.statsID
+	*c = rewritten
+	return true
 }
 
 // OnLocalCandidate sets an event handler which fires when a new local ICE candidate is available
@@ -228,6 +731,217 @@ func (g *ICEGatherer) OnLocalCandidate(f func(*ICECandidate)) {
 	g.onLocalCandidateHandler.Store(f)
 }
 
+// OnGatheringProgress sets an event handler which fires for every network interface and
+// STUN or TURN server when gathering starts, when a candidate is gathered from it and when
+// it completes. A TURN server completes with its relay candidate, the others when gathering
+// completes. Servers left out by SettingEngine.SetICEServerProbeTimeout complete right away
+// with the reason, so slow servers don't hold up the others. pion/ice doesn't tell which
+// server a candidate came from: relay candidates are matched by the IP address of the TURN
+// server, server reflexive candidates only when there is a single STUN server. Candidates
+// that can't be matched, e.g. the ones of a PortMapper, are reported without an Interface
+// or URL.
+func (g *ICEGatherer) OnGatheringProgress(f func(ICEGatheringProgress)) {
+	g.onGatheringProgressHandler.Store(f)
+}
+
+// OnGatheringError sets an event handler which is invoked for every STUN or TURN server
+// that is left out of gathering, see SettingEngine.SetICEServerProbeTimeout
+func (g *ICEGatherer) OnGatheringError(f func(ICEGatheringError)) {
+	g.onGatheringErrorHandler.Store(f)
+}
+
+func (g *ICEGatherer) onGatheringErrors(errs []ICEGatheringError) {
+	handler, ok := g.onGatheringErrorHandler.Load().(func(ICEGatheringError))
+	candidateErrorHandler, candidateErrorOK := g.onCandidateErrorHandler.Load().(func(ICECandidateError))
+	if len(errs) == 0 || (!ok || handler == nil) && (!candidateErrorOK || candidateErrorHandler == nil) {
+		return
+	}
+
+	// The gatherer may be used by an operation of the PeerConnection the handler calls
+	go func() {
+		for _, err := range errs {
+			if ok && handler != nil {
+				handler(err)
+			}
+			if candidateErrorOK && candidateErrorHandler != nil {
+				candidateErrorHandler(ICECandidateError{
+					URL:       err.URL,
+					ErrorCode: ICECandidateErrorCodeUnreachable,
+					ErrorText: err.Err.Error(),
+				})
+			}
+		}
+	}()
+}
+
+// OnCandidateError sets an event handler which is invoked for every STUN or TURN server
+// that failed to provide a candidate, with the error code returned by the server. When
+// gathering completes without a relay candidate for every TURN server, or without a
+// server reflexive candidate, the servers are asked again to find out why.
+func (g *ICEGatherer) OnCandidateError(f func(ICECandidateError)) {
+	g.onCandidateErrorHandler.Store(f)
+}
+
+// diagnoseICEServers asks the servers that may have failed for the reason, and reports
+// the errors to the OnCandidateError handler
+func (g *ICEGatherer) diagnoseICEServers(srflx, relay int) {
+	handler, ok := g.onCandidateErrorHandler.Load().(func(ICECandidateError))
+	if !ok || handler == nil {
+		return
+	}
+
+	// The diagnosis uses copies, the credentials of the servers of the agent are
+	// written when it gathers again
+	var stunServers, turnServers []*ice.URL
+	g.lock.RLock()
+	ctx, policy := g.diagnosisCtx, g.gatherPolicy
+	for _, agentURL := range g.agentServers {
+		url := *agentURL
+		if url.Scheme == ice.SchemeTypeTURN || url.Scheme == ice.SchemeTypeTURNS {
+			turnServers = append(turnServers, &url)
+		} else {
+			stunServers = append(stunServers, &url)
+		}
+	}
+	g.lock.RUnlock()
+	if ctx == nil {
+		return
+	}
+
+	urls := []*ice.URL{}
+	if relay < len(turnServers) {
+		urls = append(urls, turnServers...)
+	}
+	if srflx == 0 && policy != ICETransportPolicyRelay && !g.api.settingEngine.candidates.ICELite {
+		urls = append(urls, stunServers...)
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	go func() {
+		errs := make([]*ICECandidateError, len(urls))
+		var wg sync.WaitGroup
+		for i := range urls {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = diagnoseICEServer(ctx, g.api.settingEngine.vnet, urls[i])
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil && ctx.Err() == nil {
+				handler(*err)
+			}
+		}
+	}()
+}
+
+func (g *ICEGatherer) onGatheringProgress(progress ICEGatheringProgress) {
+	if handler, ok := g.onGatheringProgressHandler.Load().(func(progress ICEGatheringProgress)); ok && handler != nil {
+		handler(progress)
+	}
+}
+
+// gatheringProgress is the progress of an interface or server, with what is needed
+// to match candidates to it
+type gatheringProgress struct {
+	ICEGatheringProgress
+	ips []net.IP
+}
+
+// expectedGatheringProgress returns the interfaces and servers that Gather is expected
+// to gather from
+func (g *ICEGatherer) expectedGatheringProgress() []*gatheringProgress {
+	progress := []*gatheringProgress{}
+	if g.gatherPolicy != ICETransportPolicyRelay {
+		interfaces, err := localInterfaces(g.api.settingEngine)
+		if err != nil {
+			g.log.Warnf("Failed to list the network interfaces: %v", err)
+		}
+		for _, iface := range interfaces {
+			p := &gatheringProgress{ICEGatheringProgress: ICEGatheringProgress{CandidateType: ICECandidateTypeHost, Interface: iface.name}}
+			for _, addr := range iface.addrs {
+				if ipNet, ok := addr.(*net.IPNet); ok {
+					p.ips = append(p.ips, ipNet.IP)
+				}
+			}
+			progress = append(progress, p)
+		}
+	}
+
+	if g.api.settingEngine.candidates.ICELite {
+		return progress
+	}
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	for _, url := range g.agentServers {
+		p := &gatheringProgress{ICEGatheringProgress: ICEGatheringProgress{CandidateType: ICECandidateTypeRelay, URL: url.String()}}
+		if url.Scheme == ice.SchemeTypeSTUN || url.Scheme == ice.SchemeTypeSTUNS {
+			if g.gatherPolicy == ICETransportPolicyRelay {
+				continue
+			}
+			p.CandidateType = ICECandidateTypeSrflx
+		}
+		if ip := net.ParseIP(url.Host); ip != nil && p.CandidateType == ICECandidateTypeRelay {
+			p.ips = []net.IP{ip}
+		}
+		progress = append(progress, p)
+	}
+
+	for _, err := range g.leftOutServers {
+		typ := ICECandidateTypeRelay
+		if strings.HasPrefix(err.URL, "stun") {
+			typ = ICECandidateTypeSrflx
+		}
+		progress = append(progress, &gatheringProgress{ICEGatheringProgress: ICEGatheringProgress{
+			CandidateType: typ, URL: err.URL, Complete: true, Err: err.Err,
+		}})
+	}
+
+	return progress
+}
+
+// findGatheringProgress returns the progress of the interface or server c was gathered
+// from, nil if it can't be told
+func findGatheringProgress(progress []*gatheringProgress, c ICECandidate) *gatheringProgress {
+	ip := net.ParseIP(c.Address)
+
+	var candidates []*gatheringProgress
+	for _, p := range progress {
+		if p.CandidateType != c.Typ || p.Err != nil || (p.Interface == "" && p.URL == "") {
+			continue
+		}
+		for _, pIP := range p.ips {
+			if ip != nil && pIP.Equal(ip) {
+				return p
+			}
+		}
+		candidates = append(candidates, p)
+	}
+
+	// Server reflexive and relay candidates belong to the only server of their type
+	if len(candidates) == 1 && candidates[0].URL != "" {
+		return candidates[0]
+	}
+	return nil
+}
+
+// findUnmatchedGatheringProgress returns the progress of the candidates of typ that
+// can't be matched to an interface or server
+func findUnmatchedGatheringProgress(progress []*gatheringProgress, typ ICECandidateType) *gatheringProgress {
+	for _, p := range progress {
+		if p.CandidateType == typ && p.Interface == "" && p.URL == "" {
+			return p
+		}
+	}
+	return nil
+}
+
 // OnStateChange fires any time the ICEGatherer changes
 func (g *ICEGatherer) OnStateChange(f func(ICEGathererState)) {
 	g.onStateChangeHandler.Store(f)
@@ -4,11 +4,16 @@ package webrtc
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
 	"github.com/pion/transport/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -97,3 +102,529 @@ func TestICEGather_mDNSCandidateGathering(t *testing.T) {
 	<-gotMulticastDNSCandidate.Done()
 	assert.NoError(t, gatherer.Close())
 }
+
+func TestICEGatherer_GatheringProgress(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	gatherer, err := api.NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+
+	var progressLock sync.Mutex
+	progress := map[string]ICEGatheringProgress{}
+	gatherer.OnGatheringProgress(func(p ICEGatheringProgress) {
+		progressLock.Lock()
+		defer progressLock.Unlock()
+		assert.Equal(t, ICECandidateTypeHost, p.CandidateType)
+		assert.Empty(t, p.URL)
+		progress[p.Interface] = p
+	})
+
+	gatherFinished := make(chan struct{})
+	hostCandidates := 0
+	gatherer.OnLocalCandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gatherFinished)
+		} else if c.Typ == ICECandidateTypeHost {
+			hostCandidates++
+		}
+	})
+
+	assert.NoError(t, gatherer.Gather())
+	<-gatherFinished
+
+	// Without ICE servers there is progress for every interface
+	interfaces, err := localInterfaces(api.settingEngine)
+	assert.NoError(t, err)
+
+	progressLock.Lock()
+	defer progressLock.Unlock()
+	candidates := 0
+	for _, iface := range interfaces {
+		assert.Contains(t, progress, iface.name)
+	}
+	for _, p := range progress {
+		assert.True(t, p.Complete)
+		candidates += p.Candidates
+	}
+	assert.Equal(t, hostCandidates, candidates)
+
+	assert.NoError(t, gatherer.Close())
+}
+
+func TestICEGatherer_CandidatePriorityFunc(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.SetICECandidatePriorityFunc(func(c ICECandidate) uint32 {
+		if c.Protocol == ICEProtocolUDP {
+			return 1234
+		}
+		return 0
+	})
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+
+	gatherFinished := make(chan struct{})
+	gatherer.OnLocalCandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gatherFinished)
+			return
+		}
+		assert.Equal(t, uint32(1234), c.Priority)
+	})
+
+	assert.NoError(t, gatherer.Gather())
+	<-gatherFinished
+
+	candidates, err := gatherer.GetLocalCandidates()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, candidates)
+	for _, c := range candidates {
+		assert.Equal(t, uint32(1234), c.Priority)
+		assert.True(t, strings.Contains(c.ToJSON().Candidate, " 1234 "))
+	}
+
+	assert.NoError(t, gatherer.Close())
+}
+
+func TestICEGatherer_CandidateRewriteFunc(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	gather := func(rewrite func(ICECandidate) (ICECandidate, bool)) (signaled, described []ICECandidate) {
+		s := SettingEngine{}
+		s.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+		s.SetICECandidateRewriteFunc(rewrite)
+
+		gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{})
+		assert.NoError(t, err)
+
+		gatherFinished := make(chan struct{})
+		gatherer.OnLocalCandidate(func(c *ICECandidate) {
+			if c == nil {
+				close(gatherFinished)
+				return
+			}
+			signaled = append(signaled, *c)
+		})
+
+		assert.NoError(t, gatherer.Gather())
+		<-gatherFinished
+
+		described, err = gatherer.GetLocalCandidates()
+		assert.NoError(t, err)
+		assert.NoError(t, gatherer.Close())
+		return signaled, described
+	}
+
+	// The address of every candidate is replaced
+	signaled, described := gather(func(c ICECandidate) (ICECandidate, bool) {
+		c.Address = "203.0.113.9"
+		return c, true
+	})
+	assert.NotEmpty(t, signaled)
+	assert.Len(t, described, len(signaled))
+	for _, c := range append(signaled, described...) {
+		assert.Equal(t, "203.0.113.9", c.Address)
+		assert.True(t, strings.Contains(c.ToJSON().Candidate, " 203.0.113.9 "))
+	}
+
+	// Dropped candidates aren't signaled
+	signaled, described = gather(func(c ICECandidate) (ICECandidate, bool) {
+		return c, false
+	})
+	assert.Empty(t, signaled)
+	assert.Empty(t, described)
+}
+
+type testPortMapper struct {
+	mapped, unmapped chan int
+
+	// release blocks MapPort until it is closed, if set
+	release chan struct{}
+}
+
+func (m *testPortMapper) MapPort(internalPort int) (net.IP, int, error) {
+	if m.release != nil {
+		<-m.release
+	}
+	m.mapped <- internalPort
+	return net.IPv4(203, 0, 113, 7), internalPort + 1, nil
+}
+
+func (m *testPortMapper) UnmapPort(internalPort int) error {
+	m.unmapped <- internalPort
+	return nil
+}
+
+func TestICEGatherer_PortMapper(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	portMapper := &testPortMapper{mapped: make(chan int, 100), unmapped: make(chan int, 100)}
+
+	s := SettingEngine{}
+	s.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+	s.SetPortMapper(portMapper)
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+
+	gatherFinished := make(chan struct{})
+	gatherer.OnLocalCandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gatherFinished)
+		}
+	})
+
+	assert.NoError(t, gatherer.Gather())
+	<-gatherFinished
+
+	candidates, err := gatherer.GetLocalCandidates()
+	assert.NoError(t, err)
+
+	hostPorts, srflxPorts := map[uint16]bool{}, map[uint16]bool{}
+	for _, c := range candidates {
+		switch c.Typ {
+		case ICECandidateTypeHost:
+			hostPorts[c.Port] = true
+		case ICECandidateTypeSrflx:
+			assert.Equal(t, "203.0.113.7", c.Address)
+			assert.Equal(t, c.RelatedPort+1, c.Port)
+			srflxPorts[c.RelatedPort] = true
+		default:
+		}
+	}
+	assert.NotEmpty(t, hostPorts)
+	assert.Equal(t, hostPorts, srflxPorts)
+	assert.Equal(t, len(hostPorts), len(portMapper.mapped))
+
+	assert.NoError(t, gatherer.Close())
+	assert.Equal(t, len(hostPorts), len(portMapper.unmapped))
+}
+
+func TestICEGatherer_PortMapperAsync(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	portMapper := &testPortMapper{mapped: make(chan int, 100), unmapped: make(chan int, 100), release: make(chan struct{})}
+
+	s := SettingEngine{}
+	s.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+	s.SetPortMapper(portMapper)
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+
+	candidates := make(chan *ICECandidate, 100)
+	gatherer.OnLocalCandidate(func(c *ICECandidate) {
+		candidates <- c
+	})
+	assert.NoError(t, gatherer.Gather())
+
+	// Host candidates are signaled while the ports are mapped
+	host := <-candidates
+	assert.Equal(t, ICECandidateTypeHost, host.Typ)
+	close(portMapper.release)
+
+	// The end of gathering waits for the mapped candidates
+	srflx := 0
+	for c := range candidates {
+		if c == nil {
+			break
+		}
+		if c.Typ == ICECandidateTypeSrflx {
+			srflx++
+		}
+	}
+	assert.NotZero(t, srflx)
+	assert.Equal(t, len(portMapper.mapped), srflx)
+
+	assert.NoError(t, gatherer.Close())
+}
+
+func TestICEGatherer_TURNCredentialFunc(t *testing.T) {
+	s := SettingEngine{}
+	calls := 0
+	s.SetTURNCredentialFunc(func(url string) (string, string, error) {
+		calls++
+		if strings.Contains(url, "broken") {
+			return "", "", errors.New("credentials unavailable")
+		}
+		return "user", fmt.Sprintf("password%d", calls), nil
+	})
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{
+		ICEServers: []ICEServer{
+			{URLs: []string{"stun:stun.example.com"}},
+			{URLs: []string{"turn:turn.example.com"}, Username: "configured", Credential: "configured"},
+			{URLs: []string{"turn:broken.example.com"}, Username: "configured", Credential: "configured"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, gatherer.createAgent())
+
+	gatherer.setTURNCredentials(gatherer.turnCredentials())
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "user", gatherer.agentServers[1].Username)
+	assert.Equal(t, "password1", gatherer.agentServers[1].Password)
+
+	// Failing to get credentials keeps the configured ones
+	assert.Equal(t, "configured", gatherer.agentServers[2].Username)
+	assert.Equal(t, "configured", gatherer.agentServers[2].Password)
+
+	// The credentials are refreshed for every gathering
+	gatherer.setTURNCredentials(gatherer.turnCredentials())
+	assert.Equal(t, "password3", gatherer.agentServers[1].Password)
+
+	// Only the copies of the agent are written
+	assert.Equal(t, "configured", gatherer.validatedServers[1].Password)
+
+	assert.NoError(t, gatherer.Close())
+}
+
+func TestICEGatherer_OAuthTURNServer(t *testing.T) {
+	servers := []ICEServer{{
+		URLs:           []string{"turn:turn.example.com"},
+		Username:       "kid",
+		Credential:     OAuthCredential{MACKey: "key", AccessToken: "token"},
+		CredentialType: ICECredentialTypeOauth,
+	}}
+
+	// pion/turn only supports long-term credentials, the server is left out
+	gatherer, err := NewAPI().NewICEGatherer(ICEGatherOptions{ICEServers: servers})
+	assert.NoError(t, err)
+
+	gatheringErrs := make(chan ICEGatheringError, 1)
+	gatherer.OnGatheringError(func(err ICEGatheringError) {
+		gatheringErrs <- err
+	})
+	assert.NoError(t, gatherer.createAgent())
+	assert.Empty(t, gatherer.agentServers)
+	assert.True(t, errors.Is(<-gatheringErrs, ErrTurnOAuthNotSupported))
+	assert.NoError(t, gatherer.Close())
+
+	// A TURNCredentialFunc can supply long-term credentials instead
+	s := SettingEngine{}
+	s.SetTURNCredentialFunc(func(string) (string, string, error) {
+		return "user", "password", nil
+	})
+	gatherer, err = NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{ICEServers: servers})
+	assert.NoError(t, err)
+	assert.NoError(t, gatherer.createAgent())
+	gatherer.setTURNCredentials(gatherer.turnCredentials())
+	assert.Len(t, gatherer.agentServers, 1)
+	assert.Equal(t, "password", gatherer.agentServers[0].Password)
+	assert.NoError(t, gatherer.Close())
+}
+
+func TestICEGatherer_ServerProbe(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	// A STUN server that answers, and one that doesn't
+	alive, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	dead, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		b := make([]byte, receiveMTU)
+		for {
+			n, addr, readErr := alive.ReadFrom(b)
+			if readErr != nil {
+				return
+			}
+			request := &stun.Message{Raw: append([]byte{}, b[:n]...)}
+			if request.Decode() != nil {
+				continue
+			}
+			response, buildErr := stun.Build(request, stun.BindingSuccess, &stun.XORMappedAddress{
+				IP: addr.(*net.UDPAddr).IP, Port: addr.(*net.UDPAddr).Port,
+			}, stun.Fingerprint)
+			assert.NoError(t, buildErr)
+			_, writeErr := alive.WriteTo(response.Raw, addr)
+			assert.NoError(t, writeErr)
+		}
+	}()
+
+	s := SettingEngine{}
+	s.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+	s.SetICEServerProbeTimeout(200 * time.Millisecond)
+	deadURL := "stun:" + dead.LocalAddr().String()
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{
+		ICEServers: []ICEServer{{URLs: []string{"stun:" + alive.LocalAddr().String(), deadURL}}},
+	})
+	assert.NoError(t, err)
+
+	gatheringErrs := make(chan ICEGatheringError, 8)
+	gatherer.OnGatheringError(func(err ICEGatheringError) {
+		gatheringErrs <- err
+	})
+	var progressLock sync.Mutex
+	progress := map[string]ICEGatheringProgress{}
+	gatherer.OnGatheringProgress(func(p ICEGatheringProgress) {
+		progressLock.Lock()
+		defer progressLock.Unlock()
+		if p.URL != "" {
+			progress[p.URL] = p
+		}
+	})
+	gatherFinished := make(chan struct{})
+	srflx := 0
+	gatherer.OnLocalCandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gatherFinished)
+		} else if c.Typ == ICECandidateTypeSrflx {
+			srflx++
+		}
+	})
+
+	// Gathering doesn't wait for the STUN timeout of the dead server
+	start := time.Now()
+	assert.NoError(t, gatherer.Gather())
+	<-gatherFinished
+	assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	assert.NotZero(t, srflx)
+
+	gatheringErr := <-gatheringErrs
+	assert.Equal(t, deadURL, gatheringErr.URL)
+	assert.True(t, errors.Is(gatheringErr, errICEServerNoResponse))
+	assert.Empty(t, gatheringErrs)
+
+	// The candidates of the server that answered are reported with its progress,
+	// the server that was left out completed with the reason
+	progressLock.Lock()
+	aliveProgress := progress["stun:"+alive.LocalAddr().String()]
+	assert.Equal(t, srflx, aliveProgress.Candidates)
+	assert.True(t, aliveProgress.Complete)
+	assert.True(t, progress[deadURL].Complete)
+	assert.True(t, errors.Is(progress[deadURL].Err, errICEServerNoResponse))
+	progressLock.Unlock()
+
+	// The agent has room for the one server that passed the probe. Servers that pass it
+	// later but don't fit are left out, servers that fail it are tried if there is room.
+	_, alivePort, err := net.SplitHostPort(alive.LocalAddr().String())
+	assert.NoError(t, err)
+	localhostURL := "stun:localhost:" + alivePort
+	_, err = gatherer.setConfiguration([]ICEServer{{URLs: []string{"stun:" + alive.LocalAddr().String(), localhostURL}}}, ICETransportPolicyAll)
+	assert.NoError(t, err)
+	gatherer.updateAgentServers()
+	assert.Equal(t, "stun:"+alive.LocalAddr().String(), gatherer.agentServers[0].String())
+	gatheringErr = <-gatheringErrs
+	assert.Equal(t, localhostURL, gatheringErr.URL)
+	assert.ErrorIs(t, gatheringErr, errICEGathererNoRoomForServer)
+
+	_, err = gatherer.setConfiguration([]ICEServer{{URLs: []string{deadURL, deadURL}}}, ICETransportPolicyAll)
+	assert.NoError(t, err)
+	gatherer.updateAgentServers()
+	assert.Equal(t, deadURL, gatherer.agentServers[0].String())
+
+	assert.NoError(t, gatherer.Close())
+	assert.NoError(t, alive.Close())
+	assert.NoError(t, dead.Close())
+	<-serverDone
+}
+
+func TestICEGatherer_CandidateError(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	// A TURN server that rejects every credential
+	server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		b := make([]byte, receiveMTU)
+		for {
+			n, addr, readErr := server.ReadFrom(b)
+			if readErr != nil {
+				return
+			}
+			request := &stun.Message{Raw: append([]byte{}, b[:n]...)}
+			if request.Decode() != nil {
+				continue
+			}
+
+			var response *stun.Message
+			var buildErr error
+			switch request.Type.Method {
+			case stun.MethodBinding:
+				response, buildErr = stun.Build(request, stun.BindingSuccess, &stun.XORMappedAddress{
+					IP: addr.(*net.UDPAddr).IP, Port: addr.(*net.UDPAddr).Port,
+				}, stun.Fingerprint)
+			case stun.MethodAllocate:
+				response, buildErr = stun.Build(request, stun.NewType(stun.MethodAllocate, stun.ClassErrorResponse),
+					stun.CodeUnauthorized, stun.NewRealm("pion.ly"), stun.NewNonce("nonce"), stun.Fingerprint)
+			default:
+				continue
+			}
+			assert.NoError(t, buildErr)
+			_, writeErr := server.WriteTo(response.Raw, addr)
+			assert.NoError(t, writeErr)
+		}
+	}()
+
+	s := SettingEngine{}
+	s.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{
+		ICEServers: []ICEServer{{
+			URLs:       []string{"turn:" + server.LocalAddr().String()},
+			Username:   "user",
+			Credential: "wrong",
+		}},
+	})
+	assert.NoError(t, err)
+
+	candidateErrs := make(chan ICECandidateError, 1)
+	gatherer.OnCandidateError(func(err ICECandidateError) {
+		candidateErrs <- err
+	})
+
+	assert.NoError(t, gatherer.Gather())
+
+	candidateErr := <-candidateErrs
+	assert.Contains(t, candidateErr.URL, server.LocalAddr().String())
+	assert.Equal(t, int(stun.CodeUnauthorized), candidateErr.ErrorCode)
+	assert.Equal(t, "Unauthorized", candidateErr.ErrorText)
+	assert.Equal(t, "127.0.0.1", candidateErr.Address)
+	assert.NotZero(t, candidateErr.Port)
+
+	assert.NoError(t, gatherer.Close())
+	assert.NoError(t, server.Close())
+	<-serverDone
+}
@@ -0,0 +1,23 @@
+package webrtc
+
+import "fmt"
+
+// ICEGatheringError describes a STUN or TURN server that was left out while
+// gathering candidates, because it didn't answer a probe, see
+// SettingEngine.SetICEServerProbeTimeout
+type ICEGatheringError struct {
+	// URL is the URL of the server, e.g. stun:stun.example.org:3478
+	URL string
+
+	// Err is the reason the server was left out
+	Err error
+}
+
+func (e ICEGatheringError) Error() string {
+	return fmt.Sprintf("ICE server %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the reason the server was left out
+func (e ICEGatheringError) Unwrap() error {
+	return e.Err
+}
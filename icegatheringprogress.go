@@ -0,0 +1,25 @@
+package webrtc
+
+// ICEGatheringProgress describes how far gathering from one network interface or
+// one STUN or TURN server has progressed. It allows to show which step of gathering
+// is still pending, e.g. that a TURN server is still being contacted.
+type ICEGatheringProgress struct {
+	// CandidateType is the type of the candidates this progress is about
+	CandidateType ICECandidateType
+
+	// Interface is the network interface host candidates are gathered from
+	Interface string
+
+	// URL is the STUN or TURN server server reflexive or relay candidates are
+	// gathered from, e.g. turn:turn.example.org:3478?transport=udp
+	URL string
+
+	// Candidates is the amount of candidates gathered so far
+	Candidates int
+
+	// Complete is true once no more candidates will be gathered
+	Complete bool
+
+	// Err is the reason a server was left out, see SettingEngine.SetICEServerProbeTimeout
+	Err error
+}
@@ -1,12 +1,26 @@
 package webrtc
 
 import (
+	"fmt"
 	"net"
 
 	"github.com/pion/ice/v2"
 	"github.com/pion/logging"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
+// DSCP values for WebRTC traffic recommended by RFC 8837
+const (
+	// DSCPExpeditedForwarding is recommended for interactive audio
+	DSCPExpeditedForwarding = 46
+	// DSCPAssuredForwarding41 is recommended for interactive video
+	DSCPAssuredForwarding41 = 34
+)
+
+// ecnECT0 is the ECN-Capable Transport codepoint ECT(0) of RFC 3168
+const ecnECT0 = 0x02
+
 // NewICETCPMux creates a new instance of ice.TCPMuxDefault. It enables use of
 // passive ICE TCP candidates.
 func NewICETCPMux(logger logging.LeveledLogger, listener net.Listener, readBufferSize int) ice.TCPMux {
@@ -18,10 +32,41 @@ func NewICETCPMux(logger logging.LeveledLogger, listener net.Listener, readBuffe
 }
 
 // NewICEUDPMux creates a new instance of ice.UDPMuxDefault. It allows many PeerConnections to be served
-// by a single UDP Port.
+// by a single UDP Port. NewICEPacketConnMux serves them from a UDPConn too, it reads and writes the
+// packets in batches.
 func NewICEUDPMux(logger logging.LeveledLogger, udpConn *net.UDPConn) ice.UDPMux {
 	return ice.NewUDPMuxDefault(ice.UDPMuxParams{
 		UDPConn: udpConn,
 		Logger:  logger,
 	})
 }
+
+// SetUDPConnQoS marks the packets sent on a UDPConn with the DSCP value and, if ecn
+// is true, with the ECN-Capable Transport codepoint ECT(0). Use it on the UDPConn
+// passed to NewICEUDPMux to mark the traffic of all PeerConnections served by the
+// mux. Audio and video of a BUNDLE group share the socket, so they share the mark.
+// ECN congestion marks aren't reported back to the sender.
+func SetUDPConnQoS(conn *net.UDPConn, dscp uint8, ecn bool) error {
+	if dscp >= 64 {
+		return fmt.Errorf("%w: %d", errInvalidDSCP, dscp)
+	}
+
+	tos := int(dscp) << 2
+	if ecn {
+		tos |= ecnECT0
+	}
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || addr.IP.To4() != nil {
+		return ipv4.NewConn(conn).SetTOS(tos)
+	}
+
+	if err := ipv6.NewConn(conn).SetTrafficClass(tos); err != nil {
+		return err
+	}
+	// A dual stack socket also sends IPv4 packets, it fails for IPv6 only sockets
+	if addr.IP.IsUnspecified() {
+		_ = ipv4.NewConn(conn).SetTOS(tos)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// +build !js
+
+package webrtc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/stun"
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/ipv4"
+)
+
+func TestSetUDPConnQoS(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+
+	assert.NoError(t, SetUDPConnQoS(conn, DSCPExpeditedForwarding, false))
+	tos, err := ipv4.NewConn(conn).TOS()
+	assert.NoError(t, err)
+	assert.Equal(t, 0xb8, tos)
+
+	assert.NoError(t, SetUDPConnQoS(conn, DSCPAssuredForwarding41, true))
+	tos, err = ipv4.NewConn(conn).TOS()
+	assert.NoError(t, err)
+	assert.Equal(t, 0x8a, tos)
+
+	assert.ErrorIs(t, SetUDPConnQoS(conn, 64, false), errInvalidDSCP)
+
+	assert.NoError(t, conn.Close())
+}
+
+func TestNewICEUDPMuxSharded(t *testing.T) {
+	_, err := NewICEUDPMuxSharded(nil, "udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0)
+	assert.ErrorIs(t, err, errICEUDPMuxShardsInvalid)
+
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	mux, err := NewICEUDPMuxSharded(loggerFactory.NewLogger("test"), "udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, 2)
+	if errors.Is(err, errReusePortUnsupported) {
+		t.Skip(err)
+	}
+	assert.NoError(t, err)
+
+	sharded := mux.(*shardedUDPMux)
+	assert.Len(t, sharded.udpConns, 2)
+	assert.Equal(t, sharded.udpConns[0].LocalAddr(), sharded.udpConns[1].LocalAddr())
+
+	conn, err := mux.GetConn("ufrag")
+	assert.NoError(t, err)
+
+	client, err := net.DialUDP("udp4", nil, sharded.udpConns[0].LocalAddr().(*net.UDPAddr))
+	assert.NoError(t, err)
+
+	// The socket the kernel picks for the client routes the request by its username
+	request, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.NewUsername("ufrag:remote"), stun.Fingerprint)
+	assert.NoError(t, err)
+	_, err = client.Write(request.Raw)
+	assert.NoError(t, err)
+
+	buf := make([]byte, receiveMTU)
+	n, addr, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, request.Raw, buf[:n])
+	assert.Equal(t, client.LocalAddr().String(), addr.String())
+
+	// The reply is sent from the shared port
+	_, err = conn.WriteTo([]byte("reply"), addr)
+	assert.NoError(t, err)
+	n, err = client.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "reply", string(buf[:n]))
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, conn.Close())
+	assert.NoError(t, mux.Close())
+}
+
+// userspacePacketConn hides the UDPConn, like the PacketConn of a userspace network stack
+type userspacePacketConn struct {
+	net.PacketConn
+}
+
+func TestNewICEPacketConnMux(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	_, err := NewICEPacketConnMux(nil, &userspacePacketConn{PacketConn: &net.IPConn{}})
+	assert.ErrorIs(t, err, errICEPacketConnNotUDP)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	mux, err := NewICEPacketConnMux(loggerFactory.NewLogger("test"), &userspacePacketConn{PacketConn: conn})
+	assert.NoError(t, err)
+
+	s := SettingEngine{}
+	s.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+	s.SetICEUDPMux(mux)
+	s.SetNAT1To1IPs([]string{"127.0.0.1"}, ICECandidateTypeHost)
+
+	offerer, err := NewAPI().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	answerer, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	connected := make(chan struct{})
+	var once sync.Once
+	answerer.OnICEConnectionStateChange(func(state ICEConnectionState) {
+		if state == ICEConnectionStateConnected {
+			once.Do(func() { close(connected) })
+		}
+	})
+
+	_, err = offerer.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, signalPair(offerer, answerer))
+	<-connected
+
+	// The answerer only has the candidate of the PacketConn
+	assert.Contains(t, answerer.LocalDescription().SDP, fmt.Sprintf("127.0.0.1 %d typ host", conn.LocalAddr().(*net.UDPAddr).Port))
+
+	closePairNow(t, offerer, answerer)
+	assert.NoError(t, mux.Close())
+	assert.NoError(t, conn.Close())
+}
+
+// A UDPConn is read and written in batches
+func TestNewICEPacketConnMux_Batch(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	udpConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+
+	mux, err := NewICEPacketConnMux(logging.NewDefaultLoggerFactory().NewLogger("test"), udpConn)
+	assert.NoError(t, err)
+	assert.NotNil(t, mux.(*packetConnMux).writer)
+
+	conn, err := mux.GetConn("ufrag")
+	assert.NoError(t, err)
+
+	client, err := net.DialUDP("udp4", nil, udpConn.LocalAddr().(*net.UDPAddr))
+	assert.NoError(t, err)
+
+	request, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.NewUsername("ufrag:remote"), stun.Fingerprint)
+	assert.NoError(t, err)
+	_, err = client.Write(request.Raw)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = client.Write([]byte{byte(i)})
+		assert.NoError(t, err)
+	}
+
+	buf := make([]byte, receiveMTU)
+	n, addr, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, request.Raw, buf[:n])
+
+	// The packets that followed keep their own buffers
+	for i := 0; i < 10; i++ {
+		n, _, err = conn.ReadFrom(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{byte(i)}, buf[:n])
+	}
+
+	// Concurrent writes are all sent
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, writeErr := conn.WriteTo([]byte("reply"), addr)
+			assert.NoError(t, writeErr)
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < writers; i++ {
+		n, err = client.Read(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "reply", string(buf[:n]))
+	}
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, mux.Close())
+	assert.NoError(t, udpConn.Close())
+}
@@ -0,0 +1,418 @@
+// +build !js
+
+package webrtc
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/logging"
+	"github.com/pion/stun"
+	"github.com/pion/transport/deadline"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// packetConnMuxBufferSize is the number of packets buffered for each ufrag, packets
+	// arriving while the buffer is full are dropped
+	packetConnMuxBufferSize = 256
+
+	// packetConnMuxBatchSize is the number of packets read or written with one system call
+	packetConnMuxBatchSize = 32
+)
+
+// NewICEPacketConnMux creates a UDPMux that runs ICE over a PacketConn provided by the
+// caller instead of the sockets of the operating system, e.g. the UDP endpoint of a
+// userspace network stack like gVisor or wireguard-go, or a PacketConn of a test. Like
+// the mux of NewICEUDPMux it serves many PeerConnections, the packets are demultiplexed
+// by the ufrag of the STUN binding requests and then by remote address.
+//
+// The LocalAddr of the PacketConn and the addresses returned by ReadFrom have to be
+// *net.UDPAddr. pion/ice advertises the host candidate with the IP of the first local
+// interface, use SettingEngine.SetNAT1To1IPs with ICECandidateTypeHost to advertise
+// the address of the network stack instead.
+//
+// The PacketConn stays owned by the caller, the mux stops reading when it returns an
+// error, e.g. because it was closed.
+//
+// If the PacketConn is a *net.UDPConn the mux reads and writes packets in batches, with
+// a single recvmmsg or sendmmsg system call on Linux. Writes of the ufrags that happen
+// at the same time are sent together. On other platforms golang.org/x/net falls back to
+// one system call per packet.
+func NewICEPacketConnMux(logger logging.LeveledLogger, conn net.PacketConn) (ice.UDPMux, error) {
+	localAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, errICEPacketConnNotUDP
+	}
+	if logger == nil {
+		logger = logging.NewDefaultLoggerFactory().NewLogger("ice")
+	}
+
+	m := &packetConnMux{
+		conn:        conn,
+		localAddr:   localAddr,
+		log:         logger,
+		conns:       map[string]*packetConnMuxedConn{},
+		connsByAddr: map[string]*packetConnMuxedConn{},
+		closed:      make(chan struct{}),
+	}
+	if batch := newPacketConnBatch(conn); batch != nil {
+		m.writer = &packetConnBatchWriter{conn: batch}
+		go m.readBatchLoop(batch)
+	} else {
+		go m.readLoop()
+	}
+	return m, nil
+}
+
+// packetConnBatch reads and writes multiple packets with one system call, it is
+// implemented by the ipv4 and ipv6 PacketConns of golang.org/x/net
+type packetConnBatch interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+func newPacketConnBatch(conn net.PacketConn) packetConnBatch {
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+
+	if addr, ok := udpConn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		return ipv6.NewPacketConn(udpConn)
+	}
+	return ipv4.NewPacketConn(udpConn)
+}
+
+type packetConnMux struct {
+	conn      net.PacketConn
+	localAddr *net.UDPAddr
+	log       logging.LeveledLogger
+
+	mu          sync.Mutex
+	conns       map[string]*packetConnMuxedConn
+	connsByAddr map[string]*packetConnMuxedConn
+
+	// writer is set if the PacketConn writes in batches
+	writer *packetConnBatchWriter
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (m *packetConnMux) readLoop() {
+	for {
+		b := make([]byte, receiveMTU)
+		n, addr, err := m.conn.ReadFrom(b)
+		if err != nil {
+			m.log.Debugf("Stopped reading the ICE PacketConn: %v", err)
+			_ = m.Close()
+			return
+		}
+
+		m.route(b[:n], addr)
+	}
+}
+
+func (m *packetConnMux) readBatchLoop(batch packetConnBatch) {
+	messages := make([]ipv4.Message, packetConnMuxBatchSize)
+	for i := range messages {
+		messages[i].Buffers = [][]byte{make([]byte, receiveMTU)}
+	}
+
+	for {
+		n, err := batch.ReadBatch(messages, 0)
+		if err != nil {
+			m.log.Debugf("Stopped reading the ICE PacketConn: %v", err)
+			_ = m.Close()
+			return
+		}
+
+		for i := range messages[:n] {
+			// A routed packet keeps its buffer, the message gets a new one
+			if m.route(messages[i].Buffers[0][:messages[i].N], messages[i].Addr) {
+				messages[i].Buffers[0] = make([]byte, receiveMTU)
+			}
+		}
+	}
+}
+
+// route pushes a packet to the conn it belongs to and returns if there was one
+func (m *packetConnMux) route(b []byte, addr net.Addr) bool {
+	if _, ok := addr.(*net.UDPAddr); !ok {
+		m.log.Warnf("Discarding packet from %s, it isn't a UDP address", addr)
+		return false
+	}
+
+	c := m.connFor(b, addr)
+	if c == nil {
+		return false
+	}
+	c.push(udpPacket{data: b, addr: addr})
+	return true
+}
+
+// packetConnBatchWriter sends the packets written at the same time in one batch. The
+// first writer sends its packet and the ones queued meanwhile, the others wait for it.
+type packetConnBatchWriter struct {
+	conn packetConnBatch
+
+	mu      sync.Mutex
+	pending []*packetConnBatchWrite
+	writing bool
+}
+
+type packetConnBatchWrite struct {
+	message ipv4.Message
+	err     error
+	done    chan struct{}
+}
+
+func (w *packetConnBatchWriter) WriteTo(b []byte, addr net.Addr) (int, error) {
+	write := &packetConnBatchWrite{
+		message: ipv4.Message{Buffers: [][]byte{b}, Addr: addr},
+		done:    make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	w.pending = append(w.pending, write)
+	if !w.writing {
+		w.writing = true
+		for len(w.pending) != 0 {
+			writes := w.pending
+			w.pending = nil
+			w.mu.Unlock()
+			w.flush(writes)
+			w.mu.Lock()
+		}
+		w.writing = false
+	}
+	w.mu.Unlock()
+
+	<-write.done
+	if write.err != nil {
+		return 0, write.err
+	}
+	return len(b), nil
+}
+
+// flush sends the writes, a packet that can't be sent fails only its own write
+func (w *packetConnBatchWriter) flush(writes []*packetConnBatchWrite) {
+	messages := make([]ipv4.Message, len(writes))
+	for i, write := range writes {
+		messages[i] = write.message
+	}
+
+	for i := 0; i < len(writes); {
+		end := i + packetConnMuxBatchSize
+		if end > len(writes) {
+			end = len(writes)
+		}
+
+		n, err := w.conn.WriteBatch(messages[i:end], 0)
+		for _, write := range writes[i : i+n] {
+			close(write.done)
+		}
+		i += n
+
+		if err == nil && n == 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil && i < end {
+			writes[i].err = err
+			close(writes[i].done)
+			i++
+		}
+	}
+}
+
+// connFor returns the conn of the remote address, a STUN binding request from an address
+// that hasn't been seen before is routed by the ufrag of its username
+func (m *packetConnMux) connFor(b []byte, addr net.Addr) *packetConnMuxedConn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.connsByAddr[addr.String()]; ok {
+		return c
+	}
+	if !stun.IsMessage(b) {
+		return nil
+	}
+
+	msg := &stun.Message{Raw: append([]byte{}, b...)}
+	if err := msg.Decode(); err != nil {
+		m.log.Warnf("Failed to decode STUN message from %s: %v", addr, err)
+		return nil
+	}
+
+	username, err := msg.Get(stun.AttrUsername)
+	if err != nil {
+		return nil
+	}
+
+	c, ok := m.conns[strings.Split(string(username), ":")[0]]
+	if !ok {
+		return nil
+	}
+	m.connsByAddr[addr.String()] = c
+	return c
+}
+
+// GetConn returns the PacketConn of the ufrag, it is created if it doesn't exist
+func (m *packetConnMux) GetConn(ufrag string) (net.PacketConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-m.closed:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+
+	if c, ok := m.conns[ufrag]; ok {
+		return c, nil
+	}
+
+	c := &packetConnMuxedConn{
+		mux:          m,
+		ufrag:        ufrag,
+		packets:      make(chan udpPacket, packetConnMuxBufferSize),
+		readDeadline: deadline.New(),
+		closed:       make(chan struct{}),
+	}
+	m.conns[ufrag] = c
+	return c, nil
+}
+
+// RemoveConnByUfrag closes the PacketConn of the ufrag
+func (m *packetConnMux) RemoveConnByUfrag(ufrag string) {
+	m.mu.Lock()
+	c, ok := m.conns[ufrag]
+	m.mu.Unlock()
+
+	if ok {
+		_ = c.Close()
+	}
+}
+
+func (m *packetConnMux) registerAddr(c *packetConnMuxedConn, addr net.Addr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conns[c.ufrag] == c {
+		m.connsByAddr[addr.String()] = c
+	}
+}
+
+func (m *packetConnMux) removeConn(c *packetConnMuxedConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conns[c.ufrag] == c {
+		delete(m.conns, c.ufrag)
+	}
+	for addr, addrConn := range m.connsByAddr {
+		if addrConn == c {
+			delete(m.connsByAddr, addr)
+		}
+	}
+}
+
+// Close closes the PacketConns of all ufrags, the PacketConn of the caller isn't closed
+func (m *packetConnMux) Close() error {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		close(m.closed)
+		conns := make([]*packetConnMuxedConn, 0, len(m.conns))
+		for _, c := range m.conns {
+			conns = append(conns, c)
+		}
+		m.mu.Unlock()
+
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	})
+	return nil
+}
+
+// packetConnMuxedConn is the PacketConn of one ufrag of a packetConnMux
+type packetConnMuxedConn struct {
+	mux   *packetConnMux
+	ufrag string
+
+	packets      chan udpPacket
+	readDeadline *deadline.Deadline
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// push is only called by the read loop of the mux
+func (c *packetConnMuxedConn) push(p udpPacket) {
+	select {
+	case c.packets <- p:
+	default:
+		c.mux.log.Debugf("Dropping packet from %s, the buffer of %s is full", p.addr, c.ufrag)
+	}
+}
+
+func (c *packetConnMuxedConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case p := <-c.packets:
+		if len(b) < len(p.data) {
+			return 0, nil, io.ErrShortBuffer
+		}
+		return copy(b, p.data), p.addr, nil
+	case <-c.readDeadline.Done():
+		return 0, nil, c.readDeadline.Err()
+	case <-c.closed:
+		return 0, nil, io.EOF
+	}
+}
+
+func (c *packetConnMuxedConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	// Responses from an address the conn sends to are routed to it
+	c.mux.registerAddr(c, addr)
+	if c.mux.writer != nil {
+		return c.mux.writer.WriteTo(b, addr)
+	}
+	return c.mux.conn.WriteTo(b, addr)
+}
+
+func (c *packetConnMuxedConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.mux.removeConn(c)
+	})
+	return nil
+}
+
+func (c *packetConnMuxedConn) LocalAddr() net.Addr {
+	return c.mux.localAddr
+}
+
+func (c *packetConnMuxedConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *packetConnMuxedConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+// SetWriteDeadline does nothing, the PacketConn is shared with the other ufrags
+func (c *packetConnMuxedConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
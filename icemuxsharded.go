@@ -0,0 +1,228 @@
+// +build !js
+
+package webrtc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/logging"
+	"github.com/pion/transport/deadline"
+	"github.com/pion/webrtc/v3/internal/util"
+)
+
+// NewICEUDPMuxSharded opens shards UDP sockets on the same address with SO_REUSEPORT
+// and serves each of them with its own ice.UDPMuxDefault. The kernel spreads the
+// remote addresses over the sockets, so receiving isn't limited by the queue of a
+// single socket. If the port of address is 0 the first socket picks one and the
+// others share it. SO_REUSEPORT is supported on Linux and macOS.
+//
+// The returned UDPMux is used like the one of NewICEUDPMux and closes its sockets
+// when it is closed.
+func NewICEUDPMuxSharded(logger logging.LeveledLogger, network string, address *net.UDPAddr, shards int) (ice.UDPMux, error) {
+	if shards < 1 {
+		return nil, errICEUDPMuxShardsInvalid
+	}
+
+	m := &shardedUDPMux{}
+	for i := 0; i < shards; i++ {
+		conn, err := listenUDPReusePort(network, address)
+		if err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+		if i == 0 {
+			address = conn.LocalAddr().(*net.UDPAddr)
+		}
+
+		m.udpConns = append(m.udpConns, conn)
+		m.muxes = append(m.muxes, NewICEUDPMux(logger, conn))
+	}
+
+	return m, nil
+}
+
+// shardedUDPMux is a UDPMux of one UDPMuxDefault per socket
+type shardedUDPMux struct {
+	udpConns []*net.UDPConn
+	muxes    []ice.UDPMux
+}
+
+// GetConn returns a PacketConn that receives the packets for the ufrag from all sockets
+func (m *shardedUDPMux) GetConn(ufrag string) (net.PacketConn, error) {
+	conns := make([]net.PacketConn, 0, len(m.muxes))
+	for _, mux := range m.muxes {
+		conn, err := mux.GetConn(ufrag)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+
+	return newShardedUDPConn(conns), nil
+}
+
+func (m *shardedUDPMux) RemoveConnByUfrag(ufrag string) {
+	for _, mux := range m.muxes {
+		mux.RemoveConnByUfrag(ufrag)
+	}
+}
+
+func (m *shardedUDPMux) Close() error {
+	errs := []error{}
+	for _, mux := range m.muxes {
+		errs = append(errs, mux.Close())
+	}
+	for _, conn := range m.udpConns {
+		errs = append(errs, conn.Close())
+	}
+
+	return util.FlattenErrs(errs)
+}
+
+// udpMuxBufferSizer is implemented by the UDPMuxes that own their sockets
+type udpMuxBufferSizer interface {
+	setBufferSizes(receiveBufferSize, sendBufferSize int) error
+}
+
+// setBufferSizes sets the buffer sizes of all sockets, a size of zero is left unchanged
+func (m *shardedUDPMux) setBufferSizes(receiveBufferSize, sendBufferSize int) error {
+	errs := []error{}
+	for _, conn := range m.udpConns {
+		if receiveBufferSize > 0 {
+			errs = append(errs, conn.SetReadBuffer(receiveBufferSize))
+		}
+		if sendBufferSize > 0 {
+			errs = append(errs, conn.SetWriteBuffer(sendBufferSize))
+		}
+	}
+
+	return util.FlattenErrs(errs)
+}
+
+type udpPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+// shardedUDPConn merges the PacketConns of a ufrag of all shards. Packets are sent
+// on the socket a remote address was received on last, the kernel delivers the
+// packets of a remote address to the same socket.
+type shardedUDPConn struct {
+	conns []net.PacketConn
+
+	packets      chan udpPacket
+	readDeadline *deadline.Deadline
+
+	mu          sync.Mutex
+	shardByAddr map[string]int
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+func newShardedUDPConn(conns []net.PacketConn) *shardedUDPConn {
+	c := &shardedUDPConn{
+		conns:        conns,
+		packets:      make(chan udpPacket),
+		readDeadline: deadline.New(),
+		shardByAddr:  map[string]int{},
+		closed:       make(chan struct{}),
+	}
+
+	for i := range conns {
+		go c.readLoop(i)
+	}
+	return c
+}
+
+func (c *shardedUDPConn) readLoop(shard int) {
+	for {
+		b := make([]byte, receiveMTU)
+		n, addr, err := c.conns[shard].ReadFrom(b)
+		if errors.Is(err, io.ErrShortBuffer) {
+			continue
+		} else if err != nil {
+			c.closeWithError(err)
+			return
+		}
+
+		c.mu.Lock()
+		c.shardByAddr[addr.String()] = shard
+		c.mu.Unlock()
+
+		select {
+		case c.packets <- udpPacket{data: b[:n], addr: addr}:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *shardedUDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case p := <-c.packets:
+		if len(b) < len(p.data) {
+			return 0, nil, io.ErrShortBuffer
+		}
+		return copy(b, p.data), p.addr, nil
+	case <-c.readDeadline.Done():
+		return 0, nil, c.readDeadline.Err()
+	case <-c.closed:
+		return 0, nil, c.err
+	}
+}
+
+func (c *shardedUDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	shard := c.shardByAddr[addr.String()]
+	c.mu.Unlock()
+
+	return c.conns[shard].WriteTo(b, addr)
+}
+
+func (c *shardedUDPConn) closeWithError(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.closed)
+
+		for _, conn := range c.conns {
+			_ = conn.Close()
+		}
+	})
+}
+
+func (c *shardedUDPConn) Close() error {
+	c.closeWithError(io.ErrClosedPipe)
+	return nil
+}
+
+func (c *shardedUDPConn) LocalAddr() net.Addr {
+	return c.conns[0].LocalAddr()
+}
+
+func (c *shardedUDPConn) SetDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *shardedUDPConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+func (c *shardedUDPConn) SetWriteDeadline(t time.Time) error {
+	errs := []error{}
+	for _, conn := range c.conns {
+		errs = append(errs, conn.SetWriteDeadline(t))
+	}
+	return util.FlattenErrs(errs)
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/pion/ice/v2"
 )
 
+var errICEServerTURNUnsupported = errors.New("TURN is not currently supported in the JavaScript/Wasm bindings")
+
 // ICEServer describes a single STUN and TURN server that can be used by
 // the ICEAgent to establish a connection with a peer.
 type ICEServer struct {
@@ -32,7 +34,7 @@ func (s ICEServer) validate() ([]*ice.URL, error) {
 		}
 
 		if url.Scheme == ice.SchemeTypeTURN || url.Scheme == ice.SchemeTypeTURNS {
-			return nil, errors.New("TURN is not currently supported in the JavaScript/Wasm bindings")
+			return nil, errICEServerTURNUnsupported
 		}
 
 		urls = append(urls, url)
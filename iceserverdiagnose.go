@@ -0,0 +1,139 @@
+// +build !js
+
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
+	"github.com/pion/transport/vnet"
+)
+
+// iceServerDiagnosisTimeout bounds the STUN transactions used to find out why a server
+// didn't provide a candidate
+const iceServerDiagnosisTimeout = 3 * time.Second
+
+// protocolNumberUDP is the value of the REQUESTED-TRANSPORT attribute for UDP, RFC 5766
+const protocolNumberUDP = 17
+
+// diagnoseICEServer repeats what the agent asked the server for, and returns the error
+// the server responded with. Nil is returned if the server works. For a TURN server
+// the allocation that is made is released right away.
+func diagnoseICEServer(ctx context.Context, n *vnet.Net, url *ice.URL) *ICECandidateError {
+	if n == nil {
+		n = vnet.NewNet(nil)
+	}
+
+	// vnet only has UDP
+	if isStreamICEServer(url) && n.IsVirtual() {
+		return nil
+	}
+
+	candidateErr := &ICECandidateError{URL: url.String()}
+	deadline := time.Now().Add(iceServerDiagnosisTimeout)
+	conn, err := dialICEServer(n, url, deadline)
+	if err != nil {
+		candidateErr.ErrorCode = ICECandidateErrorCodeUnreachable
+		candidateErr.ErrorText = err.Error()
+		return candidateErr
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if host, port, splitErr := net.SplitHostPort(conn.LocalAddr().String()); splitErr == nil {
+		candidateErr.Address = host
+		if p, parseErr := strconv.ParseUint(port, 10, 16); parseErr == nil {
+			candidateErr.Port = uint16(p)
+		}
+	}
+
+	transaction := func(setters ...stun.Setter) (*stun.Message, error) {
+		request, buildErr := stun.Build(append([]stun.Setter{stun.TransactionID}, setters...)...)
+		if buildErr != nil {
+			return nil, buildErr
+		}
+		return stunTransaction(ctx, conn, isStreamICEServer(url), request, deadline)
+	}
+
+	var response *stun.Message
+	if url.Scheme == ice.SchemeTypeTURN || url.Scheme == ice.SchemeTypeTURNS {
+		response, err = allocateAndRelease(url, transaction)
+	} else {
+		response, err = transaction(stun.BindingRequest, stun.Fingerprint)
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return nil
+	case err != nil:
+		candidateErr.ErrorCode = ICECandidateErrorCodeUnreachable
+		candidateErr.ErrorText = err.Error()
+	case response.Type.Class == stun.ClassErrorResponse:
+		var code stun.ErrorCodeAttribute
+		if codeErr := code.GetFrom(response); codeErr != nil {
+			candidateErr.ErrorCode = int(stun.CodeServerError)
+			candidateErr.ErrorText = codeErr.Error()
+		} else {
+			candidateErr.ErrorCode = int(code.Code)
+			candidateErr.ErrorText = string(code.Reason)
+		}
+	default:
+		return nil
+	}
+	return candidateErr
+}
+
+// allocateAndRelease makes an authenticated Allocate request like the TURN client of the
+// agent does, and returns the last response. An allocation that succeeded is released.
+func allocateAndRelease(url *ice.URL, transaction func(...stun.Setter) (*stun.Message, error)) (*stun.Message, error) {
+	allocate := stun.NewType(stun.MethodAllocate, stun.ClassRequest)
+	requestedTransport := stun.RawAttribute{Type: stun.AttrRequestedTransport, Value: []byte{protocolNumberUDP, 0, 0, 0}}
+
+	response, err := transaction(allocate, requestedTransport, stun.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := []stun.Setter{}
+	if response.Type.Class == stun.ClassErrorResponse {
+		var code stun.ErrorCodeAttribute
+		if code.GetFrom(response) != nil || code.Code != stun.CodeUnauthorized {
+			return response, nil
+		}
+
+		var realm stun.Realm
+		var nonce stun.Nonce
+		if realm.GetFrom(response) != nil || nonce.GetFrom(response) != nil {
+			return nil, errICEServerNoRealm
+		}
+
+		// A stale nonce is retried once with the nonce of the response
+		for i := 0; i < 2; i++ {
+			auth = []stun.Setter{
+				stun.NewUsername(url.Username), realm, nonce,
+				stun.NewLongTermIntegrity(url.Username, realm.String(), url.Password),
+			}
+			response, err = transaction(append(append([]stun.Setter{allocate, requestedTransport}, auth...), stun.Fingerprint)...)
+			if err != nil {
+				return nil, err
+			}
+			if response.Type.Class == stun.ClassSuccessResponse ||
+				code.GetFrom(response) != nil || code.Code != stun.CodeStaleNonce || nonce.GetFrom(response) != nil {
+				break
+			}
+		}
+		if response.Type.Class != stun.ClassSuccessResponse {
+			return response, nil
+		}
+	}
+
+	// The allocation times out by itself if the release fails
+	lifetime := stun.RawAttribute{Type: stun.AttrLifetime, Value: []byte{0, 0, 0, 0}}
+	refresh := stun.NewType(stun.MethodRefresh, stun.ClassRequest)
+	_, _ = transaction(append(append([]stun.Setter{refresh, lifetime}, auth...), stun.Fingerprint)...)
+	return response, nil
+}
@@ -0,0 +1,161 @@
+// +build !js
+
+package webrtc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
+	"github.com/pion/transport/vnet"
+)
+
+// iceServerProbeInterval is how often a probe is retransmitted over UDP
+const iceServerProbeInterval = 250 * time.Millisecond
+
+// probeICEServers sends a STUN Binding request to every server at the same time, TURN
+// servers answer them too. The servers that answered within the timeout are returned,
+// the others are left out with the reason.
+func probeICEServers(n *vnet.Net, urls []*ice.URL, timeout time.Duration) ([]*ice.URL, []ICEGatheringError) {
+	if n == nil {
+		n = vnet.NewNet(nil)
+	}
+
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i := range urls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = probeICEServer(n, urls[i], timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	alive := []*ice.URL{}
+	var gatheringErrs []ICEGatheringError
+	for i, url := range urls {
+		if errs[i] != nil {
+			gatheringErrs = append(gatheringErrs, ICEGatheringError{URL: url.String(), Err: errs[i]})
+			continue
+		}
+		alive = append(alive, url)
+	}
+	return alive, gatheringErrs
+}
+
+func probeICEServer(n *vnet.Net, url *ice.URL, timeout time.Duration) error {
+	// vnet only has UDP
+	if isStreamICEServer(url) && n.IsVirtual() {
+		return nil
+	}
+
+	request, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+
+	conn, err := dialICEServer(n, url, deadline)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	// An error response shows that the server is up as well
+	response, err := stunTransaction(context.Background(), conn, isStreamICEServer(url), request, deadline)
+	if err != nil {
+		return err
+	}
+	if response.Type.Method != stun.MethodBinding {
+		return fmt.Errorf("%w: %s", errICEServerInvalidResponse, response.Type)
+	}
+	return nil
+}
+
+// isStreamICEServer returns true if the server is reached over TCP, or TLS for stuns and turns
+func isStreamICEServer(url *ice.URL) bool {
+	return url.Proto == ice.ProtoTypeTCP || url.Scheme == ice.SchemeTypeSTUNS || url.Scheme == ice.SchemeTypeTURNS
+}
+
+func dialICEServer(n *vnet.Net, url *ice.URL, deadline time.Time) (net.Conn, error) {
+	address := net.JoinHostPort(url.Host, strconv.Itoa(url.Port))
+	if !isStreamICEServer(url) {
+		return n.Dial("udp", address)
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	if url.Scheme == ice.SchemeTypeSTUNS || url.Scheme == ice.SchemeTypeTURNS {
+		return tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: url.Host}) //nolint:gosec
+	}
+	return dialer.Dial("tcp", address)
+}
+
+// stunTransaction sends the request and waits for the response with the same transaction ID.
+// Unless the conn is a stream the request is retransmitted until the response arrives. The conn is closed
+// when the context is canceled.
+func stunTransaction(ctx context.Context, conn net.Conn, stream bool, request *stun.Message, deadline time.Time) (*stun.Message, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(request.Raw); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		response *stun.Message
+		err      error
+	}
+	readResult := make(chan result, 1)
+	go func() {
+		response, err := readSTUNResponse(conn, request)
+		readResult <- result{response, err}
+	}()
+
+	var retransmit <-chan time.Time
+	if !stream {
+		ticker := time.NewTicker(iceServerProbeInterval)
+		defer ticker.Stop()
+		retransmit = ticker.C
+	}
+
+	for {
+		select {
+		case r := <-readResult:
+			return r.response, r.err
+		case <-ctx.Done():
+			_ = conn.Close()
+			<-readResult
+			return nil, ctx.Err()
+		case <-retransmit:
+			if _, err := conn.Write(request.Raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func readSTUNResponse(conn net.Conn, request *stun.Message) (*stun.Message, error) {
+	b := make([]byte, receiveMTU)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return nil, errICEServerNoResponse
+			}
+			return nil, err
+		}
+
+		response := &stun.Message{Raw: append([]byte{}, b[:n]...)}
+		if response.Decode() != nil || response.TransactionID != request.TransactionID {
+			continue
+		}
+		return response, nil
+	}
+}
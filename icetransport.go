@@ -5,9 +5,9 @@ package webrtc
 import (
 	"context"
 	"fmt"
+	"net"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/pion/ice/v2"
 	"github.com/pion/logging"
@@ -26,10 +26,19 @@ type ICETransport struct {
 
 	state atomic.Value // ICETransportState
 
+	// stateLock orders the states of the agent and the completed state, which is
+	// entered once gathering is complete as well
+	stateLock sync.Mutex
+
 	gatherer *ICEGatherer
 	conn     *ice.Conn
 	mux      *mux.Mux
 
+	// remoteCandidates are the transport addresses of the remote candidates passed
+	// to the agent, see SettingEngine.SetICEMaxRemoteCandidates
+	remoteCandidatesLock sync.Mutex
+	remoteCandidates     map[string]struct{}
+
 	ctx       context.Context
 	ctxCancel func()
 
@@ -98,13 +107,22 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 	}
 
 	if err := agent.OnConnectionStateChange(func(iceState ice.ConnectionState) {
-		state := newICETransportStateFromICE(iceState)
+		t.stateLock.Lock()
+		defer t.stateLock.Unlock()
 
+		state := newICETransportStateFromICE(iceState)
 		t.setState(state)
 		t.onConnectionStateChange(state)
+		t.checkCompleted()
 	}); err != nil {
 		return err
 	}
+	t.gatherer.onGatheringCompleteTransportHandler.Store(func() {
+		t.stateLock.Lock()
+		defer t.stateLock.Unlock()
+
+		t.checkCompleted()
+	})
 	if err := agent.OnSelectedCandidatePairChange(func(local, remote ice.Candidate) {
 		candidates, err := newICECandidatesFromICE([]ice.Candidate{local, remote})
 		if err != nil {
@@ -177,6 +195,11 @@ func (t *ICETransport) restart() error {
 	if err := agent.Restart(t.gatherer.api.settingEngine.candidates.UsernameFragment, t.gatherer.api.settingEngine.candidates.Password); err != nil {
 		return err
 	}
+
+	// The agent dropped the remote candidates
+	t.remoteCandidatesLock.Lock()
+	t.remoteCandidates = nil
+	t.remoteCandidatesLock.Unlock()
 	return t.gatherer.Gather()
 }
 
@@ -247,14 +270,20 @@ func (t *ICETransport) SetRemoteCandidates(remoteCandidates []ICECandidate) erro
 		return fmt.Errorf("%w: unable to set remote candidates", errICEAgentNotExist)
 	}
 
-	for _, c := range remoteCandidates {
-		i, err := c.toICE()
-		if err != nil {
-			return err
-		}
+	for _, remoteCandidate := range remoteCandidates {
+		for _, c := range t.withNAT64Candidate(remoteCandidate) {
+			if !t.acceptRemoteCandidate(c) {
+				continue
+			}
 
-		if err = agent.AddRemoteCandidate(i); err != nil {
-			return err
+			i, err := c.toICE()
+			if err != nil {
+				return err
+			}
+
+			if err = agent.AddRemoteCandidate(i); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -266,27 +295,77 @@ func (t *ICETransport) AddRemoteCandidate(remoteCandidate *ICECandidate) error {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	var (
-		c   ice.Candidate
-		err error
-	)
-
-	if err = t.ensureGatherer(); err != nil {
+	if err := t.ensureGatherer(); err != nil {
 		return err
 	}
 
-	if remoteCandidate != nil {
-		if c, err = remoteCandidate.toICE(); err != nil {
+	agent := t.gatherer.getAgent()
+	if agent == nil {
+		return fmt.Errorf("%w: unable to add remote candidates", errICEAgentNotExist)
+	}
+
+	// A nil candidate signals the end of remote candidates
+	if remoteCandidate == nil {
+		return agent.AddRemoteCandidate(nil)
+	}
+
+	for _, c := range t.withNAT64Candidate(*remoteCandidate) {
+		if !t.acceptRemoteCandidate(c) {
+			continue
+		}
+
+		i, err := c.toICE()
+		if err != nil {
+			return err
+		}
+
+		if err = agent.AddRemoteCandidate(i); err != nil {
 			return err
 		}
 	}
 
-	agent := t.gatherer.getAgent()
-	if agent == nil {
-		return fmt.Errorf("%w: unable to add remote candidates", errICEAgentNotExist)
+	return nil
+}
+
+// withNAT64Candidate returns the remote candidate, followed by a copy at the address
+// synthesized with the NAT64 prefix of the SettingEngine if the candidate is IPv4
+func (t *ICETransport) withNAT64Candidate(c ICECandidate) []ICECandidate {
+	prefix := t.gatherer.api.settingEngine.candidates.NAT64Prefix
+	if prefix == nil {
+		return []ICECandidate{c}
+	}
+
+	ip := net.ParseIP(c.Address)
+	if ip == nil || ip.To4() == nil {
+		return []ICECandidate{c}
+	}
+
+	synthesized := c
+	synthesized.Address = synthesizeNAT64Address(prefix, ip).String()
+	return []ICECandidate{c, synthesized}
+}
+
+// acceptRemoteCandidate reports whether the remote candidate should be passed to the
+// agent. Duplicates of a transport address and candidates beyond the limit are dropped.
+func (t *ICETransport) acceptRemoteCandidate(c ICECandidate) bool {
+	t.remoteCandidatesLock.Lock()
+	defer t.remoteCandidatesLock.Unlock()
+
+	key := fmt.Sprintf("%d %s %s %s:%d %s", c.Component, c.Protocol, c.Typ, c.Address, c.Port, c.TCPType)
+	if _, ok := t.remoteCandidates[key]; ok {
+		return false
 	}
 
-	return agent.AddRemoteCandidate(c)
+	if max := t.gatherer.api.settingEngine.candidates.MaxRemoteCandidates; max > 0 && len(t.remoteCandidates) >= max {
+		t.log.Warnf("Ignoring remote candidate %s:%d, the limit of %d remote candidates is reached", c.Address, c.Port, max)
+		return false
+	}
+
+	if t.remoteCandidates == nil {
+		t.remoteCandidates = map[string]struct{}{}
+	}
+	t.remoteCandidates[key] = struct{}{}
+	return true
 }
 
 // State returns the current ice transport state.
@@ -297,6 +376,18 @@ func (t *ICETransport) State() ICETransportState {
 	return ICETransportState(0)
 }
 
+// checkCompleted moves from connected to completed once the ICEGatherer is complete.
+// The agent doesn't look for better candidate pairs after it selected one, so only
+// local candidates gathered later could still change the connection.
+func (t *ICETransport) checkCompleted() {
+	if t.State() != ICETransportStateConnected || t.gatherer.State() != ICEGathererStateComplete {
+		return
+	}
+
+	t.setState(ICETransportStateCompleted)
+	t.onConnectionStateChange(ICETransportStateCompleted)
+}
+
 func (t *ICETransport) setState(i ICETransportState) {
 	t.state.Store(i)
 }
@@ -327,7 +418,7 @@ func (t *ICETransport) collectStats(collector *statsReportCollector) {
 	collector.Collecting()
 
 	stats := TransportStats{
-		Timestamp: statsTimestampFrom(time.Now()),
+		Timestamp: collector.timestamp,
 		Type:      StatsTypeTransport,
 		ID:        "iceTransport",
 	}
@@ -71,3 +71,37 @@ func TestICETransport_GetSelectedCandidatePair(t *testing.T) {
 
 	closePairNow(t, offerer, answerer)
 }
+
+func TestICETransport_MaxRemoteCandidates(t *testing.T) {
+	s := SettingEngine{}
+	s.SetICEMaxRemoteCandidates(2)
+	api := NewAPI(WithSettingEngine(s))
+
+	gatherer, err := api.NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+	transport := api.NewICETransport(gatherer)
+
+	candidate := func(port uint16, priority uint32) ICECandidate {
+		return ICECandidate{
+			Foundation: "1",
+			Priority:   priority,
+			Address:    "192.0.2.1",
+			Protocol:   ICEProtocolUDP,
+			Port:       port,
+			Typ:        ICECandidateTypeSrflx,
+			Component:  1,
+		}
+	}
+
+	assert.True(t, transport.acceptRemoteCandidate(candidate(5000, 100)))
+
+	// The same transport address with another priority is a duplicate
+	assert.False(t, transport.acceptRemoteCandidate(candidate(5000, 200)))
+
+	// The same foundation with another port is a distinct candidate
+	assert.True(t, transport.acceptRemoteCandidate(candidate(5001, 100)))
+
+	assert.False(t, transport.acceptRemoteCandidate(candidate(5002, 100)))
+
+	assert.NoError(t, gatherer.Close())
+}
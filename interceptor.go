@@ -9,6 +9,7 @@ import (
 	"github.com/pion/interceptor/pkg/nack"
 	"github.com/pion/interceptor/pkg/report"
 	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/rtcpxr"
 )
 
 // RegisterDefaultInterceptors will register some useful interceptors.
@@ -43,6 +44,25 @@ func ConfigureRTCPReports(interceptorRegistry *interceptor.Registry) error {
 	return nil
 }
 
+// ConfigureRTCPExtendedReports will setup everything necessary for sending RTCP Extended
+// Reports (XR) with the Sender and Receiver Reports. It has to be called before
+// ConfigureRTCPReports, the Extended Reports are added to the reports it generates.
+//
+// Receivers send a reference time with their Receiver Reports that senders echo in
+// DLRR blocks, which lets receive-only endpoints measure the round trip time. It is
+// reported as RoundTripTime of the RemoteOutboundRTPStreamStats. Receivers also send
+// VoIP metrics with the loss rate since the beginning of reception, that senders
+// report as FractionLost of the RemoteInboundRTPStreamStats.
+func ConfigureRTCPExtendedReports(interceptorRegistry *interceptor.Registry) error {
+	extendedReports, err := rtcpxr.NewInterceptor()
+	if err != nil {
+		return err
+	}
+
+	interceptorRegistry.Add(extendedReports)
+	return nil
+}
+
 // ConfigureNack will setup everything necessary for handling generating/responding to nack messages.
 func ConfigureNack(mediaEngine *MediaEngine, interceptorRegistry *interceptor.Registry) error {
 	generator, err := nack.NewGeneratorInterceptor()
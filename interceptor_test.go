@@ -11,6 +11,7 @@ import (
 
 	"github.com/pion/interceptor"
 	mock_interceptor "github.com/pion/interceptor/pkg/mock"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/transport/test"
 	"github.com/pion/webrtc/v3/pkg/media"
@@ -209,3 +210,78 @@ func Test_Interceptor_BindUnbind(t *testing.T) {
 		t.Errorf("CloseFn is expected to be called twice, but called %d times", cnt)
 	}
 }
+
+// E2E test of RTCP Extended Reports, the receiver measures the round trip time
+// and the sender gets its VoIP metrics
+func TestConfigureRTCPExtendedReports(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	createPC := func() *PeerConnection {
+		m := &MediaEngine{}
+		assert.NoError(t, m.RegisterDefaultCodecs())
+
+		ir := &interceptor.Registry{}
+		assert.NoError(t, ConfigureRTCPExtendedReports(ir))
+		assert.NoError(t, ConfigureRTCPReports(ir))
+
+		pc, err := NewAPI(WithMediaEngine(m), WithInterceptorRegistry(ir)).NewPeerConnection(Configuration{})
+		assert.NoError(t, err)
+		return pc
+	}
+
+	offerer := createPC()
+	answerer := createPC()
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: "video/vp8"}, "video", "pion")
+	assert.NoError(t, err)
+
+	sender, err := offerer.AddTrack(track)
+	assert.NoError(t, err)
+
+	readRTCP := func(read func() ([]rtcp.Packet, interceptor.Attributes, error)) {
+		for {
+			if _, _, readErr := read(); readErr != nil {
+				return
+			}
+		}
+	}
+	go readRTCP(sender.ReadRTCP)
+
+	answerer.OnTrack(func(track *TrackRemote, receiver *RTPReceiver) {
+		go readRTCP(receiver.ReadRTCP)
+		for {
+			if _, _, readErr := track.ReadRTP(); readErr != nil {
+				return
+			}
+		}
+	})
+
+	assert.NoError(t, signalPair(offerer, answerer))
+
+	var remoteInbound, remoteOutbound bool
+	ticker := time.NewTicker(time.Millisecond * 20)
+	defer ticker.Stop()
+	for !remoteInbound || !remoteOutbound {
+		<-ticker.C
+		assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Duration: time.Second}))
+
+		for _, s := range offerer.GetStats() {
+			if stats, ok := s.(RemoteInboundRTPStreamStats); ok {
+				assert.Equal(t, 0.0, stats.FractionLost)
+				remoteInbound = true
+			}
+		}
+		for _, s := range answerer.GetStats() {
+			if stats, ok := s.(RemoteOutboundRTPStreamStats); ok && stats.RoundTripTimeMeasurements != 0 {
+				assert.NotZero(t, stats.PacketsSent)
+				remoteOutbound = true
+			}
+		}
+	}
+
+	closePairNow(t, offerer, answerer)
+}
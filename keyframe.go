@@ -0,0 +1,23 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+// isKeyFramePacket reports whether the payload of a RTP packet starts a keyframe,
+// packets of codecs without keyframe detection never do
+func isKeyFramePacket(mimeType string, payload []byte) bool {
+	switch {
+	case strings.EqualFold(mimeType, MimeTypeVP8),
+		strings.EqualFold(mimeType, MimeTypeVP9),
+		strings.EqualFold(mimeType, MimeTypeH264):
+		isKeyFrame, err := media.IsKeyFramePacket(mimeType, payload)
+		return err == nil && isKeyFrame
+	default:
+		return false
+	}
+}
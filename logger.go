@@ -0,0 +1,214 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/logging"
+)
+
+// prefixLoggerFactory creates loggers that prepend a prefix to every message,
+// it is used to tell apart the logs of PeerConnections with a PeerConnectionID
+type prefixLoggerFactory struct {
+	prefix        string
+	loggerFactory logging.LoggerFactory
+}
+
+func (f *prefixLoggerFactory) NewLogger(scope string) logging.LeveledLogger {
+	return &prefixLogger{
+		prefix:       f.prefix,
+		formatPrefix: strings.ReplaceAll(f.prefix, "%", "%%"),
+		logger:       f.loggerFactory.NewLogger(scope),
+	}
+}
+
+type prefixLogger struct {
+	prefix       string
+	formatPrefix string
+	logger       logging.LeveledLogger
+}
+
+func (l *prefixLogger) Trace(msg string) { l.logger.Trace(l.prefix + msg) }
+func (l *prefixLogger) Tracef(format string, args ...interface{}) {
+	l.logger.Tracef(l.formatPrefix+format, args...)
+}
+
+func (l *prefixLogger) Debug(msg string) { l.logger.Debug(l.prefix + msg) }
+func (l *prefixLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.formatPrefix+format, args...)
+}
+
+func (l *prefixLogger) Info(msg string) { l.logger.Info(l.prefix + msg) }
+func (l *prefixLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.formatPrefix+format, args...)
+}
+
+func (l *prefixLogger) Warn(msg string) { l.logger.Warn(l.prefix + msg) }
+func (l *prefixLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf(l.formatPrefix+format, args...)
+}
+
+func (l *prefixLogger) Error(msg string) { l.logger.Error(l.prefix + msg) }
+func (l *prefixLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.formatPrefix+format, args...)
+}
+
+// logLevels holds the log levels set with SetLogLevel, they override the levels
+// of the loggers created by the LoggerFactory
+type logLevels struct {
+	mu     sync.Mutex
+	levels atomic.Value // map[string]logging.LogLevel
+}
+
+func (l *logLevels) set(scope string, level logging.LogLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	levels := map[string]logging.LogLevel{}
+	if current, ok := l.levels.Load().(map[string]logging.LogLevel); ok {
+		for s, v := range current {
+			levels[s] = v
+		}
+	}
+	levels[scope] = level
+	l.levels.Store(levels)
+}
+
+// clone returns logLevels that start with the levels of l and are changed independently.
+// l can be nil.
+func (l *logLevels) clone() *logLevels {
+	clone := &logLevels{}
+	if l == nil {
+		return clone
+	}
+	if levels, ok := l.levels.Load().(map[string]logging.LogLevel); ok {
+		// The maps are never changed once stored
+		clone.levels.Store(levels)
+	}
+	return clone
+}
+
+// get returns the level of the scope, or the level for all scopes if the scope has none
+func (l *logLevels) get(scope string) (logging.LogLevel, bool) {
+	levels, ok := l.levels.Load().(map[string]logging.LogLevel)
+	if !ok {
+		return logging.LogLevelDisabled, false
+	}
+	if level, ok := levels[scope]; ok {
+		return level, true
+	}
+	level, ok := levels[""]
+	return level, ok
+}
+
+// levelLoggerFactory creates loggers that filter messages by the levels set with SetLogLevel.
+// The levels of a LoggerFactory passed in the SettingEngine are left alone, so a message
+// has to pass both. Only the LoggerFactory created by NewAPI is made more verbose.
+type levelLoggerFactory struct {
+	levels           *logLevels
+	loggerFactory    logging.LoggerFactory
+	ownLoggerFactory bool
+}
+
+func (f *levelLoggerFactory) NewLogger(scope string) logging.LeveledLogger {
+	l := &levelLogger{
+		scope:  scope,
+		levels: f.levels,
+		logger: f.loggerFactory.NewLogger(scope),
+	}
+	if f.ownLoggerFactory {
+		l.unleveled = &atomicBool{}
+	}
+	return l
+}
+
+type levelLogger struct {
+	scope  string
+	levels *logLevels
+	logger logging.LeveledLogger
+
+	// unleveled is only set for loggers of the LoggerFactory created by NewAPI
+	unleveled *atomicBool
+}
+
+// enabled reports whether a message of level should be passed to the logger.
+// Without a level set with SetLogLevel the logger decides on its own. Once one is
+// set, loggers we created ourselves are made to accept all messages so the set
+// level takes effect.
+func (l *levelLogger) enabled(level logging.LogLevel) bool {
+	max, ok := l.levels.get(l.scope)
+	if !ok {
+		return true
+	}
+
+	if l.unleveled != nil && !l.unleveled.get() {
+		if logger, ok := l.logger.(interface{ SetLevel(logging.LogLevel) }); ok {
+			logger.SetLevel(logging.LogLevelTrace)
+		}
+		l.unleveled.set(true)
+	}
+	return level <= max
+}
+
+func (l *levelLogger) Trace(msg string) {
+	if l.enabled(logging.LogLevelTrace) {
+		l.logger.Trace(msg)
+	}
+}
+
+func (l *levelLogger) Tracef(format string, args ...interface{}) {
+	if l.enabled(logging.LogLevelTrace) {
+		l.logger.Tracef(format, args...)
+	}
+}
+
+func (l *levelLogger) Debug(msg string) {
+	if l.enabled(logging.LogLevelDebug) {
+		l.logger.Debug(msg)
+	}
+}
+
+func (l *levelLogger) Debugf(format string, args ...interface{}) {
+	if l.enabled(logging.LogLevelDebug) {
+		l.logger.Debugf(format, args...)
+	}
+}
+
+func (l *levelLogger) Info(msg string) {
+	if l.enabled(logging.LogLevelInfo) {
+		l.logger.Info(msg)
+	}
+}
+
+func (l *levelLogger) Infof(format string, args ...interface{}) {
+	if l.enabled(logging.LogLevelInfo) {
+		l.logger.Infof(format, args...)
+	}
+}
+
+func (l *levelLogger) Warn(msg string) {
+	if l.enabled(logging.LogLevelWarn) {
+		l.logger.Warn(msg)
+	}
+}
+
+func (l *levelLogger) Warnf(format string, args ...interface{}) {
+	if l.enabled(logging.LogLevelWarn) {
+		l.logger.Warnf(format, args...)
+	}
+}
+
+func (l *levelLogger) Error(msg string) {
+	if l.enabled(logging.LogLevelError) {
+		l.logger.Error(msg)
+	}
+}
+
+func (l *levelLogger) Errorf(format string, args ...interface{}) {
+	if l.enabled(logging.LogLevelError) {
+		l.logger.Errorf(format, args...)
+	}
+}
@@ -36,6 +36,23 @@ const (
 	// MimeTypePCMA PCMA MIME type
 	// Note: Matching should be case insensitive.
 	MimeTypePCMA = "audio/PCMA"
+	// MimeTypeRED RED MIME type for video, it is used to carry ULPFEC
+	// Note: Matching should be case insensitive.
+	MimeTypeRED = "video/red"
+	// MimeTypeAudioRED RED MIME type for audio, it carries the previous frames as redundancy.
+	// The fmtp line lists the payload type of the codec for every block, e.g. 111/111
+	// Note: Matching should be case insensitive.
+	MimeTypeAudioRED = "audio/red"
+	// MimeTypeULPFEC ULPFEC MIME type
+	// Note: Matching should be case insensitive.
+	MimeTypeULPFEC = "video/ulpfec"
+	// MimeTypeFlexFEC03 FlexFEC-03 MIME type
+	// Note: Matching should be case insensitive.
+	MimeTypeFlexFEC03 = "video/flexfec-03"
+	// MimeTypeTelephoneEvent telephone-event MIME type of RFC 4733, it carries DTMF tones.
+	// It has to use the clock rate of the audio codec it is sent with.
+	// Note: Matching should be case insensitive.
+	MimeTypeTelephoneEvent = "audio/telephone-event"
 )
 
 type mediaEngineHeaderExtension struct {
@@ -62,15 +79,17 @@ type MediaEngine struct {
 	mu sync.RWMutex
 }
 
+var defaultOpusCodec = RTPCodecParameters{
+	RTPCodecCapability: RTPCodecCapability{MimeTypeOpus, 48000, 2, "minptime=10;useinbandfec=1", nil},
+	PayloadType:        111,
+}
+
 // RegisterDefaultCodecs registers the default codecs supported by Pion WebRTC.
 // RegisterDefaultCodecs is not safe for concurrent use.
 func (m *MediaEngine) RegisterDefaultCodecs() error {
 	// Default Pion Audio Codecs
 	for _, codec := range []RTPCodecParameters{
-		{
-			RTPCodecCapability: RTPCodecCapability{MimeTypeOpus, 48000, 2, "minptime=10;useinbandfec=1", nil},
-			PayloadType:        111,
-		},
+		defaultOpusCodec,
 		{
 			RTPCodecCapability: RTPCodecCapability{MimeTypeG722, 8000, 0, "", nil},
 			PayloadType:        9,
@@ -173,7 +192,7 @@ func (m *MediaEngine) RegisterDefaultCodecs() error {
 		},
 
 		{
-			RTPCodecCapability: RTPCodecCapability{"video/ulpfec", 90000, 0, "", nil},
+			RTPCodecCapability: RTPCodecCapability{MimeTypeULPFEC, 90000, 0, "", nil},
 			PayloadType:        116,
 		},
 	} {
@@ -185,6 +204,14 @@ func (m *MediaEngine) RegisterDefaultCodecs() error {
 	return nil
 }
 
+// RegisterOpusCodec registers Opus as the only codec, with the parameters used by
+// RegisterDefaultCodecs. Offers of audio-only connections stay small this way, see
+// also SettingEngine.EnableFastSetup.
+// RegisterOpusCodec is not safe for concurrent use.
+func (m *MediaEngine) RegisterOpusCodec() error {
+	return m.RegisterCodec(defaultOpusCodec, RTPCodecTypeAudio)
+}
+
 // addCodec will append codec if it not exists
 func (m *MediaEngine) addCodec(codecs []RTPCodecParameters, codec RTPCodecParameters) []RTPCodecParameters {
 	for _, c := range codecs {
@@ -347,7 +374,7 @@ func (m *MediaEngine) collectStats(collector *statsReportCollector) {
 		for _, codec := range codecs {
 			collector.Collecting()
 			stats := CodecStats{
-				Timestamp:   statsTimestampFrom(time.Now()),
+				Timestamp:   collector.timestamp,
 				Type:        StatsTypeCodec,
 				ID:          codec.statsID,
 				PayloadType: codec.PayloadType,
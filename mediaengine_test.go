@@ -314,6 +314,47 @@ a=fmtp:97 apt=96
 		assert.NoError(t, err)
 	})
 
+	t.Run("Matches by codec parameters instead of payload type", func(t *testing.T) {
+		// H264 with another level and Opus with other preferences, as sent by Safari and Firefox
+		const offer = `v=0
+o=- 4596489990601351948 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+m=audio 9 UDP/TLS/RTP/SAVPF 109 101
+a=rtpmap:109 opus/48000/2
+a=fmtp:109 maxplaybackrate=48000;stereo=1;useinbandfec=0;minptime=20
+a=rtpmap:101 telephone-event/8000
+m=video 9 UDP/TLS/RTP/SAVPF 96 97
+a=rtpmap:96 H264/90000
+a=fmtp:96 packetization-mode=1;profile-level-id=42e034
+a=rtpmap:97 H264/90000
+a=fmtp:97 packetization-mode=1;profile-level-id=4d001f
+`
+		m := MediaEngine{}
+		assert.NoError(t, m.RegisterDefaultCodecs())
+		assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+			RTPCodecCapability: RTPCodecCapability{MimeTypeTelephoneEvent, 48000, 0, "", nil},
+			PayloadType:        110,
+		}, RTPCodecTypeAudio))
+		assert.NoError(t, m.updateFromRemoteDescription(mustParse(offer)))
+
+		opusCodec, _, err := m.getCodecByPayload(109)
+		assert.NoError(t, err)
+		assert.Equal(t, MimeTypeOpus, opusCodec.MimeType)
+
+		// The clock rate differs from the registered telephone-event
+		_, _, err = m.getCodecByPayload(101)
+		assert.ErrorIs(t, err, ErrCodecNotFound)
+
+		h264Codec, _, err := m.getCodecByPayload(96)
+		assert.NoError(t, err)
+		assert.Equal(t, MimeTypeH264, h264Codec.MimeType)
+
+		// The Main profile is only a partial match, exact matches are preferred
+		_, _, err = m.getCodecByPayload(97)
+		assert.ErrorIs(t, err, ErrCodecNotFound)
+	})
+
 	t.Run("Matches when rtx apt for partial match codec", func(t *testing.T) {
 		const profileLevels = `v=0
 o=- 4596489990601351948 2 IN IP4 127.0.0.1
@@ -0,0 +1,97 @@
+// +build !js
+
+package webrtc
+
+import (
+	"context"
+	"net"
+)
+
+// nat64WellKnownName is resolved by DNS64 servers to the IPv4 addresses below embedded in
+// the NAT64 prefix, RFC 7050
+const nat64WellKnownName = "ipv4only.arpa"
+
+var (
+	nat64WellKnownIPv4s = []net.IP{net.IPv4(192, 0, 0, 170), net.IPv4(192, 0, 0, 171)}
+
+	// nat64PrefixLengths are the prefix lengths that can embed an IPv4 address, RFC 6052 Section 2.2
+	nat64PrefixLengths = []int{96, 64, 56, 48, 40, 32}
+)
+
+// DiscoverNAT64Prefix discovers the prefix the NAT64 of an IPv6-only network translates
+// IPv4 addresses with, by resolving ipv4only.arpa through the DNS64 of the network as
+// described in RFC 7050. The prefix can be passed to SettingEngine.SetNAT64Prefix.
+// ErrNAT64PrefixNotFound is returned if the network has no DNS64.
+func DiscoverNAT64Prefix(ctx context.Context) (*net.IPNet, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, nat64WellKnownName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if prefix := nat64PrefixFromAddress(addr.IP); prefix != nil {
+			return prefix, nil
+		}
+	}
+	return nil, ErrNAT64PrefixNotFound
+}
+
+// nat64PrefixFromAddress returns the prefix of a synthesized address of ipv4only.arpa
+func nat64PrefixFromAddress(ip net.IP) *net.IPNet {
+	if ip.To4() != nil || ip.To16() == nil {
+		return nil
+	}
+
+	for _, length := range nat64PrefixLengths {
+		mask := net.CIDRMask(length, 128)
+		prefix := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+
+		for _, wellKnown := range nat64WellKnownIPv4s {
+			if synthesized := synthesizeNAT64Address(prefix, wellKnown); synthesized != nil && synthesized.Equal(ip) {
+				return prefix
+			}
+		}
+	}
+	return nil
+}
+
+// validNAT64Prefix reports whether an IPv4 address can be embedded in the prefix
+func validNAT64Prefix(prefix *net.IPNet) bool {
+	if prefix == nil || prefix.IP.To4() != nil || prefix.IP.To16() == nil {
+		return false
+	}
+
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 {
+		return false
+	}
+	for _, length := range nat64PrefixLengths {
+		if ones == length {
+			return true
+		}
+	}
+	return false
+}
+
+// synthesizeNAT64Address embeds an IPv4 address in the NAT64 prefix as described in
+// RFC 6052 Section 2.2, bits 64 to 71 of the address are left zero
+func synthesizeNAT64Address(prefix *net.IPNet, ip net.IP) net.IP {
+	ip4 := ip.To4()
+	if ip4 == nil || !validNAT64Prefix(prefix) {
+		return nil
+	}
+
+	ones, _ := prefix.Mask.Size()
+	synthesized := make(net.IP, net.IPv6len)
+	copy(synthesized, prefix.IP.To16().Mask(prefix.Mask))
+
+	i := ones / 8
+	for _, b := range ip4 {
+		if i == 8 {
+			i++
+		}
+		synthesized[i] = b
+		i++
+	}
+	return synthesized
+}
@@ -0,0 +1,80 @@
+// +build !js
+
+package webrtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynthesizeNAT64Address(t *testing.T) {
+	// Examples of RFC 6052 Section 2.4
+	for _, test := range []struct {
+		prefix      string
+		synthesized string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::192.0.2.33"},
+	} {
+		_, prefix, err := net.ParseCIDR(test.prefix)
+		assert.NoError(t, err)
+
+		synthesized := synthesizeNAT64Address(prefix, net.ParseIP("192.0.2.33"))
+		assert.True(t, net.ParseIP(test.synthesized).Equal(synthesized), "%s: %s", test.prefix, synthesized)
+		assert.Equal(t, prefix, nat64PrefixFromAddress(synthesizeNAT64Address(prefix, net.ParseIP("192.0.0.170"))))
+	}
+
+	_, prefix, err := net.ParseCIDR("64:ff9b::/96")
+	assert.NoError(t, err)
+	assert.Nil(t, synthesizeNAT64Address(prefix, net.ParseIP("2001:db8::1")))
+	assert.Nil(t, nat64PrefixFromAddress(net.ParseIP("64:ff9b::c000:221")))
+	assert.Equal(t, prefix, nat64PrefixFromAddress(net.ParseIP("64:ff9b::c000:ab")))
+}
+
+func TestSetNAT64Prefix(t *testing.T) {
+	s := SettingEngine{}
+
+	for _, invalid := range []string{"64:ff9b::/80", "10.0.0.0/8"} {
+		_, prefix, err := net.ParseCIDR(invalid)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, s.SetNAT64Prefix(prefix), errSettingEngineNAT64Prefix)
+	}
+
+	_, prefix, err := net.ParseCIDR("64:ff9b::/96")
+	assert.NoError(t, err)
+	assert.NoError(t, s.SetNAT64Prefix(prefix))
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{
+		ICEServers: []ICEServer{{URLs: []string{"stun:192.0.2.33:3478", "stun:stun.example.com:3478"}}},
+	})
+	assert.NoError(t, err)
+
+	// STUN servers with an IPv4 address are also contacted at their synthesized address
+	urls, gatheringErrs := gatherer.agentURLs(gatherer.validatedServers, gatherer.oauthServers)
+	assert.Empty(t, gatheringErrs)
+	assert.Len(t, urls, 3)
+	assert.Equal(t, "[64:ff9b::c000:221]", urls[2].Host)
+	assert.Equal(t, 3478, urls[2].Port)
+
+	// IPv4 remote candidates are also added at their synthesized address
+	transport := NewAPI(WithSettingEngine(s)).NewICETransport(gatherer)
+	candidates := transport.withNAT64Candidate(ICECandidate{Typ: ICECandidateTypeHost, Protocol: ICEProtocolUDP, Address: "192.0.2.33", Port: 1234})
+	assert.Len(t, candidates, 2)
+	assert.Equal(t, "192.0.2.33", candidates[0].Address)
+	assert.Equal(t, "64:ff9b::c000:221", candidates[1].Address)
+	assert.Equal(t, uint16(1234), candidates[1].Port)
+
+	assert.Len(t, transport.withNAT64Candidate(ICECandidate{Address: "2001:db8::1"}), 1)
+	assert.Len(t, transport.withNAT64Candidate(ICECandidate{Address: "a.local"}), 1)
+
+	assert.NoError(t, gatherer.Close())
+
+	assert.NoError(t, s.SetNAT64Prefix(nil))
+	assert.Nil(t, s.candidates.NAT64Prefix)
+}
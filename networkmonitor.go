@@ -0,0 +1,196 @@
+// +build !js
+
+package webrtc
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// networkMonitor polls the addresses of the local network interfaces, and calls
+// onChange when they changed. Interfaces can't be watched portably, polling works
+// on every platform and with vnet.
+type networkMonitor struct {
+	addresses func() ([]string, error)
+	onChange  func()
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// done is closed once run returned
+	done chan struct{}
+}
+
+func newNetworkMonitor(settingEngine *SettingEngine, interval time.Duration, onChange func()) *networkMonitor {
+	m := &networkMonitor{
+		addresses: func() ([]string, error) { return localAddresses(settingEngine) },
+		onChange:  onChange,
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go m.run(interval)
+	return m
+}
+
+func (m *networkMonitor) run(interval time.Duration) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// A failed lookup keeps the previous addresses, so a transient error
+	// doesn't look like a change
+	previous, _ := m.addresses()
+	for {
+		select {
+		case <-m.closed:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := m.addresses()
+		if err != nil || equalAddresses(previous, current) {
+			continue
+		}
+		previous = current
+
+		select {
+		case <-m.closed:
+			return
+		default:
+			m.onChange()
+		}
+	}
+}
+
+func (m *networkMonitor) stop() {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+}
+
+// apiNetworkMonitor runs one networkMonitor for all PeerConnections of an API that
+// use it, it is started with the first PeerConnection and stopped with the last
+type apiNetworkMonitor struct {
+	mu              sync.Mutex
+	monitor         *networkMonitor
+	peerConnections map[*PeerConnection]struct{}
+}
+
+func (a *apiNetworkMonitor) add(pc *PeerConnection, interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.peerConnections == nil {
+		a.peerConnections = map[*PeerConnection]struct{}{}
+	}
+	a.peerConnections[pc] = struct{}{}
+	if a.monitor == nil {
+		a.monitor = newNetworkMonitor(pc.api.settingEngine, interval, a.onChange)
+	}
+}
+
+// remove stops monitoring for pc, and returns the networkMonitor if it was stopped
+// because pc was the last PeerConnection, so the caller can wait for it to return
+func (a *apiNetworkMonitor) remove(pc *PeerConnection) *networkMonitor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.peerConnections[pc]; !ok {
+		return nil
+	}
+	delete(a.peerConnections, pc)
+	if len(a.peerConnections) != 0 {
+		return nil
+	}
+
+	m := a.monitor
+	a.monitor = nil
+	m.stop()
+	return m
+}
+
+func (a *apiNetworkMonitor) onChange() {
+	a.mu.Lock()
+	peerConnections := make([]*PeerConnection, 0, len(a.peerConnections))
+	for pc := range a.peerConnections {
+		peerConnections = append(peerConnections, pc)
+	}
+	a.mu.Unlock()
+
+	for _, pc := range peerConnections {
+		pc.onNetworkChange()
+	}
+}
+
+// localInterface is a network interface of the host, or of the vnet.Net
+type localInterface struct {
+	name  string
+	addrs []net.Addr
+}
+
+// localInterfaces returns the interfaces that are up and pass the InterfaceFilter of the
+// SettingEngine, loopback interfaces are skipped like the ICE agent does when gathering
+func localInterfaces(settingEngine *SettingEngine) ([]localInterface, error) {
+	var interfaces []localInterface
+	appendInterface := func(name string, flags net.Flags, addrs []net.Addr) {
+		if flags&net.FlagUp == 0 || flags&net.FlagLoopback != 0 {
+			return
+		}
+		if filter := settingEngine.candidates.InterfaceFilter; filter != nil && !filter(name) {
+			return
+		}
+		interfaces = append(interfaces, localInterface{name: name, addrs: addrs})
+	}
+
+	if settingEngine.vnet != nil && settingEngine.vnet.IsVirtual() {
+		ifaces, err := settingEngine.vnet.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, iface := range ifaces {
+			addrs, err := iface.Addrs()
+			if err != nil {
+				continue
+			}
+			appendInterface(iface.Name, iface.Flags, addrs)
+		}
+	} else {
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		for i := range ifaces {
+			addrs, err := ifaces[i].Addrs()
+			if err != nil {
+				continue
+			}
+			appendInterface(ifaces[i].Name, ifaces[i].Flags, addrs)
+		}
+	}
+	return interfaces, nil
+}
+
+// localAddresses returns the sorted addresses of the localInterfaces
+func localAddresses(settingEngine *SettingEngine) ([]string, error) {
+	interfaces, err := localInterfaces(settingEngine)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, iface := range interfaces {
+		for _, addr := range iface.addrs {
+			addresses = append(addresses, addr.String())
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+func equalAddresses(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
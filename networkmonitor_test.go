@@ -0,0 +1,150 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkMonitor(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	var mu sync.Mutex
+	addresses := []string{"192.168.1.2/24"}
+
+	changed := make(chan struct{}, 1)
+	m := &networkMonitor{
+		addresses: func() ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string{}, addresses...), nil
+		},
+		onChange: func() { changed <- struct{}{} },
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.run(10 * time.Millisecond)
+
+	select {
+	case <-changed:
+		t.Fatal("onChange called without a change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mu.Lock()
+	addresses = []string{"10.0.0.2/8"}
+	mu.Unlock()
+	<-changed
+
+	m.stop()
+	m.stop()
+}
+
+func TestPeerConnection_RestartICE(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	// Nothing to restart before the first offer
+	pcOffer.RestartICE()
+	assert.False(t, pcOffer.isICERestartPending.get())
+
+	negotiationNeeded := make(chan struct{}, 1)
+	pcOffer.OnNegotiationNeeded(func() {
+		select {
+		case negotiationNeeded <- struct{}{}:
+		default:
+		}
+	})
+
+	connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	connected.Wait()
+
+	select {
+	case <-negotiationNeeded:
+	default:
+	}
+
+	// Simulate the network monitor detecting a change
+	var networkChanged bool
+	pcOffer.OnNetworkChange(func() { networkChanged = true })
+	pcOffer.api.settingEngine.networkMonitor.RestartICE = true
+	pcOffer.onNetworkChange()
+	assert.True(t, networkChanged)
+	<-negotiationNeeded
+
+	ufrag := func(desc *SessionDescription) string {
+		for _, line := range strings.Split(desc.SDP, "\r\n") {
+			if strings.HasPrefix(line, "a=ice-ufrag:") {
+				return line
+			}
+		}
+		return ""
+	}
+	previousUfrag := ufrag(pcOffer.CurrentLocalDescription())
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, previousUfrag, ufrag(&offer))
+	assert.False(t, pcOffer.isICERestartPending.get())
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestNetworkMonitor_SharedByAPI(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.SetNetworkMonitor(time.Hour, false)
+	api := NewAPI(WithSettingEngine(s))
+
+	pcOffer, pcAnswer, err := api.newPair(Configuration{})
+	assert.NoError(t, err)
+
+	// Both PeerConnections are served by the same monitor
+	api.networkMonitor.mu.Lock()
+	monitor := api.networkMonitor.monitor
+	assert.NotNil(t, monitor)
+	assert.Len(t, api.networkMonitor.peerConnections, 2)
+	api.networkMonitor.mu.Unlock()
+
+	var changed sync.WaitGroup
+	changed.Add(2)
+	pcOffer.OnNetworkChange(changed.Done)
+	pcAnswer.OnNetworkChange(changed.Done)
+	api.networkMonitor.onChange()
+	changed.Wait()
+
+	// The monitor keeps running until the last PeerConnection is closed
+	assert.NoError(t, pcOffer.Close())
+	api.networkMonitor.mu.Lock()
+	assert.Equal(t, monitor, api.networkMonitor.monitor)
+	api.networkMonitor.mu.Unlock()
+
+	assert.NoError(t, pcAnswer.Close())
+	<-pcAnswer.Done()
+	<-monitor.done
+	api.networkMonitor.mu.Lock()
+	assert.Nil(t, api.networkMonitor.monitor)
+	api.networkMonitor.mu.Unlock()
+}
@@ -5,7 +5,14 @@ package webrtc
 type OfferAnswerOptions struct {
 	// VoiceActivityDetection allows the application to provide information
 	// about whether it wishes voice detection feature to be enabled or disabled.
+	// Voice activity detection is enabled by default and the codecs of the
+	// MediaEngine are used as they are, when set Opus is also asked to use DTX.
 	VoiceActivityDetection bool
+
+	// DisableVoiceActivityDetection disables voice activity detection, comfort
+	// noise codecs are removed from the audio media sections and Opus is asked
+	// not to use DTX. It takes precedence over VoiceActivityDetection.
+	DisableVoiceActivityDetection bool
 }
 
 // AnswerOptions structure describes the options used to control the answer
@@ -21,6 +28,7 @@ type OfferOptions struct {
 
 	// ICERestart forces the underlying ice gathering process to be restarted.
 	// When this value is true, the generated description will have ICE
-	// credentials that are different from the current credentials
+	// credentials that are different from the current credentials, unless
+	// they are set by SettingEngine.SetICECredentials. Those are used again.
 	ICERestart bool
 }
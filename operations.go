@@ -39,6 +39,16 @@ func (o *operations) Enqueue(op operation) {
 	}
 }
 
+// Run adds a new action to be executed and blocks until it has been executed
+func (o *operations) Run(op operation) {
+	done := make(chan struct{})
+	o.Enqueue(func() {
+		defer close(done)
+		op()
+	})
+	<-done
+}
+
 // IsEmpty checks if there are tasks in the queue
 func (o *operations) IsEmpty() bool {
 	o.mu.Lock()
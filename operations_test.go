@@ -2,6 +2,7 @@ package webrtc
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -29,3 +30,28 @@ func TestOperations_Done(t *testing.T) {
 	ops := newOperations()
 	ops.Done()
 }
+
+func TestOperations_Run(t *testing.T) {
+	ops := newOperations()
+
+	unblock := make(chan struct{})
+	ops.Enqueue(func() {
+		<-unblock
+	})
+
+	// Run waits for the operations enqueued before
+	ran := make(chan struct{})
+	go func() {
+		ops.Run(func() {})
+		close(ran)
+	}()
+
+	select {
+	case <-ran:
+		t.Fatal("Run returned before the previous operation finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(unblock)
+	<-ran
+}
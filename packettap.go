@@ -0,0 +1,72 @@
+// +build !js
+
+package webrtc
+
+import (
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// PacketTapDirection tells whether a packet passed to the packet tap has been
+// received or sent, see SettingEngine.SetPacketTap
+type PacketTapDirection int
+
+const (
+	// PacketTapDirectionInbound is used for packets received from the remote,
+	// after they have been decrypted
+	PacketTapDirectionInbound PacketTapDirection = iota + 1
+
+	// PacketTapDirectionOutbound is used for packets sent to the remote,
+	// before they are encrypted
+	PacketTapDirectionOutbound
+)
+
+// This is done this way because of a linter.
+const (
+	packetTapDirectionInboundStr  = "inbound"
+	packetTapDirectionOutboundStr = "outbound"
+)
+
+func (d PacketTapDirection) String() string {
+	switch d {
+	case PacketTapDirectionInbound:
+		return packetTapDirectionInboundStr
+	case PacketTapDirectionOutbound:
+		return packetTapDirectionOutboundStr
+	default:
+		return ErrUnknownType.Error()
+	}
+}
+
+// tapPacket passes a copy of an RTP or RTCP packet to the packet tap
+func (e *SettingEngine) tapPacket(direction PacketTapDirection, isRTCP bool, packet []byte) {
+	if e.packetTap != nil {
+		e.packetTap(direction, isRTCP, append([]byte{}, packet...))
+	}
+}
+
+// tapRTP passes an RTP packet that is written as header and payload to the packet tap
+func (e *SettingEngine) tapRTP(direction PacketTapDirection, header *rtp.Header, payload []byte) {
+	if e.packetTap == nil {
+		return
+	}
+
+	packet, err := header.Marshal()
+	if err != nil {
+		return
+	}
+	e.packetTap(direction, false, append(packet, payload...))
+}
+
+// tapRTCP passes RTCP packets that are sent as one compound packet to the packet tap
+func (e *SettingEngine) tapRTCP(direction PacketTapDirection, pkts []rtcp.Packet) {
+	if e.packetTap == nil {
+		return
+	}
+
+	packet, err := rtcp.Marshal(pkts)
+	if err != nil {
+		return
+	}
+	e.packetTap(direction, true, packet)
+}
@@ -0,0 +1,86 @@
+// +build !js
+
+package webrtc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketTap(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	type tapped struct {
+		direction PacketTapDirection
+		isRTCP    bool
+	}
+	var tappedMu sync.Mutex
+	seen := map[tapped]bool{}
+	allSeen := make(chan struct{})
+
+	s := SettingEngine{}
+	s.SetPacketTap(func(direction PacketTapDirection, isRTCP bool, packet []byte) {
+		// The packets are passed unencrypted
+		if isRTCP {
+			_, err := rtcp.Unmarshal(packet)
+			assert.NoError(t, err)
+		} else {
+			assert.NoError(t, (&rtp.Packet{}).Unmarshal(packet))
+		}
+
+		tappedMu.Lock()
+		defer tappedMu.Unlock()
+		if seen[tapped{direction, isRTCP}] || len(seen) == 4 {
+			return
+		}
+		seen[tapped{direction, isRTCP}] = true
+		if len(seen) == 4 {
+			close(allSeen)
+		}
+	})
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	api := NewAPI(WithSettingEngine(s), WithMediaEngine(m))
+
+	pcOffer, pcAnswer, err := api.newPair(Configuration{})
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	go func() {
+		for {
+			if _, _, readErr := sender.ReadRTCP(); readErr != nil {
+				return
+			}
+		}
+	}()
+
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, _ *RTPReceiver) {
+		for {
+			if _, _, readErr := trackRemote.ReadRTP(); readErr != nil {
+				return
+			}
+			if writeErr := pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(trackRemote.SSRC())}}); writeErr != nil {
+				return
+			}
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	sendVideoUntilDone(allSeen, t, []*TrackLocalStaticSample{track})
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
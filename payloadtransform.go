@@ -0,0 +1,28 @@
+// +build !js
+
+package webrtc
+
+import (
+	"github.com/pion/rtp"
+)
+
+// PayloadTransform transforms the payload of a RTP packet, e.g. to encrypt media end-to-end
+// so a SFU that forwards the packets only sees the RTP headers. It returns the new payload,
+// which may be longer or shorter than the original one, and must not modify the header.
+// Padding is removed before the payload is transformed.
+//
+// Unlike encoded insertable streams in browsers the transform is called for every RTP packet
+// and not for every frame, because Pion doesn't depacketize the media it forwards. A SFU that
+// parses payload headers, e.g. to detect VP8 keyframes, can't do so with transformed payloads.
+type PayloadTransform func(header *rtp.Header, payload []byte) ([]byte, error)
+
+// stripPadding returns the payload without the padding of the packet
+func stripPadding(header *rtp.Header, payload []byte) ([]byte, error) {
+	if !header.Padding {
+		return payload, nil
+	}
+	if len(payload) == 0 || int(payload[len(payload)-1]) > len(payload) {
+		return nil, errRTPPaddingInvalid
+	}
+	return payload[:len(payload)-int(payload[len(payload)-1])], nil
+}
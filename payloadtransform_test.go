@@ -0,0 +1,166 @@
+// +build !js
+
+package webrtc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestTagMissing = errors.New("tag missing")
+
+const testPayloadTag = 0xAA
+
+func xorPayload(payload []byte) []byte {
+	out := make([]byte, len(payload))
+	for i := range payload {
+		out[i] = payload[i] ^ 0xFF
+	}
+	return out
+}
+
+func TestPayloadTransform(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticRTP(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	sender, err := pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+	sender.SetPayloadTransform(func(header *rtp.Header, payload []byte) ([]byte, error) {
+		return append(xorPayload(payload), testPayloadTag), nil
+	})
+
+	payloads := make(chan []byte, 1)
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, receiver *RTPReceiver) {
+		receiver.SetPayloadTransform(func(header *rtp.Header, payload []byte) ([]byte, error) {
+			if len(payload) == 0 || payload[len(payload)-1] != testPayloadTag {
+				return nil, errTestTagMissing
+			}
+			return xorPayload(payload[:len(payload)-1]), nil
+		})
+
+		pkt, _, readErr := trackRemote.ReadRTP()
+		assert.NoError(t, readErr)
+		payloads <- pkt.Payload
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for sequenceNumber := uint16(0); ; sequenceNumber++ {
+			select {
+			case payload := <-payloads:
+				assert.Equal(t, []byte{0x01, 0x02, 0x03}, payload)
+				return
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteRTP(&rtp.Packet{
+					Header:  rtp.Header{Version: 2, SequenceNumber: sequenceNumber},
+					Payload: []byte{0x01, 0x02, 0x03},
+				}))
+			}
+		}
+	}()
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+// A transformed packet that doesn't fit into the buffer of Read is reported
+func TestPayloadTransform_ShortBuffer(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticRTP(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	readErrs := make(chan error, 1)
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, receiver *RTPReceiver) {
+		receiver.SetPayloadTransform(func(header *rtp.Header, payload []byte) ([]byte, error) {
+			return make([]byte, 100), nil
+		})
+
+		_, _, readErr := trackRemote.Read(make([]byte, 50))
+		readErrs <- readErr
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for sequenceNumber := uint16(0); ; sequenceNumber++ {
+			select {
+			case readErr := <-readErrs:
+				assert.ErrorIs(t, readErr, io.ErrShortBuffer)
+				return
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteRTP(&rtp.Packet{
+					Header:  rtp.Header{Version: 2, SequenceNumber: sequenceNumber},
+					Payload: []byte{0x01, 0x02, 0x03},
+				}))
+			}
+		}
+	}()
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestRTPReceiver_transformPayload(t *testing.T) {
+	r := &RTPReceiver{}
+
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, Padding: true, SequenceNumber: 5000},
+		Payload: []byte{0x01, 0x02, testPayloadTag, 0x00, 0x00, 0x03},
+	}
+	raw, err := packet.Marshal()
+	assert.NoError(t, err)
+
+	b := make([]byte, receiveMTU)
+	n := copy(b, raw)
+
+	// Without a transform the packet isn't changed
+	transformed, err := r.transformPayload(b, n)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, b[:transformed])
+
+	r.SetPayloadTransform(func(header *rtp.Header, payload []byte) ([]byte, error) {
+		assert.False(t, header.Padding)
+		if !bytes.HasSuffix(payload, []byte{testPayloadTag}) {
+			return nil, errTestTagMissing
+		}
+		return payload[:len(payload)-1], nil
+	})
+
+	transformed, err = r.transformPayload(b, n)
+	assert.NoError(t, err)
+
+	result := &rtp.Packet{}
+	assert.NoError(t, result.Unmarshal(b[:transformed]))
+	assert.False(t, result.Padding)
+	assert.Equal(t, uint16(5000), result.SequenceNumber)
+	assert.Equal(t, []byte{0x01, 0x02}, result.Payload)
+
+	// Transform errors are returned, TrackRemote.Read discards the packet
+	_, err = r.transformPayload(b, transformed)
+	assert.ErrorIs(t, err, errTestTagMissing)
+}
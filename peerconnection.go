@@ -3,12 +3,14 @@
 package webrtc
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,6 +29,12 @@ import (
 // PeerConnection represents a WebRTC connection that establishes a
 // peer-to-peer communications with another PeerConnection instance in a
 // browser, or to another endpoint implementing the required protocols.
+//
+// CreateOffer, CreateAnswer, SetLocalDescription, SetRemoteDescription and
+// AddICECandidate can be called from multiple goroutines, they are executed
+// one at a time in the order they have been called like the operations chain
+// of JSEP. The signaling state, connection state and media section rejected
+// handlers are called in the order of the events, one at a time.
 type PeerConnection struct {
 	statsID string
 	mu      sync.RWMutex
@@ -38,6 +46,24 @@ type PeerConnection struct {
 	// remote and local descriptions
 	ops *operations
 
+	// operationsChain executes the operations that are chained in JSEP, see
+	// https://w3c.github.io/webrtc-pc/#dfn-operations-chain
+	operationsChain *operations
+
+	// events calls the handlers of events that are dispatched in order
+	events *operations
+
+	// routines are the goroutines started for the remote media, Close waits
+	// for them before OnClose is called
+	routines sync.WaitGroup
+
+	// done is closed once the PeerConnection has been closed and all of its
+	// goroutines exited, see Done
+	done chan struct{}
+
+	// earlyMedia holds back the incoming media while a remote description is applied
+	earlyMedia *earlyMedia
+
 	configuration Configuration
 
 	currentLocalDescription  *SessionDescription
@@ -48,11 +74,16 @@ type PeerConnection struct {
 	iceConnectionState       atomic.Value // ICEConnectionState
 	connectionState          atomic.Value // PeerConnectionState
 
+	// stateLock is held while the signaling, ICE connection and connection states
+	// change, so that GetState sees them in agreement
+	stateLock sync.Mutex
+
 	idpLoginURL *string
 
 	isClosed               *atomicBool
 	isNegotiationNeeded    *atomicBool
 	negotiationNeededState negotiationNeededState
+	isICERestartPending    *atomicBool
 
 	lastOffer  string
 	lastAnswer string
@@ -70,7 +101,14 @@ type PeerConnection struct {
 	onConnectionStateChangeHandler    atomic.Value // func(PeerConnectionState)
 	onTrackHandler                    func(*TrackRemote, *RTPReceiver)
 	onDataChannelHandler              func(*DataChannel)
+	pendingDataChannels               []*DataChannel
+	drainingDataChannels              bool
 	onNegotiationNeededHandler        atomic.Value // func()
+	onMediaSectionRejectedHandler     atomic.Value // func(string, RTPCodecType)
+	onNetworkChangeHandler            atomic.Value // func()
+	onCloseHandler                    atomic.Value // func()
+
+	closeReason *CloseReason
 
 	iceGatherer   *ICEGatherer
 	iceTransport  *ICETransport
@@ -105,8 +143,29 @@ func NewPeerConnection(configuration Configuration) (*PeerConnection, error) {
 	return api.NewPeerConnection(configuration)
 }
 
-// NewPeerConnection creates a new PeerConnection with the provided configuration against the received API object
+// NewPeerConnection creates a new PeerConnection with the provided configuration against the received API object.
+// The API keeps track of the PeerConnection until it is closed, see API.GetStats.
 func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection, error) {
+	return api.newPeerConnection(configuration, nil)
+}
+
+// NewPeerConnectionWithICEGatherer creates a PeerConnection like NewPeerConnection that
+// uses an ICEGatherer created with NewICEGatherer. Gather can be called on the gatherer
+// before a call is set up, the PeerConnection then starts with the candidates gathered
+// so far instead of gathering in SetLocalDescription. The ICE servers and transport
+// policy of the gatherer are used, those of the Configuration only apply if it hasn't
+// started gathering. Candidates gathered before the PeerConnection existed aren't
+// passed to OnICECandidate, they are in the LocalDescription.
+//
+// A gatherer can only be used by one PeerConnection, which closes it when closed.
+func (api *API) NewPeerConnectionWithICEGatherer(configuration Configuration, gatherer *ICEGatherer) (*PeerConnection, error) {
+	if gatherer == nil {
+		return nil, &rtcerr.InvalidAccessError{Err: errICEGathererNil}
+	}
+	return api.newPeerConnection(configuration, gatherer)
+}
+
+func (api *API) newPeerConnection(configuration Configuration, gatherer *ICEGatherer) (*PeerConnection, error) {
 	// https://w3c.github.io/webrtc-pc/#constructor (Step #2)
 	// Some variables defined explicitly despite their implicit zero values to
 	// allow better readability to understand what is happening.
@@ -119,10 +178,16 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 			RTCPMuxPolicy:        RTCPMuxPolicyRequire,
 			Certificates:         []Certificate{},
 			ICECandidatePoolSize: 0,
+			SDPSemantics:         api.settingEngine.sdpSemantics,
 		},
 		ops:                    newOperations(),
+		operationsChain:        newOperations(),
+		events:                 newOperations(),
+		done:                   make(chan struct{}),
+		earlyMedia:             newEarlyMedia(),
 		isClosed:               &atomicBool{},
 		isNegotiationNeeded:    &atomicBool{},
+		isICERestartPending:    &atomicBool{},
 		negotiationNeededState: negotiationNeededStateEmpty,
 		lastOffer:              "",
 		lastAnswer:             "",
@@ -130,7 +195,6 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 		signalingState:         SignalingStateStable,
 
 		api: api,
-		log: api.settingEngine.LoggerFactory.NewLogger("pc"),
 	}
 	pc.iceConnectionState.Store(ICEConnectionStateNew)
 	pc.connectionState.Store(PeerConnectionStateNew)
@@ -140,16 +204,43 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 			settingEngine: api.settingEngine,
 			mediaEngine:   api.mediaEngine.copy(),
 			interceptor:   api.interceptor,
+
+			peerConnections: api.peerConnections,
+			networkMonitor:  api.networkMonitor,
+		}
+	}
+
+	// All transports get their loggers from the SettingEngine, so a copy with a
+	// prefixing LoggerFactory tags every log line of this PeerConnection
+	if configuration.PeerConnectionID != "" {
+		settingEngine := *pc.api.settingEngine
+		settingEngine.LoggerFactory = &prefixLoggerFactory{
+			prefix:        "[" + configuration.PeerConnectionID + "] ",
+			loggerFactory: settingEngine.LoggerFactory,
 		}
+		api := *pc.api
+		api.settingEngine = &settingEngine
+		pc.api = &api
 	}
+	pc.log = pc.api.settingEngine.LoggerFactory.NewLogger("pc")
 
 	var err error
 	if err = pc.initConfiguration(configuration); err != nil {
 		return nil, err
 	}
 
-	pc.iceGatherer, err = pc.createICEGatherer()
-	if err != nil {
+	if gatherer != nil {
+		if err = gatherer.attach(); err != nil {
+			return nil, err
+		}
+		// The configuration of a gatherer that started is kept
+		if (len(configuration.ICEServers) > 0 || configuration.ICETransportPolicy != ICETransportPolicy(Unknown)) && !gatherer.started() {
+			if _, err = gatherer.setConfiguration(pc.configuration.getICEServers(), pc.configuration.ICETransportPolicy); err != nil {
+				return nil, err
+			}
+		}
+		pc.iceGatherer = gatherer
+	} else if pc.iceGatherer, err = pc.createICEGatherer(); err != nil {
 		return nil, err
 	}
 
@@ -168,17 +259,16 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 	pc.sctpTransport = pc.api.NewSCTPTransport(pc.dtlsTransport)
 
 	// Wire up the on datachannel handler
-	pc.sctpTransport.OnDataChannel(func(d *DataChannel) {
-		pc.mu.RLock()
-		handler := pc.onDataChannelHandler
-		pc.mu.RUnlock()
-		if handler != nil {
-			handler(d)
-		}
-	})
+	pc.sctpTransport.OnDataChannel(pc.onDataChannel)
 
 	pc.interceptorRTCPWriter = api.interceptor.BindRTCPWriter(interceptor.RTCPWriterFunc(pc.writeRTCP))
 
+	if interval := api.settingEngine.networkMonitor.Interval; interval > 0 {
+		pc.api.networkMonitor.add(pc, interval)
+	}
+
+	api.peerConnections.add(pc)
+
 	return pc, nil
 }
 
@@ -192,6 +282,8 @@ func (pc *PeerConnection) initConfiguration(configuration Configuration) error {
 		pc.configuration.PeerIdentity = configuration.PeerIdentity
 	}
 
+	pc.configuration.PeerConnectionID = configuration.PeerConnectionID
+
 	// https://www.w3.org/TR/webrtc/#constructor (step #3)
 	if len(configuration.Certificates) > 0 {
 		now := time.Now()
@@ -204,7 +296,7 @@ func (pc *PeerConnection) initConfiguration(configuration Configuration) error {
 	} else {
 		sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 		if err != nil {
-			return &rtcerr.UnknownError{Err: err}
+			return &rtcerr.UnknownError{Err: wrapError(ErrFailedToGenerateCertificate, err)}
 		}
 		certificate, err := GenerateCertificate(sk)
 		if err != nil {
@@ -261,16 +353,71 @@ func (pc *PeerConnection) onSignalingStateChange(newState SignalingState) {
 
 	pc.log.Infof("signaling state changed to %s", newState)
 	if handler != nil {
-		go handler(newState)
+		pc.events.Enqueue(func() { handler(newState) })
 	}
 }
 
 // OnDataChannel sets an event handler which is invoked when a data
-// channel message arrives from a remote peer.
+// channel message arrives from a remote peer. DataChannels announced by
+// the remote before a handler was set are delivered to it in order, the
+// SettingEngine can limit how many are held.
 func (pc *PeerConnection) OnDataChannel(f func(*DataChannel)) {
 	pc.mu.Lock()
-	defer pc.mu.Unlock()
 	pc.onDataChannelHandler = f
+	drain := f != nil && len(pc.pendingDataChannels) != 0 && !pc.drainingDataChannels
+	if drain {
+		pc.drainingDataChannels = true
+	}
+	pc.mu.Unlock()
+
+	if drain {
+		go pc.drainDataChannels()
+	}
+}
+
+// onDataChannel delivers a DataChannel announced by the remote. It is held until
+// OnDataChannel is set, and until the ones held before it are delivered.
+func (pc *PeerConnection) onDataChannel(d *DataChannel) {
+	pc.mu.Lock()
+	handler := pc.onDataChannelHandler
+	if handler != nil && !pc.drainingDataChannels {
+		pc.mu.Unlock()
+		handler(d)
+		return
+	}
+
+	maxPending := int(pc.api.settingEngine.sctp.MaxPendingChannels)
+	full := maxPending != 0 && len(pc.pendingDataChannels) >= maxPending
+	if !full {
+		pc.pendingDataChannels = append(pc.pendingDataChannels, d)
+	}
+	pc.mu.Unlock()
+
+	if full {
+		pc.log.Warnf("Closing DataChannel %s, %d DataChannels are waiting for OnDataChannel", d.Label(), maxPending)
+		if err := d.Close(); err != nil {
+			pc.log.Warnf("Failed to close DataChannel %s: %v", d.Label(), err)
+		}
+	}
+}
+
+// drainDataChannels delivers the held DataChannels in order, DataChannels announced
+// meanwhile are delivered after them
+func (pc *PeerConnection) drainDataChannels() {
+	for {
+		pc.mu.Lock()
+		handler := pc.onDataChannelHandler
+		if handler == nil || len(pc.pendingDataChannels) == 0 {
+			pc.drainingDataChannels = false
+			pc.mu.Unlock()
+			return
+		}
+		d := pc.pendingDataChannels[0]
+		pc.pendingDataChannels = pc.pendingDataChannels[1:]
+		pc.mu.Unlock()
+
+		handler(d)
+	}
 }
 
 // OnNegotiationNeeded sets an event handler which is invoked when
@@ -279,6 +426,53 @@ func (pc *PeerConnection) OnNegotiationNeeded(f func()) {
 	pc.onNegotiationNeededHandler.Store(f)
 }
 
+// RestartICE requests an ICE restart, the next offer created restarts ICE like
+// OfferOptions.ICERestart does. OnNegotiationNeeded is fired to create that offer.
+// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-restartice
+func (pc *PeerConnection) RestartICE() {
+	// The first offer has fresh ICE credentials anyway
+	if pc.isClosed.get() || pc.CurrentLocalDescription() == nil {
+		return
+	}
+
+	pc.isICERestartPending.set(true)
+	pc.mu.Lock()
+	pc.onNegotiationNeeded()
+	pc.mu.Unlock()
+}
+
+// OnNetworkChange sets an event handler which is invoked when the addresses of the
+// local network interfaces changed, e.g. when a mobile device switched from WiFi to
+// LTE. The network is only monitored if enabled with SettingEngine.SetNetworkMonitor.
+func (pc *PeerConnection) OnNetworkChange(f func()) {
+	pc.onNetworkChangeHandler.Store(f)
+}
+
+// OnClose sets an event handler which is invoked once after the PeerConnection
+// has been closed. It is called after the handlers of all other events, once the
+// operations and goroutines of the PeerConnection have finished, so resources
+// used by the handlers can be released in it.
+func (pc *PeerConnection) OnClose(f func()) {
+	pc.onCloseHandler.Store(f)
+}
+
+func (pc *PeerConnection) onClose() {
+	if handler, ok := pc.onCloseHandler.Load().(func()); ok && handler != nil {
+		handler()
+	}
+}
+
+func (pc *PeerConnection) onNetworkChange() {
+	pc.log.Info("local network addresses changed")
+	if handler, ok := pc.onNetworkChangeHandler.Load().(func()); ok && handler != nil {
+		handler()
+	}
+
+	if pc.api.settingEngine.networkMonitor.RestartICE {
+		pc.RestartICE()
+	}
+}
+
 // onNegotiationNeeded enqueues negotiationNeededOp if necessary
 // caller of this method should hold `pc.mu` lock
 func (pc *PeerConnection) onNegotiationNeeded() {
@@ -360,6 +554,10 @@ func (pc *PeerConnection) checkNegotiationNeeded() bool { //nolint:gocognit
 		return true
 	}
 
+	if pc.isICERestartPending.get() {
+		return true
+	}
+
 	pc.sctpTransport.lock.Lock()
 	lenDataChannel := len(pc.sctpTransport.dataChannels)
 	pc.sctpTransport.lock.Unlock()
@@ -376,10 +574,10 @@ func (pc *PeerConnection) checkNegotiationNeeded() bool { //nolint:gocognit
 		// }
 		m := getByMid(t.Mid(), localDesc)
 		// Step 5.2
-		if !t.stopped && m == nil {
+		if !t.stopped.get() && m == nil {
 			return true
 		}
-		if !t.stopped && m != nil {
+		if !t.stopped.get() && m != nil {
 			// Step 5.3.1
 			if t.Direction() == RTPTransceiverDirectionSendrecv || t.Direction() == RTPTransceiverDirectionSendonly {
 				descMsid, okMsid := m.Attribute(sdp.AttrKeyMsid)
@@ -408,8 +606,11 @@ func (pc *PeerConnection) checkNegotiationNeeded() bool { //nolint:gocognit
 			}
 		}
 		// Step 5.4
-		if t.stopped && t.Mid() != "" {
-			if getByMid(t.Mid(), localDesc) != nil || getByMid(t.Mid(), remoteDesc) != nil {
+		if t.stopped.get() && t.Mid() != "" {
+			if lm := getByMid(t.Mid(), localDesc); lm != nil && !isRejectedMediaSection(lm) {
+				return true
+			}
+			if rm := getByMid(t.Mid(), remoteDesc); rm != nil && !isRejectedMediaSection(rm) {
 				return true
 			}
 		}
@@ -432,6 +633,25 @@ func (pc *PeerConnection) OnICEGatheringStateChange(f func(ICEGathererState)) {
 	pc.iceGatherer.OnStateChange(f)
 }
 
+// OnICEGatheringProgress sets an event handler which is invoked as gathering from
+// each network interface and ICE server progresses. See ICEGatherer.OnGatheringProgress
+func (pc *PeerConnection) OnICEGatheringProgress(f func(ICEGatheringProgress)) {
+	pc.iceGatherer.OnGatheringProgress(f)
+}
+
+// OnICEGatheringError sets an event handler which is invoked for every STUN
+// or TURN server that is left out of gathering. See ICEGatherer.OnGatheringError
+func (pc *PeerConnection) OnICEGatheringError(f func(ICEGatheringError)) {
+	pc.iceGatherer.OnGatheringError(f)
+}
+
+// OnICECandidateError sets an event handler which is invoked when a STUN or TURN
+// server fails to provide a candidate, e.g. when the TURN credentials are wrong.
+// See ICEGatherer.OnCandidateError
+func (pc *PeerConnection) OnICECandidateError(f func(ICECandidateError)) {
+	pc.iceGatherer.OnCandidateError(f)
+}
+
 // OnTrack sets an event handler which is called when remote track
 // arrives from a remote peer.
 func (pc *PeerConnection) OnTrack(f func(*TrackRemote, *RTPReceiver)) {
@@ -447,14 +667,41 @@ func (pc *PeerConnection) onTrack(t *TrackRemote, r *RTPReceiver) {
 
 	pc.log.Debugf("got new track: %+v", t)
 	if t != nil {
+		readByReceiver := r.trackReady(t)
 		if handler != nil {
 			go handler(t, r)
-		} else {
+		} else if !readByReceiver {
 			pc.log.Warnf("OnTrack unset, unable to handle incoming media streams")
 		}
 	}
 }
 
+// OnMediaSectionRejected sets an event handler which is called when the
+// RTPTransceiver of a media section is stopped during negotiation because a remote
+// offer rejected the media section. With SettingEngine.EnableMediaSectionRejection
+// it is also called for media sections without a common codec and for media
+// sections an answer rejected with a zero port.
+func (pc *PeerConnection) OnMediaSectionRejected(f func(mid string, kind RTPCodecType)) {
+	pc.onMediaSectionRejectedHandler.Store(f)
+}
+
+func (pc *PeerConnection) onMediaSectionRejected(mid string, kind RTPCodecType) {
+	pc.log.Infof("Media section rejected: mid %s, kind %s", mid, kind)
+	if handler, ok := pc.onMediaSectionRejectedHandler.Load().(func(string, RTPCodecType)); ok && handler != nil {
+		pc.events.Enqueue(func() { handler(mid, kind) })
+	}
+}
+
+// rejectMediaSection stops the RTPTransceiver associated with a rejected media section
+func (pc *PeerConnection) rejectMediaSection(t *RTPTransceiver, mid string) error {
+	if err := t.setStopped(); err != nil {
+		return err
+	}
+
+	pc.onMediaSectionRejected(mid, t.kind)
+	return nil
+}
+
 // OnICEConnectionStateChange sets an event handler which is called
 // when an ICE connection state is changed.
 func (pc *PeerConnection) OnICEConnectionStateChange(f func(ICEConnectionState)) {
@@ -462,7 +709,6 @@ func (pc *PeerConnection) OnICEConnectionStateChange(f func(ICEConnectionState))
 }
 
 func (pc *PeerConnection) onICEConnectionStateChange(cs ICEConnectionState) {
-	pc.iceConnectionState.Store(cs)
 	pc.log.Infof("ICE connection state changed: %s", cs)
 	if handler, ok := pc.onICEConnectionStateChangeHandler.Load().(func(ICEConnectionState)); ok && handler != nil {
 		handler(cs)
@@ -476,14 +722,19 @@ func (pc *PeerConnection) OnConnectionStateChange(f func(PeerConnectionState)) {
 }
 
 func (pc *PeerConnection) onConnectionStateChange(cs PeerConnectionState) {
-	pc.connectionState.Store(cs)
 	pc.log.Infof("peer connection state changed: %s", cs)
 	if handler, ok := pc.onConnectionStateChangeHandler.Load().(func(PeerConnectionState)); ok && handler != nil {
-		go handler(cs)
+		pc.events.Enqueue(func() { handler(cs) })
 	}
 }
 
 // SetConfiguration updates the configuration of this PeerConnection object.
+// The ICE servers and the ICE transport policy are used when the PeerConnection
+// starts gathering candidates. Once gathering started, e.g. after
+// SetLocalDescription or with an ICECandidatePoolSize, ICE servers are used
+// after the next ICE restart. The ICE agent can't be recreated then, so an
+// InvalidModificationError is returned for another number of ICE server URLs
+// or another ICE transport policy.
 func (pc *PeerConnection) SetConfiguration(configuration Configuration) error { //nolint:gocognit
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-setconfiguration (step #2)
 	if pc.isClosed.get() {
@@ -498,6 +749,10 @@ func (pc *PeerConnection) SetConfiguration(configuration Configuration) error {
 		pc.configuration.PeerIdentity = configuration.PeerIdentity
 	}
 
+	if configuration.PeerConnectionID != "" && configuration.PeerConnectionID != pc.configuration.PeerConnectionID {
+		return &rtcerr.InvalidModificationError{Err: ErrModifyingPeerConnectionID}
+	}
+
 	// https://www.w3.org/TR/webrtc/#set-the-configuration (step #4)
 	if len(configuration.Certificates) > 0 {
 		if len(configuration.Certificates) != len(pc.configuration.Certificates) {
@@ -538,8 +793,9 @@ func (pc *PeerConnection) SetConfiguration(configuration Configuration) error {
 	}
 
 	// https://www.w3.org/TR/webrtc/#set-the-configuration (step #8)
+	iceConfiguration := pc.configuration
 	if configuration.ICETransportPolicy != ICETransportPolicy(Unknown) {
-		pc.configuration.ICETransportPolicy = configuration.ICETransportPolicy
+		iceConfiguration.ICETransportPolicy = configuration.ICETransportPolicy
 	}
 
 	// https://www.w3.org/TR/webrtc/#set-the-configuration (step #11)
@@ -550,8 +806,20 @@ func (pc *PeerConnection) SetConfiguration(configuration Configuration) error {
 				return err
 			}
 		}
-		pc.configuration.ICEServers = configuration.ICEServers
+		iceConfiguration.ICEServers = configuration.ICEServers
 	}
+
+	if len(configuration.ICEServers) > 0 || configuration.ICETransportPolicy != ICETransportPolicy(Unknown) {
+		applied, err := pc.iceGatherer.setConfiguration(iceConfiguration.getICEServers(), iceConfiguration.ICETransportPolicy)
+		if err != nil {
+			return err
+		}
+		if !applied && len(configuration.ICEServers) > 0 {
+			pc.log.Infof("ICE servers changed after gathering started, they are used after the next ICE restart")
+		}
+	}
+	pc.configuration.ICETransportPolicy = iceConfiguration.ICETransportPolicy
+	pc.configuration.ICEServers = iceConfiguration.ICEServers
 	return nil
 }
 
@@ -579,6 +847,13 @@ func (pc *PeerConnection) hasLocalDescriptionChanged(desc *SessionDescription) b
 			return true
 		}
 
+		if t.stopped.get() {
+			if !isRejectedMediaSection(m) {
+				return true
+			}
+			continue
+		}
+
 		if getPeerDirection(m) != t.Direction() {
 			return true
 		}
@@ -590,7 +865,14 @@ var errExcessiveRetries = errors.New("excessive retries in CreateOffer")
 
 // CreateOffer starts the PeerConnection and generates the localDescription
 // https://w3c.github.io/webrtc-pc/#dom-rtcpeerconnection-createoffer
-func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription, error) { //nolint:gocognit
+func (pc *PeerConnection) CreateOffer(options *OfferOptions) (offer SessionDescription, err error) {
+	pc.operationsChain.Run(func() {
+		offer, err = pc.createOffer(options)
+	})
+	return
+}
+
+func (pc *PeerConnection) createOffer(options *OfferOptions) (SessionDescription, error) { //nolint:gocognit
 	useIdentity := pc.idpLoginURL != nil
 	switch {
 	case useIdentity:
@@ -599,17 +881,23 @@ func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
-	if options != nil && options.ICERestart {
+	// Before the agent exists there is nothing to restart, the first offer has new credentials
+	if ((options != nil && options.ICERestart) || pc.isICERestartPending.get()) && pc.iceGatherer.getAgent() != nil {
 		if err := pc.iceTransport.restart(); err != nil {
 			return SessionDescription{}, err
 		}
+		pc.isICERestartPending.set(false)
 	}
 
 	var (
 		d     *sdp.SessionDescription
 		offer SessionDescription
 		err   error
+		vad   voiceActivityDetection
 	)
+	if options != nil {
+		vad = voiceActivityDetectionOf(&options.OfferAnswerOptions)
+	}
 
 	// This may be necessary to recompute if, for example, createOffer was called when only an
 	// audio RTCRtpTransceiver was added to connection, but while performing the in-parallel
@@ -663,9 +951,9 @@ func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription
 		}
 
 		if pc.currentRemoteDescription == nil {
-			d, err = pc.generateUnmatchedSDP(currentTransceivers, useIdentity)
+			d, err = pc.generateUnmatchedSDP(currentTransceivers, useIdentity, vad)
 		} else {
-			d, err = pc.generateMatchedSDP(currentTransceivers, useIdentity, true /*includeUnmatched */, connectionRoleFromDtlsRole(defaultDtlsRoleOffer))
+			d, err = pc.generateMatchedSDP(currentTransceivers, useIdentity, true /*includeUnmatched */, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), vad)
 		}
 
 		if err != nil {
@@ -673,6 +961,11 @@ func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription
 		}
 
 		updateSDPOrigin(&pc.sdpOrigin, d)
+		if hook := pc.api.settingEngine.localSDPHook; hook != nil {
+			if err = hook(SDPTypeOffer, d); err != nil {
+				return SessionDescription{}, err
+			}
+		}
 		sdpBytes, err := d.Marshal()
 		if err != nil {
 			return SessionDescription{}, err
@@ -712,8 +1005,22 @@ func (pc *PeerConnection) createICEGatherer() (*ICEGatherer, error) {
 }
 
 // Update the PeerConnectionState given the state of relevant transports
+func (pc *PeerConnection) updateConnectionState() {
+	// The ICE connection state is read under the lock, so a concurrent change can't
+	// be overwritten with a connection state of the state before it
+	pc.stateLock.Lock()
+	connectionState, changed := pc.setConnectionState(pc.ICEConnectionState(), pc.dtlsTransport.State())
+	pc.stateLock.Unlock()
+
+	if changed {
+		pc.onConnectionStateChange(connectionState)
+	}
+}
+
+// setConnectionState stores the PeerConnectionState given the state of relevant transports
+// and tells if it has changed, the caller must hold the stateLock
 // https://www.w3.org/TR/webrtc/#rtcpeerconnectionstate-enum
-func (pc *PeerConnection) updateConnectionState(iceConnectionState ICEConnectionState, dtlsTransportState DTLSTransportState) {
+func (pc *PeerConnection) setConnectionState(iceConnectionState ICEConnectionState, dtlsTransportState DTLSTransportState) (PeerConnectionState, bool) {
 	connectionState := PeerConnectionStateNew
 	switch {
 	// The RTCPeerConnection object's [[IsClosed]] slot is true.
@@ -731,7 +1038,8 @@ func (pc *PeerConnection) updateConnectionState(iceConnectionState ICEConnection
 
 	// All RTCIceTransports and RTCDtlsTransports are in the "connected", "completed" or "closed"
 	// state and at least one of them is in the "connected" or "completed" state.
-	case iceConnectionState == ICEConnectionStateConnected && dtlsTransportState == DTLSTransportStateConnected:
+	case (iceConnectionState == ICEConnectionStateConnected || iceConnectionState == ICEConnectionStateCompleted) &&
+		dtlsTransportState == DTLSTransportStateConnected:
 		connectionState = PeerConnectionStateConnected
 
 	//  Any of the RTCIceTransports or RTCDtlsTransports are in the "connecting" or
@@ -741,10 +1049,11 @@ func (pc *PeerConnection) updateConnectionState(iceConnectionState ICEConnection
 	}
 
 	if pc.connectionState.Load() == connectionState {
-		return
+		return connectionState, false
 	}
 
-	pc.onConnectionStateChange(connectionState)
+	pc.connectionState.Store(connectionState)
+	return connectionState, true
 }
 
 func (pc *PeerConnection) createICETransport() *ICETransport {
@@ -770,15 +1079,30 @@ func (pc *PeerConnection) createICETransport() *ICETransport {
 			pc.log.Warnf("OnConnectionStateChange: unhandled ICE state: %s", state)
 			return
 		}
+
+		pc.stateLock.Lock()
+		pc.iceConnectionState.Store(cs)
+		connectionState, changed := pc.setConnectionState(cs, pc.dtlsTransport.State())
+		pc.stateLock.Unlock()
+
 		pc.onICEConnectionStateChange(cs)
-		pc.updateConnectionState(cs, pc.dtlsTransport.State())
+		if changed {
+			pc.onConnectionStateChange(connectionState)
+		}
 	})
 
 	return t
 }
 
 // CreateAnswer starts the PeerConnection and generates the localDescription
-func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (SessionDescription, error) {
+func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (answer SessionDescription, err error) {
+	pc.operationsChain.Run(func() {
+		answer, err = pc.createAnswer(options)
+	})
+	return
+}
+
+func (pc *PeerConnection) createAnswer(options *AnswerOptions) (SessionDescription, error) {
 	useIdentity := pc.idpLoginURL != nil
 	switch {
 	case pc.RemoteDescription() == nil:
@@ -798,12 +1122,21 @@ func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (SessionDescripti
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	d, err := pc.generateMatchedSDP(pc.rtpTransceivers, useIdentity, false /*includeUnmatched */, connectionRole)
+	var vad voiceActivityDetection
+	if options != nil {
+		vad = voiceActivityDetectionOf(&options.OfferAnswerOptions)
+	}
+	d, err := pc.generateMatchedSDP(pc.rtpTransceivers, useIdentity, false /*includeUnmatched */, connectionRole, vad)
 	if err != nil {
 		return SessionDescription{}, err
 	}
 
 	updateSDPOrigin(&pc.sdpOrigin, d)
+	if hook := pc.api.settingEngine.localSDPHook; hook != nil {
+		if err = hook(SDPTypeAnswer, d); err != nil {
+			return SessionDescription{}, err
+		}
+	}
 	sdpBytes, err := d.Marshal()
 	if err != nil {
 		return SessionDescription{}, err
@@ -921,8 +1254,10 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 	}()
 
 	if err == nil {
+		pc.stateLock.Lock()
 		pc.signalingState.Set(nextState)
-		if pc.signalingState.Get() == SignalingStateStable {
+		pc.stateLock.Unlock()
+		if nextState == SignalingStateStable {
 			pc.isNegotiationNeeded.set(false)
 			pc.mu.Lock()
 			pc.onNegotiationNeeded()
@@ -934,7 +1269,14 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 }
 
 // SetLocalDescription sets the SessionDescription of the local peer
-func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
+func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) (err error) {
+	pc.operationsChain.Run(func() {
+		err = pc.setLocalDescription(desc)
+	})
+	return
+}
+
+func (pc *PeerConnection) setLocalDescription(desc SessionDescription) error {
 	if pc.isClosed.get() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
@@ -962,8 +1304,11 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 	if err := pc.setDescription(&desc, stateChangeOpSetLocal); err != nil {
 		return err
 	}
+	if desc.Type == SDPTypeRollback {
+		pc.earlyMedia.openPath()
+	}
 
-	currentTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
+	currentTransceivers := pc.transceivers()
 
 	weAnswer := desc.Type == SDPTypeAnswer
 	remoteDesc := pc.RemoteDescription()
@@ -994,8 +1339,17 @@ func (pc *PeerConnection) LocalDescription() *SessionDescription {
 }
 
 // SetRemoteDescription sets the SessionDescription of the remote peer
+func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) (err error) {
+	pc.operationsChain.Run(func() {
+		if err = pc.setRemoteDescription(desc); err != nil {
+			pc.earlyMedia.openPath()
+		}
+	})
+	return
+}
+
 // nolint: gocyclo
-func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error { //nolint:gocognit
+func (pc *PeerConnection) setRemoteDescription(desc SessionDescription) error { //nolint:gocognit
 	if pc.isClosed.get() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
@@ -1005,19 +1359,61 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 	if _, err := desc.Unmarshal(); err != nil {
 		return err
 	}
+	// The hook and the quirks may repair the description, it is validated afterwards
+	if hook := pc.api.settingEngine.remoteSDPHook; hook != nil {
+		if err := hook(desc.Type, desc.parsed); err != nil {
+			return err
+		}
+		sdpBytes, err := desc.parsed.Marshal()
+		if err != nil {
+			return err
+		}
+		desc.SDP = string(sdpBytes)
+	}
+	if desc.Type != SDPTypeRollback && pc.api.settingEngine.sdpQuirksMode != SDPQuirksModeNever {
+		if quirks := applySDPQuirks(desc.parsed); len(quirks) != 0 {
+			pc.log.Infof("Rewriting SDP quirks of the remote description: %s", strings.Join(quirks, ", "))
+			sdpBytes, err := desc.parsed.Marshal()
+			if err != nil {
+				return err
+			}
+			desc.SDP = string(sdpBytes)
+		}
+	}
+	if pc.api.settingEngine.strictSDPValidation && desc.Type != SDPTypeRollback {
+		if err := validateRemoteDescription(desc.parsed); err != nil {
+			return err
+		}
+	}
+	if desc.Type != SDPTypeRollback {
+		if err := checkRTCPMux(desc.parsed); err != nil {
+			return err
+		}
+	}
 	if err := pc.setDescription(&desc, stateChangeOpSetRemote); err != nil {
 		return err
 	}
 
+	// The media path stays closed until the transceivers of the description are receiving
+	if desc.Type == SDPTypeRollback {
+		pc.earlyMedia.openPath()
+	} else {
+		pc.earlyMedia.closePath()
+	}
+
 	if err := pc.api.mediaEngine.updateFromRemoteDescription(*desc.parsed); err != nil {
 		return err
 	}
 
 	var t *RTPTransceiver
-	localTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
+	localTransceivers := pc.transceivers()
 	detectedPlanB := descriptionIsPlanB(pc.RemoteDescription())
 	weOffer := desc.Type == SDPTypeAnswer
 
+	if !detectedPlanB {
+		muteUnsentTracks(desc.parsed, localTransceivers)
+	}
+
 	if !weOffer && !detectedPlanB {
 		for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
 			midValue := getMidValue(media)
@@ -1029,6 +1425,16 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 				continue
 			}
 
+			// The remote stopped the transceiver of the media section
+			if isRejectedMediaSection(media) {
+				if t, localTransceivers = findByMid(midValue, localTransceivers); t != nil && !t.stopped.get() {
+					if err := pc.rejectMediaSection(t, midValue); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
 			kind := NewRTPCodecType(media.MediaName.Media)
 			direction := getPeerDirection(media)
 			if kind == 0 || direction == RTPTransceiverDirection(Unknown) {
@@ -1039,7 +1445,7 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 			if t == nil {
 				t, localTransceivers = satisfyTypeAndDirection(kind, direction, localTransceivers)
 			} else if direction == RTPTransceiverDirectionInactive {
-				if err := t.Stop(); err != nil {
+				if err := t.stop(); err != nil {
 					return err
 				}
 			}
@@ -1075,6 +1481,30 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 					return err
 				}
 			}
+
+			if len(t.getCodecs()) == 0 && pc.api.settingEngine.mediaSectionRejection {
+				if err := pc.rejectMediaSection(t, midValue); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if weOffer && !detectedPlanB && pc.api.settingEngine.mediaSectionRejection {
+		// Stop the transceivers of media sections the remote rejected in its answer
+		for _, media := range desc.parsed.MediaDescriptions {
+			if !isRejectedMediaSection(media) || media.MediaName.Media == mediaSectionApplication {
+				continue
+			}
+
+			midValue := getMidValue(media)
+			if t, _ = findByMid(midValue, localTransceivers); t == nil || t.Direction() == RTPTransceiverDirectionInactive {
+				continue
+			}
+
+			if err := pc.rejectMediaSection(t, midValue); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -1102,7 +1532,7 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 		}
 	}
 
-	currentTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
+	currentTransceivers := pc.transceivers()
 
 	if isRenegotation {
 		if weOffer {
@@ -1153,13 +1583,44 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 	return nil
 }
 
+// muteUnsentTracks mutes the remote tracks of the media sections the remote doesn't send anymore
+func muteUnsentTracks(desc *sdp.SessionDescription, transceivers []*RTPTransceiver) {
+	for _, media := range desc.MediaDescriptions {
+		direction := getPeerDirection(media)
+		if !isRejectedMediaSection(media) && direction != RTPTransceiverDirectionRecvonly && direction != RTPTransceiverDirectionInactive {
+			continue
+		}
+
+		mid := getMidValue(media)
+		if mid == "" {
+			continue
+		}
+		for _, t := range transceivers {
+			if t.Mid() != mid || t.Receiver() == nil {
+				continue
+			}
+			for _, track := range t.Receiver().Tracks() {
+				track.setMuted(true)
+			}
+		}
+	}
+}
+
 func (pc *PeerConnection) startReceiver(incoming trackDetails, receiver *RTPReceiver) {
 	encodings := []RTPDecodingParameters{}
 	if incoming.ssrc != 0 {
-		encodings = append(encodings, RTPDecodingParameters{RTPCodingParameters{SSRC: incoming.ssrc}})
+		encodings = append(encodings, RTPDecodingParameters{
+			RTPCodingParameters: RTPCodingParameters{SSRC: incoming.ssrc, RTX: RTPRtxParameters{SSRC: incoming.rtxSSRC}},
+			FEC:                 RTPFECParameters{SSRC: incoming.fecSSRC},
+		})
+	}
+	for _, layer := range incoming.simulcastLayers {
+		encodings = append(encodings, RTPDecodingParameters{
+			RTPCodingParameters: RTPCodingParameters{SSRC: layer.ssrc, RTX: RTPRtxParameters{SSRC: layer.rtxSSRC}},
+		})
 	}
 	for _, rid := range incoming.rids {
-		encodings = append(encodings, RTPDecodingParameters{RTPCodingParameters{RID: rid}})
+		encodings = append(encodings, RTPDecodingParameters{RTPCodingParameters: RTPCodingParameters{RID: rid}})
 	}
 
 	if err := receiver.Receive(RTPReceiveParameters{Encodings: encodings}); err != nil {
@@ -1173,6 +1634,7 @@ func (pc *PeerConnection) startReceiver(incoming trackDetails, receiver *RTPRece
 		receiver.tracks[i].track.mu.Lock()
 		receiver.tracks[i].track.id = incoming.id
 		receiver.tracks[i].track.streamID = incoming.streamID
+		receiver.tracks[i].track.early = pc.earlyMedia.take(receiver.tracks[i].track.ssrc)
 		receiver.tracks[i].track.mu.Unlock()
 	}
 
@@ -1181,21 +1643,25 @@ func (pc *PeerConnection) startReceiver(incoming trackDetails, receiver *RTPRece
 		return
 	}
 
-	go func() {
-		b := make([]byte, receiveMTU)
-		n, _, err := receiver.Track().peek(b)
-		if err != nil {
-			pc.log.Warnf("Could not determine PayloadType for SSRC %d (%s)", receiver.Track().SSRC(), err)
-			return
-		}
+	for _, track := range receiver.Tracks() {
+		pc.routines.Add(1)
+		go func(track *TrackRemote) {
+			defer pc.routines.Done()
 
-		if err = receiver.Track().checkAndUpdateTrack(b[:n]); err != nil {
-			pc.log.Warnf("Failed to set codec settings for track SSRC %d (%s)", receiver.Track().SSRC(), err)
-			return
-		}
+			payloadType, err := track.peek(make([]byte, receiveMTU))
+			if err != nil {
+				pc.log.Warnf("Could not determine PayloadType for SSRC %d (%s)", track.SSRC(), err)
+				return
+			}
 
-		pc.onTrack(receiver.Track(), receiver)
-	}()
+			if err = track.updatePayloadType(payloadType); err != nil {
+				pc.log.Warnf("Failed to set codec settings for track SSRC %d (%s)", track.SSRC(), err)
+				return
+			}
+
+			pc.onTrack(track, receiver)
+		}(track)
+	}
 }
 
 // startRTPReceivers opens knows inbound SRTP streams from the RemoteDescription
@@ -1220,11 +1686,15 @@ func (pc *PeerConnection) startRTPReceivers(incomingTracks []trackDetails, curre
 		incomingTrack := incomingTracks[i]
 
 		for _, t := range localTransceivers {
-			if (t.Receiver()) == nil || t.Receiver().Track() == nil || t.Receiver().Track().ssrc != incomingTrack.ssrc {
+			if (t.Receiver()) == nil {
 				continue
 			}
 
-			incomingTracks = filterTrackWithSSRC(incomingTracks, incomingTrack.ssrc)
+			for _, track := range t.Receiver().Tracks() {
+				if track.SSRC() == incomingTrack.ssrc {
+					incomingTracks = filterTrackWithSSRC(incomingTracks, incomingTrack.ssrc)
+				}
+			}
 		}
 	}
 
@@ -1273,7 +1743,7 @@ func (pc *PeerConnection) startRTPReceivers(incomingTracks []trackDetails, curre
 // startRTPSenders starts all outbound RTP streams
 func (pc *PeerConnection) startRTPSenders(currentTransceivers []*RTPTransceiver) error {
 	for _, transceiver := range currentTransceivers {
-		if transceiver.Sender() != nil && transceiver.Sender().isNegotiated() && !transceiver.Sender().hasSent() {
+		if transceiver.Sender() != nil && transceiver.Sender().isNegotiated() && !transceiver.Sender().hasSent() && !transceiver.Sender().hasStopped() {
 			err := transceiver.Sender().Send(transceiver.Sender().GetParameters())
 			if err != nil {
 				return err
@@ -1297,28 +1767,6 @@ func (pc *PeerConnection) startSCTP() {
 
 		return
 	}
-
-	// DataChannels that need to be opened now that SCTP is available
-	// make a copy we may have incoming DataChannels mutating this while we open
-	pc.sctpTransport.lock.RLock()
-	dataChannels := append([]*DataChannel{}, pc.sctpTransport.dataChannels...)
-	pc.sctpTransport.lock.RUnlock()
-
-	var openedDCCount uint32
-	for _, d := range dataChannels {
-		if d.ReadyState() == DataChannelStateConnecting {
-			err := d.open(pc.sctpTransport)
-			if err != nil {
-				pc.log.Warnf("failed to open data channel: %s", err)
-				continue
-			}
-			openedDCCount++
-		}
-	}
-
-	pc.sctpTransport.lock.Lock()
-	pc.sctpTransport.dataChannelsOpened += openedDCCount
-	pc.sctpTransport.lock.Unlock()
 }
 
 func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream io.Reader, ssrc SSRC) error { //nolint:gocognit
@@ -1360,17 +1808,21 @@ func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream io.Reader, ssrc SSRC) e
 	}
 
 	streamIDExtensionID, audioSupported, videoSupported := pc.api.mediaEngine.getHeaderExtensionID(RTPHeaderExtensionCapability{sdp.SDESRTPStreamIDURI})
-	if !audioSupported && !videoSupported {
-		return errPeerConnSimulcastStreamIDRTPExtensionRequired
-	}
+	streamIDSupported := audioSupported || videoSupported
 
 	b := make([]byte, receiveMTU)
 	var mid, rid string
+	var early [][]byte
+	waited := false
 	for readCount := 0; readCount <= simulcastProbeCount; readCount++ {
 		i, err := rtpStream.Read(b)
 		if err != nil {
 			return err
 		}
+		pc.api.settingEngine.tapPacket(PacketTapDirectionInbound, false, b[:i])
+		if pc.api.settingEngine.earlyMediaHandling == EarlyMediaHandlingBuffer {
+			early = append(early, append([]byte{}, b[:i]...))
+		}
 
 		maybeMid, maybeRid, payloadType, err := handleUnknownRTPPacket(b[:i], uint8(midExtensionID), uint8(streamIDExtensionID))
 		if err != nil {
@@ -1384,7 +1836,39 @@ func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream io.Reader, ssrc SSRC) e
 			rid = maybeRid
 		}
 
-		if mid == "" || rid == "" {
+		if mid == "" {
+			continue
+		}
+
+		media := mediaSectionForMid(remoteDescription.parsed, mid)
+		if media == nil && !waited {
+			// The remote may send media for a remote description that isn't applied yet
+			waited = true
+			if early, err = pc.waitForRemoteDescription(ssrc, early); err != nil {
+				return err
+			} else if pc.isReceivingSSRC(ssrc) {
+				return nil
+			}
+
+			remoteDescription = pc.RemoteDescription()
+			media = mediaSectionForMid(remoteDescription.parsed, mid)
+		}
+
+		// Media sections without simulcast carry a single track, the mid is enough to
+		// find it when the remote doesn't declare its SSRC
+		if media != nil && len(getRids(media)) == 0 {
+			pc.earlyMedia.store(ssrc, early)
+			err := pc.receiveUndeclaredSSRC(media, mid, ssrc)
+
+			// Drop the packets if no track took them
+			pc.earlyMedia.take(ssrc)
+			return err
+		}
+
+		if !streamIDSupported {
+			return errPeerConnSimulcastStreamIDRTPExtensionRequired
+		}
+		if rid == "" {
 			continue
 		}
 
@@ -1393,7 +1877,7 @@ func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream io.Reader, ssrc SSRC) e
 			return err
 		}
 
-		for _, t := range pc.GetTransceivers() {
+		for _, t := range pc.transceivers() {
 			if t.Mid() != mid || t.Receiver() == nil {
 				continue
 			}
@@ -1402,6 +1886,7 @@ func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream io.Reader, ssrc SSRC) e
 			if err != nil {
 				return err
 			}
+			track.setEarlyPackets(early)
 			pc.onTrack(track, t.Receiver())
 			return nil
 		}
@@ -1410,9 +1895,69 @@ func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream io.Reader, ssrc SSRC) e
 	return errPeerConnSimulcastIncomingSSRCFailed
 }
 
+// waitForRemoteDescription waits until the next remote description is applied, or for
+// earlyMediaTimeout. The packets read so far are stored meanwhile, a track of the next
+// remote description that receives the SSRC takes them. The packets that weren't taken
+// are returned, they are dropped if no remote description was applied in time.
+func (pc *PeerConnection) waitForRemoteDescription(ssrc SSRC, early [][]byte) ([][]byte, error) {
+	pc.earlyMedia.store(ssrc, early)
+
+	timer := time.NewTimer(earlyMediaTimeout)
+	defer timer.Stop()
+	select {
+	case <-pc.earlyMedia.waitNext():
+		early = pc.earlyMedia.take(ssrc)
+	case <-timer.C:
+		pc.earlyMedia.take(ssrc)
+		early = nil
+	}
+
+	if pc.isClosed.get() {
+		return nil, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+	return early, nil
+}
+
+// isReceivingSSRC returns true if a receiver of the PeerConnection receives the SSRC
+func (pc *PeerConnection) isReceivingSSRC(ssrc SSRC) bool {
+	for _, t := range pc.transceivers() {
+		if receiver := t.Receiver(); receiver != nil && receiver.receivesSSRC(ssrc) {
+			return true
+		}
+	}
+	return false
+}
+
+// receiveUndeclaredSSRC starts receiving a SSRC that isn't declared in the remote description
+// with the transceiver of the media section it has been sent for
+func (pc *PeerConnection) receiveUndeclaredSSRC(media *sdp.MediaDescription, mid string, ssrc SSRC) error {
+	for _, t := range pc.transceivers() {
+		if t.Mid() != mid || t.Receiver() == nil {
+			continue
+		}
+		if t.Receiver().haveReceived() {
+			return fmt.Errorf("%w: %s", errPeerConnUndeclaredSSRCMidReceiving, mid)
+		}
+
+		incoming := trackDetails{
+			mid:  mid,
+			kind: NewRTPCodecType(media.MediaName.Media),
+			ssrc: ssrc,
+		}
+		incoming.streamID, incoming.id = getMsid(media)
+		pc.startReceiver(incoming, t.Receiver())
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errPeerConnTranscieverMidNil, mid)
+}
+
 // undeclaredMediaProcessor handles RTP/RTCP packets that don't match any a:ssrc lines
 func (pc *PeerConnection) undeclaredMediaProcessor() {
+	pc.routines.Add(2)
 	go func() {
+		defer pc.routines.Done()
+
 		var simulcastRoutineCount uint64
 		for {
 			srtpSession, err := pc.dtlsTransport.getSRTPSession()
@@ -1440,18 +1985,28 @@ func (pc *PeerConnection) undeclaredMediaProcessor() {
 				continue
 			}
 
+			pc.routines.Add(1)
 			go func(rtpStream io.Reader, ssrc SSRC) {
+				defer pc.routines.Done()
+				defer atomic.AddUint64(&simulcastRoutineCount, ^uint64(0))
 				pc.dtlsTransport.storeSimulcastStream(stream)
 
+				// The SSRC may be declared by the remote description that is applied right now
+				<-pc.earlyMedia.waitOpen()
+				if pc.isClosed.get() || pc.isReceivingSSRC(ssrc) {
+					return
+				}
+
 				if err := pc.handleUndeclaredSSRC(rtpStream, ssrc); err != nil {
 					pc.log.Errorf("Incoming unhandled RTP ssrc(%d), OnTrack will not be fired. %v", ssrc, err)
 				}
-				atomic.AddUint64(&simulcastRoutineCount, ^uint64(0))
 			}(stream, SSRC(ssrc))
 		}
 	}()
 
 	go func() {
+		defer pc.routines.Done()
+
 		for {
 			srtcpSession, err := pc.dtlsTransport.getSRTCPSession()
 			if err != nil {
@@ -1485,7 +2040,14 @@ func (pc *PeerConnection) RemoteDescription() *SessionDescription {
 
 // AddICECandidate accepts an ICE candidate string and adds it
 // to the existing set of candidates.
-func (pc *PeerConnection) AddICECandidate(candidate ICECandidateInit) error {
+func (pc *PeerConnection) AddICECandidate(candidate ICECandidateInit) (err error) {
+	pc.operationsChain.Run(func() {
+		err = pc.addICECandidate(candidate)
+	})
+	return
+}
+
+func (pc *PeerConnection) addICECandidate(candidate ICECandidateInit) error {
 	if pc.RemoteDescription() == nil {
 		return &rtcerr.InvalidStateError{Err: ErrNoRemoteDescription}
 	}
@@ -1506,7 +2068,20 @@ func (pc *PeerConnection) AddICECandidate(candidate ICECandidateInit) error {
 		iceCandidate = &c
 	}
 
-	return pc.iceTransport.AddRemoteCandidate(iceCandidate)
+	if err := pc.iceTransport.AddRemoteCandidate(iceCandidate); err != nil {
+		return err
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.pendingRemoteDescription != nil {
+		pc.pendingRemoteDescription = addRemoteCandidate(pc.pendingRemoteDescription, candidate)
+	} else if pc.currentRemoteDescription != nil {
+		pc.currentRemoteDescription = addRemoteCandidate(pc.currentRemoteDescription, candidate)
+	}
+
+	return nil
 }
 
 // ICEConnectionState returns the ICE connection state of the
@@ -1515,12 +2090,13 @@ func (pc *PeerConnection) ICEConnectionState() ICEConnectionState {
 	return pc.iceConnectionState.Load().(ICEConnectionState)
 }
 
-// GetSenders returns the RTPSender that are currently attached to this PeerConnection
+// GetSenders returns the RTPSender that are currently attached to this PeerConnection,
+// in the order of GetTransceivers
 func (pc *PeerConnection) GetSenders() (result []*RTPSender) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	for _, transceiver := range pc.rtpTransceivers {
+	for _, transceiver := range pc.orderedTransceivers() {
 		if transceiver.Sender() != nil {
 			result = append(result, transceiver.Sender())
 		}
@@ -1528,12 +2104,13 @@ func (pc *PeerConnection) GetSenders() (result []*RTPSender) {
 	return result
 }
 
-// GetReceivers returns the RTPReceivers that are currently attached to this PeerConnection
+// GetReceivers returns the RTPReceivers that are currently attached to this PeerConnection,
+// in the order of GetTransceivers
 func (pc *PeerConnection) GetReceivers() (receivers []*RTPReceiver) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	for _, transceiver := range pc.rtpTransceivers {
+	for _, transceiver := range pc.orderedTransceivers() {
 		if transceiver.Receiver() != nil {
 			receivers = append(receivers, transceiver.Receiver())
 		}
@@ -1541,12 +2118,52 @@ func (pc *PeerConnection) GetReceivers() (receivers []*RTPReceiver) {
 	return
 }
 
-// GetTransceivers returns the RtpTransceiver that are currently attached to this PeerConnection
+// GetTransceivers returns the RtpTransceiver that are currently attached to this PeerConnection.
+// They are ordered like their media sections in the local description, transceivers without
+// one follow in the order they have been added. The slice is a snapshot, it can be iterated
+// while the PeerConnection is renegotiated and adds transceivers.
 func (pc *PeerConnection) GetTransceivers() []*RTPTransceiver {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	return pc.rtpTransceivers
+	return pc.orderedTransceivers()
+}
+
+// orderedTransceivers returns a copy of the transceivers in the order of GetTransceivers,
+// pc.mu must be held
+func (pc *PeerConnection) orderedTransceivers() []*RTPTransceiver {
+	transceivers := append([]*RTPTransceiver{}, pc.rtpTransceivers...)
+
+	desc := pc.pendingLocalDescription
+	if desc == nil {
+		desc = pc.currentLocalDescription
+	}
+	if desc == nil || desc.parsed == nil {
+		return transceivers
+	}
+
+	positions := map[string]int{}
+	for i, media := range desc.parsed.MediaDescriptions {
+		positions[getMidValue(media)] = i
+	}
+	position := func(t *RTPTransceiver) int {
+		if i, ok := positions[t.Mid()]; ok && t.Mid() != "" {
+			return i
+		}
+		return len(desc.parsed.MediaDescriptions)
+	}
+	sort.SliceStable(transceivers, func(i, j int) bool {
+		return position(transceivers[i]) < position(transceivers[j])
+	})
+	return transceivers
+}
+
+// transceivers returns a copy of the transceivers in the order they have been added
+func (pc *PeerConnection) transceivers() []*RTPTransceiver {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	return append([]*RTPTransceiver{}, pc.rtpTransceivers...)
 }
 
 // AddTrack adds a Track to the PeerConnection
@@ -1558,7 +2175,7 @@ func (pc *PeerConnection) AddTrack(track TrackLocal) (*RTPSender, error) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 	for _, t := range pc.rtpTransceivers {
-		if !t.stopped && t.kind == track.Kind() && t.Sender() == nil {
+		if !t.stopped.get() && t.kind == track.Kind() && t.Sender() == nil {
 			sender, err := pc.api.NewRTPSender(track, pc.dtlsTransport)
 			if err == nil {
 				err = t.SetSender(sender, track)
@@ -1583,7 +2200,9 @@ func (pc *PeerConnection) AddTrack(track TrackLocal) (*RTPSender, error) {
 	return transceiver.Sender(), nil
 }
 
-// RemoveTrack removes a Track from the PeerConnection
+// RemoveTrack removes a Track from the PeerConnection. The RTPSender stops sending
+// and the RTPTransceiver becomes recvonly or inactive, its media section is kept.
+// A later AddTrack of the same kind reuses the RTPTransceiver and its media section.
 func (pc *PeerConnection) RemoveTrack(sender *RTPSender) (err error) {
 	if pc.isClosed.get() {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
@@ -1600,11 +2219,18 @@ func (pc *PeerConnection) RemoveTrack(sender *RTPSender) (err error) {
 	}
 	if transceiver == nil {
 		return &rtcerr.InvalidAccessError{Err: ErrSenderNotCreatedByConnection}
-	} else if err = sender.Stop(); err == nil {
-		err = transceiver.setSendingTrack(nil)
-		if err == nil {
-			pc.onNegotiationNeeded()
-		}
+	} else if err = sender.Stop(); err != nil {
+		return err
+	}
+
+	// A stopped RTPTransceiver is inactive already, only the RTPSender is released
+	if transceiver.stopped.get() {
+		transceiver.setSender(nil)
+		return nil
+	}
+
+	if err = transceiver.setSendingTrack(nil); err == nil {
+		pc.onNegotiationNeeded()
 	}
 	return
 }
@@ -1688,6 +2314,10 @@ func (pc *PeerConnection) AddTransceiverFromTrack(track TrackLocal, init ...RTPT
 
 	t, err = pc.newTransceiverFromTrack(direction, track)
 	if err == nil {
+		if len(init) == 1 && len(init[0].SendEncodings) != 0 {
+			t.Sender().setSendEncoding(init[0].SendEncodings[0])
+		}
+
 		pc.mu.Lock()
 		pc.addRTPTransceiver(t)
 		pc.mu.Unlock()
@@ -1748,7 +2378,7 @@ func (pc *PeerConnection) CreateDataChannel(label string, options *DataChannelIn
 		}
 	}
 
-	d, err := pc.api.newDataChannel(params, pc.log)
+	d, err := pc.api.newDataChannel(params, pc.api.settingEngine.LoggerFactory.NewLogger("datachannel"))
 	if err != nil {
 		return nil, err
 	}
@@ -1759,6 +2389,10 @@ func (pc *PeerConnection) CreateDataChannel(label string, options *DataChannelIn
 	}
 
 	pc.sctpTransport.lock.Lock()
+	if pc.sctpTransport.isDataChannelLimitReached() {
+		pc.sctpTransport.lock.Unlock()
+		return nil, &rtcerr.OperationError{Err: ErrMaxDataChannels}
+	}
 	pc.sctpTransport.dataChannels = append(pc.sctpTransport.dataChannels, d)
 	pc.sctpTransport.dataChannelsRequested++
 	pc.sctpTransport.lock.Unlock()
@@ -1790,21 +2424,44 @@ func (pc *PeerConnection) WriteRTCP(pkts []rtcp.Packet) error {
 }
 
 func (pc *PeerConnection) writeRTCP(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
-	return pc.dtlsTransport.WriteRTCP(pkts)
+	n, err := pc.dtlsTransport.WriteRTCP(pkts)
+	if err != nil {
+		return n, err
+	}
+	pc.api.settingEngine.tapRTCP(PacketTapDirectionOutbound, pkts)
+
+	// Count the feedback sent for the stats of the receiving tracks
+	for _, pkt := range pkts {
+		switch pkt.(type) {
+		case *rtcp.FullIntraRequest, *rtcp.PictureLossIndication, *rtcp.TransportLayerNack:
+			for _, ssrc := range pkt.DestinationSSRC() {
+				if track := pc.dtlsTransport.remoteTrack(SSRC(ssrc)); track != nil {
+					track.handleFeedbackSent(pkt)
+				}
+			}
+		}
+	}
+	return n, nil
 }
 
-// Close ends the PeerConnection
+// Close ends the PeerConnection. It can be called more than once and from the
+// event handlers, only the first call closes the PeerConnection and the others
+// return nil right away. Once everything has stopped OnClose is invoked.
 func (pc *PeerConnection) Close() error {
-	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #1)
-	if pc.isClosed.get() {
+	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #1, #2)
+	if pc.isClosed.swap(true) {
 		return nil
 	}
 
-	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #2)
-	pc.isClosed.set(true)
+	pc.api.peerConnections.remove(pc)
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #3)
+	pc.stateLock.Lock()
 	pc.signalingState.Set(SignalingStateClosed)
+	pc.stateLock.Unlock()
+
+	networkMonitor := pc.api.networkMonitor.remove(pc)
+	pc.earlyMedia.stop()
 
 	// Try closing everything and collect the errors
 	// Shutdown strategy:
@@ -1819,8 +2476,8 @@ func (pc *PeerConnection) Close() error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #4)
 	pc.mu.Lock()
 	for _, t := range pc.rtpTransceivers {
-		if !t.stopped {
-			closeErrs = append(closeErrs, t.Stop())
+		if !t.stopped.get() {
+			closeErrs = append(closeErrs, t.setStopped())
 		}
 	}
 	pc.mu.Unlock()
@@ -1846,15 +2503,76 @@ func (pc *PeerConnection) Close() error {
 	}
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #11)
-	pc.updateConnectionState(pc.ICEConnectionState(), pc.dtlsTransport.State())
+	pc.updateConnectionState()
+
+	go pc.finishClose(networkMonitor)
 
 	return util.FlattenErrs(closeErrs)
 }
 
+// Done returns a channel that is closed once the PeerConnection has been closed
+// and all of its goroutines exited, the operations and transports as well as the
+// loops reading media, RTCP and DataChannels. It is closed after OnClose returned.
+// Event handlers must not wait for it, the read loops calling them are waited for.
+func (pc *PeerConnection) Done() <-chan struct{} {
+	return pc.done
+}
+
+// finishClose waits for the operations and goroutines that are still running after
+// Close, and for the networkMonitor if this was the last PeerConnection using it.
+// It dispatches OnClose after all other events and closes done.
+func (pc *PeerConnection) finishClose(networkMonitor *networkMonitor) {
+	// A chained operation may enqueue to ops before it notices the close
+	pc.operationsChain.Done()
+	pc.ops.Done()
+	pc.routines.Wait()
+
+	pc.sctpTransport.wait()
+	pc.mu.RLock()
+	transceivers := append([]*RTPTransceiver{}, pc.rtpTransceivers...)
+	pc.mu.RUnlock()
+	for _, t := range transceivers {
+		if sender := t.Sender(); sender != nil {
+			sender.routines.Wait()
+		}
+		if receiver := t.Receiver(); receiver != nil {
+			receiver.routines.Wait()
+		}
+	}
+	if networkMonitor != nil {
+		<-networkMonitor.done
+	}
+
+	pc.events.Enqueue(pc.onClose)
+	pc.events.Done()
+	close(pc.done)
+}
+
+// GracefulClose ends the PeerConnection like Close, but first shuts down the
+// SCTP association and waits until the remote acknowledged it or ctx is done.
+// This lets the remote close its DataChannels cleanly instead of noticing an
+// abrupt disconnect. Closing the DTLS connection afterwards sends close_notify.
+func (pc *PeerConnection) GracefulClose(ctx context.Context) error {
+	if pc.isClosed.get() {
+		return nil
+	}
+
+	if err := pc.sctpTransport.shutdown(ctx); err != nil {
+		pc.log.Warnf("Failed to shutdown SCTP gracefully: %s", err)
+	}
+
+	return pc.Close()
+}
+
 // addRTPTransceiver appends t into rtpTransceivers
 // and fires onNegotiationNeeded;
 // caller of this method should hold `pc.mu` lock
 func (pc *PeerConnection) addRTPTransceiver(t *RTPTransceiver) {
+	t.onNegotiationNeeded.Store(func() {
+		pc.mu.Lock()
+		defer pc.mu.Unlock()
+		pc.onNegotiationNeeded()
+	})
 	pc.rtpTransceivers = append(pc.rtpTransceivers, t)
 	pc.onNegotiationNeeded()
 }
@@ -1937,15 +2655,36 @@ func (pc *PeerConnection) ConnectionState() PeerConnectionState {
 	return pc.connectionState.Load().(PeerConnectionState)
 }
 
+// GetState returns all states of the PeerConnection at once. Unlike the individual
+// accessors the signaling, ICE connection and connection states are taken together,
+// e.g. the ConnectionState is always the one that follows from the ICEConnectionState.
+// The ICE gathering and DTLS transport states are read at the same time.
+func (pc *PeerConnection) GetState() PeerConnectionStateSnapshot {
+	pc.stateLock.Lock()
+	defer pc.stateLock.Unlock()
+
+	return PeerConnectionStateSnapshot{
+		SignalingState:     pc.SignalingState(),
+		ICEGatheringState:  pc.ICEGatheringState(),
+		ICEConnectionState: pc.ICEConnectionState(),
+		ConnectionState:    pc.ConnectionState(),
+		DTLSTransportState: pc.dtlsTransport.State(),
+	}
+}
+
 // GetStats return data providing statistics about the overall connection
 func (pc *PeerConnection) GetStats() StatsReport {
+	return pc.getStats(statsTimestampNow())
+}
+
+func (pc *PeerConnection) getStats(timestamp StatsTimestamp) StatsReport {
 	var (
 		dataChannelsAccepted  uint32
 		dataChannelsClosed    uint32
 		dataChannelsOpened    uint32
 		dataChannelsRequested uint32
 	)
-	statsCollector := newStatsReportCollector()
+	statsCollector := newStatsReportCollector(timestamp)
 	statsCollector.Collecting()
 
 	pc.mu.Lock()
@@ -1973,8 +2712,17 @@ func (pc *PeerConnection) GetStats() StatsReport {
 	}
 	pc.sctpTransport.collectStats(statsCollector)
 
+	for _, t := range pc.rtpTransceivers {
+		if sender := t.Sender(); sender != nil {
+			sender.collectStats(statsCollector)
+		}
+		if receiver := t.Receiver(); receiver != nil {
+			receiver.collectStats(statsCollector)
+		}
+	}
+
 	stats := PeerConnectionStats{
-		Timestamp:             statsTimestampNow(),
+		Timestamp:             timestamp,
 		Type:                  StatsTypePeerConnection,
 		ID:                    pc.statsID,
 		DataChannelsAccepted:  dataChannelsAccepted,
@@ -2020,7 +2768,7 @@ func (pc *PeerConnection) startTransports(iceRole ICERole, dtlsRole DTLSRole, re
 		Role:         dtlsRole,
 		Fingerprints: []DTLSFingerprint{{Algorithm: fingerprintHash, Value: fingerprint}},
 	})
-	pc.updateConnectionState(pc.ICEConnectionState(), pc.dtlsTransport.State())
+	pc.updateConnectionState()
 	if err != nil {
 		pc.log.Warnf("Failed to start manager: %s", err)
 		return
@@ -2062,8 +2810,10 @@ func (pc *PeerConnection) startRTP(isRenegotiation bool, remoteDesc *SessionDesc
 	}
 
 	pc.startRTPReceivers(trackDetails, currentTransceivers)
+	pc.earlyMedia.openPath()
 	if haveApplicationMediaSection(remoteDesc.parsed) {
 		pc.startSCTP()
+		pc.startCloseReasons(remoteDesc)
 	}
 
 	if !isRenegotiation {
@@ -2073,7 +2823,7 @@ func (pc *PeerConnection) startRTP(isRenegotiation bool, remoteDesc *SessionDesc
 
 // generateUnmatchedSDP generates an SDP that doesn't take remote state into account
 // This is used for the initial call for CreateOffer
-func (pc *PeerConnection) generateUnmatchedSDP(transceivers []*RTPTransceiver, useIdentity bool) (*sdp.SessionDescription, error) {
+func (pc *PeerConnection) generateUnmatchedSDP(transceivers []*RTPTransceiver, useIdentity bool, vad voiceActivityDetection) (*sdp.SessionDescription, error) {
 	d, err := sdp.NewJSEPSessionDescription(useIdentity)
 	if err != nil {
 		return nil, err
@@ -2134,18 +2884,30 @@ func (pc *PeerConnection) generateUnmatchedSDP(transceivers []*RTPTransceiver, u
 		}
 	}
 
+	for i := range mediaSections {
+		mediaSections[i].voiceActivityDetection = vad
+		if pc.api.settingEngine.closeReasons {
+			id := pc.api.settingEngine.getControlDataChannelID()
+			mediaSections[i].closeReasonsID = &id
+		}
+	}
+
 	dtlsFingerprints, err := pc.configuration.Certificates[0].GetFingerprints()
 	if err != nil {
 		return nil, err
 	}
 
-	return populateSDP(d, isPlanB, dtlsFingerprints, pc.api.settingEngine.sdpMediaLevelFingerprints, pc.api.settingEngine.candidates.ICELite, pc.api.mediaEngine, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), candidates, iceParams, mediaSections, pc.ICEGatheringState())
+	d, err = populateSDP(d, isPlanB, dtlsFingerprints, pc.api.settingEngine.sdpMediaLevelFingerprints, pc.api.settingEngine.candidates.ICELite, pc.configuration.BundlePolicy == BundlePolicyMaxBundle, pc.api.mediaEngine, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), candidates, iceParams, mediaSections, pc.ICEGatheringState())
+	if err == nil && pc.configuration.RTCPMuxPolicy == RTCPMuxPolicyRequire && pc.api.settingEngine.rtcpMuxOnly && pc.api.settingEngine.sdpQuirksMode != SDPQuirksModeAlways {
+		withRTCPMuxOnly(d)
+	}
+	return d, err
 }
 
 // generateMatchedSDP generates a SDP and takes the remote state into account
 // this is used everytime we have a RemoteDescription
 // nolint: gocyclo
-func (pc *PeerConnection) generateMatchedSDP(transceivers []*RTPTransceiver, useIdentity bool, includeUnmatched bool, connectionRole sdp.ConnectionRole) (*sdp.SessionDescription, error) { //nolint:gocognit
+func (pc *PeerConnection) generateMatchedSDP(transceivers []*RTPTransceiver, useIdentity bool, includeUnmatched bool, connectionRole sdp.ConnectionRole, vad voiceActivityDetection) (*sdp.SessionDescription, error) { //nolint:gocognit
 	d, err := sdp.NewJSEPSessionDescription(useIdentity)
 	if err != nil {
 		return nil, err
@@ -2183,6 +2945,18 @@ func (pc *PeerConnection) generateMatchedSDP(transceivers []*RTPTransceiver, use
 		}
 
 		kind := NewRTPCodecType(media.MediaName.Media)
+		if kind != 0 && !detectedPlanB && isRejectedMediaSection(media) {
+			// A rejected media section is answered with a rejected one, the
+			// transceiver has been stopped when the offer was applied
+			if t, localTransceivers = findByMid(midValue, localTransceivers); t == nil {
+				t = &RTPTransceiver{kind: kind, api: pc.api}
+				t.setDirection(RTPTransceiverDirectionInactive)
+				t.stopped.set(true)
+			}
+			mediaSections = append(mediaSections, mediaSection{id: midValue, transceivers: []*RTPTransceiver{t}})
+			continue
+		}
+
 		direction := getPeerDirection(media)
 		if kind == 0 || direction == RTPTransceiverDirection(Unknown) {
 			continue
@@ -2205,6 +2979,12 @@ func (pc *PeerConnection) generateMatchedSDP(transceivers []*RTPTransceiver, use
 					if len(mediaTransceivers) == 0 {
 						t = &RTPTransceiver{kind: kind, api: pc.api, codecs: pc.api.mediaEngine.getCodecsByKind(kind)}
 						t.setDirection(RTPTransceiverDirectionInactive)
+
+						// We have nothing to send, but still answer recvonly if the remote is sending.
+						// The placeholder is thrown away, so it doesn't get a receiver.
+						if direction == RTPTransceiverDirectionSendrecv || direction == RTPTransceiverDirectionSendonly {
+							t.setDirection(RTPTransceiverDirectionRecvonly)
+						}
 						mediaTransceivers = append(mediaTransceivers, t)
 					}
 					break
@@ -2255,12 +3035,29 @@ func (pc *PeerConnection) generateMatchedSDP(transceivers []*RTPTransceiver, use
 		pc.log.Info("Plan-B Offer detected; responding with Plan-B Answer")
 	}
 
+	negotiatedMids := map[string]bool{}
+	if pc.currentLocalDescription != nil && pc.currentLocalDescription.parsed != nil {
+		for _, media := range pc.currentLocalDescription.parsed.MediaDescriptions {
+			negotiatedMids[getMidValue(media)] = true
+		}
+	}
+
+	for i := range mediaSections {
+		mediaSections[i].voiceActivityDetection = vad
+		if pc.api.settingEngine.closeReasons {
+			id := pc.api.settingEngine.getControlDataChannelID()
+			mediaSections[i].closeReasonsID = &id
+		}
+		mediaSections[i].negotiated = negotiatedMids[mediaSections[i].id]
+	}
+
 	dtlsFingerprints, err := pc.configuration.Certificates[0].GetFingerprints()
 	if err != nil {
 		return nil, err
 	}
 
-	return populateSDP(d, detectedPlanB, dtlsFingerprints, pc.api.settingEngine.sdpMediaLevelFingerprints, pc.api.settingEngine.candidates.ICELite, pc.api.mediaEngine, connectionRole, candidates, iceParams, mediaSections, pc.ICEGatheringState())
+	bundleOnly := includeUnmatched && pc.configuration.BundlePolicy == BundlePolicyMaxBundle
+	return populateSDP(d, detectedPlanB, dtlsFingerprints, pc.api.settingEngine.sdpMediaLevelFingerprints, pc.api.settingEngine.candidates.ICELite, bundleOnly, pc.api.mediaEngine, connectionRole, candidates, iceParams, mediaSections, pc.ICEGatheringState())
 }
 
 func (pc *PeerConnection) setGatherCompleteHandler(handler func()) {
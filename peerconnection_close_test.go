@@ -3,6 +3,7 @@
 package webrtc
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -177,3 +178,40 @@ func TestPeerConnection_Close_DuringICE(t *testing.T) {
 		t.Error("pcOffer.Close() Timeout")
 	}
 }
+
+func TestPeerConnection_GracefulClose(t *testing.T) {
+	// Limit runtime in case of deadlocks
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onDataChannelOpened := make(chan struct{})
+	onDataChannelClosed := make(chan struct{})
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		d.OnOpen(func() {
+			close(onDataChannelOpened)
+		})
+		d.OnClose(func() {
+			close(onDataChannelClosed)
+		})
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	<-onDataChannelOpened
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, pcOffer.GracefulClose(ctx))
+	assert.Equal(t, SCTPTransportStateClosed, pcOffer.sctpTransport.State())
+
+	<-onDataChannelClosed
+	assert.NoError(t, pcAnswer.Close())
+}
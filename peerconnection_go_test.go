@@ -9,17 +9,24 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pion/ice/v2"
+	"github.com/pion/interceptor"
+	"github.com/pion/logging"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/transport/test"
 	"github.com/pion/transport/vnet"
 	"github.com/pion/webrtc/v3/internal/util"
@@ -280,6 +287,82 @@ func TestPeerConnection_SetConfiguration_Go(t *testing.T) {
 	}
 }
 
+func TestPeerConnection_SetConfiguration_ICEServers(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	// Before gathering the ICE servers and policy are passed to the ICEGatherer
+	assert.NoError(t, pc.SetConfiguration(Configuration{
+		ICEServers:         []ICEServer{{URLs: []string{"stun:stun.example.com:3478"}}},
+		ICETransportPolicy: ICETransportPolicyRelay,
+	}))
+	assert.Len(t, pc.iceGatherer.validatedServers, 1)
+	assert.Equal(t, "stun.example.com", pc.iceGatherer.validatedServers[0].Host)
+	assert.Equal(t, ICETransportPolicyRelay, pc.iceGatherer.gatherPolicy)
+
+	_, err = pc.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	gatherComplete := GatheringCompletePromise(pc)
+	assert.NoError(t, pc.SetLocalDescription(offer))
+
+	// Once gathering started the agent keeps its servers until the next ICE restart
+	assert.NoError(t, pc.SetConfiguration(Configuration{
+		ICEServers: []ICEServer{{URLs: []string{"stun:stun2.example.com:3478"}}},
+	}))
+	assert.Equal(t, "stun:stun2.example.com:3478", pc.GetConfiguration().ICEServers[0].URLs[0])
+	assert.Equal(t, "stun.example.com", pc.iceGatherer.agentServers[0].Host)
+	<-gatherComplete
+
+	// The restart of CreateOffer gathers again. The agent enters the complete gathering
+	// state after it signaled the end of candidates.
+	restartICE := func() {
+		for {
+			_, err = pc.CreateOffer(&OfferOptions{ICERestart: true})
+			if !errors.Is(err, ice.ErrRestartWhenGathering) {
+				assert.NoError(t, err)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	gatherComplete = GatheringCompletePromise(pc)
+	restartICE()
+	<-gatherComplete
+	assert.Equal(t, "stun2.example.com", pc.iceGatherer.agentServers[0].Host)
+
+	// The number of servers of the agent can't change
+	var modificationErr *rtcerr.InvalidModificationError
+	err = pc.SetConfiguration(Configuration{
+		ICEServers: []ICEServer{{URLs: []string{"stun:stun3.example.com:3478", "stun:stun4.example.com:3478"}}},
+	})
+	assert.True(t, errors.As(err, &modificationErr))
+	assert.ErrorIs(t, err, ErrModifyingICEServerCount)
+	assert.Equal(t, "stun:stun2.example.com:3478", pc.GetConfiguration().ICEServers[0].URLs[0])
+
+	assert.NoError(t, pc.Close())
+
+	// Neither can the policy of the agent
+	pc, err = NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	_, err = pc.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	offer, err = pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pc.SetLocalDescription(offer))
+
+	err = pc.SetConfiguration(Configuration{ICETransportPolicy: ICETransportPolicyRelay})
+	assert.True(t, errors.As(err, &modificationErr))
+	assert.ErrorIs(t, err, ErrModifyingICETransportPolicy)
+	assert.Equal(t, ICETransportPolicyAll, pc.GetConfiguration().ICETransportPolicy)
+
+	assert.NoError(t, pc.Close())
+}
+
 func TestPeerConnection_EventHandlers_Go(t *testing.T) {
 	lim := test.TimeOut(time.Second * 5)
 	defer lim.Stop()
@@ -832,6 +915,70 @@ func TestPopulateLocalCandidates(t *testing.T) {
 	})
 }
 
+// Assert that the current and pending descriptions follow the offer/answer cycle
+// and that remote candidates are reflected in the remote description
+func TestPeerConnection_DescriptionAccessors(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	_, err = offerPC.CreateDataChannel("test-channel", nil)
+	assert.NoError(t, err)
+
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, offerPC.SetLocalDescription(offer))
+	assert.Equal(t, SDPTypeOffer, offerPC.PendingLocalDescription().Type)
+	assert.Nil(t, offerPC.CurrentLocalDescription())
+
+	assert.NoError(t, answerPC.SetRemoteDescription(offer))
+	assert.Equal(t, offer.SDP, answerPC.PendingRemoteDescription().SDP)
+	assert.Nil(t, answerPC.CurrentRemoteDescription())
+
+	answer, err := answerPC.CreateAnswer(nil)
+	assert.NoError(t, err)
+	pranswer := SessionDescription{Type: SDPTypePranswer, SDP: answer.SDP}
+
+	assert.NoError(t, answerPC.SetLocalDescription(pranswer))
+	assert.Equal(t, SDPTypePranswer, answerPC.PendingLocalDescription().Type)
+	assert.Equal(t, offer.SDP, answerPC.PendingRemoteDescription().SDP)
+
+	assert.NoError(t, offerPC.SetRemoteDescription(pranswer))
+	assert.Equal(t, SDPTypePranswer, offerPC.PendingRemoteDescription().Type)
+	assert.Equal(t, SDPTypeOffer, offerPC.PendingLocalDescription().Type)
+	assert.Nil(t, offerPC.CurrentRemoteDescription())
+
+	assert.NoError(t, answerPC.SetLocalDescription(answer))
+	assert.Equal(t, SDPTypeAnswer, answerPC.CurrentLocalDescription().Type)
+	assert.Equal(t, offer.SDP, answerPC.CurrentRemoteDescription().SDP)
+	assert.Nil(t, answerPC.PendingLocalDescription())
+	assert.Nil(t, answerPC.PendingRemoteDescription())
+
+	assert.NoError(t, offerPC.SetRemoteDescription(answer))
+	assert.Equal(t, answer.SDP, offerPC.CurrentRemoteDescription().SDP)
+	assert.Equal(t, SDPTypeOffer, offerPC.CurrentLocalDescription().Type)
+	assert.Nil(t, offerPC.PendingLocalDescription())
+	assert.Nil(t, offerPC.PendingRemoteDescription())
+
+	mid := "0"
+	candidate := "candidate:1 1 udp 2130706431 192.0.2.1 5000 typ host"
+	assert.NoError(t, answerPC.AddICECandidate(ICECandidateInit{Candidate: candidate, SDPMid: &mid}))
+	assert.NoError(t, answerPC.AddICECandidate(ICECandidateInit{Candidate: candidate, SDPMid: &mid}))
+	assert.NoError(t, answerPC.AddICECandidate(ICECandidateInit{Candidate: "", SDPMid: &mid}))
+
+	remoteDescription := answerPC.CurrentRemoteDescription().SDP
+	assert.Equal(t, 1, strings.Count(remoteDescription, "a="+candidate+"\r\n"))
+	assert.Contains(t, remoteDescription, "a=end-of-candidates")
+	assert.NotContains(t, offer.SDP, "a=end-of-candidates")
+
+	closePairNow(t, offerPC, answerPC)
+}
+
 // Assert that two agents that only generate mDNS candidates can connect
 func TestMulticastDNSCandidates(t *testing.T) {
 	lim := test.TimeOut(time.Second * 30)
@@ -1434,3 +1581,624 @@ func TestPeerConnectionNilCallback(t *testing.T) {
 
 	assert.NoError(t, pc.Close())
 }
+
+// Assert that the ICEConnectionStates are emitted in the order of the specification,
+// completed following connected once gathering is complete
+func TestPeerConnection_ICEConnectionStateOrder(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	var statesMu sync.Mutex
+	states := map[*PeerConnection][]ICEConnectionState{}
+	completed := &sync.WaitGroup{}
+	completed.Add(2)
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		pc := pc
+		pc.OnICEConnectionStateChange(func(s ICEConnectionState) {
+			statesMu.Lock()
+			states[pc] = append(states[pc], s)
+			statesMu.Unlock()
+
+			if s == ICEConnectionStateCompleted {
+				completed.Done()
+			}
+		})
+	}
+
+	connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	completed.Wait()
+	connected.Wait()
+
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		assert.Equal(t, ICEConnectionStateCompleted, pc.ICEConnectionState())
+	}
+
+	closePairNow(t, pcOffer, pcAnswer)
+
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		expected := []ICEConnectionState{
+			ICEConnectionStateChecking,
+			ICEConnectionStateConnected,
+			ICEConnectionStateCompleted,
+		}
+		// Whether closing emits the closed state depends on the agent reporting it before it shut down
+		if len(states[pc]) > len(expected) {
+			expected = append(expected, ICEConnectionStateClosed)
+		}
+		assert.Equal(t, expected, states[pc])
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestPeerConnection_PeerConnectionID(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	output := &syncBuffer{}
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	loggerFactory.DefaultLogLevel = logging.LogLevelDebug
+	loggerFactory.Writer = output
+
+	s := SettingEngine{LoggerFactory: loggerFactory}
+	api := NewAPI(WithSettingEngine(s))
+
+	pcOffer, err := api.NewPeerConnection(Configuration{PeerConnectionID: "offerer"})
+	assert.NoError(t, err)
+	pcAnswer, err := api.NewPeerConnection(Configuration{PeerConnectionID: "answerer"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "offerer", pcOffer.GetConfiguration().PeerConnectionID)
+	assert.Equal(t, &rtcerr.InvalidModificationError{Err: ErrModifyingPeerConnectionID},
+		pcOffer.SetConfiguration(Configuration{PeerConnectionID: "answerer"}))
+
+	_, err = pcOffer.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+
+	connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	connected.Wait()
+	closePairNow(t, pcOffer, pcAnswer)
+
+	scopes := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		assert.Regexp(t, ` \[(offerer|answerer)\] `, line)
+		scopes[strings.Fields(line)[0]] = true
+	}
+	for _, scope := range []string{"pc", "ice"} {
+		assert.True(t, scopes[scope], scope)
+	}
+}
+
+func TestPeerConnection_OfferOptions_ICERestart(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	// Restarting before the first offer has nothing to restart
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pc.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.NoError(t, pc.Close())
+
+	// Credentials set by the SettingEngine are used again, like by RestartICE
+	s := SettingEngine{}
+	s.SetICECredentials("ufragufragufrag", "passwordpasswordpassword")
+	pc, err = NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	_, err = pc.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+
+	_, err = pc.CreateOffer(nil)
+	assert.NoError(t, err)
+
+	offer, err := pc.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=ice-ufrag:ufragufragufrag")
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_OfferOptions_VoiceActivityDetection(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeTypeOpus, 48000, 2, "minptime=10;useinbandfec=1", nil},
+		PayloadType:        111,
+	}, RTPCodecTypeAudio))
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{"audio/CN", 8000, 0, "", nil},
+		PayloadType:        13,
+	}, RTPCodecTypeAudio))
+
+	pc, err := NewAPI(WithMediaEngine(m)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	_, err = pc.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	// Options that don't set VoiceActivityDetection keep the default of W3C
+	offer, err := pc.CreateOffer(&OfferOptions{ICERestart: true})
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=rtpmap:13 CN/8000")
+
+	offer, err = pc.CreateOffer(&OfferOptions{OfferAnswerOptions: OfferAnswerOptions{DisableVoiceActivityDetection: true}})
+	assert.NoError(t, err)
+	assert.NotContains(t, offer.SDP, "CN/8000")
+	assert.Contains(t, offer.SDP, "m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n")
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_StrictSDPValidation(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.EnableStrictSDPValidation(true)
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	offerPC, answerPC, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+
+	// Descriptions generated by Pion pass
+	connected := untilConnectionState(PeerConnectionStateConnected, offerPC, answerPC)
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	connected.Wait()
+
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	offer.SDP = strings.Replace(offer.SDP, "a=mid:1\r\n", "a=mid:0\r\n", 1)
+
+	err = answerPC.SetRemoteDescription(offer)
+	assert.ErrorIs(t, err, ErrSDPValidation)
+	assert.Contains(t, err.Error(), "a=mid is already used by media section 0")
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+// The hook of SetRemoteSDPHook can repair a description before it is validated
+func TestPeerConnection_StrictSDPValidation_RemoteSDPHook(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.EnableStrictSDPValidation(true)
+	s.SetRemoteSDPHook(func(sdpType SDPType, d *sdp.SessionDescription) error {
+		for i, media := range d.MediaDescriptions {
+			for j, attr := range media.Attributes {
+				if attr.Key == "mid" {
+					media.Attributes[j].Value = strconv.Itoa(i)
+				}
+			}
+		}
+		return nil
+	})
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+
+	offerPC, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	answerPC, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	offer.SDP = strings.Replace(offer.SDP, "a=mid:1\r\n", "a=mid:0\r\n", 1)
+	assert.NoError(t, answerPC.SetRemoteDescription(offer))
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestPeerConnection_RTCPMux(t *testing.T) {
+	// The quirks would add the a=rtcp-mux left out of bundled media sections
+	s := SettingEngine{}
+	s.EnableRTCPMuxOnly(true)
+	s.SetSDPQuirksMode(SDPQuirksModeNever)
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+
+	offerPC, answerPC, err := NewAPI(WithSettingEngine(s), WithMediaEngine(m)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+
+	// Initial offers tell legacy endpoints that RTCP can't be sent on a separate port
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(offer.SDP, "a=rtcp-mux-only\r\n"))
+
+	// A remote description without rtcp-mux can't be negotiated
+	withoutMux := offer
+	withoutMux.SDP = strings.Replace(offer.SDP, "a=rtcp-mux\r\n", "", 1)
+	err = answerPC.SetRemoteDescription(withoutMux)
+	assert.ErrorIs(t, err, ErrRTCPMuxRequired)
+	var accessErr *rtcerr.InvalidAccessError
+	assert.True(t, errors.As(err, &accessErr))
+	assert.Contains(t, err.Error(), `media section 0 (m=video, mid "0")`)
+
+	// Media sections outside of a BUNDLE group need it too
+	withoutMux.SDP = strings.Replace(withoutMux.SDP, "a=group:BUNDLE 0 1 2\r\n", "", 1)
+	assert.ErrorIs(t, answerPC.SetRemoteDescription(withoutMux), ErrRTCPMuxRequired)
+
+	// Only the tagged media section of a BUNDLE group needs rtcp-mux
+	lastMux := strings.LastIndex(offer.SDP, "a=rtcp-mux\r\n")
+	withoutMux.SDP = offer.SDP[:lastMux] + offer.SDP[lastMux+len("a=rtcp-mux\r\n"):]
+	assert.NoError(t, answerPC.SetRemoteDescription(withoutMux))
+	answer, err := answerPC.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, answer.SDP, "a=rtcp-mux-only")
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+// A missing a=rtcp-mux can be added by the hook of SetRemoteSDPHook
+func TestPeerConnection_RTCPMux_RemoteSDPHook(t *testing.T) {
+	s := SettingEngine{}
+	s.SetSDPQuirksMode(SDPQuirksModeNever)
+	s.SetRemoteSDPHook(func(sdpType SDPType, d *sdp.SessionDescription) error {
+		for _, media := range d.MediaDescriptions {
+			if _, ok := media.Attribute("rtcp-mux"); !ok && media.MediaName.Media != mediaSectionApplication {
+				media.WithPropertyAttribute("rtcp-mux")
+			}
+		}
+		return nil
+	})
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+
+	offerPC, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	answerPC, err := NewAPI(WithSettingEngine(s), WithMediaEngine(m)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	offer.SDP = strings.Replace(offer.SDP, "a=rtcp-mux\r\n", "", -1)
+	assert.NoError(t, answerPC.SetRemoteDescription(offer))
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+// Offers don't have a=rtcp-mux-only unless it is enabled
+func TestPeerConnection_RTCPMuxOnlyDefault(t *testing.T) {
+	pc, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pc.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=rtcp-mux\r\n")
+	assert.NotContains(t, offer.SDP, "a=rtcp-mux-only")
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_GetState(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	assert.Equal(t, PeerConnectionStateSnapshot{
+		SignalingState:     SignalingStateStable,
+		ICEGatheringState:  ICEGatheringStateNew,
+		ICEConnectionState: ICEConnectionStateNew,
+		ConnectionState:    PeerConnectionStateNew,
+		DTLSTransportState: DTLSTransportStateNew,
+	}, offerPC.GetState())
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offerPC, answerPC)
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	connected.Wait()
+
+	state := offerPC.GetState()
+	assert.Equal(t, SignalingStateStable, state.SignalingState)
+	assert.Equal(t, ICEGatheringStateComplete, state.ICEGatheringState)
+	assert.Contains(t, []ICEConnectionState{ICEConnectionStateConnected, ICEConnectionStateCompleted}, state.ICEConnectionState)
+	assert.Equal(t, PeerConnectionStateConnected, state.ConnectionState)
+	assert.Equal(t, DTLSTransportStateConnected, state.DTLSTransportState)
+
+	closePairNow(t, offerPC, answerPC)
+
+	state = offerPC.GetState()
+	assert.Equal(t, SignalingStateClosed, state.SignalingState)
+	assert.Equal(t, PeerConnectionStateClosed, state.ConnectionState)
+	assert.Equal(t, DTLSTransportStateClosed, state.DTLSTransportState)
+}
+
+func TestPeerConnection_OperationsChain(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	signalingStates := make(chan SignalingState, 4)
+	answerPC.OnSignalingStateChange(func(state SignalingState) {
+		signalingStates <- state
+	})
+
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, offerPC.SetLocalDescription(offer))
+
+	// An operation started while another one runs waits for it
+	unblock := make(chan struct{})
+	answerPC.operationsChain.Enqueue(func() {
+		<-unblock
+	})
+
+	remoteSet := make(chan struct{})
+	go func() {
+		assert.NoError(t, answerPC.SetRemoteDescription(offer))
+		close(remoteSet)
+	}()
+	answered := make(chan struct{})
+	go func() {
+		<-remoteSet
+		answer, answerErr := answerPC.CreateAnswer(nil)
+		assert.NoError(t, answerErr)
+		assert.NoError(t, answerPC.SetLocalDescription(answer))
+		close(answered)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, SignalingStateStable, answerPC.SignalingState())
+	assert.Nil(t, answerPC.RemoteDescription())
+
+	close(unblock)
+	<-answered
+
+	// The handlers see the states in the order they changed
+	assert.Equal(t, SignalingStateHaveRemoteOffer, <-signalingStates)
+	assert.Equal(t, SignalingStateStable, <-signalingStates)
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestPeerConnection_OnClose(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	var closeCount int32
+	closed := make(chan struct{})
+	var lastState atomic.Value
+	offerPC.OnConnectionStateChange(func(state PeerConnectionState) {
+		lastState.Store(state)
+
+		// Close can be called from a handler, more than once
+		if state == PeerConnectionStateConnected {
+			assert.NoError(t, offerPC.Close())
+			assert.NoError(t, offerPC.Close())
+		}
+	})
+	offerPC.OnClose(func() {
+		assert.Equal(t, PeerConnectionStateClosed, lastState.Load())
+		if atomic.AddInt32(&closeCount, 1) == 1 {
+			close(closed)
+		}
+	})
+
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, signalPair(offerPC, answerPC))
+
+	<-closed
+	assert.NoError(t, offerPC.Close())
+	assert.NoError(t, answerPC.Close())
+
+	// OnClose isn't called again
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&closeCount))
+}
+
+func TestPeerConnection_Done(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerPC, answerPC, err := newPair()
+	assert.NoError(t, err)
+
+	var onCloseCalled atomicBool
+	offerPC.OnClose(func() {
+		onCloseCalled.set(true)
+	})
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	sender, err := offerPC.AddTrack(track)
+	assert.NoError(t, err)
+	sender.OnRTCP(func([]rtcp.Packet, interceptor.Attributes) {})
+
+	dataChannelOpen := make(chan struct{})
+	dataChannel, err := offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	dataChannel.OnOpen(func() {
+		close(dataChannelOpen)
+	})
+
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	<-dataChannelOpen
+
+	select {
+	case <-offerPC.Done():
+		t.Fatal("Done closed before Close")
+	default:
+	}
+
+	closePairNow(t, offerPC, answerPC)
+	<-offerPC.Done()
+	<-answerPC.Done()
+	assert.True(t, onCloseCalled.get())
+}
+
+func TestPeerConnection_ICEGathererReuse(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	api := NewAPI(WithMediaEngine(m))
+
+	_, err := api.NewPeerConnectionWithICEGatherer(Configuration{}, nil)
+	assert.Error(t, err)
+
+	// Gather before the PeerConnection exists
+	gatherer, err := api.NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+	gatheringComplete := make(chan struct{})
+	gatherer.OnLocalCandidate(func(c *ICECandidate) {
+		if c == nil {
+			close(gatheringComplete)
+		}
+	})
+	assert.NoError(t, gatherer.Gather())
+	<-gatheringComplete
+
+	offerPC, err := api.NewPeerConnectionWithICEGatherer(Configuration{}, gatherer)
+	assert.NoError(t, err)
+	_, err = api.NewPeerConnectionWithICEGatherer(Configuration{}, gatherer)
+	assert.True(t, errors.Is(err, ErrICEGathererInUse))
+
+	answerPC, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	// The offer has the candidates without waiting for gathering
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, offerPC.SetLocalDescription(offer))
+	assert.Equal(t, ICEGatheringStateComplete, offerPC.ICEGatheringState())
+	assert.Contains(t, offerPC.LocalDescription().SDP, "a=candidate:")
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offerPC, answerPC)
+	assert.NoError(t, answerPC.SetRemoteDescription(*offerPC.LocalDescription()))
+	answer, err := answerPC.CreateAnswer(nil)
+	assert.NoError(t, err)
+	answerGatheringComplete := GatheringCompletePromise(answerPC)
+	assert.NoError(t, answerPC.SetLocalDescription(answer))
+	<-answerGatheringComplete
+	assert.NoError(t, offerPC.SetRemoteDescription(*answerPC.LocalDescription()))
+	connected.Wait()
+
+	closePairNow(t, offerPC, answerPC)
+
+	// The gatherer isn't reused after the PeerConnection closed
+	_, err = NewAPI().NewPeerConnectionWithICEGatherer(Configuration{}, gatherer)
+	assert.True(t, errors.Is(err, ErrICEGathererInUse))
+}
+
+// GetTransceivers returns a copy ordered like the media sections of the local description
+func TestPeerConnection_GetTransceivers_Order(t *testing.T) {
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	// The answerer adds its transceivers the other way around, they are
+	// matched with the media sections by kind
+	audioTransceiver, err := pcAnswer.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+	videoTransceiver, err := pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, []*RTPTransceiver{audioTransceiver, videoTransceiver}, pcAnswer.GetTransceivers())
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+
+	transceivers := pcAnswer.GetTransceivers()
+	assert.Equal(t, []*RTPTransceiver{videoTransceiver, audioTransceiver}, transceivers)
+	assert.Equal(t, []*RTPSender{videoTransceiver.Sender(), audioTransceiver.Sender()}, pcAnswer.GetSenders())
+	assert.Equal(t, []*RTPReceiver{videoTransceiver.Receiver(), audioTransceiver.Receiver()}, pcAnswer.GetReceivers())
+
+	// Transceivers without a media section follow, the snapshot stays as it is
+	addedTransceiver, err := pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, []*RTPTransceiver{videoTransceiver, audioTransceiver}, transceivers)
+	assert.Equal(t, []*RTPTransceiver{videoTransceiver, audioTransceiver, addedTransceiver}, pcAnswer.GetTransceivers())
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
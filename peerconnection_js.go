@@ -26,6 +26,7 @@ type PeerConnection struct {
 	onICEConnectionStateChangeHandler *js.Func
 	onICECandidateHandler             *js.Func
 	onICEGatheringStateChangeHandler  *js.Func
+	onICECandidateErrorHandler        *js.Func
 
 	// Used by GatheringCompletePromise
 	onGatherCompleteHandler func()
@@ -358,6 +359,29 @@ func (pc *PeerConnection) OnICEGatheringStateChange(f func()) {
 	pc.underlying.Set("onicegatheringstatechange", onICEGatheringStateChangeHandler)
 }
 
+// OnICECandidateError sets an event handler which is invoked when a STUN or TURN
+// server fails to provide a candidate, e.g. when the TURN credentials are wrong.
+func (pc *PeerConnection) OnICECandidateError(f func(ICECandidateError)) {
+	if pc.onICECandidateErrorHandler != nil {
+		oldHandler := pc.onICECandidateErrorHandler
+		defer oldHandler.Release()
+	}
+	onICECandidateErrorHandler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		event := args[0]
+		candidateErr := ICECandidateError{
+			Address:   valueToStringOrZero(event.Get("address")),
+			Port:      valueToUint16OrZero(event.Get("port")),
+			URL:       valueToStringOrZero(event.Get("url")),
+			ErrorCode: int(valueToUint16OrZero(event.Get("errorCode"))),
+			ErrorText: valueToStringOrZero(event.Get("errorText")),
+		}
+		go f(candidateErr)
+		return js.Undefined()
+	})
+	pc.onICECandidateErrorHandler = &onICECandidateErrorHandler
+	pc.underlying.Set("onicecandidateerror", onICECandidateErrorHandler)
+}
+
 // CreateDataChannel creates a new DataChannel object with the given label
 // and optional DataChannelInit used to configure properties of the
 // underlying channel such as data reliability.
@@ -417,6 +441,9 @@ func (pc *PeerConnection) Close() (err error) {
 	if pc.onICEGatheringStateChangeHandler != nil {
 		pc.onICEGatheringStateChangeHandler.Release()
 	}
+	if pc.onICECandidateErrorHandler != nil {
+		pc.onICECandidateErrorHandler.Release()
+	}
 
 	return nil
 }
@@ -668,7 +695,7 @@ func offerOptionsToValue(offerOptions *OfferOptions) js.Value {
 	}
 	return js.ValueOf(map[string]interface{}{
 		"iceRestart":             offerOptions.ICERestart,
-		"voiceActivityDetection": offerOptions.VoiceActivityDetection,
+		"voiceActivityDetection": !offerOptions.DisableVoiceActivityDetection,
 	})
 }
 
@@ -677,7 +704,7 @@ func answerOptionsToValue(answerOptions *AnswerOptions) js.Value {
 		return js.Undefined()
 	}
 	return js.ValueOf(map[string]interface{}{
-		"voiceActivityDetection": answerOptions.VoiceActivityDetection,
+		"voiceActivityDetection": !answerOptions.DisableVoiceActivityDetection,
 	})
 }
 
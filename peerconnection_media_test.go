@@ -17,6 +17,7 @@ import (
 	"github.com/pion/randutil"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/transport/test"
 	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/stretchr/testify/assert"
@@ -144,7 +145,7 @@ func TestPeerConnection_Media_Sample(t *testing.T) {
 	go func() {
 		for {
 			time.Sleep(time.Millisecond * 100)
-			if routineErr := pcOffer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{SenderSSRC: uint32(sender.ssrc), MediaSSRC: uint32(sender.ssrc)}}); routineErr != nil {
+			if routineErr := pcOffer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{SenderSSRC: uint32(sender.trackEncodings[0].ssrc), MediaSSRC: uint32(sender.trackEncodings[0].ssrc)}}); routineErr != nil {
 				awaitRTCPSenderSend <- routineErr
 			}
 
@@ -450,6 +451,161 @@ func TestUndeclaredSSRC(t *testing.T) {
 	closePairNow(t, pcOffer, pcAnswer)
 }
 
+// Assert that the tracks of several media sections are received without a=ssrc lines,
+// the SSRCs are matched to the media sections with the mid header extension
+func TestUndeclaredSSRC_MultipleMediaSections(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := func() *API {
+		m := &MediaEngine{}
+		assert.NoError(t, m.RegisterDefaultCodecs())
+		assert.NoError(t, m.RegisterHeaderExtension(RTPHeaderExtensionCapability{URI: sdp.SDESMidURI}, RTPCodecTypeVideo))
+		return NewAPI(WithMediaEngine(m))
+	}
+	pcOffer, err := api().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	pcAnswer, err := api().NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	tracks := []*TrackLocalStaticRTP{}
+	senders := []*RTPSender{}
+	for _, id := range []string{"video1", "video2"} {
+		track, trackErr := NewTrackLocalStaticRTP(RTPCodecCapability{MimeType: MimeTypeVP8}, id, "pion")
+		assert.NoError(t, trackErr)
+		sender, trackErr := pcOffer.AddTrack(track)
+		assert.NoError(t, trackErr)
+		tracks = append(tracks, track)
+		senders = append(senders, sender)
+	}
+
+	onTrack := make(chan string, 2)
+	pcAnswer.OnTrack(func(track *TrackRemote, r *RTPReceiver) {
+		onTrack <- track.ID()
+	})
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	offerGatheringComplete := GatheringCompletePromise(pcOffer)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	<-offerGatheringComplete
+	offer = *pcOffer.LocalDescription()
+
+	filteredSDP := ""
+	for _, l := range strings.SplitAfter(offer.SDP, "\n") {
+		if !strings.HasPrefix(l, "a=ssrc") {
+			filteredSDP += l
+		}
+	}
+	offer.SDP = filteredSDP
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	answerGatheringComplete := GatheringCompletePromise(pcAnswer)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	<-answerGatheringComplete
+	assert.NoError(t, pcOffer.SetRemoteDescription(*pcAnswer.LocalDescription()))
+
+	received := map[string]bool{}
+	var sequenceNumber uint16
+	for len(received) < 2 {
+		select {
+		case id := <-onTrack:
+			received[id] = true
+		case <-time.After(20 * time.Millisecond):
+			for i, track := range tracks {
+				var midExtensionID uint8
+				for _, extension := range senders[i].GetParameters().HeaderExtensions {
+					if extension.URI == sdp.SDESMidURI {
+						midExtensionID = uint8(extension.ID)
+					}
+				}
+
+				packet := &rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: sequenceNumber}, Payload: []byte{0x00}}
+				assert.NoError(t, packet.Header.SetExtension(midExtensionID, []byte(senders[i].tr.Mid())))
+				assert.NoError(t, track.WriteRTP(packet))
+			}
+			sequenceNumber++
+		}
+	}
+	assert.Equal(t, map[string]bool{"video1": true, "video2": true}, received)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+// Assert that every track gets a media section of its own with a unique mid
+// and fires OnTrack on the remote
+func TestPeerConnection_MultipleTracks(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	// The tracks share their ids, they are told apart by their media sections
+	tracks := []*TrackLocalStaticSample{}
+	for _, codec := range []string{MimeTypeVP8, MimeTypeVP8, MimeTypeVP8, MimeTypeOpus, MimeTypeOpus} {
+		track, trackErr := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: codec}, "track", "pion")
+		assert.NoError(t, trackErr)
+		_, trackErr = pcOffer.AddTrack(track)
+		assert.NoError(t, trackErr)
+		tracks = append(tracks, track)
+	}
+
+	var onTrackCount sync.WaitGroup
+	onTrackCount.Add(len(tracks))
+	var mu sync.Mutex
+	receivedMids := map[string]RTPCodecType{}
+	pcAnswer.OnTrack(func(track *TrackRemote, r *RTPReceiver) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "track", track.ID())
+		assert.Equal(t, "pion", track.StreamID())
+		for _, transceiver := range pcAnswer.GetTransceivers() {
+			if transceiver.Receiver() == r {
+				_, seen := receivedMids[transceiver.Mid()]
+				assert.False(t, seen)
+				receivedMids[transceiver.Mid()] = track.Kind()
+			}
+		}
+		onTrackCount.Done()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	mids := map[string]bool{}
+	for _, media := range pcOffer.LocalDescription().parsed.MediaDescriptions {
+		mid := getMidValue(media)
+		assert.False(t, mids[mid])
+		mids[mid] = true
+	}
+	assert.Equal(t, 3, strings.Count(pcOffer.LocalDescription().SDP, "m=video "))
+	assert.Equal(t, 2, strings.Count(pcOffer.LocalDescription().SDP, "m=audio "))
+	assert.Equal(t, 3, strings.Count(pcAnswer.LocalDescription().SDP, "m=video "))
+	assert.Equal(t, 2, strings.Count(pcAnswer.LocalDescription().SDP, "m=audio "))
+
+	allReceived := make(chan struct{})
+	go func() {
+		onTrackCount.Wait()
+		close(allReceived)
+	}()
+	sendVideoUntilDone(allReceived, t, tracks)
+
+	mu.Lock()
+	assert.Len(t, receivedMids, len(tracks))
+	mu.Unlock()
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
 func TestAddTransceiverFromTrackSendOnly(t *testing.T) {
 	lim := test.TimeOut(time.Second * 30)
 	defer lim.Stop()
@@ -582,12 +738,12 @@ func TestAddTransceiverAddTrack_Reuse(t *testing.T) {
 	track1, sender1 := addTrack()
 	assert.Equal(t, 1, len(pc.GetTransceivers()))
 	assert.Equal(t, sender1, tr.Sender())
-	assert.Equal(t, track1, tr.Sender().track)
+	assert.Equal(t, track1, tr.Sender().Track())
 	require.NoError(t, pc.RemoveTrack(sender1))
 
 	track2, _ := addTrack()
 	assert.Equal(t, 1, len(pc.GetTransceivers()))
-	assert.Equal(t, track2, tr.Sender().track)
+	assert.Equal(t, track2, tr.Sender().Track())
 
 	addTrack()
 	assert.Equal(t, 2, len(pc.GetTransceivers()))
@@ -1052,3 +1208,118 @@ func TestPeerConnection_RaceReplaceTrack(t *testing.T) {
 
 	assert.NoError(t, pc.Close())
 }
+
+// Assert that a media section without a common codec is rejected without
+// failing the negotiation of the other media sections
+func TestPeerConnection_MediaSectionRejected(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.EnableMediaSectionRejection(true)
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	}, RTPCodecTypeAudio))
+
+	offerMediaEngine := &MediaEngine{}
+	assert.NoError(t, offerMediaEngine.RegisterDefaultCodecs())
+	pcOffer, err := NewAPI(WithMediaEngine(offerMediaEngine), WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	videoTrack, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(videoTrack)
+	assert.NoError(t, err)
+
+	audioTrack, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeOpus}, "audio", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(audioTrack)
+	assert.NoError(t, err)
+
+	pcAnswer, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	answerRejected := make(chan string, 1)
+	pcAnswer.OnMediaSectionRejected(func(mid string, kind RTPCodecType) {
+		assert.Equal(t, RTPCodecTypeVideo, kind)
+		answerRejected <- mid
+	})
+
+	offerRejected := make(chan string, 1)
+	pcOffer.OnMediaSectionRejected(func(mid string, kind RTPCodecType) {
+		assert.Equal(t, RTPCodecTypeVideo, kind)
+		offerRejected <- mid
+	})
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(track *TrackRemote, r *RTPReceiver) {
+		assert.Equal(t, RTPCodecTypeAudio, track.Kind())
+		onTrackFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	assert.True(t, strings.Contains(pcAnswer.LocalDescription().SDP, "m=video 0 "))
+
+	mid := <-answerRejected
+	assert.Equal(t, mid, <-offerRejected)
+
+	sendVideoUntilDone(onTrackFired.Done(), t, []*TrackLocalStaticSample{audioTrack})
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+// Assert that bundle-only media sections generated with max-bundle
+// are negotiated instead of being treated as rejected
+func TestPeerConnection_MaxBundle_BundleOnly(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, err := NewPeerConnection(Configuration{BundlePolicy: BundlePolicyMaxBundle})
+	assert.NoError(t, err)
+
+	pcAnswer, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	videoTrack, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(videoTrack)
+	assert.NoError(t, err)
+
+	pcOffer.OnMediaSectionRejected(func(mid string, kind RTPCodecType) {
+		t.Errorf("Media section %s rejected", mid)
+	})
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(track *TrackRemote, r *RTPReceiver) {
+		onTrackFiredFunc()
+	})
+
+	onDataChannelFired, onDataChannelFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		onDataChannelFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	offer := pcOffer.LocalDescription().parsed
+	assert.False(t, haveBundleOnly(offer.MediaDescriptions[0]))
+	for _, media := range offer.MediaDescriptions[1:] {
+		assert.True(t, haveBundleOnly(media))
+	}
+
+	sendVideoUntilDone(onTrackFired.Done(), t, []*TrackLocalStaticSample{videoTrack})
+	<-onDataChannelFired.Done()
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
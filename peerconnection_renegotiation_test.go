@@ -12,6 +12,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/transport/test"
 	"github.com/pion/webrtc/v3/internal/util"
 	"github.com/pion/webrtc/v3/pkg/media"
@@ -368,7 +370,7 @@ func TestPeerConnection_Transceiver_Mid(t *testing.T) {
 	// Must have 3 media descriptions (2 video channels)
 	assert.Equal(t, len(offer.parsed.MediaDescriptions), 2)
 
-	assert.True(t, sdpMidHasSsrc(offer, "0", sender1.ssrc), "Expected mid %q with ssrc %d, offer.SDP: %s", "0", sender1.ssrc, offer.SDP)
+	assert.True(t, sdpMidHasSsrc(offer, "0", sender1.trackEncodings[0].ssrc), "Expected mid %q with ssrc %d, offer.SDP: %s", "0", sender1.trackEncodings[0].ssrc, offer.SDP)
 
 	// Remove first track, must keep same number of media
 	// descriptions and same track ssrc for mid 1 as previous
@@ -379,7 +381,7 @@ func TestPeerConnection_Transceiver_Mid(t *testing.T) {
 
 	assert.Equal(t, len(offer.parsed.MediaDescriptions), 2)
 
-	assert.True(t, sdpMidHasSsrc(offer, "1", sender2.ssrc), "Expected mid %q with ssrc %d, offer.SDP: %s", "1", sender2.ssrc, offer.SDP)
+	assert.True(t, sdpMidHasSsrc(offer, "1", sender2.trackEncodings[0].ssrc), "Expected mid %q with ssrc %d, offer.SDP: %s", "1", sender2.trackEncodings[0].ssrc, offer.SDP)
 
 	_, err = pcAnswer.CreateAnswer(nil)
 	assert.Error(t, err, &rtcerr.InvalidStateError{Err: ErrIncorrectSignalingState})
@@ -399,8 +401,8 @@ func TestPeerConnection_Transceiver_Mid(t *testing.T) {
 	// We reuse the existing non-sending transceiver
 	assert.Equal(t, len(offer.parsed.MediaDescriptions), 2)
 
-	assert.True(t, sdpMidHasSsrc(offer, "0", sender3.ssrc), "Expected mid %q with ssrc %d, offer.sdp: %s", "0", sender3.ssrc, offer.SDP)
-	assert.True(t, sdpMidHasSsrc(offer, "1", sender2.ssrc), "Expected mid %q with ssrc %d, offer.sdp: %s", "1", sender2.ssrc, offer.SDP)
+	assert.True(t, sdpMidHasSsrc(offer, "0", sender3.trackEncodings[0].ssrc), "Expected mid %q with ssrc %d, offer.sdp: %s", "0", sender3.trackEncodings[0].ssrc, offer.SDP)
+	assert.True(t, sdpMidHasSsrc(offer, "1", sender2.trackEncodings[0].ssrc), "Expected mid %q with ssrc %d, offer.sdp: %s", "1", sender2.trackEncodings[0].ssrc, offer.SDP)
 
 	closePairNow(t, pcOffer, pcAnswer)
 }
@@ -918,6 +920,35 @@ func TestNegotiationNeededRemoveTrack(t *testing.T) {
 	closePairNow(t, pcOffer, pcAnswer)
 }
 
+// Assert that RTPTransceiver.Stop fires OnNegotiationNeeded
+func TestNegotiationNeededStopTransceiver(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	negotiationNeeded := make(chan struct{}, 1)
+	pcOffer.OnNegotiationNeeded(func() {
+		negotiationNeeded <- struct{}{}
+	})
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+	<-negotiationNeeded
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	assert.NoError(t, sender.tr.Stop())
+	<-negotiationNeeded
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
 func TestNegotiationNeededStressOneSided(t *testing.T) {
 	lim := test.TimeOut(time.Second * 30)
 	defer lim.Stop()
@@ -976,12 +1007,12 @@ func TestPeerConnection_Renegotiation_DisableTrack(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, strings.Count(offer.SDP, "a=sendrecv"), 3)
 
-	// Assert we have two active transceivers, one inactive
+	// Assert we have two active transceivers, one rejected
 	assert.NoError(t, transceiver.Stop())
 	offer, err = pcOffer.CreateOffer(nil)
 	assert.NoError(t, err)
 	assert.Equal(t, strings.Count(offer.SDP, "a=sendrecv"), 2)
-	assert.Equal(t, strings.Count(offer.SDP, "a=inactive"), 1)
+	assert.Equal(t, strings.Count(offer.SDP, "m=video 0 "), 1)
 
 	// Assert that the offer disabled one of our transceivers
 	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
@@ -989,7 +1020,278 @@ func TestPeerConnection_Renegotiation_DisableTrack(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, strings.Count(answer.SDP, "a=sendrecv"), 1) // DataChannel
 	assert.Equal(t, strings.Count(answer.SDP, "a=recvonly"), 1)
-	assert.Equal(t, strings.Count(answer.SDP, "a=inactive"), 1)
+	assert.Equal(t, strings.Count(answer.SDP, "m=video 0 "), 1)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_Renegotiation_StopTransceiver(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	vp8Track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	sender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	mid := sender.tr.Mid()
+
+	assert.NoError(t, sender.tr.Stop())
+	assert.Equal(t, RTPTransceiverDirectionInactive, sender.tr.Direction())
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+
+	// The media section is rejected and doesn't announce the SSRCs anymore
+	media := getByMid(mid, &offer)
+	assert.NotNil(t, media)
+	assert.Equal(t, 0, media.MediaName.Port.Value)
+	assert.False(t, sdpMidHasSsrc(offer, mid, sender.trackEncodings[0].ssrc))
+
+	rejected := make(chan string, 1)
+	pcAnswer.OnMediaSectionRejected(func(mid string, kind RTPCodecType) {
+		rejected <- mid
+	})
+
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	assert.Equal(t, mid, <-rejected)
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+	assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+
+	media = getByMid(mid, &answer)
+	assert.NotNil(t, media)
+	assert.Equal(t, 0, media.MediaName.Port.Value)
+
+	for _, transceiver := range pcAnswer.GetTransceivers() {
+		if transceiver.Mid() == mid {
+			assert.True(t, transceiver.stopped.get())
+			assert.Equal(t, RTPTransceiverDirectionInactive, transceiver.Direction())
+		}
+	}
+
+	// Nothing left to negotiate and a stopped transceiver isn't reused
+	assert.False(t, pcOffer.checkNegotiationNeeded())
+	newSender, err := pcOffer.AddTrack(vp8Track)
+	assert.NoError(t, err)
+	assert.NotEqual(t, sender.tr, newSender.tr)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+// Assert that media sent for a media section of a description that isn't applied yet
+// is delivered once it is, or dropped with EarlyMediaHandlingDrop
+func TestPeerConnection_Renegotiation_EarlyMedia(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	for _, handling := range []EarlyMediaHandling{EarlyMediaHandlingBuffer, EarlyMediaHandlingDrop} {
+		handling := handling
+		t.Run(handling.String(), func(t *testing.T) {
+			m := &MediaEngine{}
+			assert.NoError(t, m.RegisterDefaultCodecs())
+			assert.NoError(t, m.RegisterHeaderExtension(RTPHeaderExtensionCapability{URI: sdp.SDESMidURI}, RTPCodecTypeVideo))
+			s := SettingEngine{}
+			s.SetEarlyMediaHandling(handling)
+			pcOffer, pcAnswer, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).newPair(Configuration{})
+			assert.NoError(t, err)
+
+			// The first media section negotiates the mid header extension, the answerer
+			// doesn't send on it
+			_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+			assert.NoError(t, err)
+			unusedTrack, err := NewTrackLocalStaticRTP(RTPCodecCapability{MimeType: MimeTypeVP8}, "unused", "pion")
+			assert.NoError(t, err)
+			_, err = pcAnswer.AddTrack(unusedTrack)
+			assert.NoError(t, err)
+			connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+			assert.NoError(t, signalPair(pcOffer, pcAnswer))
+			connected.Wait()
+
+			firstSequenceNumber := make(chan uint16, 1)
+			pcOffer.OnTrack(func(track *TrackRemote, r *RTPReceiver) {
+				pkt, _, readErr := track.ReadRTP()
+				assert.NoError(t, readErr)
+				firstSequenceNumber <- pkt.SequenceNumber
+			})
+
+			_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+			assert.NoError(t, err)
+			offer, err := pcOffer.CreateOffer(nil)
+			assert.NoError(t, err)
+			assert.NoError(t, pcOffer.SetLocalDescription(offer))
+			assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+
+			vp8Track, err := NewTrackLocalStaticRTP(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+			assert.NoError(t, err)
+			sender, err := pcAnswer.AddTrack(vp8Track)
+			assert.NoError(t, err)
+			answer, err := pcAnswer.CreateAnswer(nil)
+			assert.NoError(t, err)
+			assert.NoError(t, pcAnswer.SetLocalDescription(answer))
+
+			// The mid tells the offerer which media section the packets are sent for
+			var midExtensionID uint8
+			for _, extension := range sender.GetParameters().HeaderExtensions {
+				if extension.URI == sdp.SDESMidURI {
+					midExtensionID = uint8(extension.ID)
+				}
+			}
+			assert.NotZero(t, midExtensionID)
+
+			// The answerer sends before the offerer applied the answer
+			sequenceNumber := uint16(0)
+			writePacket := func() {
+				sequenceNumber++
+				pkt := &rtp.Packet{
+					Header:  rtp.Header{Version: 2, SequenceNumber: sequenceNumber},
+					Payload: []byte{0x00},
+				}
+				assert.NoError(t, pkt.Header.SetExtension(midExtensionID, []byte(sender.tr.Mid())))
+				assert.NoError(t, vp8Track.WriteRTP(pkt))
+			}
+			for i := 0; i < 5; i++ {
+				writePacket()
+			}
+			time.Sleep(500 * time.Millisecond)
+
+			assert.NoError(t, pcOffer.SetRemoteDescription(answer))
+			if handling == EarlyMediaHandlingBuffer {
+				assert.Equal(t, uint16(1), <-firstSequenceNumber)
+			} else {
+				func() {
+					ticker := time.NewTicker(20 * time.Millisecond)
+					defer ticker.Stop()
+					for {
+						select {
+						case first := <-firstSequenceNumber:
+							assert.Greater(t, first, uint16(5))
+							return
+						case <-ticker.C:
+							writePacket()
+						}
+					}
+				}()
+			}
+
+			closePairNow(t, pcOffer, pcAnswer)
+		})
+	}
+}
+
+func TestPeerConnection_Renegotiation_RemoveTrack_Reuse(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track1, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video1", "pion1")
+	assert.NoError(t, err)
+	track2, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video2", "pion2")
+	assert.NoError(t, err)
+
+	tracks := make(chan *TrackRemote, 2)
+	pcAnswer.OnTrack(func(track *TrackRemote, r *RTPReceiver) {
+		tracks <- track
+	})
+
+	sender1, err := pcOffer.AddTrack(track1)
+	assert.NoError(t, err)
+	transceiver := pcOffer.GetTransceivers()[0]
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go sendVideoUntilDone(ctx.Done(), t, []*TrackLocalStaticSample{track1})
+	assert.Equal(t, "video1", (<-tracks).ID())
+	cancel()
+
+	// The media section stays but doesn't send anymore
+	assert.NoError(t, pcOffer.RemoveTrack(sender1))
+	assert.Equal(t, RTPTransceiverDirectionRecvonly, transceiver.Direction())
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	assert.False(t, sdpMidHasSsrc(*pcOffer.LocalDescription(), "0", sender1.trackEncodings[0].ssrc))
+
+	// The next track of the same kind takes over the media section
+	sender2, err := pcOffer.AddTrack(track2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pcOffer.GetTransceivers()))
+	assert.True(t, transceiver == sender2.tr)
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, transceiver.Direction())
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go sendVideoUntilDone(ctx.Done(), t, []*TrackLocalStaticSample{track2})
+	assert.Equal(t, "video2", (<-tracks).ID())
+	cancel()
+
+	// Removing the track of a stopped transceiver only releases the sender
+	assert.NoError(t, transceiver.Stop())
+	assert.NoError(t, pcOffer.RemoveTrack(sender2))
+	assert.Nil(t, transceiver.Sender())
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+// Assert that with max-bundle only the media sections a subsequent offer adds
+// are bundle-only, the negotiated ones keep their port
+func TestPeerConnection_Renegotiation_MaxBundle_BundleOnly(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, err := NewPeerConnection(Configuration{BundlePolicy: BundlePolicyMaxBundle})
+	assert.NoError(t, err)
+
+	pcAnswer, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	pcOffer.OnMediaSectionRejected(func(mid string, kind RTPCodecType) {
+		t.Errorf("Media section %s rejected", mid)
+	})
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	_, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	// The offer adds the last media section
+	offer := pcOffer.CurrentLocalDescription().parsed
+	added := len(offer.MediaDescriptions) - 1
+	for _, media := range offer.MediaDescriptions[:added] {
+		assert.False(t, haveBundleOnly(media))
+		assert.NotZero(t, media.MediaName.Port.Value)
+	}
+	assert.True(t, haveBundleOnly(offer.MediaDescriptions[added]))
+	assert.Zero(t, offer.MediaDescriptions[added].MediaName.Port.Value)
+
+	for _, transceiver := range pcAnswer.GetTransceivers() {
+		assert.False(t, transceiver.stopped.get())
+	}
 
 	closePairNow(t, pcOffer, pcAnswer)
 }
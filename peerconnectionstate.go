@@ -82,6 +82,16 @@ func (t PeerConnectionState) String() string {
 	}
 }
 
+// PeerConnectionStateSnapshot holds the states of a PeerConnection taken at once,
+// see PeerConnection.GetState
+type PeerConnectionStateSnapshot struct {
+	SignalingState     SignalingState
+	ICEGatheringState  ICEGatheringState
+	ICEConnectionState ICEConnectionState
+	ConnectionState    PeerConnectionState
+	DTLSTransportState DTLSTransportState
+}
+
 type negotiationNeededState int
 
 const (
@@ -0,0 +1,411 @@
+// Package datachannelmux multiplexes lightweight streams over a single detached DataChannel.
+// It avoids the DCEP round trip and the per channel SCTP stream of DataChannels, and isn't
+// limited to 65535 of them. Each stream is reliable and ordered, and has its own flow control.
+//
+// Each frame is sent as one DataChannel message, so the DataChannel must be reliable and ordered.
+// One peer uses Client and the other one Server, usually the one that created the DataChannel
+// is the Client.
+package datachannelmux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	frameTypeOpen         = 0x00
+	frameTypeData         = 0x01
+	frameTypeWindowUpdate = 0x02
+	frameTypeClose        = 0x03
+
+	frameHeaderLength = 5
+
+	// maxFramePayload keeps frames below the message size supported by all DataChannel implementations
+	maxFramePayload = 16384
+
+	// initialWindow is the number of bytes a stream may send before the remote has read them
+	initialWindow = 256 * 1024
+
+	acceptBacklog = 256
+)
+
+var (
+	// ErrSessionClosed is returned when the Session or its DataChannel has been closed
+	ErrSessionClosed = errors.New("datachannelmux: session closed")
+
+	// ErrStreamClosed is returned when writing to a Stream that has been closed
+	ErrStreamClosed = errors.New("datachannelmux: stream closed")
+
+	errInvalidFrame   = errors.New("datachannelmux: invalid frame")
+	errWindowExceeded = errors.New("datachannelmux: remote exceeded the receive window")
+)
+
+// Session multiplexes Streams over a detached DataChannel
+type Session struct {
+	conn io.ReadWriteCloser
+
+	writeLock sync.Mutex
+
+	mu       sync.Mutex
+	streams  map[uint32]*Stream
+	nextID   uint32
+	closeErr error
+
+	acceptCh chan *Stream
+	closed   chan struct{}
+}
+
+// Client creates the Session of the peer that opens streams with odd IDs
+func Client(conn io.ReadWriteCloser) *Session {
+	return newSession(conn, 1)
+}
+
+// Server creates the Session of the peer that opens streams with even IDs
+func Server(conn io.ReadWriteCloser) *Session {
+	return newSession(conn, 2)
+}
+
+func newSession(conn io.ReadWriteCloser, firstID uint32) *Session {
+	s := &Session{
+		conn:     conn,
+		streams:  map[uint32]*Stream{},
+		nextID:   firstID,
+		acceptCh: make(chan *Stream, acceptBacklog),
+		closed:   make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+// Open creates a new Stream, the remote receives it from Accept
+func (s *Session) Open() (*Stream, error) {
+	s.mu.Lock()
+	if s.closeErr != nil {
+		s.mu.Unlock()
+		return nil, s.closeErr
+	}
+	stream := newStream(s, s.nextID)
+	s.streams[stream.id] = stream
+	s.nextID += 2
+	s.mu.Unlock()
+
+	if err := s.writeFrame(frameTypeOpen, stream.id, nil); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Accept waits for the next Stream opened by the remote
+func (s *Session) Accept() (*Stream, error) {
+	select {
+	case stream := <-s.acceptCh:
+		return stream, nil
+	case <-s.closed:
+		return nil, ErrSessionClosed
+	}
+}
+
+// Close closes all Streams and the DataChannel
+func (s *Session) Close() error {
+	if !s.shutdown(ErrSessionClosed) {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// shutdown closes all Streams with err, it returns false if the Session was already closed
+func (s *Session) shutdown(err error) bool {
+	s.mu.Lock()
+	if s.closeErr != nil {
+		s.mu.Unlock()
+		return false
+	}
+	s.closeErr = err
+	streams := s.streams
+	s.streams = map[uint32]*Stream{}
+	s.mu.Unlock()
+
+	close(s.closed)
+	for _, stream := range streams {
+		stream.closeWithError(err)
+	}
+	return true
+}
+
+func (s *Session) removeStream(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, id)
+}
+
+func (s *Session) writeFrame(frameType byte, id uint32, payload []byte) error {
+	frame := make([]byte, frameHeaderLength+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint32(frame[1:], id)
+	copy(frame[frameHeaderLength:], payload)
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	select {
+	case <-s.closed:
+		return ErrSessionClosed
+	default:
+	}
+
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+func (s *Session) readLoop() {
+	buf := make([]byte, frameHeaderLength+maxFramePayload)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			s.shutdown(ErrSessionClosed)
+			return
+		}
+
+		if err = s.handleFrame(buf[:n]); err != nil {
+			// The remote doesn't follow the protocol, there is no way to recover the streams
+			if s.shutdown(err) {
+				_ = s.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func (s *Session) handleFrame(frame []byte) error {
+	if len(frame) < frameHeaderLength {
+		return errInvalidFrame
+	}
+	id := binary.BigEndian.Uint32(frame[1:])
+	payload := frame[frameHeaderLength:]
+
+	s.mu.Lock()
+	stream, ok := s.streams[id]
+	if frame[0] == frameTypeOpen && !ok && s.closeErr == nil {
+		stream = newStream(s, id)
+		s.streams[id] = stream
+		s.mu.Unlock()
+
+		select {
+		case s.acceptCh <- stream:
+		default:
+			// Too many Streams are waiting to be accepted, reject it
+			stream.closeWithError(ErrStreamClosed)
+			s.removeStream(id)
+			return s.writeFrame(frameTypeClose, id, nil)
+		}
+		return nil
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		// Frames can still arrive for a Stream that was closed locally
+		return nil
+	}
+
+	switch frame[0] {
+	case frameTypeData:
+		return stream.handleData(payload)
+	case frameTypeWindowUpdate:
+		if len(payload) != 4 {
+			return errInvalidFrame
+		}
+		stream.handleWindowUpdate(binary.BigEndian.Uint32(payload))
+	case frameTypeClose:
+		stream.handleClose()
+	default:
+		return errInvalidFrame
+	}
+	return nil
+}
+
+// Stream is a reliable and ordered byte stream within a Session
+type Stream struct {
+	id      uint32
+	session *Session
+
+	mu         sync.Mutex
+	readBuf    bytes.Buffer
+	recvWindow uint32
+	consumed   uint32
+	sendWindow uint32
+
+	localClosed  bool
+	remoteClosed bool
+	closeErr     error
+
+	// notify wakes up blocked Read and Write calls
+	notify chan struct{}
+}
+
+func newStream(s *Session, id uint32) *Stream {
+	return &Stream{
+		id:         id,
+		session:    s,
+		recvWindow: initialWindow,
+		sendWindow: initialWindow,
+		notify:     make(chan struct{}),
+	}
+}
+
+// ID returns the identifier of the Stream, it is unique within the Session
+func (st *Stream) ID() uint32 {
+	return st.id
+}
+
+// Read reads data sent by the remote. It returns io.EOF once the remote closed the Stream
+// and all data has been read.
+func (st *Stream) Read(p []byte) (int, error) {
+	for {
+		st.mu.Lock()
+		if st.readBuf.Len() != 0 {
+			n, _ := st.readBuf.Read(p)
+			update := st.consumeLocked(uint32(n))
+			st.mu.Unlock()
+
+			if update != 0 {
+				buf := make([]byte, 4)
+				binary.BigEndian.PutUint32(buf, update)
+				_ = st.session.writeFrame(frameTypeWindowUpdate, st.id, buf)
+			}
+			return n, nil
+		}
+
+		switch {
+		case st.remoteClosed:
+			st.mu.Unlock()
+			return 0, io.EOF
+		case st.closeErr != nil:
+			st.mu.Unlock()
+			return 0, st.closeErr
+		}
+		notify := st.notify
+		st.mu.Unlock()
+
+		<-notify
+	}
+}
+
+// consumeLocked returns the window update to send once half of the window has been read
+func (st *Stream) consumeLocked(n uint32) uint32 {
+	st.consumed += n
+	if st.consumed < initialWindow/2 || st.remoteClosed {
+		return 0
+	}
+	update := st.consumed
+	st.recvWindow += update
+	st.consumed = 0
+	return update
+}
+
+// Write sends data to the remote, it blocks while the remote hasn't read enough of the
+// previously sent data
+func (st *Stream) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		st.mu.Lock()
+		switch {
+		case st.closeErr != nil:
+			st.mu.Unlock()
+			return written, st.closeErr
+		case st.localClosed:
+			st.mu.Unlock()
+			return written, ErrStreamClosed
+		case st.sendWindow == 0:
+			notify := st.notify
+			st.mu.Unlock()
+			<-notify
+			continue
+		}
+
+		n := len(p) - written
+		if n > maxFramePayload {
+			n = maxFramePayload
+		}
+		if uint32(n) > st.sendWindow {
+			n = int(st.sendWindow)
+		}
+		st.sendWindow -= uint32(n)
+		st.mu.Unlock()
+
+		if err := st.session.writeFrame(frameTypeData, st.id, p[written:written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Close tells the remote that no more data will be written. Data sent by the
+// remote can still be read until it closes the Stream as well.
+func (st *Stream) Close() error {
+	st.mu.Lock()
+	if st.localClosed || st.closeErr != nil {
+		st.mu.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	remoteClosed := st.remoteClosed
+	st.notifyLocked()
+	st.mu.Unlock()
+
+	if remoteClosed {
+		st.session.removeStream(st.id)
+	}
+	return st.session.writeFrame(frameTypeClose, st.id, nil)
+}
+
+func (st *Stream) handleData(payload []byte) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if uint32(len(payload)) > st.recvWindow {
+		return errWindowExceeded
+	}
+	st.recvWindow -= uint32(len(payload))
+	st.readBuf.Write(payload)
+	st.notifyLocked()
+	return nil
+}
+
+func (st *Stream) handleWindowUpdate(delta uint32) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.sendWindow += delta
+	st.notifyLocked()
+}
+
+func (st *Stream) handleClose() {
+	st.mu.Lock()
+	st.remoteClosed = true
+	localClosed := st.localClosed
+	st.notifyLocked()
+	st.mu.Unlock()
+
+	if localClosed {
+		st.session.removeStream(st.id)
+	}
+}
+
+func (st *Stream) closeWithError(err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.closeErr == nil {
+		st.closeErr = err
+		st.notifyLocked()
+	}
+}
+
+func (st *Stream) notifyLocked() {
+	close(st.notify)
+	st.notify = make(chan struct{})
+}
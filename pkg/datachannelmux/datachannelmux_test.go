@@ -0,0 +1,177 @@
+package datachannelmux
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// messageConn is one end of an in-memory message oriented connection, like a detached DataChannel
+type messageConn struct {
+	in, out   chan []byte
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+func newMessagePipe() (*messageConn, *messageConn) {
+	a, b := make(chan []byte, 64), make(chan []byte, 64)
+	closed, closeOnce := make(chan struct{}), &sync.Once{}
+	return &messageConn{in: a, out: b, closed: closed, closeOnce: closeOnce},
+		&messageConn{in: b, out: a, closed: closed, closeOnce: closeOnce}
+}
+
+func (c *messageConn) Read(p []byte) (int, error) {
+	select {
+	case msg := <-c.in:
+		if len(msg) > len(p) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(p, msg), nil
+	case <-c.closed:
+		return 0, io.EOF
+	}
+}
+
+func (c *messageConn) Write(p []byte) (int, error) {
+	select {
+	case c.out <- append([]byte{}, p...):
+		return len(p), nil
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+func (c *messageConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestSession(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	clientConn, serverConn := newMessagePipe()
+	client, server := Client(clientConn), Server(serverConn)
+
+	// Larger than the window, the writer has to wait for window updates
+	payload := bytes.Repeat([]byte{0xAB}, 3*initialWindow+100)
+
+	received := make(chan []byte)
+	go func() {
+		for {
+			stream, err := server.Accept()
+			if err != nil {
+				close(received)
+				return
+			}
+			go func() {
+				data, err := ioutil.ReadAll(stream)
+				assert.NoError(t, err)
+				assert.NoError(t, stream.Close())
+				received <- data
+			}()
+		}
+	}()
+
+	streams := []*Stream{}
+	for i := 0; i < 3; i++ {
+		stream, err := client.Open()
+		assert.NoError(t, err)
+		streams = append(streams, stream)
+	}
+	assert.Equal(t, []uint32{1, 3, 5}, []uint32{streams[0].ID(), streams[1].ID(), streams[2].ID()})
+
+	for _, stream := range streams {
+		go func(stream *Stream) {
+			n, err := stream.Write(payload)
+			assert.NoError(t, err)
+			assert.Equal(t, len(payload), n)
+			assert.NoError(t, stream.Close())
+		}(stream)
+	}
+
+	for range streams {
+		assert.Equal(t, payload, <-received)
+	}
+
+	// The remote closed as well, the streams are removed
+	for _, stream := range streams {
+		_, err := stream.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		_, err = stream.Write([]byte{0x00})
+		assert.Equal(t, ErrStreamClosed, err)
+	}
+	client.mu.Lock()
+	assert.Len(t, client.streams, 0)
+	client.mu.Unlock()
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, client.Close())
+	_, ok := <-received
+	assert.False(t, ok)
+
+	_, err := client.Open()
+	assert.Equal(t, ErrSessionClosed, err)
+	_, err = server.Accept()
+	assert.Equal(t, ErrSessionClosed, err)
+}
+
+func TestSession_CloseUnblocksStreams(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	clientConn, serverConn := newMessagePipe()
+	client, server := Client(clientConn), Server(serverConn)
+
+	stream, err := server.Open()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), stream.ID())
+
+	accepted, err := client.Accept()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), accepted.ID())
+
+	readErr := make(chan error)
+	go func() {
+		_, err := accepted.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	assert.NoError(t, server.Close())
+	assert.Equal(t, ErrSessionClosed, <-readErr)
+
+	_, err = stream.Write([]byte{0x00})
+	assert.Equal(t, ErrSessionClosed, err)
+}
+
+func TestSession_InvalidFrame(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	clientConn, serverConn := newMessagePipe()
+	server := Server(serverConn)
+
+	_, err := clientConn.Write([]byte{0x00})
+	assert.NoError(t, err)
+
+	_, err = server.Accept()
+	assert.Equal(t, ErrSessionClosed, err)
+	server.mu.Lock()
+	assert.Equal(t, errInvalidFrame, server.closeErr)
+	server.mu.Unlock()
+}
@@ -0,0 +1,126 @@
+// +build !js
+
+// Package ingest forwards RTP packets from an external source onto local tracks.
+// Any tool that sends RTP over UDP can be used as source, e.g. ffmpeg with an
+// rtp:// output, a GStreamer udpsink or an RTSP client using UDP transport.
+package ingest
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+const receiveMTU = 1600
+
+// TrackWriter is a track the packets are forwarded to, it is implemented by webrtc.TrackLocalStaticRTP.
+// The track rewrites SSRC and payload type to the values negotiated by each PeerConnection.
+// The payload of the packet is only valid until WriteRTP returns, its buffer is reused.
+type TrackWriter interface {
+	WriteRTP(*rtp.Packet) error
+	Codec() webrtc.RTPCodecCapability
+}
+
+// Bridge reads RTP packets from a PacketConn and forwards them to the track
+// registered for their payload type. Packets of other payload types are dropped.
+type Bridge struct {
+	conn net.PacketConn
+
+	mu     sync.Mutex
+	routes map[uint8]*route
+}
+
+// route keeps the sequence numbers and timestamps of a track continuous when
+// the source restarts and sends with a new SSRC
+type route struct {
+	track TrackWriter
+
+	started   bool
+	ssrc      uint32
+	seqOffset uint16
+	tsOffset  uint32
+
+	lastSequenceNumber uint16
+	lastTimestamp      uint32
+	lastTime           time.Time
+}
+
+// New creates a Bridge that reads from the PacketConn. Close the PacketConn to stop the Bridge.
+func New(conn net.PacketConn) *Bridge {
+	return &Bridge{
+		conn:   conn,
+		routes: map[uint8]*route{},
+	}
+}
+
+// AddTrack forwards packets with the given payload type to the track, replacing
+// any track that was added for it before.
+func (b *Bridge) AddTrack(payloadType uint8, track TrackWriter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.routes[payloadType] = &route{track: track}
+}
+
+// RemoveTrack stops forwarding packets with the given payload type
+func (b *Bridge) RemoveTrack(payloadType uint8) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.routes, payloadType)
+}
+
+// Run forwards packets until reading from the PacketConn fails, e.g. because it was closed.
+// Write errors of a track are not fatal, they happen when one of its PeerConnections failed.
+func (b *Bridge) Run() error {
+	buf := make([]byte, receiveMTU)
+	for {
+		n, _, err := b.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		packet := &rtp.Packet{}
+		if err = packet.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+		b.forward(packet, time.Now())
+	}
+}
+
+func (b *Bridge) forward(packet *rtp.Packet, now time.Time) {
+	b.mu.Lock()
+	r, ok := b.routes[packet.PayloadType]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	r.rewrite(packet, now)
+	track := r.track
+	b.mu.Unlock()
+
+	_ = track.WriteRTP(packet)
+}
+
+func (r *route) rewrite(packet *rtp.Packet, now time.Time) {
+	isNewSource := r.started && packet.SSRC != r.ssrc
+	if isNewSource {
+		// Continue after the last packet, advancing the timestamp by the time that passed in between
+		elapsed := uint32(now.Sub(r.lastTime).Seconds() * float64(r.track.Codec().ClockRate))
+		r.seqOffset = r.lastSequenceNumber + 1 - packet.SequenceNumber
+		r.tsOffset = r.lastTimestamp + elapsed - packet.Timestamp
+	}
+
+	packet.SequenceNumber += r.seqOffset
+	packet.Timestamp += r.tsOffset
+
+	// Reordered packets don't move the position the next source continues at
+	if !r.started || isNewSource || int16(packet.SequenceNumber-r.lastSequenceNumber) > 0 {
+		r.lastSequenceNumber = packet.SequenceNumber
+		r.lastTimestamp = packet.Timestamp
+		r.lastTime = now
+	}
+	r.started = true
+	r.ssrc = packet.SSRC
+}
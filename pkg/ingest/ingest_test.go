@@ -0,0 +1,102 @@
+// +build !js
+
+package ingest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTrack struct {
+	packets chan *rtp.Packet
+}
+
+func (t *testTrack) WriteRTP(p *rtp.Packet) error {
+	t.packets <- p
+	return nil
+}
+
+func (t *testTrack) Codec() webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}
+}
+
+func TestBridge_Rewrite(t *testing.T) {
+	track := &testTrack{packets: make(chan *rtp.Packet, 10)}
+	b := New(nil)
+	b.AddTrack(96, track)
+
+	start := time.Now()
+	forward := func(ssrc uint32, sequenceNumber uint16, timestamp uint32, now time.Time) *rtp.Packet {
+		b.forward(&rtp.Packet{Header: rtp.Header{PayloadType: 96, SSRC: ssrc, SequenceNumber: sequenceNumber, Timestamp: timestamp}}, now)
+		return <-track.packets
+	}
+
+	// The first source is forwarded as is, reordered packets included
+	p := forward(1, 100, 1000, start)
+	assert.Equal(t, uint16(100), p.SequenceNumber)
+	assert.Equal(t, uint32(1000), p.Timestamp)
+	p = forward(1, 102, 7000, start.Add(time.Second/30))
+	assert.Equal(t, uint16(102), p.SequenceNumber)
+	p = forward(1, 101, 4000, start.Add(time.Second/30))
+	assert.Equal(t, uint16(101), p.SequenceNumber)
+
+	// A restarted source continues after the last packet
+	p = forward(2, 5000, 123456, start.Add(time.Second/30+time.Second))
+	assert.Equal(t, uint16(103), p.SequenceNumber)
+	assert.Equal(t, uint32(7000+90000), p.Timestamp)
+	p = forward(2, 5001, 126456, start.Add(2*time.Second))
+	assert.Equal(t, uint16(104), p.SequenceNumber)
+	assert.Equal(t, uint32(7000+90000+3000), p.Timestamp)
+
+	// Other payload types are dropped
+	b.forward(&rtp.Packet{Header: rtp.Header{PayloadType: 111}}, start)
+	b.RemoveTrack(96)
+	b.forward(&rtp.Packet{Header: rtp.Header{PayloadType: 96}}, start)
+	assert.Len(t, track.packets, 0)
+}
+
+func TestBridge_Run(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+
+	track := &testTrack{packets: make(chan *rtp.Packet, 10)}
+	b := New(listener)
+	b.AddTrack(96, track)
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- b.Run()
+	}()
+
+	sender, err := net.DialUDP("udp4", nil, listener.LocalAddr().(*net.UDPAddr))
+	assert.NoError(t, err)
+
+	// Packets that are not RTP are dropped
+	_, err = sender.Write([]byte{0x00})
+	assert.NoError(t, err)
+
+	raw, err := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96, SSRC: 5, SequenceNumber: 10}, Payload: []byte{0x01}}).Marshal()
+	assert.NoError(t, err)
+	_, err = sender.Write(raw)
+	assert.NoError(t, err)
+
+	p := <-track.packets
+	assert.Equal(t, uint16(10), p.SequenceNumber)
+	assert.Equal(t, []byte{0x01}, p.Payload)
+
+	assert.NoError(t, sender.Close())
+	assert.NoError(t, listener.Close())
+	assert.Error(t, <-runErr)
+}
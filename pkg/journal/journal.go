@@ -0,0 +1,274 @@
+// +build !js
+
+// Package journal implements at-least-once delivery of DataChannel messages.
+// Messages are journaled until the remote acknowledges them and are sent again
+// when a DataChannel is attached or re-opened, e.g. after an ICE restart or after
+// a new PeerConnection has been established. Both peers have to use a Journal.
+//
+// Acknowledgements are cumulative and the remote drops messages with a sequence number
+// it has already passed, so a message that is lost or arrives late would be dropped
+// and acknowledged with the ones after it. A Journal therefore only works over ordered,
+// fully reliable DataChannels.
+package journal
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	frameTypeMessage = 0x00
+	frameTypeAck     = 0x01
+
+	frameFlagString = 0x01
+
+	frameHeaderLength = 10
+)
+
+var errInvalidFrame = errors.New("journal: invalid frame")
+
+// ErrUnreliableDataChannel is returned by Attach for a DataChannel that is unordered
+// or partially reliable
+var ErrUnreliableDataChannel = errors.New("journal: DataChannel must be ordered and reliable")
+
+// Message is an application message that has not been acknowledged yet
+type Message struct {
+	Sequence uint64
+	IsString bool
+	Data     []byte
+}
+
+// Store persists the journaled messages. Implementations can write them to disk to
+// survive a restart of the application, NewMemoryStore keeps them in memory.
+type Store interface {
+	// Append adds a message that has been sent but not acknowledged yet
+	Append(Message) error
+	// Acknowledge removes all messages with a sequence number up to and including sequence
+	Acknowledge(sequence uint64) error
+	// Messages returns the unacknowledged messages in the order they were appended
+	Messages() ([]Message, error)
+	// LastSequence returns the sequence number of the last appended message, also once it
+	// has been acknowledged, or zero if none has been appended. The remote drops messages
+	// with a sequence number it has already received, so it must not go back after a restart.
+	LastSequence() (uint64, error)
+}
+
+// Journal sends DataChannel messages until the remote Journal acknowledged them
+type Journal struct {
+	mu sync.Mutex
+
+	store        Store
+	dataChannel  *webrtc.DataChannel
+	nextSequence uint64
+	lastReceived uint64
+
+	// Acks are cumulative, once a message couldn't be sent the following ones are only
+	// journaled until the next replay, so the remote can't acknowledge past the lost one
+	sendFailed bool
+
+	onMessageHandler func(webrtc.DataChannelMessage)
+}
+
+// New creates a Journal that continues with the messages that are still in the Store
+func New(store Store) (*Journal, error) {
+	lastSequence, err := store.LastSequence()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{store: store, nextSequence: lastSequence + 1}, nil
+}
+
+// Attach sends and receives messages over the DataChannel, replacing any previously
+// attached one. The unacknowledged messages are sent again once the DataChannel is open.
+// Attach sets the OnOpen and OnMessage handlers of the DataChannel, use
+// Journal.OnMessage to receive the messages. It returns ErrUnreliableDataChannel if
+// the DataChannel is unordered or has MaxRetransmits or MaxPacketLifeTime set.
+func (j *Journal) Attach(d *webrtc.DataChannel) error {
+	if !d.Ordered() || d.MaxRetransmits() != nil || d.MaxPacketLifeTime() != nil {
+		return ErrUnreliableDataChannel
+	}
+
+	j.mu.Lock()
+	j.dataChannel = d
+	j.mu.Unlock()
+
+	d.OnMessage(func(msg webrtc.DataChannelMessage) {
+		// Frames that are not from a Journal are ignored
+		_ = j.handleFrame(d, msg.Data)
+	})
+	d.OnOpen(func() {
+		_ = j.replay(d)
+	})
+	return nil
+}
+
+// OnMessage sets an event handler which is invoked for every message from the remote
+// Journal. A message may be delivered again if it was sent before the remote restarted
+// without persisting it as acknowledged.
+func (j *Journal) OnMessage(f func(webrtc.DataChannelMessage)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.onMessageHandler = f
+}
+
+// Send journals a binary message and sends it if a DataChannel is open.
+// Otherwise it is sent once a DataChannel is attached and open.
+func (j *Journal) Send(data []byte) error {
+	return j.send(data, false)
+}
+
+// SendText journals a text message and sends it if a DataChannel is open.
+// Otherwise it is sent once a DataChannel is attached and open.
+func (j *Journal) SendText(s string) error {
+	return j.send([]byte(s), true)
+}
+
+// Pending returns the number of messages that have not been acknowledged yet
+func (j *Journal) Pending() (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	messages, err := j.store.Messages()
+	return len(messages), err
+}
+
+func (j *Journal) send(data []byte, isString bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	message := Message{Sequence: j.nextSequence, IsString: isString, Data: append([]byte{}, data...)}
+	if err := j.store.Append(message); err != nil {
+		return err
+	}
+	j.nextSequence++
+
+	if j.sendFailed || j.dataChannel == nil || j.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return nil
+	}
+
+	// The message is journaled, if sending fails it is sent again on the next open
+	if err := j.dataChannel.Send(marshalMessage(message)); err != nil {
+		j.sendFailed = true
+	}
+	return nil
+}
+
+// replay sends all unacknowledged messages over the DataChannel
+func (j *Journal) replay(d *webrtc.DataChannel) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.dataChannel != d {
+		return nil
+	}
+
+	j.sendFailed = true
+	messages, err := j.store.Messages()
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err = d.Send(marshalMessage(message)); err != nil {
+			return err
+		}
+	}
+	j.sendFailed = false
+	return nil
+}
+
+func (j *Journal) handleFrame(d *webrtc.DataChannel, frame []byte) error {
+	if len(frame) < frameHeaderLength {
+		return errInvalidFrame
+	}
+	sequence := binary.BigEndian.Uint64(frame[2:frameHeaderLength])
+
+	switch frame[0] {
+	case frameTypeAck:
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		return j.store.Acknowledge(sequence)
+	case frameTypeMessage:
+	default:
+		return errInvalidFrame
+	}
+
+	j.mu.Lock()
+	isNew := sequence > j.lastReceived
+	if isNew {
+		j.lastReceived = sequence
+	}
+	handler := j.onMessageHandler
+	j.mu.Unlock()
+
+	// Duplicates are acknowledged again, the previous ack may have been lost
+	if d != nil {
+		if err := d.Send(marshalAck(sequence)); err != nil {
+			return err
+		}
+	}
+
+	if isNew && handler != nil {
+		handler(webrtc.DataChannelMessage{
+			IsString: frame[1]&frameFlagString != 0,
+			Data:     frame[frameHeaderLength:],
+		})
+	}
+	return nil
+}
+
+func marshalMessage(message Message) []byte {
+	frame := make([]byte, frameHeaderLength+len(message.Data))
+	frame[0] = frameTypeMessage
+	if message.IsString {
+		frame[1] = frameFlagString
+	}
+	binary.BigEndian.PutUint64(frame[2:], message.Sequence)
+	copy(frame[frameHeaderLength:], message.Data)
+	return frame
+}
+
+func marshalAck(sequence uint64) []byte {
+	frame := make([]byte, frameHeaderLength)
+	frame[0] = frameTypeAck
+	binary.BigEndian.PutUint64(frame[2:], sequence)
+	return frame
+}
+
+type memoryStore struct {
+	messages     []Message
+	lastSequence uint64
+}
+
+// NewMemoryStore creates a Store that keeps the messages in memory. Messages
+// survive re-opening a DataChannel, but not a restart of the application.
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Append(message Message) error {
+	s.messages = append(s.messages, message)
+	s.lastSequence = message.Sequence
+	return nil
+}
+
+func (s *memoryStore) Acknowledge(sequence uint64) error {
+	i := 0
+	for i < len(s.messages) && s.messages[i].Sequence <= sequence {
+		i++
+	}
+	s.messages = s.messages[i:]
+	return nil
+}
+
+func (s *memoryStore) Messages() ([]Message, error) {
+	return append([]Message{}, s.messages...), nil
+}
+
+func (s *memoryStore) LastSequence() (uint64, error) {
+	return s.lastSequence, nil
+}
@@ -0,0 +1,162 @@
+// +build !js
+
+package journal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	for i := uint64(1); i <= 3; i++ {
+		assert.NoError(t, store.Append(Message{Sequence: i, Data: []byte{byte(i)}}))
+	}
+
+	assert.NoError(t, store.Acknowledge(2))
+	messages, err := store.Messages()
+	assert.NoError(t, err)
+	assert.Equal(t, []Message{{Sequence: 3, Data: []byte{3}}}, messages)
+
+	j, err := New(store)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), j.nextSequence)
+
+	// The sequence continues after all messages have been acknowledged
+	assert.NoError(t, store.Acknowledge(3))
+	j, err = New(store)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), j.nextSequence)
+}
+
+func TestJournal_HandleFrame(t *testing.T) {
+	j, err := New(NewMemoryStore())
+	assert.NoError(t, err)
+
+	received := []webrtc.DataChannelMessage{}
+	j.OnMessage(func(msg webrtc.DataChannelMessage) {
+		received = append(received, msg)
+	})
+
+	assert.NoError(t, j.handleFrame(nil, marshalMessage(Message{Sequence: 1, IsString: true, Data: []byte("a")})))
+	assert.NoError(t, j.handleFrame(nil, marshalMessage(Message{Sequence: 2, Data: []byte("b")})))
+	// Replayed messages are dropped
+	assert.NoError(t, j.handleFrame(nil, marshalMessage(Message{Sequence: 1, IsString: true, Data: []byte("a")})))
+	assert.Equal(t, []webrtc.DataChannelMessage{
+		{IsString: true, Data: []byte("a")},
+		{Data: []byte("b")},
+	}, received)
+
+	assert.Equal(t, errInvalidFrame, j.handleFrame(nil, []byte{frameTypeMessage}))
+	invalidType := marshalAck(1)
+	invalidType[0] = 0xFF
+	assert.Equal(t, errInvalidFrame, j.handleFrame(nil, invalidType))
+
+	assert.NoError(t, j.SendText("c"))
+	assert.NoError(t, j.Send([]byte("d")))
+	pending, err := j.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pending)
+
+	assert.NoError(t, j.handleFrame(nil, marshalAck(2)))
+	pending, err = j.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pending)
+}
+
+func TestJournal_DataChannel(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	// Messages journaled before a restart of the application are sent again
+	store := NewMemoryStore()
+	assert.NoError(t, store.Append(Message{Sequence: 1, IsString: true, Data: []byte("before restart")}))
+
+	sender, err := New(store)
+	assert.NoError(t, err)
+	receiver, err := New(NewMemoryStore())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sender.SendText("before open"))
+
+	received := make(chan webrtc.DataChannelMessage, 3)
+	receiver.OnMessage(func(msg webrtc.DataChannelMessage) {
+		received <- msg
+	})
+
+	offerPC, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	answerPC, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+
+	answerPC.OnDataChannel(func(d *webrtc.DataChannel) {
+		assert.NoError(t, receiver.Attach(d))
+	})
+	dc, err := offerPC.CreateDataChannel("journal", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sender.Attach(dc))
+
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	offerGatheringComplete := webrtc.GatheringCompletePromise(offerPC)
+	assert.NoError(t, offerPC.SetLocalDescription(offer))
+	<-offerGatheringComplete
+	assert.NoError(t, answerPC.SetRemoteDescription(*offerPC.LocalDescription()))
+
+	answer, err := answerPC.CreateAnswer(nil)
+	assert.NoError(t, err)
+	answerGatheringComplete := webrtc.GatheringCompletePromise(answerPC)
+	assert.NoError(t, answerPC.SetLocalDescription(answer))
+	<-answerGatheringComplete
+	assert.NoError(t, offerPC.SetRemoteDescription(*answerPC.LocalDescription()))
+
+	assert.Equal(t, "before restart", string((<-received).Data))
+	assert.Equal(t, "before open", string((<-received).Data))
+
+	assert.NoError(t, sender.Send([]byte("after open")))
+	msg := <-received
+	assert.False(t, msg.IsString)
+	assert.Equal(t, "after open", string(msg.Data))
+
+	for {
+		pending, pendingErr := sender.Pending()
+		assert.NoError(t, pendingErr)
+		if pending == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.NoError(t, offerPC.Close())
+	assert.NoError(t, answerPC.Close())
+}
+
+func TestJournal_AttachUnreliable(t *testing.T) {
+	j, err := New(NewMemoryStore())
+	assert.NoError(t, err)
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+
+	ordered := false
+	maxRetransmits := uint16(0)
+	maxPacketLifeTime := uint16(100)
+	for _, init := range []*webrtc.DataChannelInit{
+		{Ordered: &ordered},
+		{MaxRetransmits: &maxRetransmits},
+		{MaxPacketLifeTime: &maxPacketLifeTime},
+	} {
+		dc, err := pc.CreateDataChannel("journal", init)
+		assert.NoError(t, err)
+		assert.Equal(t, ErrUnreliableDataChannel, j.Attach(dc))
+	}
+
+	assert.NoError(t, pc.Close())
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
+
+	"github.com/pion/webrtc/v3/pkg/media"
 )
 
 const (
@@ -143,3 +146,82 @@ func (i *IVFReader) parseFileHeader() (*IVFFileHeader, error) {
 	i.bytesReadSuccesfully += int64(bytesRead)
 	return header, nil
 }
+
+// SampleReader reads the frames of an IVF file as media.Samples. The Duration of a
+// sample is the time until the next frame, so they can be written to a TrackLocal as is.
+type SampleReader struct {
+	stream io.ReadSeeker
+	loop   bool
+	reader *IVFReader
+	header *IVFFileHeader
+
+	frame       []byte
+	frameHeader *IVFFrameHeader
+	duration    time.Duration
+}
+
+// NewSampleReader returns a new SampleReader and the IVF file header. If loop is
+// set the reader starts over at the beginning of the file instead of returning io.EOF.
+func NewSampleReader(in io.ReadSeeker, loop bool) (*SampleReader, *IVFFileHeader, error) {
+	reader, header, err := NewWith(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &SampleReader{
+		stream: in,
+		loop:   loop,
+		reader: reader,
+		header: header,
+	}
+	s.duration = s.timestampToDuration(1)
+	return s, header, nil
+}
+
+// NextSample returns the next frame, or io.EOF once all frames have been read
+func (s *SampleReader) NextSample() (media.Sample, error) {
+	if s.frame == nil {
+		frame, frameHeader, err := s.readFrame()
+		if err != nil {
+			return media.Sample{}, err
+		}
+		s.frame, s.frameHeader = frame, frameHeader
+	}
+
+	// Look ahead to compute the duration, the last frame keeps the previous one
+	nextFrame, nextFrameHeader, err := s.readFrame()
+	switch {
+	case errors.Is(err, io.EOF):
+	case err != nil:
+		return media.Sample{}, err
+	case nextFrameHeader.Timestamp > s.frameHeader.Timestamp:
+		s.duration = s.timestampToDuration(nextFrameHeader.Timestamp - s.frameHeader.Timestamp)
+	}
+
+	sample := media.Sample{Data: s.frame, Duration: s.duration}
+	s.frame, s.frameHeader = nextFrame, nextFrameHeader
+	return sample, nil
+}
+
+func (s *SampleReader) readFrame() ([]byte, *IVFFrameHeader, error) {
+	frame, frameHeader, err := s.reader.ParseNextFrame()
+	if !errors.Is(err, io.EOF) || !s.loop {
+		return frame, frameHeader, err
+	}
+
+	if _, err = s.stream.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	if s.reader, _, err = NewWith(s.stream); err != nil {
+		return nil, nil, err
+	}
+	return s.reader.ParseNextFrame()
+}
+
+// timestampToDuration converts a frame timestamp, which is in units of the timebase of the file
+func (s *SampleReader) timestampToDuration(timestamp uint64) time.Duration {
+	if s.header.TimebaseDenominator == 0 {
+		return 0
+	}
+	return time.Duration(timestamp) * time.Second * time.Duration(s.header.TimebaseNumerator) / time.Duration(s.header.TimebaseDenominator)
+}
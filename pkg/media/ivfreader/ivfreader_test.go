@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"io"
 	"testing"
+	"time"
 
+	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -165,3 +167,49 @@ func TestIVFReader_EOFWhenNoFramesLeft(t *testing.T) {
 
 	assert.Equal(io.EOF, err)
 }
+
+func TestIVFReader_SampleReader(t *testing.T) {
+	assert := assert.New(t)
+
+	// Frames with timestamps 0, 1 and 3, payloads 0x01, 0x02, 0x03
+	frames := [][]byte{}
+	for i, timestamp := range []byte{0, 1, 3} {
+		frames = append(frames, []byte{
+			0x01, 0x00, 0x00, 0x00, timestamp, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, byte(i + 1),
+		})
+	}
+	ivf := bytes.NewReader(buildIVFContainer(&frames[0], &frames[1], &frames[2]).Bytes())
+
+	// The timebase of the test file is 1000/30000, a timestamp increment of 1 is 33.3ms
+	frameDuration := time.Second / 30
+
+	reader, header, err := NewSampleReader(ivf, false)
+	assert.NoError(err)
+	assert.Equal("VP80", header.FourCC)
+
+	for _, expected := range []media.Sample{
+		{Data: []byte{0x01}, Duration: frameDuration},
+		{Data: []byte{0x02}, Duration: 2 * frameDuration},
+		{Data: []byte{0x03}, Duration: 2 * frameDuration},
+	} {
+		sample, err := reader.NextSample()
+		assert.NoError(err)
+		assert.Equal(expected, sample)
+	}
+
+	_, err = reader.NextSample()
+	assert.Equal(io.EOF, err)
+
+	// With looping the first frame follows the last one
+	_, err = ivf.Seek(0, io.SeekStart)
+	assert.NoError(err)
+	reader, _, err = NewSampleReader(ivf, true)
+	assert.NoError(err)
+
+	for _, expected := range []byte{0x01, 0x02, 0x03, 0x01, 0x02} {
+		sample, err := reader.NextSample()
+		assert.NoError(err)
+		assert.Equal([]byte{expected}, sample.Data)
+	}
+}
@@ -4,16 +4,24 @@ package ivfwriter
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
 )
 
+const (
+	mimeTypeVP8 = "video/VP8"
+	mimeTypeVP9 = "video/VP9"
+)
+
 var (
 	errFileNotOpened    = errors.New("file not opened")
 	errInvalidNilPacket = errors.New("invalid nil packet")
+	errCodecUnsupported = errors.New("codec is not supported by the IVF writer")
 )
 
 // IVFWriter is used to take RTP packets and write them to an IVF on disk
@@ -22,15 +30,35 @@ type IVFWriter struct {
 	count        uint64
 	seenKeyFrame bool
 	currentFrame []byte
+	isVP9        bool
+}
+
+// Option configures an IVFWriter
+type Option func(*IVFWriter) error
+
+// WithCodec sets the codec of the written frames, either video/VP8 or video/VP9.
+// VP8 is used by default.
+func WithCodec(mimeType string) Option {
+	return func(i *IVFWriter) error {
+		switch {
+		case strings.EqualFold(mimeType, mimeTypeVP8):
+			i.isVP9 = false
+		case strings.EqualFold(mimeType, mimeTypeVP9):
+			i.isVP9 = true
+		default:
+			return fmt.Errorf("%w: %s", errCodecUnsupported, mimeType)
+		}
+		return nil
+	}
 }
 
 // New builds a new IVF writer
-func New(fileName string) (*IVFWriter, error) {
+func New(fileName string, opts ...Option) (*IVFWriter, error) {
 	f, err := os.Create(fileName)
 	if err != nil {
 		return nil, err
 	}
-	writer, err := NewWith(f)
+	writer, err := NewWith(f, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +67,7 @@ func New(fileName string) (*IVFWriter, error) {
 }
 
 // NewWith initialize a new IVF writer with an io.Writer output
-func NewWith(out io.Writer) (*IVFWriter, error) {
+func NewWith(out io.Writer, opts ...Option) (*IVFWriter, error) {
 	if out == nil {
 		return nil, errFileNotOpened
 	}
@@ -48,6 +76,11 @@ func NewWith(out io.Writer) (*IVFWriter, error) {
 		ioWriter:     out,
 		seenKeyFrame: false,
 	}
+	for _, o := range opts {
+		if err := o(writer); err != nil {
+			return nil, err
+		}
+	}
 	if err := writer.writeHeader(); err != nil {
 		return nil, err
 	}
@@ -55,11 +88,16 @@ func NewWith(out io.Writer) (*IVFWriter, error) {
 }
 
 func (i *IVFWriter) writeHeader() error {
+	fourcc := "VP80"
+	if i.isVP9 {
+		fourcc = "VP90"
+	}
+
 	header := make([]byte, 32)
 	copy(header[0:], "DKIF")                        // DKIF
 	binary.LittleEndian.PutUint16(header[4:], 0)    // Version
 	binary.LittleEndian.PutUint16(header[6:], 32)   // Header size
-	copy(header[8:], "VP80")                        // FOURCC
+	copy(header[8:], fourcc)                        // FOURCC
 	binary.LittleEndian.PutUint16(header[12:], 640) // Width in pixels
 	binary.LittleEndian.PutUint16(header[14:], 480) // Height in pixels
 	binary.LittleEndian.PutUint32(header[16:], 30)  // Framerate denominator
@@ -77,21 +115,33 @@ func (i *IVFWriter) WriteRTP(packet *rtp.Packet) error {
 		return errFileNotOpened
 	}
 
-	vp8Packet := codecs.VP8Packet{}
-	if _, err := vp8Packet.Unmarshal(packet.Payload); err != nil {
-		return err
+	var (
+		payload            []byte
+		isKeyFrame, isHead bool
+	)
+	if i.isVP9 {
+		vp9Packet := codecs.VP9Packet{}
+		if _, err := vp9Packet.Unmarshal(packet.Payload); err != nil {
+			return err
+		}
+		payload, isKeyFrame, isHead = vp9Packet.Payload, !vp9Packet.P, vp9Packet.B
+	} else {
+		vp8Packet := codecs.VP8Packet{}
+		if _, err := vp8Packet.Unmarshal(packet.Payload); err != nil {
+			return err
+		}
+		payload, isKeyFrame, isHead = vp8Packet.Payload, vp8Packet.Payload[0]&0x01 == 0, vp8Packet.S == 1
 	}
 
-	isKeyFrame := vp8Packet.Payload[0] & 0x01
 	switch {
-	case !i.seenKeyFrame && isKeyFrame == 1:
+	case !i.seenKeyFrame && !isKeyFrame:
 		return nil
-	case i.currentFrame == nil && vp8Packet.S != 1:
+	case i.currentFrame == nil && !isHead:
 		return nil
 	}
 
 	i.seenKeyFrame = true
-	i.currentFrame = append(i.currentFrame, vp8Packet.Payload[0:]...)
+	i.currentFrame = append(i.currentFrame, payload...)
 
 	if !packet.Marker {
 		return nil
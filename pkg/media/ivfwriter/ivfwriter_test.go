@@ -230,3 +230,25 @@ func TestIVFWriter_AddPacketAndClose(t *testing.T) {
 		}
 	}
 }
+
+func TestIVFWriter_VP9(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := NewWith(buffer, WithCodec("video/vp9"))
+	assert.NoError(t, err)
+	assert.Equal(t, "VP90", string(buffer.Bytes()[8:12]))
+
+	// Inter frame before the first keyframe is dropped
+	assert.NoError(t, writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true}, Payload: []byte{0x4C, 0x01}}))
+	assert.Equal(t, uint64(0), writer.count)
+
+	// Keyframe split across two packets
+	assert.NoError(t, writer.WriteRTP(&rtp.Packet{Payload: []byte{0x08, 0xAA, 0xBB}}))
+	assert.NoError(t, writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true}, Payload: []byte{0x04, 0xCC}}))
+	assert.Equal(t, uint64(1), writer.count)
+	assert.Equal(t, []byte{0xAA, 0xBB, 0xCC}, buffer.Bytes()[32+12:])
+
+	assert.NoError(t, writer.Close())
+
+	_, err = NewWith(&bytes.Buffer{}, WithCodec("video/H264"))
+	assert.ErrorIs(t, err, errCodecUnsupported)
+}
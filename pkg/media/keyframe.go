@@ -0,0 +1,279 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/rtp/codecs"
+)
+
+const (
+	mimeTypeVP8  = "video/VP8"
+	mimeTypeVP9  = "video/VP9"
+	mimeTypeH264 = "video/H264"
+	mimeTypeAV1  = "video/AV1"
+
+	h264NALUTypeIDR   = 5
+	h264NALUTypeSPS   = 7
+	h264NALUTypeSTAPA = 24
+	h264NALUTypeFUA   = 28
+
+	av1OBUTypeSequenceHeader = 1
+	av1OBUTypeFrameHeader    = 3
+	av1OBUTypeFrame          = 6
+)
+
+var (
+	errKeyFrameCodecUnsupported = errors.New("keyframe detection is not supported for codec")
+	errFrameTooShort            = errors.New("frame is too short")
+)
+
+// IsKeyFrame reports whether a depacketized frame of the given codec is a keyframe,
+// so decoding can start with it. Supported are video/VP8, video/VP9, video/H264 as
+// Annex B byte stream and video/AV1 as a sequence of OBUs, the codec is matched
+// case insensitive.
+func IsKeyFrame(mimeType string, frame []byte) (bool, error) {
+	switch {
+	case strings.EqualFold(mimeType, mimeTypeVP8):
+		return isVP8KeyFrame(frame)
+	case strings.EqualFold(mimeType, mimeTypeVP9):
+		return isVP9KeyFrame(frame)
+	case strings.EqualFold(mimeType, mimeTypeH264):
+		return isH264KeyFrame(frame)
+	case strings.EqualFold(mimeType, mimeTypeAV1):
+		return isAV1KeyFrame(frame)
+	default:
+		return false, fmt.Errorf("%w: %s", errKeyFrameCodecUnsupported, mimeType)
+	}
+}
+
+// IsKeyFramePacket reports whether the payload of an RTP packet of the given codec starts
+// a keyframe, so forwarding can switch to the stream with it, e.g. to another simulcast
+// layer. Only the first packet of a keyframe is detected:
+//   - video/VP8: the start of the first partition with the P bit of the frame tag cleared
+//   - video/VP9: the start of a frame of the base spatial layer that isn't inter-picture predicted
+//   - video/H264: an IDR slice or the SPS sent ahead of it, as single NAL unit, in a
+//     STAP-A or the start of a FU-A
+// The codec is matched case insensitive.
+func IsKeyFramePacket(mimeType string, payload []byte) (bool, error) {
+	switch {
+	case strings.EqualFold(mimeType, mimeTypeVP8):
+		return isVP8KeyFramePacket(payload)
+	case strings.EqualFold(mimeType, mimeTypeVP9):
+		return isVP9KeyFramePacket(payload)
+	case strings.EqualFold(mimeType, mimeTypeH264):
+		return isH264KeyFramePacket(payload)
+	default:
+		return false, fmt.Errorf("%w: %s", errKeyFrameCodecUnsupported, mimeType)
+	}
+}
+
+func isVP8KeyFramePacket(payload []byte) (bool, error) {
+	vp8Packet := codecs.VP8Packet{}
+	if _, err := vp8Packet.Unmarshal(payload); err != nil {
+		return false, err
+	}
+	if vp8Packet.S != 1 || vp8Packet.PID != 0 || len(vp8Packet.Payload) == 0 {
+		return false, nil
+	}
+	return vp8Packet.Payload[0]&0x01 == 0, nil
+}
+
+func isVP9KeyFramePacket(payload []byte) (bool, error) {
+	vp9Packet := codecs.VP9Packet{}
+	if _, err := vp9Packet.Unmarshal(payload); err != nil {
+		return false, err
+	}
+	return !vp9Packet.P && vp9Packet.B && vp9Packet.SID == 0, nil
+}
+
+// isH264KeyFramePacket checks the NAL units of a packet in the formats of RFC 6184
+func isH264KeyFramePacket(payload []byte) (bool, error) {
+	if len(payload) == 0 {
+		return false, errFrameTooShort
+	}
+
+	isKeyFrameNALU := func(naluType byte) bool {
+		return naluType == h264NALUTypeIDR || naluType == h264NALUTypeSPS
+	}
+
+	switch naluType := payload[0] & 0x1F; naluType {
+	case h264NALUTypeSTAPA:
+		for offset := 1; offset+2 < len(payload); {
+			size := int(binary.BigEndian.Uint16(payload[offset:]))
+			offset += 2
+			if size == 0 || offset+size > len(payload) {
+				return false, errFrameTooShort
+			}
+			if isKeyFrameNALU(payload[offset] & 0x1F) {
+				return true, nil
+			}
+			offset += size
+		}
+		return false, nil
+	case h264NALUTypeFUA:
+		if len(payload) < 2 {
+			return false, errFrameTooShort
+		}
+		// The start bit and the type of the fragmented NAL unit are in the FU header
+		return payload[1]&0x80 != 0 && isKeyFrameNALU(payload[1]&0x1F), nil
+	default:
+		return isKeyFrameNALU(naluType), nil
+	}
+}
+
+// isVP8KeyFrame checks the frame type bit of the frame tag and the start code
+// that follows it in keyframes, RFC 6386 Section 9.1
+func isVP8KeyFrame(frame []byte) (bool, error) {
+	if len(frame) < 3 {
+		return false, errFrameTooShort
+	}
+	if frame[0]&0x01 != 0 {
+		return false, nil
+	}
+	return len(frame) >= 6 && bytes.Equal(frame[3:6], []byte{0x9D, 0x01, 0x2A}), nil
+}
+
+// isVP9KeyFrame reads the frame type from the uncompressed header, VP9 Bitstream Specification Section 6.2
+func isVP9KeyFrame(frame []byte) (bool, error) {
+	if len(frame) < 1 {
+		return false, errFrameTooShort
+	}
+
+	b := frame[0]
+	if b>>6 != 0x02 { // frame_marker
+		return false, nil
+	}
+
+	// profile_low_bit and profile_high_bit, profile 3 has a reserved bit
+	bit := 2
+	profile := (b>>5)&0x01 | ((b>>4)&0x01)<<1
+	bit += 2
+	if profile == 3 {
+		bit++
+	}
+
+	// show_existing_frame, followed by frame_type which is 0 for keyframes
+	if b>>(7-uint(bit))&0x01 == 1 {
+		return false, nil
+	}
+	bit++
+	return b>>(7-uint(bit))&0x01 == 0, nil
+}
+
+// isH264KeyFrame looks for an IDR slice in the NAL units of the frame
+func isH264KeyFrame(frame []byte) (bool, error) {
+	if len(frame) == 0 {
+		return false, errFrameTooShort
+	}
+
+	for _, nalu := range splitAnnexB(frame) {
+		if len(nalu) != 0 && nalu[0]&0x1F == h264NALUTypeIDR {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// splitAnnexB splits a byte stream into NAL units at their 3 or 4 byte start codes.
+// A frame without start codes is a single NAL unit.
+func splitAnnexB(frame []byte) [][]byte {
+	nalus := [][]byte{}
+	start := -1
+	for i := 0; i+2 < len(frame); i++ {
+		if frame[i] != 0 || frame[i+1] != 0 || frame[i+2] != 1 {
+			continue
+		}
+
+		if start != -1 {
+			end := i
+			if end > start && frame[end-1] == 0 {
+				end--
+			}
+			nalus = append(nalus, frame[start:end])
+		}
+		start = i + 3
+		i += 2
+	}
+
+	if start == -1 {
+		return [][]byte{frame}
+	}
+	return append(nalus, frame[start:])
+}
+
+// isAV1KeyFrame reads the frame type of the first frame header in the OBUs of the frame,
+// AV1 Bitstream Specification Sections 5.3 and 5.9
+func isAV1KeyFrame(frame []byte) (bool, error) {
+	if len(frame) == 0 {
+		return false, errFrameTooShort
+	}
+
+	reducedStillPictureHeader := false
+	for len(frame) > 0 {
+		header := frame[0]
+		obuType := (header >> 3) & 0x0F
+		hasExtension := header&0x04 != 0
+		hasSizeField := header&0x02 != 0
+
+		offset := 1
+		if hasExtension {
+			offset++
+		}
+		if offset > len(frame) {
+			return false, errFrameTooShort
+		}
+
+		size := len(frame) - offset
+		if hasSizeField {
+			value, n := readLEB128(frame[offset:])
+			if n == 0 {
+				return false, errFrameTooShort
+			}
+			offset += n
+			size = int(value)
+		}
+		if size < 0 || offset+size > len(frame) {
+			return false, errFrameTooShort
+		}
+		payload := frame[offset : offset+size]
+		frame = frame[offset+size:]
+
+		switch obuType {
+		case av1OBUTypeSequenceHeader:
+			// seq_profile (3 bits), still_picture, reduced_still_picture_header
+			if len(payload) == 0 {
+				return false, errFrameTooShort
+			}
+			reducedStillPictureHeader = payload[0]&0x08 != 0
+		case av1OBUTypeFrameHeader, av1OBUTypeFrame:
+			// Without a reduced still picture header, show_existing_frame is followed by frame_type
+			if reducedStillPictureHeader {
+				return true, nil
+			}
+			if len(payload) == 0 {
+				return false, errFrameTooShort
+			}
+			if payload[0]&0x80 != 0 {
+				return false, nil
+			}
+			return (payload[0]>>5)&0x03 == 0, nil
+		}
+	}
+	return false, nil
+}
+
+// readLEB128 returns the value and the number of bytes read, or 0 bytes if the value is truncated
+func readLEB128(b []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(b) && i < 8; i++ {
+		value |= uint64(b[i]&0x7F) << (7 * uint(i))
+		if b[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,107 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsKeyFrame(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		mimeType   string
+		frame      []byte
+		isKeyFrame bool
+		err        error
+	}{
+		{"VP8 KeyFrame", "video/vp8", []byte{0x50, 0x42, 0x00, 0x9D, 0x01, 0x2A, 0x80, 0x02, 0xE0, 0x01}, true, nil},
+		{"VP8 InterFrame", "video/VP8", []byte{0x31, 0x42, 0x00, 0x00, 0x00, 0x00}, false, nil},
+		{"VP8 Short", "video/VP8", []byte{0x50}, false, errFrameTooShort},
+
+		{"VP9 Profile 0 KeyFrame", "video/VP9", []byte{0x82, 0x49, 0x83, 0x42}, true, nil},
+		{"VP9 Profile 0 InterFrame", "video/VP9", []byte{0x86, 0x00}, false, nil},
+		{"VP9 Profile 3 KeyFrame", "video/VP9", []byte{0xB0, 0x49}, true, nil},
+		{"VP9 Profile 3 InterFrame", "video/VP9", []byte{0xB2, 0x00}, false, nil},
+		{"VP9 ShowExistingFrame", "video/VP9", []byte{0x88}, false, nil},
+		{"VP9 Invalid Marker", "video/VP9", []byte{0x02}, false, nil},
+
+		{"H264 IDR", "video/H264", []byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x00, 0x01, 0x68, 0xCE, 0x00, 0x00, 0x01, 0x65, 0x88}, true, nil},
+		{"H264 Non-IDR", "video/H264", []byte{0x00, 0x00, 0x00, 0x01, 0x41, 0x9A, 0x00, 0x00, 0x01, 0x41, 0x9B}, false, nil},
+		{"H264 Without Start Code", "video/H264", []byte{0x65, 0x88}, true, nil},
+		{"H264 Empty", "video/H264", []byte{}, false, errFrameTooShort},
+
+		// Temporal delimiter, sequence header and frame OBUs with size fields
+		{"AV1 KeyFrame", "video/AV1", []byte{0x12, 0x00, 0x0A, 0x01, 0x00, 0x32, 0x02, 0x10, 0x00}, true, nil},
+		{"AV1 InterFrame", "video/AV1", []byte{0x12, 0x00, 0x32, 0x02, 0x30, 0x00}, false, nil},
+		{"AV1 ShowExistingFrame", "video/AV1", []byte{0x1A, 0x01, 0x80}, false, nil},
+		{"AV1 Reduced Still Picture", "video/AV1", []byte{0x0A, 0x01, 0x18, 0x32, 0x01, 0xFF}, true, nil},
+		{"AV1 Without Size Field", "video/AV1", []byte{0x30, 0x10, 0x00}, true, nil},
+		{"AV1 Truncated", "video/AV1", []byte{0x32, 0x05, 0x10}, false, errFrameTooShort},
+
+		{"Unsupported", "audio/opus", []byte{0x00}, false, errKeyFrameCodecUnsupported},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			isKeyFrame, err := IsKeyFrame(test.mimeType, test.frame)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.isKeyFrame, isKeyFrame)
+		})
+	}
+}
+
+func TestIsKeyFramePacket(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		mimeType   string
+		payload    []byte
+		isKeyFrame bool
+		err        error
+	}{
+		// Payload descriptor with S set and partition index 0, followed by the frame tag
+		{"VP8 KeyFrame", "video/VP8", []byte{0x10, 0x50, 0x42, 0x00, 0x9D, 0x01, 0x2A}, true, nil},
+		{"VP8 KeyFrame With PictureID", "video/vp8", []byte{0x90, 0x80, 0x81, 0x02, 0x50, 0x42}, true, nil},
+		{"VP8 InterFrame", "video/VP8", []byte{0x10, 0x31, 0x42, 0x00}, false, nil},
+		{"VP8 Continuation", "video/VP8", []byte{0x00, 0x50, 0x42, 0x00}, false, nil},
+		{"VP8 Other Partition", "video/VP8", []byte{0x11, 0x50, 0x42, 0x00}, false, nil},
+		{"VP8 Empty", "video/VP8", []byte{}, false, nil},
+
+		// Descriptor with I, L and B set, the layer indices follow the picture ID
+		{"VP9 KeyFrame", "video/VP9", []byte{0xA8, 0x01, 0x00, 0x00, 0x82}, true, nil},
+		{"VP9 InterFrame", "video/VP9", []byte{0xE8, 0x01, 0x00, 0x00, 0x86}, false, nil},
+		{"VP9 Upper Spatial Layer", "video/VP9", []byte{0xA8, 0x01, 0x02, 0x00, 0x82}, false, nil},
+		{"VP9 Not Start Of Frame", "video/VP9", []byte{0xA0, 0x01, 0x00, 0x00, 0x82}, false, nil},
+		{"VP9 Empty", "video/VP9", []byte{}, false, nil},
+
+		{"H264 IDR", "video/H264", []byte{0x65, 0x88}, true, nil},
+		{"H264 SPS", "video/H264", []byte{0x67, 0x42}, true, nil},
+		{"H264 Non-IDR", "video/H264", []byte{0x41, 0x9A}, false, nil},
+		{"H264 STAP-A With SPS", "video/H264", []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xCE}, true, nil},
+		{"H264 STAP-A Without IDR", "video/H264", []byte{0x78, 0x00, 0x02, 0x41, 0x9A}, false, nil},
+		{"H264 STAP-A Truncated", "video/H264", []byte{0x78, 0x00, 0x05, 0x67}, false, errFrameTooShort},
+		{"H264 FU-A Start Of IDR", "video/H264", []byte{0x7C, 0x85, 0x88}, true, nil},
+		{"H264 FU-A Middle Of IDR", "video/H264", []byte{0x7C, 0x05, 0x88}, false, nil},
+		{"H264 FU-A Start Of Non-IDR", "video/H264", []byte{0x7C, 0x81, 0x9A}, false, nil},
+		{"H264 Empty", "video/H264", []byte{}, false, errFrameTooShort},
+
+		{"Unsupported", "video/AV1", []byte{0x00}, false, errKeyFrameCodecUnsupported},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			isKeyFrame, err := IsKeyFramePacket(test.mimeType, test.payload)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			if len(test.payload) == 0 {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.isKeyFrame, isKeyFrame)
+		})
+	}
+}
@@ -2,8 +2,12 @@
 package media
 
 import (
+	"context"
+	"errors"
+	"io"
 	"time"
 
+	"github.com/pion/interceptor"
 	"github.com/pion/rtp"
 )
 
@@ -14,6 +18,19 @@ type Sample struct {
 	Duration           time.Duration
 	PacketTimestamp    uint32
 	PrevDroppedPackets uint16
+
+	// Silence is set on samples that have been synthesized to fill a pause
+	// of discontinuous transmission (DTX), see samplebuilder.WithDTX
+	Silence bool
+
+	// Discontinuity is set on the first sample after a pause of discontinuous
+	// transmission (DTX) that hasn't been filled, media is missing before it.
+	// Its PacketTimestamp tells how long the pause was.
+	Discontinuity bool
+
+	// CSRC is the list of contributing sources that will be set on every
+	// RTP packet produced from this Sample. This is used when acting as a mixer
+	CSRC []uint32
 }
 
 // Writer defines an interface to handle
@@ -25,3 +42,68 @@ type Writer interface {
 	// Note: Close implementation must be idempotent
 	Close() error
 }
+
+// TrackReader reads RTP packets from a track, it is implemented by webrtc.TrackRemote
+type TrackReader interface {
+	ReadRTP() (*rtp.Packet, interceptor.Attributes, error)
+}
+
+// WriteTrack reads RTP packets from the track and adds them to the Writer
+// until the track ends or an error occurs. The Writer is closed afterwards.
+// The end of the track (io.EOF) is not returned as an error.
+func WriteTrack(w Writer, track TrackReader) error {
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			closeErr := w.Close()
+			if errors.Is(err, io.EOF) {
+				return closeErr
+			}
+			return err
+		}
+
+		if err = w.WriteRTP(packet); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+}
+
+// SampleReader produces timed samples, e.g. from a media file.
+// io.EOF is returned once there are no samples left.
+type SampleReader interface {
+	NextSample() (Sample, error)
+}
+
+// SampleWriter consumes samples, it is implemented by webrtc.TrackLocalStaticSample
+type SampleWriter interface {
+	WriteSample(Sample) error
+}
+
+// Play writes the samples of the SampleReader to the SampleWriter, paced by their Duration
+// so that they are sent in real time. Play returns when the reader has no samples left, or
+// with the error of the context once it is done.
+func Play(ctx context.Context, w SampleWriter, r SampleReader) error {
+	start := time.Now()
+	var elapsed time.Duration
+	for {
+		sample, err := r.NextSample()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err = w.WriteSample(sample); err != nil {
+			return err
+		}
+
+		// Sleep until the next sample is due, based on the total duration written so far to avoid drift
+		elapsed += sample.Duration
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(start.Add(elapsed))):
+		}
+	}
+}
@@ -1 +1,99 @@
 package media_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTrackReader struct {
+	packets []*rtp.Packet
+	err     error
+}
+
+func (r *testTrackReader) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	if len(r.packets) == 0 {
+		return nil, nil, r.err
+	}
+	packet := r.packets[0]
+	r.packets = r.packets[1:]
+	return packet, nil, nil
+}
+
+type testWriter struct {
+	packets []*rtp.Packet
+	closed  bool
+}
+
+func (w *testWriter) WriteRTP(packet *rtp.Packet) error {
+	w.packets = append(w.packets, packet)
+	return nil
+}
+
+func (w *testWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestWriteTrack(t *testing.T) {
+	packets := []*rtp.Packet{{Header: rtp.Header{SequenceNumber: 1}}, {Header: rtp.Header{SequenceNumber: 2}}}
+
+	w := &testWriter{}
+	assert.NoError(t, media.WriteTrack(w, &testTrackReader{packets: packets, err: io.EOF}))
+	assert.Equal(t, packets, w.packets)
+	assert.True(t, w.closed)
+
+	errRead := errors.New("read failed")
+	w = &testWriter{}
+	assert.Equal(t, errRead, media.WriteTrack(w, &testTrackReader{err: errRead}))
+	assert.True(t, w.closed)
+}
+
+type testSampleReader struct {
+	samples []media.Sample
+}
+
+func (r *testSampleReader) NextSample() (media.Sample, error) {
+	if len(r.samples) == 0 {
+		return media.Sample{}, io.EOF
+	}
+	sample := r.samples[0]
+	r.samples = r.samples[1:]
+	return sample, nil
+}
+
+type testSampleWriter struct {
+	samples []media.Sample
+}
+
+func (w *testSampleWriter) WriteSample(sample media.Sample) error {
+	w.samples = append(w.samples, sample)
+	return nil
+}
+
+func TestPlay(t *testing.T) {
+	samples := []media.Sample{
+		{Data: []byte{0x01}, Duration: 20 * time.Millisecond},
+		{Data: []byte{0x02}, Duration: 20 * time.Millisecond},
+		{Data: []byte{0x03}, Duration: 20 * time.Millisecond},
+	}
+
+	w := &testSampleWriter{}
+	start := time.Now()
+	assert.NoError(t, media.Play(context.Background(), w, &testSampleReader{samples: samples}))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(60*time.Millisecond))
+	assert.Equal(t, samples, w.samples)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = &testSampleWriter{}
+	assert.Equal(t, context.Canceled, media.Play(ctx, w, &testSampleReader{samples: samples}))
+	assert.Len(t, w.samples, 1)
+}
@@ -0,0 +1,381 @@
+// Package mkvwriter implements Matroska (WebM) media container writer
+package mkvwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+const (
+	mimeTypeVP8  = "video/VP8"
+	mimeTypeVP9  = "video/VP9"
+	mimeTypeOpus = "audio/opus"
+
+	videoClockRate = 90000
+	opusClockRate  = 48000
+	opusPreSkip    = 3840
+
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+	trackNumber    = 1
+
+	// SimpleBlock timecodes are relative to the Cluster and limited to int16
+	maxClusterDuration = math.MaxInt16
+
+	simpleBlockFlagKeyFrame = 0x80
+)
+
+// EBML IDs of the elements that are written
+var (
+	idEBML               = []byte{0x1A, 0x45, 0xDF, 0xA3}
+	idEBMLVersion        = []byte{0x42, 0x86}
+	idEBMLReadVersion    = []byte{0x42, 0xF7}
+	idEBMLMaxIDLength    = []byte{0x42, 0xF2}
+	idEBMLMaxSizeLength  = []byte{0x42, 0xF3}
+	idDocType            = []byte{0x42, 0x82}
+	idDocTypeVersion     = []byte{0x42, 0x87}
+	idDocTypeReadVersion = []byte{0x42, 0x85}
+	idSegment            = []byte{0x18, 0x53, 0x80, 0x67}
+	idInfo               = []byte{0x15, 0x49, 0xA9, 0x66}
+	idTimecodeScale      = []byte{0x2A, 0xD7, 0xB1}
+	idMuxingApp          = []byte{0x4D, 0x80}
+	idWritingApp         = []byte{0x57, 0x41}
+	idTracks             = []byte{0x16, 0x54, 0xAE, 0x6B}
+	idTrackEntry         = []byte{0xAE}
+	idTrackNumber        = []byte{0xD7}
+	idTrackUID           = []byte{0x73, 0xC5}
+	idTrackType          = []byte{0x83}
+	idCodecID            = []byte{0x86}
+	idCodecPrivate       = []byte{0x63, 0xA2}
+	idVideo              = []byte{0xE0}
+	idPixelWidth         = []byte{0xB0}
+	idPixelHeight        = []byte{0xBA}
+	idAudio              = []byte{0xE1}
+	idSamplingFrequency  = []byte{0xB5}
+	idChannels           = []byte{0x9F}
+	idCluster            = []byte{0x1F, 0x43, 0xB6, 0x75}
+	idTimecode           = []byte{0xE7}
+	idSimpleBlock        = []byte{0xA3}
+
+	// unknownSize lets Segment and Cluster be written without seeking back
+	unknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+var (
+	errFileNotOpened    = errors.New("file not opened")
+	errInvalidNilPacket = errors.New("invalid nil packet")
+	errCodecUnsupported = errors.New("codec is not supported by the MKV writer")
+)
+
+// MKVWriter is used to take RTP packets of a single VP8, VP9 or Opus track and write them to a WebM file
+type MKVWriter struct {
+	ioWriter io.Writer
+	mimeType string
+
+	width, height uint64
+	channelCount  uint16
+
+	seenKeyFrame     bool
+	currentFrame     []byte
+	currentKeyFrame  bool
+	currentTimecode  int64
+	haveTimestamp    bool
+	lastTimestamp    uint32
+	elapsedTimestamp int64
+	haveCluster      bool
+	clusterTimecode  int64
+}
+
+// Option configures an MKVWriter
+type Option func(*MKVWriter)
+
+// WithVideoSize sets the pixel size that is stored for a video track, 640x480 by default
+func WithVideoSize(width, height uint64) Option {
+	return func(m *MKVWriter) {
+		m.width, m.height = width, height
+	}
+}
+
+// WithChannelCount sets the channel count that is stored for an audio track, 2 by default
+func WithChannelCount(channelCount uint16) Option {
+	return func(m *MKVWriter) {
+		m.channelCount = channelCount
+	}
+}
+
+// New builds a new MKV writer for the given codec, either video/VP8, video/VP9 or audio/opus
+func New(fileName string, mimeType string, opts ...Option) (*MKVWriter, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := NewWith(f, mimeType, opts...)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return writer, nil
+}
+
+// NewWith initialize a new MKV writer with an io.Writer output
+func NewWith(out io.Writer, mimeType string, opts ...Option) (*MKVWriter, error) {
+	if out == nil {
+		return nil, errFileNotOpened
+	}
+
+	switch {
+	case strings.EqualFold(mimeType, mimeTypeVP8):
+		mimeType = mimeTypeVP8
+	case strings.EqualFold(mimeType, mimeTypeVP9):
+		mimeType = mimeTypeVP9
+	case strings.EqualFold(mimeType, mimeTypeOpus):
+		mimeType = mimeTypeOpus
+	default:
+		return nil, fmt.Errorf("%w: %s", errCodecUnsupported, mimeType)
+	}
+
+	writer := &MKVWriter{
+		ioWriter:     out,
+		mimeType:     mimeType,
+		width:        640,
+		height:       480,
+		channelCount: 2,
+	}
+	for _, o := range opts {
+		o(writer)
+	}
+
+	if err := writer.writeHeader(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (m *MKVWriter) writeHeader() error {
+	header := element(idEBML,
+		uintElement(idEBMLVersion, 1),
+		uintElement(idEBMLReadVersion, 1),
+		uintElement(idEBMLMaxIDLength, 4),
+		uintElement(idEBMLMaxSizeLength, 8),
+		element(idDocType, []byte("webm")),
+		uintElement(idDocTypeVersion, 4),
+		uintElement(idDocTypeReadVersion, 2),
+	)
+
+	info := element(idInfo,
+		uintElement(idTimecodeScale, 1000000), // Timecodes are in milliseconds
+		element(idMuxingApp, []byte("pion")),
+		element(idWritingApp, []byte("pion")),
+	)
+
+	var trackEntry []byte
+	switch m.mimeType {
+	case mimeTypeOpus:
+		trackEntry = element(idTrackEntry,
+			uintElement(idTrackNumber, trackNumber),
+			uintElement(idTrackUID, trackNumber),
+			uintElement(idTrackType, trackTypeAudio),
+			element(idCodecID, []byte("A_OPUS")),
+			element(idCodecPrivate, m.opusHead()),
+			element(idAudio,
+				floatElement(idSamplingFrequency, opusClockRate),
+				uintElement(idChannels, uint64(m.channelCount)),
+			),
+		)
+	default:
+		codecID := "V_VP8"
+		if m.mimeType == mimeTypeVP9 {
+			codecID = "V_VP9"
+		}
+		trackEntry = element(idTrackEntry,
+			uintElement(idTrackNumber, trackNumber),
+			uintElement(idTrackUID, trackNumber),
+			uintElement(idTrackType, trackTypeVideo),
+			element(idCodecID, []byte(codecID)),
+			element(idVideo,
+				uintElement(idPixelWidth, m.width),
+				uintElement(idPixelHeight, m.height),
+			),
+		)
+	}
+
+	segment := append(append([]byte{}, idSegment...), unknownSize...)
+
+	_, err := m.ioWriter.Write(bytes.Join([][]byte{header, segment, info, element(idTracks, trackEntry)}, nil))
+	return err
+}
+
+// opusHead is the CodecPrivate of an Opus track, see RFC 7845 Section 5.1
+func (m *MKVWriter) opusHead() []byte {
+	head := make([]byte, 19)
+	copy(head[0:], "OpusHead")
+	head[8] = 1                                             // Version
+	head[9] = uint8(m.channelCount)                         // Channel count
+	binary.LittleEndian.PutUint16(head[10:], opusPreSkip)   // Pre-skip
+	binary.LittleEndian.PutUint32(head[12:], opusClockRate) // Input sample rate
+	binary.LittleEndian.PutUint16(head[16:], 0)             // Output gain
+	head[18] = 0                                            // Channel mapping family
+	return head
+}
+
+// WriteRTP adds a new packet and writes the frame once it is complete
+func (m *MKVWriter) WriteRTP(packet *rtp.Packet) error {
+	if m.ioWriter == nil {
+		return errFileNotOpened
+	}
+	if packet == nil {
+		return errInvalidNilPacket
+	}
+
+	var (
+		payload            []byte
+		isKeyFrame, isHead bool
+	)
+	switch m.mimeType {
+	case mimeTypeOpus:
+		opusPacket := codecs.OpusPacket{}
+		if _, err := opusPacket.Unmarshal(packet.Payload); err != nil {
+			return err
+		}
+		payload, isKeyFrame, isHead = opusPacket.Payload, true, true
+	case mimeTypeVP9:
+		vp9Packet := codecs.VP9Packet{}
+		if _, err := vp9Packet.Unmarshal(packet.Payload); err != nil {
+			return err
+		}
+		payload, isKeyFrame, isHead = vp9Packet.Payload, !vp9Packet.P, vp9Packet.B
+	default:
+		vp8Packet := codecs.VP8Packet{}
+		if _, err := vp8Packet.Unmarshal(packet.Payload); err != nil {
+			return err
+		}
+		payload, isKeyFrame, isHead = vp8Packet.Payload, len(vp8Packet.Payload) > 0 && vp8Packet.Payload[0]&0x01 == 0, vp8Packet.S == 1
+	}
+
+	switch {
+	case !m.seenKeyFrame && !isKeyFrame:
+		return nil
+	case m.currentFrame == nil && !isHead:
+		return nil
+	}
+
+	if m.currentFrame == nil {
+		m.currentKeyFrame = isKeyFrame
+		m.currentTimecode = m.timecode(packet.Timestamp)
+	}
+	m.seenKeyFrame = true
+	m.currentFrame = append(m.currentFrame, payload...)
+
+	if m.mimeType != mimeTypeOpus && !packet.Marker {
+		return nil
+	}
+
+	defer func() {
+		m.currentFrame = nil
+	}()
+	return m.writeFrame(m.currentFrame, m.currentTimecode, m.currentKeyFrame)
+}
+
+// timecode converts a RTP timestamp to milliseconds since the first written frame
+func (m *MKVWriter) timecode(timestamp uint32) int64 {
+	if !m.haveTimestamp {
+		m.haveTimestamp = true
+		m.lastTimestamp = timestamp
+	}
+
+	m.elapsedTimestamp += int64(int32(timestamp - m.lastTimestamp))
+	m.lastTimestamp = timestamp
+
+	clockRate := int64(videoClockRate)
+	if m.mimeType == mimeTypeOpus {
+		clockRate = opusClockRate
+	}
+	return m.elapsedTimestamp * 1000 / clockRate
+}
+
+func (m *MKVWriter) writeFrame(frame []byte, timecode int64, isKeyFrame bool) error {
+	relativeTimecode := timecode - m.clusterTimecode
+	isVideoKeyFrame := isKeyFrame && m.mimeType != mimeTypeOpus
+	if !m.haveCluster || isVideoKeyFrame || relativeTimecode < 0 || relativeTimecode > maxClusterDuration {
+		cluster := append(append([]byte{}, idCluster...), unknownSize...)
+		cluster = append(cluster, uintElement(idTimecode, uint64(timecode))...)
+		if _, err := m.ioWriter.Write(cluster); err != nil {
+			return err
+		}
+
+		m.haveCluster = true
+		m.clusterTimecode = timecode
+		relativeTimecode = 0
+	}
+
+	blockHeader := make([]byte, 4)
+	blockHeader[0] = 0x80 | trackNumber                                   // Track number as EBML vint
+	binary.BigEndian.PutUint16(blockHeader[1:], uint16(relativeTimecode)) // Timecode relative to the Cluster
+	if isKeyFrame {
+		blockHeader[3] = simpleBlockFlagKeyFrame
+	}
+
+	_, err := m.ioWriter.Write(element(idSimpleBlock, blockHeader, frame))
+	return err
+}
+
+// Close stops the recording
+func (m *MKVWriter) Close() error {
+	if m.ioWriter == nil {
+		// Returns no error as it may be convenient to call
+		// Close() multiple times
+		return nil
+	}
+
+	defer func() {
+		m.ioWriter = nil
+	}()
+
+	if closer, ok := m.ioWriter.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// element encodes an EBML element with the given ID and concatenated data
+func element(id []byte, data ...[]byte) []byte {
+	payload := bytes.Join(data, nil)
+	return bytes.Join([][]byte{id, encodeSize(uint64(len(payload))), payload}, nil)
+}
+
+func uintElement(id []byte, value uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	for len(data) > 1 && data[0] == 0 {
+		data = data[1:]
+	}
+	return element(id, data)
+}
+
+func floatElement(id []byte, value float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	return element(id, data)
+}
+
+// encodeSize encodes an element size as the shortest EBML variable size integer
+func encodeSize(size uint64) []byte {
+	length := 1
+	for length < 8 && size >= (1<<(7*uint(length)))-1 {
+		length++
+	}
+
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, size|(1<<(7*uint(length))))
+	return encoded[8-length:]
+}
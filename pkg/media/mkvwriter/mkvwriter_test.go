@@ -0,0 +1,166 @@
+package mkvwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+type ebmlElement struct {
+	id   []byte
+	data []byte
+}
+
+// parseElements walks EBML elements, descending into Segment and Cluster as they have an unknown size
+func parseElements(t *testing.T, buf []byte) []ebmlElement {
+	elements := []ebmlElement{}
+	for len(buf) > 0 {
+		idLength := vintLength(buf[0])
+		id := buf[:idLength]
+		buf = buf[idLength:]
+
+		sizeLength := vintLength(buf[0])
+		if bytes.Equal(buf[:sizeLength], unknownSize) {
+			elements = append(elements, ebmlElement{id: id})
+			buf = buf[sizeLength:]
+			continue
+		}
+
+		sizeBytes := make([]byte, 8)
+		copy(sizeBytes[8-sizeLength:], buf[:sizeLength])
+		sizeBytes[8-sizeLength] &= 0xFF >> uint(sizeLength)
+		size := binary.BigEndian.Uint64(sizeBytes)
+		buf = buf[sizeLength:]
+
+		if !assert.LessOrEqual(t, size, uint64(len(buf))) {
+			return elements
+		}
+		elements = append(elements, ebmlElement{id: id, data: buf[:size]})
+		buf = buf[size:]
+	}
+	return elements
+}
+
+func vintLength(b byte) int {
+	length := 1
+	for mask := byte(0x80); length < 8 && b&mask == 0; mask >>= 1 {
+		length++
+	}
+	return length
+}
+
+func findElements(elements []ebmlElement, id []byte) []ebmlElement {
+	found := []ebmlElement{}
+	for _, e := range elements {
+		if bytes.Equal(e.id, id) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func TestMKVWriter_VP8(t *testing.T) {
+	assert := assert.New(t)
+
+	buffer := &bytes.Buffer{}
+	writer, err := NewWith(buffer, "video/vp8", WithVideoSize(320, 240))
+	assert.NoError(err)
+
+	// Inter frame before the first keyframe is dropped
+	assert.NoError(writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true, Timestamp: 0}, Payload: []byte{0x10, 0x01, 0x00, 0x00}}))
+
+	// Keyframe split across two packets, then an inter frame 100ms and 40s later
+	assert.NoError(writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 9000}, Payload: []byte{0x10, 0xAA, 0xAA, 0xAA}}))
+	assert.NoError(writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true, Timestamp: 9000}, Payload: []byte{0x00, 0xBB, 0xBB, 0xBB}}))
+	assert.NoError(writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true, Timestamp: 18000}, Payload: []byte{0x10, 0x01, 0x01, 0x01}}))
+	assert.NoError(writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Marker: true, Timestamp: 9000 + 40*90000}, Payload: []byte{0x10, 0x03, 0x03, 0x03}}))
+	assert.NoError(writer.Close())
+	assert.NoError(writer.Close())
+	assert.Equal(errFileNotOpened, writer.WriteRTP(&rtp.Packet{}))
+
+	elements := parseElements(t, buffer.Bytes())
+	header := findElements(elements, idEBML)
+	assert.Len(header, 1)
+	assert.Equal([]byte("webm"), findElements(parseElements(t, header[0].data), idDocType)[0].data)
+
+	tracks := findElements(elements, idTracks)
+	assert.Len(tracks, 1)
+	trackEntry := parseElements(t, findElements(parseElements(t, tracks[0].data), idTrackEntry)[0].data)
+	assert.Equal([]byte("V_VP8"), findElements(trackEntry, idCodecID)[0].data)
+	video := parseElements(t, findElements(trackEntry, idVideo)[0].data)
+	assert.Equal([]byte{0x01, 0x40}, findElements(video, idPixelWidth)[0].data)
+
+	// The 40s gap does not fit into the int16 relative timecode and starts a new Cluster
+	assert.Len(findElements(elements, idCluster), 2)
+	timecodes := findElements(elements, idTimecode)
+	assert.Len(timecodes, 2)
+	assert.Equal([]byte{0x00}, timecodes[0].data)
+	assert.Equal([]byte{0x9C, 0x40}, timecodes[1].data)
+
+	blocks := findElements(elements, idSimpleBlock)
+	assert.Len(blocks, 3)
+	assert.Equal([]byte{0x81, 0x00, 0x00, simpleBlockFlagKeyFrame, 0xAA, 0xAA, 0xAA, 0xBB, 0xBB, 0xBB}, blocks[0].data)
+	assert.Equal([]byte{0x81, 0x00, 0x64, 0x00, 0x01, 0x01, 0x01}, blocks[1].data)
+	assert.Equal([]byte{0x81, 0x00, 0x00, 0x00, 0x03, 0x03, 0x03}, blocks[2].data)
+}
+
+func TestMKVWriter_Opus(t *testing.T) {
+	assert := assert.New(t)
+
+	buffer := &bytes.Buffer{}
+	writer, err := NewWith(buffer, "audio/opus", WithChannelCount(1))
+	assert.NoError(err)
+
+	for i := uint32(0); i < 3; i++ {
+		assert.NoError(writer.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: 1000 + i*960}, Payload: []byte{byte(i)}}))
+	}
+	assert.NoError(writer.Close())
+
+	elements := parseElements(t, buffer.Bytes())
+	trackEntry := parseElements(t, findElements(parseElements(t, findElements(elements, idTracks)[0].data), idTrackEntry)[0].data)
+	assert.Equal([]byte("A_OPUS"), findElements(trackEntry, idCodecID)[0].data)
+
+	opusHead := findElements(trackEntry, idCodecPrivate)[0].data
+	assert.Equal([]byte("OpusHead"), opusHead[:8])
+	assert.Equal(byte(1), opusHead[9])
+
+	assert.Len(findElements(elements, idCluster), 1)
+	blocks := findElements(elements, idSimpleBlock)
+	assert.Len(blocks, 3)
+	assert.Equal([]byte{0x81, 0x00, 0x14, simpleBlockFlagKeyFrame, 0x01}, blocks[1].data)
+}
+
+func TestMKVWriter_Errors(t *testing.T) {
+	_, err := NewWith(nil, "video/vp8")
+	assert.Equal(t, errFileNotOpened, err)
+
+	_, err = NewWith(&bytes.Buffer{}, "video/H264")
+	assert.ErrorIs(t, err, errCodecUnsupported)
+
+	dir, err := ioutil.TempDir("", "mkvwriter")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(dir))
+	}()
+
+	writer, err := New(filepath.Join(dir, "out.mkv"), "video/H264")
+	assert.ErrorIs(t, err, errCodecUnsupported)
+	assert.Nil(t, writer)
+
+	writer, err = NewWith(&bytes.Buffer{}, "video/vp9")
+	assert.NoError(t, err)
+	assert.Equal(t, errInvalidNilPacket, writer.WriteRTP(nil))
+}
+
+func TestEncodeSize(t *testing.T) {
+	assert.Equal(t, []byte{0x80}, encodeSize(0))
+	assert.Equal(t, []byte{0xFE}, encodeSize(126))
+	assert.Equal(t, []byte{0x40, 0x7F}, encodeSize(127))
+	assert.Equal(t, []byte{0x20, 0x40, 0x00}, encodeSize(1<<14))
+}
@@ -2,16 +2,23 @@
 package oggreader
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
+	"time"
+
+	"github.com/pion/webrtc/v3/pkg/media"
 )
 
 const (
 	pageHeaderTypeBeginningOfStream = 0x02
 	pageHeaderSignature             = "OggS"
 
-	idPageSignature = "OpusHead"
+	idPageSignature      = "OpusHead"
+	commentPageSignature = "OpusTags"
+
+	opusSampleRate = 48000
 
 	pageHeaderLen       = 27
 	idPagePayloadLength = 19
@@ -213,3 +220,61 @@ func generateChecksumTable() *[256]uint32 {
 	}
 	return &table
 }
+
+// SampleReader reads the pages of an Ogg Opus file as media.Samples. Every page is
+// expected to contain a single Opus packet, the Duration of a sample is computed from
+// the granule positions.
+type SampleReader struct {
+	stream      io.ReadSeeker
+	loop        bool
+	reader      *OggReader
+	lastGranule uint64
+}
+
+// NewSampleReader returns a new SampleReader and the Ogg header. If loop is set
+// the reader starts over at the beginning of the file instead of returning io.EOF.
+func NewSampleReader(in io.ReadSeeker, loop bool) (*SampleReader, *OggHeader, error) {
+	reader, header, err := NewWith(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &SampleReader{
+		stream: in,
+		loop:   loop,
+		reader: reader,
+	}, header, nil
+}
+
+// NextSample returns the next page, or io.EOF once all pages have been read
+func (s *SampleReader) NextSample() (media.Sample, error) {
+	for {
+		payload, pageHeader, err := s.reader.ParseNextPage()
+		if errors.Is(err, io.EOF) && s.loop {
+			if _, err = s.stream.Seek(0, io.SeekStart); err != nil {
+				return media.Sample{}, err
+			}
+			if s.reader, _, err = NewWith(s.stream); err != nil {
+				return media.Sample{}, err
+			}
+			s.lastGranule = 0
+			payload, pageHeader, err = s.reader.ParseNextPage()
+		}
+		if err != nil {
+			return media.Sample{}, err
+		}
+
+		if bytes.HasPrefix(payload, []byte(commentPageSignature)) {
+			continue
+		}
+
+		// The granule position is the total amount of 48kHz samples at the end of the page
+		sampleCount := pageHeader.GranulePosition - s.lastGranule
+		s.lastGranule = pageHeader.GranulePosition
+
+		return media.Sample{
+			Data:     payload,
+			Duration: time.Duration(sampleCount) * time.Second / opusSampleRate,
+		}, nil
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"io"
 	"testing"
+	"time"
 
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -98,3 +102,37 @@ func TestOggReader_ParseErrors(t *testing.T) {
 		assert.Equal(t, err, errChecksumMismatch)
 	})
 }
+
+func TestOggReader_SampleReader(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := oggwriter.NewWith(buffer, 48000, 2)
+	assert.NoError(t, err)
+
+	for i := uint32(0); i < 3; i++ {
+		assert.NoError(t, writer.WriteRTP(&rtp.Packet{
+			Header:  rtp.Header{Timestamp: 1000 + i*960},
+			Payload: []byte{byte(i + 1)},
+		}))
+	}
+	assert.NoError(t, writer.Close())
+
+	ogg := bytes.NewReader(buffer.Bytes())
+	reader, header, err := NewSampleReader(ogg, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, header.Channels)
+
+	// The OpusTags page is skipped, the following pages are 20ms apart
+	samples := []media.Sample{}
+	for i := 0; i < 6; i++ {
+		sample, err := reader.NextSample()
+		assert.NoError(t, err)
+		samples = append(samples, sample)
+	}
+
+	for i, sample := range samples {
+		assert.Equal(t, []byte{byte(i%3 + 1)}, sample.Data)
+		if i%3 != 0 {
+			assert.Equal(t, 20*time.Millisecond, sample.Duration)
+		}
+	}
+}
@@ -40,6 +40,15 @@ type SampleBuilder struct {
 
 	// number of packets forced to be dropped
 	droppedPackets uint16
+
+	// dtxFrameTimestamps is the duration of a frame in RTP timestamp units, a
+	// larger timestamp difference between consecutive packets is a DTX pause
+	dtxFrameTimestamps uint32
+	dtxFrameDuration   time.Duration
+	dtxSilence         []byte
+
+	// discontinuity is set when the next sample follows a DTX pause
+	discontinuity bool
 }
 
 // New constructs a new SampleBuilder.
@@ -185,6 +194,10 @@ func (s *SampleBuilder) Push(p *rtp.Packet) {
 
 const secondToNanoseconds = 1000000000
 
+// maxDTXSilenceSamples limits the silence samples synthesized for a single DTX pause,
+// longer pauses aren't filled
+const maxDTXSilenceSamples = 1 << 14
+
 // buildSample creates a sample from a valid collection of RTP Packets by
 // walking forwards building a sample if everything looks good clear and
 // update buffer+values
@@ -269,19 +282,55 @@ func (s *SampleBuilder) buildSample(purgingBuffers bool) *media.Sample {
 		Duration:           time.Duration((float64(samples)/float64(s.sampleRate))*secondToNanoseconds) * time.Nanosecond,
 		PacketTimestamp:    sampleTimestamp,
 		PrevDroppedPackets: s.droppedPackets,
+		Discontinuity:      s.discontinuity,
 	}
 
 	s.droppedPackets = 0
+	s.discontinuity = false
 
 	s.preparedSamples[s.prepared.tail] = sample
 	s.prepared.tail++
 
+	// The sender paused if the timestamp jumps although no packet is missing
+	if s.dtxFrameTimestamps != 0 && s.buffer[consume.tail] != nil && samples > s.dtxFrameTimestamps*3/2 {
+		sample.Duration = s.dtxFrameDuration
+		s.fillDTXPause(sampleTimestamp+s.dtxFrameTimestamps, afterTimestamp)
+	}
+
 	s.purgeConsumedLocation(consume, true)
 	s.purgeConsumedBuffers()
 
 	return sample
 }
 
+// fillDTXPause synthesizes silence samples for the DTX pause from the timestamp
+// start until end, or flags the next sample if silence isn't synthesized
+func (s *SampleBuilder) fillDTXPause(start, end uint32) {
+	count := (end - start + s.dtxFrameTimestamps/2) / s.dtxFrameTimestamps
+	if s.dtxSilence == nil || count == 0 || count > maxDTXSilenceSamples {
+		s.discontinuity = true
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		timestamp := start + i*s.dtxFrameTimestamps
+		duration := s.dtxFrameDuration
+
+		// The last sample lasts until the end of the pause
+		if i == count-1 {
+			duration = time.Duration((float64(end-timestamp)/float64(s.sampleRate))*secondToNanoseconds) * time.Nanosecond
+		}
+
+		s.preparedSamples[s.prepared.tail] = &media.Sample{
+			Data:            append([]byte{}, s.dtxSilence...),
+			Duration:        duration,
+			PacketTimestamp: timestamp,
+			Silence:         true,
+		}
+		s.prepared.tail++
+	}
+}
+
 // Pop compiles pushed RTP packets into media samples and then
 // returns the next valid sample (or nil if no sample is compiled).
 func (s *SampleBuilder) Pop() *media.Sample {
@@ -353,3 +402,19 @@ func WithMaxTimeDelay(maxLateDuration time.Duration) Option {
 		o.maxLateTimestamp = uint32(int64(o.sampleRate) * totalMillis / 1000)
 	}
 }
+
+// WithDTX detects pauses of discontinuous transmission (DTX), like Opus DTX. The
+// sender stops sending during silence, so the RTP timestamp jumps although no
+// packet is missing. frameDuration is the duration of a single frame, e.g. 20ms
+// for Opus. The Duration of the sample before a pause is set to frameDuration.
+//
+// If silence is not nil, the pause is filled with samples containing silence, e.g.
+// []byte{0xF8, 0xFF, 0xFE} for a 20ms Opus frame. They are flagged with Silence.
+// Otherwise the first sample after the pause is flagged with Discontinuity.
+func WithDTX(frameDuration time.Duration, silence []byte) Option {
+	return func(o *SampleBuilder) {
+		o.dtxFrameDuration = frameDuration
+		o.dtxFrameTimestamps = uint32(int64(o.sampleRate) * int64(frameDuration) / int64(time.Second))
+		o.dtxSilence = silence
+	}
+}
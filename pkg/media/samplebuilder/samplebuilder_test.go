@@ -559,3 +559,62 @@ func BenchmarkSampleBuilderFragmentedLoss(b *testing.B) {
 		b.Errorf("Got %v (N=%v)", j, b.N)
 	}
 }
+
+func TestSampleBuilderDTX(t *testing.T) {
+	// 20ms frames at 48kHz, the sender pauses for 60ms after the second frame
+	packets := []*rtp.Packet{
+		{Header: rtp.Header{SequenceNumber: 5000, Timestamp: 0}, Payload: []byte{0x01}},
+		{Header: rtp.Header{SequenceNumber: 5001, Timestamp: 960}, Payload: []byte{0x02}},
+		{Header: rtp.Header{SequenceNumber: 5002, Timestamp: 4800}, Payload: []byte{0x03}},
+		{Header: rtp.Header{SequenceNumber: 5003, Timestamp: 5760}, Payload: []byte{0x04}},
+	}
+
+	popAll := func(s *SampleBuilder) (samples []*media.Sample) {
+		for _, p := range packets {
+			s.Push(p)
+			for sample := s.Pop(); sample != nil; sample = s.Pop() {
+				samples = append(samples, sample)
+			}
+		}
+		return
+	}
+
+	t.Run("Discontinuity", func(t *testing.T) {
+		samples := popAll(New(10, &fakeOpusDepacketizer{}, 48000, WithDTX(20*time.Millisecond, nil)))
+		assert.Equal(t, []*media.Sample{
+			{Data: []byte{0x01}, Duration: 20 * time.Millisecond, PacketTimestamp: 0},
+			{Data: []byte{0x02}, Duration: 20 * time.Millisecond, PacketTimestamp: 960},
+			{Data: []byte{0x03}, Duration: 20 * time.Millisecond, PacketTimestamp: 4800, Discontinuity: true},
+		}, samples)
+	})
+
+	t.Run("Silence", func(t *testing.T) {
+		silence := []byte{0xF8, 0xFF, 0xFE}
+		samples := popAll(New(10, &fakeOpusDepacketizer{}, 48000, WithDTX(20*time.Millisecond, silence)))
+		assert.Equal(t, []*media.Sample{
+			{Data: []byte{0x01}, Duration: 20 * time.Millisecond, PacketTimestamp: 0},
+			{Data: []byte{0x02}, Duration: 20 * time.Millisecond, PacketTimestamp: 960},
+			{Data: silence, Duration: 20 * time.Millisecond, PacketTimestamp: 1920, Silence: true},
+			{Data: silence, Duration: 20 * time.Millisecond, PacketTimestamp: 2880, Silence: true},
+			{Data: silence, Duration: 20 * time.Millisecond, PacketTimestamp: 3840, Silence: true},
+			{Data: []byte{0x03}, Duration: 20 * time.Millisecond, PacketTimestamp: 4800},
+		}, samples)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		samples := popAll(New(10, &fakeOpusDepacketizer{}, 48000))
+		assert.Equal(t, 80*time.Millisecond, samples[1].Duration)
+		assert.False(t, samples[2].Discontinuity)
+	})
+}
+
+// fakeOpusDepacketizer treats every packet as a complete frame, like Opus
+type fakeOpusDepacketizer struct{}
+
+func (f *fakeOpusDepacketizer) Unmarshal(r []byte) ([]byte, error) {
+	return r, nil
+}
+
+func (f *fakeOpusDepacketizer) IsDetectedFinalPacketInSequence(bool) bool {
+	return true
+}
@@ -0,0 +1,82 @@
+package media
+
+import (
+	"github.com/pion/rtp/codecs"
+)
+
+// VP9Descriptor is the payload descriptor of a VP9 RTP packet, draft-ietf-payload-vp9 Section 4.2.
+// It tells which picture and which spatial and temporal layer the packet belongs to, so
+// SVC streams can be filtered by layer without depacketizing them.
+type VP9Descriptor struct {
+	// PictureID is the 7 or 15 bit picture ID, only valid if HasPictureID is set
+	PictureID    uint16
+	HasPictureID bool
+
+	// InterPicturePredicted is set if the layer frame depends on previous pictures
+	InterPicturePredicted bool
+
+	// Flexible is set in flexible mode, the references are signaled in every packet
+	Flexible bool
+
+	// StartOfFrame and EndOfFrame are set on the first and last packet of a layer frame
+	StartOfFrame bool
+	EndOfFrame   bool
+
+	// The layer indices are only valid if HasLayerIndices is set, without them the
+	// stream has a single layer
+	HasLayerIndices      bool
+	TemporalID           uint8
+	SpatialID            uint8
+	SwitchingUpPoint     bool
+	InterLayerDependency bool
+
+	// TL0PicIdx is the index of the picture of the temporal base layer, only in non-flexible mode
+	TL0PicIdx uint8
+
+	// ScalabilityStructure is set if the packet carries the scalability structure,
+	// usually the first packet of a keyframe
+	ScalabilityStructure *VP9ScalabilityStructure
+}
+
+// VP9ScalabilityStructure describes the layers of a VP9 stream
+type VP9ScalabilityStructure struct {
+	// SpatialLayers is the number of spatial layers of the stream
+	SpatialLayers uint8
+
+	// Width and Height are the resolution of each spatial layer, if signaled
+	Width  []uint16
+	Height []uint16
+}
+
+// ParseVP9Descriptor parses the payload descriptor of a VP9 RTP packet. It returns the
+// descriptor and the VP9 payload following it.
+func ParseVP9Descriptor(payload []byte) (*VP9Descriptor, []byte, error) {
+	packet := &codecs.VP9Packet{}
+	data, err := packet.Unmarshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d := &VP9Descriptor{
+		PictureID:             packet.PictureID,
+		HasPictureID:          packet.I,
+		InterPicturePredicted: packet.P,
+		Flexible:              packet.F,
+		StartOfFrame:          packet.B,
+		EndOfFrame:            packet.E,
+		HasLayerIndices:       packet.L,
+		TemporalID:            packet.TID,
+		SpatialID:             packet.SID,
+		SwitchingUpPoint:      packet.U,
+		InterLayerDependency:  packet.D,
+		TL0PicIdx:             packet.TL0PICIDX,
+	}
+	if packet.V {
+		d.ScalabilityStructure = &VP9ScalabilityStructure{
+			SpatialLayers: packet.NS + 1,
+			Width:         packet.Width,
+			Height:        packet.Height,
+		}
+	}
+	return d, data, nil
+}
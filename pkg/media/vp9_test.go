@@ -0,0 +1,50 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVP9Descriptor(t *testing.T) {
+	t.Run("Layer Indices", func(t *testing.T) {
+		// I, L, B, E set, 15 bit picture ID, TID 2, U, SID 1, D, TL0PICIDX 7
+		d, payload, err := ParseVP9Descriptor([]byte{0xAC, 0x81, 0x23, 0x53, 0x07, 0xFF})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0xFF}, payload)
+		assert.Equal(t, &VP9Descriptor{
+			PictureID:            0x123,
+			HasPictureID:         true,
+			StartOfFrame:         true,
+			EndOfFrame:           true,
+			HasLayerIndices:      true,
+			TemporalID:           2,
+			SpatialID:            1,
+			SwitchingUpPoint:     true,
+			InterLayerDependency: true,
+			TL0PicIdx:            7,
+		}, d)
+	})
+
+	t.Run("Scalability Structure", func(t *testing.T) {
+		// I, B, V set, 7 bit picture ID, two spatial layers with resolutions
+		d, payload, err := ParseVP9Descriptor([]byte{0x8A, 0x05, 0x30, 0x01, 0x40, 0x00, 0xB4, 0x02, 0x80, 0x01, 0x68, 0xFF})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0xFF}, payload)
+		assert.Equal(t, uint16(5), d.PictureID)
+		assert.False(t, d.HasLayerIndices)
+		assert.Equal(t, &VP9ScalabilityStructure{
+			SpatialLayers: 2,
+			Width:         []uint16{320, 640},
+			Height:        []uint16{180, 360},
+		}, d.ScalabilityStructure)
+	})
+
+	t.Run("Short", func(t *testing.T) {
+		_, _, err := ParseVP9Descriptor([]byte{})
+		assert.Error(t, err)
+
+		_, _, err = ParseVP9Descriptor([]byte{0x80})
+		assert.Error(t, err)
+	})
+}
@@ -158,3 +158,19 @@ func (e *RangeError) Error() string {
 func (e *RangeError) Unwrap() error {
 	return e.Err
 }
+
+// InvalidCharacterError indicates that a string contains characters that
+// aren't allowed.
+type InvalidCharacterError struct {
+	Err error
+}
+
+func (e *InvalidCharacterError) Error() string {
+	return fmt.Sprintf("InvalidCharacterError: %v", e.Err)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err, if err's type contains
+// an Unwrap method returning error. Otherwise, Unwrap returns nil.
+func (e *InvalidCharacterError) Unwrap() error {
+	return e.Err
+}
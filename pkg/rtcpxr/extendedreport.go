@@ -0,0 +1,362 @@
+// Package rtcpxr implements the RTCP Extended Reports (XR) of RFC 3611 that let
+// receive-only endpoints measure the round trip time and report VoIP metrics
+package rtcpxr
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// TypeExtendedReport is the RTCP packet type of Extended Reports
+const TypeExtendedReport rtcp.PacketType = 207
+
+// Block types of RFC 3611 Section 4
+const (
+	BlockTypeReceiverReferenceTime uint8 = 4
+	BlockTypeDLRR                  uint8 = 5
+	BlockTypeVoIPMetrics           uint8 = 7
+)
+
+const (
+	headerLength      = 4
+	ssrcLength        = 4
+	blockHeaderLength = 4
+	rtcpVersion       = 2
+
+	receiverReferenceTimeLength = 8
+	dlrrReportLength            = 12
+	voipMetricsLength           = 32
+
+	ntpEpochOffset = 2208988800
+)
+
+var (
+	errPacketTooShort = errors.New("rtcpxr: packet too short")
+	errWrongType      = errors.New("rtcpxr: wrong packet type")
+	errBadVersion     = errors.New("rtcpxr: bad version")
+	errBadLength      = errors.New("rtcpxr: block length doesn't match")
+)
+
+// ReportBlock is a report block of an ExtendedReport
+type ReportBlock interface {
+	// DestinationSSRC returns the sources the block reports about
+	DestinationSSRC() []uint32
+
+	blockType() uint8
+	marshalBody() []byte
+}
+
+// ExtendedReport is a RTCP XR packet, RFC 3611 Section 2
+type ExtendedReport struct {
+	// SenderSSRC is the synchronization source of the originator of the report
+	SenderSSRC uint32
+	Reports    []ReportBlock
+}
+
+var _ rtcp.Packet = (*ExtendedReport)(nil)
+
+// ReceiverReferenceTimeReportBlock lets receivers send a NTP timestamp that the senders
+// echo in a DLRRReportBlock, RFC 3611 Section 4.4
+type ReceiverReferenceTimeReportBlock struct {
+	NTPTimestamp uint64
+}
+
+// DLRRReport echoes a ReceiverReferenceTimeReportBlock of a receiver
+type DLRRReport struct {
+	// SSRC is the originator of the echoed ReceiverReferenceTimeReportBlock
+	SSRC uint32
+
+	// LastRR is the middle 32 bits of the echoed NTP timestamp
+	LastRR uint32
+
+	// DLRR is the delay since the echoed report has been received in units of 1/65536 seconds
+	DLRR uint32
+}
+
+// DLRRReportBlock is sent in response to ReceiverReferenceTimeReportBlocks, RFC 3611 Section 4.5
+type DLRRReportBlock struct {
+	Reports []DLRRReport
+}
+
+// VoIPMetricsReportBlock reports the quality of a received stream, RFC 3611 Section 4.7.
+// Rates and densities are fractions in units of 1/256, delays and durations are in milliseconds.
+type VoIPMetricsReportBlock struct {
+	SSRC              uint32
+	LossRate          uint8
+	DiscardRate       uint8
+	BurstDensity      uint8
+	GapDensity        uint8
+	BurstDuration     uint16
+	GapDuration       uint16
+	RoundTripDelay    uint16
+	EndSystemDelay    uint16
+	SignalLevel       uint8
+	NoiseLevel        uint8
+	RERL              uint8
+	Gmin              uint8
+	RFactor           uint8
+	ExtRFactor        uint8
+	MOSLQ             uint8
+	MOSCQ             uint8
+	RXConfig          uint8
+	JBNominal         uint16
+	JBMaximum         uint16
+	JBAbsoluteMaximum uint16
+}
+
+// UnknownReportBlock is a report block of a type that isn't implemented
+type UnknownReportBlock struct {
+	Type         uint8
+	TypeSpecific uint8
+	Body         []byte
+}
+
+// DestinationSSRC returns nil, the reference time isn't about a source
+func (b *ReceiverReferenceTimeReportBlock) DestinationSSRC() []uint32 {
+	return nil
+}
+
+func (b *ReceiverReferenceTimeReportBlock) blockType() uint8 {
+	return BlockTypeReceiverReferenceTime
+}
+
+func (b *ReceiverReferenceTimeReportBlock) marshalBody() []byte {
+	body := make([]byte, receiverReferenceTimeLength)
+	binary.BigEndian.PutUint64(body, b.NTPTimestamp)
+	return body
+}
+
+// DestinationSSRC returns the receivers the reports are echoed to
+func (b *DLRRReportBlock) DestinationSSRC() []uint32 {
+	ssrcs := make([]uint32, 0, len(b.Reports))
+	for _, report := range b.Reports {
+		ssrcs = append(ssrcs, report.SSRC)
+	}
+	return ssrcs
+}
+
+func (b *DLRRReportBlock) blockType() uint8 {
+	return BlockTypeDLRR
+}
+
+func (b *DLRRReportBlock) marshalBody() []byte {
+	body := make([]byte, dlrrReportLength*len(b.Reports))
+	for i, report := range b.Reports {
+		binary.BigEndian.PutUint32(body[i*dlrrReportLength:], report.SSRC)
+		binary.BigEndian.PutUint32(body[i*dlrrReportLength+4:], report.LastRR)
+		binary.BigEndian.PutUint32(body[i*dlrrReportLength+8:], report.DLRR)
+	}
+	return body
+}
+
+// RoundTripTime calculates the round trip time from a report that arrived at the
+// given time, as described in RFC 3611 Section 4.5. It returns false if the report
+// doesn't echo a reference time.
+func (r DLRRReport) RoundTripTime(arrival time.Time) (time.Duration, bool) {
+	if r.LastRR == 0 {
+		return 0, false
+	}
+
+	rtt := middleNTP(ToNTP(arrival)) - r.LastRR - r.DLRR
+	if int32(rtt) < 0 {
+		return 0, true
+	}
+	return time.Duration(rtt) * time.Second >> 16, true
+}
+
+// DestinationSSRC returns the source the metrics are about
+func (b *VoIPMetricsReportBlock) DestinationSSRC() []uint32 {
+	return []uint32{b.SSRC}
+}
+
+func (b *VoIPMetricsReportBlock) blockType() uint8 {
+	return BlockTypeVoIPMetrics
+}
+
+func (b *VoIPMetricsReportBlock) marshalBody() []byte {
+	body := make([]byte, voipMetricsLength)
+	binary.BigEndian.PutUint32(body[0:], b.SSRC)
+	body[4], body[5], body[6], body[7] = b.LossRate, b.DiscardRate, b.BurstDensity, b.GapDensity
+	binary.BigEndian.PutUint16(body[8:], b.BurstDuration)
+	binary.BigEndian.PutUint16(body[10:], b.GapDuration)
+	binary.BigEndian.PutUint16(body[12:], b.RoundTripDelay)
+	binary.BigEndian.PutUint16(body[14:], b.EndSystemDelay)
+	body[16], body[17], body[18], body[19] = b.SignalLevel, b.NoiseLevel, b.RERL, b.Gmin
+	body[20], body[21], body[22], body[23] = b.RFactor, b.ExtRFactor, b.MOSLQ, b.MOSCQ
+	body[24] = b.RXConfig
+	binary.BigEndian.PutUint16(body[26:], b.JBNominal)
+	binary.BigEndian.PutUint16(body[28:], b.JBMaximum)
+	binary.BigEndian.PutUint16(body[30:], b.JBAbsoluteMaximum)
+	return body
+}
+
+func (b *VoIPMetricsReportBlock) unmarshalBody(body []byte) {
+	b.SSRC = binary.BigEndian.Uint32(body[0:])
+	b.LossRate, b.DiscardRate, b.BurstDensity, b.GapDensity = body[4], body[5], body[6], body[7]
+	b.BurstDuration = binary.BigEndian.Uint16(body[8:])
+	b.GapDuration = binary.BigEndian.Uint16(body[10:])
+	b.RoundTripDelay = binary.BigEndian.Uint16(body[12:])
+	b.EndSystemDelay = binary.BigEndian.Uint16(body[14:])
+	b.SignalLevel, b.NoiseLevel, b.RERL, b.Gmin = body[16], body[17], body[18], body[19]
+	b.RFactor, b.ExtRFactor, b.MOSLQ, b.MOSCQ = body[20], body[21], body[22], body[23]
+	b.RXConfig = body[24]
+	b.JBNominal = binary.BigEndian.Uint16(body[26:])
+	b.JBMaximum = binary.BigEndian.Uint16(body[28:])
+	b.JBAbsoluteMaximum = binary.BigEndian.Uint16(body[30:])
+}
+
+// DestinationSSRC returns nil, the block isn't understood
+func (b *UnknownReportBlock) DestinationSSRC() []uint32 {
+	return nil
+}
+
+func (b *UnknownReportBlock) blockType() uint8 {
+	return b.Type
+}
+
+func (b *UnknownReportBlock) marshalBody() []byte {
+	return b.Body
+}
+
+// DestinationSSRC returns the sources the report blocks are about
+func (x *ExtendedReport) DestinationSSRC() []uint32 {
+	ssrcs := []uint32{}
+	for _, report := range x.Reports {
+		ssrcs = append(ssrcs, report.DestinationSSRC()...)
+	}
+	return ssrcs
+}
+
+// Marshal encodes the ExtendedReport in binary
+func (x *ExtendedReport) Marshal() ([]byte, error) {
+	length := headerLength + ssrcLength
+	bodies := make([][]byte, len(x.Reports))
+	for i, report := range x.Reports {
+		bodies[i] = report.marshalBody()
+		if len(bodies[i])%4 != 0 {
+			return nil, errBadLength
+		}
+		length += blockHeaderLength + len(bodies[i])
+	}
+
+	b := make([]byte, length)
+	b[0] = rtcpVersion << 6
+	b[1] = uint8(TypeExtendedReport)
+	binary.BigEndian.PutUint16(b[2:], uint16(length/4-1))
+	binary.BigEndian.PutUint32(b[4:], x.SenderSSRC)
+
+	offset := headerLength + ssrcLength
+	for i, report := range x.Reports {
+		b[offset] = report.blockType()
+		if unknown, ok := report.(*UnknownReportBlock); ok {
+			b[offset+1] = unknown.TypeSpecific
+		}
+		binary.BigEndian.PutUint16(b[offset+2:], uint16(len(bodies[i])/4))
+		offset += blockHeaderLength
+		offset += copy(b[offset:], bodies[i])
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the ExtendedReport from binary
+func (x *ExtendedReport) Unmarshal(b []byte) error {
+	if len(b) < headerLength+ssrcLength {
+		return errPacketTooShort
+	}
+	if b[0]>>6 != rtcpVersion {
+		return errBadVersion
+	}
+	if rtcp.PacketType(b[1]) != TypeExtendedReport {
+		return errWrongType
+	}
+
+	length := (int(binary.BigEndian.Uint16(b[2:])) + 1) * 4
+	if length > len(b) || length < headerLength+ssrcLength {
+		return errPacketTooShort
+	}
+	b = b[:length]
+
+	x.SenderSSRC = binary.BigEndian.Uint32(b[4:])
+	x.Reports = nil
+	for offset := headerLength + ssrcLength; offset < len(b); {
+		if len(b)-offset < blockHeaderLength {
+			return errPacketTooShort
+		}
+		blockType, typeSpecific := b[offset], b[offset+1]
+		bodyLength := int(binary.BigEndian.Uint16(b[offset+2:])) * 4
+		offset += blockHeaderLength
+		if len(b)-offset < bodyLength {
+			return errPacketTooShort
+		}
+		body := b[offset : offset+bodyLength]
+		offset += bodyLength
+
+		switch blockType {
+		case BlockTypeReceiverReferenceTime:
+			if bodyLength != receiverReferenceTimeLength {
+				return errBadLength
+			}
+			x.Reports = append(x.Reports, &ReceiverReferenceTimeReportBlock{NTPTimestamp: binary.BigEndian.Uint64(body)})
+		case BlockTypeDLRR:
+			if bodyLength%dlrrReportLength != 0 {
+				return errBadLength
+			}
+			block := &DLRRReportBlock{}
+			for i := 0; i < bodyLength; i += dlrrReportLength {
+				block.Reports = append(block.Reports, DLRRReport{
+					SSRC:   binary.BigEndian.Uint32(body[i:]),
+					LastRR: binary.BigEndian.Uint32(body[i+4:]),
+					DLRR:   binary.BigEndian.Uint32(body[i+8:]),
+				})
+			}
+			x.Reports = append(x.Reports, block)
+		case BlockTypeVoIPMetrics:
+			if bodyLength != voipMetricsLength {
+				return errBadLength
+			}
+			block := &VoIPMetricsReportBlock{}
+			block.unmarshalBody(body)
+			x.Reports = append(x.Reports, block)
+		default:
+			x.Reports = append(x.Reports, &UnknownReportBlock{
+				Type:         blockType,
+				TypeSpecific: typeSpecific,
+				Body:         append([]byte{}, body...),
+			})
+		}
+	}
+	return nil
+}
+
+// FromPackets returns the Extended Reports of RTCP packets unmarshaled by rtcp.Unmarshal,
+// which doesn't know them and returns them as rtcp.RawPacket. Invalid reports are skipped.
+func FromPackets(pkts []rtcp.Packet) []*ExtendedReport {
+	reports := []*ExtendedReport{}
+	for _, pkt := range pkts {
+		raw, ok := pkt.(*rtcp.RawPacket)
+		if !ok || len(*raw) < headerLength || rtcp.PacketType((*raw)[1]) != TypeExtendedReport {
+			continue
+		}
+
+		report := &ExtendedReport{}
+		if err := report.Unmarshal(*raw); err == nil {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// ToNTP converts a time to a 64 bit NTP timestamp, seconds since 1900 in 32.32 fixed point
+func ToNTP(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// middleNTP returns the middle 32 bits of a NTP timestamp that are echoed in reports
+func middleNTP(ntp uint64) uint32 {
+	return uint32(ntp >> 16)
+}
@@ -0,0 +1,69 @@
+package rtcpxr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendedReport_Marshal(t *testing.T) {
+	report := &ExtendedReport{
+		SenderSSRC: 0x01020304,
+		Reports: []ReportBlock{
+			&ReceiverReferenceTimeReportBlock{NTPTimestamp: 0x0102030405060708},
+			&DLRRReportBlock{Reports: []DLRRReport{{SSRC: 0x11, LastRR: 0x22, DLRR: 0x33}, {SSRC: 0x44, LastRR: 0x55, DLRR: 0x66}}},
+			&VoIPMetricsReportBlock{SSRC: 0x77, LossRate: 12, Gmin: 16, RoundTripDelay: 150, SignalLevel: 127, JBAbsoluteMaximum: 200},
+			&UnknownReportBlock{Type: 42, TypeSpecific: 1, Body: []byte{1, 2, 3, 4}},
+		},
+	}
+
+	b, err := report.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 207, 0x00, 22, 0x01, 0x02, 0x03, 0x04}, b[:8])
+	assert.Equal(t, []byte{4, 0, 0, 2, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, b[8:20])
+	assert.Equal(t, []byte{5, 0, 0, 6}, b[20:24])
+	assert.Equal(t, []byte{7, 0, 0, 8}, b[48:52])
+	assert.Equal(t, []byte{42, 1, 0, 1, 1, 2, 3, 4}, b[84:])
+
+	unmarshaled := &ExtendedReport{}
+	assert.NoError(t, unmarshaled.Unmarshal(b))
+	assert.Equal(t, report, unmarshaled)
+	assert.Equal(t, []uint32{0x11, 0x44, 0x77}, unmarshaled.DestinationSSRC())
+
+	for _, invalid := range [][]byte{
+		b[:6],
+		b[:20],
+		append([]byte{0x40}, b[1:]...),
+		append([]byte{0x80, 200}, b[2:]...),
+		{0x80, 207, 0x00, 0x02, 0, 0, 0, 1, 4, 0, 0, 1, 0, 0, 0, 0},
+	} {
+		assert.Error(t, (&ExtendedReport{}).Unmarshal(invalid))
+	}
+}
+
+func TestFromPackets(t *testing.T) {
+	report := &ExtendedReport{SenderSSRC: 1, Reports: []ReportBlock{&ReceiverReferenceTimeReportBlock{NTPTimestamp: 2}}}
+	b, err := rtcp.Marshal([]rtcp.Packet{&rtcp.ReceiverReport{SSRC: 1}, report})
+	assert.NoError(t, err)
+
+	pkts, err := rtcp.Unmarshal(b)
+	assert.NoError(t, err)
+	assert.Len(t, pkts, 2)
+	assert.Equal(t, []*ExtendedReport{report}, FromPackets(pkts))
+}
+
+func TestDLRRReport_RoundTripTime(t *testing.T) {
+	sent := time.Unix(1600000000, 0)
+	lastRR := middleNTP(ToNTP(sent))
+
+	// The report has been held for 100ms by the sender, it arrives 350ms after the reference time
+	report := DLRRReport{SSRC: 1, LastRR: lastRR, DLRR: 65536 / 10}
+	rtt, ok := report.RoundTripTime(sent.Add(350 * time.Millisecond))
+	assert.True(t, ok)
+	assert.InDelta(t, float64(250*time.Millisecond), float64(rtt), float64(time.Millisecond))
+
+	_, ok = DLRRReport{SSRC: 1}.RoundTripTime(sent)
+	assert.False(t, ok)
+}
@@ -0,0 +1,274 @@
+package rtcpxr
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+const (
+	// gmin is the minimum number of received packets between two losses that ends
+	// a burst, the value recommended by RFC 3611 Section 4.7.2
+	gmin = 16
+
+	// unavailable marks a metric of a VoIPMetricsReportBlock that isn't measured
+	unavailable = 127
+)
+
+// Interceptor adds Extended Reports to the Sender and Receiver Reports of the
+// report interceptors, it has to be registered before them so they write through it.
+//
+// Receiver Reports are extended with a ReceiverReferenceTimeReportBlock and a
+// VoIPMetricsReportBlock for every reported stream. Sender Reports are extended
+// with a DLRRReportBlock that echoes the last reference time received for the
+// stream, which lets the receiver calculate the round trip time without sending
+// media itself.
+//
+// Incoming RTCP is inspected when it is read, like the report interceptors the
+// RTCP of every RTPSender and RTPReceiver has to be read for it to work.
+type Interceptor struct {
+	interceptor.NoOp
+	now func() time.Time
+
+	mu sync.Mutex
+
+	// referenceTimes are the last reference times received, per local stream they were
+	// sent with and the SSRC of their originator
+	referenceTimes map[uint32]map[uint32]referenceTime
+
+	// roundTripTimes are the round trip times measured with DLRR reports, per remote stream
+	roundTripTimes map[uint32]time.Duration
+
+	remoteStreams map[uint32]*remoteStream
+}
+
+type referenceTime struct {
+	lastRR  uint32
+	arrival time.Time
+}
+
+// remoteStream counts the received packets of a stream to estimate its loss rate
+type remoteStream struct {
+	started         bool
+	firstSequence   uint32
+	highestSequence uint32
+	received        uint64
+}
+
+// NewInterceptor returns a new Interceptor
+func NewInterceptor() (*Interceptor, error) {
+	return &Interceptor{
+		now:            time.Now,
+		referenceTimes: map[uint32]map[uint32]referenceTime{},
+		roundTripTimes: map[uint32]time.Duration{},
+		remoteStreams:  map[uint32]*remoteStream{},
+	}, nil
+}
+
+// BindRTCPReader inspects incoming RTCP for reference times and DLRR reports
+func (i *Interceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, a)
+		if err != nil {
+			return n, attributes, err
+		}
+
+		if pkts, unmarshalErr := rtcp.Unmarshal(b[:n]); unmarshalErr == nil {
+			i.handleIncoming(pkts)
+		}
+		return n, attributes, nil
+	})
+}
+
+// BindRTCPWriter appends Extended Reports to outgoing Sender and Receiver Reports
+func (i *Interceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		return writer.Write(i.withExtendedReports(pkts), attributes)
+	})
+}
+
+// BindRemoteStream counts the received packets of a stream for its VoIP metrics
+func (i *Interceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	stream := &remoteStream{}
+	i.mu.Lock()
+	i.remoteStreams[info.SSRC] = stream
+	i.mu.Unlock()
+
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, a)
+		if err != nil {
+			return n, attributes, err
+		}
+
+		header := rtp.Header{}
+		if unmarshalErr := header.Unmarshal(b[:n]); unmarshalErr == nil {
+			i.mu.Lock()
+			stream.receive(header.SequenceNumber)
+			i.mu.Unlock()
+		}
+		return n, attributes, nil
+	})
+}
+
+// UnbindRemoteStream forgets the stream
+func (i *Interceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.remoteStreams, info.SSRC)
+	delete(i.roundTripTimes, info.SSRC)
+}
+
+// UnbindLocalStream forgets the reference times received for the stream
+func (i *Interceptor) UnbindLocalStream(info *interceptor.StreamInfo) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.referenceTimes, info.SSRC)
+}
+
+// RoundTripTime returns the last round trip time measured for a received stream
+func (i *Interceptor) RoundTripTime(ssrc uint32) (time.Duration, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	rtt, ok := i.roundTripTimes[ssrc]
+	return rtt, ok
+}
+
+func (i *Interceptor) handleIncoming(pkts []rtcp.Packet) {
+	reports := FromPackets(pkts)
+	if len(reports) == 0 {
+		return
+	}
+
+	// Reference times concern the local streams the Receiver Report of the compound is about
+	localSSRCs := []uint32{}
+	for _, pkt := range pkts {
+		if rr, ok := pkt.(*rtcp.ReceiverReport); ok {
+			localSSRCs = append(localSSRCs, rr.DestinationSSRC()...)
+		}
+	}
+
+	now := i.now()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, report := range reports {
+		for _, block := range report.Reports {
+			switch block := block.(type) {
+			case *ReceiverReferenceTimeReportBlock:
+				for _, ssrc := range localSSRCs {
+					if i.referenceTimes[ssrc] == nil {
+						i.referenceTimes[ssrc] = map[uint32]referenceTime{}
+					}
+					i.referenceTimes[ssrc][report.SenderSSRC] = referenceTime{lastRR: middleNTP(block.NTPTimestamp), arrival: now}
+				}
+			case *DLRRReportBlock:
+				for _, dlrr := range block.Reports {
+					if rtt, ok := dlrr.RoundTripTime(now); ok {
+						i.roundTripTimes[report.SenderSSRC] = rtt
+					}
+				}
+			}
+		}
+	}
+}
+
+func (i *Interceptor) withExtendedReports(pkts []rtcp.Packet) []rtcp.Packet {
+	now := i.now()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	out := pkts
+	for _, pkt := range pkts {
+		switch pkt := pkt.(type) {
+		case *rtcp.ReceiverReport:
+			report := &ExtendedReport{
+				SenderSSRC: pkt.SSRC,
+				Reports:    []ReportBlock{&ReceiverReferenceTimeReportBlock{NTPTimestamp: ToNTP(now)}},
+			}
+			for _, reception := range pkt.Reports {
+				report.Reports = append(report.Reports, i.voipMetrics(reception.SSRC))
+			}
+			out = append(out, report)
+		case *rtcp.SenderReport:
+			block := &DLRRReportBlock{}
+			for ssrc, reference := range i.referenceTimes[pkt.SSRC] {
+				block.Reports = append(block.Reports, DLRRReport{
+					SSRC:   ssrc,
+					LastRR: reference.lastRR,
+					DLRR:   uint32(now.Sub(reference.arrival) << 16 / time.Second),
+				})
+			}
+			if len(block.Reports) != 0 {
+				out = append(out, &ExtendedReport{SenderSSRC: pkt.SSRC, Reports: []ReportBlock{block}})
+			}
+		}
+	}
+	return out
+}
+
+// voipMetrics reports the metrics that are known without a jitter buffer or decoder,
+// the others are reported as unavailable
+func (i *Interceptor) voipMetrics(ssrc uint32) *VoIPMetricsReportBlock {
+	block := &VoIPMetricsReportBlock{
+		SSRC:        ssrc,
+		SignalLevel: unavailable,
+		NoiseLevel:  unavailable,
+		RERL:        unavailable,
+		Gmin:        gmin,
+		RFactor:     unavailable,
+		ExtRFactor:  unavailable,
+		MOSLQ:       unavailable,
+		MOSCQ:       unavailable,
+	}
+
+	if stream, ok := i.remoteStreams[ssrc]; ok {
+		block.LossRate = stream.lossRate()
+	}
+	if rtt, ok := i.roundTripTimes[ssrc]; ok {
+		block.RoundTripDelay = uint16(rtt / time.Millisecond)
+	}
+	return block
+}
+
+func (s *remoteStream) receive(sequenceNumber uint16) {
+	if !s.started {
+		s.started = true
+		s.firstSequence = uint32(sequenceNumber)
+		s.highestSequence = uint32(sequenceNumber)
+		s.received++
+		return
+	}
+
+	// Extend the sequence number with the cycles of the highest one received
+	extended := s.highestSequence&0xFFFF0000 | uint32(sequenceNumber)
+	switch diff := int32(extended - s.highestSequence); {
+	case diff < -0x8000:
+		extended += 0x10000
+	case diff > 0x8000:
+		extended -= 0x10000
+	}
+
+	if int32(extended-s.highestSequence) > 0 {
+		s.highestSequence = extended
+	}
+	s.received++
+}
+
+// lossRate is the fraction of packets lost since the beginning of reception in units of 1/256
+func (s *remoteStream) lossRate() uint8 {
+	if !s.started {
+		return 0
+	}
+
+	expected := uint64(s.highestSequence-s.firstSequence) + 1
+	if s.received >= expected {
+		return 0
+	}
+	return uint8((expected - s.received) * 256 / expected)
+}
@@ -0,0 +1,119 @@
+package rtcpxr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptor(t *testing.T) {
+	const mediaSSRC, receiverSSRC = 5, 9
+
+	now := time.Unix(1600000000, 0)
+	receiver, err := NewInterceptor()
+	assert.NoError(t, err)
+	receiver.now = func() time.Time { return now }
+
+	sender, err := NewInterceptor()
+	assert.NoError(t, err)
+	sender.now = func() time.Time { return now }
+
+	// Packet 3 of 4 is lost
+	var sequenceNumber uint16
+	rtpReader := receiver.BindRemoteStream(&interceptor.StreamInfo{SSRC: mediaSSRC}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		raw, marshalErr := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: mediaSSRC, SequenceNumber: sequenceNumber}}).Marshal()
+		assert.NoError(t, marshalErr)
+		return copy(b, raw), a, nil
+	}))
+	for _, s := range []uint16{65535, 0, 2} {
+		sequenceNumber = s
+		_, _, err = rtpReader.Read(make([]byte, 1500), nil)
+		assert.NoError(t, err)
+	}
+
+	var written []rtcp.Packet
+	capture := interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+		written = pkts
+		return 0, nil
+	})
+	marshalWritten := func() []byte {
+		b, marshalErr := rtcp.Marshal(written)
+		assert.NoError(t, marshalErr)
+		return b
+	}
+	reading := func(b []byte) interceptor.RTCPReader {
+		return interceptor.RTCPReaderFunc(func(in []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+			return copy(in, b), a, nil
+		})
+	}
+
+	// The receiver adds a reference time and VoIP metrics to its Receiver Report
+	_, err = receiver.BindRTCPWriter(capture).Write([]rtcp.Packet{&rtcp.ReceiverReport{
+		SSRC:    receiverSSRC,
+		Reports: []rtcp.ReceptionReport{{SSRC: mediaSSRC}},
+	}}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, written, 2)
+	assert.Equal(t, &ExtendedReport{
+		SenderSSRC: receiverSSRC,
+		Reports: []ReportBlock{
+			&ReceiverReferenceTimeReportBlock{NTPTimestamp: ToNTP(now)},
+			&VoIPMetricsReportBlock{
+				SSRC: mediaSSRC, LossRate: 64, Gmin: gmin,
+				SignalLevel: unavailable, NoiseLevel: unavailable, RERL: unavailable,
+				RFactor: unavailable, ExtRFactor: unavailable, MOSLQ: unavailable, MOSCQ: unavailable,
+			},
+		},
+	}, written[1])
+
+	// The sender echoes the reference time 100ms later with its Sender Report
+	_, _, err = sender.BindRTCPReader(reading(marshalWritten())).Read(make([]byte, 1500), nil)
+	assert.NoError(t, err)
+
+	referenceTime := now
+	now = now.Add(100 * time.Millisecond)
+	_, err = sender.BindRTCPWriter(capture).Write([]rtcp.Packet{&rtcp.SenderReport{SSRC: mediaSSRC}}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, written, 2)
+	assert.Equal(t, &ExtendedReport{
+		SenderSSRC: mediaSSRC,
+		Reports: []ReportBlock{&DLRRReportBlock{Reports: []DLRRReport{{
+			SSRC:   receiverSSRC,
+			LastRR: middleNTP(ToNTP(referenceTime)),
+			DLRR:   65536 / 10,
+		}}}},
+	}, written[1])
+
+	// Sender Reports of other streams don't echo it
+	_, err = sender.BindRTCPWriter(capture).Write([]rtcp.Packet{&rtcp.SenderReport{SSRC: mediaSSRC + 1}}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, written, 1)
+
+	// The receiver gets the echo 350ms after the reference time
+	_, err = sender.BindRTCPWriter(capture).Write([]rtcp.Packet{&rtcp.SenderReport{SSRC: mediaSSRC}}, nil)
+	assert.NoError(t, err)
+	now = referenceTime.Add(350 * time.Millisecond)
+	_, _, err = receiver.BindRTCPReader(reading(marshalWritten())).Read(make([]byte, 1500), nil)
+	assert.NoError(t, err)
+
+	rtt, ok := receiver.RoundTripTime(mediaSSRC)
+	assert.True(t, ok)
+	assert.InDelta(t, float64(250*time.Millisecond), float64(rtt), float64(time.Millisecond))
+
+	_, err = receiver.BindRTCPWriter(capture).Write([]rtcp.Packet{&rtcp.ReceiverReport{
+		SSRC:    receiverSSRC,
+		Reports: []rtcp.ReceptionReport{{SSRC: mediaSSRC}},
+	}}, nil)
+	assert.NoError(t, err)
+	metrics, ok := written[1].(*ExtendedReport).Reports[1].(*VoIPMetricsReportBlock)
+	assert.True(t, ok)
+	assert.InDelta(t, 250, metrics.RoundTripDelay, 1)
+
+	receiver.UnbindRemoteStream(&interceptor.StreamInfo{SSRC: mediaSSRC})
+	_, ok = receiver.RoundTripTime(mediaSSRC)
+	assert.False(t, ok)
+}
@@ -0,0 +1,123 @@
+// Package rtpmunger rewrites the sequence numbers and timestamps of RTP packets, so
+// packets that are spliced together from several streams, e.g. when switching between
+// simulcast layers or sources, or with packets left out, are one continuous stream.
+package rtpmunger
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// ErrPacketTooOld is returned for a packet that arrives out of order and is older than
+// the last switch or drop. Its sequence number can't be rewritten without colliding
+// with a packet that has been forwarded, it should be dropped.
+var ErrPacketTooOld = errors.New("rtpmunger: packet is older than the last switch or drop")
+
+// Munger maintains the offsets between the sequence numbers and timestamps of the
+// incoming packets and those of the forwarded stream. The first packet is forwarded
+// unchanged, all following packets continue the sequence numbers and timestamps of
+// the forwarded stream. It is safe for concurrent use.
+type Munger struct {
+	mu sync.Mutex
+
+	started bool
+
+	// switching is set by Switch until the first packet of the new stream
+	switching    bool
+	timestampGap uint32
+
+	sequenceNumberOffset uint16
+	timestampOffset      uint32
+
+	// highestSequenceNumber is the newest incoming packet, offsetSequenceNumber
+	// the first incoming packet the current offsets apply to
+	highestSequenceNumber uint16
+	offsetSequenceNumber  uint16
+
+	// lastSequenceNumber and lastTimestamp are those of the newest forwarded packet
+	lastSequenceNumber uint16
+	lastTimestamp      uint32
+}
+
+// New creates a Munger
+func New() *Munger {
+	return &Munger{}
+}
+
+// Rewrite rewrites the sequence number and timestamp of a packet that is forwarded.
+// Packets of the same stream can arrive out of order, ErrPacketTooOld is returned
+// for those that are older than the last Switch or Drop.
+func (m *Munger) Rewrite(header *rtp.Header) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case !m.started:
+		m.started = true
+		m.restart(header.SequenceNumber, 0, 0)
+	case m.switching:
+		m.switching = false
+		m.restart(header.SequenceNumber,
+			header.SequenceNumber-(m.lastSequenceNumber+1),
+			header.Timestamp-(m.lastTimestamp+m.timestampGap))
+	case int16(header.SequenceNumber-m.offsetSequenceNumber) < 0:
+		return ErrPacketTooOld
+	}
+
+	sequenceNumber := header.SequenceNumber - m.sequenceNumberOffset
+	timestamp := header.Timestamp - m.timestampOffset
+
+	if int16(header.SequenceNumber-m.highestSequenceNumber) >= 0 {
+		m.highestSequenceNumber = header.SequenceNumber
+		m.lastSequenceNumber = sequenceNumber
+		m.lastTimestamp = timestamp
+	}
+
+	header.SequenceNumber = sequenceNumber
+	header.Timestamp = timestamp
+	return nil
+}
+
+// Drop accounts a packet of the current stream that isn't forwarded, e.g. a packet
+// of a layer that is filtered out or a padding only packet. The sequence numbers of
+// the following packets are moved down, so the remote doesn't see a loss. Only a
+// packet newer than all others can be removed, a gap is left for an older one.
+func (m *Munger) Drop(header *rtp.Header) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Without a forwarded packet of the stream the next one continues the sequence anyway
+	if !m.started || m.switching || int16(header.SequenceNumber-m.highestSequenceNumber) <= 0 {
+		return
+	}
+
+	m.sequenceNumberOffset++
+	m.highestSequenceNumber = header.SequenceNumber
+	m.offsetSequenceNumber = header.SequenceNumber + 1
+	m.lastSequenceNumber = header.SequenceNumber - m.sequenceNumberOffset
+}
+
+// Switch splices another stream: the first packet passed to Rewrite afterwards is
+// forwarded with the sequence number following the newest forwarded packet, and
+// timestampGap added to its timestamp. The gap is in units of the clock rate and
+// should match the time that passed since the newest forwarded packet.
+func (m *Munger) Switch(timestampGap uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.started {
+		return
+	}
+
+	m.switching = true
+	m.timestampGap = timestampGap
+}
+
+func (m *Munger) restart(sequenceNumber, sequenceNumberOffset uint16, timestampOffset uint32) {
+	m.sequenceNumberOffset = sequenceNumberOffset
+	m.timestampOffset = timestampOffset
+	m.highestSequenceNumber = sequenceNumber
+	m.offsetSequenceNumber = sequenceNumber
+}
@@ -0,0 +1,97 @@
+package rtpmunger
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+type packet struct {
+	sequenceNumber uint16
+	timestamp      uint32
+}
+
+func rewrite(t *testing.T, m *Munger, in packet) packet {
+	header := &rtp.Header{SequenceNumber: in.sequenceNumber, Timestamp: in.timestamp}
+	assert.NoError(t, m.Rewrite(header))
+	return packet{header.SequenceNumber, header.Timestamp}
+}
+
+func TestMunger_Passthrough(t *testing.T) {
+	m := New()
+
+	assert.Equal(t, packet{100, 5000}, rewrite(t, m, packet{100, 5000}))
+	assert.Equal(t, packet{101, 5000}, rewrite(t, m, packet{101, 5000}))
+	assert.Equal(t, packet{103, 8000}, rewrite(t, m, packet{103, 8000}))
+
+	// Reordered packets keep their place
+	assert.Equal(t, packet{102, 5000}, rewrite(t, m, packet{102, 5000}))
+
+	assert.ErrorIs(t, m.Rewrite(&rtp.Header{SequenceNumber: 99}), ErrPacketTooOld)
+}
+
+func TestMunger_Switch(t *testing.T) {
+	m := New()
+
+	assert.Equal(t, packet{1000, 90000}, rewrite(t, m, packet{1000, 90000}))
+	assert.Equal(t, packet{1001, 93000}, rewrite(t, m, packet{1001, 93000}))
+
+	m.Switch(3000)
+	assert.Equal(t, packet{1002, 96000}, rewrite(t, m, packet{40000, 12345}))
+	assert.Equal(t, packet{1003, 99000}, rewrite(t, m, packet{40001, 15345}))
+
+	// Late packets of the new stream are older than the switch, those of the
+	// previous stream can't be told apart from them
+	assert.ErrorIs(t, m.Rewrite(&rtp.Header{SequenceNumber: 39999}), ErrPacketTooOld)
+
+	// A switch before the first packet has nothing to continue
+	m = New()
+	m.Switch(3000)
+	assert.Equal(t, packet{7, 7}, rewrite(t, m, packet{7, 7}))
+}
+
+func TestMunger_Drop(t *testing.T) {
+	m := New()
+
+	assert.Equal(t, packet{10, 0}, rewrite(t, m, packet{10, 0}))
+	m.Drop(&rtp.Header{SequenceNumber: 11})
+	assert.Equal(t, packet{11, 3000}, rewrite(t, m, packet{12, 3000}))
+	m.Drop(&rtp.Header{SequenceNumber: 13})
+	m.Drop(&rtp.Header{SequenceNumber: 14})
+	assert.Equal(t, packet{12, 6000}, rewrite(t, m, packet{15, 6000}))
+
+	// Only the newest packet can be removed
+	m.Drop(&rtp.Header{SequenceNumber: 15})
+	assert.Equal(t, packet{13, 9000}, rewrite(t, m, packet{16, 9000}))
+
+	// A packet that has been lost before the drop leaves a gap
+	m.Drop(&rtp.Header{SequenceNumber: 18})
+	assert.ErrorIs(t, m.Rewrite(&rtp.Header{SequenceNumber: 17}), ErrPacketTooOld)
+	assert.Equal(t, packet{15, 12000}, rewrite(t, m, packet{19, 12000}))
+
+	// The stream after a switch follows the last forwarded packet
+	m.Drop(&rtp.Header{SequenceNumber: 20})
+	m.Switch(3000)
+	assert.Equal(t, packet{16, 15000}, rewrite(t, m, packet{500, 1}))
+}
+
+func TestMunger_Wraparound(t *testing.T) {
+	m := New()
+
+	assert.Equal(t, packet{65534, 4294965000}, rewrite(t, m, packet{65534, 4294965000}))
+	assert.Equal(t, packet{65535, 4294965000}, rewrite(t, m, packet{65535, 4294965000}))
+	assert.Equal(t, packet{1, 1704}, rewrite(t, m, packet{1, 1704}))
+	assert.Equal(t, packet{0, 4294965000}, rewrite(t, m, packet{0, 4294965000}))
+
+	// Offsets wrap as well
+	m.Switch(3000)
+	assert.Equal(t, packet{2, 4704}, rewrite(t, m, packet{65000, 100}))
+	assert.Equal(t, packet{3, 7704}, rewrite(t, m, packet{65001, 3100}))
+	m.Drop(&rtp.Header{SequenceNumber: 65002})
+	assert.Equal(t, packet{4, 10704}, rewrite(t, m, packet{65003, 6100}))
+
+	m.Switch(3000)
+	assert.Equal(t, packet{5, 13704}, rewrite(t, m, packet{65535, 4294967000}))
+	assert.Equal(t, packet{6, 16704}, rewrite(t, m, packet{0, 2704}))
+}
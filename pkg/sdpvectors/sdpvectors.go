@@ -0,0 +1,196 @@
+// +build !js
+
+// Package sdpvectors provides offers and answers as created by the major browsers for
+// common scenarios, and negotiates them against PeerConnections. Applications can use
+// them in their tests to make sure their configuration, e.g. of the MediaEngine, still
+// negotiates with browsers after upgrading.
+package sdpvectors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// Browsers the offers have been created by
+const (
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+	BrowserSafari  = "safari"
+)
+
+// Scenarios the offers have been created for
+const (
+	// ScenarioAudioVideo is an offer with a sendrecv audio and video track
+	ScenarioAudioVideo = "audio-video"
+
+	// ScenarioSimulcast is an offer sending a video track with three simulcast layers
+	ScenarioSimulcast = "simulcast"
+
+	// ScenarioDataChannel is an offer with only a DataChannel
+	ScenarioDataChannel = "datachannel"
+)
+
+var (
+	errNotFound = errors.New("no vector for browser and scenario")
+	errNoAnswer = errors.New("vector has no answer")
+)
+
+// Vector is an offer created by a browser, and the answer the browser created for
+// an offer of pion
+type Vector struct {
+	Browser  string
+	Version  string
+	Scenario string
+	Offer    string
+
+	// Answer is empty if there is no answer for the scenario, pion can't offer
+	// to receive simulcast
+	Answer string
+}
+
+// Name returns a name to use for subtests, e.g. chrome-90/audio-video
+func (v Vector) Name() string {
+	return v.Browser + "-" + v.Version + "/" + v.Scenario
+}
+
+// SessionDescription returns the offer as SessionDescription
+func (v Vector) SessionDescription() webrtc.SessionDescription {
+	return webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: strings.ReplaceAll(v.Offer, "\n", "\r\n")}
+}
+
+// AnswerSessionDescription returns the answer as SessionDescription
+func (v Vector) AnswerSessionDescription() webrtc.SessionDescription {
+	return webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: strings.ReplaceAll(v.Answer, "\n", "\r\n")}
+}
+
+var vectors = []Vector{
+	{BrowserChrome, "90", ScenarioAudioVideo, chromeAudioVideo, chromeAudioVideoAnswer},
+	{BrowserChrome, "90", ScenarioSimulcast, chromeSimulcast, ""},
+	{BrowserChrome, "90", ScenarioDataChannel, chromeDataChannel, chromeDataChannelAnswer},
+	{BrowserFirefox, "88", ScenarioAudioVideo, firefoxAudioVideo, firefoxAudioVideoAnswer},
+	{BrowserFirefox, "88", ScenarioSimulcast, firefoxSimulcast, ""},
+	{BrowserFirefox, "88", ScenarioDataChannel, firefoxDataChannel, firefoxDataChannelAnswer},
+	{BrowserSafari, "14", ScenarioAudioVideo, safariAudioVideo, safariAudioVideoAnswer},
+	{BrowserSafari, "14", ScenarioDataChannel, safariDataChannel, safariDataChannelAnswer},
+	{BrowserSafari, "12", ScenarioAudioVideo, safariLegacyAudioVideo, ""},
+	{BrowserSafari, "12", ScenarioDataChannel, safariLegacyDataChannel, ""},
+}
+
+// All returns all vectors
+func All() []Vector {
+	return append([]Vector{}, vectors...)
+}
+
+// Get returns the vector of a browser for a scenario
+func Get(browser, scenario string) (Vector, error) {
+	for _, v := range vectors {
+		if v.Browser == browser && v.Scenario == scenario {
+			return v, nil
+		}
+	}
+	return Vector{}, fmt.Errorf("%w: %s %s", errNotFound, browser, scenario)
+}
+
+// MediaSection is the outcome of the negotiation of a media section
+type MediaSection struct {
+	Mid  string
+	Kind string
+
+	// Rejected is true if the answer rejects the media section
+	Rejected bool
+
+	// Codecs are the names of the codecs accepted by the answer, in order of preference
+	Codecs []string
+}
+
+// Result is the outcome of a negotiation
+type Result struct {
+	Answer        webrtc.SessionDescription
+	MediaSections []MediaSection
+}
+
+// Rejected returns the mids of all rejected media sections
+func (r *Result) Rejected() []string {
+	var mids []string
+	for _, m := range r.MediaSections {
+		if m.Rejected {
+			mids = append(mids, m.Mid)
+		}
+	}
+	return mids
+}
+
+// Negotiate sets the offer of the vector as remote description of the PeerConnection,
+// and creates and sets the answer. Add tracks to the PeerConnection beforehand to
+// negotiate sending media.
+func Negotiate(pc *webrtc.PeerConnection, v Vector) (*Result, error) {
+	if err := pc.SetRemoteDescription(v.SessionDescription()); err != nil {
+		return nil, err
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = pc.SetLocalDescription(answer); err != nil {
+		return nil, err
+	}
+
+	return newResult(answer)
+}
+
+// NegotiateAnswer creates an offer with the PeerConnection and sets the answer of the
+// vector as remote description. The PeerConnection needs the media sections the browser
+// answered: an audio and then a video transceiver for ScenarioAudioVideo, a DataChannel
+// for ScenarioDataChannel. The Result describes the answer of the browser.
+func NegotiateAnswer(pc *webrtc.PeerConnection, v Vector) (*Result, error) {
+	if v.Answer == "" {
+		return nil, fmt.Errorf("%w: %s", errNoAnswer, v.Name())
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = pc.SetLocalDescription(offer); err != nil {
+		return nil, err
+	}
+
+	answer := v.AnswerSessionDescription()
+	if err = pc.SetRemoteDescription(answer); err != nil {
+		return nil, err
+	}
+	return newResult(answer)
+}
+
+func newResult(answer webrtc.SessionDescription) (*Result, error) {
+	parsed := &sdp.SessionDescription{}
+	if err := parsed.Unmarshal([]byte(answer.SDP)); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Answer: answer}
+	for _, media := range parsed.MediaDescriptions {
+		section := MediaSection{
+			Kind:     media.MediaName.Media,
+			Rejected: media.MediaName.Port.Value == 0,
+		}
+		section.Mid, _ = media.Attribute(sdp.AttrKeyMID)
+
+		for _, format := range media.MediaName.Formats {
+			for _, a := range media.Attributes {
+				if a.Key != "rtpmap" || !strings.HasPrefix(a.Value, format+" ") {
+					continue
+				}
+				name := strings.TrimPrefix(a.Value, format+" ")
+				section.Codecs = append(section.Codecs, strings.Split(name, "/")[0])
+			}
+		}
+		result.MediaSections = append(result.MediaSections, section)
+	}
+	return result, nil
+}
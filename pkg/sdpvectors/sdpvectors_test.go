@@ -0,0 +1,119 @@
+// +build !js
+
+package sdpvectors
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, v := range All() {
+		v := v
+		t.Run(v.Name(), func(t *testing.T) {
+			m := &webrtc.MediaEngine{}
+			assert.NoError(t, m.RegisterDefaultCodecs())
+			pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(m)).NewPeerConnection(webrtc.Configuration{})
+			assert.NoError(t, err)
+
+			result, err := Negotiate(pc, v)
+			assert.NoError(t, err)
+			assert.Empty(t, result.Rejected())
+
+			for _, section := range result.MediaSections {
+				switch section.Kind {
+				case "audio":
+					assert.Equal(t, "opus", section.Codecs[0])
+				case "video":
+					assert.NotEmpty(t, section.Codecs)
+				}
+			}
+
+			assert.NoError(t, pc.Close())
+		})
+	}
+}
+
+func TestNegotiateAnswer(t *testing.T) {
+	for _, v := range All() {
+		if v.Answer == "" {
+			continue
+		}
+
+		v := v
+		t.Run(v.Name(), func(t *testing.T) {
+			m := &webrtc.MediaEngine{}
+			assert.NoError(t, m.RegisterDefaultCodecs())
+			pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(m)).NewPeerConnection(webrtc.Configuration{})
+			assert.NoError(t, err)
+
+			switch v.Scenario {
+			case ScenarioAudioVideo:
+				_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio)
+				assert.NoError(t, err)
+				_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+				assert.NoError(t, err)
+			case ScenarioDataChannel:
+				_, err = pc.CreateDataChannel("data", nil)
+				assert.NoError(t, err)
+			}
+
+			result, err := NegotiateAnswer(pc, v)
+			assert.NoError(t, err)
+			assert.Empty(t, result.Rejected())
+
+			for _, section := range result.MediaSections {
+				switch section.Kind {
+				case "audio":
+					assert.Equal(t, "opus", section.Codecs[0])
+				case "video":
+					assert.Equal(t, "VP8", section.Codecs[0])
+				}
+			}
+
+			assert.NoError(t, pc.Close())
+		})
+	}
+
+	v, err := Get(BrowserChrome, ScenarioSimulcast)
+	assert.NoError(t, err)
+	_, err = NegotiateAnswer(nil, v)
+	assert.ErrorIs(t, err, errNoAnswer)
+}
+
+func TestGet(t *testing.T) {
+	v, err := Get(BrowserFirefox, ScenarioDataChannel)
+	assert.NoError(t, err)
+	assert.Equal(t, "firefox-88/datachannel", v.Name())
+
+	_, err = Get(BrowserSafari, ScenarioSimulcast)
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+// Without the quirks the data section of older Safari has no mid and can't be
+// negotiated. Its bundled media sections without a=rtcp-mux can, only the tagged
+// media section needs it.
+func TestNegotiate_SDPQuirks(t *testing.T) {
+	for scenario, fails := range map[string]bool{ScenarioAudioVideo: false, ScenarioDataChannel: true} {
+		var v Vector
+		for _, vector := range All() {
+			if vector.Browser == BrowserSafari && vector.Version == "12" && vector.Scenario == scenario {
+				v = vector
+			}
+		}
+
+		t.Run(v.Name(), func(t *testing.T) {
+			s := webrtc.SettingEngine{}
+			s.SetSDPQuirksMode(webrtc.SDPQuirksModeNever)
+			pc, err := webrtc.NewAPI(webrtc.WithSettingEngine(s)).NewPeerConnection(webrtc.Configuration{})
+			assert.NoError(t, err)
+
+			_, err = Negotiate(pc, v)
+			assert.Equal(t, fails, err != nil, err)
+
+			assert.NoError(t, pc.Close())
+		})
+	}
+}
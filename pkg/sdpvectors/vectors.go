@@ -0,0 +1,935 @@
+// +build !js
+
+package sdpvectors
+
+// The offers follow the ones created by the browser versions of their Vector,
+// with fixed ICE credentials, fingerprints and SSRCs. Candidates are left out
+// as they are trickled.
+//
+// The answers are the ones the browsers create for the offer of a PeerConnection
+// with the default codecs, with the transceivers or the DataChannel of the scenario.
+
+const chromeAudioVideo = `v=0
+o=- 4215775240449105457 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=extmap-allow-mixed
+a=msid-semantic: WMS a9517af3-1e71-41cb-a324-9a9a106322ce
+m=audio 9 UDP/TLS/RTP/SAVPF 111 103 104 9 0 8 106 105 13 110 112 113 126
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:+Fur
+a=ice-pwd:HT36QLBHfZyvcvTMO6fBUOzT
+a=ice-options:trickle
+a=fingerprint:sha-256 C3:D0:60:95:9A:DC:8D:03:2C:59:AE:E7:C0:53:11:84:F7:DB:89:95:BF:09:61:D6:E2:17:15:02:70:FD:D6:3D
+a=setup:actpass
+a=mid:0
+a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level
+a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:3 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=extmap:5 urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id
+a=extmap:6 urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id
+a=sendrecv
+a=msid:a9517af3-1e71-41cb-a324-9a9a106322ce 8bb9d24d-b2e7-41e7-827c-584007835fde
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=rtcp-fb:111 transport-cc
+a=fmtp:111 minptime=10;useinbandfec=1
+a=rtpmap:103 ISAC/16000
+a=rtpmap:104 ISAC/32000
+a=rtpmap:9 G722/8000
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=rtpmap:106 CN/32000
+a=rtpmap:105 CN/16000
+a=rtpmap:13 CN/8000
+a=rtpmap:110 telephone-event/48000
+a=rtpmap:112 telephone-event/32000
+a=rtpmap:113 telephone-event/16000
+a=rtpmap:126 telephone-event/8000
+a=ssrc:1811596352 cname:Q4X1d3ljn7qNs1Hd
+a=ssrc:1811596352 msid:a9517af3-1e71-41cb-a324-9a9a106322ce 8bb9d24d-b2e7-41e7-827c-584007835fde
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99 100 101 102 121 127 120 125 107 108 109 124 119 123 118 114 115 116
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:+Fur
+a=ice-pwd:HT36QLBHfZyvcvTMO6fBUOzT
+a=ice-options:trickle
+a=fingerprint:sha-256 C3:D0:60:95:9A:DC:8D:03:2C:59:AE:E7:C0:53:11:84:F7:DB:89:95:BF:09:61:D6:E2:17:15:02:70:FD:D6:3D
+a=setup:actpass
+a=mid:1
+a=extmap:14 urn:ietf:params:rtp-hdrext:toffset
+a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:13 urn:3gpp:video-orientation
+a=extmap:3 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=extmap:12 http://www.webrtc.org/experiments/rtp-hdrext/playout-delay
+a=extmap:11 http://www.webrtc.org/experiments/rtp-hdrext/video-content-type
+a=extmap:7 http://www.webrtc.org/experiments/rtp-hdrext/video-timing
+a=extmap:8 http://www.webrtc.org/experiments/rtp-hdrext/color-space
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=extmap:5 urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id
+a=extmap:6 urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id
+a=sendrecv
+a=msid:a9517af3-1e71-41cb-a324-9a9a106322ce a654b178-9a05-468f-8ca5-23bdea2f9532
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 transport-cc
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:98 VP9/90000
+a=rtcp-fb:98 goog-remb
+a=rtcp-fb:98 transport-cc
+a=rtcp-fb:98 ccm fir
+a=rtcp-fb:98 nack
+a=rtcp-fb:98 nack pli
+a=fmtp:98 profile-id=0
+a=rtpmap:99 rtx/90000
+a=fmtp:99 apt=98
+a=rtpmap:100 VP9/90000
+a=rtcp-fb:100 goog-remb
+a=rtcp-fb:100 transport-cc
+a=rtcp-fb:100 ccm fir
+a=rtcp-fb:100 nack
+a=rtcp-fb:100 nack pli
+a=fmtp:100 profile-id=2
+a=rtpmap:101 rtx/90000
+a=fmtp:101 apt=100
+a=rtpmap:102 H264/90000
+a=rtcp-fb:102 goog-remb
+a=rtcp-fb:102 transport-cc
+a=rtcp-fb:102 ccm fir
+a=rtcp-fb:102 nack
+a=rtcp-fb:102 nack pli
+a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f
+a=rtpmap:121 rtx/90000
+a=fmtp:121 apt=102
+a=rtpmap:127 H264/90000
+a=rtcp-fb:127 goog-remb
+a=rtcp-fb:127 transport-cc
+a=rtcp-fb:127 ccm fir
+a=rtcp-fb:127 nack
+a=rtcp-fb:127 nack pli
+a=fmtp:127 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42001f
+a=rtpmap:120 rtx/90000
+a=fmtp:120 apt=127
+a=rtpmap:125 H264/90000
+a=rtcp-fb:125 goog-remb
+a=rtcp-fb:125 transport-cc
+a=rtcp-fb:125 ccm fir
+a=rtcp-fb:125 nack
+a=rtcp-fb:125 nack pli
+a=fmtp:125 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f
+a=rtpmap:107 rtx/90000
+a=fmtp:107 apt=125
+a=rtpmap:108 H264/90000
+a=rtcp-fb:108 goog-remb
+a=rtcp-fb:108 transport-cc
+a=rtcp-fb:108 ccm fir
+a=rtcp-fb:108 nack
+a=rtcp-fb:108 nack pli
+a=fmtp:108 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f
+a=rtpmap:109 rtx/90000
+a=fmtp:109 apt=108
+a=rtpmap:124 H264/90000
+a=rtcp-fb:124 goog-remb
+a=rtcp-fb:124 transport-cc
+a=rtcp-fb:124 ccm fir
+a=rtcp-fb:124 nack
+a=rtcp-fb:124 nack pli
+a=fmtp:124 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f
+a=rtpmap:119 rtx/90000
+a=fmtp:119 apt=124
+a=rtpmap:123 H264/90000
+a=rtcp-fb:123 goog-remb
+a=rtcp-fb:123 transport-cc
+a=rtcp-fb:123 ccm fir
+a=rtcp-fb:123 nack
+a=rtcp-fb:123 nack pli
+a=fmtp:123 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=64001f
+a=rtpmap:118 rtx/90000
+a=fmtp:118 apt=123
+a=rtpmap:114 red/90000
+a=rtpmap:115 rtx/90000
+a=fmtp:115 apt=114
+a=rtpmap:116 ulpfec/90000
+a=ssrc-group:FID 2340375425 3390574412
+a=ssrc:2340375425 cname:Q4X1d3ljn7qNs1Hd
+a=ssrc:2340375425 msid:a9517af3-1e71-41cb-a324-9a9a106322ce a654b178-9a05-468f-8ca5-23bdea2f9532
+a=ssrc:3390574412 cname:Q4X1d3ljn7qNs1Hd
+a=ssrc:3390574412 msid:a9517af3-1e71-41cb-a324-9a9a106322ce a654b178-9a05-468f-8ca5-23bdea2f9532
+`
+
+const chromeSimulcast = `v=0
+o=- 6953367349585463183 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0
+a=extmap-allow-mixed
+a=msid-semantic: WMS 268f55da-5681-43c9-92be-87dc13d97853
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:G4Kk
+a=ice-pwd:d/2x2GqpcOHeP0OdBzlqQt+A
+a=ice-options:trickle
+a=fingerprint:sha-256 E1:CF:E4:1D:14:F4:DF:3C:16:81:C3:96:B2:35:6C:65:30:BB:EA:84:D2:EE:27:3F:88:B3:D0:11:B5:0A:29:43
+a=setup:actpass
+a=mid:0
+a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:3 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=extmap:5 urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id
+a=extmap:6 urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id
+a=sendonly
+a=msid:268f55da-5681-43c9-92be-87dc13d97853 7699ea3e-b930-48c8-984d-08ebda4b63b9
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 transport-cc
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:98 VP9/90000
+a=rtcp-fb:98 goog-remb
+a=rtcp-fb:98 transport-cc
+a=rtcp-fb:98 ccm fir
+a=rtcp-fb:98 nack
+a=rtcp-fb:98 nack pli
+a=fmtp:98 profile-id=0
+a=rtpmap:99 rtx/90000
+a=fmtp:99 apt=98
+a=rid:q send
+a=rid:h send
+a=rid:f send
+a=simulcast:send q;h;f
+`
+
+const chromeDataChannel = `v=0
+o=- 3166240823316187163 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0
+a=extmap-allow-mixed
+a=msid-semantic: WMS
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=ice-ufrag:A7EE
+a=ice-pwd:hyfPoTcaprXsbmi0IOHAWiad
+a=ice-options:trickle
+a=fingerprint:sha-256 7F:07:22:D9:7B:3A:E1:2C:82:62:FF:4B:91:4E:A2:75:B1:9A:1D:B8:91:D3:B1:D6:F1:0F:CE:B3:A3:7C:A3:84
+a=setup:actpass
+a=mid:0
+a=sctp-port:5000
+a=max-message-size:262144
+`
+
+const firefoxAudioVideo = `v=0
+o=mozilla...THIS_IS_SDPARTA-88.0 7186298717253373491 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+a=fingerprint:sha-256 B3:35:72:CB:8C:4A:45:49:84:71:BD:56:80:B4:95:88:9A:D2:D5:92:4A:90:44:D0:FB:DF:49:FE:3E:4A:CF:B7
+a=group:BUNDLE 0 1
+a=ice-options:trickle
+a=msid-semantic:WMS *
+m=audio 9 UDP/TLS/RTP/SAVPF 109 9 0 8 101
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level
+a=extmap:2/recvonly urn:ietf:params:rtp-hdrext:csrc-audio-level
+a=extmap:3 urn:ietf:params:rtp-hdrext:sdes:mid
+a=fmtp:109 maxplaybackrate=48000;stereo=1;useinbandfec=1
+a=fmtp:101 0-15
+a=ice-pwd:VKZskS6QLQ4jdJV1A/QPIq4W
+a=ice-ufrag:hSEj
+a=mid:0
+a=msid:{a9517af3-1e71-41cb-a324-9a9a106322ce} {8bb9d24d-b2e7-41e7-827c-584007835fde}
+a=rtcp-mux
+a=rtpmap:109 opus/48000/2
+a=rtpmap:9 G722/8000/1
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=rtpmap:101 telephone-event/8000
+a=setup:actpass
+a=ssrc:3679208232 cname:{d7ca21c1-40dc-4f3d-af32-68302ec0b576}
+m=video 9 UDP/TLS/RTP/SAVPF 120 124 121 125 126 127 97 98
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=extmap:3 urn:ietf:params:rtp-hdrext:sdes:mid
+a=extmap:4 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:5 urn:ietf:params:rtp-hdrext:toffset
+a=extmap:6/recvonly http://www.webrtc.org/experiments/rtp-hdrext/playout-delay
+a=extmap:7 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=fmtp:126 profile-level-id=42e01f;level-asymmetry-allowed=1;packetization-mode=1
+a=fmtp:97 profile-level-id=42e01f;level-asymmetry-allowed=1
+a=fmtp:120 max-fs=12288;max-fr=60
+a=fmtp:124 apt=120
+a=fmtp:121 max-fs=12288;max-fr=60
+a=fmtp:125 apt=121
+a=fmtp:127 apt=126
+a=fmtp:98 apt=97
+a=ice-pwd:VKZskS6QLQ4jdJV1A/QPIq4W
+a=ice-ufrag:hSEj
+a=mid:1
+a=msid:{a9517af3-1e71-41cb-a324-9a9a106322ce} {a654b178-9a05-468f-8ca5-23bdea2f9532}
+a=rtcp-fb:120 nack
+a=rtcp-fb:120 nack pli
+a=rtcp-fb:120 ccm fir
+a=rtcp-fb:120 goog-remb
+a=rtcp-fb:120 transport-cc
+a=rtcp-fb:121 nack
+a=rtcp-fb:121 nack pli
+a=rtcp-fb:121 ccm fir
+a=rtcp-fb:121 goog-remb
+a=rtcp-fb:121 transport-cc
+a=rtcp-fb:126 nack
+a=rtcp-fb:126 nack pli
+a=rtcp-fb:126 ccm fir
+a=rtcp-fb:126 goog-remb
+a=rtcp-fb:126 transport-cc
+a=rtcp-fb:97 nack
+a=rtcp-fb:97 nack pli
+a=rtcp-fb:97 ccm fir
+a=rtcp-fb:97 goog-remb
+a=rtcp-fb:97 transport-cc
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:120 VP8/90000
+a=rtpmap:124 rtx/90000
+a=rtpmap:121 VP9/90000
+a=rtpmap:125 rtx/90000
+a=rtpmap:126 H264/90000
+a=rtpmap:127 rtx/90000
+a=rtpmap:97 H264/90000
+a=rtpmap:98 rtx/90000
+a=setup:actpass
+a=ssrc:1328401739 cname:{d7ca21c1-40dc-4f3d-af32-68302ec0b576}
+a=ssrc:2216519543 cname:{d7ca21c1-40dc-4f3d-af32-68302ec0b576}
+a=ssrc-group:FID 1328401739 2216519543
+`
+
+const firefoxSimulcast = `v=0
+o=mozilla...THIS_IS_SDPARTA-88.0 4603518290714627355 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+a=fingerprint:sha-256 1F:A7:5C:30:E9:82:4D:B6:07:C1:6A:F8:93:2E:D5:48:BC:71:0F:E4:39:A6:5B:D2:80:17:C9:4E:F3:6A:2D:95
+a=group:BUNDLE 0
+a=ice-options:trickle
+a=msid-semantic:WMS *
+m=video 9 UDP/TLS/RTP/SAVPF 120 124 121 125 126 127 97 98
+c=IN IP4 0.0.0.0
+a=sendonly
+a=extmap:3 urn:ietf:params:rtp-hdrext:sdes:mid
+a=extmap:4 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:5 urn:ietf:params:rtp-hdrext:toffset
+a=extmap:6/recvonly http://www.webrtc.org/experiments/rtp-hdrext/playout-delay
+a=extmap:7 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=extmap:8 urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id
+a=extmap:9 urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id
+a=fmtp:126 profile-level-id=42e01f;level-asymmetry-allowed=1;packetization-mode=1
+a=fmtp:97 profile-level-id=42e01f;level-asymmetry-allowed=1
+a=fmtp:120 max-fs=12288;max-fr=60
+a=fmtp:124 apt=120
+a=fmtp:121 max-fs=12288;max-fr=60
+a=fmtp:125 apt=121
+a=fmtp:127 apt=126
+a=fmtp:98 apt=97
+a=ice-pwd:3c7f02b9e6d14a58c0b27e91
+a=ice-ufrag:f5a1c83d
+a=mid:0
+a=msid:{0b7e5a21-c93f-4d68-82a4-e6f1d09c3b57} {59c2f7e8-1a4b-4d03-b6e9-c8a25f1d7034}
+a=rid:q send
+a=rid:h send
+a=rid:f send
+a=rtcp-fb:120 nack
+a=rtcp-fb:120 nack pli
+a=rtcp-fb:120 ccm fir
+a=rtcp-fb:120 goog-remb
+a=rtcp-fb:120 transport-cc
+a=rtcp-fb:121 nack
+a=rtcp-fb:121 nack pli
+a=rtcp-fb:121 ccm fir
+a=rtcp-fb:121 goog-remb
+a=rtcp-fb:121 transport-cc
+a=rtcp-fb:126 nack
+a=rtcp-fb:126 nack pli
+a=rtcp-fb:126 ccm fir
+a=rtcp-fb:126 goog-remb
+a=rtcp-fb:126 transport-cc
+a=rtcp-fb:97 nack
+a=rtcp-fb:97 nack pli
+a=rtcp-fb:97 ccm fir
+a=rtcp-fb:97 goog-remb
+a=rtcp-fb:97 transport-cc
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:120 VP8/90000
+a=rtpmap:124 rtx/90000
+a=rtpmap:121 VP9/90000
+a=rtpmap:125 rtx/90000
+a=rtpmap:126 H264/90000
+a=rtpmap:127 rtx/90000
+a=rtpmap:97 H264/90000
+a=rtpmap:98 rtx/90000
+a=setup:actpass
+a=simulcast:send q;h;f
+a=ssrc:2645193788 cname:{e4b09c27-5d31-4a8f-9b62-07f3c1d8a5e9}
+a=ssrc:1032874659 cname:{e4b09c27-5d31-4a8f-9b62-07f3c1d8a5e9}
+a=ssrc:3817460295 cname:{e4b09c27-5d31-4a8f-9b62-07f3c1d8a5e9}
+a=ssrc:590328147 cname:{e4b09c27-5d31-4a8f-9b62-07f3c1d8a5e9}
+a=ssrc:2206917583 cname:{e4b09c27-5d31-4a8f-9b62-07f3c1d8a5e9}
+a=ssrc:4071539826 cname:{e4b09c27-5d31-4a8f-9b62-07f3c1d8a5e9}
+a=ssrc-group:FID 2645193788 590328147
+a=ssrc-group:FID 1032874659 2206917583
+a=ssrc-group:FID 3817460295 4071539826
+`
+
+const firefoxDataChannel = `v=0
+o=mozilla...THIS_IS_SDPARTA-88.0 2894170640462093520 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+a=fingerprint:sha-256 DD:6B:2A:44:B1:9B:94:07:1D:2A:5D:1F:42:CE:5C:00:A8:24:05:20:60:33:03:30:C2:2C:91:1C:B1:65:07:61
+a=group:BUNDLE 0
+a=ice-options:trickle
+a=msid-semantic:WMS *
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=ice-pwd:nsKC0/XQss9x/vItDXoe5K72
+a=ice-ufrag:u3JM
+a=mid:0
+a=setup:actpass
+a=sctp-port:5000
+a=max-message-size:1073741823
+`
+
+const safariAudioVideo = `v=0
+o=- 8546711404961373478 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=extmap-allow-mixed
+a=msid-semantic: WMS 268f55da-5681-43c9-92be-87dc13d97853
+m=audio 9 UDP/TLS/RTP/SAVPF 111 103 9 102 0 8 105 13 110 113 126
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:ONcF
+a=ice-pwd:94IUlWYAwVXm6M+Pd0BBnvAQ
+a=ice-options:trickle
+a=fingerprint:sha-256 AF:8D:8E:16:54:16:FE:92:48:CF:F0:23:65:5E:B9:BB:A2:CD:2C:6F:9B:F7:5A:99:F0:36:38:65:BA:5F:89:59
+a=setup:actpass
+a=mid:0
+a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level
+a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:3 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=sendrecv
+a=msid:268f55da-5681-43c9-92be-87dc13d97853 7699ea3e-b930-48c8-984d-08ebda4b63b9
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=rtcp-fb:111 transport-cc
+a=fmtp:111 minptime=10;useinbandfec=1
+a=rtpmap:103 ISAC/16000
+a=rtpmap:9 G722/8000
+a=rtpmap:102 ILBC/8000
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=rtpmap:105 CN/16000
+a=rtpmap:13 CN/8000
+a=rtpmap:110 telephone-event/48000
+a=rtpmap:113 telephone-event/16000
+a=rtpmap:126 telephone-event/8000
+a=ssrc:3156296581 cname:xs7pH4eb6lCoXDHZ
+a=ssrc:3156296581 msid:268f55da-5681-43c9-92be-87dc13d97853 7699ea3e-b930-48c8-984d-08ebda4b63b9
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99 100 101 127 125 104
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:ONcF
+a=ice-pwd:94IUlWYAwVXm6M+Pd0BBnvAQ
+a=ice-options:trickle
+a=fingerprint:sha-256 AF:8D:8E:16:54:16:FE:92:48:CF:F0:23:65:5E:B9:BB:A2:CD:2C:6F:9B:F7:5A:99:F0:36:38:65:BA:5F:89:59
+a=setup:actpass
+a=mid:1
+a=extmap:14 urn:ietf:params:rtp-hdrext:toffset
+a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=extmap:13 urn:3gpp:video-orientation
+a=extmap:3 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01
+a=extmap:12 http://www.webrtc.org/experiments/rtp-hdrext/playout-delay
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=sendrecv
+a=msid:268f55da-5681-43c9-92be-87dc13d97853 d7ca21c1-40dc-4f3d-af32-68302ec0b576
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 H264/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 transport-cc
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=fmtp:96 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640c1f
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:98 H264/90000
+a=rtcp-fb:98 goog-remb
+a=rtcp-fb:98 transport-cc
+a=rtcp-fb:98 ccm fir
+a=rtcp-fb:98 nack
+a=rtcp-fb:98 nack pli
+a=fmtp:98 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f
+a=rtpmap:99 rtx/90000
+a=fmtp:99 apt=98
+a=rtpmap:100 VP8/90000
+a=rtcp-fb:100 goog-remb
+a=rtcp-fb:100 transport-cc
+a=rtcp-fb:100 ccm fir
+a=rtcp-fb:100 nack
+a=rtcp-fb:100 nack pli
+a=rtpmap:101 rtx/90000
+a=fmtp:101 apt=100
+a=rtpmap:127 red/90000
+a=rtpmap:125 rtx/90000
+a=fmtp:125 apt=127
+a=rtpmap:104 ulpfec/90000
+a=ssrc-group:FID 1409468537 2954781342
+a=ssrc:1409468537 cname:xs7pH4eb6lCoXDHZ
+a=ssrc:1409468537 msid:268f55da-5681-43c9-92be-87dc13d97853 d7ca21c1-40dc-4f3d-af32-68302ec0b576
+a=ssrc:2954781342 cname:xs7pH4eb6lCoXDHZ
+a=ssrc:2954781342 msid:268f55da-5681-43c9-92be-87dc13d97853 d7ca21c1-40dc-4f3d-af32-68302ec0b576
+`
+
+const safariDataChannel = `v=0
+o=- 5308287339284739911 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0
+a=extmap-allow-mixed
+a=msid-semantic: WMS
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=ice-ufrag:ONcF
+a=ice-pwd:94IUlWYAwVXm6M+Pd0BBnvAQ
+a=ice-options:trickle
+a=fingerprint:sha-256 AF:8D:8E:16:54:16:FE:92:48:CF:F0:23:65:5E:B9:BB:A2:CD:2C:6F:9B:F7:5A:99:F0:36:38:65:BA:5F:89:59
+a=setup:actpass
+a=mid:0
+a=sctp-port:5000
+a=max-message-size:262144
+`
+
+// The offers of older Safari have quirks: msids without a track id, bundled media
+// sections without a=rtcp-mux and a data section without a mid
+const safariLegacyAudioVideo = `v=0
+o=- 6229813342891226114 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=msid-semantic: WMS 2d8ea0e4-3b4c-4a52-a0c6-56f2c34c2fd5
+m=audio 9 UDP/TLS/RTP/SAVPF 111 103 9 0 8 105 13 110 126
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:k3Vd
+a=ice-pwd:Gv0KqA4Q8g3t0Z3Dmo6J1nqP
+a=ice-options:trickle
+a=fingerprint:sha-256 0B:7C:39:2E:21:62:AF:9A:69:5F:B6:1E:EC:53:28:8C:5E:55:73:E0:87:93:05:8A:7D:6E:9E:6B:2C:C5:8E:31
+a=setup:actpass
+a=mid:0
+a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level
+a=sendrecv
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=fmtp:111 minptime=10;useinbandfec=1
+a=rtpmap:103 ISAC/16000
+a=rtpmap:9 G722/8000
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=rtpmap:105 CN/16000
+a=rtpmap:13 CN/8000
+a=rtpmap:110 telephone-event/48000
+a=rtpmap:126 telephone-event/8000
+a=ssrc:2087623479 cname:fQ1pX9N7CwY2a8bV
+a=ssrc:2087623479 msid:2d8ea0e4-3b4c-4a52-a0c6-56f2c34c2fd5
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:k3Vd
+a=ice-pwd:Gv0KqA4Q8g3t0Z3Dmo6J1nqP
+a=ice-options:trickle
+a=fingerprint:sha-256 0B:7C:39:2E:21:62:AF:9A:69:5F:B6:1E:EC:53:28:8C:5E:55:73:E0:87:93:05:8A:7D:6E:9E:6B:2C:C5:8E:31
+a=setup:actpass
+a=mid:1
+a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+a=sendrecv
+a=msid:2d8ea0e4-3b4c-4a52-a0c6-56f2c34c2fd5
+a=rtcp-rsize
+a=rtpmap:96 H264/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=fmtp:96 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:98 VP8/90000
+a=rtcp-fb:98 goog-remb
+a=rtcp-fb:98 ccm fir
+a=rtcp-fb:98 nack
+a=rtcp-fb:98 nack pli
+a=rtpmap:99 rtx/90000
+a=fmtp:99 apt=98
+a=ssrc-group:FID 3820487211 1249620451
+a=ssrc:3820487211 cname:fQ1pX9N7CwY2a8bV
+a=ssrc:3820487211 msid:2d8ea0e4-3b4c-4a52-a0c6-56f2c34c2fd5
+a=ssrc:1249620451 cname:fQ1pX9N7CwY2a8bV
+a=ssrc:1249620451 msid:2d8ea0e4-3b4c-4a52-a0c6-56f2c34c2fd5
+`
+
+const safariLegacyDataChannel = `v=0
+o=- 3920117784261034771 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=msid-semantic: WMS
+m=application 9 DTLS/SCTP 5000
+c=IN IP4 0.0.0.0
+a=ice-ufrag:k3Vd
+a=ice-pwd:Gv0KqA4Q8g3t0Z3Dmo6J1nqP
+a=ice-options:trickle
+a=fingerprint:sha-256 0B:7C:39:2E:21:62:AF:9A:69:5F:B6:1E:EC:53:28:8C:5E:55:73:E0:87:93:05:8A:7D:6E:9E:6B:2C:C5:8E:31
+a=setup:actpass
+a=sctpmap:5000 webrtc-datachannel 1024
+`
+
+const chromeAudioVideoAnswer = `v=0
+o=- 2710985547217371309 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=msid-semantic: WMS 5e8a11d4-2f3b-4c69-9a07-b1d3e6f4a2c8
+m=audio 9 UDP/TLS/RTP/SAVPF 111 9 0 8
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:rZ3q
+a=ice-pwd:kT9vZg2mYfL0dQ7xN4pWs1bE
+a=ice-options:trickle
+a=fingerprint:sha-256 3B:9A:4E:11:0D:6C:58:E2:97:1F:A4:20:8C:5D:E3:71:46:B8:0A:F5:29:CE:63:17:D0:82:4B:9E:F1:35:7A:C6
+a=setup:active
+a=mid:0
+a=sendrecv
+a=msid:5e8a11d4-2f3b-4c69-9a07-b1d3e6f4a2c8 0c4f2a9e-71b6-4d35-8e0a-f93b27d15c64
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=fmtp:111 minptime=10;useinbandfec=1
+a=rtpmap:9 G722/8000
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=ssrc:1725936408 cname:hW8rT2kXq5zN0cVb
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99 102 121 127 120 125 107 108 109 123 118
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:rZ3q
+a=ice-pwd:kT9vZg2mYfL0dQ7xN4pWs1bE
+a=ice-options:trickle
+a=fingerprint:sha-256 3B:9A:4E:11:0D:6C:58:E2:97:1F:A4:20:8C:5D:E3:71:46:B8:0A:F5:29:CE:63:17:D0:82:4B:9E:F1:35:7A:C6
+a=setup:active
+a=mid:1
+a=sendrecv
+a=msid:5e8a11d4-2f3b-4c69-9a07-b1d3e6f4a2c8 d8e35b71-4a02-4f9c-b6d1-2c7e90a4f38b
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:98 VP9/90000
+a=rtcp-fb:98 goog-remb
+a=rtcp-fb:98 ccm fir
+a=rtcp-fb:98 nack
+a=rtcp-fb:98 nack pli
+a=fmtp:98 profile-id=0
+a=rtpmap:99 rtx/90000
+a=fmtp:99 apt=98
+a=rtpmap:102 H264/90000
+a=rtcp-fb:102 goog-remb
+a=rtcp-fb:102 ccm fir
+a=rtcp-fb:102 nack
+a=rtcp-fb:102 nack pli
+a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f
+a=rtpmap:121 rtx/90000
+a=fmtp:121 apt=102
+a=rtpmap:127 H264/90000
+a=rtcp-fb:127 goog-remb
+a=rtcp-fb:127 ccm fir
+a=rtcp-fb:127 nack
+a=rtcp-fb:127 nack pli
+a=fmtp:127 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42001f
+a=rtpmap:120 rtx/90000
+a=fmtp:120 apt=127
+a=rtpmap:125 H264/90000
+a=rtcp-fb:125 goog-remb
+a=rtcp-fb:125 ccm fir
+a=rtcp-fb:125 nack
+a=rtcp-fb:125 nack pli
+a=fmtp:125 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f
+a=rtpmap:107 rtx/90000
+a=fmtp:107 apt=125
+a=rtpmap:108 H264/90000
+a=rtcp-fb:108 goog-remb
+a=rtcp-fb:108 ccm fir
+a=rtcp-fb:108 nack
+a=rtcp-fb:108 nack pli
+a=fmtp:108 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f
+a=rtpmap:109 rtx/90000
+a=fmtp:109 apt=108
+a=rtpmap:123 H264/90000
+a=rtcp-fb:123 goog-remb
+a=rtcp-fb:123 ccm fir
+a=rtcp-fb:123 nack
+a=rtcp-fb:123 nack pli
+a=fmtp:123 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032
+a=rtpmap:118 rtx/90000
+a=fmtp:118 apt=123
+a=ssrc-group:FID 3081465927 2419073586
+a=ssrc:3081465927 cname:hW8rT2kXq5zN0cVb
+a=ssrc:2419073586 cname:hW8rT2kXq5zN0cVb
+`
+
+const chromeDataChannelAnswer = `v=0
+o=- 6482910375126638402 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0
+a=msid-semantic: WMS
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=ice-ufrag:Jx7d
+a=ice-pwd:Qm3HfV9sLc2Tz0WpR5yNk8aG
+a=ice-options:trickle
+a=fingerprint:sha-256 3B:9A:4E:11:0D:6C:58:E2:97:1F:A4:20:8C:5D:E3:71:46:B8:0A:F5:29:CE:63:17:D0:82:4B:9E:F1:35:7A:C6
+a=setup:active
+a=mid:0
+a=sctp-port:5000
+a=max-message-size:262144
+`
+
+const firefoxAudioVideoAnswer = `v=0
+o=mozilla...THIS_IS_SDPARTA-88.0 5831766240915286404 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+a=fingerprint:sha-256 6D:E2:38:A1:5F:0C:94:7B:D3:21:8E:46:F9:BA:02:C7:5D:13:E8:60:A4:9F:37:1B:C5:82:0E:F6:49:DD:73:A8
+a=group:BUNDLE 0 1
+a=ice-options:trickle
+a=msid-semantic:WMS *
+m=audio 9 UDP/TLS/RTP/SAVPF 111 9 0 8
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=fmtp:111 maxplaybackrate=48000;stereo=1;useinbandfec=1
+a=ice-pwd:c6a0f1e2b9d84a3f7e5c2d1b
+a=ice-ufrag:9b3e27c1
+a=mid:0
+a=msid:{3f6c9e42-18ab-4d07-95e1-c2b7a4d80f63} {b1e05d97-6c2a-4f38-a9d4-17e8c3f52b06}
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=rtpmap:9 G722/8000/1
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=setup:active
+a=ssrc:2871034596 cname:{7e2d41b8-93c5-4a6f-b0e8-5d19c3f27a4e}
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 98 99 102 121 127 120 125 107 108 109
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=fmtp:97 apt=96
+a=fmtp:98 profile-id=0
+a=fmtp:99 apt=98
+a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f
+a=fmtp:121 apt=102
+a=fmtp:127 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42001f
+a=fmtp:120 apt=127
+a=fmtp:125 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f
+a=fmtp:107 apt=125
+a=fmtp:108 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f
+a=fmtp:109 apt=108
+a=ice-pwd:c6a0f1e2b9d84a3f7e5c2d1b
+a=ice-ufrag:9b3e27c1
+a=mid:1
+a=msid:{3f6c9e42-18ab-4d07-95e1-c2b7a4d80f63} {4a8f2c61-d07e-49b3-8e15-a6c93f0b72d4}
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:98 nack
+a=rtcp-fb:98 nack pli
+a=rtcp-fb:98 ccm fir
+a=rtcp-fb:98 goog-remb
+a=rtcp-fb:102 nack
+a=rtcp-fb:102 nack pli
+a=rtcp-fb:102 ccm fir
+a=rtcp-fb:102 goog-remb
+a=rtcp-fb:127 nack
+a=rtcp-fb:127 nack pli
+a=rtcp-fb:127 ccm fir
+a=rtcp-fb:127 goog-remb
+a=rtcp-fb:125 nack
+a=rtcp-fb:125 nack pli
+a=rtcp-fb:125 ccm fir
+a=rtcp-fb:125 goog-remb
+a=rtcp-fb:108 nack
+a=rtcp-fb:108 nack pli
+a=rtcp-fb:108 ccm fir
+a=rtcp-fb:108 goog-remb
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 VP8/90000
+a=rtpmap:97 rtx/90000
+a=rtpmap:98 VP9/90000
+a=rtpmap:99 rtx/90000
+a=rtpmap:102 H264/90000
+a=rtpmap:121 rtx/90000
+a=rtpmap:127 H264/90000
+a=rtpmap:120 rtx/90000
+a=rtpmap:125 H264/90000
+a=rtpmap:107 rtx/90000
+a=rtpmap:108 H264/90000
+a=rtpmap:109 rtx/90000
+a=setup:active
+a=ssrc:1596203847 cname:{7e2d41b8-93c5-4a6f-b0e8-5d19c3f27a4e}
+a=ssrc:3340718265 cname:{7e2d41b8-93c5-4a6f-b0e8-5d19c3f27a4e}
+a=ssrc-group:FID 1596203847 3340718265
+`
+
+const firefoxDataChannelAnswer = `v=0
+o=mozilla...THIS_IS_SDPARTA-88.0 1940283756612093847 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+a=fingerprint:sha-256 6D:E2:38:A1:5F:0C:94:7B:D3:21:8E:46:F9:BA:02:C7:5D:13:E8:60:A4:9F:37:1B:C5:82:0E:F6:49:DD:73:A8
+a=group:BUNDLE 0
+a=ice-options:trickle
+a=msid-semantic:WMS *
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=ice-pwd:5e91b7c3d2a04f86e1c9b3a7
+a=ice-ufrag:2d84f0a6
+a=mid:0
+a=setup:active
+a=sctp-port:5000
+a=max-message-size:1073741823
+`
+
+const safariAudioVideoAnswer = `v=0
+o=- 3962157408823194750 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=extmap-allow-mixed
+a=msid-semantic: WMS 9c2e47b1-5a3d-4f86-b0e9-d71f3a6c25e8
+m=audio 9 UDP/TLS/RTP/SAVPF 111 9 0 8
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:Vp4w
+a=ice-pwd:Ht6bE1nQz8Ks3LmXa0RcY7uJ
+a=ice-options:trickle
+a=fingerprint:sha-256 84:1C:E9:52:07:AB:3F:D6:60:29:B5:C8:1E:74:F3:0A:9D:42:6B:E7:15:80:CC:39:A2:5E:D1:06:7F:B4:28:93
+a=setup:active
+a=mid:0
+a=sendrecv
+a=msid:9c2e47b1-5a3d-4f86-b0e9-d71f3a6c25e8 61d8b3f0-2c74-4e9a-a5b1-83e0f6d94c27
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=fmtp:111 minptime=10;useinbandfec=1
+a=rtpmap:9 G722/8000
+a=rtpmap:0 PCMU/8000
+a=rtpmap:8 PCMA/8000
+a=ssrc:2093748165 cname:pL4mW9xRk2sTq7vB
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 102 121 127 120 125 107 108 109 123 118
+c=IN IP4 0.0.0.0
+a=rtcp:9 IN IP4 0.0.0.0
+a=ice-ufrag:Vp4w
+a=ice-pwd:Ht6bE1nQz8Ks3LmXa0RcY7uJ
+a=ice-options:trickle
+a=fingerprint:sha-256 84:1C:E9:52:07:AB:3F:D6:60:29:B5:C8:1E:74:F3:0A:9D:42:6B:E7:15:80:CC:39:A2:5E:D1:06:7F:B4:28:93
+a=setup:active
+a=mid:1
+a=sendrecv
+a=msid:9c2e47b1-5a3d-4f86-b0e9-d71f3a6c25e8 e3a05c92-7d1b-46f8-9c3e-b4f2a18d607c
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:102 H264/90000
+a=rtcp-fb:102 goog-remb
+a=rtcp-fb:102 ccm fir
+a=rtcp-fb:102 nack
+a=rtcp-fb:102 nack pli
+a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f
+a=rtpmap:121 rtx/90000
+a=fmtp:121 apt=102
+a=rtpmap:127 H264/90000
+a=rtcp-fb:127 goog-remb
+a=rtcp-fb:127 ccm fir
+a=rtcp-fb:127 nack
+a=rtcp-fb:127 nack pli
+a=fmtp:127 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42001f
+a=rtpmap:120 rtx/90000
+a=fmtp:120 apt=127
+a=rtpmap:125 H264/90000
+a=rtcp-fb:125 goog-remb
+a=rtcp-fb:125 ccm fir
+a=rtcp-fb:125 nack
+a=rtcp-fb:125 nack pli
+a=fmtp:125 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f
+a=rtpmap:107 rtx/90000
+a=fmtp:107 apt=125
+a=rtpmap:108 H264/90000
+a=rtcp-fb:108 goog-remb
+a=rtcp-fb:108 ccm fir
+a=rtcp-fb:108 nack
+a=rtcp-fb:108 nack pli
+a=fmtp:108 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f
+a=rtpmap:109 rtx/90000
+a=fmtp:109 apt=108
+a=rtpmap:123 H264/90000
+a=rtcp-fb:123 goog-remb
+a=rtcp-fb:123 ccm fir
+a=rtcp-fb:123 nack
+a=rtcp-fb:123 nack pli
+a=fmtp:123 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032
+a=rtpmap:118 rtx/90000
+a=fmtp:118 apt=123
+a=ssrc-group:FID 1184620397 3957201846
+a=ssrc:1184620397 cname:pL4mW9xRk2sTq7vB
+a=ssrc:3957201846 cname:pL4mW9xRk2sTq7vB
+`
+
+const safariDataChannelAnswer = `v=0
+o=- 7215093846402718356 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0
+a=msid-semantic: WMS
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=ice-ufrag:Vp4w
+a=ice-pwd:Ht6bE1nQz8Ks3LmXa0RcY7uJ
+a=ice-options:trickle
+a=fingerprint:sha-256 84:1C:E9:52:07:AB:3F:D6:60:29:B5:C8:1E:74:F3:0A:9D:42:6B:E7:15:80:CC:39:A2:5E:D1:06:7F:B4:28:93
+a=setup:active
+a=mid:0
+a=sctp-port:5000
+a=max-message-size:262144
+`
@@ -0,0 +1,217 @@
+// Package tee forwards the packets of a received track to multiple consumers, e.g. a
+// recorder and the tracks of other PeerConnections. Every consumer has its own Reader
+// with its own buffer, a consumer that doesn't keep up loses its oldest packets
+// instead of stalling the track and the other consumers.
+package tee
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+)
+
+const (
+	receiveMTU = 1460
+
+	// DefaultBufferSize is the number of packets a Reader buffers when New is called without one
+	DefaultBufferSize = 512
+)
+
+// ErrClosed is returned when reading from a Reader that has been closed
+var ErrClosed = errors.New("tee: reader closed")
+
+// TrackReader is the track the packets are read from, it is implemented by webrtc.TrackRemote
+// and by Reader, so the Reader of a Tee can be teed again.
+type TrackReader interface {
+	Read(b []byte) (n int, attributes interceptor.Attributes, err error)
+}
+
+type packet struct {
+	data       []byte
+	attributes interceptor.Attributes
+}
+
+// Tee reads a track and forwards each packet to all of its Readers
+type Tee struct {
+	track      TrackReader
+	bufferSize int
+
+	mu      sync.Mutex
+	readers map[*Reader]struct{}
+	err     error
+}
+
+// New creates a Tee that reads the track until it returns an error, the error is then
+// returned by all Readers once they have read their buffered packets. bufferSize is the
+// number of packets each Reader buffers, DefaultBufferSize is used if it is zero.
+// The track must not be read by anything else.
+func New(track TrackReader, bufferSize int) *Tee {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	t := &Tee{
+		track:      track,
+		bufferSize: bufferSize,
+		readers:    map[*Reader]struct{}{},
+	}
+	go t.readLoop()
+	return t
+}
+
+func (t *Tee) readLoop() {
+	for {
+		// Readers only read the buffer, so one buffer per packet is shared by all of them
+		b := make([]byte, receiveMTU)
+		n, attributes, err := t.track.Read(b)
+
+		t.mu.Lock()
+		if err != nil {
+			t.err = err
+			for r := range t.readers {
+				r.closeWithError(err)
+			}
+			t.readers = nil
+			t.mu.Unlock()
+			return
+		}
+
+		p := packet{data: b[:n], attributes: attributes}
+		for r := range t.readers {
+			r.push(p)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// NewReader creates a Reader that gets every packet read from the track from now on
+func (t *Tee) NewReader() *Reader {
+	r := &Reader{
+		tee:     t,
+		packets: make(chan packet, t.bufferSize),
+		done:    make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		r.closeWithError(t.err)
+	} else {
+		t.readers[r] = struct{}{}
+	}
+	return r
+}
+
+func (t *Tee) removeReader(r *Reader) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.readers, r)
+}
+
+// Reader is one consumer of a Tee with its own read position
+type Reader struct {
+	tee     *Tee
+	packets chan packet
+	dropped uint64
+
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+}
+
+// push is only called by the read loop of the Tee
+func (r *Reader) push(p packet) {
+	select {
+	case r.packets <- p:
+		return
+	default:
+	}
+
+	// The consumer is behind, make room by dropping its oldest packet
+	select {
+	case <-r.packets:
+		atomic.AddUint64(&r.dropped, 1)
+	default:
+	}
+
+	select {
+	case r.packets <- p:
+	default:
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+func (r *Reader) closeWithError(err error) {
+	r.closeOnce.Do(func() {
+		r.err = err
+		close(r.done)
+	})
+}
+
+// Read reads the next packet into b
+func (r *Reader) Read(b []byte) (int, interceptor.Attributes, error) {
+	var p packet
+	select {
+	case p = <-r.packets:
+	case <-r.done:
+		// Packets buffered before the track ended are still delivered
+		select {
+		case p = <-r.packets:
+		default:
+			return 0, nil, r.err
+		}
+	}
+
+	if len(b) < len(p.data) {
+		return 0, nil, io.ErrShortBuffer
+	}
+
+	var attributes interceptor.Attributes
+	if p.attributes != nil {
+		attributes = make(interceptor.Attributes, len(p.attributes))
+		for k, v := range p.attributes {
+			attributes[k] = v
+		}
+	}
+	return copy(b, p.data), attributes, nil
+}
+
+// ReadRTP reads and unmarshals the next packet, the packet belongs to the caller
+func (r *Reader) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	b := make([]byte, receiveMTU)
+	n, attributes, err := r.Read(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkt := &rtp.Packet{}
+	if err := pkt.Unmarshal(b[:n]); err != nil {
+		return nil, nil, err
+	}
+	return pkt, attributes, nil
+}
+
+// Dropped returns the number of packets dropped because the Reader wasn't read fast enough
+func (r *Reader) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// Close stops the Reader, its buffered packets are discarded
+func (r *Reader) Close() error {
+	r.tee.removeReader(r)
+	r.closeWithError(ErrClosed)
+
+	for {
+		select {
+		case <-r.packets:
+		default:
+			return nil
+		}
+	}
+}
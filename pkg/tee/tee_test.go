@@ -0,0 +1,128 @@
+package tee
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// track returns the packets written to it and io.EOF once it is closed
+type track struct {
+	packets chan []byte
+}
+
+func newTrack() *track {
+	return &track{packets: make(chan []byte)}
+}
+
+func (t *track) Read(b []byte) (int, interceptor.Attributes, error) {
+	p, ok := <-t.packets
+	if !ok {
+		return 0, nil, io.EOF
+	}
+	return copy(b, p), nil, nil
+}
+
+func (t *track) write(tb *testing.T, sequenceNumber uint16) {
+	b, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: sequenceNumber}, Payload: []byte{0x00}}).Marshal()
+	assert.NoError(tb, err)
+	t.packets <- b
+}
+
+func readSequenceNumber(t *testing.T, r *Reader) uint16 {
+	pkt, _, err := r.ReadRTP()
+	assert.NoError(t, err)
+	return pkt.SequenceNumber
+}
+
+func TestTee_IndependentReaders(t *testing.T) {
+	src := newTrack()
+	tee := New(src, 0)
+
+	recorder := tee.NewReader()
+	forwarder := tee.NewReader()
+
+	for i := uint16(0); i < 3; i++ {
+		src.write(t, i)
+	}
+
+	// Every reader gets every packet and modifying one doesn't affect the other
+	pkt, _, err := recorder.ReadRTP()
+	assert.NoError(t, err)
+	pkt.SequenceNumber = 1000
+	assert.Equal(t, uint16(0), readSequenceNumber(t, forwarder))
+
+	assert.Equal(t, uint16(1), readSequenceNumber(t, recorder))
+	assert.Equal(t, uint16(2), readSequenceNumber(t, recorder))
+	assert.Equal(t, uint16(1), readSequenceNumber(t, forwarder))
+
+	// A reader only gets the packets read after it was created
+	late := tee.NewReader()
+	src.write(t, 3)
+	assert.Equal(t, uint16(3), readSequenceNumber(t, late))
+
+	close(src.packets)
+
+	// Buffered packets are delivered before the error of the track
+	assert.Equal(t, uint16(2), readSequenceNumber(t, forwarder))
+	assert.Equal(t, uint16(3), readSequenceNumber(t, forwarder))
+	_, _, err = forwarder.ReadRTP()
+	assert.Equal(t, io.EOF, err)
+
+	assert.Equal(t, uint16(3), readSequenceNumber(t, recorder))
+	_, _, err = recorder.ReadRTP()
+	assert.Equal(t, io.EOF, err)
+
+	_, _, err = tee.NewReader().ReadRTP()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestTee_SlowReader(t *testing.T) {
+	src := newTrack()
+	tee := New(src, 2)
+
+	slow := tee.NewReader()
+	fast := tee.NewReader()
+
+	// The slow reader doesn't stall the fast one, it loses its oldest packets
+	for i := uint16(0); i < 6; i++ {
+		src.write(t, i)
+		assert.Equal(t, i, readSequenceNumber(t, fast))
+	}
+
+	// Once the fast reader sees the end all packets have been forwarded
+	close(src.packets)
+	_, _, err := fast.ReadRTP()
+	assert.Equal(t, io.EOF, err)
+
+	assert.Equal(t, uint16(4), readSequenceNumber(t, slow))
+	assert.Equal(t, uint16(5), readSequenceNumber(t, slow))
+	assert.Equal(t, uint64(4), slow.Dropped())
+	assert.Equal(t, uint64(0), fast.Dropped())
+}
+
+func TestTee_Close(t *testing.T) {
+	src := newTrack()
+	tee := New(src, 0)
+
+	closed := tee.NewReader()
+	open := tee.NewReader()
+
+	src.write(t, 0)
+	assert.NoError(t, closed.Close())
+	src.write(t, 1)
+
+	_, _, err := closed.ReadRTP()
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, uint16(0), readSequenceNumber(t, open))
+	assert.Equal(t, uint16(1), readSequenceNumber(t, open))
+
+	src.write(t, 2)
+	_, _, err = open.Read(make([]byte, 1))
+	assert.Equal(t, io.ErrShortBuffer, err)
+
+	close(src.packets)
+}
@@ -0,0 +1,330 @@
+// +build !js
+
+package v2compat
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+const (
+	importPathV2       = "github.com/pion/webrtc/v2"
+	importPathV3       = "github.com/pion/webrtc/v3"
+	importPathMedia    = importPathV3 + "/pkg/media"
+	importPathV2Compat = importPathV3 + "/pkg/v2compat"
+)
+
+// v2Names are the identifiers of the v2 webrtc package that only exist in v2compat
+var v2Names = map[string]bool{
+	"RTPCodec":               true,
+	"NewRTPCodec":            true,
+	"NewRTPOpusCodec":        true,
+	"NewRTPG722Codec":        true,
+	"NewRTPPCMUCodec":        true,
+	"NewRTPPCMACodec":        true,
+	"NewRTPVP8Codec":         true,
+	"NewRTPVP9Codec":         true,
+	"NewRTPH264Codec":        true,
+	"DefaultPayloadTypePCMU": true,
+	"DefaultPayloadTypePCMA": true,
+	"DefaultPayloadTypeG722": true,
+	"DefaultPayloadTypeOpus": true,
+	"DefaultPayloadTypeVP8":  true,
+	"DefaultPayloadTypeVP9":  true,
+	"DefaultPayloadTypeH264": true,
+	"Track":                  true,
+}
+
+// MigrationNote is a use of the v2 API that Migrate couldn't rewrite, it has to be
+// changed by hand
+type MigrationNote struct {
+	Pos     token.Position
+	Message string
+}
+
+func (n MigrationNote) String() string {
+	return fmt.Sprintf("%s: %s", n.Pos, n.Message)
+}
+
+// Migrate rewrites the source of a Go file that uses pion/webrtc v2, so it builds
+// against v3 with the help of this package:
+//   - imports of github.com/pion/webrtc/v2 and its packages are changed to v3
+//   - the codecs, payload types and Track of v2 are taken from this package
+//   - MediaEngine.RegisterCodec with a v2 codec becomes RegisterCodec, and a MediaEngine
+//     declared as a value is passed to WithMediaEngine by pointer
+//   - PeerConnection.NewTrack becomes NewTrack, the track is added with AddTrack as before
+//   - PeerConnection.OnTrack with a v2 Track becomes OnTrack with a RemoteTrack
+//   - media.Sample with a number of Samples becomes Sample
+//
+// The uses that can't be rewritten without type information are returned as notes,
+// e.g. PeerConnection.AddTransceiver, which was split into AddTransceiverFromKind and
+// AddTransceiverFromTrack. The source is returned unchanged if it doesn't import v2.
+// The result still uses deprecated API, the deprecation notices of this package
+// describe the next step of the migration.
+func Migrate(filename string, src []byte) ([]byte, []MigrationNote, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := &migration{fset: fset, file: file}
+	if !m.rewriteImports() {
+		return src, nil, nil
+	}
+	m.rewrite()
+	m.fixImports()
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, nil, err
+	}
+	// Sorts the imports that were added
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, m.notes, nil
+}
+
+type migration struct {
+	fset  *token.FileSet
+	file  *ast.File
+	notes []MigrationNote
+
+	// The names the webrtc and media packages are imported as, empty if they aren't
+	webrtcName string
+	mediaName  string
+
+	usesV2Compat bool
+}
+
+// rewriteImports changes the v2 imports to v3, false is returned if there are none
+func (m *migration) rewriteImports() bool {
+	found := false
+	for _, spec := range m.file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || (path != importPathV2 && !strings.HasPrefix(path, importPathV2+"/")) {
+			continue
+		}
+		found = true
+
+		path = importPathV3 + strings.TrimPrefix(path, importPathV2)
+		spec.Path.Value = strconv.Quote(path)
+
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		switch path {
+		case importPathV3:
+			if name == "" {
+				name = "webrtc"
+			}
+			m.webrtcName = name
+		case importPathMedia:
+			if name == "" {
+				name = "media"
+			}
+			m.mediaName = name
+		default:
+		}
+	}
+	return found
+}
+
+func (m *migration) rewrite() {
+	ast.Inspect(m.file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			m.rewriteCall(n)
+		case *ast.CompositeLit:
+			m.rewriteSample(n)
+		case *ast.SelectorExpr:
+			if m.isPackage(n.X, m.webrtcName) && v2Names[n.Sel.Name] {
+				n.X = m.v2compat(n.X.Pos())
+			}
+		default:
+		}
+		return true
+	})
+}
+
+// rewriteCall replaces the methods that were removed in v3 by the functions of this package
+func (m *migration) rewriteCall(call *ast.CallExpr) {
+	method, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || m.webrtcName == "" || m.isPackage(method.X, "v2compat") {
+		return
+	}
+
+	// v3 takes a pointer to the MediaEngine
+	if m.isPackage(method.X, m.webrtcName) {
+		if method.Sel.Name == "WithMediaEngine" && len(call.Args) == 1 && isValue(call.Args[0]) {
+			call.Args[0] = &ast.UnaryExpr{OpPos: call.Args[0].Pos(), Op: token.AND, X: call.Args[0]}
+		}
+		return
+	}
+
+	switch {
+	// v3 takes the codec parameters and the codec type
+	case method.Sel.Name == "RegisterCodec" && len(call.Args) == 1:
+		mediaEngine := method.X
+		if isValue(mediaEngine) {
+			mediaEngine = &ast.UnaryExpr{OpPos: mediaEngine.Pos(), Op: token.AND, X: mediaEngine}
+		}
+		call.Args = append([]ast.Expr{mediaEngine}, call.Args...)
+		call.Fun = m.v2compatSelector(call.Pos(), "RegisterCodec")
+	case method.Sel.Name == "NewTrack" && len(call.Args) == 5:
+		call.Fun = m.v2compatSelector(call.Pos(), "NewTrack")
+	case method.Sel.Name == "OnTrack" && len(call.Args) == 1:
+		handler, ok := call.Args[0].(*ast.FuncLit)
+		if !ok || len(handler.Type.Params.List) == 0 {
+			m.note(call, "OnTrack: use v2compat.OnTrack for a handler that takes a v2 Track")
+			return
+		}
+		track, ok := handler.Type.Params.List[0].Type.(*ast.StarExpr)
+		if !ok {
+			return
+		}
+		trackType, ok := track.X.(*ast.SelectorExpr)
+		if !ok || !m.isPackage(trackType.X, m.webrtcName) || trackType.Sel.Name != "Track" {
+			return
+		}
+		track.X = m.v2compatSelector(track.X.Pos(), "RemoteTrack")
+		call.Args = append([]ast.Expr{method.X}, call.Args...)
+		call.Fun = m.v2compatSelector(call.Pos(), "OnTrack")
+	case method.Sel.Name == "AddTransceiver":
+		m.note(call, "AddTransceiver: use AddTransceiverFromKind or AddTransceiverFromTrack")
+	default:
+	}
+}
+
+// rewriteSample replaces media.Sample by Sample when it is created with a number of samples
+func (m *migration) rewriteSample(lit *ast.CompositeLit) {
+	typ, ok := lit.Type.(*ast.SelectorExpr)
+	if !ok || !m.isPackage(typ.X, m.mediaName) || typ.Sel.Name != "Sample" {
+		return
+	}
+
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Samples" {
+				typ.X = m.v2compat(typ.X.Pos())
+				return
+			}
+		}
+	}
+}
+
+// isValue returns true if x is a variable declared with a composite literal or a
+// type that isn't a pointer, e.g. m := webrtc.MediaEngine{}
+func isValue(x ast.Expr) bool {
+	ident, ok := x.(*ast.Ident)
+	if !ok || ident.Obj == nil {
+		return false
+	}
+
+	switch decl := ident.Obj.Decl.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range decl.Lhs {
+			if lhsIdent, ok := lhs.(*ast.Ident); ok && lhsIdent.Name == ident.Name && i < len(decl.Rhs) {
+				_, ok = decl.Rhs[i].(*ast.CompositeLit)
+				return ok
+			}
+		}
+	case *ast.ValueSpec:
+		if decl.Type != nil {
+			_, ok := decl.Type.(*ast.StarExpr)
+			return !ok
+		}
+		for i, name := range decl.Names {
+			if name.Name == ident.Name && i < len(decl.Values) {
+				_, ok := decl.Values[i].(*ast.CompositeLit)
+				return ok
+			}
+		}
+	}
+	return false
+}
+
+func (m *migration) isPackage(x ast.Expr, name string) bool {
+	ident, ok := x.(*ast.Ident)
+	return ok && name != "" && ident.Name == name && ident.Obj == nil
+}
+
+// v2compat returns the name of this package at pos, the positions keep the printer
+// from dropping the blank lines around the rewritten code
+func (m *migration) v2compat(pos token.Pos) *ast.Ident {
+	m.usesV2Compat = true
+	return &ast.Ident{NamePos: pos, Name: "v2compat"}
+}
+
+func (m *migration) v2compatSelector(pos token.Pos, name string) *ast.SelectorExpr {
+	return &ast.SelectorExpr{X: m.v2compat(pos), Sel: &ast.Ident{NamePos: pos, Name: name}}
+}
+
+func (m *migration) note(node ast.Node, message string) {
+	m.notes = append(m.notes, MigrationNote{Pos: m.fset.Position(node.Pos()), Message: message})
+}
+
+// fixImports adds the import of this package if it is used, and removes the imports of
+// webrtc and media if all of their uses were replaced
+func (m *migration) fixImports() {
+	used := map[string]bool{}
+	ast.Inspect(m.file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	for _, decl := range m.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+
+		specs := gen.Specs[:0]
+		for _, spec := range gen.Specs {
+			importSpec, ok := spec.(*ast.ImportSpec)
+			if !ok {
+				continue
+			}
+			path, _ := strconv.Unquote(importSpec.Path.Value)
+			if (path == importPathV3 && !used[m.webrtcName]) || (path == importPathMedia && !used[m.mediaName]) {
+				continue
+			}
+			specs = append(specs, spec)
+		}
+		gen.Specs = specs
+
+		if m.usesV2Compat {
+			gen.Specs = append(gen.Specs, &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(importPathV2Compat)}})
+			if !gen.Lparen.IsValid() {
+				gen.Lparen = gen.Pos()
+			}
+			m.usesV2Compat = false
+		}
+	}
+
+	// Imports that were removed would otherwise still be printed
+	imports := m.file.Imports[:0]
+	for _, decl := range m.file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			for _, spec := range gen.Specs {
+				if importSpec, ok := spec.(*ast.ImportSpec); ok {
+					imports = append(imports, importSpec)
+				}
+			}
+		}
+	}
+	m.file.Imports = imports
+}
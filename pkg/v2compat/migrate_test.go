@@ -0,0 +1,74 @@
+// +build !js
+
+package v2compat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	src := `package main
+
+import (
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func main() {
+	m := webrtc.MediaEngine{}
+	m.RegisterCodec(webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(m))
+
+	pc, _ := api.NewPeerConnection(webrtc.Configuration{})
+	track, _ := pc.NewTrack(webrtc.DefaultPayloadTypeVP8, 1234, "video", "pion", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+	_, _ = pc.AddTrack(track)
+	_, _ = pc.AddTransceiver(webrtc.RTPCodecTypeAudio)
+
+	pc.OnTrack(func(remote *webrtc.Track, receiver *webrtc.RTPReceiver) {
+		_, _ = remote.ReadRTP()
+	})
+
+	_ = track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 3000})
+}
+`
+
+	expected := `package main
+
+import (
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/v2compat"
+)
+
+func main() {
+	m := webrtc.MediaEngine{}
+	v2compat.RegisterCodec(&m, v2compat.NewRTPVP8Codec(v2compat.DefaultPayloadTypeVP8, 90000))
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(&m))
+
+	pc, _ := api.NewPeerConnection(webrtc.Configuration{})
+	track, _ := v2compat.NewTrack(v2compat.DefaultPayloadTypeVP8, 1234, "video", "pion", v2compat.NewRTPVP8Codec(v2compat.DefaultPayloadTypeVP8, 90000))
+	_, _ = pc.AddTrack(track)
+	_, _ = pc.AddTransceiver(webrtc.RTPCodecTypeAudio)
+
+	v2compat.OnTrack(pc, func(remote *v2compat.RemoteTrack, receiver *webrtc.RTPReceiver) {
+		_, _ = remote.ReadRTP()
+	})
+
+	_ = track.WriteSample(v2compat.Sample{Data: []byte{0x00}, Samples: 3000})
+}
+`
+
+	out, notes, err := Migrate("main.go", []byte(src))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(out))
+
+	assert.Len(t, notes, 1)
+	assert.Equal(t, "main.go:16:9: AddTransceiver: use AddTransceiverFromKind or AddTransceiverFromTrack", notes[0].String())
+
+	// Sources that don't import v2 are left alone
+	out, notes, err = Migrate("main.go", []byte(expected))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(out))
+	assert.Empty(t, notes)
+}
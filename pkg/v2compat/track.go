@@ -0,0 +1,158 @@
+// +build !js
+
+package v2compat
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// rtpOutboundMTU matches the MTU of webrtc.TrackLocalStaticSample
+const rtpOutboundMTU = 1200
+
+// Track is a local track as used by v2, it can be added to PeerConnections with
+// PeerConnection.AddTrack. The SSRC and payload type passed to NewTrack are only
+// informational, v3 chooses them for each RTPSender.
+//
+// Deprecated: Use webrtc.TrackLocalStaticSample or webrtc.TrackLocalStaticRTP.
+type Track struct {
+	*webrtc.TrackLocalStaticRTP
+
+	payloadType uint8
+	ssrc        uint32
+	codec       *RTPCodec
+
+	mu         sync.Mutex
+	packetizer rtp.Packetizer
+}
+
+// NewTrack creates a local track.
+//
+// Deprecated: Use webrtc.NewTrackLocalStaticSample or webrtc.NewTrackLocalStaticRTP.
+func NewTrack(payloadType uint8, ssrc uint32, id, label string, codec *RTPCodec) (*Track, error) {
+	rtpTrack, err := webrtc.NewTrackLocalStaticRTP(codec.Capability(), id, label)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Track{
+		TrackLocalStaticRTP: rtpTrack,
+		payloadType:         payloadType,
+		ssrc:                ssrc,
+		codec:               codec,
+	}, nil
+}
+
+// Label returns the label of the track, which is called StreamID in v3.
+//
+// Deprecated: Use StreamID.
+func (t *Track) Label() string {
+	return t.StreamID()
+}
+
+// SSRC returns the SSRC passed to NewTrack.
+//
+// Deprecated: The SSRC is chosen by each RTPSender, see RTPSender.GetParameters.
+func (t *Track) SSRC() uint32 {
+	return t.ssrc
+}
+
+// PayloadType returns the payload type passed to NewTrack.
+//
+// Deprecated: The payload type is negotiated by each RTPSender, see RTPSender.GetParameters.
+func (t *Track) PayloadType() uint8 {
+	return t.payloadType
+}
+
+// V2Codec returns the codec passed to NewTrack, Codec returns the v3 capability.
+//
+// Deprecated: Use Codec.
+func (t *Track) V2Codec() *RTPCodec {
+	return t.codec
+}
+
+// WriteSample packetizes and sends a sample.
+//
+// Deprecated: Use TrackLocalStaticSample.WriteSample with a media.Sample.
+func (t *Track) WriteSample(s Sample) error {
+	t.mu.Lock()
+	if t.packetizer == nil {
+		if t.codec.Payloader == nil {
+			t.mu.Unlock()
+			return errNoPayloader
+		}
+		t.packetizer = rtp.NewPacketizer(rtpOutboundMTU, t.payloadType, t.ssrc, t.codec.Payloader, rtp.NewRandomSequencer(), t.codec.ClockRate)
+	}
+	packets := t.packetizer.Packetize(s.Data, s.Samples)
+	t.mu.Unlock()
+
+	for _, p := range packets {
+		if err := t.WriteRTP(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoteTrack is a received track as used by v2.
+//
+// Deprecated: Use webrtc.TrackRemote.
+type RemoteTrack struct {
+	*webrtc.TrackRemote
+}
+
+// Label returns the label of the track, which is called StreamID in v3.
+//
+// Deprecated: Use StreamID.
+func (t *RemoteTrack) Label() string {
+	return t.StreamID()
+}
+
+// SSRC returns the SSRC of the track.
+//
+// Deprecated: Use TrackRemote.SSRC, which returns a webrtc.SSRC.
+func (t *RemoteTrack) SSRC() uint32 {
+	return uint32(t.TrackRemote.SSRC())
+}
+
+// PayloadType returns the payload type of the track.
+//
+// Deprecated: Use TrackRemote.PayloadType, which returns a webrtc.PayloadType.
+func (t *RemoteTrack) PayloadType() uint8 {
+	return uint8(t.TrackRemote.PayloadType())
+}
+
+// V2Codec returns the codec of the track, Codec returns the v3 parameters.
+//
+// Deprecated: Use Codec.
+func (t *RemoteTrack) V2Codec() *RTPCodec {
+	return codecFromParameters(t.Codec())
+}
+
+// Read reads an RTP packet into b.
+//
+// Deprecated: Use TrackRemote.Read, which also returns the interceptor attributes.
+func (t *RemoteTrack) Read(b []byte) (int, error) {
+	n, _, err := t.TrackRemote.Read(b)
+	return n, err
+}
+
+// ReadRTP reads and unmarshals an RTP packet.
+//
+// Deprecated: Use TrackRemote.ReadRTP, which also returns the interceptor attributes.
+func (t *RemoteTrack) ReadRTP() (*rtp.Packet, error) {
+	p, _, err := t.TrackRemote.ReadRTP()
+	return p, err
+}
+
+// OnTrack sets a v2 track handler on the PeerConnection, replacing any handler set
+// with PeerConnection.OnTrack.
+//
+// Deprecated: Use PeerConnection.OnTrack with webrtc.TrackRemote.
+func OnTrack(pc *webrtc.PeerConnection, f func(*RemoteTrack, *webrtc.RTPReceiver)) {
+	pc.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		f(&RemoteTrack{TrackRemote: track}, receiver)
+	})
+}
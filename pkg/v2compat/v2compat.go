@@ -0,0 +1,198 @@
+// +build !js
+
+// Package v2compat provides the media API of pion/webrtc v2 on top of v3, so large
+// codebases can upgrade incrementally instead of rewriting all media code at once.
+// Migrate, or the v2migrate command for a whole project, rewrites v2 code to use it.
+//
+// Everything in this package is deprecated, linters like staticcheck report the
+// remaining uses together with the v3 API that replaces them:
+//   - RTPCodec and its constructors are replaced by RTPCodecParameters and MediaEngine.RegisterCodec
+//   - Sample with a sample count is replaced by media.Sample with a Duration
+//   - NewTrack and PeerConnection.AddTrack are replaced by NewTrackLocalStaticSample
+//     or NewTrackLocalStaticRTP, the SSRC and payload type are chosen by each RTPSender
+//   - OnTrack with RemoteTrack is replaced by PeerConnection.OnTrack with TrackRemote
+package v2compat
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+// Payload types used by v2 for the default codecs.
+//
+// Deprecated: The payload types are set in the RTPCodecParameters registered with the MediaEngine.
+const (
+	DefaultPayloadTypePCMU = 0
+	DefaultPayloadTypePCMA = 8
+	DefaultPayloadTypeG722 = 9
+	DefaultPayloadTypeOpus = 111
+	DefaultPayloadTypeVP8  = 96
+	DefaultPayloadTypeVP9  = 98
+	DefaultPayloadTypeH264 = 102
+)
+
+var errNoPayloader = errors.New("codec has no payloader")
+
+// RTPCodec is a codec as used by v2.
+//
+// Deprecated: Use webrtc.RTPCodecParameters.
+type RTPCodec struct {
+	Type         webrtc.RTPCodecType
+	Name         string
+	ClockRate    uint32
+	Channels     uint16
+	SDPFmtpLine  string
+	PayloadType  uint8
+	RTCPFeedback []webrtc.RTCPFeedback
+	Payloader    rtp.Payloader
+}
+
+// NewRTPCodec creates a codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters.
+func NewRTPCodec(codecType webrtc.RTPCodecType, name string, clockRate uint32, channels uint16, fmtp string, payloadType uint8, payloader rtp.Payloader) *RTPCodec {
+	return &RTPCodec{
+		Type:        codecType,
+		Name:        name,
+		ClockRate:   clockRate,
+		Channels:    channels,
+		SDPFmtpLine: fmtp,
+		PayloadType: payloadType,
+		Payloader:   payloader,
+	}
+}
+
+// NewRTPOpusCodec creates an Opus codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypeOpus.
+func NewRTPOpusCodec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeAudio, "opus", clockRate, 2, "minptime=10;useinbandfec=1", payloadType, &codecs.OpusPayloader{})
+}
+
+// NewRTPG722Codec creates a G722 codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypeG722.
+func NewRTPG722Codec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeAudio, "G722", clockRate, 0, "", payloadType, &codecs.G722Payloader{})
+}
+
+// NewRTPPCMUCodec creates a PCMU codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypePCMU.
+func NewRTPPCMUCodec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeAudio, "PCMU", clockRate, 0, "", payloadType, &codecs.G711Payloader{})
+}
+
+// NewRTPPCMACodec creates a PCMA codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypePCMA.
+func NewRTPPCMACodec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeAudio, "PCMA", clockRate, 0, "", payloadType, &codecs.G711Payloader{})
+}
+
+// NewRTPVP8Codec creates a VP8 codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypeVP8.
+func NewRTPVP8Codec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeVideo, "VP8", clockRate, 0, "", payloadType, &codecs.VP8Payloader{})
+}
+
+// NewRTPVP9Codec creates a VP9 codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypeVP9.
+func NewRTPVP9Codec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeVideo, "VP9", clockRate, 0, "", payloadType, &codecs.VP9Payloader{})
+}
+
+// NewRTPH264Codec creates an H264 codec.
+//
+// Deprecated: Use webrtc.RTPCodecParameters with webrtc.MimeTypeH264.
+func NewRTPH264Codec(payloadType uint8, clockRate uint32) *RTPCodec {
+	return NewRTPCodec(webrtc.RTPCodecTypeVideo, "H264", clockRate, 0, "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f", payloadType, &codecs.H264Payloader{})
+}
+
+// Capability returns the v3 capability of the codec
+func (c *RTPCodec) Capability() webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{
+		MimeType:     c.Type.String() + "/" + c.Name,
+		ClockRate:    c.ClockRate,
+		Channels:     c.Channels,
+		SDPFmtpLine:  c.SDPFmtpLine,
+		RTCPFeedback: c.RTCPFeedback,
+	}
+}
+
+// Parameters returns the v3 parameters of the codec
+func (c *RTPCodec) Parameters() webrtc.RTPCodecParameters {
+	return webrtc.RTPCodecParameters{
+		RTPCodecCapability: c.Capability(),
+		PayloadType:        webrtc.PayloadType(c.PayloadType),
+	}
+}
+
+// codecFromParameters converts v3 codec parameters, the payloader is only set for
+// codecs pion/webrtc can packetize
+func codecFromParameters(params webrtc.RTPCodecParameters) *RTPCodec {
+	codecType := webrtc.RTPCodecTypeVideo
+	if strings.HasPrefix(strings.ToLower(params.MimeType), "audio/") {
+		codecType = webrtc.RTPCodecTypeAudio
+	}
+
+	name := params.MimeType
+	if i := strings.Index(name, "/"); i != -1 {
+		name = name[i+1:]
+	}
+
+	codec := NewRTPCodec(codecType, name, params.ClockRate, params.Channels, params.SDPFmtpLine, uint8(params.PayloadType), nil)
+	codec.RTCPFeedback = params.RTCPFeedback
+	codec.Payloader = payloaderForName(name)
+	return codec
+}
+
+func payloaderForName(name string) rtp.Payloader {
+	switch strings.ToLower(name) {
+	case "opus":
+		return &codecs.OpusPayloader{}
+	case "g722":
+		return &codecs.G722Payloader{}
+	case "pcmu", "pcma":
+		return &codecs.G711Payloader{}
+	case "vp8":
+		return &codecs.VP8Payloader{}
+	case "vp9":
+		return &codecs.VP9Payloader{}
+	case "h264":
+		return &codecs.H264Payloader{}
+	default:
+		return nil
+	}
+}
+
+// RegisterCodec registers a v2 codec with a MediaEngine.
+//
+// Deprecated: Use MediaEngine.RegisterCodec with webrtc.RTPCodecParameters.
+func RegisterCodec(m *webrtc.MediaEngine, codec *RTPCodec) error {
+	return m.RegisterCodec(codec.Parameters(), codec.Type)
+}
+
+// Sample is a media sample with the duration as number of samples, as used by v2.
+//
+// Deprecated: Use media.Sample, which has a Duration.
+type Sample struct {
+	Data    []byte
+	Samples uint32
+}
+
+// ToV3 converts the sample, the clock rate of the codec is needed for the duration
+func (s Sample) ToV3(clockRate uint32) media.Sample {
+	return media.Sample{
+		Data:     s.Data,
+		Duration: time.Duration(s.Samples) * time.Second / time.Duration(clockRate),
+	}
+}
@@ -0,0 +1,97 @@
+// +build !js
+
+package v2compat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTPCodec(t *testing.T) {
+	codec := NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000)
+	assert.Equal(t, webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        DefaultPayloadTypeVP8,
+	}, codec.Parameters())
+
+	converted := codecFromParameters(codec.Parameters())
+	assert.Equal(t, codec, converted)
+
+	m := &webrtc.MediaEngine{}
+	assert.NoError(t, RegisterCodec(m, NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000)))
+}
+
+func TestSample(t *testing.T) {
+	s := Sample{Data: []byte{0x01}, Samples: 960}.ToV3(48000)
+	assert.Equal(t, []byte{0x01}, s.Data)
+	assert.Equal(t, 20*time.Millisecond, s.Duration)
+}
+
+func signalPair(t *testing.T, offerPC, answerPC *webrtc.PeerConnection) {
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	offerGatheringComplete := webrtc.GatheringCompletePromise(offerPC)
+	assert.NoError(t, offerPC.SetLocalDescription(offer))
+	<-offerGatheringComplete
+	assert.NoError(t, answerPC.SetRemoteDescription(*offerPC.LocalDescription()))
+
+	answer, err := answerPC.CreateAnswer(nil)
+	assert.NoError(t, err)
+	answerGatheringComplete := webrtc.GatheringCompletePromise(answerPC)
+	assert.NoError(t, answerPC.SetLocalDescription(answer))
+	<-answerGatheringComplete
+	assert.NoError(t, offerPC.SetRemoteDescription(*answerPC.LocalDescription()))
+}
+
+func TestTrack(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &webrtc.MediaEngine{}
+	assert.NoError(t, RegisterCodec(m, NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000)))
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(m))
+
+	offerPC, err := api.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	answerPC, err := api.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+
+	track, err := NewTrack(DefaultPayloadTypeVP8, 1234, "video", "pion", NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000))
+	assert.NoError(t, err)
+	assert.Equal(t, "pion", track.Label())
+	_, err = offerPC.AddTrack(track)
+	assert.NoError(t, err)
+
+	received := make(chan *RemoteTrack)
+	OnTrack(answerPC, func(remote *RemoteTrack, _ *webrtc.RTPReceiver) {
+		if _, readErr := remote.ReadRTP(); readErr == nil {
+			received <- remote
+		}
+	})
+
+	signalPair(t, offerPC, answerPC)
+
+	var remote *RemoteTrack
+	for remote == nil {
+		select {
+		case remote = <-received:
+		case <-time.After(20 * time.Millisecond):
+			assert.NoError(t, track.WriteSample(Sample{Data: []byte{0x00}, Samples: 3000}))
+		}
+	}
+
+	assert.Equal(t, "video", remote.ID())
+	assert.Equal(t, "pion", remote.Label())
+	assert.Equal(t, uint8(DefaultPayloadTypeVP8), remote.PayloadType())
+	assert.Equal(t, "VP8", remote.V2Codec().Name)
+
+	assert.NoError(t, offerPC.Close())
+	assert.NoError(t, answerPC.Close())
+}
@@ -0,0 +1,112 @@
+// +build !js
+
+// v2migrate rewrites the Go files of a project that uses pion/webrtc v2 to build
+// against v3 with the help of the v2compat package, see v2compat.Migrate.
+//
+//	go run github.com/pion/webrtc/v3/pkg/v2compat/v2migrate ./...
+//
+// Files are rewritten in place, the uses of v2 that have to be changed by hand are
+// printed. Directories named vendor or testdata are skipped.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pion/webrtc/v3/pkg/v2compat"
+)
+
+func main() {
+	paths := os.Args[1:]
+	if len(paths) == 0 {
+		paths = []string{"./..."}
+	}
+
+	failed := false
+	for _, path := range paths {
+		if err := migratePath(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// migratePath migrates a file, a directory, or a directory and its subdirectories
+// if the path ends with /...
+func migratePath(path string) error {
+	if strings.HasSuffix(path, "/...") {
+		root := strings.TrimSuffix(path, "/...")
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != root && (info.Name() == "vendor" || info.Name() == "testdata" || strings.HasPrefix(info.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return migrateGoFile(path)
+		})
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return migrateFile(path)
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			if err := migrateGoFile(filepath.Join(path, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrateGoFile(path string) error {
+	if filepath.Ext(path) != ".go" {
+		return nil
+	}
+	return migrateFile(path)
+}
+
+func migrateFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := ioutil.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	out, notes, err := v2compat.Migrate(path, src)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		fmt.Println(note)
+	}
+	if bytes.Equal(src, out) {
+		return nil
+	}
+
+	fmt.Println("migrated", path)
+	return ioutil.WriteFile(path, out, info.Mode())
+}
@@ -0,0 +1,272 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// PortMapper requests port mappings from the local gateway, e.g. via NAT-PMP or UPnP IGD.
+// When set on the SettingEngine the ICEGatherer maps the port of every IPv4 UDP host
+// candidate and advertises the mapped address as a server reflexive candidate.
+type PortMapper interface {
+	// MapPort asks the gateway to forward a UDP port to the given internal port
+	// and returns the external address and port that was mapped. The mapping is
+	// kept alive until UnmapPort is called.
+	MapPort(internalPort int) (externalIP net.IP, externalPort int, err error)
+
+	// UnmapPort removes the mapping of the given internal port.
+	UnmapPort(internalPort int) error
+}
+
+// sharedPortMapper counts the users of every mapped internal port. Gatherers sharing
+// a UDPMux map the same port, the mapping is only removed when the last one is done.
+type sharedPortMapper struct {
+	PortMapper
+
+	mu       sync.Mutex
+	mappings map[int]*sharedPortMapping
+}
+
+type sharedPortMapping struct {
+	refs int
+
+	// done is closed once the gateway answered the MapPort call
+	done         chan struct{}
+	externalIP   net.IP
+	externalPort int
+	err          error
+}
+
+func newSharedPortMapper(m PortMapper) *sharedPortMapper {
+	if s, ok := m.(*sharedPortMapper); ok {
+		return s
+	}
+	return &sharedPortMapper{PortMapper: m, mappings: map[int]*sharedPortMapping{}}
+}
+
+func (s *sharedPortMapper) MapPort(internalPort int) (net.IP, int, error) {
+	s.mu.Lock()
+	mapping, ok := s.mappings[internalPort]
+	if !ok {
+		mapping = &sharedPortMapping{done: make(chan struct{})}
+		s.mappings[internalPort] = mapping
+	}
+	mapping.refs++
+	s.mu.Unlock()
+
+	if ok {
+		<-mapping.done
+		return mapping.externalIP, mapping.externalPort, mapping.err
+	}
+
+	externalIP, externalPort, err := s.PortMapper.MapPort(internalPort)
+
+	s.mu.Lock()
+	mapping.externalIP, mapping.externalPort, mapping.err = externalIP, externalPort, err
+	if err != nil && s.mappings[internalPort] == mapping {
+		delete(s.mappings, internalPort)
+	}
+	close(mapping.done)
+	s.mu.Unlock()
+
+	return externalIP, externalPort, err
+}
+
+func (s *sharedPortMapper) UnmapPort(internalPort int) error {
+	s.mu.Lock()
+	mapping, ok := s.mappings[internalPort]
+	if !ok {
+		s.mu.Unlock()
+		return nil
+	}
+	if mapping.refs--; mapping.refs > 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	delete(s.mappings, internalPort)
+	s.mu.Unlock()
+
+	<-mapping.done
+	if mapping.err != nil {
+		return nil
+	}
+	return s.PortMapper.UnmapPort(internalPort)
+}
+
+// portMappingRenewals renews the mappings of a PortMapper at half their lifetime, as
+// recommended by RFC 6886 Section 3.3, until they are removed
+type portMappingRenewals struct {
+	mu     sync.Mutex
+	timers map[int]*time.Timer
+}
+
+// add renews the mapping of the internal port with renew, which returns the lifetime
+// the gateway granted. The next renewal is scheduled from it, a failed renewal is
+// retried sooner. A previous renewal of the port is replaced.
+func (r *portMappingRenewals) add(internalPort int, lifetime time.Duration, renew func() (time.Duration, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timers == nil {
+		r.timers = map[int]*time.Timer{}
+	}
+	if timer, ok := r.timers[internalPort]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(lifetime/2, func() {
+		// Renewing under the lock keeps remove from racing with a renewal
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.timers[internalPort] != timer {
+			return
+		}
+
+		granted, err := renew()
+		if err != nil {
+			timer.Reset(lifetime / 8)
+			return
+		}
+		lifetime = granted
+		timer.Reset(lifetime / 2)
+	})
+	r.timers[internalPort] = timer
+}
+
+// remove stops renewing the mapping of the internal port
+func (r *portMappingRenewals) remove(internalPort int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timer, ok := r.timers[internalPort]; ok {
+		timer.Stop()
+		delete(r.timers, internalPort)
+	}
+}
+
+const (
+	natPMPPort            = 5351
+	natPMPVersion         = 0
+	natPMPOpExternalAddr  = 0
+	natPMPOpMapUDP        = 1
+	natPMPResponseOpFlag  = 128
+	natPMPInitialTimeout  = 250 * time.Millisecond
+	natPMPMaxAttempts     = 4
+	natPMPDefaultLifetime = 2 * time.Hour
+)
+
+type natPMPPortMapper struct {
+	gateway  *net.UDPAddr
+	lifetime time.Duration
+	renewals portMappingRenewals
+}
+
+// NewNATPMPPortMapper creates a PortMapper that talks NAT-PMP (RFC 6886) to the given gateway.
+// The gateway is not discovered, it has to be the address of the local router.
+// Mappings are requested with a lifetime of two hours and renewed at half the lifetime
+// the gateway granted.
+func NewNATPMPPortMapper(gateway net.IP) PortMapper {
+	return &natPMPPortMapper{
+		gateway:  &net.UDPAddr{IP: gateway, Port: natPMPPort},
+		lifetime: natPMPDefaultLifetime,
+	}
+}
+
+func (m *natPMPPortMapper) MapPort(internalPort int) (net.IP, int, error) {
+	res, err := m.request([]byte{natPMPVersion, natPMPOpExternalAddr}, 12)
+	if err != nil {
+		return nil, 0, err
+	}
+	externalIP := net.IPv4(res[8], res[9], res[10], res[11])
+
+	externalPort, lifetime, err := m.mapPort(internalPort, internalPort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The renewal asks for the same external port, the gateway keeps it while the
+	// mapping exists
+	m.renewals.add(internalPort, lifetime, func() (time.Duration, error) {
+		_, lifetime, err := m.mapPort(internalPort, externalPort)
+		return lifetime, err
+	})
+	return externalIP, externalPort, nil
+}
+
+// mapPort requests a mapping of the internal port and returns the external port and
+// lifetime the gateway granted, which may be shorter than the requested one
+func (m *natPMPPortMapper) mapPort(internalPort, externalPort int) (int, time.Duration, error) {
+	res, err := m.request(m.mapRequest(internalPort, externalPort, m.lifetime), 16)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	lifetime := time.Duration(binary.BigEndian.Uint32(res[12:16])) * time.Second
+	if lifetime == 0 {
+		return 0, 0, fmt.Errorf("%w: mapping without lifetime", errNATPMPInvalidResponse)
+	}
+	return int(binary.BigEndian.Uint16(res[10:12])), lifetime, nil
+}
+
+func (m *natPMPPortMapper) UnmapPort(internalPort int) error {
+	m.renewals.remove(internalPort)
+	_, err := m.request(m.mapRequest(internalPort, 0, 0), 16)
+	return err
+}
+
+func (m *natPMPPortMapper) mapRequest(internalPort, externalPort int, lifetime time.Duration) []byte {
+	req := make([]byte, 12)
+	req[0] = natPMPVersion
+	req[1] = natPMPOpMapUDP
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:12], uint32(lifetime/time.Second))
+	return req
+}
+
+// request sends a NAT-PMP request, retransmitting with a doubling timeout, and
+// returns the response once its opcode and result code have been checked
+func (m *natPMPPortMapper) request(req []byte, responseLen int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, m.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	res := make([]byte, 16)
+	timeout := natPMPInitialTimeout
+	for i := 0; i < natPMPMaxAttempts; i++ {
+		if _, err = conn.Write(req); err != nil {
+			return nil, err
+		}
+		if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+		timeout *= 2
+
+		n, readErr := conn.Read(res)
+		if readErr != nil {
+			err = readErr
+			continue
+		}
+
+		switch {
+		case n < responseLen || res[0] != natPMPVersion || res[1] != req[1]|natPMPResponseOpFlag:
+			return nil, errNATPMPInvalidResponse
+		case binary.BigEndian.Uint16(res[2:4]) != 0:
+			return nil, fmt.Errorf("%w: %d", errNATPMPResultCode, binary.BigEndian.Uint16(res[2:4]))
+		}
+		return res[:n], nil
+	}
+
+	return nil, err
+}
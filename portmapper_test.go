@@ -0,0 +1,336 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNATPMPGateway answers NAT-PMP requests with the given result code, mappings are
+// granted the given lifetime in seconds or the requested one if it is zero
+func fakeNATPMPGateway(t *testing.T, resultCode uint16, lifetime uint32) (*net.UDPConn, chan []byte) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+
+	requests := make(chan []byte, 8)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				close(requests)
+				return
+			}
+			req := append([]byte{}, buf[:n]...)
+			requests <- req
+
+			res := make([]byte, 16)
+			res[1] = req[1] | natPMPResponseOpFlag
+			binary.BigEndian.PutUint16(res[2:4], resultCode)
+			if req[1] == natPMPOpExternalAddr {
+				copy(res[8:12], net.IPv4(203, 0, 113, 7).To4())
+				res = res[:12]
+			} else {
+				copy(res[8:10], req[4:6])
+				binary.BigEndian.PutUint16(res[10:12], binary.BigEndian.Uint16(req[4:6])+1)
+				copy(res[12:16], req[8:12])
+				if lifetime != 0 && binary.BigEndian.Uint32(req[8:12]) != 0 {
+					binary.BigEndian.PutUint32(res[12:16], lifetime)
+				}
+			}
+
+			if _, err = conn.WriteToUDP(res, addr); err != nil {
+				return
+			}
+		}
+	}()
+
+	return conn, requests
+}
+
+func TestNATPMPPortMapper(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	t.Run("MapAndUnmap", func(t *testing.T) {
+		conn, requests := fakeNATPMPGateway(t, 0, 0)
+		m := &natPMPPortMapper{gateway: conn.LocalAddr().(*net.UDPAddr), lifetime: time.Minute}
+
+		externalIP, externalPort, err := m.MapPort(5000)
+		assert.NoError(t, err)
+		assert.True(t, externalIP.Equal(net.IPv4(203, 0, 113, 7)))
+		assert.Equal(t, 5001, externalPort)
+
+		assert.Equal(t, []byte{natPMPVersion, natPMPOpExternalAddr}, <-requests)
+		mapReq := <-requests
+		assert.Equal(t, byte(natPMPOpMapUDP), mapReq[1])
+		assert.Equal(t, uint16(5000), binary.BigEndian.Uint16(mapReq[4:6]))
+		assert.Equal(t, uint32(60), binary.BigEndian.Uint32(mapReq[8:12]))
+
+		assert.NoError(t, m.UnmapPort(5000))
+		unmapReq := <-requests
+		assert.Equal(t, uint32(0), binary.BigEndian.Uint32(unmapReq[8:12]))
+
+		assert.NoError(t, conn.Close())
+	})
+
+	t.Run("Renewal", func(t *testing.T) {
+		conn, requests := fakeNATPMPGateway(t, 0, 0)
+		m := &natPMPPortMapper{gateway: conn.LocalAddr().(*net.UDPAddr), lifetime: 2 * time.Second}
+
+		_, externalPort, err := m.MapPort(5000)
+		assert.NoError(t, err)
+		<-requests
+		<-requests
+
+		// The mapping is renewed at half its lifetime with the mapped external port
+		renewReq := <-requests
+		assert.Equal(t, byte(natPMPOpMapUDP), renewReq[1])
+		assert.Equal(t, uint16(5000), binary.BigEndian.Uint16(renewReq[4:6]))
+		assert.Equal(t, uint16(externalPort), binary.BigEndian.Uint16(renewReq[6:8]))
+
+		assert.NoError(t, m.UnmapPort(5000))
+		<-requests
+		assert.Empty(t, m.renewals.timers)
+
+		assert.NoError(t, conn.Close())
+	})
+
+	t.Run("GrantedLifetime", func(t *testing.T) {
+		conn, requests := fakeNATPMPGateway(t, 0, 2)
+		m := &natPMPPortMapper{gateway: conn.LocalAddr().(*net.UDPAddr), lifetime: time.Hour}
+
+		_, _, err := m.MapPort(5000)
+		assert.NoError(t, err)
+		<-requests
+		<-requests
+
+		// The renewal is scheduled from the granted lifetime, not the requested hour
+		select {
+		case renewReq := <-requests:
+			assert.Equal(t, uint32(3600), binary.BigEndian.Uint32(renewReq[8:12]))
+		case <-time.After(3 * time.Second):
+			assert.Fail(t, "mapping wasn't renewed before the granted lifetime expired")
+		}
+
+		assert.NoError(t, m.UnmapPort(5000))
+		assert.NoError(t, conn.Close())
+	})
+
+	t.Run("ResultCode", func(t *testing.T) {
+		conn, _ := fakeNATPMPGateway(t, 2, 0)
+		m := &natPMPPortMapper{gateway: conn.LocalAddr().(*net.UDPAddr), lifetime: time.Minute}
+
+		_, _, err := m.MapPort(5000)
+		assert.True(t, errors.Is(err, errNATPMPResultCode))
+
+		assert.NoError(t, conn.Close())
+	})
+}
+
+// fakeUPnPGateway serves the device description and the WANIPConnection actions of an
+// internet gateway device, AddPortMapping fails with the fault code returned by fault
+// if it isn't zero
+func fakeUPnPGateway(t *testing.T, fault func(body string) int) (*httptest.Server, chan string) {
+	actions := make(chan string, 8)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.WriteString(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`)
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		action := r.Header.Get("SOAPAction")
+		actions <- action + " " + string(body)
+
+		switch {
+		case strings.HasSuffix(action, `#GetExternalIPAddress"`):
+			_, err = io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1"><NewExternalIPAddress>203.0.113.7</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case strings.HasSuffix(action, `#AddPortMapping"`) && fault(string(body)) != 0:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, err = fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>Failed</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`, fault(string(body)))
+		default:
+			_, err = io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body></s:Body></s:Envelope>`)
+		}
+		assert.NoError(t, err)
+	})
+
+	return httptest.NewServer(mux), actions
+}
+
+func TestUPnPPortMapper(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	noFault := func(string) int { return 0 }
+
+	t.Run("MapAndUnmap", func(t *testing.T) {
+		server, actions := fakeUPnPGateway(t, noFault)
+		defer server.Close()
+
+		m := NewUPnPPortMapper(server.URL + "/rootDesc.xml")
+		externalIP, externalPort, err := m.MapPort(5000)
+		assert.NoError(t, err)
+		assert.True(t, externalIP.Equal(net.IPv4(203, 0, 113, 7)))
+		assert.Equal(t, 5000, externalPort)
+
+		assert.Contains(t, <-actions, "#GetExternalIPAddress")
+		add := <-actions
+		assert.Contains(t, add, "#AddPortMapping")
+		assert.Contains(t, add, "<NewInternalPort>5000</NewInternalPort>")
+		assert.Contains(t, add, "<NewInternalClient>127.0.0.1</NewInternalClient>")
+		assert.Contains(t, add, "<NewLeaseDuration>7200</NewLeaseDuration>")
+
+		assert.NoError(t, m.UnmapPort(5000))
+		assert.Contains(t, <-actions, "#DeletePortMapping")
+	})
+
+	t.Run("OnlyPermanentLeases", func(t *testing.T) {
+		server, actions := fakeUPnPGateway(t, func(body string) int {
+			if strings.Contains(body, "<NewLeaseDuration>0<") {
+				return 0
+			}
+			return upnpErrOnlyPermanent
+		})
+		defer server.Close()
+
+		m := NewUPnPPortMapper(server.URL + "/rootDesc.xml").(*upnpPortMapper)
+		_, _, err := m.MapPort(5000)
+		assert.NoError(t, err)
+
+		<-actions
+		assert.Contains(t, <-actions, "<NewLeaseDuration>7200</NewLeaseDuration>")
+		assert.Contains(t, <-actions, "<NewLeaseDuration>0</NewLeaseDuration>")
+
+		// Permanent mappings aren't renewed
+		assert.Empty(t, m.renewals.timers)
+		assert.NoError(t, m.UnmapPort(5000))
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		// Another client maps the external port 5000 already
+		server, actions := fakeUPnPGateway(t, func(body string) int {
+			if strings.Contains(body, "<NewExternalPort>5000<") {
+				return upnpErrConflict
+			}
+			return 0
+		})
+		defer server.Close()
+
+		m := NewUPnPPortMapper(server.URL + "/rootDesc.xml")
+		_, externalPort, err := m.MapPort(5000)
+		assert.NoError(t, err)
+		assert.NotEqual(t, 5000, externalPort)
+
+		<-actions
+		assert.Contains(t, <-actions, "<NewExternalPort>5000</NewExternalPort>")
+		add := <-actions
+		assert.Contains(t, add, fmt.Sprintf("<NewExternalPort>%d</NewExternalPort>", externalPort))
+		assert.Contains(t, add, "<NewInternalPort>5000</NewInternalPort>")
+
+		// The fallback port is removed, not the internal one
+		assert.NoError(t, m.UnmapPort(5000))
+		assert.Contains(t, <-actions, fmt.Sprintf("<NewExternalPort>%d</NewExternalPort>", externalPort))
+	})
+
+	t.Run("Fault", func(t *testing.T) {
+		server, _ := fakeUPnPGateway(t, func(string) int { return upnpErrConflict })
+		defer server.Close()
+
+		_, _, err := NewUPnPPortMapper(server.URL + "/rootDesc.xml").MapPort(5000)
+		assert.True(t, errors.Is(err, errUPnPFault))
+	})
+
+	t.Run("NoWANService", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := io.WriteString(w, `<?xml version="1.0"?><root><device></device></root>`)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		_, _, err := NewUPnPPortMapper(server.URL).MapPort(5000)
+		assert.True(t, errors.Is(err, errUPnPNoWANService))
+	})
+}
+
+type countingPortMapper struct {
+	mu              sync.Mutex
+	mapped, unmapped int
+}
+
+func (m *countingPortMapper) MapPort(internalPort int) (net.IP, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mapped++
+	return net.IPv4(203, 0, 113, 7), internalPort, nil
+}
+
+func (m *countingPortMapper) UnmapPort(int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unmapped++
+	return nil
+}
+
+func TestSharedPortMapper(t *testing.T) {
+	counting := &countingPortMapper{}
+	m := newSharedPortMapper(counting)
+
+	// Two gatherers on a shared UDPMux map the same port
+	for i := 0; i < 2; i++ {
+		externalIP, externalPort, err := m.MapPort(5000)
+		assert.NoError(t, err)
+		assert.True(t, externalIP.Equal(net.IPv4(203, 0, 113, 7)))
+		assert.Equal(t, 5000, externalPort)
+	}
+	assert.Equal(t, 1, counting.mapped)
+
+	// The mapping stays until the last of them is closed
+	assert.NoError(t, m.UnmapPort(5000))
+	assert.Equal(t, 0, counting.unmapped)
+	assert.NoError(t, m.UnmapPort(5000))
+	assert.Equal(t, 1, counting.unmapped)
+
+	assert.NoError(t, m.UnmapPort(5000))
+	assert.Equal(t, 1, counting.unmapped)
+	assert.Equal(t, m, newSharedPortMapper(m))
+}
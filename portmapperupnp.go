@@ -0,0 +1,407 @@
+// +build !js
+
+package webrtc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/randutil"
+)
+
+const (
+	ssdpMulticastAddr     = "239.255.255.250:1900"
+	upnpDeviceType        = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+	upnpDiscoveryTimeout  = 2 * time.Second
+	upnpRequestTimeout    = 2 * time.Second
+	upnpDefaultLifetime   = 2 * time.Hour
+	upnpMappingName       = "pion"
+	upnpErrConflict       = 718
+	upnpErrOnlyPermanent  = 725
+	upnpMaxPortAttempts   = 4
+	upnpMaxResponseLength = 64 * 1024
+)
+
+// upnpServiceTypes are the WAN connection services that can map ports, in order of preference
+var upnpServiceTypes = []string{ //nolint:gochecknoglobals
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+type upnpPortMapper struct {
+	location string
+	lifetime time.Duration
+	client   *http.Client
+	renewals portMappingRenewals
+
+	// The WAN connection service of the gateway, discovered on the first request
+	mu          sync.Mutex
+	controlURL  string
+	serviceType string
+	internalIP  net.IP
+
+	// externalPorts are the mapped external ports by internal port
+	externalPorts map[int]int
+}
+
+// NewUPnPPortMapper creates a PortMapper that talks UPnP IGD to the local gateway. The
+// location is the URL of the device description of the gateway, if it is empty the
+// gateway is discovered with SSDP on the first mapping. Mappings are requested with a
+// lease of two hours and renewed after one hour, or permanently if the gateway only
+// supports permanent leases. The ports are mapped to the local address that reaches
+// the gateway. The external port is the internal one, unless the gateway already
+// maps it for another client, then a random port is tried.
+func NewUPnPPortMapper(location string) PortMapper {
+	return &upnpPortMapper{
+		location: location,
+		lifetime: upnpDefaultLifetime,
+		client:   &http.Client{Timeout: upnpRequestTimeout},
+	}
+}
+
+func (m *upnpPortMapper) MapPort(internalPort int) (net.IP, int, error) {
+	if err := m.discover(); err != nil {
+		return nil, 0, err
+	}
+
+	res := struct {
+		ExternalIP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}{}
+	if err := m.soap("GetExternalIPAddress", nil, &res); err != nil {
+		return nil, 0, err
+	}
+	externalIP := net.ParseIP(strings.TrimSpace(res.ExternalIP))
+	if externalIP == nil || externalIP.To4() == nil {
+		return nil, 0, fmt.Errorf("%w: external address %q", errUPnPInvalidResponse, res.ExternalIP)
+	}
+
+	externalPort, lifetime, err := m.addPortMappingAnyPort(internalPort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.Lock()
+	if m.externalPorts == nil {
+		m.externalPorts = map[int]int{}
+	}
+	m.externalPorts[internalPort] = externalPort
+	m.mu.Unlock()
+
+	if lifetime > 0 {
+		m.renewals.add(internalPort, lifetime, func() (time.Duration, error) {
+			return lifetime, m.addPortMapping(internalPort, externalPort, lifetime)
+		})
+	}
+	return externalIP, externalPort, nil
+}
+
+func (m *upnpPortMapper) UnmapPort(internalPort int) error {
+	m.renewals.remove(internalPort)
+	if err := m.discover(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	externalPort, ok := m.externalPorts[internalPort]
+	delete(m.externalPorts, internalPort)
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	return m.soap("DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(externalPort)},
+		{"NewProtocol", "UDP"},
+	}, nil)
+}
+
+// addPortMappingAnyPort maps an external port to the internal port and returns it with
+// the lifetime of the lease. The internal port is tried first, random ports if the
+// gateway maps it to another client already.
+func (m *upnpPortMapper) addPortMappingAnyPort(internalPort int) (int, time.Duration, error) {
+	externalPort := internalPort
+	for attempt := 1; ; attempt++ {
+		lifetime := m.lifetime
+		err := m.addPortMapping(internalPort, externalPort, lifetime)
+		var upnpErr *upnpError
+		if errors.As(err, &upnpErr) && upnpErr.code == upnpErrOnlyPermanent {
+			lifetime = 0
+			err = m.addPortMapping(internalPort, externalPort, lifetime)
+		}
+		if err == nil {
+			return externalPort, lifetime, nil
+		}
+
+		if !errors.As(err, &upnpErr) || upnpErr.code != upnpErrConflict || attempt == upnpMaxPortAttempts {
+			return 0, 0, err
+		}
+		externalPort = 1024 + randutil.NewMathRandomGenerator().Intn(65536-1024)
+	}
+}
+
+// addPortMapping maps the external port to the internal port, a mapping that exists
+// is replaced, which renews it
+func (m *upnpPortMapper) addPortMapping(internalPort, externalPort int, lifetime time.Duration) error {
+	m.mu.Lock()
+	internalIP := m.internalIP
+	m.mu.Unlock()
+
+	return m.soap("AddPortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(externalPort)},
+		{"NewProtocol", "UDP"},
+		{"NewInternalPort", strconv.Itoa(internalPort)},
+		{"NewInternalClient", internalIP.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", upnpMappingName},
+		{"NewLeaseDuration", strconv.Itoa(int(lifetime / time.Second))},
+	}, nil)
+}
+
+// discover finds the WAN connection service of the gateway and the local address that
+// reaches it. Discovery waits for the network, so it runs without the lock, concurrent
+// discoveries keep the result of the first.
+func (m *upnpPortMapper) discover() error {
+	m.mu.Lock()
+	discovered := m.controlURL != ""
+	m.mu.Unlock()
+	if discovered {
+		return nil
+	}
+
+	location := m.location
+	if location == "" {
+		var err error
+		if location, err = ssdpDiscover(upnpDeviceType, upnpDiscoveryTimeout); err != nil {
+			return err
+		}
+	}
+
+	controlURL, serviceType, err := m.findService(location)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(controlURL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	conn, err := net.Dial("udp4", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	internalIP := conn.LocalAddr().(*net.UDPAddr).IP
+	if err = conn.Close(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.controlURL == "" {
+		m.controlURL, m.serviceType, m.internalIP = controlURL, serviceType, internalIP
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// findService returns the control URL and type of the preferred WAN connection service
+// in the device description at location
+func (m *upnpPortMapper) findService(location string) (string, string, error) {
+	res, err := m.client.Get(location)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%w: device description status %s", errUPnPInvalidResponse, res.Status)
+	}
+
+	root := struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}{}
+	if err = xml.NewDecoder(io.LimitReader(res.Body, upnpMaxResponseLength)).Decode(&root); err != nil {
+		return "", "", fmt.Errorf("%w: %v", errUPnPInvalidResponse, err)
+	}
+
+	controlURLs := map[string]string{}
+	devices := []upnpDevice{root.Device}
+	for len(devices) != 0 {
+		device := devices[0]
+		devices = append(devices[1:], device.Devices...)
+		for _, service := range device.Services {
+			if _, ok := controlURLs[service.ServiceType]; !ok {
+				controlURLs[service.ServiceType] = strings.TrimSpace(service.ControlURL)
+			}
+		}
+	}
+
+	base := location
+	if root.URLBase != "" {
+		base = root.URLBase
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, serviceType := range upnpServiceTypes {
+		controlURL, ok := controlURLs[serviceType]
+		if !ok {
+			continue
+		}
+		u, err := baseURL.Parse(controlURL)
+		if err != nil {
+			return "", "", err
+		}
+		return u.String(), serviceType, nil
+	}
+	return "", "", errUPnPNoWANService
+}
+
+// upnpError is the UPnPError of a SOAP fault
+type upnpError struct {
+	code        int
+	description string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("%s %d: %s", errUPnPFault, e.code, e.description)
+}
+
+func (e *upnpError) Unwrap() error {
+	return errUPnPFault
+}
+
+// soap calls an action of the WAN connection service and decodes the response into res
+func (m *upnpPortMapper) soap(action string, args [][2]string, res interface{}) error {
+	m.mu.Lock()
+	controlURL, serviceType := m.controlURL, m.serviceType
+	m.mu.Unlock()
+
+	body := &bytes.Buffer{}
+	fmt.Fprintf(body, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:%s xmlns:u="%s">`, action, serviceType)
+	for _, arg := range args {
+		fmt.Fprintf(body, "<%s>", arg[0])
+		if err := xml.EscapeText(body, []byte(arg[1])); err != nil {
+			return err
+		}
+		fmt.Fprintf(body, "</%s>", arg[0])
+	}
+	fmt.Fprintf(body, "</u:%s></s:Body></s:Envelope>", action)
+
+	req, err := http.NewRequest(http.MethodPost, controlURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, serviceType, action))
+
+	httpRes, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = httpRes.Body.Close()
+	}()
+
+	resBody, err := ioutil.ReadAll(io.LimitReader(httpRes.Body, upnpMaxResponseLength))
+	if err != nil {
+		return err
+	}
+
+	if httpRes.StatusCode != http.StatusOK {
+		fault := struct {
+			Code        int    `xml:"Body>Fault>detail>UPnPError>errorCode"`
+			Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+		}{}
+		if xml.Unmarshal(resBody, &fault) == nil && fault.Code != 0 {
+			return &upnpError{code: fault.Code, description: fault.Description}
+		}
+		return fmt.Errorf("%w: %s status %s", errUPnPInvalidResponse, action, httpRes.Status)
+	}
+
+	if res == nil {
+		return nil
+	}
+	if err := xml.Unmarshal(resBody, res); err != nil {
+		return fmt.Errorf("%w: %v", errUPnPInvalidResponse, err)
+	}
+	return nil
+}
+
+// ssdpDiscover sends an SSDP M-SEARCH for the search target and returns the location
+// of the first device that answers
+func ssdpDiscover(searchTarget string, timeout time.Duration) (string, error) {
+	addr, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddr)
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpMulticastAddr + "\r\n" +
+		"ST: " + searchTarget + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: " + strconv.Itoa(int(timeout/time.Second)) + "\r\n\r\n"
+	if _, err = conn.WriteTo([]byte(search), addr); err != nil {
+		return "", err
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return "", errUPnPNoGateway
+			}
+			return "", err
+		}
+
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		_ = res.Body.Close()
+		if location := res.Header.Get("Location"); location != "" && res.StatusCode == http.StatusOK {
+			return location, nil
+		}
+	}
+}
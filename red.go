@@ -0,0 +1,119 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// redDistance is the number of previous payloads sent as redundancy, like browsers
+	// do it is two, so two packets in a row can be lost
+	redDistance = 2
+
+	redMaxTimestampOffset = 1<<14 - 1
+	redMaxBlockLength     = 1<<10 - 1
+)
+
+// redEncoder wraps the packets of a codec in RED, RFC 2198. Every packet carries
+// the payloads of the previous packets as redundant blocks.
+type redEncoder struct {
+	mu                 sync.Mutex
+	payloadType        uint8
+	primaryPayloadType uint8
+	history            []redHistoryEntry
+}
+
+type redHistoryEntry struct {
+	payloadType uint8
+	timestamp   uint32
+	data        []byte
+}
+
+func newREDEncoder(payloadType, primaryPayloadType PayloadType) *redEncoder {
+	return &redEncoder{payloadType: uint8(payloadType), primaryPayloadType: uint8(primaryPayloadType)}
+}
+
+// encode returns the header and payload of the RED packet carrying the payload, the
+// header passed in isn't modified. Packets of other codecs are returned unchanged.
+func (e *redEncoder) encode(header *rtp.Header, payload []byte) (*rtp.Header, []byte, error) {
+	if header.PayloadType != e.primaryPayloadType {
+		return header, payload, nil
+	}
+
+	payload, err := stripPadding(header, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// The receiver maps the blocks to the packets immediately preceding this one, so
+	// redundancy stops at the first payload too old or too large for a block header
+	redundant := []redHistoryEntry{}
+	for i := len(e.history) - 1; i >= 0; i-- {
+		entry := e.history[i]
+		if offset := header.Timestamp - entry.timestamp; offset == 0 || offset > redMaxTimestampOffset || len(entry.data) > redMaxBlockLength {
+			break
+		}
+		redundant = append([]redHistoryEntry{entry}, redundant...)
+	}
+
+	size := len(redundant)*4 + 1 + len(payload)
+	for _, entry := range redundant {
+		size += len(entry.data)
+	}
+
+	out := make([]byte, 0, size)
+	for _, entry := range redundant {
+		block := 0x80000000 | uint32(entry.payloadType)<<24 | (header.Timestamp-entry.timestamp)<<10 | uint32(len(entry.data))
+		out = append(out, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(out[len(out)-4:], block)
+	}
+	out = append(out, header.PayloadType&rtpPayloadTypeBitmask)
+	for _, entry := range redundant {
+		out = append(out, entry.data...)
+	}
+	out = append(out, payload...)
+
+	e.history = append(e.history, redHistoryEntry{
+		payloadType: header.PayloadType,
+		timestamp:   header.Timestamp,
+		data:        append([]byte{}, payload...),
+	})
+	if len(e.history) > redDistance {
+		e.history = e.history[1:]
+	}
+
+	h := *header
+	h.PayloadType = e.payloadType
+	h.Padding = false
+	return &h, out, nil
+}
+
+// audioREDPayloadType returns the payload type of the audio RED codec that carries the
+// codec with the payload type primary, the fmtp line of RED lists it for every block
+func audioREDPayloadType(codecs []RTPCodecParameters, primary PayloadType) (PayloadType, bool) {
+	for _, codec := range codecs {
+		if !strings.EqualFold(codec.MimeType, MimeTypeAudioRED) || codec.SDPFmtpLine == "" {
+			continue
+		}
+
+		matches := true
+		for _, payloadType := range strings.Split(codec.SDPFmtpLine, "/") {
+			if strings.TrimSpace(payloadType) != strconv.Itoa(int(primary)) {
+				matches = false
+			}
+		}
+		if matches {
+			return codec.PayloadType, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,133 @@
+// +build !js
+
+package webrtc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREDEncoder(t *testing.T) {
+	encoder := newREDEncoder(63, 111)
+
+	encode := func(timestamp uint32, payload []byte) []redBlock {
+		header, data, err := encoder.encode(&rtp.Header{PayloadType: 111, Timestamp: timestamp}, payload)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(63), header.PayloadType)
+
+		blocks, err := parseRED(data)
+		assert.NoError(t, err)
+		return blocks
+	}
+
+	assert.Equal(t, []redBlock{{payloadType: 111, data: []byte{0x01}}}, encode(0, []byte{0x01}))
+	assert.Equal(t, []redBlock{
+		{payloadType: 111, timestampOffset: 960, data: []byte{0x01}},
+		{payloadType: 111, data: []byte{0x02}},
+	}, encode(960, []byte{0x02}))
+	assert.Equal(t, []redBlock{
+		{payloadType: 111, timestampOffset: 1920, data: []byte{0x01}},
+		{payloadType: 111, timestampOffset: 960, data: []byte{0x02}},
+		{payloadType: 111, data: []byte{0x03}},
+	}, encode(1920, []byte{0x03}))
+
+	// Only the last two payloads are sent again
+	assert.Equal(t, []redBlock{
+		{payloadType: 111, timestampOffset: 1440, data: []byte{0x02}},
+		{payloadType: 111, timestampOffset: 480, data: []byte{0x03}},
+		{payloadType: 111, data: []byte{0x04}},
+	}, encode(2400, []byte{0x04}))
+
+	// Payloads with a timestamp offset that doesn't fit into a block header are skipped
+	assert.Equal(t, []redBlock{{payloadType: 111, data: []byte{0x05}}}, encode(2400+1<<14, []byte{0x05}))
+
+	// Packets of other codecs aren't wrapped
+	header, data, err := encoder.encode(&rtp.Header{PayloadType: 0}, []byte{0x06})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(0), header.PayloadType)
+	assert.Equal(t, []byte{0x06}, data)
+}
+
+func TestAudioREDPayloadType(t *testing.T) {
+	codecs := []RTPCodecParameters{
+		{RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeOpus}, PayloadType: 111},
+		{RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeAudioRED, SDPFmtpLine: "0/0"}, PayloadType: 62},
+		{RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeAudioRED, SDPFmtpLine: "111/111"}, PayloadType: 63},
+	}
+
+	payloadType, ok := audioREDPayloadType(codecs, 111)
+	assert.True(t, ok)
+	assert.Equal(t, PayloadType(63), payloadType)
+
+	_, ok = audioREDPayloadType(codecs, 9)
+	assert.False(t, ok)
+}
+
+func TestPeerConnection_AudioRED(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	}, RTPCodecTypeAudio))
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeAudioRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"},
+		PayloadType:        63,
+	}, RTPCodecTypeAudio))
+
+	// The packets sent are wrapped in RED
+	var sentRED atomicBool
+	s := SettingEngine{}
+	s.SetPacketTap(func(direction PacketTapDirection, isRTCP bool, packet []byte) {
+		if direction == PacketTapDirectionOutbound && !isRTCP && len(packet) > 1 && packet[1]&rtpPayloadTypeBitmask == 63 {
+			sentRED.set(true)
+		}
+	})
+
+	pcOffer, pcAnswer, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeOpus}, "audio", "pion")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	// The RED encapsulation is removed before the packets are read
+	trackRead, trackReadCancel := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, r *RTPReceiver) {
+		pkt, _, readErr := trackRemote.ReadRTP()
+		assert.NoError(t, readErr)
+		assert.Equal(t, uint8(111), pkt.PayloadType)
+		assert.Equal(t, []byte{0xAA}, pkt.Payload)
+		assert.Equal(t, MimeTypeOpus, trackRemote.Codec().MimeType)
+		trackReadCancel()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-trackRead.Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0xAA}, Duration: 20 * time.Millisecond}))
+			}
+		}
+	}()
+	assert.True(t, sentRED.get())
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
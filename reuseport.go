@@ -0,0 +1,33 @@
+// +build linux darwin
+
+package webrtc
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenUDPReusePort opens a UDP socket with SO_REUSEPORT, other sockets with the
+// option set can be bound to the same address
+func listenUDPReusePort(network string, address *net.UDPAddr) (*net.UDPConn, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var optErr error
+			if err := c.Control(func(fd uintptr) {
+				optErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}); err != nil {
+				return err
+			}
+			return optErr
+		},
+	}
+
+	conn, err := config.ListenPacket(context.Background(), network, address.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
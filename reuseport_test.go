@@ -0,0 +1,46 @@
+// +build linux darwin
+
+package webrtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pion/logging"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestSettingEngine_SetBufferSizes(t *testing.T) {
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	mux, err := NewICEUDPMuxSharded(loggerFactory.NewLogger("test"), "udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, 2)
+	assert.NoError(t, err)
+
+	const bufferSize = 1 << 16
+
+	s := SettingEngine{}
+	s.SetICEUDPMux(mux)
+	s.SetReceiveBufferSize(bufferSize)
+	s.SetSendBufferSize(bufferSize)
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+	_, err = gatherer.GetLocalParameters()
+	assert.NoError(t, err)
+
+	// Linux reports twice the size that was set, it reserves space for its bookkeeping
+	for _, conn := range mux.(*shardedUDPMux).udpConns {
+		rawConn, err := conn.SyscallConn()
+		assert.NoError(t, err)
+		assert.NoError(t, rawConn.Control(func(fd uintptr) {
+			for _, opt := range []int{unix.SO_RCVBUF, unix.SO_SNDBUF} {
+				size, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, opt)
+				assert.NoError(t, err)
+				assert.GreaterOrEqual(t, size, bufferSize)
+			}
+		}))
+	}
+
+	assert.NoError(t, gatherer.Close())
+	assert.NoError(t, mux.Close())
+}
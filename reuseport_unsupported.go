@@ -0,0 +1,11 @@
+// +build !linux,!darwin
+
+package webrtc
+
+import (
+	"net"
+)
+
+func listenUDPReusePort(string, *net.UDPAddr) (*net.UDPConn, error) {
+	return nil, errReusePortUnsupported
+}
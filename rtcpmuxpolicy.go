@@ -13,11 +13,16 @@ const (
 	// RTP and RTCP candidates. If the remote-endpoint is capable of
 	// multiplexing RTCP, multiplex RTCP on the RTP candidates. If it is not,
 	// use both the RTP and RTCP candidates separately.
+	//
+	// Pion doesn't gather RTCP candidates, so remote descriptions without
+	// rtcp-mux fail with ErrRTCPMuxRequired like with RTCPMuxPolicyRequire.
 	RTCPMuxPolicyNegotiate RTCPMuxPolicy = iota + 1
 
 	// RTCPMuxPolicyRequire indicates to gather ICE candidates only for
 	// RTP and multiplex RTCP on the RTP candidates. If the remote endpoint is
-	// not capable of rtcp-mux, session negotiation will fail.
+	// not capable of rtcp-mux, session negotiation will fail. Initial offers
+	// mark their media sections as rtcp-mux-only with
+	// SettingEngine.EnableRTCPMuxOnly.
 	RTCPMuxPolicyRequire
 )
 
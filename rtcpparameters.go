@@ -0,0 +1,12 @@
+package webrtc
+
+// RTCPParameters provides information about the RTCP of a RTPSender or RTPReceiver
+//
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtcpparameters
+type RTCPParameters struct {
+	// CName is the canonical name used in RTCP, it is the StreamID of the track sent
+	CName string `json:"cname"`
+
+	// ReducedSize is true if reduced size RTCP is used, RFC 5506
+	ReducedSize bool `json:"reducedSize"`
+}
@@ -83,6 +83,7 @@ type RTPCodecParameters struct {
 type RTPParameters struct {
 	HeaderExtensions []RTPHeaderExtensionParameter
 	Codecs           []RTPCodecParameters
+	RTCP             RTCPParameters
 }
 
 type codecMatchType int
@@ -99,20 +100,29 @@ const (
 func codecParametersFuzzySearch(needle RTPCodecParameters, haystack []RTPCodecParameters) (RTPCodecParameters, codecMatchType) {
 	needleFmtp := parseFmtp(needle.RTPCodecCapability.SDPFmtpLine)
 
-	// First attempt to match on MimeType + SDPFmtpLine
+	// First attempt to match on MimeType, ClockRate, Channels + SDPFmtpLine
 	for _, c := range haystack {
-		if strings.EqualFold(c.RTPCodecCapability.MimeType, needle.RTPCodecCapability.MimeType) &&
-			fmtpConsist(needleFmtp, parseFmtp(c.RTPCodecCapability.SDPFmtpLine)) {
+		if codecCapabilityMatch(c.RTPCodecCapability, needle.RTPCodecCapability) &&
+			fmtpConsist(needle.MimeType, needleFmtp, parseFmtp(c.RTPCodecCapability.SDPFmtpLine)) {
 			return c, codecMatchExact
 		}
 	}
 
-	// Fallback to just MimeType
+	// Fallback to just MimeType, ClockRate and Channels
 	for _, c := range haystack {
-		if strings.EqualFold(c.RTPCodecCapability.MimeType, needle.RTPCodecCapability.MimeType) {
+		if codecCapabilityMatch(c.RTPCodecCapability, needle.RTPCodecCapability) {
 			return c, codecMatchPartial
 		}
 	}
 
 	return RTPCodecParameters{}, codecMatchNone
 }
+
+// codecCapabilityMatch checks MimeType, ClockRate and Channels of two codecs. A
+// ClockRate or Channels of zero hasn't been set, e.g. the channels of a rtpmap
+// that leaves them out, and matches any value.
+func codecCapabilityMatch(a, b RTPCodecCapability) bool {
+	return strings.EqualFold(a.MimeType, b.MimeType) &&
+		(a.ClockRate == 0 || b.ClockRate == 0 || a.ClockRate == b.ClockRate) &&
+		(a.Channels == 0 || b.Channels == 0 || a.Channels == b.Channels)
+}
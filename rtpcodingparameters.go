@@ -7,4 +7,13 @@ type RTPCodingParameters struct {
 	RID         string      `json:"rid"`
 	SSRC        SSRC        `json:"ssrc"`
 	PayloadType PayloadType `json:"payloadType"`
+
+	// RTX is the retransmission stream of this encoding, declared with a=ssrc-group:FID
+	RTX RTPRtxParameters `json:"rtx"`
+}
+
+// RTPRtxParameters provides information about the RTX stream of an encoding, RFC 4588
+// http://draft.ortc.org/#dom-rtcrtprtxparameters
+type RTPRtxParameters struct {
+	SSRC SSRC `json:"ssrc"`
 }
@@ -5,4 +5,13 @@ package webrtc
 // http://draft.ortc.org/#dom-rtcrtpdecodingparameters
 type RTPDecodingParameters struct {
 	RTPCodingParameters
+
+	// FEC is the FlexFEC stream protecting this encoding, if any
+	FEC RTPFECParameters `json:"fec"`
+}
+
+// RTPFECParameters provides information about the FEC stream protecting an encoding
+// http://draft.ortc.org/#dom-rtcrtpfecparameters
+type RTPFECParameters struct {
+	SSRC SSRC `json:"ssrc"`
 }
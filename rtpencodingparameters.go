@@ -5,4 +5,16 @@ package webrtc
 // http://draft.ortc.org/#dom-rtcrtpencodingparameters
 type RTPEncodingParameters struct {
 	RTPCodingParameters
+
+	// Active is false if the encoding must not be sent, packets written while
+	// it is inactive are dropped
+	Active bool `json:"active"`
+
+	// MaxBitrate is the maximum bitrate in bits per second the application's
+	// encoder should use, 0 means unlimited
+	MaxBitrate uint64 `json:"maxBitrate"`
+
+	// ScaleResolutionDownBy is the factor the application's encoder should scale
+	// down the resolution of video by, it must be at least 1
+	ScaleResolutionDownBy float64 `json:"scaleResolutionDownBy"`
 }
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/interceptor"
 	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/srtp/v2"
 	"github.com/pion/webrtc/v3/internal/util"
 )
@@ -26,6 +28,12 @@ type trackStreams struct {
 
 	rtcpReadStream  *srtp.ReadStreamSRTCP
 	rtcpInterceptor interceptor.RTCPReader
+
+	fecReadStream *srtp.ReadStreamSRTP
+
+	// ready is set once the track has been announced with OnTrack, reading once
+	// its packets are read for the OnRTP handler
+	ready, reading bool
 }
 
 // RTPReceiver allows an application to inspect the receipt of a TrackRemote
@@ -33,13 +41,21 @@ type RTPReceiver struct {
 	kind      RTPCodecType
 	transport *DTLSTransport
 
-	tracks []trackStreams
+	tracks     []trackStreams
+	parameters RTPReceiveParameters
 
 	closed, received chan interface{}
 	mu               sync.RWMutex
 
 	tr *RTPTransceiver
 
+	payloadTransform  atomic.Value // PayloadTransform
+	onDTMFToneHandler atomic.Value // func(tone string, duration time.Duration)
+	onRTPHandler      atomic.Value // func(*rtp.Packet)
+
+	// routines are the goroutines reading the FEC streams
+	routines sync.WaitGroup
+
 	// A reference to the associated api object
 	api *API
 }
@@ -68,6 +84,122 @@ func (r *RTPReceiver) setRTPTransceiver(tr *RTPTransceiver) {
 	r.tr = tr
 }
 
+// SetPayloadTransform sets a transform that is applied to the payload of every RTP
+// packet read from the tracks of the RTPReceiver, after it has been decrypted with
+// SRTP and before RED and FEC are removed. Packets the transform returns an error for
+// are discarded. Setting nil removes the transform.
+func (r *RTPReceiver) SetPayloadTransform(f PayloadTransform) {
+	r.payloadTransform.Store(f)
+}
+
+// transformPayload transforms the payload of the packet in b[:n] in place and
+// returns the new length of the packet
+func (r *RTPReceiver) transformPayload(b []byte, n int) (int, error) {
+	transform, ok := r.payloadTransform.Load().(PayloadTransform)
+	if !ok || transform == nil {
+		return n, nil
+	}
+
+	header := &rtp.Header{}
+	if err := header.Unmarshal(b[:n]); err != nil {
+		return 0, err
+	}
+
+	payload, err := stripPadding(header, b[header.PayloadOffset:n])
+	if err != nil {
+		return 0, err
+	}
+	header.Padding = false
+
+	if payload, err = transform(header, payload); err != nil {
+		return 0, err
+	}
+	if header.PayloadOffset+len(payload) > len(b) {
+		return 0, io.ErrShortBuffer
+	}
+
+	b[0] &^= 0x20
+	return header.PayloadOffset + copy(b[header.PayloadOffset:], payload), nil
+}
+
+// OnRTP sets a handler that is called with every RTP packet received on the tracks
+// of the RTPReceiver, as an alternative to a ReadRTP loop per track. The RTPReceiver
+// reads each track in its own goroutine once the track has been announced with
+// PeerConnection.OnTrack, until it is stopped. The tracks must not be read by the
+// application then. The packets of simulcast layers are delivered concurrently and
+// are told apart by their SSRC, the handler must not block. Setting nil discards
+// the packets of the tracks that are read already.
+func (r *RTPReceiver) OnRTP(f func(*rtp.Packet)) {
+	r.onRTPHandler.Store(f)
+	if f == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tracks {
+		if r.tracks[i].ready {
+			r.startReadLoop(&r.tracks[i])
+		}
+	}
+}
+
+// trackReady is called once the track has been announced, the OnRTP handler gets
+// its packets from then on. It returns true if the track is read for the handler.
+func (r *RTPReceiver) trackReady(t *TrackRemote) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	streams := r.streamsForTrack(t)
+	if streams == nil {
+		return false
+	}
+
+	streams.ready = true
+	if handler, ok := r.onRTPHandler.Load().(func(*rtp.Packet)); ok && handler != nil {
+		r.startReadLoop(streams)
+	}
+	return streams.reading
+}
+
+// startReadLoop reads the track of the streams for the OnRTP handler, r.mu must be held
+func (r *RTPReceiver) startReadLoop(streams *trackStreams) {
+	select {
+	case <-r.closed:
+		return
+	default:
+	}
+
+	if streams.reading {
+		return
+	}
+	streams.reading = true
+
+	r.routines.Add(1)
+	go func(track *TrackRemote) {
+		defer r.routines.Done()
+
+		for {
+			// The packet belongs to the handler, so every packet gets its own buffer
+			b := make([]byte, receiveMTU)
+			n, _, err := track.Read(b)
+			if err != nil {
+				return
+			}
+
+			pkt := &rtp.Packet{}
+			if err := pkt.Unmarshal(b[:n]); err != nil {
+				continue
+			}
+
+			if handler, ok := r.onRTPHandler.Load().(func(*rtp.Packet)); ok && handler != nil {
+				handler(pkt)
+			}
+		}
+	}(streams.track)
+}
+
 // Transport returns the currently-configured *DTLSTransport or nil
 // if one has not yet been configured
 func (r *RTPReceiver) Transport() *DTLSTransport {
@@ -81,17 +213,42 @@ func (r *RTPReceiver) getParameters() RTPParameters {
 	if r.tr != nil {
 		parameters.Codecs = r.tr.getCodecs()
 	}
+	parameters.RTCP.ReducedSize = true
 	return parameters
 }
 
 // GetParameters describes the current configuration for the encoding and
-// transmission of media on the receiver's track.
+// transmission of media on the receiver's track. Once negotiated the codecs
+// and header extensions are the negotiated ones, the SSRCs of the encodings
+// received are returned by GetReceiveParameters.
 func (r *RTPReceiver) GetParameters() RTPParameters {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	return r.getParameters()
 }
 
+// GetReceiveParameters returns the encodings the RTPReceiver receives, with the SSRCs
+// of their RTX and FEC streams as declared by the remote with a=ssrc-group. Encodings
+// identified by RID only have a SSRC once their first packet has been received.
+func (r *RTPReceiver) GetReceiveParameters() RTPReceiveParameters {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	parameters := RTPReceiveParameters{Encodings: append([]RTPDecodingParameters{}, r.parameters.Encodings...)}
+	for i := range parameters.Encodings {
+		for _, t := range r.tracks {
+			encoding := &parameters.Encodings[i]
+			if t.track == nil || t.track.RID() != encoding.RID || (encoding.SSRC != 0 && t.track.SSRC() != encoding.SSRC) {
+				continue
+			}
+			encoding.SSRC = t.track.SSRC()
+			encoding.PayloadType = t.track.PayloadType()
+			break
+		}
+	}
+	return parameters
+}
+
 // Track returns the RtpTransceiver TrackRemote
 func (r *RTPReceiver) Track() *TrackRemote {
 	r.mu.RLock()
@@ -127,29 +284,46 @@ func (r *RTPReceiver) Receive(parameters RTPReceiveParameters) error {
 	}
 	defer close(r.received)
 
-	if len(parameters.Encodings) == 1 && parameters.Encodings[0].SSRC != 0 {
-		t := trackStreams{
-			track: newTrackRemote(
-				r.kind,
-				parameters.Encodings[0].SSRC,
-				parameters.Encodings[0].RID,
-				r,
-			),
-		}
+	r.parameters = RTPReceiveParameters{Encodings: append([]RTPDecodingParameters{}, parameters.Encodings...)}
 
+	// Simulcast layers declared with a=ssrc-group:SIM are known upfront, layers
+	// identified by RID only once their first packet arrives
+	hasSSRCs := len(parameters.Encodings) != 0
+	for _, encoding := range parameters.Encodings {
+		hasSSRCs = hasSSRCs && encoding.SSRC != 0
+	}
+
+	if hasSSRCs {
 		globalParams := r.getParameters()
 		codec := RTPCodecCapability{}
 		if len(globalParams.Codecs) != 0 {
 			codec = globalParams.Codecs[0].RTPCodecCapability
 		}
 
-		t.streamInfo = createStreamInfo("", parameters.Encodings[0].SSRC, 0, codec, globalParams.HeaderExtensions)
-		var err error
-		if t.rtpReadStream, t.rtpInterceptor, t.rtcpReadStream, t.rtcpInterceptor, err = r.streamsForSSRC(parameters.Encodings[0].SSRC, t.streamInfo); err != nil {
-			return err
-		}
+		for _, encoding := range parameters.Encodings {
+			t := trackStreams{
+				track: newTrackRemote(
+					r.kind,
+					encoding.SSRC,
+					encoding.RID,
+					r,
+				),
+			}
+
+			t.streamInfo = createStreamInfo("", encoding.SSRC, 0, codec, globalParams.HeaderExtensions)
+			var err error
+			if t.rtpReadStream, t.rtpInterceptor, t.rtcpReadStream, t.rtcpInterceptor, err = r.streamsForSSRC(encoding.SSRC, t.streamInfo, t.track); err != nil {
+				return err
+			}
 
-		r.tracks = append(r.tracks, t)
+			if fecSSRC := encoding.FEC.SSRC; fecSSRC != 0 {
+				if t.fecReadStream, err = r.fecStreamForSSRC(fecSSRC, t.track); err != nil {
+					return err
+				}
+			}
+
+			r.tracks = append(r.tracks, t)
+		}
 	} else {
 		for _, encoding := range parameters.Encodings {
 			r.tracks = append(r.tracks, trackStreams{
@@ -220,6 +394,44 @@ func (r *RTPReceiver) ReadSimulcastRTCP(rid string) ([]rtcp.Packet, interceptor.
 	return pkts, attributes, err
 }
 
+// discardEarlyPackets discards the packets a stream received before it was opened
+// with EarlyMediaHandlingDrop
+func (r *RTPReceiver) discardEarlyPackets(stream *srtp.ReadStreamSRTP) {
+	if r.api.settingEngine.earlyMediaHandling != EarlyMediaHandlingDrop {
+		return
+	}
+
+	if err := stream.SetReadDeadline(time.Now().Add(earlyMediaDrainTimeout)); err != nil {
+		return
+	}
+	b := make([]byte, receiveMTU)
+	for {
+		if _, err := stream.Read(b); err != nil {
+			break
+		}
+	}
+	_ = stream.SetReadDeadline(time.Time{})
+}
+
+// receivesSSRC returns true if the receiver receives the SSRC, for a track or
+// for the repair of one
+func (r *RTPReceiver) receivesSSRC(ssrc SSRC) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, encoding := range r.parameters.Encodings {
+		if encoding.SSRC == ssrc || encoding.RTX.SSRC == ssrc || encoding.FEC.SSRC == ssrc {
+			return true
+		}
+	}
+	for i := range r.tracks {
+		if r.tracks[i].track.SSRC() == ssrc {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *RTPReceiver) haveReceived() bool {
 	select {
 	case <-r.received:
@@ -254,8 +466,14 @@ func (r *RTPReceiver) Stop() error {
 				errs = append(errs, r.tracks[i].rtpReadStream.Close())
 			}
 
+			if r.tracks[i].fecReadStream != nil {
+				errs = append(errs, r.tracks[i].fecReadStream.Close())
+			}
+
 			err = util.FlattenErrs(errs)
 			r.api.interceptor.UnbindRemoteStream(&r.tracks[i].streamInfo)
+			r.transport.removeRemoteTrack(r.tracks[i].track)
+			r.tracks[i].track.stopMuteTimer()
 		}
 	default:
 	}
@@ -300,7 +518,7 @@ func (r *RTPReceiver) receiveForRid(rid string, params RTPParameters, ssrc SSRC)
 			r.tracks[i].track.mu.Unlock()
 
 			var err error
-			if r.tracks[i].rtpReadStream, r.tracks[i].rtpInterceptor, r.tracks[i].rtcpReadStream, r.tracks[i].rtcpInterceptor, err = r.streamsForSSRC(ssrc, r.tracks[i].streamInfo); err != nil {
+			if r.tracks[i].rtpReadStream, r.tracks[i].rtpInterceptor, r.tracks[i].rtcpReadStream, r.tracks[i].rtcpInterceptor, err = r.streamsForSSRC(ssrc, r.tracks[i].streamInfo, r.tracks[i].track); err != nil {
 				return nil, err
 			}
 
@@ -311,7 +529,7 @@ func (r *RTPReceiver) receiveForRid(rid string, params RTPParameters, ssrc SSRC)
 	return nil, fmt.Errorf("%w: %d", errRTPReceiverForSSRCTrackStreamNotFound, ssrc)
 }
 
-func (r *RTPReceiver) streamsForSSRC(ssrc SSRC, streamInfo interceptor.StreamInfo) (*srtp.ReadStreamSRTP, interceptor.RTPReader, *srtp.ReadStreamSRTCP, interceptor.RTCPReader, error) {
+func (r *RTPReceiver) streamsForSSRC(ssrc SSRC, streamInfo interceptor.StreamInfo, track *TrackRemote) (*srtp.ReadStreamSRTP, interceptor.RTPReader, *srtp.ReadStreamSRTCP, interceptor.RTCPReader, error) {
 	srtpSession, err := r.transport.getSRTPSession()
 	if err != nil {
 		return nil, nil, nil, nil, err
@@ -321,9 +539,19 @@ func (r *RTPReceiver) streamsForSSRC(ssrc SSRC, streamInfo interceptor.StreamInf
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
+	r.discardEarlyPackets(rtpReadStream)
+	r.transport.addRemoteTrack(ssrc, track)
 
 	rtpInterceptor := r.api.interceptor.BindRemoteStream(&streamInfo, interceptor.RTPReaderFunc(func(in []byte, a interceptor.Attributes) (n int, attributes interceptor.Attributes, err error) {
-		n, err = rtpReadStream.Read(in)
+		n, ok, err := track.readEarly(in)
+		if !ok {
+			if n, err = rtpReadStream.Read(in); err == nil {
+				r.api.settingEngine.tapPacket(PacketTapDirectionInbound, false, in[:n])
+			}
+		}
+		if err == nil {
+			track.updateStats(in[:n])
+		}
 		return n, a, err
 	}))
 
@@ -339,12 +567,68 @@ func (r *RTPReceiver) streamsForSSRC(ssrc SSRC, streamInfo interceptor.StreamInf
 
 	rtcpInterceptor := r.api.interceptor.BindRTCPReader(interceptor.RTPReaderFunc(func(in []byte, a interceptor.Attributes) (n int, attributes interceptor.Attributes, err error) {
 		n, err = rtcpReadStream.Read(in)
+		if err == nil {
+			r.api.settingEngine.tapPacket(PacketTapDirectionInbound, true, in[:n])
+			track.handleRTCP(in[:n])
+		}
 		return n, a, err
 	}))
 
 	return rtpReadStream, rtpInterceptor, rtcpReadStream, rtcpInterceptor, nil
 }
 
+// fecStreamForSSRC opens the FlexFEC stream protecting track and passes its
+// packets to the FEC decoder of the track until the stream is closed
+func (r *RTPReceiver) fecStreamForSSRC(ssrc SSRC, track *TrackRemote) (*srtp.ReadStreamSRTP, error) {
+	srtpSession, err := r.transport.getSRTPSession()
+	if err != nil {
+		return nil, err
+	}
+
+	fecReadStream, err := srtpSession.OpenReadStream(uint32(ssrc))
+	if err != nil {
+		return nil, err
+	}
+	r.discardEarlyPackets(fecReadStream)
+
+	fec := track.getFECDecoder(true)
+	r.routines.Add(1)
+	go func() {
+		defer r.routines.Done()
+
+		b := make([]byte, receiveMTU)
+		header := &rtp.Header{}
+		for {
+			n, readErr := fecReadStream.Read(b)
+			if readErr != nil {
+				return
+			}
+			r.api.settingEngine.tapPacket(PacketTapDirectionInbound, false, b[:n])
+
+			if header.Unmarshal(b[:n]) == nil {
+				fec.addFlexFEC(uint32(track.SSRC()), b[header.PayloadOffset:n])
+			}
+		}
+	}()
+
+	return fecReadStream, nil
+}
+
+func (r *RTPReceiver) collectStats(collector *statsReportCollector) {
+	if !r.haveReceived() {
+		return
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.tracks {
+		if t.track.SSRC() != 0 {
+			t.track.collectStats(collector)
+		}
+	}
+}
+
 // SetReadDeadline sets the max amount of time the RTCP stream will block before returning. 0 is forever.
 func (r *RTPReceiver) SetReadDeadline(t time.Time) error {
 	r.mu.RLock()
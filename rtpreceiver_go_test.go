@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
 	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/stretchr/testify/assert"
 )
@@ -65,3 +67,90 @@ func TestSetRTPParameters(t *testing.T) {
 	assert.NoError(t, wan.Stop())
 	closePairNow(t, sender, receiver)
 }
+
+func TestRTPReceiver_GetReceiveParameters(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTransceiverFromTrack(track, RTPTransceiverInit{
+		Direction: RTPTransceiverDirectionSendonly,
+		SendEncodings: []RTPEncodingParameters{{
+			RTPCodingParameters: RTPCodingParameters{SSRC: 1234, RTX: RTPRtxParameters{SSRC: 5678}},
+		}},
+	})
+	assert.NoError(t, err)
+
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=ssrc-group:FID 1234 5678\r\n")
+	assert.Contains(t, offer.SDP, "a=ssrc:5678 ")
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, receiver *RTPReceiver) {
+		assert.Equal(t, SSRC(1234), trackRemote.SSRC())
+
+		parameters := receiver.GetReceiveParameters()
+		if assert.Len(t, parameters.Encodings, 1) {
+			assert.Equal(t, SSRC(1234), parameters.Encodings[0].SSRC)
+			assert.Equal(t, SSRC(5678), parameters.Encodings[0].RTX.SSRC)
+			assert.Equal(t, trackRemote.PayloadType(), parameters.Encodings[0].PayloadType)
+		}
+		onTrackFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	sendVideoUntilDone(onTrackFired.Done(), t, []*TrackLocalStaticSample{track})
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestRTPReceiver_OnRTP(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	// The handler is set before the track arrives, no OnTrack and no read loop are needed
+	transceiver, err := pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+	assert.NoError(t, err)
+
+	received := make(chan *rtp.Packet, 64)
+	transceiver.Receiver().OnRTP(func(pkt *rtp.Packet) {
+		select {
+		case received <- pkt:
+		default:
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go sendVideoUntilDone(ctx.Done(), t, []*TrackLocalStaticSample{track})
+
+	pkt := <-received
+	cancel()
+	assert.Equal(t, []byte{0x00}, pkt.Payload[len(pkt.Payload)-1:])
+	assert.Equal(t, uint32(transceiver.Receiver().Track().SSRC()), pkt.SSRC)
+
+	// The read loop ends with the RTPReceiver
+	closePairNow(t, pcOffer, pcAnswer)
+}
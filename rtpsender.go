@@ -3,30 +3,105 @@
 package webrtc
 
 import (
+	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/interceptor"
 	"github.com/pion/randutil"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3/internal/util"
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
+	"github.com/pion/webrtc/v3/pkg/rtcpxr"
 )
 
-// RTPSender allows an application to control how a given Track is encoded and transmitted to a remote peer
-type RTPSender struct {
+// trackLocalWithRID is implemented by tracks that are a simulcast layer
+type trackLocalWithRID interface {
+	RID() string
+}
+
+func trackRID(track TrackLocal) string {
+	if t, ok := track.(trackLocalWithRID); ok {
+		return t.RID()
+	}
+	return ""
+}
+
+// trackEncoding is an encoding of a RTPSender, every simulcast layer has its own
+// track, SSRC and streams
+type trackEncoding struct {
 	track TrackLocal
+	rid   string
+	ssrc  SSRC
+
+	// rtxSSRC is declared as RTX stream of ssrc with a=ssrc-group:FID, it is only
+	// set if the application sends RTX itself
+	rtxSSRC SSRC
 
 	srtpStream      *srtpWriterFuture
+	rtpInterceptor  interceptor.RTPWriter
 	rtcpInterceptor interceptor.RTCPReader
 	streamInfo      interceptor.StreamInfo
 
 	context TrackLocalContext
 
+	// red wraps the packets in audio RED if it has been negotiated for the codec
+	red *redEncoder
+
+	// Encoding parameters changed with SetParameters, packets are dropped
+	// while inactive
+	inactive              atomicBool
+	maxBitrate            uint64
+	scaleResolutionDownBy float64
+
+	statsID string
+	stats   struct {
+		mu                       sync.Mutex
+		packetsSent              uint32
+		bytesSent                uint64
+		headerBytesSent          uint64
+		retransmittedPacketsSent uint64
+		retransmittedBytesSent   uint64
+		lastPacketSentTimestamp  time.Time
+		firCount                 uint32
+		pliCount                 uint32
+		nackCount                uint32
+
+		// keyFramesSent counts the packets that start a keyframe, packets of
+		// the same frame share the timestamp of the last one
+		keyFramesSent         uint32
+		lastKeyFrameTimestamp uint32
+
+		// highestSequenceNumber is the newest packet sent, packets that aren't
+		// newer than it are retransmissions
+		highestSequenceNumber uint16
+
+		// voipMetrics is the last report of the remote about the stream in a RTCP XR
+		voipMetrics *rtcpxr.VoIPMetricsReportBlock
+	}
+}
+
+// RTPSender allows an application to control how a given Track is encoded and transmitted to a remote peer
+type RTPSender struct {
+	trackEncodings []*trackEncoding
+
 	transport *DTLSTransport
 
 	payloadType PayloadType
-	ssrc        SSRC
+
+	transactionID string
+
+	// targetBitrate is the minimum of the MaxBitrate of the encodings and the last REMB received
+	targetBitrate struct {
+		mu     sync.Mutex
+		remb   uint64
+		target uint64
+	}
+	onTargetBitrateChangeHandler atomic.Value // func(uint64)
 
 	// nolint:godox
 	// TODO(sgotti) remove this when in future we'll avoid replacing
@@ -40,8 +115,22 @@ type RTPSender struct {
 
 	tr *RTPTransceiver
 
+	onRTCPHandler atomic.Value // func([]rtcp.Packet, interceptor.Attributes)
+
+	payloadTransform atomic.Value // PayloadTransform
+
+	// dtmf sends DTMF tones with audio tracks
+	dtmf *DTMFSender
+
 	mu                     sync.RWMutex
 	sendCalled, stopCalled chan struct{}
+
+	// readingRTCP is set once OnRTCP started the RTCP read loops, the loops of
+	// encodings added later are started by addEncoding
+	readingRTCP bool
+
+	// routines are the RTCP read loops started by OnRTCP
+	routines sync.WaitGroup
 }
 
 // NewRTPSender constructs a new RTPSender
@@ -58,24 +147,89 @@ func (api *API) NewRTPSender(track TrackLocal, transport *DTLSTransport) (*RTPSe
 	}
 
 	r := &RTPSender{
-		track:      track,
 		transport:  transport,
 		api:        api,
 		sendCalled: make(chan struct{}),
 		stopCalled: make(chan struct{}),
-		ssrc:       SSRC(randutil.NewMathRandomGenerator().Uint32()),
 		id:         id,
-		srtpStream: &srtpWriterFuture{},
 	}
+	r.addEncoding(track)
+	if track.Kind() == RTPCodecTypeAudio {
+		r.dtmf = newDTMFSender(r)
+	}
+
+	return r, nil
+}
+
+func (r *RTPSender) addEncoding(track TrackLocal) {
+	encoding := &trackEncoding{
+		track:      track,
+		rid:        trackRID(track),
+		ssrc:       SSRC(randutil.NewMathRandomGenerator().Uint32()),
+		statsID:    fmt.Sprintf("RTPSender-%d", time.Now().UnixNano()),
+		srtpStream: &srtpWriterFuture{rtpSender: r},
+	}
+	encoding.srtpStream.ssrc = encoding.ssrc
 
-	r.srtpStream.rtpSender = r
+	// Resolution isn't scaled by default, it doesn't apply to audio
+	if track.Kind() == RTPCodecTypeVideo {
+		encoding.scaleResolutionDownBy = 1
+	}
 
-	r.rtcpInterceptor = r.api.interceptor.BindRTCPReader(interceptor.RTPReaderFunc(func(in []byte, a interceptor.Attributes) (n int, attributes interceptor.Attributes, err error) {
-		n, err = r.srtpStream.Read(in)
+	encoding.rtcpInterceptor = r.api.interceptor.BindRTCPReader(interceptor.RTPReaderFunc(func(in []byte, a interceptor.Attributes) (n int, attributes interceptor.Attributes, err error) {
+		n, err = encoding.srtpStream.Read(in)
+		if err == nil {
+			r.api.settingEngine.tapPacket(PacketTapDirectionInbound, true, in[:n])
+			r.handleFeedback(encoding, in[:n])
+		}
 		return n, a, err
 	}))
 
-	return r, nil
+	r.trackEncodings = append(r.trackEncodings, encoding)
+	if r.readingRTCP {
+		r.routines.Add(1)
+		go r.readRTCPLoop(encoding)
+	}
+}
+
+// AddEncoding adds a simulcast layer to the RTPSender, it is sent with its own SSRC
+// and the RID of the track. The track must have the same ID, StreamID and kind as the
+// track of the RTPSender, and both must have a RID, see WithRTPStreamID.
+//
+// Encodings have to be added before the RTPSender is negotiated. They are offered with
+// a=simulcast, the receiver tells them apart by the mid and rtp-stream-id header
+// extensions, which have to be registered with the MediaEngine.
+func (r *RTPSender) AddEncoding(track TrackLocal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if track == nil {
+		return errRTPSenderTrackNil
+	}
+	if r.hasSent() {
+		return errRTPSenderSendAlreadyCalled
+	}
+
+	base := r.trackEncodings[0].track
+	if base == nil {
+		return errRTPSenderTrackNil
+	}
+	if base.ID() != track.ID() || base.StreamID() != track.StreamID() || base.Kind() != track.Kind() {
+		return errRTPSenderEncodingTrackMismatch
+	}
+
+	rid := trackRID(track)
+	if rid == "" || r.trackEncodings[0].rid == "" {
+		return errRTPSenderRIDNil
+	}
+	for _, encoding := range r.trackEncodings {
+		if encoding.rid == rid {
+			return errRTPSenderRIDCollision
+		}
+	}
+
+	r.addEncoding(track)
+	return nil
 }
 
 func (r *RTPSender) isNegotiated() bool {
@@ -96,6 +250,27 @@ func (r *RTPSender) setRTPTransceiver(tr *RTPTransceiver) {
 	r.tr = tr
 }
 
+// setSendEncoding applies the SSRCs of an encoding passed with RTPTransceiverInit,
+// zero values keep the defaults
+func (r *RTPSender) setSendEncoding(parameters RTPEncodingParameters) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	encoding := r.trackEncodings[0]
+	if parameters.SSRC != 0 {
+		encoding.ssrc = parameters.SSRC
+		encoding.srtpStream.ssrc = parameters.SSRC
+	}
+	encoding.rtxSSRC = parameters.RTX.SSRC
+}
+
+// isSimulcast is true if the RTPSender has more than one encoding
+func (r *RTPSender) isSimulcast() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.trackEncodings) > 1
+}
+
 // Transport returns the currently-configured *DTLSTransport or nil
 // if one has not yet been configured
 func (r *RTPSender) Transport() *DTLSTransport {
@@ -105,66 +280,187 @@ func (r *RTPSender) Transport() *DTLSTransport {
 }
 
 func (r *RTPSender) getParameters() RTPSendParameters {
+	track := r.trackEncodings[0].track
 	sendParameters := RTPSendParameters{
 		RTPParameters: r.api.mediaEngine.getRTPParametersByKind(
-			r.track.Kind(),
+			track.Kind(),
 			[]RTPTransceiverDirection{RTPTransceiverDirectionSendonly},
 		),
-		Encodings: []RTPEncodingParameters{
-			{
-				RTPCodingParameters: RTPCodingParameters{
-					SSRC:        r.ssrc,
-					PayloadType: r.payloadType,
-				},
+	}
+	for _, encoding := range r.trackEncodings {
+		sendParameters.Encodings = append(sendParameters.Encodings, RTPEncodingParameters{
+			RTPCodingParameters: RTPCodingParameters{
+				RID:         encoding.rid,
+				SSRC:        encoding.ssrc,
+				PayloadType: r.payloadType,
+				RTX:         RTPRtxParameters{SSRC: encoding.rtxSSRC},
 			},
-		},
+			Active:                !encoding.inactive.get(),
+			MaxBitrate:            encoding.maxBitrate,
+			ScaleResolutionDownBy: encoding.scaleResolutionDownBy,
+		})
+	}
+	if r.tr != nil {
+		sendParameters.Codecs = r.tr.getCodecs()
 	}
-	sendParameters.Codecs = r.tr.getCodecs()
+
+	// Pion always negotiates rtcp-rsize and sends feedback like PLIs on its own
+	sendParameters.RTCP = RTCPParameters{CName: track.StreamID(), ReducedSize: true}
 	return sendParameters
 }
 
 // GetParameters describes the current configuration for the encoding and
-// transmission of media on the sender's track.
+// transmission of media on the sender's track. Once negotiated the codecs
+// and header extensions are the negotiated ones, and the encodings have the
+// payload type of the codec sent.
 func (r *RTPSender) GetParameters() RTPSendParameters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parameters := r.getParameters()
+	r.transactionID = util.MathRandAlpha(16)
+	parameters.TransactionID = r.transactionID
+	return parameters
+}
+
+// SetParameters changes the Active, MaxBitrate and ScaleResolutionDownBy of the
+// encodings at runtime. The parameters must be the ones returned by the last call
+// to GetParameters, with only these fields modified. Pion doesn't encode media, an
+// encoder of the application is expected to follow GetParameters and
+// OnTargetBitrateChange. Packets written while an encoding is inactive are dropped.
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtpsender-setparameters
+func (r *RTPSender) SetParameters(parameters RTPSendParameters) error {
+	r.mu.Lock()
+
+	if r.hasStopped() {
+		r.mu.Unlock()
+		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+
+	if r.transactionID == "" || parameters.TransactionID != r.transactionID {
+		r.mu.Unlock()
+		return &rtcerr.InvalidModificationError{Err: errRTPSenderTransactionIDMismatch}
+	}
+
+	if len(parameters.Encodings) != len(r.trackEncodings) {
+		r.mu.Unlock()
+		return &rtcerr.InvalidModificationError{Err: errRTPSenderEncodingsModified}
+	}
+	for i, encoding := range parameters.Encodings {
+		if encoding.SSRC != r.trackEncodings[i].ssrc || encoding.RID != r.trackEncodings[i].rid {
+			r.mu.Unlock()
+			return &rtcerr.InvalidModificationError{Err: errRTPSenderEncodingsModified}
+		}
+		if encoding.ScaleResolutionDownBy != 0 && encoding.ScaleResolutionDownBy < 1 {
+			r.mu.Unlock()
+			return &rtcerr.RangeError{Err: errRTPSenderScaleResolutionDownBy}
+		}
+	}
+
+	r.transactionID = ""
+	for i, encoding := range parameters.Encodings {
+		r.trackEncodings[i].inactive.set(!encoding.Active)
+		r.trackEncodings[i].maxBitrate = encoding.MaxBitrate
+		r.trackEncodings[i].scaleResolutionDownBy = encoding.ScaleResolutionDownBy
+	}
+	r.mu.Unlock()
+
+	r.updateTargetBitrate()
+	return nil
+}
+
+// OnTargetBitrateChange sets an event handler which is invoked when the bitrate the
+// encoder should target changes. It is the sum of the MaxBitrate of the active encodings
+// set with SetParameters or the bitrate estimated by the remote with REMB, whichever
+// is lower, 0 means neither is known. REMB is only handled while the RTCP of the
+// RTPSender is read.
+func (r *RTPSender) OnTargetBitrateChange(f func(bitrate uint64)) {
+	r.onTargetBitrateChangeHandler.Store(f)
+}
+
+func (r *RTPSender) updateTargetBitrate() {
+	// An active encoding without MaxBitrate makes the sum unlimited
+	var maxBitrate uint64
 	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.getParameters()
+	for _, encoding := range r.trackEncodings {
+		if encoding.inactive.get() {
+			continue
+		}
+		if encoding.maxBitrate == 0 {
+			maxBitrate = 0
+			break
+		}
+		maxBitrate += encoding.maxBitrate
+	}
+	r.mu.RUnlock()
+
+	r.targetBitrate.mu.Lock()
+	target := r.targetBitrate.remb
+	if maxBitrate != 0 && (target == 0 || maxBitrate < target) {
+		target = maxBitrate
+	}
+	changed := target != r.targetBitrate.target
+	r.targetBitrate.target = target
+	r.targetBitrate.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if handler, ok := r.onTargetBitrateChangeHandler.Load().(func(uint64)); ok && handler != nil {
+		handler(target)
+	}
 }
 
-// Track returns the RTCRtpTransceiver track, or nil
+// Track returns the RTCRtpTransceiver track, or nil. With simulcast it is the
+// track of the first encoding.
 func (r *RTPSender) Track() TrackLocal {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.track
+	if len(r.trackEncodings) == 0 {
+		return nil
+	}
+	return r.trackEncodings[0].track
 }
 
 // ReplaceTrack replaces the track currently being used as the sender's source with a new TrackLocal.
 // The new track must be of the same media kind (audio, video, etc) and switching the track should not
-// require negotiation.
+// require negotiation. The tracks of simulcast encodings can only be removed by passing nil.
 func (r *RTPSender) ReplaceTrack(track TrackLocal) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.hasSent() && r.track != nil {
-		if err := r.track.Unbind(r.context); err != nil {
-			return err
+	if track != nil && len(r.trackEncodings) > 1 {
+		return errRTPSenderReplaceTrackSimulcast
+	}
+
+	if r.hasSent() {
+		for _, encoding := range r.trackEncodings {
+			if encoding.track == nil {
+				continue
+			}
+			if err := encoding.track.Unbind(encoding.context); err != nil {
+				return err
+			}
 		}
 	}
 
 	if !r.hasSent() || track == nil {
-		r.track = track
+		for _, encoding := range r.trackEncodings {
+			encoding.track = track
+		}
 		return nil
 	}
 
+	encoding := r.trackEncodings[0]
 	codec, err := track.Bind(TrackLocalContext{
-		id:          r.context.id,
-		params:      r.api.mediaEngine.getRTPParametersByKind(r.track.Kind(), []RTPTransceiverDirection{RTPTransceiverDirectionSendonly}),
-		ssrc:        r.context.ssrc,
-		writeStream: r.context.writeStream,
+		id:          encoding.context.id,
+		params:      r.api.mediaEngine.getRTPParametersByKind(encoding.track.Kind(), []RTPTransceiverDirection{RTPTransceiverDirectionSendonly}),
+		ssrc:        encoding.context.ssrc,
+		writeStream: encoding.context.writeStream,
 	})
 	if err != nil {
 		// Re-bind the original track
-		if _, reBindErr := r.track.Bind(r.context); reBindErr != nil {
+		if _, reBindErr := encoding.track.Bind(encoding.context); reBindErr != nil {
 			return reBindErr
 		}
 
@@ -173,10 +469,11 @@ func (r *RTPSender) ReplaceTrack(track TrackLocal) error {
 
 	// Codec has changed
 	if r.payloadType != codec.PayloadType {
-		r.context.params.Codecs = []RTPCodecParameters{codec}
+		encoding.context.params.Codecs = []RTPCodecParameters{codec}
+		r.payloadType = codec.PayloadType
 	}
 
-	r.track = track
+	encoding.track = track
 	return nil
 }
 
@@ -189,30 +486,117 @@ func (r *RTPSender) Send(parameters RTPSendParameters) error {
 		return errRTPSenderSendAlreadyCalled
 	}
 
-	writeStream := &interceptorToTrackLocalWriter{}
-	r.context = TrackLocalContext{
-		id:          r.id,
-		params:      r.api.mediaEngine.getRTPParametersByKind(r.track.Kind(), []RTPTransceiverDirection{RTPTransceiverDirectionSendonly}),
-		ssrc:        parameters.Encodings[0].SSRC,
-		writeStream: writeStream,
+	// Simulcast layers are told apart by the mid and rtp-stream-id header extensions
+	var midExtensionID, ridExtensionID uint8
+	var mid string
+	if len(r.trackEncodings) > 1 {
+		for _, extension := range parameters.HeaderExtensions {
+			switch extension.URI {
+			case sdp.SDESMidURI:
+				midExtensionID = uint8(extension.ID)
+			case sdp.SDESRTPStreamIDURI:
+				ridExtensionID = uint8(extension.ID)
+			}
+		}
+		if midExtensionID == 0 {
+			return errPeerConnSimulcastMidRTPExtensionRequired
+		}
+		if ridExtensionID == 0 {
+			return errPeerConnSimulcastStreamIDRTPExtensionRequired
+		}
+		if r.tr != nil {
+			mid = r.tr.Mid()
+		}
 	}
 
-	codec, err := r.track.Bind(r.context)
-	if err != nil {
-		return err
-	}
-	r.context.params.Codecs = []RTPCodecParameters{codec}
+	for i, encoding := range r.trackEncodings {
+		ssrc := encoding.ssrc
+		if i < len(parameters.Encodings) {
+			ssrc = parameters.Encodings[i].SSRC
+		}
 
-	r.streamInfo = createStreamInfo(r.id, parameters.Encodings[0].SSRC, codec.PayloadType, codec.RTPCodecCapability, parameters.HeaderExtensions)
-	rtpInterceptor := r.api.interceptor.BindLocalStream(&r.streamInfo, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
-		return r.srtpStream.WriteRTP(header, payload)
-	}))
-	writeStream.interceptor.Store(rtpInterceptor)
+		writeStream := &interceptorToTrackLocalWriter{}
+		encoding.context = TrackLocalContext{
+			id:          r.id,
+			params:      r.api.mediaEngine.getRTPParametersByKind(encoding.track.Kind(), []RTPTransceiverDirection{RTPTransceiverDirectionSendonly}),
+			ssrc:        ssrc,
+			writeStream: writeStream,
+		}
+
+		codec, err := encoding.track.Bind(encoding.context)
+		if err != nil {
+			return err
+		}
+		if redPayloadType, ok := audioREDPayloadType(encoding.context.params.Codecs, codec.PayloadType); ok {
+			encoding.red = newREDEncoder(redPayloadType, codec.PayloadType)
+		}
+		if r.dtmf != nil && i == 0 {
+			r.dtmf.setCodec(encoding.context.params.Codecs, codec)
+		}
+		encoding.context.params.Codecs = []RTPCodecParameters{codec}
+		r.payloadType = codec.PayloadType
+
+		encoding.streamInfo = createStreamInfo(r.id, ssrc, codec.PayloadType, codec.RTPCodecCapability, parameters.HeaderExtensions)
+		encoding.rtpInterceptor = r.api.interceptor.BindLocalStream(&encoding.streamInfo, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+			n, err := encoding.srtpStream.WriteRTP(header, payload)
+			if err == nil && n > 0 {
+				encoding.updateStats(header, payload, isKeyFramePacket(codec.MimeType, payload))
+			}
+			return n, err
+		}))
+
+		rid := encoding.rid
+		rtpInterceptor := encoding.rtpInterceptor
+		dtmf := r.dtmf
+		if i != 0 {
+			dtmf = nil
+		}
+		writeStream.interceptor.Store(interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+			if encoding.inactive.get() {
+				return 0, nil
+			}
+
+			header, payload, err := r.transformPayload(header, payload)
+			if err != nil {
+				return 0, err
+			}
+
+			if encoding.red != nil {
+				if header, payload, err = encoding.red.encode(header, payload); err != nil {
+					return 0, err
+				}
+			}
+
+			if midExtensionID != 0 {
+				if header, err = withSimulcastExtensions(header, midExtensionID, mid, ridExtensionID, rid); err != nil {
+					return 0, err
+				}
+			}
+			if dtmf != nil {
+				return dtmf.writeMedia(rtpInterceptor, header, payload, attributes)
+			}
+			return rtpInterceptor.Write(header, payload, attributes)
+		}))
+	}
 
 	close(r.sendCalled)
 	return nil
 }
 
+// withSimulcastExtensions returns a copy of the header with the mid and rid header
+// extensions set, the header is shared with the other bindings of the track
+func withSimulcastExtensions(header *rtp.Header, midExtensionID uint8, mid string, ridExtensionID uint8, rid string) (*rtp.Header, error) {
+	h := *header
+	h.Extensions = append([]rtp.Extension{}, header.Extensions...)
+	if err := h.SetExtension(midExtensionID, []byte(mid)); err != nil {
+		return nil, err
+	}
+	if err := h.SetExtension(ridExtensionID, []byte(rid)); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
 // Stop irreversibly stops the RTPSender
 func (r *RTPSender) Stop() error {
 	r.mu.Lock()
@@ -233,16 +617,21 @@ func (r *RTPSender) Stop() error {
 		return err
 	}
 
-	r.api.interceptor.UnbindLocalStream(&r.streamInfo)
+	errs := []error{}
+	for _, encoding := range r.trackEncodings {
+		r.api.interceptor.UnbindLocalStream(&encoding.streamInfo)
+		errs = append(errs, encoding.srtpStream.Close())
+	}
 
-	return r.srtpStream.Close()
+	return util.FlattenErrs(errs)
 }
 
-// Read reads incoming RTCP for this RTPReceiver
+// Read reads incoming RTCP for this RTPReceiver. With simulcast it reads the RTCP
+// of the first encoding, see ReadSimulcast.
 func (r *RTPSender) Read(b []byte) (n int, a interceptor.Attributes, err error) {
 	select {
 	case <-r.sendCalled:
-		return r.rtcpInterceptor.Read(b, a)
+		return r.trackEncodings[0].rtcpInterceptor.Read(b, a)
 	case <-r.stopCalled:
 		return 0, nil, io.ErrClosedPipe
 	}
@@ -264,10 +653,270 @@ func (r *RTPSender) ReadRTCP() ([]rtcp.Packet, interceptor.Attributes, error) {
 	return pkts, attributes, nil
 }
 
+// ReadSimulcast reads incoming RTCP for the encoding with the given rid
+func (r *RTPSender) ReadSimulcast(b []byte, rid string) (n int, a interceptor.Attributes, err error) {
+	select {
+	case <-r.sendCalled:
+		for _, encoding := range r.trackEncodings {
+			if encoding.rid == rid {
+				return encoding.rtcpInterceptor.Read(b, a)
+			}
+		}
+		return 0, nil, fmt.Errorf("%w: %s", errRTPSenderNoEncodingForRID, rid)
+	case <-r.stopCalled:
+		return 0, nil, io.ErrClosedPipe
+	}
+}
+
+// ReadSimulcastRTCP is a convenience method that wraps ReadSimulcast and unmarshals for you
+func (r *RTPSender) ReadSimulcastRTCP(rid string) ([]rtcp.Packet, interceptor.Attributes, error) {
+	b := make([]byte, receiveMTU)
+	i, attributes, err := r.ReadSimulcast(b, rid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkts, err := rtcp.Unmarshal(b[:i])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pkts, attributes, nil
+}
+
+// OnRTCP sets an event handler which is invoked with the RTCP packets the remote sends
+// about the outgoing streams, e.g. Receiver Reports, NACKs, PLIs and REMB. Once a handler
+// is set the RTPSender reads the RTCP of all encodings itself until it is stopped, also
+// of the encodings added later with AddEncoding, so OnRTCP must not be combined with
+// Read or ReadRTCP.
+func (r *RTPSender) OnRTCP(f func([]rtcp.Packet, interceptor.Attributes)) {
+	r.onRTCPHandler.Store(f)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.readingRTCP {
+		return
+	}
+	r.readingRTCP = true
+	for _, encoding := range r.trackEncodings {
+		r.routines.Add(1)
+		go r.readRTCPLoop(encoding)
+	}
+}
+
+func (r *RTPSender) readRTCPLoop(encoding *trackEncoding) {
+	defer r.routines.Done()
+
+	b := make([]byte, receiveMTU)
+	for {
+		var (
+			i          int
+			attributes interceptor.Attributes
+			err        error
+		)
+		select {
+		case <-r.sendCalled:
+			i, attributes, err = encoding.rtcpInterceptor.Read(b, attributes)
+		case <-r.stopCalled:
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		// Packets that can't be unmarshaled are dropped, the next ones may be valid
+		pkts, err := rtcp.Unmarshal(b[:i])
+		if err != nil {
+			continue
+		}
+
+		if handler, ok := r.onRTCPHandler.Load().(func([]rtcp.Packet, interceptor.Attributes)); ok && handler != nil {
+			handler(pkts, attributes)
+		}
+	}
+}
+
+// SetPayloadTransform sets a transform that is applied to the payload of every RTP
+// packet the track writes, before the packet is passed to the interceptors and
+// encrypted with SRTP. Setting nil removes the transform.
+func (r *RTPSender) SetPayloadTransform(f PayloadTransform) {
+	r.payloadTransform.Store(f)
+}
+
+func (r *RTPSender) transformPayload(header *rtp.Header, payload []byte) (*rtp.Header, []byte, error) {
+	transform, ok := r.payloadTransform.Load().(PayloadTransform)
+	if !ok || transform == nil {
+		return header, payload, nil
+	}
+
+	payload, err := stripPadding(header, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The header is shared with the other bindings of the track
+	if header.Padding {
+		h := *header
+		h.Padding = false
+		header = &h
+	}
+
+	payload, err = transform(header, payload)
+	return header, payload, err
+}
+
 // SetReadDeadline sets the deadline for the Read operation.
 // Setting to zero means no deadline.
 func (r *RTPSender) SetReadDeadline(t time.Time) error {
-	return r.srtpStream.SetReadDeadline(t)
+	return r.trackEncodings[0].srtpStream.SetReadDeadline(t)
+}
+
+// updateStats accounts a RTP packet that has been handed to the SRTP session
+func (e *trackEncoding) updateStats(header *rtp.Header, payload []byte, isKeyFrame bool) {
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+
+	if e.stats.packetsSent != 0 && int16(header.SequenceNumber-e.stats.highestSequenceNumber) <= 0 {
+		e.stats.retransmittedPacketsSent++
+		e.stats.retransmittedBytesSent += uint64(len(payload))
+	} else {
+		e.stats.highestSequenceNumber = header.SequenceNumber
+
+		if isKeyFrame && (e.stats.keyFramesSent == 0 || header.Timestamp != e.stats.lastKeyFrameTimestamp) {
+			e.stats.keyFramesSent++
+			e.stats.lastKeyFrameTimestamp = header.Timestamp
+		}
+	}
+
+	e.stats.packetsSent++
+	e.stats.bytesSent += uint64(len(payload))
+	e.stats.headerBytesSent += uint64(header.MarshalSize())
+	e.stats.lastPacketSentTimestamp = time.Now()
+}
+
+// handleFeedback counts the feedback the remote sent about the outgoing stream of an encoding
+func (r *RTPSender) handleFeedback(encoding *trackEncoding, b []byte) {
+	pkts, err := rtcp.Unmarshal(b)
+	if err != nil {
+		return
+	}
+
+	r.mu.RLock()
+	ssrc := uint32(encoding.context.ssrc)
+	r.mu.RUnlock()
+
+	var remb *rtcp.ReceiverEstimatedMaximumBitrate
+	encoding.stats.mu.Lock()
+	for _, pkt := range pkts {
+		if !containsSSRC(pkt.DestinationSSRC(), ssrc) {
+			continue
+		}
+
+		switch pkt := pkt.(type) {
+		case *rtcp.FullIntraRequest:
+			encoding.stats.firCount++
+		case *rtcp.PictureLossIndication:
+			encoding.stats.pliCount++
+		case *rtcp.TransportLayerNack:
+			encoding.stats.nackCount++
+		case *rtcp.ReceiverEstimatedMaximumBitrate:
+			remb = pkt
+		}
+	}
+	for _, report := range rtcpxr.FromPackets(pkts) {
+		for _, block := range report.Reports {
+			if metrics, ok := block.(*rtcpxr.VoIPMetricsReportBlock); ok && metrics.SSRC == ssrc {
+				encoding.stats.voipMetrics = metrics
+			}
+		}
+	}
+	encoding.stats.mu.Unlock()
+
+	if remb != nil {
+		r.targetBitrate.mu.Lock()
+		r.targetBitrate.remb = remb.Bitrate
+		r.targetBitrate.mu.Unlock()
+		r.updateTargetBitrate()
+	}
+}
+
+func containsSSRC(ssrcs []uint32, ssrc uint32) bool {
+	for _, s := range ssrcs {
+		if s == ssrc {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RTPSender) collectStats(collector *statsReportCollector) {
+	if !r.hasSent() {
+		return
+	}
+
+	r.mu.RLock()
+	encodings := append([]*trackEncoding{}, r.trackEncodings...)
+	r.mu.RUnlock()
+
+	r.targetBitrate.mu.Lock()
+	targetBitrate := r.targetBitrate.target
+	r.targetBitrate.mu.Unlock()
+
+	for _, encoding := range encodings {
+		collector.Collecting()
+
+		r.mu.RLock()
+		stats := OutboundRTPStreamStats{
+			Timestamp: collector.timestamp,
+			Type:      StatsTypeOutboundRTP,
+			ID:        encoding.statsID,
+			SSRC:      encoding.context.ssrc,
+			SenderID:  r.id,
+			RID:       encoding.rid,
+		}
+		if encoding.track != nil {
+			stats.Kind = encoding.track.Kind().String()
+		}
+		if len(encoding.context.params.Codecs) != 0 {
+			stats.CodecID = encoding.context.params.Codecs[0].statsID
+		}
+		r.mu.RUnlock()
+
+		encoding.stats.mu.Lock()
+		stats.PacketsSent = encoding.stats.packetsSent
+		stats.BytesSent = encoding.stats.bytesSent
+		stats.HeaderBytesSent = encoding.stats.headerBytesSent
+		stats.RetransmittedPacketsSent = encoding.stats.retransmittedPacketsSent
+		stats.RetransmittedBytesSent = encoding.stats.retransmittedBytesSent
+		stats.FIRCount = encoding.stats.firCount
+		stats.PLICount = encoding.stats.pliCount
+		stats.NACKCount = encoding.stats.nackCount
+		stats.KeyFramesSent = encoding.stats.keyFramesSent
+		stats.TargetBitrate = float64(targetBitrate)
+		if !encoding.stats.lastPacketSentTimestamp.IsZero() {
+			stats.LastPacketSentTimestamp = statsTimestampFrom(encoding.stats.lastPacketSentTimestamp)
+		}
+		voipMetrics := encoding.stats.voipMetrics
+		encoding.stats.mu.Unlock()
+
+		collector.Collect(stats.ID, stats)
+
+		if voipMetrics != nil {
+			collector.Collecting()
+			remoteStats := RemoteInboundRTPStreamStats{
+				Timestamp:     collector.timestamp,
+				Type:          StatsTypeRemoteInboundRTP,
+				ID:            "RemoteInbound" + stats.ID,
+				SSRC:          stats.SSRC,
+				Kind:          stats.Kind,
+				CodecID:       stats.CodecID,
+				LocalID:       stats.ID,
+				FractionLost:  float64(voipMetrics.LossRate) / 256,
+				RoundTripTime: float64(voipMetrics.RoundTripDelay) / 1000,
+			}
+			collector.Collect(remoteStats.ID, remoteStats)
+		}
+	}
 }
 
 // hasSent tells if data has been ever sent for this instance
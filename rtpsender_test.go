@@ -11,9 +11,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/transport/packetio"
 	"github.com/pion/transport/test"
 	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -133,7 +137,43 @@ func Test_RTPSender_GetParameters(t *testing.T) {
 	parameters := rtpTransceiver.Sender().GetParameters()
 	assert.NotEqual(t, 0, len(parameters.Codecs))
 	assert.Equal(t, 1, len(parameters.Encodings))
-	assert.Equal(t, rtpTransceiver.Sender().ssrc, parameters.Encodings[0].SSRC)
+	assert.Equal(t, rtpTransceiver.Sender().trackEncodings[0].ssrc, parameters.Encodings[0].SSRC)
+
+	closePairNow(t, offerer, answerer)
+}
+
+func Test_RTPSender_GetParameters_Negotiated(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerer, answerer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	sender, err := offerer.AddTrack(track)
+	assert.NoError(t, err)
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	answerer.OnTrack(func(trackRemote *TrackRemote, receiver *RTPReceiver) {
+		parameters := sender.GetParameters()
+		assert.Equal(t, "pion", parameters.RTCP.CName)
+		if assert.Equal(t, 1, len(parameters.Encodings)) {
+			assert.Equal(t, trackRemote.SSRC(), parameters.Encodings[0].SSRC)
+			assert.Equal(t, trackRemote.PayloadType(), parameters.Encodings[0].PayloadType)
+		}
+
+		assert.Contains(t, receiver.GetParameters().Codecs, trackRemote.Codec())
+		onTrackFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(offerer, answerer))
+
+	sendVideoUntilDone(onTrackFired.Done(), t, []*TrackLocalStaticSample{track})
 
 	closePairNow(t, offerer, answerer)
 }
@@ -208,3 +248,237 @@ func Test_RTPSender_ReplaceTrack_InvalidCodecChange(t *testing.T) {
 
 	closePairNow(t, sender, receiver)
 }
+
+func Test_RTPSender_OnRTCP(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	sender, receiver, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	rtpSender, err := sender.AddTrack(track)
+	assert.NoError(t, err)
+
+	seenPLI, seenPLICancel := context.WithCancel(context.Background())
+	rtpSender.OnRTCP(func(pkts []rtcp.Packet, _ interceptor.Attributes) {
+		for _, pkt := range pkts {
+			if pli, ok := pkt.(*rtcp.PictureLossIndication); ok {
+				assert.Equal(t, uint32(rtpSender.trackEncodings[0].ssrc), pli.MediaSSRC)
+				seenPLICancel()
+			}
+		}
+	})
+
+	receiver.OnTrack(func(trackRemote *TrackRemote, _ *RTPReceiver) {
+		assert.NoError(t, receiver.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(trackRemote.SSRC())}}))
+	})
+
+	assert.NoError(t, signalPair(sender, receiver))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0xAA}, Duration: time.Second}))
+			case <-seenPLI.Done():
+				return
+			}
+		}
+	}()
+
+	closePairNow(t, sender, receiver)
+}
+
+func Test_RTPSender_SetParameters(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	offerer, answerer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	sender, err := offerer.AddTrack(track)
+	assert.NoError(t, err)
+
+	targetBitrates := make(chan uint64, 2)
+	sender.OnTargetBitrateChange(func(bitrate uint64) {
+		targetBitrates <- bitrate
+	})
+
+	var modificationErr *rtcerr.InvalidModificationError
+	var rangeErr *rtcerr.RangeError
+
+	// Parameters must come from GetParameters
+	assert.True(t, errors.As(sender.SetParameters(RTPSendParameters{}), &modificationErr))
+
+	parameters := sender.GetParameters()
+	assert.True(t, parameters.Encodings[0].Active)
+	assert.Equal(t, 1.0, parameters.Encodings[0].ScaleResolutionDownBy)
+
+	parameters.Encodings[0].ScaleResolutionDownBy = 0.5
+	assert.True(t, errors.As(sender.SetParameters(parameters), &rangeErr))
+
+	parameters.Encodings[0].SSRC++
+	assert.True(t, errors.As(sender.SetParameters(parameters), &modificationErr))
+	parameters.Encodings[0].SSRC--
+
+	parameters.Encodings[0].MaxBitrate = 500000
+	parameters.Encodings[0].ScaleResolutionDownBy = 2
+	assert.NoError(t, sender.SetParameters(parameters))
+	assert.Equal(t, uint64(500000), <-targetBitrates)
+
+	// The transaction ends with SetParameters
+	assert.True(t, errors.As(sender.SetParameters(parameters), &modificationErr))
+
+	parameters = sender.GetParameters()
+	assert.Equal(t, uint64(500000), parameters.Encodings[0].MaxBitrate)
+	assert.Equal(t, 2.0, parameters.Encodings[0].ScaleResolutionDownBy)
+
+	// A lower REMB from the remote lowers the target bitrate
+	sender.OnRTCP(func([]rtcp.Packet, interceptor.Attributes) {})
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	answerer.OnTrack(func(trackRemote *TrackRemote, receiver *RTPReceiver) {
+		assert.NoError(t, answerer.WriteRTCP([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{
+			Bitrate: 300000,
+			SSRCs:   []uint32{uint32(trackRemote.SSRC())},
+		}}))
+		onTrackFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(offerer, answerer))
+
+	sendVideoUntilDone(onTrackFired.Done(), t, []*TrackLocalStaticSample{track})
+	assert.Equal(t, uint64(300000), <-targetBitrates)
+
+	parameters = sender.GetParameters()
+	parameters.Encodings[0].Active = false
+	assert.NoError(t, sender.SetParameters(parameters))
+	assert.False(t, sender.GetParameters().Encodings[0].Active)
+
+	closePairNow(t, offerer, answerer)
+}
+
+func Test_RTPSender_Simulcast(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	for _, uri := range []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI} {
+		assert.NoError(t, m.RegisterHeaderExtension(RTPHeaderExtensionCapability{URI: uri}, RTPCodecTypeVideo))
+	}
+
+	offerer, answerer, err := NewAPI(WithMediaEngine(m)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	rids := []string{"a", "b", "c"}
+	tracks := []*TrackLocalStaticSample{}
+	for _, rid := range rids {
+		track, trackErr := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion", WithRTPStreamID(rid))
+		assert.NoError(t, trackErr)
+		tracks = append(tracks, track)
+	}
+
+	sender, err := offerer.AddTrack(tracks[0])
+	assert.NoError(t, err)
+	for _, track := range tracks[1:] {
+		assert.NoError(t, sender.AddEncoding(track))
+	}
+	assert.ErrorIs(t, sender.AddEncoding(tracks[1]), errRTPSenderRIDCollision)
+	assert.ErrorIs(t, sender.ReplaceTrack(tracks[0]), errRTPSenderReplaceTrackSimulcast)
+
+	var seenRidsMu sync.Mutex
+	seenRids := map[string]bool{}
+	seenAllRids, seenAllRidsCancel := context.WithCancel(context.Background())
+	answerer.OnTrack(func(track *TrackRemote, _ *RTPReceiver) {
+		seenRidsMu.Lock()
+		defer seenRidsMu.Unlock()
+		seenRids[track.RID()] = true
+		if len(seenRids) == len(rids) {
+			seenAllRidsCancel()
+		}
+	})
+
+	offer, err := offerer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=rid:a send")
+	assert.Contains(t, offer.SDP, "a=simulcast:send a;b;c")
+	assert.NotContains(t, offer.SDP, "a=ssrc:")
+
+	assert.NoError(t, signalPair(offerer, answerer))
+
+	sendVideoUntilDone(seenAllRids.Done(), t, tracks)
+
+	parameters := sender.GetParameters()
+	assert.Equal(t, len(rids), len(parameters.Encodings))
+	for i, rid := range rids {
+		assert.Equal(t, rid, parameters.Encodings[i].RID)
+	}
+
+	closePairNow(t, offerer, answerer)
+}
+
+// Assert that the RTCP of encodings added after OnRTCP reaches the handler
+func Test_RTPSender_OnRTCP_AddEncoding(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	for _, uri := range []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI} {
+		assert.NoError(t, m.RegisterHeaderExtension(RTPHeaderExtensionCapability{URI: uri}, RTPCodecTypeVideo))
+	}
+
+	offerer, answerer, err := NewAPI(WithMediaEngine(m)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	tracks := []*TrackLocalStaticSample{}
+	for _, rid := range []string{"a", "b"} {
+		track, trackErr := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion", WithRTPStreamID(rid))
+		assert.NoError(t, trackErr)
+		tracks = append(tracks, track)
+	}
+
+	sender, err := offerer.AddTrack(tracks[0])
+	assert.NoError(t, err)
+
+	seenPLI, seenPLICancel := context.WithCancel(context.Background())
+	sender.OnRTCP(func(pkts []rtcp.Packet, _ interceptor.Attributes) {
+		for _, pkt := range pkts {
+			if pli, ok := pkt.(*rtcp.PictureLossIndication); ok && pli.MediaSSRC == uint32(sender.trackEncodings[1].ssrc) {
+				seenPLICancel()
+			}
+		}
+	})
+	assert.NoError(t, sender.AddEncoding(tracks[1]))
+
+	answerer.OnTrack(func(trackRemote *TrackRemote, _ *RTPReceiver) {
+		if trackRemote.RID() == "b" {
+			assert.NoError(t, answerer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(trackRemote.SSRC())}}))
+		}
+	})
+
+	assert.NoError(t, signalPair(offerer, answerer))
+
+	sendVideoUntilDone(seenPLI.Done(), t, tracks)
+
+	closePairNow(t, offerer, answerer)
+}
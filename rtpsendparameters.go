@@ -4,4 +4,8 @@ package webrtc
 type RTPSendParameters struct {
 	RTPParameters
 	Encodings []RTPEncodingParameters
+
+	// TransactionID is set by GetParameters, SetParameters only accepts
+	// parameters from the last GetParameters call
+	TransactionID string
 }
@@ -19,9 +19,12 @@ type RTPTransceiver struct {
 
 	codecs []RTPCodecParameters // User provided codecs via SetCodecPreferences
 
-	stopped bool
+	stopped atomicBool
 	kind    RTPCodecType
 
+	// onNegotiationNeeded is set by the PeerConnection the RTPTransceiver belongs to
+	onNegotiationNeeded atomic.Value // func()
+
 	api *API
 	mu  sync.RWMutex
 }
@@ -139,8 +142,35 @@ func (t *RTPTransceiver) Direction() RTPTransceiverDirection {
 	return t.direction.Load().(RTPTransceiverDirection)
 }
 
-// Stop irreversibly stops the RTPTransceiver
+// Stop irreversibly stops the RTPTransceiver. The RTPSender and RTPReceiver are
+// stopped right away and the media section of the RTPTransceiver is rejected with
+// a zero port in the next offer or answer, the transceiver isn't reused by AddTrack.
+// OnNegotiationNeeded fires, so that the rejection is negotiated.
 func (t *RTPTransceiver) Stop() error {
+	if err := t.setStopped(); err != nil {
+		return err
+	}
+
+	if handler, ok := t.onNegotiationNeeded.Load().(func()); ok && handler != nil {
+		handler()
+	}
+	return nil
+}
+
+// setStopped is Stop without firing OnNegotiationNeeded, for the PeerConnection
+// stopping the RTPTransceiver itself
+func (t *RTPTransceiver) setStopped() error {
+	if err := t.stop(); err != nil {
+		return err
+	}
+
+	t.stopped.set(true)
+	return nil
+}
+
+// stop stops the RTPSender and RTPReceiver and makes the RTPTransceiver inactive,
+// unlike Stop the media section keeps being negotiated
+func (t *RTPTransceiver) stop() error {
 	if t.Sender() != nil {
 		if err := t.Sender().Stop(); err != nil {
 			return err
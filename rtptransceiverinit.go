@@ -2,7 +2,11 @@ package webrtc
 
 // RTPTransceiverInit dictionary is used when calling the WebRTC function addTransceiver() to provide configuration options for the new transceiver.
 type RTPTransceiverInit struct {
-	Direction     RTPTransceiverDirection
+	Direction RTPTransceiverDirection
+
+	// SendEncodings configures the RTPSender created by AddTransceiverFromTrack, only
+	// the SSRC and RTX SSRC of the first encoding are used. The RTX SSRC is declared
+	// with a=ssrc-group:FID, Pion doesn't send RTX itself.
 	SendEncodings []RTPEncodingParameters
 	// Streams       []*Track
 }
@@ -3,12 +3,15 @@
 package webrtc
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"math"
 	"sync"
-	"time"
 
 	"github.com/pion/datachannel"
+	"github.com/pion/dtls/v2"
 	"github.com/pion/logging"
 	"github.com/pion/sctp"
 	"github.com/pion/webrtc/v3/pkg/rtcerr"
@@ -17,6 +20,9 @@ import (
 const sctpMaxChannels = uint16(65535)
 
 // SCTPTransport provides details about the SCTP transport.
+// The SCTP association lives as long as the DTLSTransport it runs over. An ICE
+// restart keeps the association and its DataChannels open, but once the association
+// has been closed it is not re-established and its DataChannels stay closed.
 type SCTPTransport struct {
 	lock sync.RWMutex
 
@@ -45,12 +51,26 @@ type SCTPTransport struct {
 	onDataChannelHandler       func(*DataChannel)
 	onDataChannelOpenedHandler func(*DataChannel)
 
+	// onDataChannelRequestHandler decides whether a DataChannel opened by the remote is accepted
+	onDataChannelRequestHandler func(DataChannelParameters) bool
+
+	// handshakeDone is closed once the association is established and the
+	// DataChannels created before it have been opened. DataChannels accepted
+	// from the remote are queued until then.
+	handshakeDone chan struct{}
+
+	// controlDataChannel carries close reasons, see SettingEngine.EnableCloseReasons
+	controlDataChannel *DataChannel
+
 	// DataChannels
 	dataChannels          []*DataChannel
 	dataChannelsOpened    uint32
 	dataChannelsRequested uint32
 	dataChannelsAccepted  uint32
 
+	// routines is acceptDataChannels, the DataChannels track their read loops
+	routines sync.WaitGroup
+
 	api *API
 	log logging.LeveledLogger
 }
@@ -62,6 +82,7 @@ func (api *API) NewSCTPTransport(dtls *DTLSTransport) *SCTPTransport {
 	res := &SCTPTransport{
 		dtlsTransport: dtls,
 		state:         SCTPTransportStateConnecting,
+		handshakeDone: make(chan struct{}),
 		api:           api,
 		log:           api.settingEngine.LoggerFactory.NewLogger("ortc"),
 	}
@@ -102,21 +123,43 @@ func (r *SCTPTransport) Start(remoteCaps SCTPCapabilities) error {
 	}
 
 	sctpAssociation, err := sctp.Client(sctp.Config{
-		NetConn:       dtlsTransport.conn,
-		LoggerFactory: r.api.settingEngine.LoggerFactory,
+		NetConn:              dtlsTransport.conn,
+		MaxReceiveBufferSize: r.api.settingEngine.sctp.MaxReceiveBufferSize,
+		LoggerFactory:        r.api.settingEngine.LoggerFactory,
 	})
 	if err != nil {
 		return err
 	}
 
 	r.lock.Lock()
-	defer r.lock.Unlock()
-
 	r.sctpAssociation = sctpAssociation
 	r.state = SCTPTransportStateConnected
+	dataChannels := append([]*DataChannel{}, r.dataChannels...)
+	r.lock.Unlock()
 
+	// Start accepting right away, the association drops incoming streams
+	// when nobody accepts them. Accepted DataChannels wait for handshakeDone.
+	r.routines.Add(1)
 	go r.acceptDataChannels(sctpAssociation)
 
+	// DataChannels that need to be opened now that SCTP is available
+	var openedDCCount uint32
+	for _, d := range dataChannels {
+		if d.ReadyState() == DataChannelStateConnecting {
+			if err := d.open(r); err != nil {
+				r.log.Warnf("failed to open data channel: %s", err)
+				continue
+			}
+			openedDCCount++
+		}
+	}
+
+	r.lock.Lock()
+	r.dataChannelsOpened += openedDCCount
+	r.lock.Unlock()
+
+	close(r.handshakeDone)
+
 	return nil
 }
 
@@ -127,8 +170,9 @@ func (r *SCTPTransport) Stop() error {
 	if r.sctpAssociation == nil {
 		return nil
 	}
+	// dtls connection is already closed if the association was shutdown
 	err := r.sctpAssociation.Close()
-	if err != nil {
+	if err != nil && !errors.Is(err, dtls.ErrConnClosed) {
 		return err
 	}
 
@@ -138,7 +182,20 @@ func (r *SCTPTransport) Stop() error {
 	return nil
 }
 
+// shutdown gracefully ends the SCTP association. It sends a SHUTDOWN and
+// blocks until the remote acknowledged it or ctx is done.
+func (r *SCTPTransport) shutdown(ctx context.Context) error {
+	association := r.association()
+	if association == nil {
+		return nil
+	}
+
+	return association.Shutdown(ctx)
+}
+
 func (r *SCTPTransport) acceptDataChannels(a *sctp.Association) {
+	defer r.routines.Done()
+
 	for {
 		dc, err := datachannel.Accept(a, &datachannel.Config{
 			LoggerFactory: r.api.settingEngine.LoggerFactory,
@@ -179,7 +236,7 @@ func (r *SCTPTransport) acceptDataChannels(a *sctp.Association) {
 		}
 
 		sid := dc.StreamIdentifier()
-		rtcDC, err := r.api.newDataChannel(&DataChannelParameters{
+		params := DataChannelParameters{
 			ID:                &sid,
 			Label:             dc.Config.Label,
 			Protocol:          dc.Config.Protocol,
@@ -187,7 +244,35 @@ func (r *SCTPTransport) acceptDataChannels(a *sctp.Association) {
 			Ordered:           ordered,
 			MaxPacketLifeTime: maxPacketLifeTime,
 			MaxRetransmits:    maxRetransmits,
-		}, r.api.settingEngine.LoggerFactory.NewLogger("ortc"))
+		}
+
+		r.lock.RLock()
+		requestHandler := r.onDataChannelRequestHandler
+		limitReached := r.isDataChannelLimitReached()
+		r.lock.RUnlock()
+
+		rejected := true
+		switch {
+		case limitReached:
+			r.log.Warnf("Rejected data channel %s (id %d): %s", params.Label, sid, ErrMaxDataChannels)
+		case !dataChannelProtocolAllowed(r.api.settingEngine.sctp.Protocols, params.Protocol):
+			r.log.Infof("Rejected data channel %s (id %d): protocol %q isn't allowed", params.Label, sid, params.Protocol)
+		case requestHandler != nil && !requestHandler(params):
+			r.log.Infof("Rejected data channel %s (id %d, protocol %q)", params.Label, sid, params.Protocol)
+		default:
+			rejected = false
+		}
+		if rejected {
+			if err = dc.Close(); err != nil {
+				r.log.Warnf("Failed to reset rejected data channel %d: %v", sid, err)
+			}
+			continue
+		}
+
+		// Dispatch in order, and only once the locally created DataChannels are open
+		<-r.handshakeDone
+
+		rtcDC, err := r.api.newDataChannel(&params, r.api.settingEngine.LoggerFactory.NewLogger("datachannel"))
 		if err != nil {
 			r.log.Errorf("Failed to accept data channel: %v", err)
 			r.onError(err)
@@ -234,6 +319,19 @@ func (r *SCTPTransport) OnDataChannel(f func(*DataChannel)) {
 	r.onDataChannelHandler = f
 }
 
+// OnDataChannelRequest sets an event handler which decides whether a DataChannel
+// opened by the remote is accepted, e.g. to enforce a policy on labels and protocols.
+// It is invoked with the parameters of the DataChannel before OnDataChannel. If it
+// returns false the DataChannel is closed by resetting its stream, and OnDataChannel
+// isn't invoked for it. The remote has already been told the DataChannel is open when
+// the handler runs, so it sees the DataChannel open and close right away. DataChannels
+// negotiated out-of-band are not requested from the remote and never reach the handler.
+func (r *SCTPTransport) OnDataChannelRequest(f func(DataChannelParameters) bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onDataChannelRequestHandler = f
+}
+
 // OnDataChannelOpened sets an event handler which is invoked when a data
 // channel is opened
 func (r *SCTPTransport) OnDataChannelOpened(f func(*DataChannel)) {
@@ -297,9 +395,29 @@ func (r *SCTPTransport) calcMessageSize(remoteMaxMessageSize, canSendSize float6
 
 func (r *SCTPTransport) updateMaxChannels() {
 	val := sctpMaxChannels
+	if max := r.api.settingEngine.sctp.MaxDataChannels; max != 0 {
+		val = max
+	}
 	r.maxChannels = &val
 }
 
+// isDataChannelLimitReached reports whether as many DataChannels as MaxChannels are open,
+// the DataChannel carrying close reasons doesn't count.
+// Note: the caller should hold the lock.
+func (r *SCTPTransport) isDataChannelLimitReached() bool {
+	if r.maxChannels == nil || *r.maxChannels == sctpMaxChannels {
+		return false
+	}
+
+	open := 0
+	for _, d := range r.dataChannels {
+		if d != r.controlDataChannel && d.ReadyState() != DataChannelStateClosed {
+			open++
+		}
+	}
+	return open >= int(*r.maxChannels)
+}
+
 // MaxChannels is the maximum number of RTCDataChannels that can be open simultaneously.
 func (r *SCTPTransport) MaxChannels() uint16 {
 	r.lock.Lock()
@@ -323,7 +441,7 @@ func (r *SCTPTransport) collectStats(collector *statsReportCollector) {
 	collector.Collecting()
 
 	stats := TransportStats{
-		Timestamp: statsTimestampFrom(time.Now()),
+		Timestamp: collector.timestamp,
 		Type:      StatsTypeTransport,
 		ID:        "sctpTransport",
 	}
@@ -338,33 +456,57 @@ func (r *SCTPTransport) collectStats(collector *statsReportCollector) {
 }
 
 func (r *SCTPTransport) generateAndSetDataChannelID(dtlsRole DTLSRole, idOut **uint16) error {
-	isChannelWithID := func(id uint16) bool {
-		for _, d := range r.dataChannels {
-			if d.id != nil && *d.id == id {
-				return true
-			}
-		}
-		return false
-	}
-
 	var id uint16
+	parity := "even"
 	if dtlsRole != DTLSRoleClient {
 		id++
+		parity = "odd"
 	}
 
-	max := r.MaxChannels()
-
 	r.lock.Lock()
 	defer r.lock.Unlock()
-	for ; id < max-1; id += 2 {
-		if isChannelWithID(id) {
+
+	usedIDs := make(map[uint16]struct{}, len(r.dataChannels))
+	for _, d := range r.dataChannels {
+		if d.id != nil {
+			usedIDs[*d.id] = struct{}{}
+		}
+	}
+	if r.api != nil && r.api.settingEngine.closeReasons {
+		usedIDs[r.api.settingEngine.getControlDataChannelID()] = struct{}{}
+	}
+
+	for ; id < sctpMaxChannels-1; id += 2 {
+		if _, ok := usedIDs[id]; ok {
 			continue
 		}
 		*idOut = &id
 		return nil
 	}
 
-	return &rtcerr.OperationError{Err: ErrMaxDataChannelID}
+	return &rtcerr.OperationError{Err: fmt.Errorf("%w: all %s IDs for the DTLS %s are in use", ErrMaxDataChannelID, parity, dtlsRole)}
+}
+
+// wait blocks until acceptDataChannels and the read loops of the DataChannels returned
+func (r *SCTPTransport) wait() {
+	r.routines.Wait()
+
+	r.lock.RLock()
+	dataChannels := append([]*DataChannel{}, r.dataChannels...)
+	if r.controlDataChannel != nil {
+		dataChannels = append(dataChannels, r.controlDataChannel)
+	}
+	r.lock.RUnlock()
+
+	for _, d := range dataChannels {
+		d.readLoops.Wait()
+	}
+}
+
+func (r *SCTPTransport) getControlDataChannel() *DataChannel {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.controlDataChannel
 }
 
 func (r *SCTPTransport) association() *sctp.Association {
@@ -376,3 +518,17 @@ func (r *SCTPTransport) association() *sctp.Association {
 	r.lock.RUnlock()
 	return association
 }
+
+// dataChannelProtocolAllowed reports whether a DataChannel of the protocol may be opened
+// by the remote, all are allowed without a list of protocols
+func dataChannelProtocolAllowed(protocols []string, protocol string) bool {
+	if len(protocols) == 0 {
+		return true
+	}
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
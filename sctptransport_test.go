@@ -2,7 +2,10 @@
 
 package webrtc
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestGenerateDataChannelID(t *testing.T) {
 	sctpTransportWithChannels := func(ids []uint16) *SCTPTransport {
@@ -44,3 +47,20 @@ func TestGenerateDataChannelID(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateDataChannelID_Exhausted(t *testing.T) {
+	s := &SCTPTransport{}
+	for i := uint16(1); i < sctpMaxChannels-1; i += 2 {
+		i := i
+		s.dataChannels = append(s.dataChannels, &DataChannel{id: &i})
+	}
+
+	// Once all IDs of the role are used the error says so, the other role still has IDs
+	id := new(uint16)
+	if err := s.generateAndSetDataChannelID(DTLSRoleServer, &id); !errors.Is(err, ErrMaxDataChannelID) {
+		t.Errorf("Expected ErrMaxDataChannelID, got %v", err)
+	}
+	if err := s.generateAndSetDataChannelID(DTLSRoleClient, &id); err != nil {
+		t.Errorf("failed to generate id: %v", err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pion/ice/v2"
 	"github.com/pion/logging"
 	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3/pkg/rtcerr"
 )
 
 // trackDetails represents any media source that can be represented in a SDP
@@ -24,6 +25,22 @@ type trackDetails struct {
 	id       string
 	ssrc     SSRC
 	rids     []string
+
+	// fecSSRC is the SSRC of the FlexFEC stream protecting ssrc
+	fecSSRC SSRC
+
+	// rtxSSRC is the SSRC of the RTX stream repairing ssrc
+	rtxSSRC SSRC
+
+	// simulcastLayers are the layers declared together with ssrc in a=ssrc-group:SIM,
+	// ssrc is the first layer and not included
+	simulcastLayers []simulcastLayer
+}
+
+// simulcastLayer is a simulcast layer declared with a=ssrc-group:SIM, RFC 5576
+type simulcastLayer struct {
+	ssrc    SSRC
+	rtxSSRC SSRC
 }
 
 func trackDetailsForSSRC(trackDetails []trackDetails, ssrc SSRC) *trackDetails {
@@ -48,7 +65,10 @@ func filterTrackWithSSRC(incomingTracks []trackDetails, ssrc SSRC) []trackDetail
 // extract all trackDetails from an SDP.
 func trackDetailsFromSDP(log logging.LeveledLogger, s *sdp.SessionDescription) []trackDetails { // nolint:gocognit
 	incomingTracks := []trackDetails{}
-	rtxRepairFlows := map[uint32]bool{}
+	repairFlows := map[uint32]bool{}
+	fecRepairFlows := map[SSRC]SSRC{}
+	rtxRepairFlows := map[SSRC]SSRC{}
+	simulcastGroups := map[SSRC][]SSRC{}
 
 	for _, media := range s.MediaDescriptions {
 		// Plan B can have multiple tracks in a signle media section
@@ -82,7 +102,7 @@ func trackDetailsFromSDP(log logging.LeveledLogger, s *sdp.SessionDescription) [
 					// as this declares that the second SSRC (632943048) is a rtx repair flow (RFC4588) for the first
 					// (2231627014) as specified in RFC5576
 					if len(split) == 3 {
-						_, err := strconv.ParseUint(split[1], 10, 32)
+						mediaSSRC, err := strconv.ParseUint(split[1], 10, 32)
 						if err != nil {
 							log.Warnf("Failed to parse SSRC: %v", err)
 							continue
@@ -92,9 +112,48 @@ func trackDetailsFromSDP(log logging.LeveledLogger, s *sdp.SessionDescription) [
 							log.Warnf("Failed to parse SSRC: %v", err)
 							continue
 						}
-						rtxRepairFlows[uint32(rtxRepairFlow)] = true
+						repairFlows[uint32(rtxRepairFlow)] = true
+						rtxRepairFlows[SSRC(mediaSSRC)] = SSRC(rtxRepairFlow)
 						incomingTracks = filterTrackWithSSRC(incomingTracks, SSRC(rtxRepairFlow)) // Remove if rtx was added as track before
 					}
+				} else if split[0] == sdpSemanticTokenSimulcast && len(split) > 2 {
+					// `a=ssrc-group:SIM 1717457120 2281624593 3218283914` declares the SSRCs as
+					// simulcast layers of the same track. The other layers are received by
+					// the RTPReceiver of the first one.
+					ssrcs := make([]SSRC, 0, len(split)-1)
+					for _, value := range split[1:] {
+						ssrc, err := strconv.ParseUint(value, 10, 32)
+						if err != nil {
+							log.Warnf("Failed to parse SSRC: %v", err)
+							break
+						}
+						ssrcs = append(ssrcs, SSRC(ssrc))
+					}
+					if len(ssrcs) != len(split)-1 {
+						continue
+					}
+
+					simulcastGroups[ssrcs[0]] = ssrcs[1:]
+					for _, layer := range ssrcs[1:] {
+						repairFlows[uint32(layer)] = true
+						incomingTracks = filterTrackWithSSRC(incomingTracks, layer)
+					}
+				} else if split[0] == sdpSemanticTokenFECFR && len(split) == 3 {
+					// `a=ssrc-group:FEC-FR 2231627014 1347896325` declares the second SSRC as
+					// the FlexFEC stream protecting the first one. It is no track either.
+					mediaSSRC, err := strconv.ParseUint(split[1], 10, 32)
+					if err != nil {
+						log.Warnf("Failed to parse SSRC: %v", err)
+						continue
+					}
+					fecSSRC, err := strconv.ParseUint(split[2], 10, 32)
+					if err != nil {
+						log.Warnf("Failed to parse SSRC: %v", err)
+						continue
+					}
+					repairFlows[uint32(fecSSRC)] = true
+					fecRepairFlows[SSRC(mediaSSRC)] = SSRC(fecSSRC)
+					incomingTracks = filterTrackWithSSRC(incomingTracks, SSRC(fecSSRC))
 				}
 
 			// Handle `a=msid:<stream_id> <track_label>` for Unified plan. The first value is the same as MediaStream.id
@@ -115,8 +174,8 @@ func trackDetailsFromSDP(log logging.LeveledLogger, s *sdp.SessionDescription) [
 					continue
 				}
 
-				if rtxRepairFlow := rtxRepairFlows[uint32(ssrc)]; rtxRepairFlow {
-					continue // This ssrc is a RTX repair flow, ignore
+				if rtxRepairFlow := repairFlows[uint32(ssrc)]; rtxRepairFlow {
+					continue // This ssrc is a RTX or FEC repair flow or a simulcast layer, ignore
 				}
 
 				if len(split) == 3 && strings.HasPrefix(split[1], "msid:") {
@@ -160,9 +219,40 @@ func trackDetailsFromSDP(log logging.LeveledLogger, s *sdp.SessionDescription) [
 			incomingTracks = append(incomingTracks, newTrack)
 		}
 	}
+
+	for i := range incomingTracks {
+		incomingTracks[i].fecSSRC = fecRepairFlows[incomingTracks[i].ssrc]
+		incomingTracks[i].rtxSSRC = rtxRepairFlows[incomingTracks[i].ssrc]
+		for _, layer := range simulcastGroups[incomingTracks[i].ssrc] {
+			incomingTracks[i].simulcastLayers = append(incomingTracks[i].simulcastLayers, simulcastLayer{
+				ssrc:    layer,
+				rtxSSRC: rtxRepairFlows[layer],
+			})
+		}
+	}
 	return incomingTracks
 }
 
+// getMsid returns the stream and track id of `a=msid:<stream_id> <track_id>`
+func getMsid(media *sdp.MediaDescription) (streamID, trackID string) {
+	if value, ok := media.Attribute(sdp.AttrKeyMsid); ok {
+		if split := strings.Split(value, " "); len(split) == 2 {
+			return split[0], split[1]
+		}
+	}
+	return "", ""
+}
+
+// mediaSectionForMid returns the media section with the mid, or nil
+func mediaSectionForMid(s *sdp.SessionDescription, mid string) *sdp.MediaDescription {
+	for _, media := range s.MediaDescriptions {
+		if getMidValue(media) == mid {
+			return media
+		}
+	}
+	return nil
+}
+
 func getRids(media *sdp.MediaDescription) map[string]string {
 	rids := map[string]string{}
 	for _, attr := range media.Attributes {
@@ -212,7 +302,7 @@ func addCandidatesToMediaDescriptions(candidates []ICECandidate, m *sdp.MediaDes
 	return nil
 }
 
-func addDataMediaSection(d *sdp.SessionDescription, shouldAddCandidates bool, dtlsFingerprints []DTLSFingerprint, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, iceGatheringState ICEGatheringState) error {
+func addDataMediaSection(d *sdp.SessionDescription, shouldAddCandidates bool, dtlsFingerprints []DTLSFingerprint, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, iceGatheringState ICEGatheringState, closeReasonsID *uint16) error {
 	media := (&sdp.MediaDescription{
 		MediaName: sdp.MediaName{
 			Media:   mediaSectionApplication,
@@ -234,6 +324,10 @@ func addDataMediaSection(d *sdp.SessionDescription, shouldAddCandidates bool, dt
 		WithPropertyAttribute("sctp-port:5000").
 		WithICECredentials(iceParams.UsernameFragment, iceParams.Password)
 
+	if closeReasonsID != nil {
+		media = media.WithValueAttribute(sdpAttributeCloseReasons, strconv.Itoa(int(*closeReasonsID)))
+	}
+
 	for _, f := range dtlsFingerprints {
 		media = media.WithFingerprint(f.Algorithm, strings.ToUpper(f.Value))
 	}
@@ -278,6 +372,50 @@ func populateLocalCandidates(sessionDescription *SessionDescription, i *ICEGathe
 	}
 }
 
+// addRemoteCandidate returns a copy of the SessionDescription with a candidate supplied via
+// AddICECandidate added to its media section. An empty candidate adds end-of-candidates
+func addRemoteCandidate(sessionDescription *SessionDescription, candidate ICECandidateInit) *SessionDescription {
+	parsed := &sdp.SessionDescription{}
+	if err := parsed.Unmarshal([]byte(sessionDescription.SDP)); err != nil || len(parsed.MediaDescriptions) == 0 {
+		return sessionDescription
+	}
+
+	m := parsed.MediaDescriptions[0]
+	if candidate.SDPMid != nil {
+		for _, media := range parsed.MediaDescriptions {
+			if getMidValue(media) == *candidate.SDPMid {
+				m = media
+				break
+			}
+		}
+	} else if candidate.SDPMLineIndex != nil && int(*candidate.SDPMLineIndex) < len(parsed.MediaDescriptions) {
+		m = parsed.MediaDescriptions[*candidate.SDPMLineIndex]
+	}
+
+	attribute := sdp.NewPropertyAttribute("end-of-candidates")
+	if candidateValue := strings.TrimPrefix(candidate.Candidate, "candidate:"); candidateValue != "" {
+		attribute = sdp.NewAttribute("candidate", candidateValue)
+	}
+
+	for _, a := range m.Attributes {
+		if a.Key == attribute.Key && a.Value == attribute.Value {
+			return sessionDescription
+		}
+	}
+	m.Attributes = append(m.Attributes, attribute)
+
+	marshaled, err := parsed.Marshal()
+	if err != nil {
+		return sessionDescription
+	}
+
+	return &SessionDescription{
+		SDP:    string(marshaled),
+		Type:   sessionDescription.Type,
+		parsed: parsed,
+	}
+}
+
 func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates bool, dtlsFingerprints []DTLSFingerprint, mediaEngine *MediaEngine, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, iceGatheringState ICEGatheringState, mediaSection mediaSection) (bool, error) {
 	transceivers := mediaSection.transceivers
 	if len(transceivers) < 1 {
@@ -293,6 +431,9 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 		WithPropertyAttribute(sdp.AttrKeyRTCPRsize)
 
 	codecs := t.getCodecs()
+	if t.kind == RTPCodecTypeAudio {
+		codecs = withVoiceActivityDetection(codecs, mediaSection.voiceActivityDetection)
+	}
 	for _, codec := range codecs {
 		name := strings.TrimPrefix(codec.MimeType, "audio/")
 		name = strings.TrimPrefix(name, "video/")
@@ -302,8 +443,8 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 			media.WithValueAttribute("rtcp-fb", fmt.Sprintf("%d %s %s", codec.PayloadType, feedback.Type, feedback.Parameter))
 		}
 	}
-	if len(codecs) == 0 {
-		// Explicitly reject track if we don't have the codec
+	if len(codecs) == 0 || t.stopped.get() {
+		// Explicitly reject track if we don't have the codec or it has been stopped
 		d.WithMedia(&sdp.MediaDescription{
 			MediaName: sdp.MediaName{
 				Media:   t.kind.String(),
@@ -311,6 +452,7 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 				Protos:  []string{"UDP", "TLS", "RTP", "SAVPF"},
 				Formats: []string{"0"},
 			},
+			Attributes: []sdp.Attribute{{Key: sdp.AttrKeyMID, Value: midValue}},
 		})
 		return false, nil
 	}
@@ -319,7 +461,8 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 	if t.Sender() != nil {
 		directions = append(directions, RTPTransceiverDirectionSendonly)
 	}
-	if t.Receiver() != nil {
+	// Placeholders answering a Plan B section recvonly don't have a receiver
+	if t.Receiver() != nil || t.Direction() == RTPTransceiverDirectionRecvonly {
 		directions = append(directions, RTPTransceiverDirectionRecvonly)
 	}
 
@@ -332,7 +475,16 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 		media.WithExtMap(sdp.ExtMap{Value: rtpExtension.ID, URI: extURL})
 	}
 
-	if len(mediaSection.ridMap) > 0 {
+	// The rids of a sender with simulcast encodings are sent, not received, even
+	// if the remote description already lists them
+	sendSimulcast := false
+	for _, mt := range transceivers {
+		if mt.Sender() != nil && mt.Sender().isSimulcast() {
+			sendSimulcast = true
+		}
+	}
+
+	if len(mediaSection.ridMap) > 0 && !sendSimulcast {
 		recvRids := make([]string, 0, len(mediaSection.ridMap))
 
 		for rid := range mediaSection.ridMap {
@@ -346,7 +498,30 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 	for _, mt := range transceivers {
 		if mt.Sender() != nil && mt.Sender().Track() != nil {
 			track := mt.Sender().Track()
-			media = media.WithMediaSource(uint32(mt.Sender().ssrc), track.StreamID() /* cname */, track.StreamID() /* streamLabel */, track.ID())
+
+			// Simulcast is sent with rids, like browsers do the SSRCs aren't signaled
+			if mt.Sender().isSimulcast() {
+				sendRids := []string{}
+				for _, encoding := range mt.Sender().trackEncodings {
+					media.WithValueAttribute("rid", encoding.rid+" send")
+					sendRids = append(sendRids, encoding.rid)
+				}
+				media.WithValueAttribute("simulcast", "send "+strings.Join(sendRids, ";"))
+				if !isPlanB {
+					media = media.WithPropertyAttribute("msid:" + track.StreamID() + " " + track.ID())
+					break
+				}
+				continue
+			}
+
+			encoding := mt.Sender().trackEncodings[0]
+			if encoding.rtxSSRC != 0 {
+				media = media.WithValueAttribute(sdp.AttrKeySSRCGroup, fmt.Sprintf("%s %d %d", sdp.SemanticTokenFlowIdentification, encoding.ssrc, encoding.rtxSSRC))
+			}
+			media = media.WithMediaSource(uint32(encoding.ssrc), track.StreamID() /* cname */, track.StreamID() /* streamLabel */, track.ID())
+			if encoding.rtxSSRC != 0 {
+				media = media.WithMediaSource(uint32(encoding.rtxSSRC), track.StreamID() /* cname */, track.StreamID() /* streamLabel */, track.ID())
+			}
 			if !isPlanB {
 				media = media.WithPropertyAttribute("msid:" + track.StreamID() + " " + track.ID())
 				break
@@ -372,14 +547,20 @@ func addTransceiverSDP(d *sdp.SessionDescription, isPlanB, shouldAddCandidates b
 }
 
 type mediaSection struct {
-	id           string
-	transceivers []*RTPTransceiver
-	data         bool
-	ridMap       map[string]string
+	id                     string
+	transceivers           []*RTPTransceiver
+	data                   bool
+	ridMap                 map[string]string
+	voiceActivityDetection voiceActivityDetection
+	closeReasonsID         *uint16 // ID of the DataChannel for close reasons, nil if disabled
+
+	// negotiated is set if the section is part of the current local description,
+	// such a section is never offered bundle-only again
+	negotiated bool
 }
 
 // populateSDP serializes a PeerConnections state into an SDP
-func populateSDP(d *sdp.SessionDescription, isPlanB bool, dtlsFingerprints []DTLSFingerprint, mediaDescriptionFingerprint bool, isICELite bool, mediaEngine *MediaEngine, connectionRole sdp.ConnectionRole, candidates []ICECandidate, iceParams ICEParameters, mediaSections []mediaSection, iceGatheringState ICEGatheringState) (*sdp.SessionDescription, error) {
+func populateSDP(d *sdp.SessionDescription, isPlanB bool, dtlsFingerprints []DTLSFingerprint, mediaDescriptionFingerprint bool, isICELite bool, bundleOnly bool, mediaEngine *MediaEngine, connectionRole sdp.ConnectionRole, candidates []ICECandidate, iceParams ICEParameters, mediaSections []mediaSection, iceGatheringState ICEGatheringState) (*sdp.SessionDescription, error) {
 	var err error
 	mediaDtlsFingerprints := []DTLSFingerprint{}
 
@@ -404,7 +585,7 @@ func populateSDP(d *sdp.SessionDescription, isPlanB bool, dtlsFingerprints []DTL
 		shouldAddID := true
 		shouldAddCandidates := i == 0
 		if m.data {
-			if err = addDataMediaSection(d, shouldAddCandidates, mediaDtlsFingerprints, m.id, iceParams, candidates, connectionRole, iceGatheringState); err != nil {
+			if err = addDataMediaSection(d, shouldAddCandidates, mediaDtlsFingerprints, m.id, iceParams, candidates, connectionRole, iceGatheringState, m.closeReasonsID); err != nil {
 				return nil, err
 			}
 		} else {
@@ -415,6 +596,14 @@ func populateSDP(d *sdp.SessionDescription, isPlanB bool, dtlsFingerprints []DTL
 		}
 
 		if shouldAddID {
+			// RFC 8843 S7.2.1 with max-bundle every bundled media section besides
+			// the first one (the tagged one) has a zero port and is bundle-only.
+			// S7.3 only allows that for sections the offer adds.
+			if bundleOnly && bundleCount != 0 && !m.negotiated {
+				media := d.MediaDescriptions[len(d.MediaDescriptions)-1]
+				media.MediaName.Port = sdp.RangedPort{Value: 0}
+				media.WithPropertyAttribute(sdpAttributeBundleOnly)
+			}
 			appendBundle(m.id)
 		}
 	}
@@ -442,6 +631,88 @@ func getMidValue(media *sdp.MediaDescription) string {
 	return ""
 }
 
+// haveBundleOnly returns true if the media section is only usable as part of a BUNDLE group,
+// a zero port then doesn't mean it has been rejected
+func haveBundleOnly(media *sdp.MediaDescription) bool {
+	_, ok := media.Attribute(sdpAttributeBundleOnly)
+	return ok
+}
+
+// isRejectedMediaSection returns true if the media section has been rejected with a zero port
+func isRejectedMediaSection(media *sdp.MediaDescription) bool {
+	return media.MediaName.Port.Value == 0 && !haveBundleOnly(media)
+}
+
+// withRTCPMuxOnly marks the RTP media sections of an initial offer as rtcp-mux-only,
+// legacy endpoints then know that they can't answer with RTCP on a separate port
+func withRTCPMuxOnly(d *sdp.SessionDescription) {
+	for _, media := range d.MediaDescriptions {
+		if _, ok := media.Attribute(sdp.AttrKeyRTCPMux); ok {
+			media.WithPropertyAttribute(sdpAttributeRTCPMuxOnly)
+		}
+	}
+}
+
+// checkRTCPMux returns an error for the first media section of a remote description
+// that wants RTCP on a separate port, it can't be negotiated as RTCP is always
+// multiplexed with RTP. A BUNDLE group only needs a=rtcp-mux in its tagged media
+// section, RFC 8843 Section 9.3, which is the first one of the group that carries
+// RTCP. Rejected and data media sections don't carry RTCP.
+func checkRTCPMux(d *sdp.SessionDescription) error {
+	carriesRTCP := func(media *sdp.MediaDescription) bool {
+		return media.MediaName.Media != mediaSectionApplication && !isRejectedMediaSection(media)
+	}
+	checkMedia := func(i int, media *sdp.MediaDescription) error {
+		if _, ok := media.Attribute(sdp.AttrKeyRTCPMux); !ok {
+			return &rtcerr.InvalidAccessError{Err: fmt.Errorf("%w: media section %d (m=%s, mid %q)", ErrRTCPMuxRequired, i, media.MediaName.Media, getMidValue(media))}
+		}
+		return nil
+	}
+
+	mids := map[string]int{}
+	for i, media := range d.MediaDescriptions {
+		if mid := getMidValue(media); mid != "" {
+			mids[mid] = i
+		}
+	}
+
+	bundled := map[int]bool{}
+	for _, a := range d.Attributes {
+		fields := strings.Fields(a.Value)
+		if a.Key != sdp.AttrKeyGroup || len(fields) < 2 || fields[0] != "BUNDLE" {
+			continue
+		}
+
+		tagged := -1
+		for _, mid := range fields[1:] {
+			i, ok := mids[mid]
+			if !ok {
+				continue
+			}
+			bundled[i] = true
+			if tagged == -1 && carriesRTCP(d.MediaDescriptions[i]) {
+				tagged = i
+			}
+		}
+
+		if tagged != -1 {
+			if err := checkMedia(tagged, d.MediaDescriptions[tagged]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, media := range d.MediaDescriptions {
+		if bundled[i] || !carriesRTCP(media) {
+			continue
+		}
+		if err := checkMedia(i, media); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func descriptionIsPlanB(desc *SessionDescription) bool {
 	if desc == nil || desc.parsed == nil {
 		return false
@@ -662,3 +933,166 @@ func updateSDPOrigin(origin *sdp.Origin, d *sdp.SessionDescription) {
 		d.Origin.SessionVersion = atomic.AddUint64(&origin.SessionVersion, 1)
 	}
 }
+
+// voiceActivityDetection is the VoiceActivityDetection option of an offer or answer
+type voiceActivityDetection int
+
+const (
+	// voiceActivityDetectionUnchanged leaves the codecs of the MediaEngine as they are,
+	// which is the default of W3C that voice activity detection is enabled
+	voiceActivityDetectionUnchanged voiceActivityDetection = iota
+	voiceActivityDetectionEnabled
+	voiceActivityDetectionDisabled
+)
+
+func voiceActivityDetectionOf(options *OfferAnswerOptions) voiceActivityDetection {
+	switch {
+	case options == nil:
+		return voiceActivityDetectionUnchanged
+	case options.DisableVoiceActivityDetection:
+		return voiceActivityDetectionDisabled
+	case options.VoiceActivityDetection:
+		return voiceActivityDetectionEnabled
+	default:
+		return voiceActivityDetectionUnchanged
+	}
+}
+
+// withVoiceActivityDetection returns the audio codecs of a media section for the
+// VoiceActivityDetection option, JSEP Section 5.2.3.2. With voice activity detection
+// Opus is asked to use DTX, without it comfort noise codecs are removed and Opus is
+// asked not to use DTX.
+func withVoiceActivityDetection(codecs []RTPCodecParameters, vad voiceActivityDetection) []RTPCodecParameters {
+	if vad == voiceActivityDetectionUnchanged {
+		return codecs
+	}
+
+	filtered := make([]RTPCodecParameters, 0, len(codecs))
+	for _, codec := range codecs {
+		switch {
+		case strings.EqualFold(codec.MimeType, "audio/CN") && vad == voiceActivityDetectionDisabled:
+			continue
+		case strings.EqualFold(codec.MimeType, MimeTypeOpus):
+			codec.SDPFmtpLine = withOpusDTX(codec.SDPFmtpLine, vad == voiceActivityDetectionEnabled)
+		}
+		filtered = append(filtered, codec)
+	}
+
+	// A section that only has comfort noise keeps it, it can't be left without codecs
+	if len(filtered) == 0 {
+		return codecs
+	}
+	return filtered
+}
+
+// withOpusDTX returns the fmtp parameters of Opus with usedtx set for enabled, or removed
+func withOpusDTX(fmtp string, enabled bool) string {
+	params := []string{}
+	for _, param := range strings.Split(fmtp, ";") {
+		if param = strings.TrimSpace(param); param != "" && !strings.HasPrefix(strings.ToLower(param), "usedtx=") {
+			params = append(params, param)
+		}
+	}
+	if enabled {
+		params = append(params, "usedtx=1")
+	}
+	return strings.Join(params, ";")
+}
+
+// validateRemoteDescription checks a remote description against the rules of JSEP that
+// Pion otherwise tolerates or works around. The returned error wraps ErrSDPValidation and
+// names the offending media section, the first violation found is returned.
+func validateRemoteDescription(d *sdp.SessionDescription) error { //nolint:gocognit
+	mediaSectionError := func(i int, media *sdp.MediaDescription, format string, a ...interface{}) error {
+		return fmt.Errorf("%w: media section %d (m=%s %d %s %s, mid %q): %s", ErrSDPValidation,
+			i, media.MediaName.Media, media.MediaName.Port.Value, strings.Join(media.MediaName.Protos, "/"),
+			strings.Join(media.MediaName.Formats, " "), getMidValue(media), fmt.Sprintf(format, a...))
+	}
+
+	mids := map[string]int{}
+	for i, media := range d.MediaDescriptions {
+		mid := getMidValue(media)
+		if mid == "" {
+			return mediaSectionError(i, media, "a=mid is missing")
+		}
+		if previous, ok := mids[mid]; ok {
+			return mediaSectionError(i, media, "a=mid is already used by media section %d", previous)
+		}
+		mids[mid] = i
+
+		formats := map[string]bool{}
+		for _, format := range media.MediaName.Formats {
+			if formats[format] {
+				return mediaSectionError(i, media, "payload type %s is listed more than once", format)
+			}
+			formats[format] = true
+		}
+
+		rtpmaps := map[string]bool{}
+		for _, a := range media.Attributes {
+			if a.Key != "rtpmap" {
+				continue
+			}
+			payloadType := strings.SplitN(a.Value, " ", 2)[0]
+			if rtpmaps[payloadType] {
+				return mediaSectionError(i, media, "payload type %s has more than one a=rtpmap", payloadType)
+			}
+			if !formats[payloadType] {
+				return mediaSectionError(i, media, "a=rtpmap for payload type %s that isn't listed", payloadType)
+			}
+			rtpmaps[payloadType] = true
+		}
+	}
+
+	bundled := map[string]bool{}
+	for _, a := range d.Attributes {
+		if a.Key != sdp.AttrKeyGroup {
+			continue
+		}
+		fields := strings.Fields(a.Value)
+		if len(fields) == 0 || fields[0] != "BUNDLE" {
+			continue
+		}
+
+		// A payload type must identify the same codec in all media sections of a BUNDLE group,
+		// RFC 8843 Section 9.1.2
+		codecs := map[string]string{}
+		for _, mid := range fields[1:] {
+			i, ok := mids[mid]
+			if !ok {
+				return fmt.Errorf("%w: a=group:%s references mid %q without a media section", ErrSDPValidation, a.Value, mid)
+			}
+			bundled[mid] = true
+
+			media := d.MediaDescriptions[i]
+			for _, attr := range media.Attributes {
+				if attr.Key != "rtpmap" {
+					continue
+				}
+				split := strings.SplitN(attr.Value, " ", 2)
+				if len(split) != 2 {
+					continue
+				}
+				codec := strings.ToLower(split[1])
+				if previous, ok := codecs[split[0]]; ok && previous != codec {
+					return mediaSectionError(i, media, "payload type %s is %s, but %s in another media section of the BUNDLE group", split[0], split[1], previous)
+				}
+				codecs[split[0]] = codec
+			}
+		}
+	}
+
+	for i, media := range d.MediaDescriptions {
+		if !haveBundleOnly(media) {
+			continue
+		}
+		if !bundled[getMidValue(media)] {
+			return mediaSectionError(i, media, "a=bundle-only outside of a BUNDLE group")
+		}
+		if media.MediaName.Port.Value != 0 {
+			return mediaSectionError(i, media, "a=bundle-only with a non-zero port")
+		}
+	}
+
+	return nil
+}
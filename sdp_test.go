@@ -267,6 +267,66 @@ func TestTrackDetailsFromSDP(t *testing.T) {
 		}
 		assert.Equal(t, 0, len(trackDetailsFromSDP(nil, s)))
 	})
+
+	t.Run("FlexFEC stream", func(t *testing.T) {
+		s := &sdp.SessionDescription{
+			MediaDescriptions: []*sdp.MediaDescription{
+				{
+					MediaName: sdp.MediaName{
+						Media: "video",
+					},
+					Attributes: []sdp.Attribute{
+						{Key: "mid", Value: "0"},
+						{Key: "sendrecv"},
+						{Key: "ssrc-group", Value: "FEC-FR 3000 4000"},
+						{Key: "ssrc", Value: "3000 msid:video_trk_label video_trk_guid"},
+						{Key: "ssrc", Value: "4000 msid:video_trk_label video_trk_guid"},
+					},
+				},
+			},
+		}
+
+		tracks := trackDetailsFromSDP(nil, s)
+		assert.Equal(t, 1, len(tracks))
+		if track := trackDetailsForSSRC(tracks, 3000); track == nil {
+			assert.Fail(t, "missing video track with ssrc:3000")
+		} else {
+			assert.Equal(t, SSRC(4000), track.fecSSRC)
+		}
+	})
+
+	t.Run("Simulcast with RTX", func(t *testing.T) {
+		s := &sdp.SessionDescription{
+			MediaDescriptions: []*sdp.MediaDescription{
+				{
+					MediaName: sdp.MediaName{
+						Media: "video",
+					},
+					Attributes: []sdp.Attribute{
+						{Key: "mid", Value: "0"},
+						{Key: "sendrecv"},
+						{Key: "ssrc-group", Value: "SIM 1000 2000 3000"},
+						{Key: "ssrc-group", Value: "FID 1000 1001"},
+						{Key: "ssrc-group", Value: "FID 2000 2001"},
+						{Key: "ssrc", Value: "1000 msid:video_trk_label video_trk_guid"},
+						{Key: "ssrc", Value: "1001 msid:video_trk_label video_trk_guid"},
+						{Key: "ssrc", Value: "2000 msid:video_trk_label video_trk_guid"},
+						{Key: "ssrc", Value: "2001 msid:video_trk_label video_trk_guid"},
+						{Key: "ssrc", Value: "3000 msid:video_trk_label video_trk_guid"},
+					},
+				},
+			},
+		}
+
+		tracks := trackDetailsFromSDP(nil, s)
+		assert.Equal(t, 1, len(tracks))
+		if track := trackDetailsForSSRC(tracks, 1000); track == nil {
+			assert.Fail(t, "missing video track with ssrc:1000")
+		} else {
+			assert.Equal(t, SSRC(1001), track.rtxSSRC)
+			assert.Equal(t, []simulcastLayer{{ssrc: 2000, rtxSSRC: 2001}, {ssrc: 3000}}, track.simulcastLayers)
+		}
+	})
 }
 
 func TestHaveApplicationMediaSection(t *testing.T) {
@@ -353,7 +413,7 @@ func TestMediaDescriptionFingerprints(t *testing.T) {
 			s, err = populateSDP(s, false,
 				dtlsFingerprints,
 				SDPMediaDescriptionFingerprints,
-				false, engine, sdp.ConnectionRoleActive, []ICECandidate{}, ICEParameters{}, media, ICEGatheringStateNew)
+				false, false, engine, sdp.ConnectionRoleActive, []ICECandidate{}, ICEParameters{}, media, ICEGatheringStateNew)
 			assert.NoError(t, err)
 
 			sdparray, err := s.Marshal()
@@ -384,7 +444,7 @@ func TestPopulateSDP(t *testing.T) {
 
 		d := &sdp.SessionDescription{}
 
-		offerSdp, err := populateSDP(d, false, []DTLSFingerprint{}, se.sdpMediaLevelFingerprints, se.candidates.ICELite, me, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), []ICECandidate{}, ICEParameters{}, mediaSections, ICEGatheringStateComplete)
+		offerSdp, err := populateSDP(d, false, []DTLSFingerprint{}, se.sdpMediaLevelFingerprints, se.candidates.ICELite, false, me, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), []ICECandidate{}, ICEParameters{}, mediaSections, ICEGatheringStateComplete)
 		assert.Nil(t, err)
 
 		// Test contains rid map keys
@@ -427,7 +487,7 @@ func TestPopulateSDP(t *testing.T) {
 
 		d := &sdp.SessionDescription{}
 
-		offerSdp, err := populateSDP(d, false, []DTLSFingerprint{}, se.sdpMediaLevelFingerprints, se.candidates.ICELite, me, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), []ICECandidate{}, ICEParameters{}, mediaSections, ICEGatheringStateComplete)
+		offerSdp, err := populateSDP(d, false, []DTLSFingerprint{}, se.sdpMediaLevelFingerprints, se.candidates.ICELite, false, me, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), []ICECandidate{}, ICEParameters{}, mediaSections, ICEGatheringStateComplete)
 		assert.Nil(t, err)
 
 		// Test codecs
@@ -448,6 +508,39 @@ func TestPopulateSDP(t *testing.T) {
 		}
 		assert.Equal(t, true, foundVP8, "vp8 should be present in sdp")
 	})
+	t.Run("BundleOnly", func(t *testing.T) {
+		se := SettingEngine{}
+
+		me := &MediaEngine{}
+		assert.NoError(t, me.RegisterDefaultCodecs())
+		api := NewAPI(WithMediaEngine(me))
+
+		audio := &RTPTransceiver{kind: RTPCodecTypeAudio, api: api, codecs: me.audioCodecs}
+		audio.setDirection(RTPTransceiverDirectionRecvonly)
+		video := &RTPTransceiver{kind: RTPCodecTypeVideo, api: api, codecs: me.videoCodecs}
+		video.setDirection(RTPTransceiverDirectionRecvonly)
+		mediaSections := []mediaSection{
+			{id: "0", transceivers: []*RTPTransceiver{audio}},
+			{id: "1", transceivers: []*RTPTransceiver{video}},
+			{id: "2", data: true},
+		}
+
+		d := &sdp.SessionDescription{}
+
+		offerSdp, err := populateSDP(d, false, []DTLSFingerprint{}, se.sdpMediaLevelFingerprints, se.candidates.ICELite, true, me, connectionRoleFromDtlsRole(defaultDtlsRoleOffer), []ICECandidate{}, ICEParameters{}, mediaSections, ICEGatheringStateComplete)
+		assert.Nil(t, err)
+
+		bundle, ok := offerSdp.Attribute(sdp.AttrKeyGroup)
+		assert.True(t, ok)
+		assert.Equal(t, "BUNDLE 0 1 2", bundle)
+
+		assert.Equal(t, 9, offerSdp.MediaDescriptions[0].MediaName.Port.Value)
+		assert.False(t, haveBundleOnly(offerSdp.MediaDescriptions[0]))
+		for _, media := range offerSdp.MediaDescriptions[1:] {
+			assert.Equal(t, 0, media.MediaName.Port.Value)
+			assert.True(t, haveBundleOnly(media))
+		}
+	})
 }
 
 func TestGetRIDs(t *testing.T) {
@@ -532,3 +625,113 @@ func TestRtpExtensionsFromMediaDescription(t *testing.T) {
 	assert.Equal(t, extensions[sdp.ABSSendTimeURI], 1)
 	assert.Equal(t, extensions[sdp.SDESMidURI], 3)
 }
+
+func TestWithVoiceActivityDetection(t *testing.T) {
+	opus := RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeTypeOpus, 48000, 2, "minptime=10;usedtx=1;useinbandfec=1", nil},
+		PayloadType:        111,
+	}
+	comfortNoise := RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{"audio/CN", 8000, 0, "", nil},
+		PayloadType:        13,
+	}
+	codecs := []RTPCodecParameters{opus, comfortNoise}
+
+	assert.Equal(t, codecs, withVoiceActivityDetection(codecs, voiceActivityDetectionUnchanged))
+
+	disabled := withVoiceActivityDetection(codecs, voiceActivityDetectionDisabled)
+	assert.Len(t, disabled, 1)
+	assert.Equal(t, "minptime=10;useinbandfec=1", disabled[0].SDPFmtpLine)
+
+	enabled := withVoiceActivityDetection(codecs, voiceActivityDetectionEnabled)
+	assert.Len(t, enabled, 2)
+	assert.Equal(t, "minptime=10;useinbandfec=1;usedtx=1", enabled[0].SDPFmtpLine)
+
+	// The codecs of the MediaEngine aren't modified
+	assert.Equal(t, "minptime=10;usedtx=1;useinbandfec=1", codecs[0].SDPFmtpLine)
+
+	// A section that only has comfort noise keeps it
+	assert.Equal(t, []RTPCodecParameters{comfortNoise}, withVoiceActivityDetection([]RTPCodecParameters{comfortNoise}, voiceActivityDetectionDisabled))
+
+	assert.Equal(t, voiceActivityDetectionUnchanged, voiceActivityDetectionOf(nil))
+	assert.Equal(t, voiceActivityDetectionUnchanged, voiceActivityDetectionOf(&OfferAnswerOptions{}))
+	assert.Equal(t, voiceActivityDetectionEnabled, voiceActivityDetectionOf(&OfferAnswerOptions{VoiceActivityDetection: true}))
+	assert.Equal(t, voiceActivityDetectionDisabled, voiceActivityDetectionOf(&OfferAnswerOptions{VoiceActivityDetection: true, DisableVoiceActivityDetection: true}))
+}
+
+func TestValidateRemoteDescription(t *testing.T) {
+	valid := func() *sdp.SessionDescription {
+		return &sdp.SessionDescription{
+			Attributes: []sdp.Attribute{{Key: sdp.AttrKeyGroup, Value: "BUNDLE 0 1"}},
+			MediaDescriptions: []*sdp.MediaDescription{
+				{
+					MediaName: sdp.MediaName{Media: "video", Port: sdp.RangedPort{Value: 9}, Protos: []string{"UDP", "TLS", "RTP", "SAVPF"}, Formats: []string{"96", "97"}},
+					Attributes: []sdp.Attribute{
+						{Key: "mid", Value: "0"},
+						{Key: "rtpmap", Value: "96 VP8/90000"},
+						{Key: "rtpmap", Value: "97 rtx/90000"},
+					},
+				},
+				{
+					MediaName: sdp.MediaName{Media: "video", Port: sdp.RangedPort{Value: 0}, Protos: []string{"UDP", "TLS", "RTP", "SAVPF"}, Formats: []string{"96"}},
+					Attributes: []sdp.Attribute{
+						{Key: "mid", Value: "1"},
+						{Key: "bundle-only"},
+						{Key: "rtpmap", Value: "96 vp8/90000"},
+					},
+				},
+			},
+		}
+	}
+	assert.NoError(t, validateRemoteDescription(valid()))
+
+	for _, test := range []struct {
+		name   string
+		modify func(d *sdp.SessionDescription)
+		err    string
+	}{
+		{"MissingMid", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[1].Attributes = d.MediaDescriptions[1].Attributes[1:]
+		}, `media section 1 (m=video 0 UDP/TLS/RTP/SAVPF 96, mid ""): a=mid is missing`},
+		{"DuplicateMid", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[1].Attributes[0].Value = "0"
+		}, "a=mid is already used by media section 0"},
+		{"DuplicateFormat", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[0].MediaName.Formats = []string{"96", "97", "96"}
+		}, "media section 0 (m=video 9 UDP/TLS/RTP/SAVPF 96 97 96, mid \"0\"): payload type 96 is listed more than once"},
+		{"DuplicateRtpmap", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[0].Attributes[2].Value = "96 H264/90000"
+		}, "payload type 96 has more than one a=rtpmap"},
+		{"UnlistedRtpmap", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[0].MediaName.Formats = []string{"96"}
+		}, "a=rtpmap for payload type 97 that isn't listed"},
+		{"UnknownBundleMid", func(d *sdp.SessionDescription) {
+			d.Attributes[0].Value = "BUNDLE 0 1 2"
+		}, `a=group:BUNDLE 0 1 2 references mid "2" without a media section`},
+		{"BundledPayloadTypeConflict", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[1].Attributes[2].Value = "96 VP9/90000"
+		}, "media section 1 (m=video 0 UDP/TLS/RTP/SAVPF 96, mid \"1\"): payload type 96 is VP9/90000, but vp8/90000 in another media section of the BUNDLE group"},
+		{"BundleOnlyNotBundled", func(d *sdp.SessionDescription) {
+			d.Attributes[0].Value = "BUNDLE 0"
+		}, "a=bundle-only outside of a BUNDLE group"},
+		{"BundleOnlyWithPort", func(d *sdp.SessionDescription) {
+			d.MediaDescriptions[1].MediaName.Port.Value = 9
+		}, "a=bundle-only with a non-zero port"},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			d := valid()
+			test.modify(d)
+
+			err := validateRemoteDescription(d)
+			assert.ErrorIs(t, err, ErrSDPValidation)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+
+	// Payload types of media sections that aren't bundled don't have to match
+	d := valid()
+	d.Attributes = nil
+	d.MediaDescriptions[1].Attributes = []sdp.Attribute{{Key: "mid", Value: "1"}, {Key: "rtpmap", Value: "96 VP9/90000"}}
+	assert.NoError(t, validateRemoteDescription(d))
+}
@@ -0,0 +1,119 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3/internal/util"
+)
+
+// Quirks of older browsers applySDPQuirks rewrites
+const (
+	sdpQuirkMissingMid     = "media section without mid"
+	sdpQuirkMsidWithoutID  = "msid without track id"
+	sdpQuirkBundledRTCPMux = "bundled media section without rtcp-mux"
+)
+
+// applySDPQuirks rewrites a remote description with the quirks of older browsers into
+// one that can be negotiated, and returns the quirks it found:
+//   - media sections without a mid, e.g. the data section of older Safari, get the
+//     lowest numeric mid that isn't taken
+//   - a msid without the track id, in a=msid or a=ssrc, gets the track id of another
+//     msid of the media section, or a random one as browsers do
+//   - media sections of a BUNDLE group that leave out a=rtcp-mux get it, if another
+//     media section of the group has it. RTCP is multiplexed on the shared transport.
+func applySDPQuirks(d *sdp.SessionDescription) (quirks []string) {
+	found := map[string]bool{}
+	addQuirk := func(quirk string) {
+		if !found[quirk] {
+			found[quirk] = true
+			quirks = append(quirks, quirk)
+		}
+	}
+
+	mids := map[string]bool{}
+	for _, media := range d.MediaDescriptions {
+		mids[getMidValue(media)] = true
+	}
+	nextMid := 0
+	for _, media := range d.MediaDescriptions {
+		if getMidValue(media) != "" {
+			continue
+		}
+		for mids[strconv.Itoa(nextMid)] {
+			nextMid++
+		}
+		mids[strconv.Itoa(nextMid)] = true
+		media.WithValueAttribute(sdp.AttrKeyMID, strconv.Itoa(nextMid))
+		addQuirk(sdpQuirkMissingMid)
+	}
+
+	for _, media := range d.MediaDescriptions {
+		if completeMsids(media) {
+			addQuirk(sdpQuirkMsidWithoutID)
+		}
+	}
+
+	for _, attr := range d.Attributes {
+		fields := strings.Fields(attr.Value)
+		if attr.Key != sdp.AttrKeyGroup || len(fields) == 0 || fields[0] != "BUNDLE" {
+			continue
+		}
+
+		bundled := []*sdp.MediaDescription{}
+		rtcpMux := false
+		for _, mid := range fields[1:] {
+			if media := mediaSectionForMid(d, mid); media != nil && media.MediaName.Media != mediaSectionApplication {
+				bundled = append(bundled, media)
+				if _, ok := media.Attribute(sdp.AttrKeyRTCPMux); ok {
+					rtcpMux = true
+				}
+			}
+		}
+		if !rtcpMux {
+			continue
+		}
+		for _, media := range bundled {
+			if _, ok := media.Attribute(sdp.AttrKeyRTCPMux); !ok {
+				media.WithPropertyAttribute(sdp.AttrKeyRTCPMux)
+				addQuirk(sdpQuirkBundledRTCPMux)
+			}
+		}
+	}
+
+	return quirks
+}
+
+// completeMsids adds the track id to the msids of a media section that leave it out,
+// `a=msid:<stream_id>` and `a=ssrc:<ssrc> msid:<stream_id>`, and reports if it did
+func completeMsids(media *sdp.MediaDescription) bool {
+	trackID := ""
+	incomplete := []int{}
+	for i, attr := range media.Attributes {
+		fields := strings.Fields(attr.Value)
+		switch {
+		case attr.Key == sdp.AttrKeyMsid && len(fields) == 2,
+			attr.Key == sdp.AttrKeySSRC && len(fields) == 3 && strings.HasPrefix(fields[1], "msid:"):
+			if trackID == "" {
+				trackID = fields[len(fields)-1]
+			}
+		case attr.Key == sdp.AttrKeyMsid && len(fields) == 1,
+			attr.Key == sdp.AttrKeySSRC && len(fields) == 2 && strings.HasPrefix(fields[1], "msid:"):
+			incomplete = append(incomplete, i)
+		}
+	}
+	if len(incomplete) == 0 {
+		return false
+	}
+
+	if trackID == "" {
+		trackID = util.MathRandAlpha(16)
+	}
+	for _, i := range incomplete {
+		media.Attributes[i].Value = strings.Join(append(strings.Fields(media.Attributes[i].Value), trackID), " ")
+	}
+	return true
+}
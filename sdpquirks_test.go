@@ -0,0 +1,96 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySDPQuirks(t *testing.T) {
+	const legacy = `v=0
+o=- 6229813342891226114 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 1 2
+m=application 9 DTLS/SCTP 5000
+c=IN IP4 0.0.0.0
+a=sctpmap:5000 webrtc-datachannel 1024
+m=audio 9 UDP/TLS/RTP/SAVPF 111
+c=IN IP4 0.0.0.0
+a=mid:1
+a=sendrecv
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=ssrc:2087623479 msid:stream audio
+m=video 9 UDP/TLS/RTP/SAVPF 96
+c=IN IP4 0.0.0.0
+a=mid:2
+a=sendrecv
+a=msid:stream
+a=rtpmap:96 VP8/90000
+a=ssrc:3820487211 msid:stream
+m=video 9 UDP/TLS/RTP/SAVPF 96
+c=IN IP4 0.0.0.0
+a=sendrecv
+a=rtpmap:96 VP8/90000
+a=ssrc:1249620451 msid:other
+`
+
+	parsed := &sdp.SessionDescription{}
+	assert.NoError(t, parsed.Unmarshal([]byte(strings.ReplaceAll(legacy, "\n", "\r\n"))))
+
+	assert.Equal(t, []string{sdpQuirkMissingMid, sdpQuirkMsidWithoutID, sdpQuirkBundledRTCPMux}, applySDPQuirks(parsed))
+
+	// The lowest mids that aren't taken
+	assert.Equal(t, "0", getMidValue(parsed.MediaDescriptions[0]))
+	assert.Equal(t, "3", getMidValue(parsed.MediaDescriptions[3]))
+
+	// Only the video section of the BUNDLE group carries RTP without a=rtcp-mux
+	_, ok := parsed.MediaDescriptions[2].Attribute(sdp.AttrKeyRTCPMux)
+	assert.True(t, ok)
+	_, ok = parsed.MediaDescriptions[3].Attribute(sdp.AttrKeyRTCPMux)
+	assert.False(t, ok)
+
+	tracks := trackDetailsFromSDP(nil, parsed)
+	assert.Equal(t, 3, len(tracks))
+	assert.Equal(t, "stream", tracks[0].streamID)
+	assert.Equal(t, "audio", tracks[0].id)
+	assert.Equal(t, "stream", tracks[1].streamID)
+	assert.NotEmpty(t, tracks[1].id)
+	streamID, trackID := getMsid(parsed.MediaDescriptions[2])
+	assert.Equal(t, "stream", streamID)
+	assert.Equal(t, tracks[1].id, trackID)
+	assert.Equal(t, "other", tracks[2].streamID)
+	assert.NotEmpty(t, tracks[2].id)
+
+	// A description without quirks stays as it is
+	assert.Empty(t, applySDPQuirks(parsed))
+}
+
+// Offers leave a=rtcp-mux-only out when the quirks are always handled, even if it is enabled
+func TestPeerConnection_SDPQuirksMode(t *testing.T) {
+	for _, mode := range []SDPQuirksMode{SDPQuirksModeAuto, SDPQuirksModeAlways} {
+		m := &MediaEngine{}
+		assert.NoError(t, m.RegisterDefaultCodecs())
+		s := SettingEngine{}
+		s.SetSDPQuirksMode(mode)
+		s.EnableRTCPMuxOnly(true)
+		pc, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).NewPeerConnection(Configuration{})
+		assert.NoError(t, err)
+
+		_, err = pc.CreateDataChannel("quirks", nil)
+		assert.NoError(t, err)
+		_, err = pc.AddTransceiverFromKind(RTPCodecTypeVideo, RTPTransceiverInit{Direction: RTPTransceiverDirectionRecvonly})
+		assert.NoError(t, err)
+
+		offer, err := pc.CreateOffer(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, mode == SDPQuirksModeAuto, strings.Contains(offer.SDP, "a=rtcp-mux-only"), mode.String())
+
+		assert.NoError(t, pc.Close())
+	}
+}
@@ -0,0 +1,41 @@
+// +build !js
+
+package webrtc
+
+// SDPQuirksMode determines how remote descriptions with the SDP quirks of older
+// browsers, e.g. Safari before Unified Plan, are handled. See SettingEngine.SetSDPQuirksMode
+type SDPQuirksMode int
+
+const (
+	// SDPQuirksModeAuto rewrites a remote description that has a quirk, so it can be
+	// negotiated. This is the default.
+	SDPQuirksModeAuto SDPQuirksMode = iota
+
+	// SDPQuirksModeAlways also leaves the attributes older browsers don't understand
+	// out of offers. Their quirks can only be detected in their own descriptions.
+	SDPQuirksModeAlways
+
+	// SDPQuirksModeNever handles remote descriptions as they are, descriptions with
+	// quirks are rejected or lose information
+	SDPQuirksModeNever
+)
+
+// This is done this way because of a linter.
+const (
+	sdpQuirksModeAutoStr   = "auto"
+	sdpQuirksModeAlwaysStr = "always"
+	sdpQuirksModeNeverStr  = "never"
+)
+
+func (m SDPQuirksMode) String() string {
+	switch m {
+	case SDPQuirksModeAuto:
+		return sdpQuirksModeAutoStr
+	case SDPQuirksModeAlways:
+		return sdpQuirksModeAlwaysStr
+	case SDPQuirksModeNever:
+		return sdpQuirksModeNeverStr
+	default:
+		return ErrUnknownType.Error()
+	}
+}
@@ -0,0 +1,29 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSDPQuirksMode_String(t *testing.T) {
+	testCases := []struct {
+		mode           SDPQuirksMode
+		expectedString string
+	}{
+		{SDPQuirksModeAuto, "auto"},
+		{SDPQuirksModeAlways, "always"},
+		{SDPQuirksModeNever, "never"},
+		{SDPQuirksMode(42), "unknown"},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.expectedString,
+			testCase.mode.String(),
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}
@@ -225,6 +225,60 @@ func TestSDPSemantics_PlanBAnswerSenders(t *testing.T) {
 	closePairNow(t, apc, opc)
 }
 
+func TestSDPSemantics_AnswerRecvonlyWithoutTracks(t *testing.T) {
+	report := test.CheckRoutines(t)
+	defer report()
+
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	for _, semantics := range []SDPSemantics{SDPSemanticsUnifiedPlan, SDPSemanticsPlanB} {
+		semantics := semantics
+		t.Run(semantics.String(), func(t *testing.T) {
+			opc, err := NewPeerConnection(Configuration{SDPSemantics: semantics})
+			assert.NoError(t, err)
+
+			for _, direction := range []RTPTransceiverDirection{RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendonly} {
+				track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", direction.String())
+				assert.NoError(t, err)
+
+				_, err = opc.AddTransceiverFromTrack(track, RTPTransceiverInit{Direction: direction})
+				assert.NoError(t, err)
+
+				if semantics == SDPSemanticsPlanB {
+					break
+				}
+			}
+
+			offer, err := opc.CreateOffer(nil)
+			assert.NoError(t, err)
+
+			apc, err := NewPeerConnection(Configuration{SDPSemantics: semantics})
+			assert.NoError(t, err)
+
+			assert.NoError(t, apc.SetRemoteDescription(offer))
+
+			answer, err := apc.CreateAnswer(nil)
+			assert.NoError(t, err)
+
+			videoSections := 0
+			for _, media := range answer.parsed.MediaDescriptions {
+				if media.MediaName.Media != "video" {
+					continue
+				}
+				videoSections++
+
+				assert.NotZero(t, media.MediaName.Port.Value)
+				assert.NotEmpty(t, media.MediaName.Formats)
+				assert.Equal(t, RTPTransceiverDirectionRecvonly, getPeerDirection(media))
+			}
+			assert.NotZero(t, videoSections)
+
+			closePairNow(t, apc, opc)
+		})
+	}
+}
+
 func TestSDPSemantics_UnifiedPlanWithFallback(t *testing.T) {
 	report := test.CheckRoutines(t)
 	defer report()
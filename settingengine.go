@@ -4,16 +4,27 @@ package webrtc
 
 import (
 	"io"
+	"net"
 	"time"
 
 	"github.com/pion/dtls/v2"
 	"github.com/pion/ice/v2"
 	"github.com/pion/logging"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/transport/packetio"
 	"github.com/pion/transport/vnet"
 	"golang.org/x/net/proxy"
 )
 
+// Timers of EnableFastSetup
+const (
+	fastSetupICECheckInterval           = 50 * time.Millisecond
+	fastSetupSrflxAcceptanceMinWait     = 100 * time.Millisecond
+	fastSetupPrflxAcceptanceMinWait     = 200 * time.Millisecond
+	fastSetupRelayAcceptanceMinWait     = 500 * time.Millisecond
+	fastSetupDTLSRetransmissionInterval = 100 * time.Millisecond
+)
+
 // SettingEngine allows influencing behavior in ways that are not
 // supported by the WebRTC API. This allows us to support additional
 // use-cases without deviating from the WebRTC API elsewhere.
@@ -26,13 +37,16 @@ type SettingEngine struct {
 		DataChannels bool
 	}
 	timeout struct {
-		ICEDisconnectedTimeout    *time.Duration
-		ICEFailedTimeout          *time.Duration
-		ICEKeepaliveInterval      *time.Duration
-		ICEHostAcceptanceMinWait  *time.Duration
-		ICESrflxAcceptanceMinWait *time.Duration
-		ICEPrflxAcceptanceMinWait *time.Duration
-		ICERelayAcceptanceMinWait *time.Duration
+		ICEDisconnectedTimeout     *time.Duration
+		ICEFailedTimeout           *time.Duration
+		ICEKeepaliveInterval       *time.Duration
+		ICECheckInterval           *time.Duration
+		ICEHostAcceptanceMinWait   *time.Duration
+		ICESrflxAcceptanceMinWait  *time.Duration
+		ICEPrflxAcceptanceMinWait  *time.Duration
+		ICERelayAcceptanceMinWait  *time.Duration
+		DTLSRetransmissionInterval *time.Duration
+		DTLSHandshakeTimeout       *time.Duration
 	}
 	candidates struct {
 		ICELite                bool
@@ -44,12 +58,40 @@ type SettingEngine struct {
 		MulticastDNSHostName   string
 		UsernameFragment       string
 		Password               string
+		PriorityFunc           func(ICECandidate) uint32
+		RewriteFunc            func(ICECandidate) (ICECandidate, bool)
+		PortMapper             PortMapper
+		MaxRemoteCandidates    int
+		TURNCredentialFunc     func(url string) (username, password string, err error)
+		NAT64Prefix            *net.IPNet
+		ServerProbeTimeout     time.Duration
 	}
 	replayProtection struct {
 		DTLS  *uint
 		SRTP  *uint
 		SRTCP *uint
 	}
+	networkMonitor struct {
+		Interval   time.Duration
+		RestartICE bool
+	}
+	dtls struct {
+		CipherSuites   []dtls.CipherSuiteID
+		MTU            int
+		InsecureHashes bool
+		KeyLogWriter   io.Writer
+	}
+	sctp struct {
+		MaxReceiveBufferSize uint32
+		SendCoalescingDelay  time.Duration
+		MaxDataChannels      uint16
+		MaxPendingChannels   uint16
+		Protocols            []string
+	}
+	udpSocketBuffers struct {
+		ReceiveBufferSize int
+		SendBufferSize    int
+	}
 	sdpMediaLevelFingerprints                 bool
 	answeringDTLSRole                         DTLSRole
 	disableCertificateFingerprintVerification bool
@@ -58,11 +100,25 @@ type SettingEngine struct {
 	vnet                                      *vnet.Net
 	BufferFactory                             func(packetType packetio.BufferPacketType, ssrc uint32) io.ReadWriteCloser
 	LoggerFactory                             logging.LoggerFactory
+	logLevels                                 *logLevels
 	iceTCPMux                                 ice.TCPMux
 	iceUDPMux                                 ice.UDPMux
 	iceProxyDialer                            proxy.Dialer
 	disableMediaEngineCopy                    bool
+	mediaSectionRejection                     bool
 	srtpProtectionProfiles                    []dtls.SRTPProtectionProfile
+	sdpSemantics                              SDPSemantics
+	localSDPHook                              func(SDPType, *sdp.SessionDescription) error
+	remoteSDPHook                             func(SDPType, *sdp.SessionDescription) error
+	strictSDPValidation                       bool
+	maxRTPPacketAge                           time.Duration
+	remoteTrackMuteTimeout                    time.Duration
+	earlyMediaHandling                        EarlyMediaHandling
+	sdpQuirksMode                             SDPQuirksMode
+	closeReasons                              bool
+	closeReasonsDataChannelID                 *uint16
+	rtcpMuxOnly                               bool
+	packetTap                                 func(direction PacketTapDirection, isRTCP bool, packet []byte)
 }
 
 // DetachDataChannels enables detaching data channels. When enabled
@@ -72,6 +128,33 @@ func (e *SettingEngine) DetachDataChannels() {
 	e.detach.DataChannels = true
 }
 
+// SetDTLSCipherSuites overrides the cipher suites offered and accepted by the DTLS
+// handshake, the default are all cipher suites supported by pion/dtls. The cipher
+// suites have to match the certificates of the PeerConnections, which use ECDSA
+// by default.
+func (e *SettingEngine) SetDTLSCipherSuites(cipherSuites ...dtls.CipherSuiteID) {
+	e.dtls.CipherSuites = cipherSuites
+}
+
+// SetDTLSMTU sets the maximum size of DTLS records, larger handshake messages are
+// fragmented. It defaults to 1200 bytes.
+func (e *SettingEngine) SetDTLSMTU(mtu int) {
+	e.dtls.MTU = mtu
+}
+
+// SetDTLSInsecureHashes allows the remote to use the insecure MD5 and SHA-1 hashes in
+// signatures of the DTLS handshake. It should only be used to test with old peers.
+func (e *SettingEngine) SetDTLSInsecureHashes(insecureHashes bool) {
+	e.dtls.InsecureHashes = insecureHashes
+}
+
+// SetDTLSKeyLogWriter sets a writer the secrets of DTLS connections are logged to in
+// NSS key log format, so tools like Wireshark can decrypt captured sessions. This
+// compromises the security of the connections and should only be used for debugging.
+func (e *SettingEngine) SetDTLSKeyLogWriter(writer io.Writer) {
+	e.dtls.KeyLogWriter = writer
+}
+
 // SetSRTPProtectionProfiles allows the user to override the default SRTP Protection Profiles
 // The default srtp protection profiles are provided by the function `defaultSrtpProtectionProfiles`
 func (e *SettingEngine) SetSRTPProtectionProfiles(profiles ...dtls.SRTPProtectionProfile) {
@@ -88,6 +171,86 @@ func (e *SettingEngine) SetICETimeouts(disconnectedTimeout, failedTimeout, keepA
 	e.timeout.ICEKeepaliveInterval = &keepAliveInterval
 }
 
+// SetICECheckInterval sets the pacing of ICE connectivity checks (the Ta timer of RFC 8445).
+// Every interval the ICEAgent sends a binding request for each candidate pair it is checking,
+// a longer interval avoids bursts of packets on constrained devices but slows down connecting.
+// The ICEAgent has no limit on the number of pairs checked at once.
+func (e *SettingEngine) SetICECheckInterval(interval time.Duration) {
+	e.timeout.ICECheckInterval = &interval
+}
+
+// EnableFastSetup tunes the timers of ICE and DTLS for a fast connection setup, e.g. for
+// audio-only calls of voice assistants and intercom devices. Together with a MediaEngine
+// that only has Opus registered (see MediaEngine.RegisterOpusCodec) connections on a LAN
+// are usually set up in well under 500ms. EnableFastSetup
+//   - only gathers IPv4 UDP candidates, halving the number of candidate pairs to check
+//   - checks candidate pairs every 50ms, the Ta of RFC 8445, instead of every 200ms
+//   - nominates server reflexive, peer reflexive and relay candidates sooner
+//   - retransmits lost DTLS handshake flights after 100ms instead of one second
+// The values can be changed afterwards with the other setters.
+func (e *SettingEngine) EnableFastSetup() {
+	e.SetNetworkTypes([]NetworkType{NetworkTypeUDP4})
+	e.SetICECheckInterval(fastSetupICECheckInterval)
+	e.SetHostAcceptanceMinWait(0)
+	e.SetSrflxAcceptanceMinWait(fastSetupSrflxAcceptanceMinWait)
+	e.SetPrflxAcceptanceMinWait(fastSetupPrflxAcceptanceMinWait)
+	e.SetRelayAcceptanceMinWait(fastSetupRelayAcceptanceMinWait)
+	e.SetDTLSRetransmissionInterval(fastSetupDTLSRetransmissionInterval)
+}
+
+// SetSCTPMaxReceiveBufferSize sets the size of the SCTP receive buffer, which is the
+// receive window announced to the remote. Messages that haven't been read from the
+// DataChannels count against it, once it is full the remote stops sending. The default
+// of pion/sctp is 1 MiB, 0 keeps it.
+func (e *SettingEngine) SetSCTPMaxReceiveBufferSize(maxReceiveBufferSize uint32) {
+	e.sctp.MaxReceiveBufferSize = maxReceiveBufferSize
+}
+
+// SetSCTPMaxDataChannels limits the number of DataChannels that can be open at the same
+// time, which is reported by SCTPTransport.MaxChannels. Creating a DataChannel beyond the
+// limit fails with ErrMaxDataChannels, and DataChannels the remote opens beyond it are
+// closed right away. The default of 0 allows as many as there are stream IDs.
+func (e *SettingEngine) SetSCTPMaxDataChannels(max uint16) {
+	e.sctp.MaxDataChannels = max
+}
+
+// SetSCTPMaxPendingDataChannels limits the number of DataChannels announced by the remote
+// that are held until a handler is set with PeerConnection.OnDataChannel. Further ones
+// are closed right away. The default of 0 holds all of them, which keeps every
+// DataChannel of a remote that opens many before the handler is set.
+func (e *SettingEngine) SetSCTPMaxPendingDataChannels(max uint16) {
+	e.sctp.MaxPendingChannels = max
+}
+
+// SetDataChannelProtocols limits the sub-protocols of the DataChannels the remote opens,
+// others are closed right away like the ones rejected by SCTPTransport.OnDataChannelRequest,
+// which is invoked for the allowed ones. The empty string allows DataChannels without
+// a protocol. By default, or when called without protocols, all are allowed.
+func (e *SettingEngine) SetDataChannelProtocols(protocols ...string) {
+	e.sctp.Protocols = protocols
+}
+
+// SetSCTPSendCoalescing holds small DataChannel messages for up to delay before they are
+// handed to SCTP together, so they are bundled into a few packets instead of one packet
+// each, like Nagle's algorithm does for TCP. This trades latency for a lot less overhead
+// when many tiny messages are sent. Messages keep their order, and are written as soon as
+// a packet's worth is held. Errors writing held messages are passed to OnError. The
+// default of 0 writes every message immediately.
+func (e *SettingEngine) SetSCTPSendCoalescing(delay time.Duration) {
+	e.sctp.SendCoalescingDelay = delay
+}
+
+// SetNetworkMonitor makes PeerConnections poll the addresses of the local network interfaces
+// every interval, and fire OnNetworkChange when they changed, e.g. when a mobile device
+// switched from WiFi to LTE. If restartICE is set the PeerConnections also call RestartICE,
+// which fires OnNegotiationNeeded to send an offer that restarts ICE and gathers candidates
+// for the new addresses. All PeerConnections of an API share one monitor, it runs while
+// at least one of them is open. An interval of 0 disables the monitor, which is the default.
+func (e *SettingEngine) SetNetworkMonitor(interval time.Duration, restartICE bool) {
+	e.networkMonitor.Interval = interval
+	e.networkMonitor.RestartICE = restartICE
+}
+
 // SetHostAcceptanceMinWait sets the ICEHostAcceptanceMinWait
 func (e *SettingEngine) SetHostAcceptanceMinWait(t time.Duration) {
 	e.timeout.ICEHostAcceptanceMinWait = &t
@@ -108,6 +271,18 @@ func (e *SettingEngine) SetRelayAcceptanceMinWait(t time.Duration) {
 	e.timeout.ICERelayAcceptanceMinWait = &t
 }
 
+// SetDTLSRetransmissionInterval sets the retransmission interval for DTLS handshake flights.
+// It defaults to one second, links with a high latency may need a larger value.
+func (e *SettingEngine) SetDTLSRetransmissionInterval(interval time.Duration) {
+	e.timeout.DTLSRetransmissionInterval = &interval
+}
+
+// SetDTLSHandshakeTimeout sets how long the DTLS handshake may take before it fails.
+// It defaults to 30 seconds.
+func (e *SettingEngine) SetDTLSHandshakeTimeout(timeout time.Duration) {
+	e.timeout.DTLSHandshakeTimeout = &timeout
+}
+
 // SetEphemeralUDPPortRange limits the pool of ephemeral ports that
 // ICE UDP connections can allocate from. This affects both host candidates,
 // and the local address of server reflexive candidates.
@@ -140,6 +315,88 @@ func (e *SettingEngine) SetInterfaceFilter(filter func(string) bool) {
 	e.candidates.InterfaceFilter = filter
 }
 
+// SetICECandidatePriorityFunc sets a function that can override the priority of local ICE
+// candidates, e.g. to prefer a wired over a wireless interface. Returning zero keeps the
+// priority computed as described in RFC 8445. Only the signaled priority is overridden,
+// the remote uses it to order its connectivity checks and, when it is controlling, to
+// choose the nominated pair. The ICE agent of pion/ice can't override the priority of its
+// local candidates, its own checks, candidate pairs and nomination keep using the priority
+// of RFC 8445.
+func (e *SettingEngine) SetICECandidatePriorityFunc(f func(ICECandidate) uint32) {
+	e.candidates.PriorityFunc = f
+}
+
+// SetICEServerProbeTimeout makes the ICEGatherer probe all STUN and TURN servers at the
+// same time before it gathers candidates. Servers that don't answer within the timeout
+// are left out, instead of delaying the end of gathering until the requests to them
+// time out, and are reported with OnGatheringError. Gathering starts once the probes
+// finished, at most after the timeout. Zero, the default, disables probing.
+func (e *SettingEngine) SetICEServerProbeTimeout(timeout time.Duration) {
+	e.candidates.ServerProbeTimeout = timeout
+}
+
+// SetICECandidateRewriteFunc sets a function that is called with every local ICE candidate
+// before it is signaled, e.g. to replace the address of a container with the address of its
+// host, or to keep private addresses from the remote. It returns the candidate to signal,
+// or false to not signal it at all. The ICE agent keeps using the gathered address, so a
+// rewritten address has to reach it, like a port forwarded to the container. The function
+// is called every time the candidates are signaled and has to return the same result for
+// a candidate each time.
+func (e *SettingEngine) SetICECandidateRewriteFunc(f func(ICECandidate) (ICECandidate, bool)) {
+	e.candidates.RewriteFunc = f
+}
+
+// SetTURNCredentialFunc sets a function that supplies the username and password of
+// TURN servers, e.g. to fetch short-lived credentials from a REST API. It is called
+// with the URL of each TURN server before candidates are gathered, including when
+// ICE restarts, so expired credentials are replaced without changing the
+// Configuration. The ICEServer still needs a username and credential to pass
+// validation, they are used if f returns an error. f is also called for TURN
+// servers with OAuth credentials, which are otherwise left out because only
+// long-term credentials are supported.
+func (e *SettingEngine) SetTURNCredentialFunc(f func(url string) (username, password string, err error)) {
+	e.candidates.TURNCredentialFunc = f
+}
+
+// SetNAT64Prefix sets the prefix the NAT64 of an IPv6-only network uses to translate IPv4
+// addresses, e.g. 64:ff9b::/96 or the result of DiscoverNAT64Prefix. Remote candidates
+// and STUN servers with an IPv4 address are then also tried at their address synthesized
+// as described in RFC 6052, so they can be reached from hosts without IPv4 connectivity.
+// Passing nil disables the synthesis.
+func (e *SettingEngine) SetNAT64Prefix(prefix *net.IPNet) error {
+	if prefix != nil && !validNAT64Prefix(prefix) {
+		return errSettingEngineNAT64Prefix
+	}
+
+	e.candidates.NAT64Prefix = prefix
+	return nil
+}
+
+// SetPortMapper sets a PortMapper that is asked to map the port of every IPv4 UDP host
+// candidate on the local gateway. The mapped address is advertised as an additional
+// server reflexive candidate, which allows direct connections without a STUN server.
+// NewNATPMPPortMapper and NewUPnPPortMapper provide NAT-PMP and UPnP IGD
+// implementations. The ports are mapped while candidates are gathered, the end of
+// gathering waits for the mappings. Mappings of a port shared by several gatherers,
+// e.g. through a UDPMux, are removed once the last of them is closed.
+func (e *SettingEngine) SetPortMapper(m PortMapper) {
+	if m == nil {
+		e.candidates.PortMapper = nil
+		return
+	}
+	e.candidates.PortMapper = newSharedPortMapper(m)
+}
+
+// SetICEMaxRemoteCandidates limits the number of remote ICE candidates used. The ICE agent
+// pairs every remote candidate with every local one and checks all pairs, so a remote
+// offering hundreds of candidates causes a storm of connectivity checks. Candidates beyond
+// the limit are ignored. Candidates with the same transport address are only counted once,
+// candidates sharing a foundation but using different ports are distinct. Zero, the
+// default, doesn't limit the remote candidates.
+func (e *SettingEngine) SetICEMaxRemoteCandidates(max int) {
+	e.candidates.MaxRemoteCandidates = max
+}
+
 // SetNAT1To1IPs sets a list of external IP addresses of 1:1 (D)NAT
 // and a candidate type for which the external IP address is used.
 // This is useful when you are host a server using Pion on an AWS EC2 instance
@@ -207,9 +464,11 @@ func (e *SettingEngine) SetMulticastDNSHostName(hostName string) {
 	e.candidates.MulticastDNSHostName = hostName
 }
 
-// SetICECredentials sets a staic uFrag/uPwd to be used by pion/ice
+// SetICECredentials sets a static uFrag/uPwd to be used by pion/ice
 //
-// This is useful if you want to do signalless WebRTC session, or having a reproducible environment with static credentials
+// This is useful if you want to do signalless WebRTC session, or having a reproducible environment with static credentials.
+// A cluster of servers behind a load balancer can also present identical credentials, so that any node is able to
+// answer connectivity checks. The same credentials are used again on an ICE restart.
 func (e *SettingEngine) SetICECredentials(usernameFragment, password string) {
 	e.candidates.UsernameFragment = usernameFragment
 	e.candidates.Password = password
@@ -268,6 +527,28 @@ func (e *SettingEngine) SetICEUDPMux(udpMux ice.UDPMux) {
 	e.iceUDPMux = udpMux
 }
 
+// SetReceiveBufferSize sets the size of the operating system receive buffer of the ICE
+// UDP sockets, in bytes. The default of the operating system is often too small for
+// bursts of video packets, which are then dropped before they are read. The size is
+// capped by the operating system, e.g. by net.core.rmem_max on Linux. Zero keeps the
+// default.
+//
+// The size is applied to the sockets of a UDPMux created by NewICEUDPMuxSharded.
+// pion/ice doesn't expose the sockets it opens for each PeerConnection, so they keep
+// the default. The size of the UDPConn passed to NewICEUDPMux is set with its
+// SetReadBuffer method. A warning is logged when the size can't be applied because no
+// UDPMux created by NewICEUDPMuxSharded is set.
+func (e *SettingEngine) SetReceiveBufferSize(bytes int) {
+	e.udpSocketBuffers.ReceiveBufferSize = bytes
+}
+
+// SetSendBufferSize sets the size of the operating system send buffer of the ICE UDP
+// sockets, in bytes. Zero keeps the default. It is applied to the same sockets as
+// SetReceiveBufferSize.
+func (e *SettingEngine) SetSendBufferSize(bytes int) {
+	e.udpSocketBuffers.SendBufferSize = bytes
+}
+
 // SetICEProxyDialer sets the proxy dialer interface based on golang.org/x/net/proxy.
 func (e *SettingEngine) SetICEProxyDialer(d proxy.Dialer) {
 	e.iceProxyDialer = d
@@ -279,3 +560,153 @@ func (e *SettingEngine) SetICEProxyDialer(d proxy.Dialer) {
 func (e *SettingEngine) DisableMediaEngineCopy(isDisabled bool) {
 	e.disableMediaEngineCopy = isDisabled
 }
+
+// EnableMediaSectionRejection controls what happens to a media section with no codec in
+// common with the MediaEngine. When enabled its RTPTransceiver is stopped, the answer
+// rejects it (port 0) and an offerer stops the transceivers of media sections rejected by
+// the answer, so the rest of the session is negotiated as usual, see
+// PeerConnection.OnMediaSectionRejected. By default the transceivers aren't stopped and
+// starting the sender of a track without a common codec fails with ErrUnsupportedCodec.
+func (e *SettingEngine) EnableMediaSectionRejection(isEnabled bool) {
+	e.mediaSectionRejection = isEnabled
+}
+
+// SetSDPSemantics sets the SDPSemantics of PeerConnections whose Configuration doesn't set
+// them. Use SDPSemanticsPlanB to talk to older clients that only understand plan-b, where
+// all tracks of a kind share one media section, or SDPSemanticsUnifiedPlanWithFallback to
+// answer them in plan-b and everyone else in unified-plan, with one media section per track.
+// As SDPSemanticsUnifiedPlan is the zero value of Configuration.SDPSemantics, it can't
+// override the value set here.
+func (e *SettingEngine) SetSDPSemantics(semantics SDPSemantics) {
+	e.sdpSemantics = semantics
+}
+
+// SetLocalSDPHook sets a function that is called with every offer and answer generated
+// by CreateOffer and CreateAnswer before it is marshaled. It can modify the
+// SessionDescription, e.g. to add attributes the PeerConnection doesn't generate.
+// If it returns an error CreateOffer or CreateAnswer fails with that error.
+func (e *SettingEngine) SetLocalSDPHook(f func(SDPType, *sdp.SessionDescription) error) {
+	e.localSDPHook = f
+}
+
+// SetRemoteSDPHook sets a function that is called with every description passed to
+// SetRemoteDescription after it has been parsed and before it is applied. Changes
+// made by it are applied and returned by RemoteDescription. It runs before the SDP
+// quirks are rewritten and the description is validated, so it can repair a
+// non-compliant one. If it returns an error SetRemoteDescription fails with that error.
+func (e *SettingEngine) SetRemoteSDPHook(f func(SDPType, *sdp.SessionDescription) error) {
+	e.remoteSDPHook = f
+}
+
+// SetMaxRTPPacketAge sets the maximum age of received RTP packets. Packets whose RTP timestamp
+// is older than the newest one received on the same TrackRemote by more than this are discarded
+// by TrackRemote.Read, so applications that never want stale media don't have to process late
+// or retransmitted packets. By default no packets are discarded.
+func (e *SettingEngine) SetMaxRTPPacketAge(maxAge time.Duration) {
+	e.maxRTPPacketAge = maxAge
+}
+
+// SetRemoteTrackMuteTimeout sets how long no RTP packets have to be read from a TrackRemote
+// before it is muted, see TrackRemote.OnMute. Senders that pause without renegotiation, e.g.
+// when a camera is disabled, are noticed this way. By default tracks are only muted when
+// the remote stops sending them with a renegotiation.
+func (e *SettingEngine) SetRemoteTrackMuteTimeout(timeout time.Duration) {
+	e.remoteTrackMuteTimeout = timeout
+}
+
+// SetEarlyMediaHandling sets what happens to media that arrives before it can be delivered
+// to a TrackRemote. The media path is opened once the transceivers of a remote description
+// are receiving, media that arrives while the description is applied could otherwise be
+// taken for an SSRC that isn't declared. Media for a media section the remote description
+// doesn't have yet waits for the next remote description for a few seconds. By default
+// the packets are buffered and delivered to the track, EarlyMediaHandlingDrop discards them.
+func (e *SettingEngine) SetEarlyMediaHandling(handling EarlyMediaHandling) {
+	e.earlyMediaHandling = handling
+}
+
+// SetSDPQuirksMode sets how remote descriptions with the quirks of older browsers, like
+// Safari before Unified Plan, are handled. By default a remote description is rewritten
+// when it has one: media sections without a mid get one, msids without a track id get
+// one and bundled media sections that leave out a=rtcp-mux get it. SDPQuirksModeAlways
+// also leaves a=rtcp-mux-only out of offers, SDPQuirksModeNever turns the rewriting off.
+// Descriptions are rewritten after the hook of SetRemoteSDPHook and before the
+// validation of EnableStrictSDPValidation.
+func (e *SettingEngine) SetSDPQuirksMode(mode SDPQuirksMode) {
+	e.sdpQuirksMode = mode
+}
+
+// SetLogLevel sets the log level of a scope. An empty scope sets the level of all scopes
+// without one of their own. The scopes are:
+//   - pc: PeerConnection
+//   - ice, dtls and sctp: the ICE agent, DTLS connection and SCTP association
+//   - datachannel: DataChannels
+//   - ortc: ICETransport and SCTPTransport
+//   - DTLSTransport and mux: DTLSTransport and the demultiplexing of its packets
+//
+// Without a LoggerFactory the level overrides the PIONS_LOG_* environment variables.
+// With a LoggerFactory of your own the level only filters the messages its loggers
+// accept, it can't make them more verbose. The level can be changed at runtime with
+// API.SetLogLevel.
+func (e *SettingEngine) SetLogLevel(scope string, level logging.LogLevel) {
+	if e.logLevels == nil {
+		e.logLevels = &logLevels{}
+	}
+	e.logLevels.set(scope, level)
+}
+
+// SetPacketTap sets a function that receives a copy of the RTP and RTCP packets of
+// the PeerConnections, e.g. to dump sessions to a pcap or rtpdump file for debugging.
+// Outbound packets are passed before they are encrypted. Inbound packets are passed
+// after they have been decrypted, as they are read from the streams of the SSRCs:
+// RTP packets once they are read from the TrackRemote, packets that are never read
+// aren't passed. A compound RTCP packet is passed for every SSRC stream it is
+// delivered to, so it can be passed more than once. The tap is called on the path
+// of the packets, it must not block.
+func (e *SettingEngine) SetPacketTap(tap func(direction PacketTapDirection, isRTCP bool, packet []byte)) {
+	e.packetTap = tap
+}
+
+// EnableStrictSDPValidation makes SetRemoteDescription reject descriptions that break
+// the rules of JSEP instead of tolerating them: every media section needs a unique mid,
+// payload types have to be unique in a media section and mean the same codec across a
+// BUNDLE group, and bundle-only media sections must be bundled with a zero port. The
+// error wraps ErrSDPValidation and names the offending m= line, which helps to debug
+// interop failures. Validation happens after the hook of SetRemoteSDPHook and the
+// quirks of SetSDPQuirksMode had the chance to repair the description.
+func (e *SettingEngine) EnableStrictSDPValidation(isEnabled bool) {
+	e.strictSDPValidation = isEnabled
+}
+
+// EnableCloseReasons lets DataChannel.CloseWithReason and PeerConnection.CloseWithReason
+// tell the remote why they have been closed. It is a convention between Pion peers, as
+// DCEP has no way to carry a close reason. Both peers signal it with the attribute
+// a=x-pion-close-reasons:<id> in the application media section. Once SCTP is connected
+// and both peers signalled the same ID, the reasons are sent over a negotiated
+// DataChannel with that ID, 65534 unless it is set with SetCloseReasonsDataChannelID.
+// The ID isn't used for other DataChannels created by the PeerConnection.
+func (e *SettingEngine) EnableCloseReasons(isEnabled bool) {
+	e.closeReasons = isEnabled
+}
+
+// SetCloseReasonsDataChannelID sets the ID of the negotiated DataChannel that carries
+// close reasons, see EnableCloseReasons. It has to be set to the same ID on both peers,
+// e.g. if the application negotiates a DataChannel with the ID 65534 itself. IDs go up
+// to 65534.
+func (e *SettingEngine) SetCloseReasonsDataChannelID(id uint16) {
+	e.closeReasonsDataChannelID = &id
+}
+
+func (e *SettingEngine) getControlDataChannelID() uint16 {
+	if e.closeReasonsDataChannelID != nil {
+		return *e.closeReasonsDataChannelID
+	}
+	return defaultControlDataChannelID
+}
+
+// EnableRTCPMuxOnly marks the RTP media sections of initial offers with
+// a=rtcp-mux-only when the RTCPMuxPolicy is RTCPMuxPolicyRequire, RFC 8858. Legacy
+// SIP gateways can then tell up front that they can't answer with RTCP on a separate
+// port. Older browsers, like Safari, reject offers with it, so it is off by default.
+func (e *SettingEngine) EnableRTCPMuxOnly(isEnabled bool) {
+	e.rtcpMuxOnly = isEnabled
+}
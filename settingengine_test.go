@@ -3,10 +3,15 @@
 package webrtc
 
 import (
+	"errors"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/pion/dtls/v2"
+	"github.com/pion/logging"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/transport/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -48,6 +53,143 @@ func TestSetConnectionTimeout(t *testing.T) {
 	assert.Equal(t, *s.timeout.ICEKeepaliveInterval, 3*time.Second)
 }
 
+func TestSetICECheckPacing(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+
+	var nilDuration *time.Duration
+	assert.Equal(t, s.timeout.ICECheckInterval, nilDuration)
+
+	s.SetICECheckInterval(500 * time.Millisecond)
+	assert.Equal(t, *s.timeout.ICECheckInterval, 500*time.Millisecond)
+
+	// Connecting still works with slower checks
+	api := NewAPI(WithSettingEngine(s))
+	offer, answer, err := api.newPair(Configuration{})
+	assert.NoError(t, err)
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offer, answer)
+	assert.NoError(t, signalPair(offer, answer))
+	connected.Wait()
+	closePairNow(t, offer, answer)
+}
+
+func TestEnableFastSetup(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.EnableFastSetup()
+	assert.Equal(t, []NetworkType{NetworkTypeUDP4}, s.candidates.ICENetworkTypes)
+	assert.Equal(t, fastSetupICECheckInterval, *s.timeout.ICECheckInterval)
+	assert.Equal(t, fastSetupDTLSRetransmissionInterval, *s.timeout.DTLSRetransmissionInterval)
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterOpusCodec())
+	api := NewAPI(WithSettingEngine(s), WithMediaEngine(m))
+
+	offerPC, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	answerPC, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = offerPC.AddTransceiverFromKind(RTPCodecTypeAudio)
+	assert.NoError(t, err)
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offerPC, answerPC)
+	assert.NoError(t, signalPair(offerPC, answerPC))
+	connected.Wait()
+
+	assert.Contains(t, offerPC.LocalDescription().SDP, "m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n")
+	assert.NotContains(t, offerPC.LocalDescription().SDP, "m=video")
+
+	closePairNow(t, offerPC, answerPC)
+}
+
+func TestSetDTLSTimeouts(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+
+	var nilDuration *time.Duration
+	assert.Equal(t, s.timeout.DTLSRetransmissionInterval, nilDuration)
+	assert.Equal(t, s.timeout.DTLSHandshakeTimeout, nilDuration)
+
+	s.SetDTLSRetransmissionInterval(2 * time.Second)
+	s.SetDTLSHandshakeTimeout(time.Minute)
+	assert.Equal(t, *s.timeout.DTLSRetransmissionInterval, 2*time.Second)
+	assert.Equal(t, *s.timeout.DTLSHandshakeTimeout, time.Minute)
+
+	// The handshake still completes with the changed timers
+	s.SetDTLSRetransmissionInterval(100 * time.Millisecond)
+	api := NewAPI(WithSettingEngine(s))
+	offer, answer, err := api.newPair(Configuration{})
+	assert.NoError(t, err)
+
+	connected := untilConnectionState(PeerConnectionStateConnected, offer, answer)
+	assert.NoError(t, signalPair(offer, answer))
+	connected.Wait()
+	closePairNow(t, offer, answer)
+}
+
+func TestSetDTLSConfig(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	t.Run("Connect", func(t *testing.T) {
+		keyLog := &syncBuffer{}
+		s := SettingEngine{}
+		s.SetDTLSCipherSuites(dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+		s.SetDTLSMTU(600)
+		s.SetDTLSKeyLogWriter(keyLog)
+		s.SetDTLSInsecureHashes(true)
+
+		offer, answer, err := NewAPI(WithSettingEngine(s)).newPair(Configuration{})
+		assert.NoError(t, err)
+
+		connected := untilConnectionState(PeerConnectionStateConnected, offer, answer)
+		assert.NoError(t, signalPair(offer, answer))
+		connected.Wait()
+		closePairNow(t, offer, answer)
+
+		assert.Contains(t, keyLog.String(), "CLIENT_RANDOM ")
+	})
+
+	t.Run("No common cipher suite", func(t *testing.T) {
+		offerSettingEngine := SettingEngine{}
+		offerSettingEngine.SetDTLSCipherSuites(dtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+		answerSettingEngine := SettingEngine{}
+		answerSettingEngine.SetDTLSCipherSuites(dtls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA)
+
+		offer, err := NewAPI(WithSettingEngine(offerSettingEngine)).NewPeerConnection(Configuration{})
+		assert.NoError(t, err)
+		answer, err := NewAPI(WithSettingEngine(answerSettingEngine)).NewPeerConnection(Configuration{})
+		assert.NoError(t, err)
+
+		failed := untilConnectionState(PeerConnectionStateFailed, offer, answer)
+		_, err = offer.CreateDataChannel("data", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, signalPair(offer, answer))
+		failed.Wait()
+		closePairNow(t, offer, answer)
+	})
+}
+
 func TestDetachDataChannels(t *testing.T) {
 	s := SettingEngine{}
 
@@ -212,3 +354,217 @@ func TestSettingEngine_SetDisableMediaEngineCopy(t *testing.T) {
 		closePairNow(t, offerer, answerer)
 	})
 }
+
+func TestSettingEngine_SetICECredentials(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	const (
+		ufrag = "sharedufrag"
+		pwd   = "sharedpasswordsharedpassword"
+	)
+
+	s := SettingEngine{}
+	s.SetICECredentials(ufrag, pwd)
+	api := NewAPI(WithSettingEngine(s))
+
+	// Every PeerConnection created with the SettingEngine presents the same credentials
+	for i := 0; i < 2; i++ {
+		offerer, answerer, err := api.newPair(Configuration{})
+		assert.NoError(t, err)
+
+		connected := untilConnectionState(PeerConnectionStateConnected, offerer, answerer)
+		assert.NoError(t, signalPair(offerer, answerer))
+		assert.Contains(t, offerer.LocalDescription().SDP, "a=ice-ufrag:"+ufrag)
+		assert.Contains(t, offerer.LocalDescription().SDP, "a=ice-pwd:"+pwd)
+		assert.Contains(t, answerer.LocalDescription().SDP, "a=ice-ufrag:"+ufrag)
+
+		connected.Wait()
+		closePairNow(t, offerer, answerer)
+	}
+}
+
+func TestSettingEngine_SetSDPSemantics(t *testing.T) {
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+
+	s := SettingEngine{}
+	s.SetSDPSemantics(SDPSemanticsPlanB)
+	api := NewAPI(WithMediaEngine(m), WithSettingEngine(s))
+
+	pc, err := api.NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	assert.Equal(t, SDPSemanticsPlanB, pc.GetConfiguration().SDPSemantics)
+
+	// Both tracks share one media section
+	for _, id := range []string{"video1", "video2"} {
+		track, trackErr := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, id, "pion")
+		assert.NoError(t, trackErr)
+		_, err = pc.AddTrack(track)
+		assert.NoError(t, err)
+	}
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(offer.SDP, "m=video"))
+	assert.NoError(t, pc.Close())
+
+	// The Configuration takes precedence
+	pc, err = api.NewPeerConnection(Configuration{SDPSemantics: SDPSemanticsUnifiedPlanWithFallback})
+	assert.NoError(t, err)
+	assert.Equal(t, SDPSemanticsUnifiedPlanWithFallback, pc.GetConfiguration().SDPSemantics)
+	assert.NoError(t, pc.Close())
+}
+
+func TestSettingEngine_SDPHooks(t *testing.T) {
+	s := SettingEngine{}
+	s.SetLocalSDPHook(func(sdpType SDPType, d *sdp.SessionDescription) error {
+		assert.Equal(t, SDPTypeOffer, sdpType)
+		d.WithPropertyAttribute("x-google-flag:conference")
+		return nil
+	})
+	offerPC, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	var remoteTypes []SDPType
+	s = SettingEngine{}
+	s.SetRemoteSDPHook(func(sdpType SDPType, d *sdp.SessionDescription) error {
+		remoteTypes = append(remoteTypes, sdpType)
+		if _, ok := d.Attribute("x-google-flag"); !ok {
+			return errors.New("attribute is missing")
+		}
+		d.WithPropertyAttribute("x-remote-hook")
+		return nil
+	})
+	answerPC, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	_, err = offerPC.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+
+	offer, err := offerPC.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, offer.SDP, "a=x-google-flag:conference")
+
+	// The changes of the remote hook are applied
+	assert.NoError(t, answerPC.SetRemoteDescription(offer))
+	assert.Equal(t, []SDPType{SDPTypeOffer}, remoteTypes)
+	assert.Contains(t, answerPC.RemoteDescription().SDP, "a=x-remote-hook")
+
+	// An error of the hook fails the operation
+	hookErr := errors.New("hook failed")
+	s = SettingEngine{}
+	s.SetLocalSDPHook(func(SDPType, *sdp.SessionDescription) error {
+		return hookErr
+	})
+	failingPC, err := NewAPI(WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+	_, err = failingPC.CreateOffer(nil)
+	assert.ErrorIs(t, err, hookErr)
+
+	assert.NoError(t, offerPC.Close())
+	assert.NoError(t, answerPC.Close())
+	assert.NoError(t, failingPC.Close())
+}
+
+func TestSettingEngine_SetBufferSizes_NoMux(t *testing.T) {
+	output := &syncBuffer{}
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	loggerFactory.DefaultLogLevel = logging.LogLevelWarn
+	loggerFactory.Writer = output
+
+	s := SettingEngine{LoggerFactory: loggerFactory}
+	s.SetReceiveBufferSize(1 << 16)
+
+	gatherer, err := NewAPI(WithSettingEngine(s)).NewICEGatherer(ICEGatherOptions{})
+	assert.NoError(t, err)
+	_, err = gatherer.GetLocalParameters()
+	assert.NoError(t, err)
+
+	assert.Contains(t, output.String(), "Buffer sizes of the ICE UDP sockets are ignored")
+	assert.NoError(t, gatherer.Close())
+}
+
+func TestSetLogLevel(t *testing.T) {
+	output := &syncBuffer{}
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	loggerFactory.DefaultLogLevel = logging.LogLevelDebug
+	loggerFactory.Writer = output
+
+	s := SettingEngine{LoggerFactory: loggerFactory}
+	s.SetLogLevel("pc", logging.LogLevelInfo)
+	api := NewAPI(WithSettingEngine(s))
+
+	pcLog := api.settingEngine.LoggerFactory.NewLogger("pc")
+	iceLog := api.settingEngine.LoggerFactory.NewLogger("ice")
+	dataChannelLog := api.settingEngine.LoggerFactory.NewLogger("datachannel")
+
+	// The level of the scope filters the messages of the LoggerFactory
+	pcLog.Info("pc info")
+	pcLog.Debug("pc debug")
+	iceLog.Debug("ice debug")
+	iceLog.Trace("ice trace")
+
+	// Levels can be changed for loggers that already exist, but can't make the
+	// loggers of the LoggerFactory more verbose
+	api.SetLogLevel("pc", logging.LogLevelDisabled)
+	api.SetLogLevel("", logging.LogLevelTrace)
+	api.SetLogLevel("datachannel", logging.LogLevelWarn)
+	pcLog.Error("pc disabled")
+	iceLog.Trace("ice trace after SetLogLevel")
+	dataChannelLog.Warn("datachannel warn")
+	dataChannelLog.Info("datachannel info")
+
+	logs := output.String()
+	for _, msg := range []string{"pc info", "ice debug", "datachannel warn"} {
+		assert.Contains(t, logs, msg)
+	}
+	for _, msg := range []string{"pc debug", "ice trace", "pc disabled", "datachannel info"} {
+		assert.NotContains(t, logs, msg)
+	}
+	assert.Equal(t, logging.LogLevelDebug, loggerFactory.DefaultLogLevel)
+}
+
+func TestSetLogLevel_OwnLoggerFactory(t *testing.T) {
+	output := &syncBuffer{}
+	loggerFactory := logging.NewDefaultLoggerFactory()
+	loggerFactory.Writer = output
+
+	// Loggers of the LoggerFactory created by NewAPI are made more verbose
+	levels := &logLevels{}
+	levelLoggers := &levelLoggerFactory{levels: levels, loggerFactory: loggerFactory, ownLoggerFactory: true}
+	log := levelLoggers.NewLogger("ice")
+
+	log.Debug("debug before SetLogLevel")
+	levels.set("ice", logging.LogLevelDebug)
+	log.Debug("debug after SetLogLevel")
+	log.Trace("trace after SetLogLevel")
+
+	logs := output.String()
+	assert.Contains(t, logs, "debug after SetLogLevel")
+	assert.NotContains(t, logs, "debug before SetLogLevel")
+	assert.NotContains(t, logs, "trace after SetLogLevel")
+}
+
+func TestSetLogLevel_SharedSettingEngine(t *testing.T) {
+	s := SettingEngine{}
+	s.SetLogLevel("pc", logging.LogLevelInfo)
+
+	api := NewAPI(WithSettingEngine(s))
+	otherAPI := NewAPI(WithSettingEngine(s))
+
+	// Each API changes its own levels
+	api.SetLogLevel("pc", logging.LogLevelTrace)
+
+	level, ok := api.settingEngine.logLevels.get("pc")
+	assert.True(t, ok)
+	assert.Equal(t, logging.LogLevelTrace, level)
+
+	for _, levels := range []*logLevels{otherAPI.settingEngine.logLevels, s.logLevels} {
+		level, ok = levels.get("pc")
+		assert.True(t, ok)
+		assert.Equal(t, logging.LogLevelInfo, level)
+	}
+}
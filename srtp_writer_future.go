@@ -14,6 +14,7 @@ import (
 // srtpWriterFuture blocks Read/Write calls until
 // the SRTP Session is available
 type srtpWriterFuture struct {
+	ssrc           SSRC
 	rtpSender      *RTPSender
 	rtcpReadStream atomic.Value // *srtp.ReadStreamSRTCP
 	rtpWriteStream atomic.Value // *srtp.WriteStreamSRTP
@@ -41,7 +42,7 @@ func (s *srtpWriterFuture) init(returnWhenNoSRTP bool) error {
 		return err
 	}
 
-	rtcpReadStream, err := srtcpSession.OpenReadStream(uint32(s.rtpSender.ssrc))
+	rtcpReadStream, err := srtcpSession.OpenReadStream(uint32(s.ssrc))
 	if err != nil {
 		return err
 	}
@@ -95,7 +96,11 @@ func (s *srtpWriterFuture) SetReadDeadline(t time.Time) error {
 
 func (s *srtpWriterFuture) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
 	if value := s.rtpWriteStream.Load(); value != nil {
-		return value.(*srtp.WriteStreamSRTP).WriteRTP(header, payload)
+		n, err := value.(*srtp.WriteStreamSRTP).WriteRTP(header, payload)
+		if err == nil {
+			s.rtpSender.api.settingEngine.tapRTP(PacketTapDirectionOutbound, header, payload)
+		}
+		return n, err
 	}
 
 	if err := s.init(true); err != nil || s.rtpWriteStream.Load() == nil {
@@ -107,7 +112,11 @@ func (s *srtpWriterFuture) WriteRTP(header *rtp.Header, payload []byte) (int, er
 
 func (s *srtpWriterFuture) Write(b []byte) (int, error) {
 	if value := s.rtpWriteStream.Load(); value != nil {
-		return value.(*srtp.WriteStreamSRTP).Write(b)
+		n, err := value.(*srtp.WriteStreamSRTP).Write(b)
+		if err == nil {
+			s.rtpSender.api.settingEngine.tapPacket(PacketTapDirectionOutbound, false, b)
+		}
+		return n, err
 	}
 
 	if err := s.init(true); err != nil || s.rtpWriteStream.Load() == nil {
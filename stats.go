@@ -95,10 +95,13 @@ type statsReportCollector struct {
 	collectingGroup sync.WaitGroup
 	report          StatsReport
 	mux             sync.Mutex
+
+	// timestamp is shared by all stats of the report
+	timestamp StatsTimestamp
 }
 
-func newStatsReportCollector() *statsReportCollector {
-	return &statsReportCollector{report: make(StatsReport)}
+func newStatsReportCollector(timestamp StatsTimestamp) *statsReportCollector {
+	return &statsReportCollector{report: make(StatsReport), timestamp: timestamp}
 }
 
 func (src *statsReportCollector) Collecting() {
@@ -291,6 +294,10 @@ type InboundRTPStreamStats struct {
 	// i.e., frames that would be displayed if no frames are dropped. Only valid for video.
 	FramesDecoded uint32 `json:"framesDecoded"`
 
+	// KeyFramesReceived represents the total number of keyframes received for this SSRC,
+	// detected in the payload of VP8, VP9 and H264 packets. Only valid for video.
+	KeyFramesReceived uint32 `json:"keyFramesReceived"`
+
 	// LastPacketReceivedTimestamp represents the timestamp at which the last packet was
 	// received for this SSRC. This differs from Timestamp, which represents the time
 	// at which the statistics were generated by the local endpoint.
@@ -306,9 +313,29 @@ type InboundRTPStreamStats struct {
 	// This counter can also be incremented when receiving FEC packets in-band with media packets (e.g., with Opus).
 	FECPacketsReceived uint32 `json:"fecPacketsReceived"`
 
+	// FECPacketsDiscarded is the total number of RTP FEC packets received for this SSRC
+	// that weren't used to recover a packet, e.g. because all protected packets arrived.
+	FECPacketsDiscarded uint32 `json:"fecPacketsDiscarded"`
+
+	// PacketsRecovered is the total number of RTP packets of this SSRC rebuilt from
+	// FEC packets. They aren't counted by PacketsReceived.
+	PacketsRecovered uint32 `json:"packetsRecovered"`
+
 	// BytesReceived is the total number of bytes received for this SSRC.
 	BytesReceived uint64 `json:"bytesReceived"`
 
+	// HeaderBytesReceived is the total number of RTP header and padding bytes received for this SSRC.
+	// This does not include the size of transport layer headers such as IP or UDP.
+	HeaderBytesReceived uint64 `json:"headerBytesReceived"`
+
+	// RetransmittedPacketsReceived is the total number of retransmitted packets received
+	// for this SSRC, they are counted by PacketsReceived as well.
+	RetransmittedPacketsReceived uint64 `json:"retransmittedPacketsReceived"`
+
+	// RetransmittedBytesReceived is the total number of payload bytes received in
+	// retransmitted packets for this SSRC, they are counted by BytesReceived as well.
+	RetransmittedBytesReceived uint64 `json:"retransmittedBytesReceived"`
+
 	// PacketsFailedDecryption is the cumulative number of RTP packets that failed
 	// to be decrypted. These packets are not counted by PacketsDiscarded.
 	PacketsFailedDecryption uint32 `json:"packetsFailedDecryption"`
@@ -368,6 +395,9 @@ type OutboundRTPStreamStats struct {
 	// Kind is either "audio" or "video"
 	Kind string `json:"kind"`
 
+	// RID is the rid of the simulcast encoding, empty if the sender has a single encoding
+	RID string `json:"rid,omitempty"`
+
 	// It is a unique identifier that is associated to the object that was inspected
 	// to produce the TransportStats associated with this RTP stream.
 	TransportID string `json:"transportId"`
@@ -410,9 +440,21 @@ type OutboundRTPStreamStats struct {
 	// media packets (e.g., with Opus).
 	FECPacketsSent uint32 `json:"fecPacketsSent"`
 
+	// RetransmittedPacketsSent is the total number of packets that were retransmitted
+	// for this SSRC, they are counted by PacketsSent as well.
+	RetransmittedPacketsSent uint64 `json:"retransmittedPacketsSent"`
+
+	// RetransmittedBytesSent is the total number of payload bytes retransmitted for
+	// this SSRC, they are counted by BytesSent as well.
+	RetransmittedBytesSent uint64 `json:"retransmittedBytesSent"`
+
 	// BytesSent is the total number of bytes sent for this SSRC.
 	BytesSent uint64 `json:"bytesSent"`
 
+	// HeaderBytesSent is the total number of RTP header and padding bytes sent for this SSRC.
+	// This does not include the size of transport layer headers such as IP or UDP.
+	HeaderBytesSent uint64 `json:"headerBytesSent"`
+
 	// BytesDiscardedOnSend is the total number of bytes for this SSRC that have
 	// been discarded due to socket errors, i.e. a socket error occurred when handing
 	// the packets containing the bytes to the socket. This might happen due to various
@@ -448,6 +490,10 @@ type OutboundRTPStreamStats struct {
 	// Only valid for video.
 	FramesEncoded uint32 `json:"framesEncoded"`
 
+	// KeyFramesSent represents the total number of keyframes sent for this SSRC,
+	// detected in the payload of VP8, VP9 and H264 packets. Only valid for video.
+	KeyFramesSent uint32 `json:"keyFramesSent"`
+
 	// TotalEncodeTime is the total number of seconds that has been spent encoding the
 	// framesEncoded frames of this stream. The average encode time can be calculated by
 	// dividing this value with FramesEncoded. The time it takes to encode one frame is the
@@ -666,6 +712,16 @@ type RemoteOutboundRTPStreamStats struct {
 	// Sender Report (SR) packet, which reflects the remote endpoint's clock.
 	// That clock may not be synchronized with the local clock.
 	RemoteTimestamp StatsTimestamp `json:"remoteTimestamp"`
+
+	// RoundTripTime is the last round trip time measured in seconds with the RTCP
+	// Extended Report (XR) blocks of RFC 3611, which works without sending media.
+	RoundTripTime float64 `json:"roundTripTime"`
+
+	// TotalRoundTripTime is the sum of all round trip times measured in seconds.
+	TotalRoundTripTime float64 `json:"totalRoundTripTime"`
+
+	// RoundTripTimeMeasurements is the number of valid round trip times measured.
+	RoundTripTimeMeasurements uint64 `json:"roundTripTimeMeasurements"`
 }
 
 // RTPContributingSourceStats contains statistics for a contributing source (CSRC) that contributed
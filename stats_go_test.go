@@ -3,12 +3,17 @@
 package webrtc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -343,3 +348,166 @@ func TestPeerConnection_GetStats_Closed(t *testing.T) {
 
 	pc.GetStats()
 }
+
+func TestPeerConnection_GetStats_Feedback(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	i := &interceptor.Registry{}
+	assert.NoError(t, RegisterDefaultInterceptors(m, i))
+
+	// The retransmission has the sequence number of a packet that has been received
+	s := SettingEngine{}
+	s.DisableSRTPReplayProtection(true)
+
+	pcOffer, pcAnswer, err := NewAPI(WithMediaEngine(m), WithInterceptorRegistry(i), WithSettingEngine(s)).newPair(Configuration{})
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	rtpSender, err := pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	// Reading RTCP runs the NACK responder
+	rtpSender.OnRTCP(func([]rtcp.Packet, interceptor.Attributes) {})
+
+	retransmitted, retransmittedCancel := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, _ *RTPReceiver) {
+		pkt, _, readErr := trackRemote.ReadRTP()
+		assert.NoError(t, readErr)
+
+		assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{
+			&rtcp.TransportLayerNack{
+				MediaSSRC: uint32(trackRemote.SSRC()),
+				Nacks:     []rtcp.NackPair{{PacketID: pkt.SequenceNumber}},
+			},
+			&rtcp.PictureLossIndication{MediaSSRC: uint32(trackRemote.SSRC())},
+		}))
+
+		for {
+			retransmission, _, readErr := trackRemote.ReadRTP()
+			if readErr != nil {
+				return
+			}
+			if retransmission.SequenceNumber == pkt.SequenceNumber {
+				retransmittedCancel()
+			}
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Duration: time.Second}))
+			case <-retransmitted.Done():
+				return
+			}
+		}
+	}()
+
+	var outboundStats OutboundRTPStreamStats
+	for _, s := range pcOffer.GetStats() {
+		if stats, ok := s.(OutboundRTPStreamStats); ok {
+			outboundStats = stats
+		}
+	}
+	assert.Equal(t, uint64(1), outboundStats.RetransmittedPacketsSent)
+	// The payload is the VP8 payload descriptor and the sample
+	assert.Equal(t, uint64(2), outboundStats.RetransmittedBytesSent)
+	assert.Equal(t, uint32(1), outboundStats.NACKCount)
+	assert.Equal(t, uint32(1), outboundStats.PLICount)
+
+	var inboundStats InboundRTPStreamStats
+	for _, s := range pcAnswer.GetStats() {
+		if stats, ok := s.(InboundRTPStreamStats); ok {
+			inboundStats = stats
+		}
+	}
+	assert.Equal(t, outboundStats.SSRC, inboundStats.SSRC)
+	assert.NotZero(t, inboundStats.PacketsReceived)
+	assert.Equal(t, uint64(1), inboundStats.RetransmittedPacketsReceived)
+	assert.Equal(t, uint64(2), inboundStats.RetransmittedBytesReceived)
+	assert.Equal(t, uint32(1), inboundStats.NACKCount)
+	assert.Equal(t, uint32(1), inboundStats.PLICount)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_GetStats_KeyFrames(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	// Every third frame is a keyframe, the P bit of the frame tag is cleared, so
+	// any frameCount consecutive frames contain frameCount/3 keyframes
+	const frameCount = 9
+	received, receivedCancel := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, _ *RTPReceiver) {
+		for count := 0; ; count++ {
+			if _, _, readErr := trackRemote.ReadRTP(); readErr != nil {
+				return
+			}
+			if count == frameCount-1 {
+				receivedCancel()
+				return
+			}
+		}
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer).Wait()
+
+	func() {
+		for i := 0; ; i++ {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				frame := []byte{0x01, 0x00, 0x00, 0x00}
+				if i%3 == 0 {
+					frame[0] = 0x00
+				}
+				assert.NoError(t, track.WriteSample(media.Sample{Data: frame, Duration: time.Second}))
+			case <-received.Done():
+				return
+			}
+		}
+	}()
+
+	var outboundStats OutboundRTPStreamStats
+	for _, s := range pcOffer.GetStats() {
+		if stats, ok := s.(OutboundRTPStreamStats); ok {
+			outboundStats = stats
+		}
+	}
+	assert.GreaterOrEqual(t, outboundStats.KeyFramesSent, uint32(frameCount/3))
+	assert.Less(t, outboundStats.KeyFramesSent, outboundStats.PacketsSent)
+
+	var inboundStats InboundRTPStreamStats
+	for _, s := range pcAnswer.GetStats() {
+		if stats, ok := s.(InboundRTPStreamStats); ok {
+			inboundStats = stats
+		}
+	}
+	assert.Equal(t, uint32(frameCount/3), inboundStats.KeyFramesReceived)
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
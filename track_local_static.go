@@ -24,20 +24,34 @@ type trackBinding struct {
 // TrackLocalStaticRTP  is a TrackLocal that has a pre-set codec and accepts RTP Packets.
 // If you wish to send a media.Sample use TrackLocalStaticSample
 type TrackLocalStaticRTP struct {
-	mu           sync.RWMutex
-	bindings     []trackBinding
-	codec        RTPCodecCapability
-	id, streamID string
+	mu                sync.RWMutex
+	bindings          []trackBinding
+	codec             RTPCodecCapability
+	id, rid, streamID string
 }
 
 // NewTrackLocalStaticRTP returns a TrackLocalStaticRTP.
-func NewTrackLocalStaticRTP(c RTPCodecCapability, id, streamID string) (*TrackLocalStaticRTP, error) {
-	return &TrackLocalStaticRTP{
+func NewTrackLocalStaticRTP(c RTPCodecCapability, id, streamID string, options ...func(*TrackLocalStaticRTP)) (*TrackLocalStaticRTP, error) {
+	t := &TrackLocalStaticRTP{
 		codec:    c,
 		bindings: []trackBinding{},
 		id:       id,
 		streamID: streamID,
-	}, nil
+	}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	return t, nil
+}
+
+// WithRTPStreamID sets the RID of the track, which makes it a simulcast layer
+// that can be added to a RTPSender with AddEncoding
+func WithRTPStreamID(rid string) func(*TrackLocalStaticRTP) {
+	return func(t *TrackLocalStaticRTP) {
+		t.rid = rid
+	}
 }
 
 // Bind is called by the PeerConnection after negotiation is complete
@@ -86,6 +100,9 @@ func (s *TrackLocalStaticRTP) ID() string { return s.id }
 // StreamID is the group this track belongs too. This must be unique
 func (s *TrackLocalStaticRTP) StreamID() string { return s.streamID }
 
+// RID is the RTP stream identifier of the simulcast layer, empty if the track isn't one
+func (s *TrackLocalStaticRTP) RID() string { return s.rid }
+
 // Kind controls if this TrackLocal is audio or video
 func (s *TrackLocalStaticRTP) Kind() RTPCodecType {
 	switch {
@@ -128,6 +145,10 @@ func (s *TrackLocalStaticRTP) WriteRTP(p *rtp.Packet) error {
 
 // writeRTP is like WriteRTP, except that it may modify the packet p
 func (s *TrackLocalStaticRTP) writeRTP(p *rtp.Packet) error {
+	if len(p.Header.CSRC) > rtpMaxCSRC {
+		return ErrTooManyCSRC
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -148,6 +169,8 @@ func (s *TrackLocalStaticRTP) writeRTP(p *rtp.Packet) error {
 // If one PeerConnection fails the packets will still be sent to
 // all PeerConnections. The error message will contain the ID of the failed
 // PeerConnections so you can remove them
+// Only SSRC and payload type are rewritten, header extensions are sent as they
+// are in the buffer. This allows forwarding packets read with TrackRemote.Read.
 func (s *TrackLocalStaticRTP) Write(b []byte) (n int, err error) {
 	ipacket := rtpPacketPool.Get()
 	packet := ipacket.(*rtp.Packet)
@@ -173,8 +196,8 @@ type TrackLocalStaticSample struct {
 }
 
 // NewTrackLocalStaticSample returns a TrackLocalStaticSample
-func NewTrackLocalStaticSample(c RTPCodecCapability, id, streamID string) (*TrackLocalStaticSample, error) {
-	rtpTrack, err := NewTrackLocalStaticRTP(c, id, streamID)
+func NewTrackLocalStaticSample(c RTPCodecCapability, id, streamID string, options ...func(*TrackLocalStaticRTP)) (*TrackLocalStaticSample, error) {
+	rtpTrack, err := NewTrackLocalStaticRTP(c, id, streamID, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -192,6 +215,9 @@ func (s *TrackLocalStaticSample) ID() string { return s.rtpTrack.ID() }
 // StreamID is the group this track belongs too. This must be unique
 func (s *TrackLocalStaticSample) StreamID() string { return s.rtpTrack.StreamID() }
 
+// RID is the RTP stream identifier of the simulcast layer, empty if the track isn't one
+func (s *TrackLocalStaticSample) RID() string { return s.rtpTrack.RID() }
+
 // Kind controls if this TrackLocal is audio or video
 func (s *TrackLocalStaticSample) Kind() RTPCodecType { return s.rtpTrack.Kind() }
 
@@ -245,7 +271,13 @@ func (s *TrackLocalStaticSample) Unbind(t TrackLocalContext) error {
 // If one PeerConnection fails the packets will still be sent to
 // all PeerConnections. The error message will contain the ID of the failed
 // PeerConnections so you can remove them
+//
+// If the Sample contains CSRCs they are set on every packet of the Sample
 func (s *TrackLocalStaticSample) WriteSample(sample media.Sample) error {
+	if len(sample.CSRC) > rtpMaxCSRC {
+		return ErrTooManyCSRC
+	}
+
 	s.rtpTrack.mu.RLock()
 	p := s.packetizer
 	clockRate := s.clockRate
@@ -268,6 +300,7 @@ func (s *TrackLocalStaticSample) WriteSample(sample media.Sample) error {
 
 	writeErrs := []error{}
 	for _, p := range packets {
+		p.CSRC = sample.CSRC
 		if err := s.rtpTrack.WriteRTP(p); err != nil {
 			writeErrs = append(writeErrs, err)
 		}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pion/rtp"
 	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -248,3 +249,111 @@ func BenchmarkTrackLocalWrite(b *testing.B) {
 		assert.NoError(b, err)
 	}
 }
+
+// Assert that CSRCs set by a mixer are carried to the remote and
+// accounted for in the outbound stats
+func Test_TrackLocalStatic_CSRC(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: "video/vp8"}, "video", "pion")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	csrc := []uint32{0xAABBCCDD, 0x11223344}
+	assert.ErrorIs(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Duration: time.Second, CSRC: make([]uint32, 16)}), ErrTooManyCSRC)
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, r *RTPReceiver) {
+		pkt, _, readErr := trackRemote.ReadRTP()
+		assert.NoError(t, readErr)
+		assert.Equal(t, csrc, pkt.CSRC)
+
+		onTrackFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Duration: time.Second, CSRC: csrc}))
+			case <-onTrackFired.Done():
+				return
+			}
+		}
+	}()
+
+	var outboundStats *OutboundRTPStreamStats
+	for _, s := range pcOffer.GetStats() {
+		if stats, ok := s.(OutboundRTPStreamStats); ok {
+			outboundStats = &stats
+		}
+	}
+	assert.NotNil(t, outboundStats)
+	assert.NotZero(t, outboundStats.PacketsSent)
+	assert.GreaterOrEqual(t, outboundStats.HeaderBytesSent, uint64(outboundStats.PacketsSent)*uint64(12+4*len(csrc)))
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
+
+// Assert that header extensions of packets forwarded as a buffer arrive unchanged
+func Test_TrackLocalStatic_HeaderExtensionPassthrough(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticRTP(RTPCodecCapability{MimeType: "video/vp8"}, "video", "pion")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	packet := &rtp.Packet{Header: rtp.Header{Version: 2}, Payload: []byte{0x00}}
+	assert.NoError(t, packet.SetExtension(1, []byte{0xAA, 0xBB}))
+	assert.NoError(t, packet.SetExtension(2, []byte{0xCC}))
+	raw, err := packet.Marshal()
+	assert.NoError(t, err)
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, r *RTPReceiver) {
+		buf := make([]byte, 1500)
+		n, _, readErr := trackRemote.Read(buf)
+		assert.NoError(t, readErr)
+
+		// The extension block follows the fixed header, there are no CSRCs
+		assert.Equal(t, raw[12:packet.PayloadOffset], buf[12:n-len(packet.Payload)])
+
+		onTrackFiredFunc()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				_, writeErr := track.Write(raw)
+				assert.NoError(t, writeErr)
+			case <-onTrackFired.Done():
+				return
+			}
+		}
+	}()
+
+	closePairNow(t, pcOffer, pcAnswer)
+}
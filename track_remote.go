@@ -3,13 +3,30 @@
 package webrtc
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+	"github.com/pion/webrtc/v3/pkg/rtcpxr"
 )
 
+// sampleMaxLate is the number of packets ReadSample waits for a missing packet
+// before it drops the incomplete Sample
+const sampleMaxLate = 64
+
+// maxNACKedSequenceNumbers limits the number of NACKed packets remembered for the stats
+const maxNACKedSequenceNumbers = 1024
+
 // TrackRemote represents a single inbound source of media
 type TrackRemote struct {
 	mu sync.RWMutex
@@ -24,9 +41,76 @@ type TrackRemote struct {
 	params      RTPParameters
 	rid         string
 
-	receiver         *RTPReceiver
-	peeked           []byte
-	peekedAttributes interceptor.Attributes
+	receiver *RTPReceiver
+
+	// peeked are the packets read by peek, they are returned by Read as they were
+	// received, before they are transformed and FEC is handled
+	peeked []peekedPacket
+
+	// early are the packets read before the track was created, see EarlyMediaHandling
+	early [][]byte
+
+	// timestampReference maps rtpTimestampReference to a wallclock time, it
+	// is taken from the last Sender Report or the arrival of the first packet
+	timestampReference    time.Time
+	rtpTimestampReference uint32
+
+	// newestTimestamp is the newest RTP timestamp received, it is used to discard
+	// packets older than SettingEngine.SetMaxRTPPacketAge
+	newestTimestamp    uint32
+	hasNewestTimestamp bool
+
+	sampleLock    sync.Mutex
+	sampleBuilder *samplebuilder.SampleBuilder
+
+	// sampleSequenceOffset counts the telephone events skipped by ReadSample, the
+	// sequence numbers of the media packets are shifted to close the gaps they leave
+	sampleSequenceOffset uint16
+
+	// telephoneEvent is the DTMF tone received last
+	telephoneEvent telephoneEventState
+
+	// muted is set when the remote stops sending, either after the timeout of
+	// SettingEngine.SetRemoteTrackMuteTimeout or by renegotiation. The next packet
+	// unmutes the track.
+	muted           bool
+	muteTimer       *time.Timer
+	onMuteHandler   func()
+	onUnmuteHandler func()
+
+	// fec is created once the first RED, ULPFEC or FlexFEC packet arrives
+	fec *fecDecoder
+
+	statsID string
+	stats   struct {
+		mu                           sync.Mutex
+		packetsReceived              uint32
+		packetsDiscarded             uint32
+		bytesReceived                uint64
+		headerBytesReceived          uint64
+		retransmittedPacketsReceived uint64
+		retransmittedBytesReceived   uint64
+		lastPacketReceivedTimestamp  time.Time
+		firCount                     uint32
+		pliCount                     uint32
+		nackCount                    uint32
+
+		// keyFramesReceived counts the packets that start a keyframe, packets of
+		// the same frame share the timestamp of the last one
+		keyFramesReceived     uint32
+		lastKeyFrameTimestamp uint32
+
+		// nackedSequenceNumbers are the packets requested by NACKs, a packet that
+		// arrives after it has been requested is counted as retransmitted
+		nackedSequenceNumbers map[uint16]struct{}
+
+		// Sender Reports and round trip times measured with RTCP XR DLRR reports
+		// describe the stream at the remote
+		senderReport              *rtcp.SenderReport
+		roundTripTime             time.Duration
+		totalRoundTripTime        time.Duration
+		roundTripTimeMeasurements uint64
+	}
 }
 
 func newTrackRemote(kind RTPCodecType, ssrc SSRC, rid string, receiver *RTPReceiver) *TrackRemote {
@@ -34,6 +118,7 @@ func newTrackRemote(kind RTPCodecType, ssrc SSRC, rid string, receiver *RTPRecei
 		kind:     kind,
 		ssrc:     ssrc,
 		rid:      rid,
+		statsID:  fmt.Sprintf("TrackRemote-%d", time.Now().UnixNano()),
 		receiver: receiver,
 	}
 }
@@ -97,47 +182,450 @@ func (t *TrackRemote) Codec() RTPCodecParameters {
 	return t.codec
 }
 
-// Read reads data from the track.
+// Read reads data from the track. The RTP packet is returned as it was received,
+// header extensions included, so it can be forwarded with TrackLocalStaticRTP.Write
+// without the application parsing the extensions.
+//
+// If SettingEngine.SetMaxRTPPacketAge is set, packets that are older than the maximum
+// age relative to the newest packet received are discarded and Read continues with
+// the next one. The same applies to packets the PayloadTransform of the RTPReceiver
+// fails to transform, a transformed packet that doesn't fit into b is reported with
+// io.ErrShortBuffer instead.
 func (t *TrackRemote) Read(b []byte) (n int, attributes interceptor.Attributes, err error) {
-	t.mu.RLock()
-	r := t.receiver
-	peeked := t.peeked != nil
-	t.mu.RUnlock()
+	for {
+		n, attributes, err = t.read(b)
+		if err != nil || !t.isStale(b[:n]) {
+			return
+		}
 
-	if peeked {
-		t.mu.Lock()
-		data := t.peeked
-		attributes = t.peekedAttributes
+		t.countDiscarded()
+	}
+}
 
-		t.peeked = nil
-		t.peekedAttributes = nil
-		t.mu.Unlock()
-		// someone else may have stolen our packet when we
-		// released the lock.  Deal with it.
-		if data != nil {
-			n = copy(b, data)
-			err = t.checkAndUpdateTrack(b)
+func (t *TrackRemote) countDiscarded() {
+	t.stats.mu.Lock()
+	t.stats.packetsDiscarded++
+	t.stats.mu.Unlock()
+}
+
+func (t *TrackRemote) read(b []byte) (n int, attributes interceptor.Attributes, err error) {
+	// Packets recovered from FEC are returned before the next packet is read
+	if recovered, ok := t.readRecovered(b); ok {
+		return recovered, nil, t.checkAndUpdateTrack(b)
+	}
+
+	r := t.receiver
+	for {
+		if n, attributes, err = t.readReceived(b); err != nil {
+			return
+		}
+
+		// The payload is transformed as it was sent, before RED and FEC are
+		// removed and before it is inspected for DTMF and keyframes
+		if n, err = r.transformPayload(b, n); errors.Is(err, io.ErrShortBuffer) {
 			return
+		} else if err != nil {
+			t.countDiscarded()
+			continue
+		}
+
+		var isFEC bool
+		if n, isFEC = t.handleFEC(b, n); !isFEC {
+			break
+		}
+
+		if recovered, ok := t.readRecovered(b); ok {
+			return recovered, attributes, t.checkAndUpdateTrack(b)
 		}
 	}
 
-	n, attributes, err = r.readRTP(b, t)
-	if err != nil {
+	if err = t.checkAndUpdateTrack(b); err != nil {
 		return
 	}
 
-	err = t.checkAndUpdateTrack(b)
+	t.handleTelephoneEvent(b[:n])
+	t.updateKeyFrameStats(b[:n])
+	t.updateMuted()
 	return
 }
 
+// Muted tells if the remote doesn't send the track, like the muted attribute of
+// a MediaStreamTrack. A track is muted when the remote stops sending it with a
+// renegotiation, or when no packets have been read for the timeout set with
+// SettingEngine.SetRemoteTrackMuteTimeout. It is unmuted by the next packet read.
+func (t *TrackRemote) Muted() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.muted
+}
+
+// OnMute sets a handler that is called when the track is muted
+func (t *TrackRemote) OnMute(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onMuteHandler = f
+}
+
+// OnUnmute sets a handler that is called when the track is unmuted
+func (t *TrackRemote) OnUnmute(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onUnmuteHandler = f
+}
+
+func (t *TrackRemote) setMuted(muted bool) {
+	t.mu.Lock()
+	if t.muted == muted {
+		t.mu.Unlock()
+		return
+	}
+	t.muted = muted
+	handler := t.onUnmuteHandler
+	if muted {
+		handler = t.onMuteHandler
+	}
+	t.mu.Unlock()
+
+	if handler != nil {
+		handler()
+	}
+}
+
+// updateMuted unmutes the track after a packet has been read and watches for the
+// next period without packets
+func (t *TrackRemote) updateMuted() {
+	timeout := t.receiver.api.settingEngine.remoteTrackMuteTimeout
+
+	t.mu.Lock()
+	if timeout > 0 && t.muteTimer == nil {
+		select {
+		case <-t.receiver.closed:
+		default:
+			t.muteTimer = time.AfterFunc(timeout, t.checkInactivity)
+		}
+	}
+	muted := t.muted
+	t.mu.Unlock()
+
+	if muted {
+		t.setMuted(false)
+	}
+}
+
+// checkInactivity mutes the track if no packet has been read during the timeout,
+// or checks again when the timeout has passed since the last one
+func (t *TrackRemote) checkInactivity() {
+	timeout := t.receiver.api.settingEngine.remoteTrackMuteTimeout
+
+	t.stats.mu.Lock()
+	inactive := time.Since(t.stats.lastPacketReceivedTimestamp)
+	t.stats.mu.Unlock()
+
+	t.mu.Lock()
+	if t.muteTimer == nil {
+		t.mu.Unlock()
+		return
+	}
+	if inactive < timeout {
+		t.muteTimer.Reset(timeout - inactive)
+		t.mu.Unlock()
+		return
+	}
+	t.muteTimer = nil
+	t.mu.Unlock()
+
+	t.setMuted(true)
+}
+
+// stopMuteTimer stops watching for inactivity once the RTPReceiver is stopped
+func (t *TrackRemote) stopMuteTimer() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.muteTimer != nil {
+		t.muteTimer.Stop()
+		t.muteTimer = nil
+	}
+}
+
+// handleFEC removes the RED encapsulation of a packet and passes it to the FEC
+// decoder. It returns true if the packet only carried FEC, it must not be returned
+// by Read then.
+func (t *TrackRemote) handleFEC(b []byte, n int) (int, bool) {
+	if n < 2 {
+		return n, false
+	}
+
+	// Packets of unknown payload types are handled like media packets
+	codec, _, _ := t.receiver.api.mediaEngine.getCodecByPayload(PayloadType(b[1] & rtpPayloadTypeBitmask))
+
+	header := &rtp.Header{}
+	switch {
+	case strings.EqualFold(codec.MimeType, MimeTypeRED), strings.EqualFold(codec.MimeType, MimeTypeAudioRED):
+		if err := header.Unmarshal(b[:n]); err != nil {
+			return 0, true
+		}
+		payloadEnd := n
+		if header.Padding {
+			payloadEnd -= int(b[n-1])
+		}
+		if payloadEnd < header.PayloadOffset {
+			return 0, true
+		}
+
+		blocks, err := parseRED(b[header.PayloadOffset:payloadEnd])
+		if err != nil {
+			return 0, true
+		}
+		payloadType, data := blocks[len(blocks)-1].payloadType, blocks[len(blocks)-1].data
+
+		if primary, _, _ := t.receiver.api.mediaEngine.getCodecByPayload(PayloadType(payloadType)); strings.EqualFold(primary.MimeType, MimeTypeULPFEC) {
+			t.getFECDecoder(true).addULPFEC(header.SSRC, data)
+			return 0, true
+		}
+
+		// Packets lost before this one are recovered from the redundant blocks
+		fec := t.getFECDecoder(true)
+		if len(blocks) > 1 {
+			fec.addRedundant(header, blocks)
+		}
+
+		// Replace the RED payload with the primary block, padding is removed with it
+		b[0] &^= 0x20
+		b[1] = b[1]&^rtpPayloadTypeBitmask | payloadType
+		n = header.PayloadOffset + copy(b[header.PayloadOffset:], data)
+		fec.addMedia(b[:n])
+	case strings.EqualFold(codec.MimeType, MimeTypeULPFEC):
+		if err := header.Unmarshal(b[:n]); err != nil {
+			return 0, true
+		}
+		t.getFECDecoder(true).addULPFEC(header.SSRC, b[header.PayloadOffset:n])
+		return 0, true
+	default:
+		if fec := t.getFECDecoder(false); fec != nil {
+			fec.addMedia(b[:n])
+		}
+	}
+
+	return n, false
+}
+
+// readRecovered copies the next packet recovered from FEC into b
+func (t *TrackRemote) readRecovered(b []byte) (int, bool) {
+	fec := t.getFECDecoder(false)
+	if fec == nil {
+		return 0, false
+	}
+
+	data := fec.popRecovered()
+	if data == nil {
+		return 0, false
+	}
+	return copy(b, data), true
+}
+
+func (t *TrackRemote) getFECDecoder(create bool) *fecDecoder {
+	t.mu.RLock()
+	fec := t.fec
+	t.mu.RUnlock()
+	if fec != nil || !create {
+		return fec
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fec == nil {
+		t.fec = &fecDecoder{}
+	}
+	return t.fec
+}
+
+// updateStats accounts a RTP packet that has been decrypted for the track
+func (t *TrackRemote) updateStats(b []byte) {
+	header := &rtp.Header{}
+	if err := header.Unmarshal(b); err != nil {
+		return
+	}
+	payloadLength := len(b) - header.PayloadOffset
+
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	t.stats.packetsReceived++
+	t.stats.bytesReceived += uint64(payloadLength)
+	t.stats.headerBytesReceived += uint64(header.PayloadOffset)
+	t.stats.lastPacketReceivedTimestamp = time.Now()
+
+	if _, ok := t.stats.nackedSequenceNumbers[header.SequenceNumber]; ok {
+		delete(t.stats.nackedSequenceNumbers, header.SequenceNumber)
+		t.stats.retransmittedPacketsReceived++
+		t.stats.retransmittedBytesReceived += uint64(payloadLength)
+	}
+}
+
+// updateKeyFrameStats counts the key frames read
+func (t *TrackRemote) updateKeyFrameStats(b []byte) {
+	header := &rtp.Header{}
+	if err := header.Unmarshal(b); err != nil {
+		return
+	}
+
+	payload := b[header.PayloadOffset:]
+	if header.Padding && len(payload) != 0 && int(payload[len(payload)-1]) <= len(payload) {
+		payload = payload[:len(payload)-int(payload[len(payload)-1])]
+	}
+
+	t.mu.RLock()
+	mimeType := t.codec.MimeType
+	t.mu.RUnlock()
+
+	if !isKeyFramePacket(mimeType, payload) {
+		return
+	}
+
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	if t.stats.keyFramesReceived == 0 || header.Timestamp != t.stats.lastKeyFrameTimestamp {
+		t.stats.keyFramesReceived++
+		t.stats.lastKeyFrameTimestamp = header.Timestamp
+	}
+}
+
+// handleFeedbackSent counts the feedback sent to the remote about this track
+func (t *TrackRemote) handleFeedbackSent(pkt rtcp.Packet) {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+
+	switch pkt := pkt.(type) {
+	case *rtcp.FullIntraRequest:
+		t.stats.firCount++
+	case *rtcp.PictureLossIndication:
+		t.stats.pliCount++
+	case *rtcp.TransportLayerNack:
+		t.stats.nackCount++
+
+		// Requests that are never answered mustn't accumulate
+		if t.stats.nackedSequenceNumbers == nil || len(t.stats.nackedSequenceNumbers) > maxNACKedSequenceNumbers {
+			t.stats.nackedSequenceNumbers = map[uint16]struct{}{}
+		}
+		for _, pair := range pkt.Nacks {
+			for _, sequenceNumber := range pair.PacketList() {
+				t.stats.nackedSequenceNumbers[sequenceNumber] = struct{}{}
+			}
+		}
+	}
+}
+
+func (t *TrackRemote) collectStats(collector *statsReportCollector) {
+	collector.Collecting()
+
+	t.mu.RLock()
+	stats := InboundRTPStreamStats{
+		Timestamp: collector.timestamp,
+		Type:      StatsTypeInboundRTP,
+		ID:        t.statsID,
+		SSRC:      t.ssrc,
+		Kind:      t.kind.String(),
+		CodecID:   t.codec.statsID,
+	}
+	t.mu.RUnlock()
+
+	t.stats.mu.Lock()
+	stats.PacketsReceived = t.stats.packetsReceived
+	stats.PacketsDiscarded = t.stats.packetsDiscarded
+	stats.BytesReceived = t.stats.bytesReceived
+	stats.HeaderBytesReceived = t.stats.headerBytesReceived
+	stats.RetransmittedPacketsReceived = t.stats.retransmittedPacketsReceived
+	stats.RetransmittedBytesReceived = t.stats.retransmittedBytesReceived
+	stats.FIRCount = t.stats.firCount
+	stats.PLICount = t.stats.pliCount
+	stats.NACKCount = t.stats.nackCount
+	stats.KeyFramesReceived = t.stats.keyFramesReceived
+	if !t.stats.lastPacketReceivedTimestamp.IsZero() {
+		stats.LastPacketReceivedTimestamp = statsTimestampFrom(t.stats.lastPacketReceivedTimestamp)
+	}
+
+	var remoteStats *RemoteOutboundRTPStreamStats
+	if t.stats.senderReport != nil || t.stats.roundTripTimeMeasurements != 0 {
+		remoteStats = &RemoteOutboundRTPStreamStats{
+			Timestamp:                 collector.timestamp,
+			Type:                      StatsTypeRemoteOutboundRTP,
+			ID:                        "RemoteOutbound" + stats.ID,
+			SSRC:                      stats.SSRC,
+			Kind:                      stats.Kind,
+			CodecID:                   stats.CodecID,
+			LocalID:                   stats.ID,
+			RoundTripTime:             t.stats.roundTripTime.Seconds(),
+			TotalRoundTripTime:        t.stats.totalRoundTripTime.Seconds(),
+			RoundTripTimeMeasurements: t.stats.roundTripTimeMeasurements,
+		}
+		if sr := t.stats.senderReport; sr != nil {
+			remoteStats.PacketsSent = sr.PacketCount
+			remoteStats.BytesSent = uint64(sr.OctetCount)
+			remoteStats.RemoteTimestamp = statsTimestampFrom(ntpToTime(sr.NTPTime))
+		}
+	}
+	t.stats.mu.Unlock()
+
+	if fec := t.getFECDecoder(false); fec != nil {
+		stats.FECPacketsReceived, stats.FECPacketsDiscarded, stats.PacketsRecovered = fec.stats()
+	}
+
+	collector.Collect(stats.ID, stats)
+
+	if remoteStats != nil {
+		collector.Collecting()
+		collector.Collect(remoteStats.ID, *remoteStats)
+	}
+}
+
+// isStale reports whether the RTP timestamp of the packet is older than the maximum
+// packet age relative to the newest RTP timestamp received
+func (t *TrackRemote) isStale(b []byte) bool {
+	maxAge := t.receiver.api.settingEngine.maxRTPPacketAge
+	if maxAge == 0 || len(b) < rtpTimestampOffset+4 {
+		return false
+	}
+	timestamp := binary.BigEndian.Uint32(b[rtpTimestampOffset:])
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.hasNewestTimestamp || int32(timestamp-t.newestTimestamp) > 0 {
+		t.newestTimestamp = timestamp
+		t.hasNewestTimestamp = true
+		return false
+	}
+
+	if t.codec.ClockRate == 0 {
+		return false
+	}
+	return time.Duration(t.newestTimestamp-timestamp)*time.Second/time.Duration(t.codec.ClockRate) > maxAge
+}
+
 // checkAndUpdateTrack checks payloadType for every incoming packet
-// once a different payloadType is detected the track will be updated
+// once a different payloadType is detected the track will be updated.
+// Telephone events are sent between the packets of the audio codec, they
+// don't change the codec of the track.
 func (t *TrackRemote) checkAndUpdateTrack(b []byte) error {
 	if len(b) < 2 {
 		return errRTPTooShort
 	}
 
-	if payloadType := PayloadType(b[1] & rtpPayloadTypeBitmask); payloadType != t.PayloadType() {
+	return t.updatePayloadType(PayloadType(b[1] & rtpPayloadTypeBitmask))
+}
+
+// updatePayloadType sets the codec of the track to the one of the payload type
+func (t *TrackRemote) updatePayloadType(payloadType PayloadType) error {
+	if payloadType != t.PayloadType() {
+		if _, ok := t.telephoneEventCodec(payloadType); ok && t.Codec().MimeType != "" {
+			return nil
+		}
+
 		t.mu.Lock()
 		defer t.mu.Unlock()
 
@@ -170,26 +658,262 @@ func (t *TrackRemote) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
 	return r, attributes, nil
 }
 
-// peek is like Read, but it doesn't discard the packet read
-func (t *TrackRemote) peek(b []byte) (n int, a interceptor.Attributes, err error) {
-	n, a, err = t.Read(b)
-	if err != nil {
-		return
+// readEarly returns the next packet read before the track was created, ok is false
+// if there are none left. The RTPReceiver passes them to the interceptors before
+// the packets of its stream.
+func (t *TrackRemote) readEarly(b []byte) (n int, ok bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.early) == 0 {
+		return 0, false, nil
 	}
 
+	data := t.early[0]
+	t.early = t.early[1:]
+	if len(b) < len(data) {
+		return 0, true, io.ErrShortBuffer
+	}
+	return copy(b, data), true, nil
+}
+
+// setEarlyPackets sets the packets that are returned before the packets of the receiver
+func (t *TrackRemote) setEarlyPackets(packets [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.early = packets
+}
+
+type peekedPacket struct {
+	data       []byte
+	attributes interceptor.Attributes
+}
+
+// readReceived returns the next packet peeked or read from the receiver
+func (t *TrackRemote) readReceived(b []byte) (int, interceptor.Attributes, error) {
 	t.mu.Lock()
-	// this might overwrite data if somebody peeked between the Read
-	// and us getting the lock.  Oh well, we'll just drop a packet in
-	// that case.
-	data := make([]byte, n)
-	n = copy(data, b[:n])
-	t.peeked = data
-	t.peekedAttributes = a
+	if len(t.peeked) == 0 {
+		t.mu.Unlock()
+		return t.receiver.readRTP(b, t)
+	}
+	p := t.peeked[0]
+	t.peeked = t.peeked[1:]
 	t.mu.Unlock()
-	return
+
+	if len(b) < len(p.data) {
+		return 0, nil, io.ErrShortBuffer
+	}
+	return copy(b, p.data), p.attributes, nil
+}
+
+// peek reads packets until one carries media and returns its payload type. The
+// packets are kept and returned by Read, so the PayloadTransform set when the track
+// is announced applies to them.
+func (t *TrackRemote) peek(b []byte) (PayloadType, error) {
+	for {
+		n, a, err := t.receiver.readRTP(b, t)
+		if err != nil {
+			return 0, err
+		}
+		if n < 2 {
+			return 0, errRTPTooShort
+		}
+
+		t.mu.Lock()
+		t.peeked = append(t.peeked, peekedPacket{data: append([]byte{}, b[:n]...), attributes: a})
+		t.mu.Unlock()
+
+		if payloadType, ok := t.mediaPayloadType(b[:n]); ok {
+			return payloadType, nil
+		}
+	}
+}
+
+// mediaPayloadType returns the payload type of the media in a packet as it was
+// received, which is the primary one of a RED packet. Packets that only carry FEC
+// don't have one.
+func (t *TrackRemote) mediaPayloadType(b []byte) (PayloadType, bool) {
+	payloadType := PayloadType(b[1] & rtpPayloadTypeBitmask)
+
+	codec, _, _ := t.receiver.api.mediaEngine.getCodecByPayload(payloadType)
+	switch {
+	case strings.EqualFold(codec.MimeType, MimeTypeULPFEC):
+		return 0, false
+	case strings.EqualFold(codec.MimeType, MimeTypeRED), strings.EqualFold(codec.MimeType, MimeTypeAudioRED):
+		header := &rtp.Header{}
+		if err := header.Unmarshal(b); err != nil {
+			return payloadType, true
+		}
+		// The payload can't be parsed if it is still to be transformed
+		primary, _, err := parseREDPrimary(b[header.PayloadOffset:])
+		if err != nil {
+			return payloadType, true
+		}
+		if primaryCodec, _, _ := t.receiver.api.mediaEngine.getCodecByPayload(PayloadType(primary)); strings.EqualFold(primaryCodec.MimeType, MimeTypeULPFEC) {
+			return 0, false
+		}
+		return PayloadType(primary), true
+	default:
+		return payloadType, true
+	}
 }
 
 // SetReadDeadline sets the max amount of time the RTP stream will block before returning. 0 is forever.
 func (t *TrackRemote) SetReadDeadline(deadline time.Time) error {
 	return t.receiver.setRTPReadDeadline(deadline, t)
 }
+
+// ReadSample reads RTP packets until a complete Sample has been assembled and returns it.
+// Packets are reordered and Samples with lost packets are dropped, PrevDroppedPackets
+// counts their packets. The Timestamp of a Sample is the sender's wallclock time taken
+// from RTCP Sender Reports, so Samples of different tracks from the same sender can be
+// synchronized. Sender Reports are only seen while RTCP is read from the RTPReceiver,
+// before the first one the Timestamp is estimated from the arrival time of the packets.
+//
+// ReadSample supports VP8, VP9, H264, Opus, G722, PCMU and PCMA. It must not be mixed
+// with Read or ReadRTP, as the packets they return are missing from the Samples.
+// Telephone events are skipped, they are reported by RTPReceiver.OnDTMFTone.
+func (t *TrackRemote) ReadSample() (*media.Sample, error) {
+	t.sampleLock.Lock()
+	defer t.sampleLock.Unlock()
+
+	for {
+		if t.sampleBuilder != nil {
+			if sample := t.sampleBuilder.Pop(); sample != nil {
+				sample.Timestamp = t.sampleTimestamp(sample.PacketTimestamp)
+				return sample, nil
+			}
+		}
+
+		packet, _, err := t.ReadRTP()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := t.telephoneEventCodec(PayloadType(packet.PayloadType)); ok {
+			t.sampleSequenceOffset++
+			continue
+		}
+		packet.SequenceNumber -= t.sampleSequenceOffset
+
+		// The codec is known once the first packet has been read
+		if t.sampleBuilder == nil {
+			codec := t.Codec()
+			depacketizer, depacketizerErr := newDepacketizer(codec.MimeType)
+			if depacketizerErr != nil {
+				return nil, depacketizerErr
+			}
+			t.sampleBuilder = samplebuilder.New(sampleMaxLate, depacketizer, codec.ClockRate)
+		}
+
+		t.mu.Lock()
+		if t.timestampReference.IsZero() {
+			t.timestampReference = time.Now()
+			t.rtpTimestampReference = packet.Timestamp
+		}
+		t.mu.Unlock()
+
+		t.sampleBuilder.Push(packet)
+	}
+}
+
+// sampleTimestamp converts a RTP timestamp to a wallclock time. The reference is moved
+// to every Sample so that the difference to it never wraps around.
+func (t *TrackRemote) sampleTimestamp(rtpTimestamp uint32) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.codec.ClockRate == 0 {
+		return t.timestampReference
+	}
+
+	delta := int64(int32(rtpTimestamp - t.rtpTimestampReference))
+	t.timestampReference = t.timestampReference.Add(time.Duration(delta * int64(time.Second) / int64(t.codec.ClockRate)))
+	t.rtpTimestampReference = rtpTimestamp
+	return t.timestampReference
+}
+
+// handleRTCP takes the wallclock time of the Sender Reports of this track as reference
+// for the Timestamp of the Samples returned by ReadSample
+func (t *TrackRemote) handleRTCP(b []byte) {
+	pkts, err := rtcp.Unmarshal(b)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, pkt := range pkts {
+		if sr, ok := pkt.(*rtcp.SenderReport); ok && SSRC(sr.SSRC) == t.ssrc {
+			t.timestampReference = ntpToTime(sr.NTPTime)
+			t.rtpTimestampReference = sr.RTPTime
+
+			t.stats.mu.Lock()
+			t.stats.senderReport = sr
+			t.stats.mu.Unlock()
+		}
+	}
+
+	// The remote echoes the reference times of our Receiver Reports in DLRR reports,
+	// see ConfigureRTCPExtendedReports
+	arrival := time.Now()
+	for _, report := range rtcpxr.FromPackets(pkts) {
+		if SSRC(report.SenderSSRC) != t.ssrc {
+			continue
+		}
+		for _, block := range report.Reports {
+			dlrr, ok := block.(*rtcpxr.DLRRReportBlock)
+			if !ok {
+				continue
+			}
+			for _, r := range dlrr.Reports {
+				if rtt, ok := r.RoundTripTime(arrival); ok {
+					t.stats.mu.Lock()
+					t.stats.roundTripTime = rtt
+					t.stats.totalRoundTripTime += rtt
+					t.stats.roundTripTimeMeasurements++
+					t.stats.mu.Unlock()
+				}
+			}
+		}
+	}
+}
+
+// ntpToTime converts a 64 bit NTP timestamp, seconds since 1900 in 32.32 fixed point
+func ntpToTime(ntp uint64) time.Time {
+	const ntpEpochOffset = 2208988800
+
+	seconds := int64(ntp>>32) - ntpEpochOffset
+	nanoseconds := (ntp & 0xFFFFFFFF) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanoseconds))
+}
+
+func newDepacketizer(mimeType string) (rtp.Depacketizer, error) {
+	switch {
+	case strings.EqualFold(mimeType, MimeTypeVP8):
+		return &codecs.VP8Packet{}, nil
+	case strings.EqualFold(mimeType, MimeTypeVP9):
+		return &codecs.VP9Packet{}, nil
+	case strings.EqualFold(mimeType, MimeTypeH264):
+		return &codecs.H264Packet{}, nil
+	case strings.EqualFold(mimeType, MimeTypeOpus):
+		return &codecs.OpusPacket{}, nil
+	case strings.EqualFold(mimeType, MimeTypeG722),
+		strings.EqualFold(mimeType, MimeTypePCMU),
+		strings.EqualFold(mimeType, MimeTypePCMA):
+		return &rawDepacketizer{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errTrackRemoteReadSampleCodecUnsupported, mimeType)
+	}
+}
+
+// rawDepacketizer is used for codecs that carry one frame in a payload without any header
+type rawDepacketizer struct{}
+
+func (d *rawDepacketizer) IsDetectedFinalPacketInSequence(bool) bool {
+	return true
+}
+
+func (d *rawDepacketizer) Unmarshal(payload []byte) ([]byte, error) {
+	return payload, nil
+}
@@ -1,3 +1,300 @@
 // +build !js
 
 package webrtc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackRemote_ReadSample(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+
+	_, err = pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	sampleData := []byte{0x10, 0x20, 0x30, 0x40}
+	samplesRead, samplesReadCancel := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(trackRemote *TrackRemote, r *RTPReceiver) {
+		var last *media.Sample
+		for i := 0; i < 5; i++ {
+			sample, readErr := trackRemote.ReadSample()
+			assert.NoError(t, readErr)
+			assert.True(t, bytes.Equal(sampleData, sample.Data))
+			assert.False(t, sample.Timestamp.IsZero())
+
+			if last != nil {
+				// The samples are written 20ms apart, so their RTP timestamps differ by 1800
+				assert.Equal(t, uint32(1800), sample.PacketTimestamp-last.PacketTimestamp)
+				assert.Equal(t, 20*time.Millisecond, sample.Timestamp.Sub(last.Timestamp))
+			}
+			last = sample
+		}
+		samplesReadCancel()
+	})
+
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+
+	for {
+		select {
+		case <-samplesRead.Done():
+			closePairNow(t, pcOffer, pcAnswer)
+			return
+		case <-time.After(20 * time.Millisecond):
+			assert.NoError(t, track.WriteSample(media.Sample{Data: sampleData, Duration: 20 * time.Millisecond}))
+		}
+	}
+}
+
+func TestTrackRemote_SenderReportTimestamp(t *testing.T) {
+	track := newTrackRemote(RTPCodecTypeVideo, 1234, "", nil)
+	track.codec = RTPCodecParameters{RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeVP8, ClockRate: 90000}}
+
+	// 2021-01-01 00:00:00.5 UTC
+	sr, err := (&rtcp.SenderReport{SSRC: 1234, NTPTime: 3818448000<<32 | 1<<31, RTPTime: 90000}).Marshal()
+	assert.NoError(t, err)
+	track.handleRTCP(sr)
+
+	// Reports of other streams are ignored
+	sr, err = (&rtcp.SenderReport{SSRC: 5678, NTPTime: 0, RTPTime: 0}).Marshal()
+	assert.NoError(t, err)
+	track.handleRTCP(sr)
+
+	reference := time.Date(2021, 1, 1, 0, 0, 0, int(500*time.Millisecond), time.UTC)
+	assert.True(t, reference.Add(time.Second).Equal(track.sampleTimestamp(180000)))
+	assert.True(t, reference.Add(-time.Second).Equal(track.sampleTimestamp(0)))
+
+	// Differences are computed across the wrap around of the RTP timestamp
+	assert.True(t, reference.Add(-2*time.Second).Equal(track.sampleTimestamp(0xFFFFFFFF-89999)))
+}
+
+func TestTrackRemote_MaxRTPPacketAge(t *testing.T) {
+	s := SettingEngine{}
+	s.SetMaxRTPPacketAge(100 * time.Millisecond)
+	api := NewAPI(WithSettingEngine(s))
+
+	track := newTrackRemote(RTPCodecTypeVideo, 1234, "", &RTPReceiver{api: api})
+	track.codec = RTPCodecParameters{RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeVP8, ClockRate: 90000}}
+
+	isStale := func(timestamp uint32) bool {
+		b, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1234, Timestamp: timestamp}}).Marshal()
+		assert.NoError(t, err)
+		return track.isStale(b)
+	}
+
+	assert.False(t, isStale(90000))
+	assert.False(t, isStale(99000))
+	// 100ms older than the newest packet is still accepted
+	assert.False(t, isStale(90000))
+	assert.True(t, isStale(89999))
+
+	// The newest timestamp follows the wrap around
+	track.hasNewestTimestamp = false
+	assert.False(t, isStale(0xFFFFFFFF))
+	assert.False(t, isStale(4500))
+	assert.True(t, isStale(0xFFFFFFFF-9000))
+}
+
+func TestTrackRemote_REDULPFEC(t *testing.T) {
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	}, RTPCodecTypeVideo))
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeRED, ClockRate: 90000},
+		PayloadType:        117,
+	}, RTPCodecTypeVideo))
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeULPFEC, ClockRate: 90000},
+		PayloadType:        116,
+	}, RTPCodecTypeVideo))
+
+	track := newTrackRemote(RTPCodecTypeVideo, 1234, "", &RTPReceiver{api: NewAPI(WithMediaEngine(m))})
+
+	packets := [][]byte{
+		newFECTestPacket(t, 1, false, []byte{0x01, 0x02}),
+		newFECTestPacket(t, 2, true, []byte{0x03}),
+	}
+
+	// Wraps data into RED, the payload type of the primary block is 96 or 116
+	wrapRED := func(header rtp.Header, payloadType uint8, data []byte) []byte {
+		header.PayloadType = 117
+		b, err := (&rtp.Packet{Header: header, Payload: append([]byte{payloadType}, data...)}).Marshal()
+		assert.NoError(t, err)
+		return b
+	}
+
+	// The RED encapsulation of media packets is removed
+	media := &rtp.Packet{}
+	assert.NoError(t, media.Unmarshal(packets[1]))
+
+	b := make([]byte, receiveMTU)
+	n, isFEC := track.handleFEC(b, copy(b, wrapRED(media.Header, 96, media.Payload)))
+	assert.False(t, isFEC)
+	assert.Equal(t, packets[1], b[:n])
+
+	// The first packet has been lost, the ULPFEC packet recovers it
+	n, isFEC = track.handleFEC(b, copy(b, wrapRED(rtp.Header{Version: 2, SequenceNumber: 3, SSRC: 1234}, 116, marshalULPFECTestPacket(1, 0xC000, packets))))
+	assert.True(t, isFEC)
+	assert.Equal(t, 0, n)
+
+	n, ok := track.readRecovered(b)
+	assert.True(t, ok)
+	assert.Equal(t, packets[0], b[:n])
+
+	_, ok = track.readRecovered(b)
+	assert.False(t, ok)
+
+	received, discarded, recovered := track.getFECDecoder(false).stats()
+	assert.Equal(t, uint32(1), received)
+	assert.Equal(t, uint32(0), discarded)
+	assert.Equal(t, uint32(1), recovered)
+}
+
+func TestTrackRemote_AudioRED(t *testing.T) {
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	}, RTPCodecTypeAudio))
+	assert.NoError(t, m.RegisterCodec(RTPCodecParameters{
+		RTPCodecCapability: RTPCodecCapability{MimeType: MimeTypeAudioRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"},
+		PayloadType:        63,
+	}, RTPCodecTypeAudio))
+
+	track := newTrackRemote(RTPCodecTypeAudio, 1234, "", &RTPReceiver{api: NewAPI(WithMediaEngine(m))})
+
+	encoder := newREDEncoder(63, 111)
+	packets := [][]byte{}
+	for i := 0; i < 3; i++ {
+		header, payload, err := encoder.encode(&rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: uint16(i + 1), Timestamp: uint32(i * 960), SSRC: 1234}, []byte{byte(i), byte(i)})
+		assert.NoError(t, err)
+
+		b, err := (&rtp.Packet{Header: *header, Payload: payload}).Marshal()
+		assert.NoError(t, err)
+		packets = append(packets, b)
+	}
+
+	expected := func(i int) []byte {
+		b, err := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: uint16(i + 1), Timestamp: uint32(i * 960), SSRC: 1234}, Payload: []byte{byte(i), byte(i)}}).Marshal()
+		assert.NoError(t, err)
+		return b
+	}
+
+	b := make([]byte, receiveMTU)
+	n, isFEC := track.handleFEC(b, copy(b, packets[0]))
+	assert.False(t, isFEC)
+	assert.Equal(t, expected(0), b[:n])
+
+	// The second packet has been lost, the third one carries it as redundancy
+	n, isFEC = track.handleFEC(b, copy(b, packets[2]))
+	assert.False(t, isFEC)
+	assert.Equal(t, expected(2), b[:n])
+
+	n, ok := track.readRecovered(b)
+	assert.True(t, ok)
+	assert.Equal(t, expected(1), b[:n])
+
+	_, ok = track.readRecovered(b)
+	assert.False(t, ok)
+}
+
+func TestTrackRemote_Mute(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, err := NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	m := &MediaEngine{}
+	assert.NoError(t, m.RegisterDefaultCodecs())
+	s := SettingEngine{}
+	s.SetRemoteTrackMuteTimeout(time.Second)
+	pcAnswer, err := NewAPI(WithMediaEngine(m), WithSettingEngine(s)).NewPeerConnection(Configuration{})
+	assert.NoError(t, err)
+
+	track, err := NewTrackLocalStaticSample(RTPCodecCapability{MimeType: MimeTypeVP8}, "video", "pion")
+	assert.NoError(t, err)
+	sender, err := pcOffer.AddTrack(track)
+	assert.NoError(t, err)
+
+	events := make(chan string, 4)
+	var trackRemote *TrackRemote
+	trackStarted, readDone := make(chan struct{}), make(chan struct{})
+	pcAnswer.OnTrack(func(remote *TrackRemote, r *RTPReceiver) {
+		defer close(readDone)
+
+		trackRemote = remote
+		trackRemote.OnMute(func() { events <- "mute" })
+		trackRemote.OnUnmute(func() { events <- "unmute" })
+		close(trackStarted)
+		for {
+			if _, _, readErr := trackRemote.ReadRTP(); readErr != nil {
+				return
+			}
+		}
+	})
+
+	connected := untilConnectionState(PeerConnectionStateConnected, pcOffer, pcAnswer)
+	assert.NoError(t, signalPair(pcOffer, pcAnswer))
+	connected.Wait()
+
+	writeUntil := func(done <-chan struct{}) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Duration: 20 * time.Millisecond}))
+			}
+		}
+	}
+
+	// The track is muted when the sender pauses and unmuted when it resumes
+	writeUntil(trackStarted)
+	assert.False(t, trackRemote.Muted())
+	assert.Equal(t, "mute", <-events)
+	assert.True(t, trackRemote.Muted())
+
+	unmuted := make(chan struct{})
+	go func() {
+		assert.Equal(t, "unmute", <-events)
+		close(unmuted)
+	}()
+	writeUntil(unmuted)
+	assert.False(t, trackRemote.Muted())
+
+	// The track is muted as soon as the remote stops sending it with a renegotiation
+	assert.NoError(t, pcOffer.RemoveTrack(sender))
+	offer, err := pcOffer.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pcOffer.SetLocalDescription(offer))
+	assert.NoError(t, pcAnswer.SetRemoteDescription(offer))
+	assert.True(t, trackRemote.Muted())
+	assert.Equal(t, "mute", <-events)
+
+	closePairNow(t, pcOffer, pcAnswer)
+	<-readDone
+}